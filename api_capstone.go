@@ -0,0 +1,387 @@
+package main
+
+/*
+端到端场景：限流、缓存、多副本的API服务
+
+原理：
+一个面向公网的读多写少API，通常由几层保护叠加而成，每一层解决一类问题：
+1. 按客户端IP限流：个别客户端（爬虫、失控的脚本）的突发流量在入口处被拒绝，不会挤占正常用户的配额
+2. 响应缓存：热点数据直接从LRU缓存返回；同一个键的并发未命中只回源一次（singleflight），
+   临近过期时后台提前刷新，后端故障时在宽限期内返回旧值
+3. 多副本存储：数据写入异地容灾系统，同步复制到备份数据中心，主数据中心故障时自动切换
+本场景把包中的这些模块用HTTP中间件串起来，并通过故障注入开关观察每一层在故障下的表现。
+
+关键特点：
+1. 中间件按 限流 -> 缓存 -> 后端 的顺序组合，每一层都是普通的 http.Handler
+2. 故障注入：后端延迟、后端随机错误、主数据中心宕机三种开关可以随时打开
+3. 每个阶段结束后汇总各层指标：限流比例、缓存命中率、回源次数、合并的回源、兜底返回的旧值、5xx比例和P99延迟
+
+实现方式：
+- 限流：KeyedRateLimiter，以请求的来源IP为键，每个IP一个令牌桶
+- 缓存：LoadingCache 装饰主包的泛型LRU，加载函数把请求转发给后端并记录响应，非200响应不进入缓存
+- 后端：以 DisasterRecoverySystem 为存储的KV接口（GET/PUT /kv/{key}），写入后使缓存失效
+- 指标：各层计数器的阶段差值，延迟分布使用 T-Digest 统计，最后用 report 包输出对比表
+
+应用场景：
+- 理解各个模块如何组合成一个完整的服务
+- 评估某一层保护被关闭或参数调整后对整体的影响
+- 故障演练：在上线前观察服务在后端变慢、报错、机房故障时的行为
+
+优缺点：
+- 优点：各层职责单一，可以单独替换或调整；故障下仍能提供大部分读服务
+- 缺点：缓存带来短暂的不一致（写入后其他实例上的缓存仍可能返回旧值，直到失效或过期）；
+  兜底旧值只能覆盖缓存过的键，冷门键在后端故障时仍然失败
+
+以下实现了一个由按IP限流、带singleflight的LRU响应缓存和多副本KV后端组成的API服务，并进行分阶段故障演练。
+*/
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/strive/scenario/cache_strategies"
+	"github.com/strive/scenario/lifecycle"
+	"github.com/strive/scenario/practical_applications"
+	"github.com/strive/scenario/report"
+	"github.com/strive/scenario/workload"
+)
+
+// chaosToggles 后端故障注入开关
+type chaosToggles struct {
+	latency   time.Duration // 每个后端请求额外增加的延迟
+	errorRate float64       // 后端请求随机失败的比例
+	rng       *rand.Rand
+	mutex     sync.Mutex
+}
+
+// set 设置故障注入参数
+func (c *chaosToggles) set(latency time.Duration, errorRate float64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.latency = latency
+	c.errorRate = errorRate
+}
+
+// inject 按当前开关注入延迟和错误
+func (c *chaosToggles) inject() error {
+	c.mutex.Lock()
+	latency := c.latency
+	failed := c.errorRate > 0 && c.rng.Float64() < c.errorRate
+	c.mutex.Unlock()
+
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+	if failed {
+		return errors.New("注入的后端错误")
+	}
+	return nil
+}
+
+// apiMetrics 各层计数器
+type apiMetrics struct {
+	requests      int64 // 客户端发出的请求数
+	rateLimited   int64 // 被限流拒绝的请求数（429）
+	serverErrors  int64 // 返回5xx的请求数
+	backendReads  int64 // 到达后端的读请求数
+	backendWrites int64 // 到达后端的写请求数
+}
+
+// snapshot 返回计数器的当前值
+func (m *apiMetrics) snapshot() apiMetrics {
+	return apiMetrics{
+		requests:      atomic.LoadInt64(&m.requests),
+		rateLimited:   atomic.LoadInt64(&m.rateLimited),
+		serverErrors:  atomic.LoadInt64(&m.serverErrors),
+		backendReads:  atomic.LoadInt64(&m.backendReads),
+		backendWrites: atomic.LoadInt64(&m.backendWrites),
+	}
+}
+
+// rateLimitMiddleware 按客户端IP限流，超出速率时返回429
+func rateLimitMiddleware(limiter *practical_applications.KeyedRateLimiter, metrics *apiMetrics, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+		if !limiter.Allow(ip) {
+			atomic.AddInt64(&metrics.rateLimited, 1)
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "请求过于频繁", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// cachedResponse 缓存的后端响应
+type cachedResponse struct {
+	status int
+	body   []byte
+}
+
+// responseError 后端返回的非200响应，作为加载错误返回，不会进入缓存
+type responseError struct {
+	cachedResponse
+}
+
+func (e *responseError) Error() string {
+	return fmt.Sprintf("后端返回 %d: %s", e.status, strings.TrimSpace(string(e.body)))
+}
+
+// newResponseCache 创建以主包LRU为存储的响应缓存，未命中时把GET请求转发给后端
+func newResponseCache(capacity int, options cache_strategies.LoadingCacheOptions, backend http.Handler) *cache_strategies.LoadingCache[string, cachedResponse] {
+	store := NewLRU[string, *cache_strategies.Loaded[cachedResponse]](capacity)
+	loader := func(path string) (cachedResponse, error) {
+		recorder := httptest.NewRecorder()
+		backend.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		response := cachedResponse{status: recorder.Code, body: recorder.Body.Bytes()}
+		if response.status != http.StatusOK {
+			return cachedResponse{}, &responseError{response}
+		}
+		return response, nil
+	}
+	return cache_strategies.NewLoadingCache[string, cachedResponse](store, loader, options)
+}
+
+// cacheMiddleware GET请求从响应缓存读取，其他请求转发给后端后使缓存失效
+func cacheMiddleware(cache *cache_strategies.LoadingCache[string, cachedResponse], next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			cache.Invalidate(r.URL.Path)
+			return
+		}
+
+		response, err := cache.Get(r.URL.Path)
+		var backendErr *responseError
+		switch {
+		case errors.As(err, &backendErr):
+			response = backendErr.cachedResponse
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(response.status)
+		w.Write(response.body)
+	})
+}
+
+// kvBackend 以异地容灾系统为存储的KV接口：GET/PUT /kv/{key}
+type kvBackend struct {
+	drs     *practical_applications.DisasterRecoverySystem
+	chaos   *chaosToggles
+	metrics *apiMetrics
+}
+
+func (b *kvBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/kv/")
+	if key == r.URL.Path || key == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		atomic.AddInt64(&b.metrics.backendReads, 1)
+		if err := b.chaos.inject(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		data, err := b.drs.Read(key)
+		switch {
+		case errors.Is(err, practical_applications.ErrDataNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		default:
+			w.Write(data)
+		}
+
+	case http.MethodPut:
+		atomic.AddInt64(&b.metrics.backendWrites, 1)
+		if err := b.chaos.inject(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if err := b.drs.Write(key, []byte(r.FormValue("value"))); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, "不支持的方法", http.StatusMethodNotAllowed)
+	}
+}
+
+// 场景示例：商品详情API在后端变慢、机房故障、后端报错时的表现
+func RateLimitedCachedAPIDemo() {
+	fmt.Println("限流 + 缓存 + 多副本 API 故障演练:")
+
+	// 后台组件统一交给生命周期管理器，演示结束时缓存先于它回源的存储层关闭
+	components := lifecycle.NewManager()
+	defer components.Stop()
+
+	// 存储层：上海为主，北京、广州为同步复制的备份
+	drs := practical_applications.NewDisasterRecoverySystem(practical_applications.ReplicationSync, time.Minute)
+	components.RegisterStopper("容灾存储", drs.Shutdown)
+	drs.AddDataCenter(practical_applications.NewDataCenter("dc-sh", "上海数据中心", "上海", true))
+	drs.AddDataCenter(practical_applications.NewDataCenter("dc-bj", "北京数据中心", "北京", false))
+	drs.AddDataCenter(practical_applications.NewDataCenter("dc-gz", "广州数据中心", "广州", false))
+
+	const keys = 200
+	for i := 0; i < keys; i++ {
+		key := workload.FormatKey("sku-", uint64(i))
+		if err := drs.Write(key, []byte("详情:"+key)); err != nil {
+			fmt.Printf("初始化数据失败: %v\n", err)
+			return
+		}
+	}
+
+	// 组装中间件：按IP限流 -> LRU响应缓存 -> KV后端
+	metrics := &apiMetrics{}
+	chaos := &chaosToggles{rng: rand.New(rand.NewSource(7))}
+	backend := &kvBackend{drs: drs, chaos: chaos, metrics: metrics}
+
+	cacheOptions := cache_strategies.DefaultLoadingCacheOptions
+	cacheOptions.TTL = 100 * time.Millisecond
+	cacheOptions.StaleIfError = time.Second
+	cache := newResponseCache(64, cacheOptions, backend)
+	components.RegisterStopper("响应缓存", cache.Close, "容灾存储")
+
+	limiter := practical_applications.NewKeyedRateLimiter(200, 20) // 每个IP每秒200次，突发20次
+	handler := rateLimitMiddleware(limiter, metrics, cacheMiddleware(cache, backend))
+
+	fmt.Printf("数据: %d 个商品，3个数据中心同步复制；缓存: LRU 64 条，TTL %v，兜底 %v；限流: 每个IP %d/s\n",
+		keys, cacheOptions.TTL, cacheOptions.StaleIfError, 200)
+	fmt.Println("流量: 10个正常客户端每10ms一次请求，1个爬虫IP每1ms一次请求，5%为写请求")
+
+	// runPhase 运行一个阶段的流量，返回成功请求的延迟分布
+	runPhase := func(duration time.Duration, seed int64) *practical_applications.TDigest {
+		digest := practical_applications.NewTDigest(practical_applications.DefaultTDigestCompression)
+		var digestMutex sync.Mutex
+		deadline := time.Now().Add(duration)
+
+		client := func(ip string, interval time.Duration, seed int64) {
+			zipf := workload.NewZipf(keys, 1.1, seed)
+			rng := rand.New(rand.NewSource(seed))
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for now := range ticker.C {
+				if now.After(deadline) {
+					return
+				}
+				path := "/kv/" + workload.FormatKey("sku-", zipf.Next())
+				request := httptest.NewRequest(http.MethodGet, path, nil)
+				if rng.Float64() < 0.05 {
+					request = httptest.NewRequest(http.MethodPut, path+"?value=v"+now.Format("150405.000"), nil)
+				}
+				request.RemoteAddr = ip + ":40000"
+
+				start := time.Now()
+				recorder := httptest.NewRecorder()
+				handler.ServeHTTP(recorder, request)
+				latency := time.Since(start)
+
+				atomic.AddInt64(&metrics.requests, 1)
+				switch {
+				case recorder.Code >= 500:
+					atomic.AddInt64(&metrics.serverErrors, 1)
+				case recorder.Code < 400:
+					digestMutex.Lock()
+					digest.Add(float64(latency) / float64(time.Millisecond))
+					digestMutex.Unlock()
+				}
+			}
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				client(fmt.Sprintf("10.0.0.%d", i+1), 10*time.Millisecond, seed+int64(i))
+			}(i)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client("203.0.113.66", time.Millisecond, seed+100)
+		}()
+		wg.Wait()
+		return digest
+	}
+
+	comparison := report.NewComparison("各阶段指标", "每个阶段400ms，指标为阶段内的增量",
+		report.Metric{Name: "请求数", Precision: 0},
+		report.Metric{Name: "限流比例(%)", LowerIsBetter: true, Precision: 1},
+		report.Metric{Name: "缓存命中率(%)", Precision: 1},
+		report.Metric{Name: "后端读", LowerIsBetter: true, Precision: 0},
+		report.Metric{Name: "合并的回源", Precision: 0},
+		report.Metric{Name: "兜底旧值", Precision: 0},
+		report.Metric{Name: "5xx比例(%)", LowerIsBetter: true, Precision: 2},
+		report.Metric{Name: "P99(ms)", LowerIsBetter: true, Precision: 2},
+	)
+
+	phases := []struct {
+		name  string
+		note  string
+		setup func()
+	}{
+		{"正常", "无故障", func() {}},
+		{"后端变慢", "后端每次请求增加20ms", func() { chaos.set(20*time.Millisecond, 0) }},
+		{"主数据中心宕机", "上海故障，切换到备份数据中心", func() {
+			chaos.set(0, 0)
+			drs.UpdateDataCenterStatus("dc-sh", practical_applications.StatusFailed)
+		}},
+		{"后端错误注入", "后端50%的请求失败", func() { chaos.set(0, 0.5) }},
+	}
+
+	statInt := func(stats map[string]interface{}, key string) float64 {
+		value, _ := stats[key].(int64)
+		return float64(value)
+	}
+	for i, phase := range phases {
+		phase.setup()
+		before, cacheBefore, accessBefore := metrics.snapshot(), cache.Stats(), cache.CacheStats()
+		digest := runPhase(400*time.Millisecond, int64(i*1000))
+		after, cacheAfter, access := metrics.snapshot(), cache.Stats(), cache.CacheStats().Sub(accessBefore)
+
+		delta := func(key string) float64 { return statInt(cacheAfter, key) - statInt(cacheBefore, key) }
+		requests := float64(after.requests - before.requests)
+		result := comparison.Add(phase.name, map[string]float64{
+			"请求数":      requests,
+			"限流比例(%)":  float64(after.rateLimited-before.rateLimited) / requests * 100,
+			"缓存命中率(%)": access.HitRate() * 100,
+			"后端读":      float64(after.backendReads - before.backendReads),
+			"合并的回源":    float64(access.Misses) - delta("loads") - delta("loadFailures"),
+			"兜底旧值":     delta("staleServed"),
+			"5xx比例(%)": float64(after.serverErrors-before.serverErrors) / requests * 100,
+			"P99(ms)":  digest.Quantile(0.99),
+		})
+		result.Note = phase.note
+	}
+
+	fmt.Println()
+	if err := comparison.Write(os.Stdout, report.FormatMarkdown); err != nil {
+		fmt.Printf("输出报告失败: %v\n", err)
+	}
+
+	total := metrics.snapshot()
+	stats := cache.Stats()
+	fmt.Printf("\n合计: 请求 %d 次，限流 %d 次（%d 个IP），后端读 %d 次、写 %d 次；缓存回源 %v 次，后台刷新 %v 次\n",
+		total.requests, total.rateLimited, limiter.Len(), total.backendReads, total.backendWrites,
+		stats["loads"], stats["refreshes"])
+}
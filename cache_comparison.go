@@ -0,0 +1,155 @@
+package main
+
+/*
+缓存淘汰策略对比
+
+原理：
+不同的淘汰策略对访问模式的假设不同：LRU 假设"最近访问的将来还会访问"，LFU 假设"访问次数多的将来还会访问"，
+FIFO 只按进入顺序淘汰，LRU-K 则要求数据被访问 K 次后才认为是热点，LIRS 按相邻两次访问之间的重用距离区分冷热。
+Random（随机淘汰）和 MRU（淘汰最近访问的数据）几乎不利用局部性，作为基线衡量其他策略的收益。
+同一种策略在不同访问模式下表现差异很大，因此需要在相同的访问轨迹上横向对比。
+
+关键特点：
+1. 所有策略使用相同的容量，回放完全相同的访问轨迹
+2. 未命中时写入缓存（模拟回源后回填）
+3. 同时对比命中率、总耗时和内存分配
+
+实现方式：
+- 使用 workload 包的 Zipf 分布生成热点集中的访问轨迹
+- 在热点轨迹中周期性插入顺序扫描，模拟批量任务对缓存的污染
+- 使用 report 包统一采集指标并输出对比表
+
+应用场景：
+- 为业务选择合适的缓存淘汰策略
+- 验证新策略相对已有策略的收益
+
+优缺点：
+- 优点：输入一致，结论直观
+- 缺点：合成轨迹与真实流量存在差异，结论需要用真实轨迹复核
+
+以下实现了多种缓存淘汰策略在相同访问轨迹上的对比。
+*/
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/strive/scenario/cache_strategies"
+	"github.com/strive/scenario/cachesim"
+	"github.com/strive/scenario/graph_algorithms"
+	"github.com/strive/scenario/report"
+	"github.com/strive/scenario/search_sort"
+	"github.com/strive/scenario/workload"
+)
+
+// comparableCache 参与对比的缓存需要实现的最小接口
+type comparableCache interface {
+	Get(key string) (interface{}, bool)
+	Put(key string, value interface{})
+}
+
+// 将主包中的LRU/LFU实现注册到缓存模拟器和统一缓存工厂
+func init() {
+	// 衰减周期取容量的10倍，与 W-TinyLFU 频率统计的保鲜周期一致
+	cachesim.RegisterPolicy("lfu-decay", func(capacity int) cachesim.Cache {
+		return NewDecayingLFU[string, interface{}](capacity, capacity*10)
+	})
+	cachesim.RegisterPolicy("tinylfu-lru", func(capacity int) cachesim.Cache { return newTinyLFULRU(capacity) })
+	cache_strategies.RegisterCache("lru", func(capacity int) cache_strategies.AnyCache { return NewLRUCache(capacity) })
+	cache_strategies.RegisterCache("lfu", func(capacity int) cache_strategies.AnyCache { return NewLFUCache(capacity) })
+}
+
+// newTinyLFULRU 在主包的LRU前加上 W-TinyLFU 的窗口和准入过滤
+func newTinyLFULRU(capacity int) *cache_strategies.WTinyLFUCache {
+	window := max(int(float64(capacity)*cache_strategies.DefaultWindowRatio), 1)
+	return cache_strategies.NewWTinyLFUWith[string, interface{}](window, NewLRUCache(capacity-window))
+}
+
+// zipfTrace 生成服从Zipf分布的访问轨迹
+func zipfTrace(seed int64, keys, length int, skew float64) []string {
+	return workload.Strings(workload.NewZipf(uint64(keys), skew, seed), length, "key-")
+}
+
+// scanTrace 在热点轨迹中周期性插入一次性的顺序扫描
+func scanTrace(seed int64, keys, length int, skew float64, scanEvery, scanLength int) []string {
+	hot := zipfTrace(seed, keys, length, skew)
+	scan := workload.NewSequential(uint64(length/scanEvery*scanLength+1), 0)
+	trace := make([]string, 0, length+length/scanEvery*scanLength)
+	for i, key := range hot {
+		trace = append(trace, key)
+		if (i+1)%scanEvery == 0 {
+			for j := 0; j < scanLength; j++ {
+				trace = append(trace, workload.FormatKey("scan-", scan.Next()))
+			}
+		}
+	}
+	return trace
+}
+
+// CompareCachePolicies 在同一条访问轨迹上对比各缓存淘汰策略
+func CompareCachePolicies(title, input string, trace []string, capacity int) *report.Comparison {
+	comparison := report.NewComparison(title, input,
+		report.Metric{Name: "命中率(%)", Precision: 2},
+	)
+
+	policies := []struct {
+		name  string
+		cache func() comparableCache
+	}{
+		{"LRU", func() comparableCache { return NewLRUCache(capacity) }},
+		{"LRU(自定义链表)", func() comparableCache { return NewCustomLRUCache(capacity) }},
+		{"LFU", func() comparableCache { return NewLFUCache(capacity) }},
+		{"LFU(自定义链表)", func() comparableCache { return NewCustomLFUCache(capacity) }},
+		{"FIFO", func() comparableCache { return cache_strategies.NewFIFOCache(capacity) }},
+		{"LRU-2", func() comparableCache { return cache_strategies.NewLRUKCache(capacity, 2) }},
+		{"SLRU", func() comparableCache {
+			return cache_strategies.NewSLRUCache(capacity, cache_strategies.DefaultProtectedRatio)
+		}},
+		{"TinyLFU+LRU", func() comparableCache { return newTinyLFULRU(capacity) }},
+		{"W-TinyLFU", func() comparableCache { return cache_strategies.NewWTinyLFUCache(capacity) }},
+		{"LIRS", func() comparableCache {
+			return cache_strategies.NewLIRSCache(capacity, cache_strategies.DefaultHIRRatio)
+		}},
+		{"Random(基线)", func() comparableCache { return cache_strategies.NewRandomCache(capacity, 1) }},
+		{"MRU(基线)", func() comparableCache { return cache_strategies.NewMRUCache(capacity) }},
+	}
+
+	for _, policy := range policies {
+		comparison.Measure(policy.name, func() (map[string]float64, error) {
+			cache := policy.cache()
+			hits := 0
+			for _, key := range trace {
+				if _, ok := cache.Get(key); ok {
+					hits++
+				} else {
+					cache.Put(key, key)
+				}
+			}
+			return map[string]float64{"命中率(%)": float64(hits) / float64(len(trace)) * 100}, nil
+		})
+	}
+
+	return comparison
+}
+
+// 场景示例：缓存策略、路径算法、TopK算法的对比报告
+func ComparisonReportDemo() {
+	fmt.Println("算法对比报告:")
+
+	comparisons := []*report.Comparison{
+		CompareCachePolicies("缓存淘汰策略对比（热点访问）",
+			"10000 个键、Zipf(1.1) 分布的 200000 次访问，容量 500",
+			zipfTrace(2024, 10000, 200000, 1.1), 500),
+		CompareCachePolicies("缓存淘汰策略对比（热点访问 + 批量扫描）",
+			"同上，每 1000 次访问插入 400 个只访问一次的扫描键，容量 500",
+			scanTrace(2025, 10000, 200000, 1.1, 1000, 400), 500),
+		graph_algorithms.CompareRoutingAlgorithms(30, 30, 200, 7),
+		search_sort.CompareTopK(1_000_000, 100, 100_000, 42),
+	}
+
+	report.WriteAll(os.Stdout, report.FormatMarkdown, comparisons...)
+
+	// JSON格式便于保存后做趋势对比
+	fmt.Println("JSON格式（第一份报告）:")
+	comparisons[0].Write(os.Stdout, report.FormatJSON)
+}
@@ -0,0 +1,385 @@
+package cache_strategies
+
+/*
+基于内存压力的缓存容量自适应调整
+
+原理：
+缓存容量通常在启动时按经验写死：写小了命中率低，写大了在流量高峰或其他模块占用内存时可能触发 OOM。
+自适应调整让缓存容量跟随进程的实际内存状况变化：
+- 内存用量超过预算的高水位时，按比例缩小各缓存的容量，被淘汰的数据交给 GC 回收
+- 内存用量低于低水位时，逐步把容量恢复到配置的上限
+- 高低水位之间不做调整，并且两次调整之间至少间隔一个冷却时间，避免在阈值附近反复收缩、扩张（迟滞）
+
+关键特点：
+1. 内存预算可以直接配置，也可以读取 debug.SetMemoryLimit 设置的软限制
+2. 每个缓存有权重：收缩时权重高的缓存缩得少，扩张时权重高的缓存涨得多
+3. 每个缓存有容量上下限，收缩不会把缓存缩到失去作用，扩张不会超过配置的上限
+4. 只扩张已经装满的缓存，没有用满容量的缓存扩张没有意义
+5. 可以手动调用 Check，也可以 Start 后台定时检查
+
+实现方式：
+- 缓存实现 Resizable 接口（Capacity、Resize、Size），FIFO、LRU-K、SLRU 以及主包中的 LRU、LFU 都已实现
+- 默认用 runtime.ReadMemStats 的 HeapAlloc 作为内存用量，也可以替换成其他指标
+- 缓存本身不是并发安全的，注册时可以传入缓存的锁，调整容量时持有
+
+应用场景：
+- 同一进程中有多个缓存争用内存
+- 容器内存限制固定、流量波动大的服务
+- 不希望缓存成为 OOM 原因的场景
+
+优缺点：
+- 优点：高峰期主动让出内存，空闲时自动恢复命中率，无需人工调参
+- 缺点：按条目数而非字节数调整，条目大小差异大时不够精确；HeapAlloc 包含尚未回收的垃圾，
+  收缩后需要等 GC 才能看到效果，因此需要冷却时间
+
+以下实现了按内存预算调整缓存容量的控制器。
+*/
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Resizable 可以在运行时调整容量的缓存
+type Resizable interface {
+	Capacity() int           // 当前最大容量
+	Resize(capacity int) int // 调整最大容量，返回因此淘汰的元素数量
+	Size() int               // 当前元素数量
+}
+
+// ManagedCache 注册到控制器的缓存
+type ManagedCache struct {
+	Name        string      // 名称，用于统计输出
+	Cache       Resizable   // 被管理的缓存
+	Lock        sync.Locker // 缓存的锁，调整容量时持有；只在一个协程中使用时可以为 nil
+	Weight      float64     // 权重，默认1
+	MinCapacity int         // 容量下限，默认1
+	MaxCapacity int         // 容量上限，默认为注册时的容量
+}
+
+// MemoryControllerOptions 控制器选项
+type MemoryControllerOptions struct {
+	Budget     uint64        // 内存预算（字节），为0时使用 debug.SetMemoryLimit 设置的软限制
+	HighWater  float64       // 用量超过 Budget*HighWater 时收缩
+	LowWater   float64       // 用量低于 Budget*LowWater 时扩张
+	ShrinkStep float64       // 权重为平均值的缓存每次收缩的比例
+	GrowStep   float64       // 权重为平均值的缓存每次扩张的比例
+	Cooldown   time.Duration // 两次调整之间的最短间隔
+	Interval   time.Duration // Start 后台检查的间隔
+	ReadMemory func() uint64 // 读取当前内存用量，默认为 runtime.MemStats.HeapAlloc
+}
+
+// DefaultMemoryControllerOptions 默认的控制器选项
+var DefaultMemoryControllerOptions = MemoryControllerOptions{
+	HighWater:  0.9,
+	LowWater:   0.7,
+	ShrinkStep: 0.2,
+	GrowStep:   0.1,
+	Cooldown:   2 * time.Second,
+	Interval:   time.Second,
+}
+
+// 控制器的调整动作
+const (
+	ActionNone   = "none"
+	ActionShrink = "shrink"
+	ActionGrow   = "grow"
+)
+
+// ErrNoMemoryBudget 没有配置内存预算，进程也没有设置内存软限制
+var ErrNoMemoryBudget = errors.New("未配置内存预算，且未通过 debug.SetMemoryLimit 设置内存限制")
+
+// MemoryController 根据内存压力调整已注册缓存的容量
+type MemoryController struct {
+	mu       sync.Mutex
+	options  MemoryControllerOptions
+	caches   []*ManagedCache
+	last     time.Time // 上次调整的时间
+	usage    uint64    // 上次检查时的内存用量
+	shrinks  int       // 收缩次数
+	grows    int       // 扩张次数
+	evicted  int       // 因收缩淘汰的元素总数
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewMemoryController 创建控制器，未设置的选项使用默认值
+func NewMemoryController(options MemoryControllerOptions) (*MemoryController, error) {
+	if options.Budget == 0 {
+		limit := debug.SetMemoryLimit(-1) // 负数表示只读取当前值
+		if limit == math.MaxInt64 {
+			return nil, ErrNoMemoryBudget
+		}
+		options.Budget = uint64(limit)
+	}
+	if options.HighWater <= 0 || options.HighWater > 1 {
+		options.HighWater = DefaultMemoryControllerOptions.HighWater
+	}
+	if options.LowWater <= 0 || options.LowWater >= options.HighWater {
+		options.LowWater = options.HighWater * DefaultMemoryControllerOptions.LowWater / DefaultMemoryControllerOptions.HighWater
+	}
+	if options.ShrinkStep <= 0 || options.ShrinkStep >= 1 {
+		options.ShrinkStep = DefaultMemoryControllerOptions.ShrinkStep
+	}
+	if options.GrowStep <= 0 {
+		options.GrowStep = DefaultMemoryControllerOptions.GrowStep
+	}
+	if options.Cooldown < 0 {
+		options.Cooldown = DefaultMemoryControllerOptions.Cooldown
+	}
+	if options.Interval <= 0 {
+		options.Interval = DefaultMemoryControllerOptions.Interval
+	}
+	if options.ReadMemory == nil {
+		options.ReadMemory = heapAlloc
+	}
+	return &MemoryController{options: options, stop: make(chan struct{})}, nil
+}
+
+// heapAlloc 读取堆上已分配的字节数
+func heapAlloc() uint64 {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
+
+// Register 注册缓存，同名缓存会被替换
+func (mc *MemoryController) Register(cache ManagedCache) error {
+	if cache.Cache == nil {
+		return fmt.Errorf("缓存 %q 为空", cache.Name)
+	}
+	if cache.Weight <= 0 {
+		cache.Weight = 1
+	}
+	if cache.MinCapacity < 1 {
+		cache.MinCapacity = 1
+	}
+	if cache.MaxCapacity <= 0 {
+		cache.MaxCapacity = cache.capacity()
+	}
+	if cache.MaxCapacity < cache.MinCapacity {
+		return fmt.Errorf("缓存 %q 的容量上限 %d 小于下限 %d", cache.Name, cache.MaxCapacity, cache.MinCapacity)
+	}
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	for i, existing := range mc.caches {
+		if existing.Name == cache.Name {
+			mc.caches[i] = &cache
+			return nil
+		}
+	}
+	mc.caches = append(mc.caches, &cache)
+	return nil
+}
+
+// Unregister 取消注册，缓存保持当前容量
+func (mc *MemoryController) Unregister(name string) bool {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	for i, cache := range mc.caches {
+		if cache.Name == name {
+			mc.caches = append(mc.caches[:i], mc.caches[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Check 读取一次内存用量并在需要时调整容量，返回执行的动作
+func (mc *MemoryController) Check() string {
+	usage := mc.options.ReadMemory()
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.usage = usage
+
+	if len(mc.caches) == 0 || (!mc.last.IsZero() && time.Since(mc.last) < mc.options.Cooldown) {
+		return ActionNone
+	}
+
+	budget := float64(mc.options.Budget)
+	switch {
+	case float64(usage) > budget*mc.options.HighWater:
+		if mc.resize(mc.options.ShrinkStep, true) {
+			mc.shrinks++
+			mc.last = time.Now()
+			return ActionShrink
+		}
+	case float64(usage) < budget*mc.options.LowWater:
+		if mc.resize(mc.options.GrowStep, false) {
+			mc.grows++
+			mc.last = time.Now()
+			return ActionGrow
+		}
+	}
+	return ActionNone
+}
+
+// resize 按权重收缩或扩张所有缓存，返回是否有缓存的容量发生变化，调用方需持有 mc.mu
+func (mc *MemoryController) resize(step float64, shrink bool) bool {
+	totalWeight := 0.0
+	for _, cache := range mc.caches {
+		totalWeight += cache.Weight
+	}
+	meanWeight := totalWeight / float64(len(mc.caches))
+
+	changed := false
+	for _, cache := range mc.caches {
+		if shrink {
+			// 权重越高缩得越少，单次最多缩掉90%
+			evicted, ok := cache.shrink(math.Min(step*meanWeight/cache.Weight, 0.9))
+			mc.evicted += evicted
+			changed = changed || ok
+		} else {
+			// 权重越高涨得越多
+			changed = cache.grow(step*cache.Weight/meanWeight) || changed
+		}
+	}
+	return changed
+}
+
+// shrink 把容量缩小 ratio 比例（不低于下限），返回淘汰的元素数量以及容量是否变化
+func (c *ManagedCache) shrink(ratio float64) (int, bool) {
+	if c.Lock != nil {
+		c.Lock.Lock()
+		defer c.Lock.Unlock()
+	}
+	current := c.Cache.Capacity()
+	target := max(int(float64(current)*(1-ratio)), c.MinCapacity)
+	if target >= current {
+		return 0, false
+	}
+	return c.Cache.Resize(target), true
+}
+
+// grow 把已经装满的缓存扩大 ratio 比例（至少加1，不超过上限），返回容量是否变化
+func (c *ManagedCache) grow(ratio float64) bool {
+	if c.Lock != nil {
+		c.Lock.Lock()
+		defer c.Lock.Unlock()
+	}
+	current := c.Cache.Capacity()
+	if current >= c.MaxCapacity || c.Cache.Size() < current {
+		return false
+	}
+	c.Cache.Resize(min(current+max(int(float64(current)*ratio), 1), c.MaxCapacity))
+	return true
+}
+
+// capacity 在持有锁的情况下读取当前容量
+func (c *ManagedCache) capacity() int {
+	if c.Lock != nil {
+		c.Lock.Lock()
+		defer c.Lock.Unlock()
+	}
+	return c.Cache.Capacity()
+}
+
+// Start 启动后台定时检查
+func (mc *MemoryController) Start() {
+	go func() {
+		ticker := time.NewTicker(mc.options.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				mc.Check()
+			case <-mc.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止后台检查，可重复调用
+func (mc *MemoryController) Stop() {
+	mc.stopOnce.Do(func() { close(mc.stop) })
+}
+
+// Capacities 返回各缓存的当前容量
+func (mc *MemoryController) Capacities() map[string]int {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	capacities := make(map[string]int, len(mc.caches))
+	for _, cache := range mc.caches {
+		capacities[cache.Name] = cache.capacity()
+	}
+	return capacities
+}
+
+// Stats 返回控制器统计信息
+func (mc *MemoryController) Stats() map[string]interface{} {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	return map[string]interface{}{
+		"budget":  mc.options.Budget,
+		"usage":   mc.usage,
+		"caches":  len(mc.caches),
+		"shrinks": mc.shrinks,
+		"grows":   mc.grows,
+		"evicted": mc.evicted,
+	}
+}
+
+// 场景示例：批处理任务临时占用大量内存时，会话缓存和商品缓存按权重让出内存，任务结束后恢复
+func AdaptiveSizingDemo() {
+	fmt.Println("缓存容量自适应调整示例:")
+
+	const entryBytes = 1 << 10 // 假设每个缓存项约 1KB
+	sessions := NewFIFO[int, string](4000)
+	products := NewLRUK[int, string](4000, DefaultK)
+
+	// 用"其他模块占用 + 缓存项数量 × 条目大小"模拟进程内存，便于观察控制器的行为
+	var otherUsage uint64 = 2 << 20
+	readMemory := func() uint64 {
+		return otherUsage + uint64(sessions.Size()+products.Size())*entryBytes
+	}
+
+	controller, err := NewMemoryController(MemoryControllerOptions{
+		Budget:     12 << 20,
+		Cooldown:   0, // 演示中每一步都允许调整
+		ReadMemory: readMemory,
+	})
+	if err != nil {
+		fmt.Printf("创建控制器失败: %v\n", err)
+		return
+	}
+	controller.Register(ManagedCache{Name: "会话", Cache: sessions, Weight: 1, MinCapacity: 500})
+	controller.Register(ManagedCache{Name: "商品", Cache: products, Weight: 3, MinCapacity: 500})
+
+	fill := func(step int) {
+		for i := 0; i < 3000; i++ {
+			sessions.Put(step*3000+i, "session")
+			key := (step*997 + i) % 6000
+			if _, ok := products.Get(key); !ok {
+				products.Put(key, "product")
+			}
+		}
+	}
+
+	fmt.Printf("预算 12MB，高水位 %.0f%%，低水位 %.0f%%\n\n",
+		DefaultMemoryControllerOptions.HighWater*100, DefaultMemoryControllerOptions.LowWater*100)
+	fmt.Println("步骤  其他占用  内存用量  动作    会话容量  商品容量")
+	for step := 0; step < 16; step++ {
+		switch step {
+		case 4:
+			otherUsage = 8 << 20 // 批处理任务开始
+		case 10:
+			otherUsage = 2 << 20 // 批处理任务结束
+		}
+		fill(step)
+		action := controller.Check()
+		capacities := controller.Capacities()
+		fmt.Printf("%4d  %6dMB  %7.1fMB  %-6s  %8d  %8d\n", step, otherUsage>>20,
+			float64(readMemory())/(1<<20), action, capacities["会话"], capacities["商品"])
+	}
+
+	stats := controller.Stats()
+	fmt.Printf("\n共收缩 %d 次、扩张 %d 次，收缩时淘汰 %d 个缓存项\n", stats["shrinks"], stats["grows"], stats["evicted"])
+}
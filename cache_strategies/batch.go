@@ -0,0 +1,155 @@
+package cache_strategies
+
+/*
+缓存的批量读写
+
+原理：
+一次请求常常需要读取一组键（渲染商品列表页要读20个商品详情、合并多个下游响应），
+逐个调用 Get 时每个键都要加锁、解锁一次，多个协程并发访问时锁的获取和释放本身成为主要开销，
+而且每次解锁后其他协程都可能插进来，锁在协程之间频繁转手。
+批量接口在一次加锁内完成一组键的读写，把 N 次加锁合并为1次。
+
+关键特点：
+1. BatchCache 是可选接口：Cache 接口保持不变，实现了 GetMulti、PutMulti 的缓存才提供批量能力
+2. 包级函数 GetMulti、PutMulti 对任意 Cache 可用，缓存不支持批量时退化为逐个调用
+3. GetMulti 只返回命中的键值，未命中的键不出现在结果中，调用方据此确定需要回源的键
+4. 命中统计、过期删除、淘汰顺序与逐个调用完全一致，回调仍在解锁后执行
+5. 同一批次持有锁的时间更长，批次过大时会增加其他协程的等待时间，批次大小应与一次请求需要的键数相当
+
+实现方式：
+- TTL 缓存：一次读锁内查找所有键，发现已过期的条目后再用一次写锁统一删除；写入时权重在锁外计算
+- TTL+LRU 缓存：一次互斥锁内完成查找、移动到链表头部和写入，被淘汰、过期的条目收集起来在解锁后通知
+- ObservableCache：一次加锁内完成批量操作并发布事件
+- 主包中的 SyncCache 一次加锁完成整个批次，ShardedCache 先按分片分组，每个分片加一次锁
+
+应用场景：
+- 列表页、推荐位等一次需要多个键的读取
+- 回源后把一批结果一起写回缓存
+- 流水线中按批处理数据的阶段
+
+优缺点：
+- 优点：加锁次数从每个键一次降为每批一次，多核下锁竞争激烈时吞吐量提升明显；调用代码也更简洁
+- 缺点：每批要分配结果 map，单核或锁竞争不激烈时这部分开销可能抵消收益；批次越大持锁越久，尾延迟可能变差；
+  不支持批量的缓存通过包级函数调用时没有收益
+
+以下实现了批量读写接口和按需选择批量或逐个调用的辅助函数，以及商品列表页批量读取的示例。
+*/
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// BatchCache 支持批量读写的缓存，一个批次只加一次锁
+type BatchCache[K comparable, V any] interface {
+	Cache[K, V]
+	GetMulti(keys []K) map[K]V // 批量读取，返回命中的键值，未命中的键不出现在结果中
+	PutMulti(items map[K]V)    // 批量写入或更新
+}
+
+// GetMulti 批量读取，缓存实现了 BatchCache 时一次完成，否则逐个调用 Get
+func GetMulti[K comparable, V any](cache Cache[K, V], keys []K) map[K]V {
+	if batch, ok := cache.(BatchCache[K, V]); ok {
+		return batch.GetMulti(keys)
+	}
+	result := make(map[K]V, len(keys))
+	for _, key := range keys {
+		if value, ok := cache.Get(key); ok {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// PutMulti 批量写入，缓存实现了 BatchCache 时一次完成，否则逐个调用 Put
+func PutMulti[K comparable, V any](cache Cache[K, V], items map[K]V) {
+	if batch, ok := cache.(BatchCache[K, V]); ok {
+		batch.PutMulti(items)
+		return
+	}
+	for key, value := range items {
+		cache.Put(key, value)
+	}
+}
+
+// 场景示例：商品列表页每次请求读取一页商品详情，未命中的商品回源后一起写回
+func BatchCacheDemo() {
+	const (
+		workers  = 8
+		requests = 20000
+		pageSize = 20
+		capacity = 2000
+	)
+	fmt.Printf("批量读写示例 (%d个协程，每个协程%d次请求，每页%d个商品，容量=%d):\n", workers, requests/workers, pageSize, capacity)
+
+	// 每个协程有自己的访问轨迹，每连续 pageSize 个键组成一页
+	traces := make([][]string, workers)
+	for i := range traces {
+		zipf := rand.NewZipf(rand.New(rand.NewSource(int64(i))), 1.1, 1, 9999)
+		traces[i] = make([]string, requests/workers*pageSize)
+		for j := range traces[i] {
+			traces[i][j] = fmt.Sprintf("sku-%d", zipf.Uint64())
+		}
+	}
+
+	run := func(name string, cache *TTLLRU[string, string], page func(keys []string) int) {
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		locks := 0
+		start := time.Now()
+		for _, trace := range traces {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				localLocks := 0
+				for i := 0; i+pageSize <= len(trace); i += pageSize {
+					localLocks += page(trace[i : i+pageSize])
+				}
+				mu.Lock()
+				locks += localLocks
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+		elapsed := time.Since(start)
+		fmt.Printf("%s: 耗时 %v，加锁 %d 次，%s\n", name, elapsed.Round(time.Millisecond), locks, cache.CacheStats())
+	}
+
+	// 逐个读取：每个键加一次锁，未命中的键各自回填
+	single := NewTTLLRU[string, string](capacity, time.Minute)
+	run("逐个 Get/Put", single, func(keys []string) int {
+		locks := 0
+		for _, key := range keys {
+			locks++
+			if _, ok := single.Get(key); !ok {
+				single.Put(key, "详情:"+key)
+				locks++
+			}
+		}
+		return locks
+	})
+
+	// 批量读取：一页只加一次锁，未命中的键回源后一次写回
+	batch := NewTTLLRU[string, string](capacity, time.Minute)
+	run("GetMulti/PutMulti", batch, func(keys []string) int {
+		found := GetMulti[string, string](batch, keys)
+		if len(found) == len(keys) {
+			return 1
+		}
+		missing := make(map[string]string, len(keys)-len(found))
+		for _, key := range keys {
+			if _, ok := found[key]; !ok {
+				missing[key] = "详情:" + key
+			}
+		}
+		PutMulti[string, string](batch, missing)
+		return 2
+	})
+
+	// 不支持批量的缓存通过包级函数调用时退化为逐个操作，结果相同
+	fifo := NewFIFO[string, string](3)
+	PutMulti[string, string](fifo, map[string]string{"sku-1": "手机", "sku-2": "耳机"})
+	fmt.Printf("FIFO 缓存通过 GetMulti 读取: %v\n", GetMulti[string, string](fifo, []string{"sku-1", "sku-2", "sku-3"}))
+}
@@ -0,0 +1,156 @@
+package cache_strategies
+
+/*
+统一的缓存接口与按名称创建缓存的工厂
+
+原理：
+各种淘汰策略（FIFO、LRU、LFU、LRU-K、SLRU、W-TinyLFU、LIRS、TTL、TTL+LRU、Random、MRU）对外的操作其实是一样的：读、写、删除、统计大小、列出键、清空。
+把这些操作抽象成一个 Cache 接口后，业务代码只依赖接口，淘汰策略可以通过配置切换，
+不需要改动任何调用代码，也方便在同一条访问轨迹上横向对比不同策略。
+
+关键特点：
+1. Cache[K, V] 是泛型接口，只包含所有策略都能实现的六个操作，读取时无需类型断言
+2. NewCache 按策略名创建缓存，策略名可以来自配置文件或命令行参数
+3. 通过 RegisterCache 注册新的策略，主包中的 LRU/LFU 实现也通过这种方式接入
+4. 未知策略名返回 ErrUnknownPolicy，并在错误信息中列出可用的策略
+5. 批量读写由可选的 BatchCache 接口提供，GetMulti、PutMulti 函数对任意缓存可用（见 batch.go）
+
+实现方式：
+- 全局注册表保存策略名到工厂函数的映射，读写加锁
+- 包内的 FIFO、LRU-K、SLRU、W-TinyLFU、LIRS、TTL、TTL+LRU、Random、MRU 缓存在 init 中自动注册
+
+应用场景：
+- 通过配置选择缓存策略
+- 对比不同淘汰策略在同一业务上的效果
+- 在测试中用简单策略替换复杂策略
+
+优缺点：
+- 优点：调用方与具体实现解耦，新增策略无需修改调用方
+- 缺点：接口只能覆盖公共操作，策略特有的功能（如 TTL 的 SetWithTTL）仍需类型断言
+
+以下实现了统一的缓存接口、策略注册表和按名称创建缓存的工厂。
+*/
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Cache 所有缓存策略共同实现的泛型接口
+type Cache[K comparable, V any] interface {
+	Get(key K) (V, bool) // 读取，不存在时返回 false
+	Put(key K, value V)  // 写入或更新
+	Remove(key K) bool   // 删除，不存在时返回 false
+	Size() int           // 当前元素数量
+	Keys() []K           // 所有键
+	Clear()              // 清空
+}
+
+// AnyCache 字符串键、任意值的缓存接口（兼容旧接口），注册表中的策略都返回这种缓存
+type AnyCache = Cache[string, interface{}]
+
+// Factory 按容量创建缓存的工厂函数
+type Factory func(capacity int) AnyCache
+
+// ErrUnknownPolicy 未注册的缓存策略
+var ErrUnknownPolicy = errors.New("未知的缓存策略")
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = make(map[string]Factory)
+)
+
+// 注册包内实现的缓存策略
+func init() {
+	RegisterCache("fifo", func(capacity int) AnyCache { return NewFIFOCache(capacity) })
+	RegisterCache("lru-k", func(capacity int) AnyCache { return NewLRUKCache(capacity, DefaultK) })
+	RegisterCache("slru", func(capacity int) AnyCache { return NewSLRUCache(capacity, DefaultProtectedRatio) })
+	RegisterCache("w-tinylfu", func(capacity int) AnyCache { return NewWTinyLFUCache(capacity) })
+	RegisterCache("lirs", func(capacity int) AnyCache { return NewLIRSCache(capacity, DefaultHIRRatio) })
+	RegisterCache("random", func(capacity int) AnyCache { return NewRandomCache(capacity, 1) })
+	RegisterCache("mru", func(capacity int) AnyCache { return NewMRUCache(capacity) })
+	RegisterCache("ttl-lru", func(capacity int) AnyCache {
+		return NewTTLLRUCache(capacity, DefaultTTLCacheOptions.DefaultTTL)
+	})
+	// TTL缓存没有容量限制，capacity 被忽略；不启动后台清理，依靠访问时的懒惰过期
+	RegisterCache("ttl", func(capacity int) AnyCache {
+		return NewTTLCache(TTLCacheOptions{DefaultTTL: DefaultTTLCacheOptions.DefaultTTL})
+	})
+}
+
+// RegisterCache 注册缓存策略，同名策略会被覆盖
+func RegisterCache(policy string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	factories[strings.ToLower(policy)] = factory
+}
+
+// Policies 返回已注册的策略名（按字母排序）
+func Policies() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewCache 按策略名创建指定容量的缓存，策略名不区分大小写
+func NewCache(policy string, capacity int) (AnyCache, error) {
+	factoriesMu.RLock()
+	factory, ok := factories[strings.ToLower(policy)]
+	factoriesMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %s（可用: %s）", ErrUnknownPolicy, policy, strings.Join(Policies(), ", "))
+	}
+	if capacity <= 0 {
+		return nil, fmt.Errorf("缓存容量必须大于0: %d", capacity)
+	}
+	return factory(capacity), nil
+}
+
+// 场景示例：根据配置选择缓存策略，调用代码保持不变
+func CacheInterfaceDemo() {
+	fmt.Println("统一缓存接口示例:")
+	fmt.Printf("已注册的策略: %v\n", Policies())
+
+	// 同一段业务代码：缓存最近查询的商品，容量为3
+	run := func(cache AnyCache) {
+		for _, key := range []string{"商品A", "商品B", "商品A", "商品C", "商品A", "商品D"} {
+			if _, ok := cache.Get(key); !ok {
+				cache.Put(key, "详情:"+key)
+			}
+		}
+	}
+
+	for _, policy := range Policies() {
+		cache, err := NewCache(policy, 3)
+		if err != nil {
+			fmt.Printf("创建 %s 失败: %v\n", policy, err)
+			continue
+		}
+		run(cache)
+		keys := cache.Keys()
+		sort.Strings(keys)
+		_, hot := cache.Get("商品A")
+		fmt.Printf("  %-6s 大小=%d 键=%v 热点商品A仍在缓存=%v\n", policy, cache.Size(), keys, hot)
+	}
+
+	if _, err := NewCache("arc", 3); err != nil {
+		fmt.Printf("\n配置错误时: %v\n", err)
+	}
+
+	// 泛型缓存：键和值都有具体类型，读取时不需要类型断言
+	var prices Cache[int, float64] = NewLRUK[int, float64](2, DefaultK)
+	prices.Put(1001, 59.9)
+	if price, ok := prices.Get(1001); ok {
+		fmt.Printf("\n泛型缓存 Cache[int, float64]: 商品1001 八折后 %.2f 元\n", price*0.8)
+	}
+}
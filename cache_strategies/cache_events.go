@@ -0,0 +1,320 @@
+package cache_strategies
+
+/*
+缓存事件的发布与订阅
+
+原理：
+OnEvict、OnExpire 回调在缓存内部同步执行，适合释放资源这类简单、快速的操作；
+指标采集、把变更同步给副本缓存这类工作耗时不确定，放在回调里会拖慢缓存本身。
+ObservableCache 包装任意缓存，把写入、删除、淘汰、过期转换成事件，通过带缓冲的通道发给订阅者，
+订阅者在自己的协程里按自己的节奏处理。
+
+关键特点：
+1. 事件类型沿用 keyspace.EventType：set、del、expired、evicted；与键空间事件不同，事件带有原始类型的键和值
+2. 被包装的缓存实现了 OnEvict、OnExpire 时自动注册回调，淘汰原因为 EvictionExpired 的条目按过期发布
+3. 订阅者的缓冲区满时按订阅时选择的慢消费者策略处理（见 concurrency.Hub）：默认丢弃最旧的事件并计数，慢消费者不会拖慢缓存；
+   需要完整重放的副本可以选择阻塞策略，代价是副本处理过慢时缓存写入跟着变慢
+4. 包装后的缓存是并发安全的，同一个锁内完成缓存操作和发布，订阅者看到的事件顺序与操作顺序一致
+   （TTL 缓存后台清理发布的过期事件在锁外产生，与同一个键的并发写入之间不保证顺序）
+5. Clear 为每个键发布一个 del 事件，副本可以据此同步清空
+
+实现方式：
+- CacheEvents 基于广播中心（concurrency.Hub）把事件扇出给每个订阅者，零值可直接使用
+- ObservableCache 用互斥锁保护被包装的缓存，Put、PutMulti、Remove、Clear 之后发布对应事件
+- Close 关闭所有订阅通道，消费者的 range 循环自然结束
+
+应用场景：
+- 指标采集：按事件类型统计写入、淘汰、过期次数
+- 副本缓存：另一个进程或分片订阅事件并重放，保持数据近似一致
+- 调试：观察淘汰策略在真实流量下淘汰了哪些键
+
+优缺点：
+- 优点：生产者与消费者解耦，订阅者拿到完整的值，可以直接重放
+- 缺点：丢弃策略下缓冲区满时丢事件（至多一次语义），副本只能做到最终近似一致；阻塞策略不丢事件但会拖慢写入；
+  每次写入多一次加锁和发送的开销
+
+以下实现了缓存事件的订阅接口，以及指标采集器和副本缓存订阅同一个缓存的示例。
+*/
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/strive/scenario/concurrency"
+	"github.com/strive/scenario/keyspace"
+)
+
+// CacheEvent 缓存事件
+type CacheEvent[K comparable, V any] struct {
+	Type  keyspace.EventType // 事件类型
+	Key   K                  // 发生变化的键
+	Value V                  // 写入的值，或被淘汰、过期的值；del 事件中为零值
+	Time  time.Time          // 事件发生时间
+}
+
+// CacheEvents 缓存事件的订阅列表，零值可直接使用，并发安全
+type CacheEvents[K comparable, V any] struct {
+	hub   concurrency.Hub[CacheEvent[K, V]]
+	mutex sync.Mutex
+	subs  map[<-chan CacheEvent[K, V]]*concurrency.Subscription[CacheEvent[K, V]]
+}
+
+// Subscribe 订阅所有事件，buffer 为通道的缓冲大小，小于等于0时使用 keyspace.DefaultBufferSize；
+// 缓冲区满时丢弃最旧的事件，关闭后返回已关闭的通道
+func (e *CacheEvents[K, V]) Subscribe(buffer int) <-chan CacheEvent[K, V] {
+	return e.SubscribeWithOptions(concurrency.SubscribeOptions{Buffer: buffer, Policy: concurrency.DropOldest})
+}
+
+// SubscribeWithOptions 按选项订阅所有事件，缓冲区满时按 options.Policy 处理；Buffer 小于等于0时使用 keyspace.DefaultBufferSize
+func (e *CacheEvents[K, V]) SubscribeWithOptions(options concurrency.SubscribeOptions) <-chan CacheEvent[K, V] {
+	if options.Buffer <= 0 {
+		options.Buffer = keyspace.DefaultBufferSize
+	}
+	sub := e.hub.Subscribe("cache-events", options)
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.subs == nil {
+		e.subs = make(map[<-chan CacheEvent[K, V]]*concurrency.Subscription[CacheEvent[K, V]])
+	}
+	// Disconnect 策略下广播中心会自行关闭过慢的订阅，顺便清理掉这些记录，避免订阅频繁变化时越积越多
+	for ch, old := range e.subs {
+		if old.Closed() {
+			delete(e.subs, ch)
+		}
+	}
+	e.subs[sub.C()] = sub
+	return sub.C()
+}
+
+// Unsubscribe 取消订阅并关闭通道，重复取消时返回 false
+func (e *CacheEvents[K, V]) Unsubscribe(ch <-chan CacheEvent[K, V]) bool {
+	e.mutex.Lock()
+	sub, ok := e.subs[ch]
+	delete(e.subs, ch)
+	e.mutex.Unlock()
+	if !ok {
+		return false
+	}
+	sub.Close()
+	return true
+}
+
+// Publish 发布事件；只有 Block 策略的订阅者缓冲区满时才会阻塞
+func (e *CacheEvents[K, V]) Publish(eventType keyspace.EventType, key K, value V) {
+	if e.hub.Subscribers() == 0 {
+		return
+	}
+	e.hub.Publish(CacheEvent[K, V]{Type: eventType, Key: key, Value: value, Time: time.Now()})
+}
+
+// Dropped 返回因订阅者缓冲区满而丢弃的事件数
+func (e *CacheEvents[K, V]) Dropped() uint64 {
+	return uint64(e.hub.Dropped())
+}
+
+// Subscribers 返回当前订阅数
+func (e *CacheEvents[K, V]) Subscribers() int {
+	return e.hub.Subscribers()
+}
+
+// Close 关闭所有订阅通道，之后的订阅立即得到已关闭的通道，可重复调用
+func (e *CacheEvents[K, V]) Close() {
+	e.hub.Close()
+	e.mutex.Lock()
+	e.subs = nil
+	e.mutex.Unlock()
+}
+
+// ObservableCache 发布缓存事件的并发安全缓存包装
+type ObservableCache[K comparable, V any] struct {
+	mutex  sync.Mutex
+	cache  Cache[K, V]
+	buffer int
+	events CacheEvents[K, V]
+}
+
+// NewObservableCache 包装缓存，buffer 为每个订阅通道的缓冲大小；包装后不应再直接访问原缓存
+func NewObservableCache[K comparable, V any](cache Cache[K, V], buffer int) *ObservableCache[K, V] {
+	c := &ObservableCache[K, V]{cache: cache, buffer: buffer}
+	notify := func(key K, value V, reason EvictionReason) {
+		if reason == EvictionExpired {
+			c.events.Publish(keyspace.EventExpired, key, value)
+		} else {
+			c.events.Publish(keyspace.EventEvicted, key, value)
+		}
+	}
+	if evictable, ok := cache.(interface{ OnEvict(EvictionCallback[K, V]) }); ok {
+		evictable.OnEvict(notify)
+	}
+	if expirable, ok := cache.(interface{ OnExpire(EvictionCallback[K, V]) }); ok {
+		expirable.OnExpire(notify)
+	}
+	return c
+}
+
+// Subscribe 订阅 set、del、evicted、expired 事件
+func (c *ObservableCache[K, V]) Subscribe() <-chan CacheEvent[K, V] {
+	return c.events.Subscribe(c.buffer)
+}
+
+// SubscribeWithOptions 按选项订阅事件，Buffer 小于等于0时使用创建时指定的缓冲大小；
+// Block 策略的订阅者处理过慢时会拖慢缓存的写入，建议设置 BlockTimeout
+func (c *ObservableCache[K, V]) SubscribeWithOptions(options concurrency.SubscribeOptions) <-chan CacheEvent[K, V] {
+	if options.Buffer <= 0 {
+		options.Buffer = c.buffer
+	}
+	return c.events.SubscribeWithOptions(options)
+}
+
+// Unsubscribe 取消订阅并关闭通道
+func (c *ObservableCache[K, V]) Unsubscribe(ch <-chan CacheEvent[K, V]) bool {
+	return c.events.Unsubscribe(ch)
+}
+
+// Close 关闭所有订阅通道，缓存本身仍可使用，之后的操作不再发布事件
+func (c *ObservableCache[K, V]) Close() {
+	c.events.Close()
+}
+
+// Dropped 返回因订阅者缓冲区满而丢弃的事件数
+func (c *ObservableCache[K, V]) Dropped() uint64 {
+	return c.events.Dropped()
+}
+
+// Get 获取缓存中的值，不发布事件
+func (c *ObservableCache[K, V]) Get(key K) (V, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.cache.Get(key)
+}
+
+// Put 写入并发布 set 事件；写入导致的淘汰先于 set 事件发布
+func (c *ObservableCache[K, V]) Put(key K, value V) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.cache.Put(key, value)
+	c.events.Publish(keyspace.EventSet, key, value)
+}
+
+// GetMulti 批量读取，返回命中的键值，不发布事件
+func (c *ObservableCache[K, V]) GetMulti(keys []K) map[K]V {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return GetMulti(c.cache, keys)
+}
+
+// PutMulti 批量写入，为每个键发布 set 事件
+func (c *ObservableCache[K, V]) PutMulti(items map[K]V) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	PutMulti(c.cache, items)
+	for key, value := range items {
+		c.events.Publish(keyspace.EventSet, key, value)
+	}
+}
+
+// Remove 删除指定键，键存在时发布 del 事件
+func (c *ObservableCache[K, V]) Remove(key K) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.cache.Remove(key) {
+		return false
+	}
+	var zero V
+	c.events.Publish(keyspace.EventDelete, key, zero)
+	return true
+}
+
+// Size 返回当前缓存中的元素数量
+func (c *ObservableCache[K, V]) Size() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.cache.Size()
+}
+
+// Keys 返回所有键
+func (c *ObservableCache[K, V]) Keys() []K {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.cache.Keys()
+}
+
+// Clear 清空缓存，为清空前的每个键发布 del 事件
+func (c *ObservableCache[K, V]) Clear() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	keys := c.cache.Keys()
+	c.cache.Clear()
+	var zero V
+	for _, key := range keys {
+		c.events.Publish(keyspace.EventDelete, key, zero)
+	}
+}
+
+// 场景示例：商品缓存的变更同时交给指标采集器和副本缓存处理
+func ObservableCacheDemo() {
+	fmt.Println("缓存事件订阅示例:")
+
+	primary := NewTTLLRU[string, int](3, 50*time.Millisecond)
+	cache := NewObservableCache[string, int](primary, 128)
+
+	// 指标采集器：按事件类型计数
+	var wg sync.WaitGroup
+	counts := make(map[keyspace.EventType]int)
+	metrics := cache.Subscribe()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for event := range metrics {
+			counts[event.Type]++
+		}
+	}()
+
+	// 副本缓存：重放写入，其余事件一律删除对应键；过期由主缓存决定，副本不设过期时间。
+	// 副本必须完整重放，缓冲区满时让写入等待而不是丢事件
+	replica := NewTTLLRU[string, int](3, 0)
+	var log []string
+	changes := cache.SubscribeWithOptions(concurrency.SubscribeOptions{Policy: concurrency.Block, BlockTimeout: 100 * time.Millisecond})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for event := range changes {
+			if event.Type == keyspace.EventSet {
+				replica.Put(event.Key, event.Value)
+			} else {
+				replica.Remove(event.Key)
+			}
+			log = append(log, fmt.Sprintf("%s %s=%d", event.Type, event.Key, event.Value))
+		}
+	}()
+
+	cache.Put("apple", 5)
+	cache.Put("banana", 3)
+	cache.Put("cherry", 8)
+	cache.Get("apple")
+	cache.Put("durian", 20) // 容量已满，淘汰最久未使用的 banana
+	cache.Put("apple", 6)
+	cache.Remove("cherry")
+	time.Sleep(80 * time.Millisecond)
+	cache.Get("apple") // 读取时发现已过期；没有被读取的过期条目不会产生事件
+	cache.Get("durian")
+	cache.Put("elderberry", 12)
+
+	cache.Close()
+	wg.Wait()
+
+	fmt.Println("副本收到的事件:")
+	for _, line := range log {
+		fmt.Printf("  %s\n", line)
+	}
+	primaryKeys, replicaKeys := cache.Keys(), replica.Keys()
+	sort.Strings(primaryKeys)
+	sort.Strings(replicaKeys)
+	fmt.Printf("主缓存的键: %v，副本的键: %v\n", primaryKeys, replicaKeys)
+	fmt.Printf("指标: set=%d del=%d evicted=%d expired=%d，丢弃 %d 个事件\n",
+		counts[keyspace.EventSet], counts[keyspace.EventDelete], counts[keyspace.EventEvicted],
+		counts[keyspace.EventExpired], cache.Dropped())
+}
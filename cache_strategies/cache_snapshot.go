@@ -0,0 +1,236 @@
+package cache_strategies
+
+/*
+缓存持久化：快照与恢复
+
+原理：
+进程内缓存随进程重启而清空，重启后的一段时间内所有请求都会穿透到数据库（冷启动），
+流量大的服务甚至会因此把数据库压垮。把缓存内容定期或在退出前写成快照，
+重启时从快照恢复，服务一启动就带着上一次的热点数据，命中率不必从零开始爬升。
+
+关键特点：
+1. 快照不只保存键值，还保存淘汰策略需要的元数据：LRU/FIFO 的顺序、LFU 的访问频率、TTL 的过期时间
+2. 条目按"最先被淘汰到最后被淘汰"的顺序写入，恢复时按顺序插入即可还原淘汰顺序；
+   快照条目多于当前容量时，丢弃最先会被淘汰的那部分
+3. 过期时间保存为绝对时间，停机期间到期的条目在恢复时直接丢弃，不会把过期数据带回来
+4. 快照头记录格式版本和策略名，用 LFU 的快照恢复 LRU 缓存会返回 ErrSnapshotMismatch
+
+实现方式：
+- 使用 encoding/gob 编码，键和值保留原始类型；值为接口类型时，需要先用 gob.Register 注册具体类型
+- 各缓存的 Save(io.Writer) 导出条目，Load(io.Reader) 清空当前内容后恢复，恢复过程不计入统计、不触发淘汰回调
+- SaveToFile 先写临时文件再重命名，进程在写快照途中崩溃也不会损坏上一份快照
+
+应用场景：
+- 服务重启、发布时预热缓存
+- 把线上缓存的热点数据导出，在测试环境复现
+- 定期快照，配合数据库实现简单的冷备
+
+优缺点：
+- 优点：实现简单，恢复后立即拥有接近重启前的命中率
+- 缺点：快照是某一时刻的副本，恢复时可能比数据库旧，只适合能容忍短暂不一致或带过期时间的数据；
+  缓存很大时保存和加载都需要时间，期间要持有缓存的锁
+
+以下实现了快照格式、文件读写辅助函数，以及服务重启后从快照预热缓存的示例。
+*/
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SnapshotVersion 快照格式版本，格式不兼容地变化时递增
+const SnapshotVersion = 1
+
+// ErrSnapshotMismatch 快照的版本或策略与要恢复的缓存不一致
+var ErrSnapshotMismatch = errors.New("快照与缓存不匹配")
+
+// SnapshotHeader 快照头
+type SnapshotHeader struct {
+	Version  int       // 格式版本
+	Policy   string    // 淘汰策略名，如 lru、lfu、fifo、ttl
+	Capacity int       // 保存时的容量，仅供参考，恢复时使用缓存自己的容量
+	SavedAt  time.Time // 保存时间
+}
+
+// SnapshotEntry 快照中的一个条目
+type SnapshotEntry[K comparable, V any] struct {
+	Key        K
+	Value      V
+	Freq       int       // 访问频率，仅LFU使用
+	ExpireTime time.Time // 过期时间，零值表示永不过期，仅TTL使用
+}
+
+// snapshot 快照文件的完整内容
+type snapshot[K comparable, V any] struct {
+	Header  SnapshotHeader
+	Entries []SnapshotEntry[K, V] // 按最先被淘汰到最后被淘汰的顺序排列
+}
+
+// Persistent 能够保存和恢复快照的缓存
+type Persistent interface {
+	Save(w io.Writer) error
+	Load(r io.Reader) error
+}
+
+// WriteSnapshot 把条目写成快照，entries 需按最先被淘汰到最后被淘汰的顺序排列
+func WriteSnapshot[K comparable, V any](w io.Writer, policy string, capacity int, entries []SnapshotEntry[K, V]) error {
+	data := snapshot[K, V]{
+		Header: SnapshotHeader{
+			Version:  SnapshotVersion,
+			Policy:   policy,
+			Capacity: capacity,
+			SavedAt:  time.Now(),
+		},
+		Entries: entries,
+	}
+	if err := gob.NewEncoder(w).Encode(&data); err != nil {
+		return fmt.Errorf("写入%s缓存快照失败: %w", policy, err)
+	}
+	return nil
+}
+
+// ReadSnapshot 读取快照并检查版本和策略，返回快照头和条目
+func ReadSnapshot[K comparable, V any](r io.Reader, policy string) (SnapshotHeader, []SnapshotEntry[K, V], error) {
+	var data snapshot[K, V]
+	if err := gob.NewDecoder(r).Decode(&data); err != nil {
+		return SnapshotHeader{}, nil, fmt.Errorf("读取%s缓存快照失败: %w", policy, err)
+	}
+	header := data.Header
+	if header.Version != SnapshotVersion {
+		return header, nil, fmt.Errorf("%w: 快照版本 %d，当前支持 %d", ErrSnapshotMismatch, header.Version, SnapshotVersion)
+	}
+	if header.Policy != policy {
+		return header, nil, fmt.Errorf("%w: 快照策略为 %s，缓存策略为 %s", ErrSnapshotMismatch, header.Policy, policy)
+	}
+	return header, data.Entries, nil
+}
+
+// SaveToFile 把缓存快照保存到文件，先写同目录下的临时文件再重命名，保证文件要么是旧快照要么是完整的新快照
+func SaveToFile(path string, cache Persistent) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmp := file.Name()
+	if err := cache.Save(file); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadFromFile 从文件恢复缓存快照
+func LoadFromFile(path string, cache Persistent) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := cache.Load(file); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// 场景示例：商品服务重启后从快照预热缓存，避免冷启动时请求全部打到数据库
+func CacheSnapshotDemo() {
+	fmt.Println("缓存快照示例:")
+
+	dir, err := os.MkdirTemp("", "cache-snapshot")
+	if err != nil {
+		fmt.Printf("创建临时目录失败: %v\n", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "products.snapshot")
+
+	// productService 模拟商品服务：缓存未命中时查询数据库
+	type productService struct {
+		cache   *FIFO[string, string]
+		dbReads int
+	}
+	newService := func() *productService {
+		return &productService{cache: NewFIFO[string, string](300)}
+	}
+	// 500个商品，访问集中在少数热门商品上
+	rng := rand.New(rand.NewSource(7))
+	trace := func(requests int) []string {
+		keys := make([]string, requests)
+		for i := range keys {
+			keys[i] = fmt.Sprintf("product:%03d", int(math.Pow(rng.Float64(), 5)*500))
+		}
+		return keys
+	}
+	serve := func(s *productService, keys []string) {
+		for _, key := range keys {
+			if _, ok := s.cache.Get(key); !ok {
+				s.dbReads++
+				s.cache.Put(key, "详情:"+key)
+			}
+		}
+	}
+
+	// 第一次启动：缓存从空开始，退出前保存快照
+	first := newService()
+	serve(first, trace(5000))
+	fmt.Printf("第一次启动: 5000个请求，数据库查询 %d 次，命中率 %.1f%%\n",
+		first.dbReads, first.cache.CacheStats().HitRate()*100)
+	if err := SaveToFile(path, first.cache); err != nil {
+		fmt.Printf("保存快照失败: %v\n", err)
+		return
+	}
+	if info, err := os.Stat(path); err == nil {
+		fmt.Printf("退出前保存快照: %d 个条目，%d 字节\n", first.cache.Size(), info.Size())
+	}
+
+	// 重启后的前300个请求：不加载快照（冷启动）与加载快照（预热）对比
+	cold, warm := newService(), newService()
+	if err := LoadFromFile(path, warm.cache); err != nil {
+		fmt.Printf("加载快照失败: %v\n", err)
+		return
+	}
+	afterRestart := trace(300)
+	serve(cold, afterRestart)
+	serve(warm, afterRestart)
+	fmt.Printf("重启后冷启动: 前300个请求数据库查询 %d 次，命中率 %.1f%%\n", cold.dbReads, cold.cache.CacheStats().HitRate()*100)
+	fmt.Printf("重启后预热:   前300个请求数据库查询 %d 次，命中率 %.1f%%\n", warm.dbReads, warm.cache.CacheStats().HitRate()*100)
+
+	// TTL缓存的快照保存绝对过期时间，停机期间到期的会话在恢复时被丢弃
+	sessions := NewTTL[string, string](TTLCacheOptions{DefaultTTL: time.Hour})
+	defer sessions.StopCleanup()
+	sessions.SetWithTTL("session:short", "即将过期", 20*time.Millisecond)
+	sessions.Set("session:user1", "张三")
+	sessions.SetForever("config:site", "v2")
+	sessionPath := filepath.Join(dir, "sessions.snapshot")
+	if err := SaveToFile(sessionPath, sessions); err != nil {
+		fmt.Printf("保存会话快照失败: %v\n", err)
+		return
+	}
+	time.Sleep(30 * time.Millisecond) // 停机期间 session:short 到期
+
+	restored := NewTTL[string, string](TTLCacheOptions{DefaultTTL: time.Hour})
+	defer restored.StopCleanup()
+	if err := LoadFromFile(sessionPath, restored); err != nil {
+		fmt.Printf("加载会话快照失败: %v\n", err)
+		return
+	}
+	fmt.Printf("\n会话快照: 保存 %d 个条目，恢复 %d 个（停机期间过期的会话被丢弃）\n", sessions.Size(), restored.Size())
+
+	// 快照策略不一致时拒绝恢复
+	if err := LoadFromFile(sessionPath, NewFIFO[string, string](10)); errors.Is(err, ErrSnapshotMismatch) {
+		fmt.Printf("用TTL快照恢复FIFO缓存: %v\n", err)
+	}
+}
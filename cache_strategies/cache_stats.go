@@ -0,0 +1,190 @@
+package cache_strategies
+
+/*
+缓存访问统计
+
+原理：
+判断一个缓存是否有效，最直接的指标是命中率；判断容量是否合适，要看淘汰次数；判断过期时间是否合理，要看过期次数。
+每个缓存在读写路径上记录四个计数：
+- 命中（hits）：Get 找到了未过期的值
+- 未命中（misses）：Get 没有找到，或者找到的值已过期
+- 淘汰（evictions）：因容量不足（包括 Resize 缩容、准入失败）被移出缓存的条目
+- 过期（expirations）：因 TTL 到期被删除的条目
+主动调用 Remove、Clear 删除的条目不计入淘汰和过期。
+
+关键特点：
+1. 所有缓存共用同一个 StatsCounter，统计口径一致，可以直接横向比较
+2. 计数器使用原子操作，缓存本身改为并发访问后统计仍然准确，读取统计也不需要持有缓存的锁
+3. Snapshot 返回某一时刻的快照，两个快照相减可以得到一段时间内的增量
+
+实现方式：
+- StatsCounter 由四个 uint64 计数器组成，通过 atomic.AddUint64 递增、atomic.LoadUint64 读取
+- 各缓存的 Stats 方法把快照转换为 map，并补充容量、大小等策略特有的信息
+
+应用场景：
+- 监控看板上展示各个缓存的命中率
+- 根据淘汰次数判断是否需要扩容，根据过期次数调整 TTL
+- 在同一条访问轨迹上对比不同淘汰策略
+
+优缺点：
+- 优点：开销只有几次原子加法，所有策略口径一致
+- 缺点：四个计数器分别读取，快照中的各项之间不保证严格一致（例如命中数和未命中数可能来自相差几次访问的时刻）
+
+以下实现了缓存统计计数器，以及在同一访问轨迹上输出所有已注册策略统计的示例。
+*/
+
+import (
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// CacheStats 缓存访问统计的快照
+type CacheStats struct {
+	Hits        uint64 // 命中次数
+	Misses      uint64 // 未命中次数（包括读到已过期的值）
+	Evictions   uint64 // 因容量不足被淘汰的条目数
+	Expirations uint64 // 因过期被删除的条目数
+}
+
+// Requests 返回读取总次数
+func (s CacheStats) Requests() uint64 {
+	return s.Hits + s.Misses
+}
+
+// HitRate 返回命中率，没有读取时为0
+func (s CacheStats) HitRate() float64 {
+	if total := s.Requests(); total > 0 {
+		return float64(s.Hits) / float64(total)
+	}
+	return 0
+}
+
+// Sub 返回两个快照之间的增量
+func (s CacheStats) Sub(earlier CacheStats) CacheStats {
+	return CacheStats{
+		Hits:        s.Hits - earlier.Hits,
+		Misses:      s.Misses - earlier.Misses,
+		Evictions:   s.Evictions - earlier.Evictions,
+		Expirations: s.Expirations - earlier.Expirations,
+	}
+}
+
+// Map 把快照转换为 Stats 方法使用的 map
+func (s CacheStats) Map() map[string]interface{} {
+	return map[string]interface{}{
+		"hits":        s.Hits,
+		"misses":      s.Misses,
+		"evictions":   s.Evictions,
+		"expirations": s.Expirations,
+		"hitRate":     s.HitRate(),
+	}
+}
+
+// String 返回便于打印的统计摘要
+func (s CacheStats) String() string {
+	return fmt.Sprintf("命中 %d，未命中 %d，命中率 %.1f%%，淘汰 %d，过期 %d",
+		s.Hits, s.Misses, s.HitRate()*100, s.Evictions, s.Expirations)
+}
+
+// StatsCounter 并发安全的缓存统计计数器，零值可直接使用
+type StatsCounter struct {
+	hits        uint64
+	misses      uint64
+	evictions   uint64
+	expirations uint64
+}
+
+// RecordHit 记录一次命中
+func (s *StatsCounter) RecordHit() {
+	atomic.AddUint64(&s.hits, 1)
+}
+
+// RecordMiss 记录一次未命中
+func (s *StatsCounter) RecordMiss() {
+	atomic.AddUint64(&s.misses, 1)
+}
+
+// RecordEvictions 记录 n 个条目被淘汰
+func (s *StatsCounter) RecordEvictions(n int) {
+	if n > 0 {
+		atomic.AddUint64(&s.evictions, uint64(n))
+	}
+}
+
+// RecordExpirations 记录 n 个条目过期
+func (s *StatsCounter) RecordExpirations(n int) {
+	if n > 0 {
+		atomic.AddUint64(&s.expirations, uint64(n))
+	}
+}
+
+// Snapshot 返回当前计数的快照
+func (s *StatsCounter) Snapshot() CacheStats {
+	return CacheStats{
+		Hits:        atomic.LoadUint64(&s.hits),
+		Misses:      atomic.LoadUint64(&s.misses),
+		Evictions:   atomic.LoadUint64(&s.evictions),
+		Expirations: atomic.LoadUint64(&s.expirations),
+	}
+}
+
+// Reset 把所有计数清零
+func (s *StatsCounter) Reset() {
+	atomic.StoreUint64(&s.hits, 0)
+	atomic.StoreUint64(&s.misses, 0)
+	atomic.StoreUint64(&s.evictions, 0)
+	atomic.StoreUint64(&s.expirations, 0)
+}
+
+// StatsReporter 能够输出统计信息的缓存
+type StatsReporter interface {
+	Stats() map[string]interface{}
+}
+
+// 场景示例：同一条访问轨迹下各策略的命中、淘汰和过期情况
+func CacheStatsDemo() {
+	fmt.Println("缓存统计示例 (容量=50，1000个键，偏斜访问20000次):")
+
+	rng := rand.New(rand.NewSource(7))
+	trace := make([]string, 20000)
+	for i := range trace {
+		r := rng.Float64()
+		trace[i] = fmt.Sprintf("key-%03d", int(r*r*r*1000))
+	}
+
+	for _, policy := range Policies() {
+		cache, err := NewCache(policy, 50)
+		if err != nil {
+			fmt.Printf("创建 %s 失败: %v\n", policy, err)
+			continue
+		}
+		for _, key := range trace {
+			if _, ok := cache.Get(key); !ok {
+				cache.Put(key, key)
+			}
+		}
+		reporter, ok := cache.(StatsReporter)
+		if !ok {
+			fmt.Printf("  %-10s 不支持统计\n", policy)
+			continue
+		}
+		stats := reporter.Stats()
+		fmt.Printf("  %-10s 命中率 %5.1f%%  命中 %-6v 未命中 %-6v 淘汰 %-6v 过期 %v\n", policy,
+			stats["hitRate"].(float64)*100, stats["hits"], stats["misses"], stats["evictions"], stats["expirations"])
+	}
+
+	// TTL缓存没有容量限制，条目只会过期，不会被淘汰
+	sessions := NewTTLCache(TTLCacheOptions{DefaultTTL: 20 * time.Millisecond})
+	defer sessions.StopCleanup()
+	for i := 0; i < 10; i++ {
+		sessions.Put(fmt.Sprintf("session-%d", i), i)
+	}
+	before := sessions.CacheStats()
+	sessions.Get("session-0")
+	time.Sleep(30 * time.Millisecond)
+	sessions.Get("session-1") // 懒惰过期
+	sessions.Cleanup()        // 清理其余8个过期会话
+	fmt.Printf("\n会话缓存 (TTL=20ms): %s\n", sessions.CacheStats().Sub(before))
+}
@@ -0,0 +1,187 @@
+package cache_strategies
+
+/*
+缓存淘汰回调（OnEvict / OnExpire）
+
+原理：
+缓存中的值有时不只是一份副本：写回（write-back）模式下，被修改过的值只存在于缓存中，
+淘汰前必须先写回数据库；值也可能持有文件句柄、连接等资源，离开缓存时需要释放。
+淘汰回调让使用者在条目因容量不足被淘汰、或因TTL到期被删除时得到通知，拿到键、值和原因。
+
+关键特点：
+1. 回调收到键、值和原因（写入时容量已满、Resize 缩容、过期），同一个回调可以按原因区分处理
+2. 只有缓存自己决定删除的条目才会触发回调；主动调用 Remove、Clear 或用 Put 覆盖旧值不会触发，
+   调用方此时手里已经有这些值
+3. 回调在缓存的数据结构更新完成、并且释放内部锁之后才执行，回调中可以再次读写同一个缓存
+4. 回调同步执行，耗时的操作（如写回数据库）应在回调中自行异步处理
+
+实现方式：
+- EvictionListeners 保存回调列表，零值可直接使用，注册和通知都是并发安全的
+- LRU、LFU、FIFO 在 Put 和 Resize 淘汰条目后通知 OnEvict 注册的回调
+- TTL 在懒惰过期和 Cleanup 删除条目后通知 OnExpire 注册的回调，Cleanup 先收集过期条目，解锁后再逐个通知
+
+应用场景：
+- 写回缓存：淘汰脏数据前写回数据库
+- 资源管理：连接、文件句柄离开缓存时关闭
+- 监控：记录被淘汰的热点键，辅助调整容量
+
+优缺点：
+- 优点：使用者不需要轮询或包装缓存就能感知淘汰，释放资源和写回的逻辑与业务代码解耦
+- 缺点：回调同步执行，慢回调会拖慢触发淘汰的 Put；通过 SyncCache 等外部加锁的包装使用时，
+  回调执行期间外部锁仍被持有，回调中不能再访问同一个包装
+
+以下实现了淘汰回调列表，以及写回缓存和连接缓存两个示例。
+*/
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// EvictionReason 条目被缓存删除的原因
+type EvictionReason int
+
+const (
+	EvictionCapacity       EvictionReason = iota // 写入新条目时容量已满（包括准入失败被拒绝）
+	EvictionResize                               // 调用 Resize 缩小容量
+	EvictionExpired                              // TTL 到期
+	EvictionMemoryPressure                       // 内存用量超过阈值时被 PressureEvictor 主动淘汰
+)
+
+// String 返回原因的名称
+func (r EvictionReason) String() string {
+	switch r {
+	case EvictionCapacity:
+		return "capacity"
+	case EvictionResize:
+		return "resize"
+	case EvictionExpired:
+		return "expired"
+	case EvictionMemoryPressure:
+		return "memory-pressure"
+	default:
+		return fmt.Sprintf("EvictionReason(%d)", int(r))
+	}
+}
+
+// EvictionCallback 淘汰回调，收到被删除条目的键、值和原因
+type EvictionCallback[K comparable, V any] func(key K, value V, reason EvictionReason)
+
+// EvictionListeners 淘汰回调列表，零值可直接使用，并发安全
+type EvictionListeners[K comparable, V any] struct {
+	mutex     sync.RWMutex
+	callbacks []EvictionCallback[K, V]
+}
+
+// Add 注册回调，按注册顺序调用
+func (l *EvictionListeners[K, V]) Add(callback EvictionCallback[K, V]) {
+	if callback == nil {
+		return
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.callbacks = append(l.callbacks, callback)
+}
+
+// Len 返回已注册的回调数量，没有回调时缓存可以跳过收集被删除的条目
+func (l *EvictionListeners[K, V]) Len() int {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return len(l.callbacks)
+}
+
+// Notify 依次调用所有回调；回调列表先复制出来，回调中注册新回调不会死锁
+func (l *EvictionListeners[K, V]) Notify(key K, value V, reason EvictionReason) {
+	l.mutex.RLock()
+	callbacks := l.callbacks
+	l.mutex.RUnlock()
+
+	for _, callback := range callbacks {
+		callback(key, value, reason)
+	}
+}
+
+// 场景示例：写回缓存在淘汰脏数据前写回数据库，连接缓存在连接过期时关闭连接
+func EvictionListenerDemo() {
+	fmt.Println("淘汰回调示例:")
+
+	// 写回缓存：账户余额只在缓存中修改，被淘汰时才写回数据库
+	type account struct {
+		balance int
+		dirty   bool // 缓存中的值比数据库新
+	}
+	database := map[string]int{"alice": 100, "bob": 50, "carol": 80, "dave": 20}
+	writes := 0
+
+	cache := NewFIFO[string, *account](2)
+	cache.OnEvict(func(key string, acc *account, reason EvictionReason) {
+		if !acc.dirty {
+			fmt.Printf("  淘汰 %-5s (%s) 数据未修改，直接丢弃\n", key, reason)
+			return
+		}
+		database[key] = acc.balance
+		writes++
+		fmt.Printf("  淘汰 %-5s (%s) 写回数据库: 余额 %d\n", key, reason, acc.balance)
+	})
+
+	load := func(name string) *account {
+		if acc, ok := cache.Get(name); ok {
+			return acc
+		}
+		acc := &account{balance: database[name]}
+		cache.Put(name, acc)
+		return acc
+	}
+	transfer := func(from, to string, amount int) {
+		src, dst := load(from), load(to)
+		src.balance -= amount
+		dst.balance += amount
+		src.dirty, dst.dirty = true, true
+		fmt.Printf("转账 %s -> %s %d\n", from, to, amount)
+	}
+
+	fmt.Println("\n=== 写回缓存 (FIFO容量=2) ===")
+	transfer("alice", "bob", 30)
+	load("carol") // 只读，淘汰最早进入的 alice
+	transfer("carol", "dave", 10)
+	cache.Resize(1)
+
+	names := make([]string, 0, len(database))
+	for name := range database {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Printf("数据库写入 %d 次，当前余额:", writes)
+	for _, name := range names {
+		fmt.Printf(" %s=%d", name, database[name])
+	}
+	fmt.Printf("（仍在缓存中未写回: %v）\n", cache.Keys())
+
+	// 连接缓存：空闲连接过期后关闭，避免连接泄漏
+	type conn struct {
+		addr   string
+		closed bool
+	}
+	fmt.Println("\n=== 连接缓存 (TTL=20ms) ===")
+	conns := NewTTL[string, *conn](TTLCacheOptions{DefaultTTL: 20 * time.Millisecond})
+	defer conns.StopCleanup()
+	conns.OnExpire(func(addr string, c *conn, reason EvictionReason) {
+		c.closed = true
+		fmt.Printf("  连接 %s %s，已关闭\n", addr, reason)
+	})
+
+	opened := []*conn{{addr: "10.0.0.1:3306"}, {addr: "10.0.0.2:3306"}, {addr: "10.0.0.3:3306"}}
+	for _, c := range opened {
+		conns.Put(c.addr, c)
+	}
+	conns.SetWithTTL(opened[2].addr, opened[2], time.Hour) // 主库连接长期保留
+	time.Sleep(30 * time.Millisecond)
+
+	conns.Get(opened[0].addr) // 懒惰过期
+	conns.Cleanup()           // 清理其余过期连接
+	for _, c := range opened {
+		fmt.Printf("%s 已关闭=%v\n", c.addr, c.closed)
+	}
+}
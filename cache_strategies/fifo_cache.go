@@ -32,69 +32,96 @@ FIFO是最简单的缓存替换算法，基于"先进先出"原则淘汰数据
 import (
 	"container/list"
 	"fmt"
+	"io"
+
+	"github.com/strive/scenario/sizeof"
 )
 
-// FIFONode FIFO缓存节点结构
-type FIFONode struct {
-	Key   string
-	Value interface{}
+// FIFOEntry FIFO缓存节点结构
+type FIFOEntry[K comparable, V any] struct {
+	Key   K
+	Value V
 }
 
-// FIFOCache FIFO缓存结构
-type FIFOCache struct {
-	capacity int                      // 最大容量
-	queue    *list.List               // 队列：维护先进先出顺序
-	cache    map[string]*list.Element // 哈希表：键 -> 队列节点
+// FIFO 泛型FIFO缓存结构
+type FIFO[K comparable, V any] struct {
+	capacity int                 // 最大容量
+	queue    *list.List          // 队列：维护先进先出顺序
+	cache    map[K]*list.Element // 哈希表：键 -> 队列节点
+	stats    StatsCounter        // 访问统计
+	onEvict  EvictionListeners[K, V]
 }
 
+// FIFONode 字符串键FIFO缓存节点（兼容旧接口）
+type FIFONode = FIFOEntry[string, interface{}]
+
+// FIFOCache 字符串键、任意值的FIFO缓存（兼容旧接口）
+type FIFOCache = FIFO[string, interface{}]
+
 // NewFIFOCache 创建指定容量的FIFO缓存
 func NewFIFOCache(capacity int) *FIFOCache {
-	return &FIFOCache{
+	return NewFIFO[string, interface{}](capacity)
+}
+
+// NewFIFO 创建指定容量的泛型FIFO缓存
+func NewFIFO[K comparable, V any](capacity int) *FIFO[K, V] {
+	return &FIFO[K, V]{
 		capacity: capacity,
 		queue:    list.New(),
-		cache:    make(map[string]*list.Element),
+		cache:    make(map[K]*list.Element),
 	}
 }
 
-// Get 获取缓存中的值，不存在返回nil和false
-func (c *FIFOCache) Get(key string) (interface{}, bool) {
+// Get 获取缓存中的值，不存在返回零值和false
+func (c *FIFO[K, V]) Get(key K) (V, bool) {
 	// 查找哈希表
 	if element, exists := c.cache[key]; exists {
 		// 返回节点值，但不改变位置（与LRU不同）
-		return element.Value.(*FIFONode).Value, true
+		c.stats.RecordHit()
+		return element.Value.(*FIFOEntry[K, V]).Value, true
 	}
 	// 未找到
-	return nil, false
+	c.stats.RecordMiss()
+	var zero V
+	return zero, false
 }
 
 // Put 插入或更新缓存中的键值对
-func (c *FIFOCache) Put(key string, value interface{}) {
+func (c *FIFO[K, V]) Put(key K, value V) {
 	// 如果键已存在，只更新值，不改变位置（与LRU不同）
 	if element, exists := c.cache[key]; exists {
-		element.Value.(*FIFONode).Value = value
+		element.Value.(*FIFOEntry[K, V]).Value = value
 		return
 	}
 
 	// 如果达到容量上限，从队列头部删除最早的元素
+	var evicted *FIFOEntry[K, V]
 	if c.queue.Len() >= c.capacity {
 		oldest := c.queue.Front()
 		if oldest != nil {
 			c.queue.Remove(oldest)
+			evicted = oldest.Value.(*FIFOEntry[K, V])
 			// 从哈希表中删除
-			delete(c.cache, oldest.Value.(*FIFONode).Key)
+			delete(c.cache, evicted.Key)
+			c.stats.RecordEvictions(1)
 		}
 	}
 
 	// 创建新节点并添加到队列尾部
-	node := &FIFONode{Key: key, Value: value}
+	node := &FIFOEntry[K, V]{Key: key, Value: value}
 	element := c.queue.PushBack(node)
 
 	// 在哈希表中记录节点位置
 	c.cache[key] = element
+
+	// 新节点插入后再通知，回调中可以安全地访问缓存
+	if evicted != nil {
+		c.onEvict.Notify(evicted.Key, evicted.Value, EvictionCapacity)
+	}
 }
 
 // Remove 从缓存中删除指定键
-func (c *FIFOCache) Remove(key string) bool {
+func (c *FIFO[K, V]) Remove(key K) bool {
 	if element, exists := c.cache[key]; exists {
 		c.queue.Remove(element)
 		delete(c.cache, key)
@@ -104,25 +131,98 @@ func (c *FIFOCache) Remove(key string) bool {
 }
 
 // Size 返回当前缓存中的元素数量
-func (c *FIFOCache) Size() int {
+func (c *FIFO[K, V]) Size() int {
 	return c.queue.Len()
 }
 
+// Capacity 返回最大容量
+func (c *FIFO[K, V]) Capacity() int {
+	return c.capacity
+}
+
+// Resize 调整最大容量（至少为1），容量变小时从队列头部淘汰多出的元素，返回淘汰的数量
+func (c *FIFO[K, V]) Resize(capacity int) int {
+	if capacity < 1 {
+		capacity = 1
+	}
+	c.capacity = capacity
+
+	var evicted []*FIFOEntry[K, V]
+	for c.queue.Len() > capacity {
+		oldest := c.queue.Front()
+		c.queue.Remove(oldest)
+		entry := oldest.Value.(*FIFOEntry[K, V])
+		delete(c.cache, entry.Key)
+		evicted = append(evicted, entry)
+	}
+	c.stats.RecordEvictions(len(evicted))
+	for _, entry := range evicted {
+		c.onEvict.Notify(entry.Key, entry.Value, EvictionResize)
+	}
+	return len(evicted)
+}
+
+// OnEvict 注册淘汰回调，条目因容量不足或 Resize 缩容被淘汰时调用；Remove、Clear 不会触发
+func (c *FIFO[K, V]) OnEvict(callback EvictionCallback[K, V]) {
+	c.onEvict.Add(callback)
+}
+
 // Clear 清空缓存
-func (c *FIFOCache) Clear() {
+func (c *FIFO[K, V]) Clear() {
 	c.queue = list.New()
-	c.cache = make(map[string]*list.Element)
+	c.cache = make(map[K]*list.Element)
 }
 
 // Keys 返回缓存中所有键的列表（按FIFO顺序）
-func (c *FIFOCache) Keys() []string {
-	keys := make([]string, 0, c.queue.Len())
+func (c *FIFO[K, V]) Keys() []K {
+	keys := make([]K, 0, c.queue.Len())
 	for e := c.queue.Front(); e != nil; e = e.Next() {
-		keys = append(keys, e.Value.(*FIFONode).Key)
+		keys = append(keys, e.Value.(*FIFOEntry[K, V]).Key)
 	}
 	return keys
 }
 
+// Save 把缓存内容按入队顺序写成快照
+func (c *FIFO[K, V]) Save(w io.Writer) error {
+	entries := make([]SnapshotEntry[K, V], 0, c.queue.Len())
+	for e := c.queue.Front(); e != nil; e = e.Next() {
+		node := e.Value.(*FIFOEntry[K, V])
+		entries = append(entries, SnapshotEntry[K, V]{Key: node.Key, Value: node.Value})
+	}
+	return WriteSnapshot(w, "fifo", c.capacity, entries)
+}
+
+// Load 清空缓存并从快照恢复，快照条目多于容量时丢弃最早入队的条目
+func (c *FIFO[K, V]) Load(r io.Reader) error {
+	_, entries, err := ReadSnapshot[K, V](r, "fifo")
+	if err != nil {
+		return err
+	}
+	c.Clear()
+	for _, entry := range entries[max(len(entries)-c.capacity, 0):] {
+		c.cache[entry.Key] = c.queue.PushBack(&FIFOEntry[K, V]{Key: entry.Key, Value: entry.Value})
+	}
+	return nil
+}
+
+// CacheStats 返回命中、未命中、淘汰和过期次数的快照
+func (c *FIFO[K, V]) CacheStats() CacheStats {
+	return c.stats.Snapshot()
+}
+
+// Stats 返回访问统计和容量信息
+func (c *FIFO[K, V]) Stats() map[string]interface{} {
+	stats := c.stats.Snapshot().Map()
+	stats["capacity"] = c.capacity
+	stats["size"] = c.queue.Len()
+	return stats
+}
+
+// MemoryUsage 返回深度估算的内存占用（字节）
+func (c *FIFO[K, V]) MemoryUsage() int64 {
+	return sizeof.Of(c)
+}
+
 // 场景示例：网络请求缓存
 func FIFOCacheDemo() {
 	// 创建容量为3的FIFO缓存
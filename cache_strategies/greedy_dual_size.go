@@ -0,0 +1,341 @@
+package cache_strategies
+
+/*
+GreedyDual-Size 按成本和大小淘汰的缓存替换算法
+
+原理：
+LRU、LFU 假设所有条目一样大、重新获取的代价一样高。CDN、Web 代理缓存的对象却从几KB的页面到几MB的视频不等，
+回源代价也不同：同机房的源站几毫秒，跨洋的源站几百毫秒。GreedyDual-Size（Cao & Irani, 1997）为每个条目维护优先级
+    H = L + cost / size
+淘汰时删除 H 最小的条目，并把全局"通胀值" L 提高到被淘汰条目的 H；条目被访问时用当前的 L 重新计算 H。
+- cost/size 越大（单位字节的回源代价越高）的条目越晚被淘汰
+- L 随淘汰单调增长，长期不被访问的条目的 H 相对越来越低，因此也有 LRU 式的"老化"效果
+
+关键特点：
+1. 成本模型决定算法的目标：cost=1 时倾向保留小对象，最大化请求命中率；cost=回源延迟时最小化总回源代价；
+   cost=size 时所有条目的 cost/size 相同，算法退化为 LRU，字节命中率最高
+2. 容量按条目大小之和限制，单个条目超过上限时不写入
+3. 优先级相同时先淘汰更早访问的条目
+4. 不需要为老化单独做衰减：L 的增长隐含了老化
+
+实现方式：
+- 按 (H, 最近访问序号) 排序的最小堆，条目记录自己在堆中的下标，访问时 heap.Fix 调整位置，O(log n)
+- 哈希表保存键到条目的映射
+- Put 使用 cost=1、大小由 Weigher 计算；PutWithCost 指定单个条目的成本和大小
+
+应用场景：
+- CDN 边缘节点、Web 代理缓存（Squid 的 GDSF 策略即为其变体）
+- 查询结果缓存：不同查询的计算代价差异很大
+- 任何条目大小、重新获取代价差异明显的缓存
+
+优缺点：
+- 优点：同时考虑大小、成本和最近性，成本模型可按业务目标切换，没有需要调的参数
+- 缺点：访问时更新堆为 O(log n)，比 LRU 的 O(1) 慢；不考虑访问频率（GDSF 在公式中再乘以访问次数）
+
+以下实现了 GreedyDual-Size 缓存，以及 CDN 边缘节点在三种成本模型下的对比示例。
+*/
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/strive/scenario/sizeof"
+)
+
+// GDSEntry GreedyDual-Size缓存节点结构
+type GDSEntry[K comparable, V any] struct {
+	Key      K
+	Value    V
+	Cost     float64 // 重新获取的代价
+	Size     int64   // 条目大小
+	priority float64 // H = L + Cost/Size
+	seq      uint64  // 最近一次访问的序号，优先级相同时先淘汰序号小的
+	index    int     // 在堆中的下标
+}
+
+// gdsHeap 按优先级排序的最小堆，实现 heap.Interface
+type gdsHeap[K comparable, V any] []*GDSEntry[K, V]
+
+func (h gdsHeap[K, V]) Len() int { return len(h) }
+func (h gdsHeap[K, V]) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority < h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+func (h gdsHeap[K, V]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *gdsHeap[K, V]) Push(x any) {
+	entry := x.(*GDSEntry[K, V])
+	entry.index = len(*h)
+	*h = append(*h, entry)
+}
+
+func (h *gdsHeap[K, V]) Pop() any {
+	old := *h
+	n := len(old)
+	entry := old[n-1]
+	old[n-1] = nil
+	entry.index = -1
+	*h = old[:n-1]
+	return entry
+}
+
+// GreedyDualSize 泛型GreedyDual-Size缓存结构
+type GreedyDualSize[K comparable, V any] struct {
+	maxSize   int64                 // 条目大小之和的上限
+	size      int64                 // 当前条目大小之和
+	inflation float64               // 通胀值 L，等于最近一次被淘汰条目的优先级
+	seq       uint64                // 访问序号
+	entries   gdsHeap[K, V]         // 按优先级排序的最小堆
+	cache     map[K]*GDSEntry[K, V] // 哈希表：键 -> 节点
+	weigher   Weigher[K, V]         // Put 使用的大小计算函数
+	stats     StatsCounter          // 访问统计
+	onEvict   EvictionListeners[K, V]
+}
+
+// GDSCache 字符串键、任意值的GreedyDual-Size缓存
+type GDSCache = GreedyDualSize[string, interface{}]
+
+// NewGDSCache 创建大小之和不超过 maxSize 的GreedyDual-Size缓存，Put 按 SizeOfWeigher 计算大小
+func NewGDSCache(maxSize int64) *GDSCache {
+	return NewGreedyDualSize[string, interface{}](maxSize, nil)
+}
+
+// NewGreedyDualSize 创建大小之和不超过 maxSize 的泛型GreedyDual-Size缓存，weigher 为 nil 时使用 SizeOfWeigher
+func NewGreedyDualSize[K comparable, V any](maxSize int64, weigher Weigher[K, V]) *GreedyDualSize[K, V] {
+	if weigher == nil {
+		weigher = SizeOfWeigher[K, V]()
+	}
+	return &GreedyDualSize[K, V]{
+		maxSize: max(maxSize, 1),
+		cache:   make(map[K]*GDSEntry[K, V]),
+		weigher: weigher,
+	}
+}
+
+// touch 用当前的通胀值重新计算优先级，并记为最近访问
+func (c *GreedyDualSize[K, V]) touch(entry *GDSEntry[K, V]) {
+	c.seq++
+	entry.seq = c.seq
+	entry.priority = c.inflation + entry.Cost/float64(entry.Size)
+}
+
+// Get 获取缓存中的值并恢复它的优先级，不存在返回零值和false
+func (c *GreedyDualSize[K, V]) Get(key K) (V, bool) {
+	entry, exists := c.cache[key]
+	if !exists {
+		c.stats.RecordMiss()
+		var zero V
+		return zero, false
+	}
+	c.stats.RecordHit()
+	c.touch(entry)
+	heap.Fix(&c.entries, entry.index)
+	return entry.Value, true
+}
+
+// Put 以成本1写入，大小由 Weigher 计算（实现 Cache 接口）
+func (c *GreedyDualSize[K, V]) Put(key K, value V) {
+	c.PutWithCost(key, value, 1, c.weigher(key, value))
+}
+
+// PutWithCost 写入并指定重新获取的代价和条目大小（小于1时按1计算），总大小超出上限时按优先级从低到高淘汰；
+// 单个条目超过上限时不写入，已有的旧值被删除，计为一次淘汰
+func (c *GreedyDualSize[K, V]) PutWithCost(key K, value V, cost float64, size int64) {
+	size = max(size, 1)
+	cost = max(cost, 0)
+
+	// 更新视为删除旧条目后重新写入
+	if entry, exists := c.cache[key]; exists {
+		c.removeEntry(entry)
+	}
+
+	var evicted []*GDSEntry[K, V]
+	if size > c.maxSize {
+		c.stats.RecordEvictions(1)
+		evicted = append(evicted, &GDSEntry[K, V]{Key: key, Value: value})
+	} else {
+		evicted = c.makeRoom(size)
+		// 淘汰提高了通胀值，新条目按淘汰后的通胀值计算优先级
+		entry := &GDSEntry[K, V]{Key: key, Value: value, Cost: cost, Size: size}
+		c.touch(entry)
+		heap.Push(&c.entries, entry)
+		c.cache[key] = entry
+		c.size += size
+	}
+
+	// 新条目写入后再通知，回调中可以安全地访问缓存
+	for _, e := range evicted {
+		c.onEvict.Notify(e.Key, e.Value, EvictionCapacity)
+	}
+}
+
+// makeRoom 淘汰优先级最低的条目直到能再放下 size 大小，并把通胀值提高到被淘汰条目的优先级
+func (c *GreedyDualSize[K, V]) makeRoom(size int64) []*GDSEntry[K, V] {
+	var evicted []*GDSEntry[K, V]
+	for c.size+size > c.maxSize && len(c.entries) > 0 {
+		victim := c.entries[0]
+		c.inflation = victim.priority
+		c.removeEntry(victim)
+		c.stats.RecordEvictions(1)
+		evicted = append(evicted, victim)
+	}
+	return evicted
+}
+
+// removeEntry 从堆和哈希表中删除条目
+func (c *GreedyDualSize[K, V]) removeEntry(entry *GDSEntry[K, V]) {
+	heap.Remove(&c.entries, entry.index)
+	delete(c.cache, entry.Key)
+	c.size -= entry.Size
+}
+
+// Victim 返回下一个将被淘汰的键（优先级最低），缓存为空时返回 false
+func (c *GreedyDualSize[K, V]) Victim() (K, bool) {
+	if len(c.entries) == 0 {
+		var zero K
+		return zero, false
+	}
+	return c.entries[0].Key, true
+}
+
+// Contains 判断键是否存在，不改变优先级
+func (c *GreedyDualSize[K, V]) Contains(key K) bool {
+	_, exists := c.cache[key]
+	return exists
+}
+
+// Remove 从缓存中删除指定键
+func (c *GreedyDualSize[K, V]) Remove(key K) bool {
+	entry, exists := c.cache[key]
+	if !exists {
+		return false
+	}
+	c.removeEntry(entry)
+	return true
+}
+
+// Size 返回当前缓存中的元素数量
+func (c *GreedyDualSize[K, V]) Size() int {
+	return len(c.cache)
+}
+
+// Weight 返回当前条目大小之和
+func (c *GreedyDualSize[K, V]) Weight() int64 {
+	return c.size
+}
+
+// MaxWeight 返回条目大小之和的上限
+func (c *GreedyDualSize[K, V]) MaxWeight() int64 {
+	return c.maxSize
+}
+
+// Inflation 返回当前的通胀值 L
+func (c *GreedyDualSize[K, V]) Inflation() float64 {
+	return c.inflation
+}
+
+// OnEvict 注册淘汰回调，条目因总大小超出上限被淘汰或自身超过上限无法写入时调用；Remove、Clear 不会触发
+func (c *GreedyDualSize[K, V]) OnEvict(callback EvictionCallback[K, V]) {
+	c.onEvict.Add(callback)
+}
+
+// Keys 返回缓存中所有键的列表（按优先级从高到低，即越靠后越先被淘汰）
+func (c *GreedyDualSize[K, V]) Keys() []K {
+	entries := append([]*GDSEntry[K, V](nil), c.entries...)
+	sort.Slice(entries, func(i, j int) bool {
+		return gdsHeap[K, V](entries).Less(j, i)
+	})
+	keys := make([]K, len(entries))
+	for i, entry := range entries {
+		keys[i] = entry.Key
+	}
+	return keys
+}
+
+// Clear 清空缓存，通胀值归零
+func (c *GreedyDualSize[K, V]) Clear() {
+	c.entries = nil
+	c.cache = make(map[K]*GDSEntry[K, V])
+	c.size = 0
+	c.inflation = 0
+}
+
+// CacheStats 返回命中、未命中、淘汰和过期次数的快照
+func (c *GreedyDualSize[K, V]) CacheStats() CacheStats {
+	return c.stats.Snapshot()
+}
+
+// Stats 返回访问统计、容量和通胀值
+func (c *GreedyDualSize[K, V]) Stats() map[string]interface{} {
+	stats := c.stats.Snapshot().Map()
+	stats["size"] = len(c.cache)
+	stats["weight"] = c.size
+	stats["maxWeight"] = c.maxSize
+	stats["inflation"] = c.inflation
+	return stats
+}
+
+// MemoryUsage 返回深度估算的内存占用（字节）
+func (c *GreedyDualSize[K, V]) MemoryUsage() int64 {
+	return sizeof.Of(c)
+}
+
+// 场景示例：CDN 边缘节点缓存大小不一、源站远近不同的对象
+func GreedyDualSizeDemo() {
+	fmt.Println("GreedyDual-Size缓存示例 (CDN边缘节点，容量20MB):")
+
+	// 2000个对象，大小在1KB到1MB之间对数均匀分布；每5个对象中有1个来自跨洋源站，回源延迟200ms，其余20ms
+	type object struct {
+		size    int64
+		latency float64 // 回源延迟（ms）
+	}
+	rng := rand.New(rand.NewSource(42))
+	objects := make([]object, 2000)
+	for i := range objects {
+		objects[i].size = int64(math.Exp(math.Log(1024) + rng.Float64()*math.Log(1024)))
+		objects[i].latency = 20
+		if i%5 == 0 {
+			objects[i].latency = 200
+		}
+	}
+	zipf := rand.NewZipf(rng, 1.1, 1, uint64(len(objects)-1))
+	trace := make([]int, 100000)
+	for i := range trace {
+		trace[i] = int(zipf.Uint64())
+	}
+
+	models := []struct {
+		name string
+		cost func(o object) float64
+	}{
+		{"cost=1(偏向小对象)", func(o object) float64 { return 1 }},
+		{"cost=回源延迟", func(o object) float64 { return o.latency }},
+		{"cost=size(等价LRU)", func(o object) float64 { return float64(o.size) }},
+	}
+	for _, model := range models {
+		cache := NewGreedyDualSize[int, []byte](20<<20, nil)
+		var bytes, hitBytes int64
+		var originLatency float64
+		for _, id := range trace {
+			o := objects[id]
+			bytes += o.size
+			if _, ok := cache.Get(id); ok {
+				hitBytes += o.size
+				continue
+			}
+			originLatency += o.latency
+			cache.PutWithCost(id, nil, model.cost(o), o.size) // 示例中只记录大小，不真正保存对象内容
+		}
+		fmt.Printf("%s: 请求命中率 %.1f%%，字节命中率 %.1f%%，总回源延迟 %.1fs\n", model.name,
+			cache.CacheStats().HitRate()*100, float64(hitBytes)/float64(bytes)*100, originLatency/1000)
+	}
+}
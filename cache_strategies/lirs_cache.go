@@ -0,0 +1,458 @@
+package cache_strategies
+
+/*
+LIRS（Low Inter-reference Recency Set）缓存替换算法
+
+原理：
+LRU 只看"距离上次访问过了多久"（recency），一个只访问一次的块和一个每隔一会儿就被访问的块在刚被访问时没有区别，
+因此顺序扫描、循环访问会把热点挤出缓存。LIRS 改用"重用距离"（IRR，同一个块相邻两次访问之间访问过的其他不同块数）判断热度：
+- 重用距离小的块是 LIR（低重用距离）块，常驻缓存，占绝大部分容量
+- 其余是 HIR（高重用距离）块，只有很小一部分容量（默认1%）留给常驻的 HIR 块，淘汰总是从这里发生
+- 一个 HIR 块再次被访问时，如果它的重用距离比最老的 LIR 块的最近访问距离还小，它就升为 LIR，最老的 LIR 块降为 HIR
+
+重用距离不需要真的计算：LIRS 栈 S 按最近访问排序，栈底永远是最老的 LIR 块（通过"栈剪枝"保证），
+HIR 块被访问时如果还在栈 S 中，说明它上次访问以来经过的不同块比栈底的 LIR 块少，即重用距离更小。
+
+关键特点：
+1. 对扫描和循环访问有很强的抵抗力：只访问一次的块永远停留在很小的 HIR 区
+2. 块的三种状态：LIR、常驻 HIR、非常驻 HIR（已被淘汰，只在栈 S 中保留访问历史，不保存值）
+3. 非常驻 HIR 块的数量限制为容量大小，防止栈 S 无限增长
+4. 所有操作都是均摊 O(1)
+
+实现方式：
+- 栈 S：双向链表，包含 LIR 块、常驻 HIR 块和非常驻 HIR 块，头部是最近访问的块
+- 队列 Q：双向链表，包含所有常驻 HIR 块，淘汰从队头开始
+- 非常驻队列：按变成非常驻的先后排列，超出上限时删除最老的历史记录
+- 栈剪枝：栈底的 HIR 块（常驻或非常驻）不可能再升为 LIR，不断从栈底删除直到栈底是 LIR 块
+
+应用场景：
+- 数据库缓冲池、文件系统页缓存（MySQL、PostgreSQL 的研究原型，Linux 的 ClockPro 源自 LIRS）
+- 有大量顺序扫描或循环访问、而 LRU 表现很差的负载
+- 作为淘汰策略对比中抗扫描策略的代表
+
+优缺点：
+- 优点：抗扫描、抗循环，多数负载上命中率明显高于 LRU，不需要调参
+- 缺点：实现比 LRU 复杂，需要额外保存非常驻块的历史；访问模式突变时 LIR 集合的更替较慢
+
+以下实现了 LIRS 缓存，以及循环扫描和热点加扫描两种负载上与其他策略对比的示例。
+*/
+
+import (
+	"container/list"
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/strive/scenario/sizeof"
+)
+
+// DefaultHIRRatio 常驻 HIR 块默认占总容量的比例
+const DefaultHIRRatio = 0.01
+
+// lirsState 块的状态
+type lirsState int
+
+const (
+	lirsLIR         lirsState = iota // LIR 块，常驻
+	lirsHIR                          // 常驻 HIR 块，在队列 Q 中
+	lirsNonResident                  // 非常驻 HIR 块，只在栈 S 中保留历史
+)
+
+// LIRSEntry LIRS缓存节点结构
+type LIRSEntry[K comparable, V any] struct {
+	Key   K
+	Value V
+	state lirsState
+	stack *list.Element // 在栈 S 中的节点，不在栈中时为 nil
+	queue *list.Element // 常驻 HIR 块在队列 Q 中的节点，非常驻块在非常驻队列中的节点
+}
+
+// LIRS 泛型LIRS缓存结构
+type LIRS[K comparable, V any] struct {
+	capacity int                    // 最大容量（常驻块数）
+	ratio    float64                // 常驻 HIR 块占总容量的比例
+	lirCap   int                    // LIR 块的容量
+	lirCount int                    // 当前 LIR 块数
+	stack    *list.List             // 栈 S：头部是最近访问的块，尾部总是 LIR 块
+	queue    *list.List             // 队列 Q：常驻 HIR 块，头部最先淘汰
+	ghosts   *list.List             // 非常驻 HIR 块，头部最早变成非常驻
+	cache    map[K]*LIRSEntry[K, V] // 哈希表：键 -> 节点（包括非常驻块）
+	promoted int                    // HIR 块升为 LIR 的次数
+	stats    StatsCounter           // 访问统计
+	onEvict  EvictionListeners[K, V]
+}
+
+// LIRSNode 字符串键LIRS缓存节点
+type LIRSNode = LIRSEntry[string, interface{}]
+
+// LIRSCache 字符串键、任意值的LIRS缓存
+type LIRSCache = LIRS[string, interface{}]
+
+// NewLIRSCache 创建指定容量和常驻 HIR 比例的LIRS缓存
+func NewLIRSCache(capacity int, hirRatio float64) *LIRSCache {
+	return NewLIRS[string, interface{}](capacity, hirRatio)
+}
+
+// NewLIRS 创建指定容量和常驻 HIR 比例的泛型LIRS缓存，比例不在 (0, 1) 内时使用默认值
+func NewLIRS[K comparable, V any](capacity int, hirRatio float64) *LIRS[K, V] {
+	if hirRatio <= 0 || hirRatio >= 1 {
+		hirRatio = DefaultHIRRatio
+	}
+	c := &LIRS[K, V]{
+		ratio:  hirRatio,
+		stack:  list.New(),
+		queue:  list.New(),
+		ghosts: list.New(),
+		cache:  make(map[K]*LIRSEntry[K, V]),
+	}
+	c.setCapacity(capacity)
+	return c
+}
+
+// setCapacity 设置总容量（至少为1）并重新计算 LIR 块的容量，至少给常驻 HIR 块留一个位置
+func (c *LIRS[K, V]) setCapacity(capacity int) {
+	if capacity < 1 {
+		capacity = 1
+	}
+	c.capacity = capacity
+	hirCap := max(int(math.Ceil(float64(capacity)*c.ratio)), 1)
+	c.lirCap = max(capacity-hirCap, 1)
+}
+
+// resident 返回常驻块的数量
+func (c *LIRS[K, V]) resident() int {
+	return c.lirCount + c.queue.Len()
+}
+
+// Get 获取缓存中的值，不存在返回零值和false；非常驻块视为未命中，不改变状态
+func (c *LIRS[K, V]) Get(key K) (V, bool) {
+	node, exists := c.cache[key]
+	if !exists || node.state == lirsNonResident {
+		c.stats.RecordMiss()
+		var zero V
+		return zero, false
+	}
+	c.stats.RecordHit()
+	c.touch(node)
+	return node.Value, true
+}
+
+// touch 记录一次对常驻块的访问
+func (c *LIRS[K, V]) touch(node *LIRSEntry[K, V]) {
+	if node.state == lirsLIR {
+		// LIR 块移到栈顶，原来在栈底时需要剪枝
+		c.stack.MoveToFront(node.stack)
+		c.prune()
+		return
+	}
+
+	// 常驻 HIR 块：仍在栈中说明重用距离比栈底的 LIR 块小，升为 LIR；
+	// LIR 区因删除或缩容未满时也直接升为 LIR
+	if node.stack != nil || c.lirCount < c.lirCap {
+		if node.stack != nil {
+			c.stack.MoveToFront(node.stack)
+		} else {
+			node.stack = c.stack.PushFront(node)
+		}
+		c.queue.Remove(node.queue)
+		node.queue = nil
+		c.makeLIR(node)
+		return
+	}
+	// 不在栈中：保持 HIR，重新压栈并移到队尾
+	node.stack = c.stack.PushFront(node)
+	c.queue.MoveToBack(node.queue)
+}
+
+// makeLIR 把栈中的块升为 LIR，超出 LIR 容量时把栈底的 LIR 块降为常驻 HIR
+func (c *LIRS[K, V]) makeLIR(node *LIRSEntry[K, V]) {
+	node.state = lirsLIR
+	c.lirCount++
+	c.promoted++
+	for c.lirCount > c.lirCap {
+		c.demoteBottom()
+	}
+}
+
+// demoteBottom 把栈底的 LIR 块降为常驻 HIR 块并放到队尾，然后剪枝
+func (c *LIRS[K, V]) demoteBottom() {
+	bottom := c.stack.Back().Value.(*LIRSEntry[K, V])
+	c.stack.Remove(bottom.stack)
+	bottom.stack = nil
+	bottom.state = lirsHIR
+	bottom.queue = c.queue.PushBack(bottom)
+	c.lirCount--
+	c.prune()
+}
+
+// prune 栈剪枝：删除栈底的 HIR 块，直到栈底是 LIR 块；非常驻块离开栈后不再有用，一并删除
+func (c *LIRS[K, V]) prune() {
+	for back := c.stack.Back(); back != nil; back = c.stack.Back() {
+		node := back.Value.(*LIRSEntry[K, V])
+		if node.state == lirsLIR {
+			return
+		}
+		c.stack.Remove(back)
+		node.stack = nil
+		if node.state == lirsNonResident {
+			c.ghosts.Remove(node.queue)
+			delete(c.cache, node.Key)
+		}
+	}
+}
+
+// Put 插入或更新缓存中的键值对，已存在的键视为一次访问
+func (c *LIRS[K, V]) Put(key K, value V) {
+	node, exists := c.cache[key]
+	if exists && node.state != lirsNonResident {
+		node.Value = value
+		c.touch(node)
+		return
+	}
+
+	var evicted *LIRSEntry[K, V]
+	if c.resident() >= c.capacity {
+		evicted = c.evict()
+	}
+
+	switch {
+	case exists && node.stack != nil:
+		// 非常驻块仍在栈中：重用距离足够小，直接成为 LIR
+		c.ghosts.Remove(node.queue)
+		node.queue = nil
+		node.Value = value
+		c.stack.MoveToFront(node.stack)
+		c.makeLIR(node)
+	case c.lirCount < c.lirCap:
+		// 预热阶段：LIR 区未满时新块直接成为 LIR
+		node = &LIRSEntry[K, V]{Key: key, Value: value, state: lirsLIR}
+		node.stack = c.stack.PushFront(node)
+		c.cache[key] = node
+		c.lirCount++
+	default:
+		node = &LIRSEntry[K, V]{Key: key, Value: value, state: lirsHIR}
+		node.stack = c.stack.PushFront(node)
+		node.queue = c.queue.PushBack(node)
+		c.cache[key] = node
+	}
+
+	// 新节点插入后再通知，回调中可以安全地访问缓存
+	if evicted != nil {
+		c.onEvict.Notify(evicted.Key, evicted.Value, EvictionCapacity)
+	}
+}
+
+// evict 淘汰队头的常驻 HIR 块（队列为空时先把栈底的 LIR 块降级），仍在栈中的块变为非常驻，返回被淘汰条目的副本
+func (c *LIRS[K, V]) evict() *LIRSEntry[K, V] {
+	if c.queue.Len() == 0 {
+		if c.lirCount == 0 {
+			return nil
+		}
+		c.demoteBottom()
+	}
+	node := c.queue.Remove(c.queue.Front()).(*LIRSEntry[K, V])
+	evicted := &LIRSEntry[K, V]{Key: node.Key, Value: node.Value}
+	c.stats.RecordEvictions(1)
+
+	if node.stack == nil {
+		node.queue = nil
+		delete(c.cache, node.Key)
+		return evicted
+	}
+	var zero V
+	node.Value = zero
+	node.state = lirsNonResident
+	node.queue = c.ghosts.PushBack(node)
+	// 非常驻块的数量不超过容量，超出时删除最老的历史
+	for c.ghosts.Len() > c.capacity {
+		ghost := c.ghosts.Remove(c.ghosts.Front()).(*LIRSEntry[K, V])
+		c.stack.Remove(ghost.stack)
+		ghost.stack = nil
+		delete(c.cache, ghost.Key)
+	}
+	return evicted
+}
+
+// Victim 返回下一个将被淘汰的键，缓存为空时返回 false
+func (c *LIRS[K, V]) Victim() (K, bool) {
+	if front := c.queue.Front(); front != nil {
+		return front.Value.(*LIRSEntry[K, V]).Key, true
+	}
+	if back := c.stack.Back(); back != nil {
+		return back.Value.(*LIRSEntry[K, V]).Key, true
+	}
+	var zero K
+	return zero, false
+}
+
+// Contains 判断键是否常驻缓存，不计入访问
+func (c *LIRS[K, V]) Contains(key K) bool {
+	node, exists := c.cache[key]
+	return exists && node.state != lirsNonResident
+}
+
+// Remove 从缓存中删除指定键，同时删除它的访问历史
+func (c *LIRS[K, V]) Remove(key K) bool {
+	node, exists := c.cache[key]
+	if !exists {
+		return false
+	}
+	delete(c.cache, key)
+	if node.stack != nil {
+		c.stack.Remove(node.stack)
+	}
+	switch node.state {
+	case lirsLIR:
+		c.lirCount--
+		c.prune()
+	case lirsHIR:
+		c.queue.Remove(node.queue)
+	case lirsNonResident:
+		c.ghosts.Remove(node.queue)
+		return false
+	}
+	return true
+}
+
+// Size 返回当前常驻缓存的元素数量
+func (c *LIRS[K, V]) Size() int {
+	return c.resident()
+}
+
+// Capacity 返回最大容量
+func (c *LIRS[K, V]) Capacity() int {
+	return c.capacity
+}
+
+// Resize 调整最大容量（至少为1），LIR 区超出新容量的块先降为 HIR，再从队头淘汰，返回淘汰的数量
+func (c *LIRS[K, V]) Resize(capacity int) int {
+	c.setCapacity(capacity)
+	for c.lirCount > c.lirCap {
+		c.demoteBottom()
+	}
+
+	var evicted []*LIRSEntry[K, V]
+	for c.resident() > c.capacity {
+		evicted = append(evicted, c.evict())
+	}
+	for _, entry := range evicted {
+		c.onEvict.Notify(entry.Key, entry.Value, EvictionResize)
+	}
+	return len(evicted)
+}
+
+// OnEvict 注册淘汰回调，条目因容量不足或 Resize 缩容被淘汰时调用；Remove、Clear 不会触发
+func (c *LIRS[K, V]) OnEvict(callback EvictionCallback[K, V]) {
+	c.onEvict.Add(callback)
+}
+
+// Keys 返回所有常驻键（先按栈 S 从新到旧，再列出不在栈中的常驻 HIR 块）
+func (c *LIRS[K, V]) Keys() []K {
+	keys := make([]K, 0, c.resident())
+	for e := c.stack.Front(); e != nil; e = e.Next() {
+		if node := e.Value.(*LIRSEntry[K, V]); node.state != lirsNonResident {
+			keys = append(keys, node.Key)
+		}
+	}
+	for e := c.queue.Front(); e != nil; e = e.Next() {
+		if node := e.Value.(*LIRSEntry[K, V]); node.stack == nil {
+			keys = append(keys, node.Key)
+		}
+	}
+	return keys
+}
+
+// Clear 清空缓存及访问历史
+func (c *LIRS[K, V]) Clear() {
+	c.stack = list.New()
+	c.queue = list.New()
+	c.ghosts = list.New()
+	c.cache = make(map[K]*LIRSEntry[K, V])
+	c.lirCount = 0
+}
+
+// CacheStats 返回命中、未命中、淘汰和过期次数的快照
+func (c *LIRS[K, V]) CacheStats() CacheStats {
+	return c.stats.Snapshot()
+}
+
+// Stats 返回各区的占用情况和访问统计
+func (c *LIRS[K, V]) Stats() map[string]interface{} {
+	stats := c.stats.Snapshot().Map()
+	stats["capacity"] = c.capacity
+	stats["size"] = c.resident()
+	stats["lirSize"] = c.lirCount
+	stats["lirCapacity"] = c.lirCap
+	stats["hirSize"] = c.queue.Len()
+	stats["nonResident"] = c.ghosts.Len()
+	stats["stackSize"] = c.stack.Len()
+	stats["promoted"] = c.promoted
+	return stats
+}
+
+// MemoryUsage 返回深度估算的内存占用（字节）
+func (c *LIRS[K, V]) MemoryUsage() int64 {
+	return sizeof.Of(c)
+}
+
+// 场景示例：报表任务循环扫描数据分片，以及热点访问中夹杂批量扫描
+func LIRSCacheDemo() {
+	type statsCache interface {
+		Cache[int, string]
+		CacheStats() CacheStats
+	}
+	type policy struct {
+		name  string
+		cache statsCache
+	}
+	replay := func(trace []int, policies ...policy) {
+		for _, p := range policies {
+			for _, key := range trace {
+				if _, ok := p.cache.Get(key); !ok {
+					p.cache.Put(key, fmt.Sprintf("数据%d", key))
+				}
+			}
+			fmt.Printf("  %-6s 命中率 %5.1f%%\n", p.name, p.cache.CacheStats().HitRate()*100)
+		}
+	}
+
+	// 循环读取的分片比缓存多一个：FIFO 和 LRU 每次都未命中，LIRS 把大部分分片固定为 LIR
+	fmt.Println("LIRS缓存示例 (循环读取101个分片50轮，缓存容量=100):")
+	loop := make([]int, 0, 101*50)
+	for round := 0; round < 50; round++ {
+		for shard := 0; shard < 101; shard++ {
+			loop = append(loop, shard)
+		}
+	}
+	lirs := NewLIRS[int, string](100, DefaultHIRRatio)
+	replay(loop,
+		policy{"FIFO", NewFIFO[int, string](100)},
+		policy{"SLRU", NewSLRU[int, string](100, DefaultProtectedRatio)},
+		policy{"LIRS", lirs},
+	)
+	stats := lirs.Stats()
+	fmt.Printf("  LIRS: LIR块 %d/%d，常驻HIR块 %d，非常驻历史 %d\n",
+		stats["lirSize"], stats["lirCapacity"], stats["hirSize"], stats["nonResident"])
+
+	// 热点访问中每隔一段时间插入一次全量扫描
+	fmt.Println("\n热点加扫描 (80%的访问集中在50个键，每2000次访问插入一次500个冷键的扫描，缓存容量=100):")
+	rng := rand.New(rand.NewSource(7))
+	var mixed []int
+	for i := 0; i < 20000; i++ {
+		if i%2000 == 1999 {
+			for key := 10000; key < 10500; key++ {
+				mixed = append(mixed, key)
+			}
+		}
+		if rng.Float64() < 0.8 {
+			mixed = append(mixed, rng.Intn(50))
+		} else {
+			mixed = append(mixed, 50+rng.Intn(5000))
+		}
+	}
+	replay(mixed,
+		policy{"FIFO", NewFIFO[int, string](100)},
+		policy{"SLRU", NewSLRU[int, string](100, DefaultProtectedRatio)},
+		policy{"LIRS", NewLIRS[int, string](100, DefaultHIRRatio)},
+	)
+}
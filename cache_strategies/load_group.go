@@ -0,0 +1,138 @@
+package cache_strategies
+
+/*
+GetOrLoad 与合并加载（防止缓存击穿）
+
+原理：
+热点键过期或被淘汰的瞬间，大量并发请求同时未命中，如果每个请求都去查数据库再回填，
+数据库会在同一时刻收到成百上千个相同的查询，这就是缓存击穿（cache stampede）。
+合并加载让同一个键同一时刻只有一个协程执行加载，其余协程等待并共享它的结果，
+数据库只收到一次查询。
+
+关键特点：
+1. 按键合并：不同键的加载互不影响，可以并行执行
+2. 结果共享：加载成功时所有等待者拿到同一个值，失败时拿到同一个错误
+3. 加载失败不回填缓存，下一批请求会重新加载
+4. 加载函数在缓存的锁之外执行，慢加载不会阻塞其他键的读写
+5. 加载函数 panic 时，执行加载的协程和所有等待者都收到同一个 panic，不会有等待者永远阻塞
+
+实现方式：
+- LoadGroup 基于 concurrency.SingleFlight：第一个到达的协程执行加载函数，后到的协程等待同一次调用完成
+- 加载完成（包括 panic）后从表中删除，之后的未命中会发起新的加载
+- GetOrLoad 先读缓存，未命中时通过 LoadGroup 加载，成功后回填缓存
+- 提供 GetOrLoad 的是并发安全的缓存：TTL 缓存和主包中的 SyncCache、ShardedCache；
+  LRU、LFU、FIFO 本身不是并发安全的，需要先用 SyncCache 包装
+
+应用场景：
+- 热门商品、首页等热点数据过期时的回源
+- 服务刚启动、缓存为空时的大量并发请求
+- 任何回源代价高、并发读同一个键的场景
+
+优缺点：
+- 优点：把 N 次并发回源降为 1 次，调用方只需把加载逻辑传给 GetOrLoad
+- 缺点：等待者的延迟取决于那一次加载，加载卡住时所有等待者一起卡住；
+  只合并同一时刻的加载，加载完成后才到达的请求不受影响（通常已经能命中缓存）
+
+以下实现了按键合并的加载组，以及热点网页过期时并发回源的对比示例。
+*/
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/strive/scenario/concurrency"
+)
+
+// LoadGroup 按键合并并发加载，零值可直接使用
+type LoadGroup[K comparable, V any] struct {
+	flight concurrency.SingleFlight[K, V]
+}
+
+// Do 执行 key 的加载函数；同一个键已有加载在进行时等待它的结果，shared 为 true 表示结果被多个协程共享。
+// 加载函数 panic 时所有调用方都以同一个 *concurrency.PanicError 重新 panic
+func (g *LoadGroup[K, V]) Do(key K, load func() (V, error)) (value V, err error, shared bool) {
+	return g.flight.Do(key, load)
+}
+
+// Forget 忘记 key 正在进行的加载，之后的未命中发起新的加载而不是等待它
+func (g *LoadGroup[K, V]) Forget(key K) {
+	g.flight.Forget(key)
+}
+
+// 场景示例：热点网页过期的瞬间，100个并发请求同时回源
+func GetOrLoadDemo() {
+	fmt.Println("GetOrLoad 合并加载示例 (100个并发请求读取同一个刚过期的热点网页):")
+
+	var queries int64
+	// renderPage 模拟查询数据库并渲染页面，耗时20ms
+	renderPage := func(url string) (string, error) {
+		atomic.AddInt64(&queries, 1)
+		time.Sleep(20 * time.Millisecond)
+		return "<html>" + url + "</html>", nil
+	}
+
+	const requests = 100
+	run := func(name string, get func(cache *TTL[string, string], url string) (string, error)) {
+		cache := NewTTL[string, string](TTLCacheOptions{DefaultTTL: time.Minute})
+		defer cache.StopCleanup()
+		atomic.StoreInt64(&queries, 0)
+
+		var wg sync.WaitGroup
+		start := time.Now()
+		for i := 0; i < requests; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := get(cache, "/index.html"); err != nil {
+					fmt.Printf("请求失败: %v\n", err)
+				}
+			}()
+		}
+		wg.Wait()
+		fmt.Printf("  %-12s 数据库查询 %3d 次，耗时 %v\n", name, atomic.LoadInt64(&queries), time.Since(start).Round(time.Millisecond))
+	}
+
+	// 先查缓存，未命中再回源并回填：每个未命中的请求都会查一次数据库
+	run("Get + Put", func(cache *TTL[string, string], url string) (string, error) {
+		if page, ok := cache.Get(url); ok {
+			return page, nil
+		}
+		page, err := renderPage(url)
+		if err == nil {
+			cache.Put(url, page)
+		}
+		return page, err
+	})
+
+	// GetOrLoad：同一时刻只有一个请求回源
+	run("GetOrLoad", func(cache *TTL[string, string], url string) (string, error) {
+		return cache.GetOrLoad(url, func() (string, error) { return renderPage(url) })
+	})
+
+	// 加载失败时所有等待者收到同一个错误，缓存不回填，之后的请求会重新加载
+	cache := NewTTL[string, string](TTLCacheOptions{DefaultTTL: time.Minute})
+	defer cache.StopCleanup()
+	var attempts, failed int64
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := cache.GetOrLoad("/report.html", func() (string, error) {
+				atomic.AddInt64(&attempts, 1)
+				time.Sleep(10 * time.Millisecond)
+				return "", errors.New("报表数据库超时")
+			})
+			if err != nil {
+				atomic.AddInt64(&failed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	page, err := cache.GetOrLoad("/report.html", func() (string, error) { return "<html>报表</html>", nil })
+	fmt.Printf("\n加载失败: 10个并发请求只加载 %d 次，%d 个请求收到错误；数据库恢复后重新加载得到 %q (错误: %v)\n",
+		attempts, failed, page, err)
+}
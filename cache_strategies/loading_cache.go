@@ -0,0 +1,379 @@
+package cache_strategies
+
+/*
+读穿/写穿缓存装饰器（LoadingCache）
+
+原理：
+业务代码直接操作缓存时，每个调用点都要写一遍"查缓存 -> 未命中查数据库 -> 回填缓存"，
+过期时间、并发回源、数据源故障的处理也散落在各处。LoadingCache 把这些逻辑收进一个装饰器：
+- 读穿（read-through）：未命中时调用用户提供的 Loader 加载并回填，同一个键的并发加载只执行一次
+- 写穿（write-through）：Put 先写数据源，成功后再更新缓存，缓存与数据源保持一致
+- 提前刷新（refresh-ahead）：条目在过期前的一段时间内被访问时，后台异步重新加载，
+  请求仍然拿到旧值，热点数据几乎不会因为过期而阻塞在回源上
+- TTL 抖动：每个条目的 TTL 在基准值上随机浮动，避免同一时刻加载的大量条目同时过期造成回源风暴
+- 过期兜底（stale-if-error）：条目过期后重新加载失败时，在宽限期内继续返回旧值，数据源故障不会直接传导给用户
+
+关键特点：
+1. 装饰任意实现 Cache 接口的缓存，淘汰策略由被装饰的缓存决定
+2. 并发安全，同一个键同时只有一个加载或刷新在进行
+3. 统计命中、加载、刷新成功/失败、兜底次数，便于观察数据源健康状况
+
+实现方式：
+- 被装饰的缓存保存 Loaded 条目，记录值以及刷新时间、过期时间
+- 正在进行的加载保存在 inflight 表中，后到的请求等待同一次加载的结果
+- 后台刷新用协程执行，Close 等待所有刷新结束
+
+应用场景：
+- 商品详情、用户资料等读多写少、回源代价高的数据
+- 配置中心客户端：定期刷新，配置服务故障时继续使用旧配置
+- 下游服务偶尔抖动，希望缓存能顶住的场景
+
+优缺点：
+- 优点：调用方只需要 Get/Put，过期、并发回源和故障兜底由装饰器统一处理
+- 缺点：提前刷新会对冷门但恰好在刷新窗口内被访问的键产生额外回源；兜底期间返回的是旧数据
+
+以下实现了带提前刷新、TTL 抖动和过期兜底的读穿/写穿缓存。
+*/
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Loader 缓存未命中或需要刷新时从数据源加载值
+type Loader[K comparable, V any] func(key K) (V, error)
+
+// Writer 写穿时把值写入数据源
+type Writer[K comparable, V any] func(key K, value V) error
+
+// Loaded 被装饰的缓存中保存的条目
+type Loaded[V any] struct {
+	Value     V
+	LoadedAt  time.Time // 加载时间
+	RefreshAt time.Time // 此后被访问时触发后台刷新
+	ExpireAt  time.Time // 过期时间
+}
+
+// LoadingCacheOptions 读穿缓存选项
+type LoadingCacheOptions struct {
+	TTL          time.Duration // 基准过期时间
+	Jitter       float64       // TTL 随机浮动的比例，0.1 表示 ±10%
+	RefreshAhead float64       // 经过 TTL 的这一比例后访问即触发后台刷新，0 表示不提前刷新
+	StaleIfError time.Duration // 过期后加载失败时，继续返回旧值的宽限期
+}
+
+// DefaultLoadingCacheOptions 默认的读穿缓存选项
+var DefaultLoadingCacheOptions = LoadingCacheOptions{
+	TTL:          time.Minute,
+	Jitter:       0.1,
+	RefreshAhead: 0.8,
+	StaleIfError: 5 * time.Minute,
+}
+
+// ErrCacheClosed 缓存已关闭
+var ErrCacheClosed = errors.New("缓存已关闭")
+
+// loadCall 一次正在进行的加载
+type loadCall[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// LoadingCache 读穿/写穿缓存装饰器，可被多个协程并发使用
+type LoadingCache[K comparable, V any] struct {
+	mu       sync.Mutex
+	store    Cache[K, *Loaded[V]]
+	loader   Loader[K, V]
+	writer   Writer[K, V]
+	options  LoadingCacheOptions
+	rng      *rand.Rand
+	inflight map[K]*loadCall[V] // 正在进行的加载或刷新
+	clock    func() time.Time
+	closed   bool
+	wg       sync.WaitGroup // 后台刷新协程
+
+	stats           StatsCounter // 命中（包括触发刷新的命中）、未命中或已过期、过期次数
+	loads           int64        // 同步加载成功
+	loadFailures    int64        // 同步加载失败
+	refreshes       int64        // 后台刷新成功
+	refreshFailures int64        // 后台刷新失败
+	staleServed     int64        // 加载失败后返回旧值的次数
+	writes          int64        // 写穿成功
+	writeFailures   int64        // 写穿失败
+}
+
+// NewLoadingCache 用 loader 装饰 store，未设置的选项使用默认值
+func NewLoadingCache[K comparable, V any](store Cache[K, *Loaded[V]], loader Loader[K, V], options LoadingCacheOptions) *LoadingCache[K, V] {
+	if options.TTL <= 0 {
+		options.TTL = DefaultLoadingCacheOptions.TTL
+	}
+	if options.Jitter < 0 || options.Jitter >= 1 {
+		options.Jitter = DefaultLoadingCacheOptions.Jitter
+	}
+	if options.RefreshAhead < 0 || options.RefreshAhead >= 1 {
+		options.RefreshAhead = DefaultLoadingCacheOptions.RefreshAhead
+	}
+	if options.StaleIfError < 0 {
+		options.StaleIfError = 0
+	}
+	return &LoadingCache[K, V]{
+		store:    store,
+		loader:   loader,
+		options:  options,
+		rng:      rand.New(rand.NewSource(time.Now().UnixNano())),
+		inflight: make(map[K]*loadCall[V]),
+		clock:    time.Now,
+	}
+}
+
+// WithWriter 设置写穿的数据源写入函数
+func (c *LoadingCache[K, V]) WithWriter(writer Writer[K, V]) *LoadingCache[K, V] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writer = writer
+	return c
+}
+
+// newEntry 创建条目，TTL 加上随机抖动，调用方需持有锁
+func (c *LoadingCache[K, V]) newEntry(value V) *Loaded[V] {
+	now := c.clock()
+	ttl := c.options.TTL
+	if c.options.Jitter > 0 {
+		ttl = time.Duration(float64(ttl) * (1 + c.options.Jitter*(2*c.rng.Float64()-1)))
+	}
+	entry := &Loaded[V]{Value: value, LoadedAt: now, ExpireAt: now.Add(ttl), RefreshAt: now.Add(ttl)}
+	if c.options.RefreshAhead > 0 {
+		entry.RefreshAt = now.Add(time.Duration(float64(ttl) * c.options.RefreshAhead))
+	}
+	return entry
+}
+
+// Get 读取键值：命中直接返回，临近过期时触发后台刷新，未命中或过期时同步加载
+func (c *LoadingCache[K, V]) Get(key K) (V, error) {
+	var zero V
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return zero, ErrCacheClosed
+	}
+	now := c.clock()
+	entry, ok := c.store.Get(key)
+	if ok && now.Before(entry.ExpireAt) {
+		c.stats.RecordHit()
+		if !now.Before(entry.RefreshAt) {
+			c.startLoad(key, true)
+		}
+		c.mu.Unlock()
+		return entry.Value, nil
+	}
+	c.stats.RecordMiss()
+	if ok {
+		c.stats.RecordExpirations(1)
+	}
+	call := c.startLoad(key, false)
+	c.mu.Unlock()
+
+	<-call.done
+	if call.err == nil {
+		return call.value, nil
+	}
+
+	// 加载失败：在宽限期内返回旧值
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ok && c.options.StaleIfError > 0 && now.Before(entry.ExpireAt.Add(c.options.StaleIfError)) {
+		c.staleServed++
+		return entry.Value, nil
+	}
+	return zero, call.err
+}
+
+// startLoad 开始加载 key，已有加载在进行时返回它；background 为 true 时不等待结果。调用方需持有锁
+func (c *LoadingCache[K, V]) startLoad(key K, background bool) *loadCall[V] {
+	if call, ok := c.inflight[key]; ok {
+		return call
+	}
+	call := &loadCall[V]{done: make(chan struct{})}
+	c.inflight[key] = call
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		value, err := c.loader(key)
+
+		c.mu.Lock()
+		call.value, call.err = value, err
+		delete(c.inflight, key)
+		switch {
+		case err != nil && background:
+			c.refreshFailures++ // 保留旧条目，过期后由同步加载或兜底处理
+		case err != nil:
+			c.loadFailures++
+		default:
+			if background {
+				c.refreshes++
+			} else {
+				c.loads++
+			}
+			if !c.closed {
+				c.store.Put(key, c.newEntry(value))
+			}
+		}
+		c.mu.Unlock()
+		close(call.done)
+	}()
+	return call
+}
+
+// Put 写穿：先写数据源，成功后更新缓存；没有设置 Writer 时只更新缓存
+func (c *LoadingCache[K, V]) Put(key K, value V) error {
+	c.mu.Lock()
+	writer := c.writer
+	c.mu.Unlock()
+
+	if writer != nil {
+		if err := writer(key, value); err != nil {
+			c.mu.Lock()
+			c.writeFailures++
+			c.mu.Unlock()
+			return fmt.Errorf("写入数据源失败: %w", err)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return ErrCacheClosed
+	}
+	if writer != nil {
+		c.writes++
+	}
+	c.store.Put(key, c.newEntry(value))
+	return nil
+}
+
+// Invalidate 删除缓存中的键，下次读取时重新加载
+func (c *LoadingCache[K, V]) Invalidate(key K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.store.Remove(key)
+}
+
+// Size 返回缓存的条目数量
+func (c *LoadingCache[K, V]) Size() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.store.Size()
+}
+
+// Close 停止接受请求并等待所有后台加载结束
+func (c *LoadingCache[K, V]) Close() {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+	c.wg.Wait()
+}
+
+// CacheStats 返回命中、未命中和过期次数的快照；淘汰发生在被装饰的缓存中，由它自己统计
+func (c *LoadingCache[K, V]) CacheStats() CacheStats {
+	return c.stats.Snapshot()
+}
+
+// Stats 返回统计信息
+func (c *LoadingCache[K, V]) Stats() map[string]interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats.Snapshot().Map()
+	stats["size"] = c.store.Size()
+	stats["loads"] = c.loads
+	stats["loadFailures"] = c.loadFailures
+	stats["refreshes"] = c.refreshes
+	stats["refreshFailures"] = c.refreshFailures
+	stats["staleServed"] = c.staleServed
+	stats["writes"] = c.writes
+	stats["writeFailures"] = c.writeFailures
+	return stats
+}
+
+// 场景示例：商品详情缓存，数据库偶尔故障时继续提供旧数据
+func LoadingCacheDemo() {
+	fmt.Println("读穿/写穿缓存示例 - 商品价格:")
+
+	// 模拟数据库：每次查询耗时 20ms，down 为 true 时查询失败
+	var dbMu sync.Mutex
+	db := map[string]int{"手机": 3999, "耳机": 899, "手表": 1599}
+	queries, down := 0, false
+	loader := func(key string) (int, error) {
+		time.Sleep(20 * time.Millisecond)
+		dbMu.Lock()
+		defer dbMu.Unlock()
+		queries++
+		if down {
+			return 0, errors.New("数据库连接超时")
+		}
+		price, ok := db[key]
+		if !ok {
+			return 0, fmt.Errorf("商品不存在: %s", key)
+		}
+		return price, nil
+	}
+	writer := func(key string, price int) error {
+		dbMu.Lock()
+		defer dbMu.Unlock()
+		db[key] = price
+		return nil
+	}
+
+	cache := NewLoadingCache(NewLRUK[string, *Loaded[int]](100, DefaultK), loader, LoadingCacheOptions{
+		TTL:          200 * time.Millisecond,
+		Jitter:       0.1,
+		RefreshAhead: 0.5,
+		StaleIfError: time.Second,
+	}).WithWriter(writer)
+	defer cache.Close()
+
+	// 1. 100 个并发请求同时读取未缓存的商品，只回源一次
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cache.Get("手机")
+		}()
+	}
+	wg.Wait()
+	fmt.Printf("1. 100 个并发请求读取\"手机\"，数据库查询 %d 次\n", queries)
+
+	// 2. 进入刷新窗口后访问：立即返回旧值，后台刷新
+	time.Sleep(120 * time.Millisecond)
+	start := time.Now()
+	price, _ := cache.Get("手机")
+	fmt.Printf("2. 临近过期时读取: %d 元，耗时 %v（不等待回源），后台刷新中\n", price, time.Since(start).Round(time.Millisecond))
+	time.Sleep(50 * time.Millisecond)
+
+	// 3. 写穿：先写数据库再更新缓存
+	cache.Put("手机", 3799)
+	price, _ = cache.Get("手机")
+	dbMu.Lock()
+	fmt.Printf("3. 降价写穿后读取: %d 元，数据库中为 %d 元\n", price, db["手机"])
+	down = true
+	dbMu.Unlock()
+
+	// 4. 数据库故障且条目已过期：宽限期内返回旧值
+	time.Sleep(250 * time.Millisecond)
+	price, err := cache.Get("手机")
+	fmt.Printf("4. 数据库故障、条目已过期时读取: %d 元, err=%v（过期兜底）\n", price, err)
+	if _, err := cache.Get("耳机"); err != nil {
+		fmt.Printf("   从未缓存过的\"耳机\": %v\n", err)
+	}
+
+	stats := cache.Stats()
+	fmt.Printf("\n命中率 %.0f%%，同步加载 %d 次（失败 %d），后台刷新 %d 次（失败 %d），兜底返回旧值 %d 次\n",
+		stats["hitRate"].(float64)*100, stats["loads"], stats["loadFailures"],
+		stats["refreshes"], stats["refreshFailures"], stats["staleServed"])
+}
@@ -12,11 +12,14 @@ LRU-K是LRU算法的一个变种，它考虑数据的前K次访问，而不仅
 2. 对于访问次数少于K次的数据，使用特殊处理（通常在初始阶段更容易被淘汰）
 3. 当缓存满时，淘汰K距离最大的数据
 4. K值越大，算法对访问频率的敏感度越高，越有利于识别热点数据
+5. 关联访问周期（Correlated Reference Period）：一次请求内对同一数据的连续访问（如同一个事务多次读取同一行）
+   并不说明数据是热点，距上一次访问不超过该周期的访问合并为一次，只刷新最近访问时间
 
 实现方式：
 - 使用哈希表存储数据及其访问历史
 - 使用两个队列：一个用于存储访问次数小于K次的数据，另一个用于存储访问次数达到K次的数据
 - 对于两个队列的淘汰策略略有不同
+- 关联访问不增加访问次数、不把数据移入缓存队列，只把最近一次访问时间更新为当前时间
 
 应用场景：
 - 数据库缓存
@@ -27,57 +30,93 @@ LRU-K是LRU算法的一个变种，它考虑数据的前K次访问，而不仅
 - 优点：更好地识别长期热点数据，对突发访问不敏感
 - 缺点：实现复杂，需要维护更多的历史信息，内存开销较大
 
-以下实现一个LRU-2缓存（K=2），记录每个数据的前两次访问时间。
+以下实现一个LRU-K缓存，K值和关联访问周期可通过 LRUKOptions 配置，示例使用LRU-2（K=2）。
 */
 
 import (
 	"container/list"
 	"fmt"
 	"time"
+
+	"github.com/strive/scenario/sizeof"
 )
 
 // LRUK参数常量
 const (
 	DefaultK             = 2              // 默认K值
 	InfiniteDistance     = int64(1 << 60) // 无限大的距离值（用于未满K次访问的数据）
-	CorrelationThreshold = 100            // 历史关联阈值（毫秒）
+	CorrelationThreshold = 100            // 历史关联阈值（毫秒），DefaultLRUKOptions 的关联访问周期
 )
 
-// LRUKNode LRU-K缓存节点结构
-type LRUKNode struct {
-	Key          string      // 键
-	Value        interface{} // 值
-	HistoryTimes []int64     // 历史访问时间戳（最多K个）
-	AccessCount  int         // 历史访问次数
+// LRUKOptions LRU-K缓存配置选项
+type LRUKOptions struct {
+	K                 int           // 计算K距离使用的访问次数，小于1时使用 DefaultK
+	CorrelationPeriod time.Duration // 关联访问周期，距上一次访问不超过该时长的访问视为同一次访问，0表示不合并
+}
+
+// DefaultLRUKOptions 默认的LRU-K缓存配置
+var DefaultLRUKOptions = LRUKOptions{
+	K:                 DefaultK,
+	CorrelationPeriod: CorrelationThreshold * time.Millisecond,
+}
+
+// LRUKEntry LRU-K缓存节点结构
+type LRUKEntry[K comparable, V any] struct {
+	Key          K       // 键
+	Value        V       // 值
+	HistoryTimes []int64 // 历史访问时间戳（最多K个）
+	AccessCount  int     // 历史访问次数
 }
 
-// LRUKCache LRU-K缓存结构
-type LRUKCache struct {
-	capacity int                      // 最大容量
-	k        int                      // K值
-	cache    map[string]*list.Element // 哈希表: 键 -> 链表节点
-	history  *list.List               // 历史队列: 访问次数 < K 的节点
-	cache2q  *list.List               // 缓存队列: 访问次数 >= K 的节点
-	clock    func() int64             // 时钟函数，用于模拟或获取时间
+// LRUK 泛型LRU-K缓存结构
+type LRUK[K comparable, V any] struct {
+	capacity int                 // 最大容量
+	k        int                 // K值
+	cache    map[K]*list.Element // 哈希表: 键 -> 链表节点
+	history  *list.List          // 历史队列: 访问次数 < K 的节点
+	cache2q  *list.List          // 缓存队列: 访问次数 >= K 的节点
+	clock    func() int64        // 时钟函数，用于模拟或获取时间
+	stats    StatsCounter        // 访问统计
+
+	correlationPeriod int64 // 关联访问周期（毫秒），0表示不合并
+	correlated        int   // 被合并的关联访问次数
 }
 
+// LRUKNode 字符串键LRU-K缓存节点（兼容旧接口）
+type LRUKNode = LRUKEntry[string, interface{}]
+
+// LRUKCache 字符串键、任意值的LRU-K缓存（兼容旧接口）
+type LRUKCache = LRUK[string, interface{}]
+
 // NewLRUKCache 创建指定容量和K值的LRU-K缓存
 func NewLRUKCache(capacity int, k int) *LRUKCache {
+	return NewLRUK[string, interface{}](capacity, k)
+}
+
+// NewLRUK 创建指定容量和K值的泛型LRU-K缓存，不合并关联访问，适合回放没有真实时间间隔的访问轨迹
+func NewLRUK[K comparable, V any](capacity int, k int) *LRUK[K, V] {
+	return NewLRUKWithOptions[K, V](capacity, LRUKOptions{K: k})
+}
+
+// NewLRUKWithOptions 按选项创建泛型LRU-K缓存
+func NewLRUKWithOptions[K comparable, V any](capacity int, options LRUKOptions) *LRUK[K, V] {
+	k := options.K
 	if k <= 0 {
 		k = DefaultK
 	}
-	return &LRUKCache{
-		capacity: capacity,
-		k:        k,
-		cache:    make(map[string]*list.Element),
-		history:  list.New(),
-		cache2q:  list.New(),
-		clock:    func() int64 { return time.Now().UnixNano() / int64(time.Millisecond) },
+	return &LRUK[K, V]{
+		capacity:          capacity,
+		k:                 k,
+		cache:             make(map[K]*list.Element),
+		history:           list.New(),
+		cache2q:           list.New(),
+		clock:             func() int64 { return time.Now().UnixNano() / int64(time.Millisecond) },
+		correlationPeriod: max(options.CorrelationPeriod.Milliseconds(), 0),
 	}
 }
 
 // 获取节点的K距离（第K次最近访问的时间）
-func (c *LRUKCache) kDistance(node *LRUKNode) int64 {
+func (c *LRUK[K, V]) kDistance(node *LRUKEntry[K, V]) int64 {
 	if node.AccessCount < c.k {
 		return InfiniteDistance // 未满K次访问，返回无限大的距离
 	}
@@ -86,20 +125,34 @@ func (c *LRUKCache) kDistance(node *LRUKNode) int64 {
 	return c.clock() - node.HistoryTimes[c.k-1]
 }
 
-// Get 获取缓存中的值，不存在返回nil和false
-func (c *LRUKCache) Get(key string) (interface{}, bool) {
+// Get 获取缓存中的值，不存在返回零值和false
+func (c *LRUK[K, V]) Get(key K) (V, bool) {
 	if element, exists := c.cache[key]; exists {
-		node := element.Value.(*LRUKNode)
+		node := element.Value.(*LRUKEntry[K, V])
 		c.recordAccess(node, element)
+		c.stats.RecordHit()
 		return node.Value, true
 	}
-	return nil, false
+	c.stats.RecordMiss()
+	var zero V
+	return zero, false
 }
 
 // recordAccess 记录节点的访问
-func (c *LRUKCache) recordAccess(node *LRUKNode, element *list.Element) {
+func (c *LRUK[K, V]) recordAccess(node *LRUKEntry[K, V], element *list.Element) {
 	// 记录新的访问时间
 	now := c.clock()
+	inHistory := node.AccessCount < c.k // 本次访问前是否还在历史队列
+
+	// 关联访问：与上一次访问间隔不超过关联周期，视为同一次访问，只刷新最近访问时间
+	if c.correlationPeriod > 0 && now-node.HistoryTimes[0] <= c.correlationPeriod {
+		node.HistoryTimes[0] = now
+		c.correlated++
+		if !inHistory {
+			c.cache2q.MoveToFront(element)
+		}
+		return
+	}
 
 	// 更新访问历史
 	if node.AccessCount < c.k {
@@ -112,21 +165,21 @@ func (c *LRUKCache) recordAccess(node *LRUKNode, element *list.Element) {
 	}
 
 	// 如果节点在历史队列中且已达到K次访问，将其移至缓存队列
-	if element.Value == node && node.AccessCount == c.k && element.Value == node {
+	if inHistory && node.AccessCount == c.k {
 		c.history.Remove(element)
 		newElement := c.cache2q.PushFront(node)
 		c.cache[node.Key] = newElement
-	} else if element.Value == node && node.AccessCount >= c.k {
+	} else if !inHistory {
 		// 已经在缓存队列中，移至队列前端
 		c.cache2q.MoveToFront(element)
 	}
 }
 
 // Put 插入或更新缓存中的键值对
-func (c *LRUKCache) Put(key string, value interface{}) {
+func (c *LRUK[K, V]) Put(key K, value V) {
 	// 如果键已存在，更新值并记录访问
 	if element, exists := c.cache[key]; exists {
-		node := element.Value.(*LRUKNode)
+		node := element.Value.(*LRUKEntry[K, V])
 		node.Value = value
 		c.recordAccess(node, element)
 		return
@@ -138,7 +191,7 @@ func (c *LRUKCache) Put(key string, value interface{}) {
 	}
 
 	// 创建新节点
-	node := &LRUKNode{
+	node := &LRUKEntry[K, V]{
 		Key:          key,
 		Value:        value,
 		HistoryTimes: []int64{c.clock()},
@@ -151,12 +204,13 @@ func (c *LRUKCache) Put(key string, value interface{}) {
 }
 
 // 淘汰策略
-func (c *LRUKCache) evict() {
+func (c *LRUK[K, V]) evict() {
 	// 优先从历史队列中淘汰
 	if c.history.Len() > 0 {
 		oldest := c.history.Back()
 		c.history.Remove(oldest)
-		delete(c.cache, oldest.Value.(*LRUKNode).Key)
+		delete(c.cache, oldest.Value.(*LRUKEntry[K, V]).Key)
+		c.stats.RecordEvictions(1)
 		return
 	}
 
@@ -167,7 +221,7 @@ func (c *LRUKCache) evict() {
 
 		// 遍历查找K距离最大的节点
 		for e := c.cache2q.Back(); e != nil; e = e.Prev() {
-			node := e.Value.(*LRUKNode)
+			node := e.Value.(*LRUKEntry[K, V])
 			distance := c.kDistance(node)
 			if distance > maxDistance {
 				maxDistance = distance
@@ -177,15 +231,16 @@ func (c *LRUKCache) evict() {
 
 		if toRemove != nil {
 			c.cache2q.Remove(toRemove)
-			delete(c.cache, toRemove.Value.(*LRUKNode).Key)
+			delete(c.cache, toRemove.Value.(*LRUKEntry[K, V]).Key)
+			c.stats.RecordEvictions(1)
 		}
 	}
 }
 
 // Remove 从缓存中删除指定键
-func (c *LRUKCache) Remove(key string) bool {
+func (c *LRUK[K, V]) Remove(key K) bool {
 	if element, exists := c.cache[key]; exists {
-		node := element.Value.(*LRUKNode)
+		node := element.Value.(*LRUKEntry[K, V])
 		if node.AccessCount < c.k {
 			c.history.Remove(element)
 		} else {
@@ -198,10 +253,71 @@ func (c *LRUKCache) Remove(key string) bool {
 }
 
 // Size 返回当前缓存中的元素数量
-func (c *LRUKCache) Size() int {
+func (c *LRUK[K, V]) Size() int {
 	return len(c.cache)
 }
 
+// Capacity 返回最大容量
+func (c *LRUK[K, V]) Capacity() int {
+	return c.capacity
+}
+
+// Resize 调整最大容量（至少为1），容量变小时按LRU-K策略淘汰多出的元素，返回淘汰的数量
+func (c *LRUK[K, V]) Resize(capacity int) int {
+	if capacity < 1 {
+		capacity = 1
+	}
+	c.capacity = capacity
+
+	evicted := 0
+	for len(c.cache) > capacity {
+		c.evict()
+		evicted++
+	}
+	return evicted
+}
+
+// Keys 返回缓存中所有键的列表（先缓存队列后历史队列，各自按最近访问排序）
+func (c *LRUK[K, V]) Keys() []K {
+	keys := make([]K, 0, len(c.cache))
+	for _, queue := range []*list.List{c.cache2q, c.history} {
+		for e := queue.Front(); e != nil; e = e.Next() {
+			keys = append(keys, e.Value.(*LRUKEntry[K, V]).Key)
+		}
+	}
+	return keys
+}
+
+// Clear 清空缓存
+func (c *LRUK[K, V]) Clear() {
+	c.cache = make(map[K]*list.Element)
+	c.history = list.New()
+	c.cache2q = list.New()
+}
+
+// CacheStats 返回命中、未命中、淘汰和过期次数的快照
+func (c *LRUK[K, V]) CacheStats() CacheStats {
+	return c.stats.Snapshot()
+}
+
+// Stats 返回访问统计和容量信息
+func (c *LRUK[K, V]) Stats() map[string]interface{} {
+	stats := c.stats.Snapshot().Map()
+	stats["capacity"] = c.capacity
+	stats["size"] = len(c.cache)
+	stats["k"] = c.k
+	if c.correlationPeriod > 0 {
+		stats["correlationPeriodMs"] = c.correlationPeriod
+		stats["correlatedAccesses"] = c.correlated
+	}
+	return stats
+}
+
+// MemoryUsage 返回深度估算的内存占用（字节）
+func (c *LRUK[K, V]) MemoryUsage() int64 {
+	return sizeof.Of(c)
+}
+
 // 场景示例：数据库查询缓存
 func LRUKCacheDemo() {
 	// 创建容量为4的LRU-2缓存
@@ -253,6 +369,26 @@ func LRUKCacheDemo() {
 
 	fmt.Println("\n=== 添加新查询后(根据K距离淘汰) ===")
 	printLRUKStatus(cache)
+
+	// 关联访问：同一个报表请求在10ms内把汇总查询执行了3次，这只是一次请求，不说明它是热点；
+	// 用户资料查询在两个不同的请求中各执行一次，才是真正的重复访问
+	fmt.Printf("\n=== 关联访问周期 (%dms) ===\n", CorrelationThreshold)
+	correlated := NewLRUKWithOptions[string, interface{}](4, DefaultLRUKOptions)
+	now := int64(0)
+	correlated.clock = func() int64 { return now }
+	access := func(at int64, key string) {
+		now = at
+		if _, ok := correlated.Get(key); !ok {
+			correlated.Put(key, key+" 的结果")
+		}
+	}
+	access(0, "SELECT SUM(amount) FROM orders")
+	access(5, "SELECT SUM(amount) FROM orders")
+	access(10, "SELECT SUM(amount) FROM orders")
+	access(20, "SELECT * FROM profiles")
+	access(1500, "SELECT * FROM profiles")
+	printLRUKStatus(correlated)
+	fmt.Printf("合并的关联访问: %v 次\n", correlated.Stats()["correlatedAccesses"])
 }
 
 // 辅助函数：打印LRU-K缓存状态
@@ -0,0 +1,297 @@
+package cache_strategies
+
+/*
+内存压力驱动的主动淘汰
+
+原理：
+缓存平时只在写入新数据、容量已满时才淘汰，内存用量由容量间接决定。
+进程的其他部分突然占用大量内存时（批处理、大请求、流量突增），缓存仍然装满，可能把进程推向 OOM。
+PressureEvictor 在后台定期读取堆内存用量，超过阈值后主动从各缓存中淘汰一定比例最久未使用的条目，
+把内存让给更紧急的用途；容量本身不变，压力解除后缓存会随正常访问重新填满。
+
+为了避免在阈值附近反复进入、退出压力状态（抖动），使用迟滞：
+- 用量超过 Threshold 时进入压力状态
+- 处于压力状态时每次检查都淘汰一轮，直到用量降到 Threshold*(1-Hysteresis) 以下才退出
+- 用量在两者之间且不处于压力状态时什么都不做
+
+关键特点：
+1. 与 MemoryController 的区别：MemoryController 缩小容量，压力解除后逐步扩张；
+   PressureEvictor 只删除条目、不改容量，适合内存尖峰短暂、希望尽快恢复命中率的场景
+2. 每轮按比例淘汰（默认10%），条目多的缓存淘汰得多，至少淘汰1个
+3. 阈值可以直接配置，也可以取 debug.SetMemoryLimit 软限制的90%
+4. 被淘汰的条目触发 OnEvict 回调，原因为 EvictionMemoryPressure
+
+实现方式：
+- 缓存实现 Evictor 接口（Evict、Size），主包中的 LRU 和本包的 TTLLRU 都已实现，从链表尾部淘汰
+- 默认用 runtime.ReadMemStats 的 HeapAlloc 作为内存用量，也可以替换成其他指标
+- 缓存本身不是并发安全的，注册时可以传入缓存的锁，淘汰时持有
+
+应用场景：
+- 流量有尖峰、容器内存限制固定的服务
+- 缓存在进程内存中占比很大，希望内存紧张时优先牺牲缓存
+
+优缺点：
+- 优点：实现简单，内存尖峰时快速释放内存，容量不变、压力解除后命中率恢复快
+- 缺点：HeapAlloc 包含尚未回收的垃圾，淘汰后要等 GC 才能看到用量下降，检查间隔太短会多淘汰几轮；
+  按条目数比例淘汰，条目大小差异大时释放的字节数不精确
+
+以下实现了内存压力驱动的后台淘汰器。
+*/
+
+import (
+	"fmt"
+	"math"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Evictor 可以主动淘汰条目的缓存
+type Evictor interface {
+	Evict(n int) int // 按缓存的淘汰顺序删除最多 n 个条目，返回删除的数量
+	Size() int       // 当前元素数量
+}
+
+// PressureCache 注册到 PressureEvictor 的缓存
+type PressureCache struct {
+	Name  string      // 名称，用于统计输出
+	Cache Evictor     // 被管理的缓存
+	Lock  sync.Locker // 缓存的锁，淘汰时持有；缓存自身并发安全或只在一个协程中使用时可以为 nil
+}
+
+// PressureEvictorOptions 淘汰器选项
+type PressureEvictorOptions struct {
+	Threshold  uint64        // 内存用量超过该值（字节）时进入压力状态，为0时使用 debug.SetMemoryLimit 软限制的90%
+	Hysteresis float64       // 用量降到 Threshold*(1-Hysteresis) 以下才退出压力状态
+	EvictRatio float64       // 压力状态下每轮淘汰各缓存条目数的比例
+	Interval   time.Duration // Start 后台检查的间隔
+	ReadMemory func() uint64 // 读取当前内存用量，默认为 runtime.MemStats.HeapAlloc
+}
+
+// DefaultPressureEvictorOptions 默认的淘汰器选项
+var DefaultPressureEvictorOptions = PressureEvictorOptions{
+	Hysteresis: 0.2,
+	EvictRatio: 0.1,
+	Interval:   time.Second,
+}
+
+// PressureEvictor 内存用量超过阈值时主动淘汰已注册缓存中的条目
+type PressureEvictor struct {
+	mu       sync.Mutex
+	options  PressureEvictorOptions
+	caches   []*PressureCache
+	pressure bool   // 是否处于压力状态
+	usage    uint64 // 上次检查时的内存用量
+	triggers int    // 进入压力状态的次数
+	rounds   int    // 淘汰轮数
+	evicted  int    // 淘汰的条目总数
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewPressureEvictor 创建淘汰器，未设置的选项使用默认值
+func NewPressureEvictor(options PressureEvictorOptions) (*PressureEvictor, error) {
+	if options.Threshold == 0 {
+		limit := debug.SetMemoryLimit(-1) // 负数表示只读取当前值
+		if limit == math.MaxInt64 {
+			return nil, ErrNoMemoryBudget
+		}
+		options.Threshold = uint64(float64(limit) * 0.9)
+	}
+	if options.Hysteresis <= 0 || options.Hysteresis >= 1 {
+		options.Hysteresis = DefaultPressureEvictorOptions.Hysteresis
+	}
+	if options.EvictRatio <= 0 || options.EvictRatio > 1 {
+		options.EvictRatio = DefaultPressureEvictorOptions.EvictRatio
+	}
+	if options.Interval <= 0 {
+		options.Interval = DefaultPressureEvictorOptions.Interval
+	}
+	if options.ReadMemory == nil {
+		options.ReadMemory = heapAlloc
+	}
+	return &PressureEvictor{options: options, stop: make(chan struct{})}, nil
+}
+
+// Register 注册缓存，同名缓存会被替换
+func (pe *PressureEvictor) Register(cache PressureCache) error {
+	if cache.Cache == nil {
+		return fmt.Errorf("缓存 %q 为空", cache.Name)
+	}
+
+	pe.mu.Lock()
+	defer pe.mu.Unlock()
+	for i, existing := range pe.caches {
+		if existing.Name == cache.Name {
+			pe.caches[i] = &cache
+			return nil
+		}
+	}
+	pe.caches = append(pe.caches, &cache)
+	return nil
+}
+
+// Unregister 取消注册
+func (pe *PressureEvictor) Unregister(name string) bool {
+	pe.mu.Lock()
+	defer pe.mu.Unlock()
+	for i, cache := range pe.caches {
+		if cache.Name == name {
+			pe.caches = append(pe.caches[:i], pe.caches[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Release 退出压力状态的内存用量
+func (pe *PressureEvictor) Release() uint64 {
+	return uint64(float64(pe.options.Threshold) * (1 - pe.options.Hysteresis))
+}
+
+// Check 读取一次内存用量，处于压力状态时淘汰一轮，返回本轮淘汰的条目数
+func (pe *PressureEvictor) Check() int {
+	usage := pe.options.ReadMemory()
+
+	pe.mu.Lock()
+	defer pe.mu.Unlock()
+	pe.usage = usage
+
+	switch {
+	case !pe.pressure && usage > pe.options.Threshold:
+		pe.pressure = true
+		pe.triggers++
+	case pe.pressure && usage < pe.Release():
+		pe.pressure = false
+	}
+	if !pe.pressure {
+		return 0
+	}
+
+	evicted := 0
+	for _, cache := range pe.caches {
+		evicted += cache.evict(pe.options.EvictRatio)
+	}
+	pe.rounds++
+	pe.evicted += evicted
+	return evicted
+}
+
+// evict 淘汰 ratio 比例的条目，缓存不为空时至少淘汰1个
+func (c *PressureCache) evict(ratio float64) int {
+	if c.Lock != nil {
+		c.Lock.Lock()
+		defer c.Lock.Unlock()
+	}
+	size := c.Cache.Size()
+	if size == 0 {
+		return 0
+	}
+	return c.Cache.Evict(max(int(float64(size)*ratio), 1))
+}
+
+// UnderPressure 返回上次检查后是否处于压力状态
+func (pe *PressureEvictor) UnderPressure() bool {
+	pe.mu.Lock()
+	defer pe.mu.Unlock()
+	return pe.pressure
+}
+
+// Start 启动后台定时检查
+func (pe *PressureEvictor) Start() {
+	go func() {
+		ticker := time.NewTicker(pe.options.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				pe.Check()
+			case <-pe.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止后台检查，可重复调用
+func (pe *PressureEvictor) Stop() {
+	pe.stopOnce.Do(func() { close(pe.stop) })
+}
+
+// Stats 返回淘汰器统计信息
+func (pe *PressureEvictor) Stats() map[string]interface{} {
+	pe.mu.Lock()
+	defer pe.mu.Unlock()
+	return map[string]interface{}{
+		"threshold": pe.options.Threshold,
+		"release":   pe.Release(),
+		"usage":     pe.usage,
+		"pressure":  pe.pressure,
+		"caches":    len(pe.caches),
+		"triggers":  pe.triggers,
+		"rounds":    pe.rounds,
+		"evicted":   pe.evicted,
+	}
+}
+
+// 场景示例：导出任务临时占用大量内存，会话缓存和商品缓存让出内存，任务结束后重新填满
+func PressureEvictorDemo() {
+	fmt.Println("内存压力主动淘汰示例:")
+
+	const entryBytes = 1 << 10 // 假设每个缓存项约 1KB
+	sessions := NewTTLLRU[int, string](3000, time.Hour)
+	products := NewTTLLRU[int, string](3000, time.Hour)
+	pressureEvicted := 0
+	sessions.OnEvict(func(key int, value string, reason EvictionReason) {
+		if reason == EvictionMemoryPressure {
+			pressureEvicted++
+		}
+	})
+
+	// 用"其他模块占用 + 缓存项数量 × 条目大小"模拟进程内存，便于观察淘汰器的行为
+	var otherUsage uint64 = 2 << 20
+	readMemory := func() uint64 {
+		return otherUsage + uint64(sessions.Size()+products.Size())*entryBytes
+	}
+
+	evictor, err := NewPressureEvictor(PressureEvictorOptions{
+		Threshold:  10 << 20,
+		Hysteresis: 0.2,
+		EvictRatio: 0.25,
+		ReadMemory: readMemory,
+	})
+	if err != nil {
+		fmt.Printf("创建淘汰器失败: %v\n", err)
+		return
+	}
+	evictor.Register(PressureCache{Name: "会话", Cache: sessions})
+	evictor.Register(PressureCache{Name: "商品", Cache: products})
+
+	fill := func(step int) {
+		for i := 0; i < 300; i++ {
+			sessions.Put(step*300+i, "session")
+			products.Put((step*300+i)%5000, "product")
+		}
+	}
+
+	fmt.Printf("阈值 %.1fMB，低于 %.1fMB 才退出压力状态，每轮淘汰 25%%\n\n",
+		float64(10<<20)/(1<<20), float64(evictor.Release())/(1<<20))
+	fmt.Println("步骤  其他占用  检查前用量  压力   淘汰  检查后用量")
+	for step := 0; step < 16; step++ {
+		switch step {
+		case 6:
+			otherUsage = 6 << 20 // 导出任务开始
+		case 11:
+			otherUsage = 3 << 20 // 导出任务结束，内存没有完全释放
+		}
+		fill(step)
+		before := readMemory()
+		evicted := evictor.Check()
+		fmt.Printf("%4d  %6dMB  %8.1fMB  %-5v  %4d  %8.1fMB\n", step, otherUsage>>20,
+			float64(before)/(1<<20), evictor.UnderPressure(), evicted, float64(readMemory())/(1<<20))
+	}
+
+	stats := evictor.Stats()
+	fmt.Printf("\n进入压力状态 %d 次，淘汰 %d 轮共 %d 个缓存项（会话缓存回调收到 %d 个），缓存容量始终为 %d\n",
+		stats["triggers"], stats["rounds"], stats["evicted"], pressureEvicted, sessions.Capacity())
+}
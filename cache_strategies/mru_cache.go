@@ -0,0 +1,220 @@
+package cache_strategies
+
+/*
+MRU（Most Recently Used）缓存替换算法
+
+原理：
+MRU与LRU相反，缓存满时淘汰最近刚被访问过的数据。
+它针对的是"刚用过的数据短期内不会再用"的访问模式，典型的例子是对一个比缓存大的数据集反复做顺序扫描：
+LRU 总是淘汰下一轮最先要读的数据，命中率为0；MRU 则保留扫描开头的那部分数据，每一轮都能命中。
+
+关键特点：
+1. 淘汰最近一次访问（读或写）的数据
+2. 在循环顺序扫描、嵌套循环连接（nested loop join）的内表扫描等模式下明显优于LRU
+3. 在热点明显的负载上表现很差：热点数据刚被访问就成为下一个被淘汰的对象
+
+实现方式：
+- 与LRU相同，使用双向链表 + 哈希表，链表头部是最近访问的数据
+- 访问时把节点移到头部，缓存满时先从头部淘汰，再插入新节点
+
+应用场景：
+- 数据库对大表的重复顺序扫描
+- 循环播放列表、轮询式批处理
+- 作为淘汰策略对比的基线，说明访问模式对策略选择的影响
+
+优缺点：
+- 优点：实现与LRU一样简单，循环扫描负载下命中率远高于LRU
+- 缺点：只适合特定的访问模式，通用负载下命中率很低
+
+以下实现了MRU缓存，以及循环扫描负载上与FIFO对比的示例。
+*/
+
+import (
+	"container/list"
+	"fmt"
+
+	"github.com/strive/scenario/sizeof"
+)
+
+// MRUEntry MRU缓存节点结构
+type MRUEntry[K comparable, V any] struct {
+	Key   K
+	Value V
+}
+
+// MRU 泛型MRU缓存结构
+type MRU[K comparable, V any] struct {
+	capacity int                 // 最大容量
+	list     *list.List          // 双向链表：头部为最近访问的节点
+	cache    map[K]*list.Element // 哈希表：键 -> 链表节点
+	stats    StatsCounter        // 访问统计
+	onEvict  EvictionListeners[K, V]
+}
+
+// MRUCache 字符串键、任意值的MRU缓存
+type MRUCache = MRU[string, interface{}]
+
+// NewMRUCache 创建指定容量的MRU缓存
+func NewMRUCache(capacity int) *MRUCache {
+	return NewMRU[string, interface{}](capacity)
+}
+
+// NewMRU 创建指定容量的泛型MRU缓存
+func NewMRU[K comparable, V any](capacity int) *MRU[K, V] {
+	return &MRU[K, V]{
+		capacity: capacity,
+		list:     list.New(),
+		cache:    make(map[K]*list.Element),
+	}
+}
+
+// Get 获取缓存中的值并把它标记为最近访问，不存在返回零值和false
+func (c *MRU[K, V]) Get(key K) (V, bool) {
+	if element, exists := c.cache[key]; exists {
+		c.list.MoveToFront(element)
+		c.stats.RecordHit()
+		return element.Value.(*MRUEntry[K, V]).Value, true
+	}
+	c.stats.RecordMiss()
+	var zero V
+	return zero, false
+}
+
+// Put 插入或更新缓存中的键值对，容量已满时淘汰最近访问的元素
+func (c *MRU[K, V]) Put(key K, value V) {
+	if c.capacity <= 0 {
+		return
+	}
+	if element, exists := c.cache[key]; exists {
+		element.Value.(*MRUEntry[K, V]).Value = value
+		c.list.MoveToFront(element)
+		return
+	}
+
+	// 先淘汰最近访问的元素再插入，否则刚插入的新元素会成为被淘汰的对象
+	var evicted *MRUEntry[K, V]
+	if c.list.Len() >= c.capacity {
+		newest := c.list.Front()
+		c.list.Remove(newest)
+		evicted = newest.Value.(*MRUEntry[K, V])
+		delete(c.cache, evicted.Key)
+		c.stats.RecordEvictions(1)
+	}
+
+	c.cache[key] = c.list.PushFront(&MRUEntry[K, V]{Key: key, Value: value})
+
+	// 新节点插入后再通知，回调中可以安全地访问缓存
+	if evicted != nil {
+		c.onEvict.Notify(evicted.Key, evicted.Value, EvictionCapacity)
+	}
+}
+
+// Remove 从缓存中删除指定键
+func (c *MRU[K, V]) Remove(key K) bool {
+	if element, exists := c.cache[key]; exists {
+		c.list.Remove(element)
+		delete(c.cache, key)
+		return true
+	}
+	return false
+}
+
+// Size 返回当前缓存中的元素数量
+func (c *MRU[K, V]) Size() int {
+	return c.list.Len()
+}
+
+// Capacity 返回最大容量
+func (c *MRU[K, V]) Capacity() int {
+	return c.capacity
+}
+
+// Resize 调整最大容量（至少为1），容量变小时从最近访问的一端淘汰多出的元素，返回淘汰的数量
+func (c *MRU[K, V]) Resize(capacity int) int {
+	if capacity < 1 {
+		capacity = 1
+	}
+	c.capacity = capacity
+
+	var evicted []*MRUEntry[K, V]
+	for c.list.Len() > capacity {
+		newest := c.list.Front()
+		c.list.Remove(newest)
+		entry := newest.Value.(*MRUEntry[K, V])
+		delete(c.cache, entry.Key)
+		evicted = append(evicted, entry)
+	}
+	c.stats.RecordEvictions(len(evicted))
+	for _, entry := range evicted {
+		c.onEvict.Notify(entry.Key, entry.Value, EvictionResize)
+	}
+	return len(evicted)
+}
+
+// OnEvict 注册淘汰回调，条目因容量不足或 Resize 缩容被淘汰时调用；Remove、Clear 不会触发
+func (c *MRU[K, V]) OnEvict(callback EvictionCallback[K, V]) {
+	c.onEvict.Add(callback)
+}
+
+// Keys 返回缓存中所有键的列表（从最近访问到最久未访问）
+func (c *MRU[K, V]) Keys() []K {
+	keys := make([]K, 0, c.list.Len())
+	for e := c.list.Front(); e != nil; e = e.Next() {
+		keys = append(keys, e.Value.(*MRUEntry[K, V]).Key)
+	}
+	return keys
+}
+
+// Clear 清空缓存
+func (c *MRU[K, V]) Clear() {
+	c.list = list.New()
+	c.cache = make(map[K]*list.Element)
+}
+
+// CacheStats 返回命中、未命中、淘汰和过期次数的快照
+func (c *MRU[K, V]) CacheStats() CacheStats {
+	return c.stats.Snapshot()
+}
+
+// Stats 返回访问统计和容量信息
+func (c *MRU[K, V]) Stats() map[string]interface{} {
+	stats := c.stats.Snapshot().Map()
+	stats["capacity"] = c.capacity
+	stats["size"] = c.list.Len()
+	return stats
+}
+
+// MemoryUsage 返回深度估算的内存占用（字节）
+func (c *MRU[K, V]) MemoryUsage() int64 {
+	return sizeof.Of(c)
+}
+
+// 场景示例：报表任务每轮顺序扫描一张6页的表，缓冲池只能容纳4页
+func MRUCacheDemo() {
+	fmt.Println("MRU缓存示例 (循环扫描6个数据页，缓冲池容量=4):")
+
+	mru := NewMRU[string, string](4)
+	fifo := NewFIFO[string, string](4)
+	pages := []string{"page1", "page2", "page3", "page4", "page5", "page6"}
+
+	for round := 1; round <= 3; round++ {
+		mruHits, fifoHits := 0, 0
+		for _, page := range pages {
+			if _, ok := mru.Get(page); ok {
+				mruHits++
+			} else {
+				mru.Put(page, "数据页:"+page)
+			}
+			if _, ok := fifo.Get(page); ok {
+				fifoHits++
+			} else {
+				fifo.Put(page, "数据页:"+page)
+			}
+		}
+		fmt.Printf("第%d轮: MRU命中 %d/6，缓冲池 %v；FIFO命中 %d/6，缓冲池 %v\n",
+			round, mruHits, mru.Keys(), fifoHits, fifo.Keys())
+	}
+
+	fmt.Printf("\n三轮总命中率: MRU %.1f%%，FIFO（以及LRU）%.1f%%\n",
+		mru.CacheStats().HitRate()*100, fifo.CacheStats().HitRate()*100)
+}
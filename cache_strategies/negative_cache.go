@@ -0,0 +1,126 @@
+package cache_strategies
+
+/*
+负缓存（Negative Caching）- 缓存"数据不存在"的查询结果
+
+原理：
+缓存只保存查到的数据时，查询一个数据库里根本不存在的键永远不会命中，每次请求都会落到数据库上，
+这就是缓存穿透。恶意请求或爬虫反复查询不存在的ID，可以绕过缓存直接压垮数据库。
+负缓存在数据源确认"不存在"后，把这个结论也写入缓存并设置一个较短的过期时间，
+过期之前同一个键的请求直接由缓存回答"不存在"。
+
+关键特点：
+1. "不存在"的记录与真实的值区分开：Get 仍返回未命中，Lookup 返回 LookupNegative，GetOrLoad 返回 ErrNotFound
+2. 负缓存的过期时间（NegativeTTL）通常远短于普通数据，数据被创建后最多延迟这么久才能被读到
+3. "不存在"的记录和普通条目一样占用容量、参与淘汰，但不触发过期和淘汰回调，也不出现在 Keys 和快照中
+4. 只有加载函数返回 ErrNotFound（或包装了它的错误）时才记录，其他错误（如超时）不缓存
+
+实现方式：
+- 条目增加 negative 标记，值为零值
+- GetOrLoad 加载失败时用 errors.Is 判断是否为 ErrNotFound，是则按 NegativeTTL 写入负缓存记录
+- TTLCache 通过 TTLCacheOptions.NegativeTTL 配置，TTLLRU 通过 SetNegativeTTL 配置，也可以调用 SetNotFound 直接写入
+
+应用场景：
+- 缓存穿透防护（与布隆过滤器互补：布隆过滤器拦截一定不存在的键，负缓存拦截刚查过确实不存在的键，
+  不需要预先知道全部存在的键，参见 practical_applications/bloom_filter.go）
+- DNS 的 NXDOMAIN 缓存
+- 用户名、短链接等"是否已被占用"的查询
+
+优缺点：
+- 优点：实现简单，不需要预先加载全量键集合，对反复查询同一个不存在键的请求非常有效
+- 缺点：每次查询不同的随机键时无效（此时应配合布隆过滤器）；数据创建后在 NegativeTTL 内仍被报告为不存在
+
+以下定义了负缓存使用的错误和查询结果类型，以及用户查询接口防止缓存穿透的示例。
+*/
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotFound 表示数据源中不存在该键；GetOrLoad 的加载函数返回它（或包装了它的错误）时会写入负缓存
+var ErrNotFound = errors.New("数据不存在")
+
+// LookupStatus Lookup 的查询结果
+type LookupStatus int
+
+const (
+	LookupMiss     LookupStatus = iota // 缓存中没有记录，需要查询数据源
+	LookupHit                          // 命中真实的值
+	LookupNegative                     // 命中"不存在"的记录，无需查询数据源
+)
+
+// String 返回查询结果的名称
+func (s LookupStatus) String() string {
+	switch s {
+	case LookupMiss:
+		return "未命中"
+	case LookupHit:
+		return "命中"
+	case LookupNegative:
+		return "不存在"
+	default:
+		return fmt.Sprintf("LookupStatus(%d)", int(s))
+	}
+}
+
+// 场景示例：用户查询接口被反复请求不存在的用户ID
+func NegativeCacheDemo() {
+	fmt.Println("负缓存示例 (防止缓存穿透):")
+
+	users := map[string]string{"1001": "张三", "1002": "李四"}
+	dbQueries := 0
+	loadUser := func(id string) func() (string, error) {
+		return func() (string, error) {
+			dbQueries++
+			if name, ok := users[id]; ok {
+				return name, nil
+			}
+			return "", fmt.Errorf("用户 %s: %w", id, ErrNotFound)
+		}
+	}
+	// 20次请求中只有2次查询存在的用户，其余查询两个不存在的ID
+	requests := []string{"1001", "1002"}
+	for i := 0; i < 18; i++ {
+		requests = append(requests, []string{"9999", "-1"}[i%2])
+	}
+	replay := func(get func(id string) (string, error)) (notFound int) {
+		dbQueries = 0
+		for _, id := range requests {
+			if _, err := get(id); errors.Is(err, ErrNotFound) {
+				notFound++
+			}
+		}
+		return notFound
+	}
+
+	plain := NewTTL[string, string](TTLCacheOptions{DefaultTTL: time.Minute})
+	defer plain.StopCleanup()
+	notFound := replay(func(id string) (string, error) { return plain.GetOrLoad(id, loadUser(id)) })
+	fmt.Printf("不缓存\"不存在\": %d 次请求，%d 次返回不存在，数据库查询 %d 次\n", len(requests), notFound, dbQueries)
+
+	negative := NewTTL[string, string](TTLCacheOptions{DefaultTTL: time.Minute, NegativeTTL: 100 * time.Millisecond})
+	defer negative.StopCleanup()
+	notFound = replay(func(id string) (string, error) { return negative.GetOrLoad(id, loadUser(id)) })
+	fmt.Printf("NegativeTTL=100ms: %d 次请求，%d 次返回不存在，数据库查询 %d 次\n", len(requests), notFound, dbQueries)
+
+	lru := NewTTLLRU[string, string](100, time.Minute)
+	lru.SetNegativeTTL(100 * time.Millisecond)
+	notFound = replay(func(id string) (string, error) { return lru.GetOrLoad(id, loadUser(id)) })
+	fmt.Printf("TTL+LRU 负缓存:   %d 次请求，%d 次返回不存在，数据库查询 %d 次\n", len(requests), notFound, dbQueries)
+
+	// 负缓存记录与真实值区分开：Get 视为未命中，Lookup 能区分
+	_, ok := negative.Get("9999")
+	_, status := negative.Lookup("9999")
+	fmt.Printf("\nGet(\"9999\") 命中=%v，Lookup(\"9999\") = %s，Keys = %v\n", ok, status, negative.Keys())
+
+	// 用户注册后，负缓存过期之前仍报告不存在
+	users["9999"] = "王五"
+	_, err := negative.GetOrLoad("9999", loadUser("9999"))
+	fmt.Printf("用户9999注册后立即查询: %v\n", err)
+	time.Sleep(150 * time.Millisecond)
+	name, err := negative.GetOrLoad("9999", loadUser("9999"))
+	fmt.Printf("NegativeTTL 过期后查询: %s (错误: %v)\n", name, err)
+	fmt.Printf("缓存统计: %v\n", negative.CacheStats())
+}
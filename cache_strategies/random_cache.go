@@ -0,0 +1,243 @@
+package cache_strategies
+
+/*
+Random（随机替换）缓存替换算法
+
+原理：
+缓存满时从现有数据中等概率随机选一个淘汰，完全不看访问历史。
+它是评估淘汰策略时最常用的基线：一个策略如果连随机替换都比不过，说明它对访问模式的假设在这个负载上是错的。
+
+关键特点：
+1. 不维护任何访问顺序或频率，Get 不修改任何状态
+2. 每个条目被淘汰的概率相同，与访问模式无关
+3. 在循环扫描（工作集略大于缓存）这类让 LRU/FIFO 命中率降为0的模式下，随机替换反而能保留一部分数据
+4. 使用带种子的随机数生成器，同一个种子回放同一条轨迹得到相同的结果，便于对比
+
+实现方式：
+- 切片保存所有键，哈希表保存键到条目的映射，条目记录自己在切片中的下标
+- 淘汰时随机选一个下标，把切片最后一个键移到该位置，删除为 O(1)
+
+应用场景：
+- 淘汰策略对比的基线
+- 访问模式接近均匀随机、维护访问顺序得不偿失的场景
+- CPU 缓存、TLB 等硬件实现（维护LRU顺序代价高）
+
+优缺点：
+- 优点：实现最简单，读操作无需修改状态，没有最坏情况的访问模式
+- 缺点：不利用任何局部性，热点明显的负载上命中率明显低于LRU/LFU
+
+以下实现了随机替换缓存，以及在循环扫描和热点两种负载上与其他策略对比的示例。
+*/
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/strive/scenario/sizeof"
+)
+
+// RandomEntry 随机替换缓存节点结构
+type RandomEntry[K comparable, V any] struct {
+	Key   K
+	Value V
+	index int // 在键切片中的下标
+}
+
+// Random 泛型随机替换缓存结构
+type Random[K comparable, V any] struct {
+	capacity int                      // 最大容量
+	keys     []K                      // 所有键，淘汰时随机选取
+	cache    map[K]*RandomEntry[K, V] // 哈希表：键 -> 节点
+	rng      *rand.Rand               // 随机数生成器
+	stats    StatsCounter             // 访问统计
+	onEvict  EvictionListeners[K, V]
+}
+
+// RandomCache 字符串键、任意值的随机替换缓存
+type RandomCache = Random[string, interface{}]
+
+// NewRandomCache 创建指定容量的随机替换缓存，seed 相同时淘汰顺序相同
+func NewRandomCache(capacity int, seed int64) *RandomCache {
+	return NewRandom[string, interface{}](capacity, seed)
+}
+
+// NewRandom 创建指定容量的泛型随机替换缓存，seed 相同时淘汰顺序相同
+func NewRandom[K comparable, V any](capacity int, seed int64) *Random[K, V] {
+	return &Random[K, V]{
+		capacity: capacity,
+		keys:     make([]K, 0, capacity),
+		cache:    make(map[K]*RandomEntry[K, V]),
+		rng:      rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Get 获取缓存中的值，不存在返回零值和false
+func (c *Random[K, V]) Get(key K) (V, bool) {
+	if entry, exists := c.cache[key]; exists {
+		c.stats.RecordHit()
+		return entry.Value, true
+	}
+	c.stats.RecordMiss()
+	var zero V
+	return zero, false
+}
+
+// Put 插入或更新缓存中的键值对，容量已满时随机淘汰一个元素
+func (c *Random[K, V]) Put(key K, value V) {
+	if c.capacity <= 0 {
+		return
+	}
+	if entry, exists := c.cache[key]; exists {
+		entry.Value = value
+		return
+	}
+
+	var evicted *RandomEntry[K, V]
+	if len(c.keys) >= c.capacity {
+		evicted = c.removeAt(c.rng.Intn(len(c.keys)))
+		c.stats.RecordEvictions(1)
+	}
+
+	c.cache[key] = &RandomEntry[K, V]{Key: key, Value: value, index: len(c.keys)}
+	c.keys = append(c.keys, key)
+
+	// 新节点插入后再通知，回调中可以安全地访问缓存
+	if evicted != nil {
+		c.onEvict.Notify(evicted.Key, evicted.Value, EvictionCapacity)
+	}
+}
+
+// removeAt 删除下标 i 处的键：把最后一个键移到该位置后截短切片
+func (c *Random[K, V]) removeAt(i int) *RandomEntry[K, V] {
+	entry := c.cache[c.keys[i]]
+	last := len(c.keys) - 1
+	c.keys[i] = c.keys[last]
+	c.cache[c.keys[i]].index = i
+	c.keys = c.keys[:last]
+	delete(c.cache, entry.Key)
+	return entry
+}
+
+// Remove 从缓存中删除指定键
+func (c *Random[K, V]) Remove(key K) bool {
+	entry, exists := c.cache[key]
+	if !exists {
+		return false
+	}
+	c.removeAt(entry.index)
+	return true
+}
+
+// Size 返回当前缓存中的元素数量
+func (c *Random[K, V]) Size() int {
+	return len(c.keys)
+}
+
+// Capacity 返回最大容量
+func (c *Random[K, V]) Capacity() int {
+	return c.capacity
+}
+
+// Resize 调整最大容量（至少为1），容量变小时随机淘汰多出的元素，返回淘汰的数量
+func (c *Random[K, V]) Resize(capacity int) int {
+	if capacity < 1 {
+		capacity = 1
+	}
+	c.capacity = capacity
+
+	var evicted []*RandomEntry[K, V]
+	for len(c.keys) > capacity {
+		evicted = append(evicted, c.removeAt(c.rng.Intn(len(c.keys))))
+	}
+	c.stats.RecordEvictions(len(evicted))
+	for _, entry := range evicted {
+		c.onEvict.Notify(entry.Key, entry.Value, EvictionResize)
+	}
+	return len(evicted)
+}
+
+// OnEvict 注册淘汰回调，条目因容量不足或 Resize 缩容被淘汰时调用；Remove、Clear 不会触发
+func (c *Random[K, V]) OnEvict(callback EvictionCallback[K, V]) {
+	c.onEvict.Add(callback)
+}
+
+// Keys 返回缓存中所有键的列表（顺序无意义）
+func (c *Random[K, V]) Keys() []K {
+	return append([]K(nil), c.keys...)
+}
+
+// Clear 清空缓存
+func (c *Random[K, V]) Clear() {
+	c.keys = make([]K, 0, c.capacity)
+	c.cache = make(map[K]*RandomEntry[K, V])
+}
+
+// CacheStats 返回命中、未命中、淘汰和过期次数的快照
+func (c *Random[K, V]) CacheStats() CacheStats {
+	return c.stats.Snapshot()
+}
+
+// Stats 返回访问统计和容量信息
+func (c *Random[K, V]) Stats() map[string]interface{} {
+	stats := c.stats.Snapshot().Map()
+	stats["capacity"] = c.capacity
+	stats["size"] = len(c.keys)
+	return stats
+}
+
+// MemoryUsage 返回深度估算的内存占用（字节）
+func (c *Random[K, V]) MemoryUsage() int64 {
+	return sizeof.Of(c)
+}
+
+// 场景示例：夜间批处理任务循环读取101个数据分片，缓存只能容纳100个
+func RandomCacheDemo() {
+	type statsCache interface {
+		Cache[int, string]
+		CacheStats() CacheStats
+	}
+	type policy struct {
+		name  string
+		cache statsCache
+	}
+	replay := func(trace []int, policies ...policy) {
+		for _, p := range policies {
+			for _, key := range trace {
+				if _, ok := p.cache.Get(key); !ok {
+					p.cache.Put(key, fmt.Sprintf("数据%d", key))
+				}
+			}
+			fmt.Printf("  %-6s 命中率 %5.1f%%\n", p.name, p.cache.CacheStats().HitRate()*100)
+		}
+	}
+
+	// FIFO（以及LRU）总是淘汰下一个马上要读的分片，每次读取都未命中
+	fmt.Println("随机替换缓存示例 (循环读取101个分片50轮，缓存容量=100):")
+	loop := make([]int, 0, 101*50)
+	for round := 0; round < 50; round++ {
+		for shard := 0; shard < 101; shard++ {
+			loop = append(loop, shard)
+		}
+	}
+	replay(loop,
+		policy{"FIFO", NewFIFO[int, string](100)},
+		policy{"Random", NewRandom[int, string](100, 1)},
+	)
+
+	// 热点明显的负载上随机替换不占优势
+	fmt.Println("\n热点负载 (80%的访问集中在10个键，共1000个键，缓存容量=20):")
+	rng := rand.New(rand.NewSource(3))
+	hot := make([]int, 20000)
+	for i := range hot {
+		if rng.Float64() < 0.8 {
+			hot[i] = rng.Intn(10)
+		} else {
+			hot[i] = 10 + rng.Intn(990)
+		}
+	}
+	replay(hot,
+		policy{"FIFO", NewFIFO[int, string](20)},
+		policy{"SLRU", NewSLRU[int, string](20, DefaultProtectedRatio)},
+		policy{"Random", NewRandom[int, string](20, 1)},
+	)
+}
@@ -0,0 +1,329 @@
+package cache_strategies
+
+/*
+SLRU（Segmented LRU）分段LRU缓存替换算法
+
+原理：
+普通LRU只看最近一次访问，一次性的批量扫描（如全表导出、爬虫遍历）会把真正的热点数据全部挤出缓存。
+SLRU把缓存分成两段：
+- 试用段（probation）：新数据先进入这里
+- 保护段（protected）：在试用段中再次被访问的数据晋升到这里
+淘汰总是从试用段的尾部开始，只被访问过一次的数据在试用段里自生自灭，不会影响保护段中的热点数据。
+保护段满时，其中最久未访问的数据降级回试用段头部，获得再次证明自己的机会。
+
+关键特点：
+1. 两段各自按LRU维护，数据在第二次访问时晋升
+2. 保护段占总容量的比例可配置，比例越大越偏向保护长期热点，越小越适应访问模式的变化
+3. 对扫描型访问有抵抗力，实现却只比LRU多一个链表
+4. 是 W-TinyLFU 等现代算法中主缓存区的基础结构
+
+实现方式：
+- 两个双向链表分别表示试用段和保护段，一个哈希表记录键所在的链表节点
+- 节点上记录所在的段，晋升、降级时在两个链表之间移动
+
+应用场景：
+- 数据库缓冲池、磁盘缓存（常有顺序扫描）
+- CDN 和对象存储的热点缓存
+- 需要兼顾最近性和频率、又不想引入复杂计数的场景
+
+优缺点：
+- 优点：抗扫描，命中率通常高于LRU，操作都是O(1)
+- 缺点：保护段比例需要根据业务调整；只区分"一次"和"多次"访问，不如 LFU 精细
+
+以下实现了保护段比例可配置的SLRU缓存，并通过 Stats 输出两段的占用情况。
+*/
+
+import (
+	"container/list"
+	"fmt"
+	"math"
+
+	"github.com/strive/scenario/sizeof"
+)
+
+// DefaultProtectedRatio 保护段默认占总容量的比例
+const DefaultProtectedRatio = 0.8
+
+// SLRUEntry SLRU缓存节点结构
+type SLRUEntry[K comparable, V any] struct {
+	Key       K
+	Value     V
+	Protected bool // 是否在保护段
+}
+
+// SLRU 泛型SLRU缓存结构
+type SLRU[K comparable, V any] struct {
+	capacity     int                 // 最大容量
+	ratio        float64             // 保护段占总容量的比例
+	protectedCap int                 // 保护段容量
+	probation    *list.List          // 试用段：新数据
+	protected    *list.List          // 保护段：至少访问过两次的数据
+	cache        map[K]*list.Element // 哈希表：键 -> 链表节点
+	promotions   int                 // 从试用段晋升到保护段的次数
+	demotions    int                 // 从保护段降级到试用段的次数
+	stats        StatsCounter        // 访问统计
+}
+
+// SLRUNode 字符串键SLRU缓存节点（兼容旧接口）
+type SLRUNode = SLRUEntry[string, interface{}]
+
+// SLRUCache 字符串键、任意值的SLRU缓存（兼容旧接口）
+type SLRUCache = SLRU[string, interface{}]
+
+// NewSLRUCache 创建指定容量和保护段比例的SLRU缓存
+func NewSLRUCache(capacity int, protectedRatio float64) *SLRUCache {
+	return NewSLRU[string, interface{}](capacity, protectedRatio)
+}
+
+// NewSLRU 创建指定容量和保护段比例的泛型SLRU缓存，比例不在 (0, 1) 内时使用默认值
+func NewSLRU[K comparable, V any](capacity int, protectedRatio float64) *SLRU[K, V] {
+	if protectedRatio <= 0 || protectedRatio >= 1 {
+		protectedRatio = DefaultProtectedRatio
+	}
+	c := &SLRU[K, V]{
+		ratio:     protectedRatio,
+		probation: list.New(),
+		protected: list.New(),
+		cache:     make(map[K]*list.Element),
+	}
+	c.setCapacity(capacity)
+	return c
+}
+
+// setCapacity 设置总容量（至少为1）并重新计算保护段容量
+func (c *SLRU[K, V]) setCapacity(capacity int) {
+	if capacity < 1 {
+		capacity = 1
+	}
+	c.capacity = capacity
+	c.protectedCap = int(math.Round(float64(capacity) * c.ratio))
+	if c.protectedCap >= capacity && capacity > 1 {
+		c.protectedCap = capacity - 1 // 至少给试用段留一个位置
+	}
+}
+
+// Get 获取缓存中的值，不存在返回零值和false
+func (c *SLRU[K, V]) Get(key K) (V, bool) {
+	element, exists := c.cache[key]
+	if !exists {
+		c.stats.RecordMiss()
+		var zero V
+		return zero, false
+	}
+	c.stats.RecordHit()
+	c.touch(element)
+	return element.Value.(*SLRUEntry[K, V]).Value, true
+}
+
+// touch 记录一次访问：保护段中的节点移到头部，试用段中的节点晋升到保护段
+func (c *SLRU[K, V]) touch(element *list.Element) {
+	node := element.Value.(*SLRUEntry[K, V])
+	if node.Protected {
+		c.protected.MoveToFront(element)
+		return
+	}
+
+	c.probation.Remove(element)
+	node.Protected = true
+	c.cache[node.Key] = c.protected.PushFront(node)
+	c.promotions++
+	c.demoteOverflow()
+}
+
+// demoteOverflow 保护段超出容量时，把其中最久未访问的节点降级到试用段头部
+func (c *SLRU[K, V]) demoteOverflow() {
+	for c.protected.Len() > c.protectedCap {
+		oldest := c.protected.Back()
+		c.protected.Remove(oldest)
+		node := oldest.Value.(*SLRUEntry[K, V])
+		node.Protected = false
+		c.cache[node.Key] = c.probation.PushFront(node)
+		c.demotions++
+	}
+}
+
+// Put 插入或更新缓存中的键值对，已存在的键视为一次访问
+func (c *SLRU[K, V]) Put(key K, value V) {
+	if element, exists := c.cache[key]; exists {
+		element.Value.(*SLRUEntry[K, V]).Value = value
+		c.touch(element)
+		return
+	}
+
+	if len(c.cache) >= c.capacity {
+		c.evict()
+	}
+	node := &SLRUEntry[K, V]{Key: key, Value: value}
+	c.cache[key] = c.probation.PushFront(node)
+}
+
+// evict 淘汰一个节点：优先淘汰试用段尾部，试用段为空时淘汰保护段尾部
+func (c *SLRU[K, V]) evict() {
+	victim := c.probation.Back()
+	segment := c.probation
+	if victim == nil {
+		victim = c.protected.Back()
+		segment = c.protected
+	}
+	if victim == nil {
+		return
+	}
+	segment.Remove(victim)
+	delete(c.cache, victim.Value.(*SLRUEntry[K, V]).Key)
+	c.stats.RecordEvictions(1)
+}
+
+// Victim 返回下一个将被淘汰的键，缓存为空时返回 false
+func (c *SLRU[K, V]) Victim() (K, bool) {
+	victim := c.probation.Back()
+	if victim == nil {
+		victim = c.protected.Back()
+	}
+	if victim == nil {
+		var zero K
+		return zero, false
+	}
+	return victim.Value.(*SLRUEntry[K, V]).Key, true
+}
+
+// Contains 判断键是否存在，不计入访问
+func (c *SLRU[K, V]) Contains(key K) bool {
+	_, exists := c.cache[key]
+	return exists
+}
+
+// Remove 从缓存中删除指定键
+func (c *SLRU[K, V]) Remove(key K) bool {
+	element, exists := c.cache[key]
+	if !exists {
+		return false
+	}
+	if element.Value.(*SLRUEntry[K, V]).Protected {
+		c.protected.Remove(element)
+	} else {
+		c.probation.Remove(element)
+	}
+	delete(c.cache, key)
+	return true
+}
+
+// Size 返回当前缓存中的元素数量
+func (c *SLRU[K, V]) Size() int {
+	return len(c.cache)
+}
+
+// Capacity 返回最大容量
+func (c *SLRU[K, V]) Capacity() int {
+	return c.capacity
+}
+
+// Resize 调整最大容量（至少为1），保护段按比例缩小后多出的节点先降级，再从试用段尾部淘汰，返回淘汰的数量
+func (c *SLRU[K, V]) Resize(capacity int) int {
+	c.setCapacity(capacity)
+	c.demoteOverflow()
+
+	evicted := 0
+	for len(c.cache) > c.capacity {
+		c.evict()
+		evicted++
+	}
+	return evicted
+}
+
+// Keys 返回缓存中所有键的列表（先保护段后试用段，各自按最近访问排序）
+func (c *SLRU[K, V]) Keys() []K {
+	keys := make([]K, 0, len(c.cache))
+	for _, segment := range []*list.List{c.protected, c.probation} {
+		for e := segment.Front(); e != nil; e = e.Next() {
+			keys = append(keys, e.Value.(*SLRUEntry[K, V]).Key)
+		}
+	}
+	return keys
+}
+
+// Clear 清空缓存
+func (c *SLRU[K, V]) Clear() {
+	c.probation = list.New()
+	c.protected = list.New()
+	c.cache = make(map[K]*list.Element)
+}
+
+// CacheStats 返回命中、未命中、淘汰和过期次数的快照
+func (c *SLRU[K, V]) CacheStats() CacheStats {
+	return c.stats.Snapshot()
+}
+
+// Stats 返回两段的占用情况和访问统计
+func (c *SLRU[K, V]) Stats() map[string]interface{} {
+	stats := c.stats.Snapshot().Map()
+	stats["capacity"] = c.capacity
+	stats["size"] = len(c.cache)
+	stats["probationSize"] = c.probation.Len()
+	stats["probationCapacity"] = c.capacity - c.protectedCap
+	stats["protectedSize"] = c.protected.Len()
+	stats["protectedCapacity"] = c.protectedCap
+	stats["promotions"] = c.promotions
+	stats["demotions"] = c.demotions
+	return stats
+}
+
+// MemoryUsage 返回深度估算的内存占用（字节）
+func (c *SLRU[K, V]) MemoryUsage() int64 {
+	return sizeof.Of(c)
+}
+
+// 场景示例：商品详情缓存遭遇一次全量导出扫描
+func SLRUCacheDemo() {
+	// 容量为6，保护段占一半
+	cache := NewSLRUCache(6, 0.5)
+
+	fmt.Println("商品详情缓存示例 (SLRU缓存容量=6，保护段比例=0.5):")
+
+	// 新商品进入试用段
+	for _, key := range []string{"iPhone", "Mate60", "小米14", "Pixel"} {
+		cache.Put(key, "详情:"+key)
+	}
+	fmt.Println("\n=== 首次加载4个商品 ===")
+	printSLRUStatus(cache)
+
+	// 热门商品被再次访问，晋升到保护段
+	cache.Get("iPhone")
+	cache.Get("Mate60")
+	cache.Get("小米14")
+	fmt.Println("\n=== iPhone、Mate60、小米14 再次被访问后晋升 ===")
+	printSLRUStatus(cache)
+
+	// 保护段已满，Pixel 再次被访问时晋升，保护段中最久未访问的 iPhone 降级回试用段
+	cache.Get("Pixel")
+	fmt.Println("\n=== Pixel 晋升，保护段满，iPhone 被降级 ===")
+	printSLRUStatus(cache)
+
+	// 后台任务导出全部商品：每个商品只访问一次，只会冲刷试用段
+	for i := 1; i <= 20; i++ {
+		key := fmt.Sprintf("SKU-%03d", i)
+		if _, ok := cache.Get(key); !ok {
+			cache.Put(key, "详情:"+key)
+		}
+	}
+	fmt.Println("\n=== 导出扫描20个冷门商品后 ===")
+	printSLRUStatus(cache)
+
+	stats := cache.Stats()
+	fmt.Printf("\n晋升 %d 次，降级 %d 次，淘汰 %d 次；扫描后热点商品仍在保护段，而LRU此时已全部被挤出\n",
+		stats["promotions"], stats["demotions"], stats["evictions"])
+}
+
+// 辅助函数：打印SLRU缓存两段的状态
+func printSLRUStatus(cache *SLRUCache) {
+	stats := cache.Stats()
+	fmt.Printf("保护段(%d/%d): ", stats["protectedSize"], stats["protectedCapacity"])
+	for e := cache.protected.Front(); e != nil; e = e.Next() {
+		fmt.Printf("%s ", e.Value.(*SLRUNode).Key)
+	}
+	// 保护段未满时试用段可以借用空位，所以试用段的数量可能超过名义容量
+	fmt.Printf("\n试用段(%d，名义容量%d): ", stats["probationSize"], stats["probationCapacity"])
+	for e := cache.probation.Front(); e != nil; e = e.Next() {
+		fmt.Printf("%s ", e.Value.(*SLRUNode).Key)
+	}
+	fmt.Println()
+}
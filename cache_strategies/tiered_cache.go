@@ -0,0 +1,514 @@
+package cache_strategies
+
+/*
+二级缓存：内存LRU + 慢速存储（写穿与写回）
+
+原理：
+磁盘、远程KV等存储容量大但读写慢，内存容量小但快。二级缓存在慢速存储前放一层内存LRU：
+读取先查内存，未命中再读存储并回填内存；写入则有两种策略：
+- 写穿（write-through）：每次写入同步写存储，成功后再更新内存，两层始终一致，写入延迟等于存储延迟
+- 写回（write-back）：写入只更新内存并标记为脏，由后台定期把脏数据刷到存储，或在脏条目被淘汰时写回；
+  同一个键在两次刷盘之间的多次写入只落盘一次，写入延迟等于内存延迟，代价是进程崩溃时丢失尚未刷盘的数据
+
+关键特点：
+1. 内存层按LRU淘汰，写回模式下被淘汰的脏条目先写回存储，写回完成前仍能从内存读到
+2. 写回模式的后台刷盘按 FlushInterval 周期执行，Flush 可以随时手动刷盘，Close 停止后台刷盘并做最后一次刷盘
+3. 刷盘期间同一个键又被写入时，刷盘完成后该键仍保持为脏，下一次刷盘写入新值
+4. 所有写存储的操作串行执行，保证同一个键在存储中的写入顺序与写入缓存的顺序一致
+5. 回填内存前检查加载期间是否发生过写入或删除，避免把旧值回填到内存
+
+实现方式：
+- 哈希表 + 双向链表实现内存LRU，节点记录是否为脏以及版本号，每次写入版本号加一
+- 被淘汰但尚未写回的脏条目暂存在 pending 表中，读取时先查内存再查 pending 最后查存储
+- 全局写入代数（generation）在每次写入和删除时递增，读存储前记下代数，回填时代数未变才写入内存
+- BackingStore 接口抽象慢速存储，DirStore 把每个键保存为目录下的一个文件；
+  practical_applications 中的 SkiplistKVStore 通过 AsBackingStore 接入
+
+应用场景：
+- 本地磁盘缓存远程对象存储的数据
+- 计数器、会话等频繁更新、允许短暂丢失的数据（写回）
+- 配置、账户等不允许丢失的数据（写穿）
+
+优缺点：
+- 优点：读多写少时大部分读取命中内存；写回模式把高频写入合并为少量存储写入
+- 缺点：写穿模式的写入受存储延迟限制，且写入之间串行；写回模式崩溃时丢失未刷盘的数据；
+  写入频繁时回填经常因为代数变化而被放弃，读取会多次访问存储
+
+以下实现了二级缓存、基于目录的慢速存储，以及写穿与写回两种模式下存储写入次数的对比示例。
+*/
+
+import (
+	"container/list"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/strive/scenario/sizeof"
+)
+
+// ErrStoreKeyNotFound 慢速存储中不存在该键，BackingStore.Load 在键不存在时返回（或包装）该错误
+var ErrStoreKeyNotFound = errors.New("存储中不存在该键")
+
+// BackingStore 二级缓存背后的慢速存储，实现需要并发安全
+type BackingStore interface {
+	Load(key string) ([]byte, error)      // 读取，键不存在时返回 ErrStoreKeyNotFound
+	Store(key string, value []byte) error // 写入或覆盖
+	Delete(key string) error              // 删除，键不存在时不返回错误
+}
+
+// WriteMode 二级缓存的写入策略
+type WriteMode int
+
+const (
+	WriteThrough WriteMode = iota // 写穿：同步写存储后再更新内存
+	WriteBack                     // 写回：只写内存，延迟刷到存储
+)
+
+// String 返回写入策略的名称
+func (m WriteMode) String() string {
+	switch m {
+	case WriteThrough:
+		return "write-through"
+	case WriteBack:
+		return "write-back"
+	default:
+		return fmt.Sprintf("WriteMode(%d)", int(m))
+	}
+}
+
+// TieredCacheOptions 二级缓存配置选项
+type TieredCacheOptions struct {
+	Capacity      int           // 内存层容量（条目数）
+	Mode          WriteMode     // 写入策略
+	FlushInterval time.Duration // 写回模式的后台刷盘间隔，0表示不启动后台刷盘
+}
+
+// DefaultTieredCacheOptions 默认的二级缓存配置
+var DefaultTieredCacheOptions = TieredCacheOptions{
+	Capacity:      1000,
+	Mode:          WriteThrough,
+	FlushInterval: time.Second,
+}
+
+// tieredEntry 内存层的节点
+type tieredEntry struct {
+	key     string
+	value   []byte
+	dirty   bool   // 写回模式下尚未写入存储
+	version uint64 // 每次写入递增，刷盘完成后版本未变才清除 dirty
+}
+
+// TieredCache 内存LRU + 慢速存储的二级缓存，可被多个协程并发使用
+type TieredCache struct {
+	mutex      sync.Mutex
+	writeMutex sync.Mutex // 串行化所有写存储的操作
+	store      BackingStore
+	mode       WriteMode
+	capacity   int
+	cache      map[string]*list.Element // 键 -> 链表节点
+	list       *list.List               // 访问顺序，头部为最近使用
+	pending    map[string]*tieredEntry  // 已被淘汰、尚未写回存储的脏条目
+	generation uint64                   // 每次写入或删除递增
+
+	stats       StatsCounter // 内存层的访问统计
+	storeReads  uint64       // 读存储次数
+	storeWrites uint64       // 写存储次数
+	writeErrors uint64       // 写回失败次数
+
+	stopFlush chan struct{}
+	flushDone chan struct{}
+	stopOnce  sync.Once
+}
+
+// NewTieredCache 创建以 store 为慢速存储的二级缓存，写回模式且 FlushInterval 大于0时启动后台刷盘
+func NewTieredCache(store BackingStore, options ...TieredCacheOptions) *TieredCache {
+	opts := DefaultTieredCacheOptions
+	if len(options) > 0 {
+		opts = options[0]
+	}
+	c := &TieredCache{
+		store:     store,
+		mode:      opts.Mode,
+		capacity:  max(opts.Capacity, 1),
+		cache:     make(map[string]*list.Element),
+		list:      list.New(),
+		pending:   make(map[string]*tieredEntry),
+		stopFlush: make(chan struct{}),
+		flushDone: make(chan struct{}),
+	}
+	if opts.Mode == WriteBack && opts.FlushInterval > 0 {
+		go c.flushLoop(opts.FlushInterval)
+	} else {
+		close(c.flushDone)
+	}
+	return c
+}
+
+// flushLoop 后台周期刷盘
+func (c *TieredCache) flushLoop(interval time.Duration) {
+	defer close(c.flushDone)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.Flush()
+		case <-c.stopFlush:
+			return
+		}
+	}
+}
+
+// Get 读取键的值：先查内存，再查待写回的条目，最后读存储并回填内存；键不存在时返回 ErrStoreKeyNotFound
+func (c *TieredCache) Get(key string) ([]byte, error) {
+	c.mutex.Lock()
+	if element, ok := c.cache[key]; ok {
+		c.list.MoveToFront(element)
+		value := element.Value.(*tieredEntry).value
+		c.mutex.Unlock()
+		c.stats.RecordHit()
+		return value, nil
+	}
+	if entry, ok := c.pending[key]; ok {
+		value := entry.value
+		c.mutex.Unlock()
+		c.stats.RecordHit()
+		return value, nil
+	}
+	generation := c.generation
+	c.mutex.Unlock()
+	c.stats.RecordMiss()
+
+	atomic.AddUint64(&c.storeReads, 1)
+	value, err := c.store.Load(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// 读存储期间有写入或删除时放弃回填，读到的可能已经是旧值
+	var evicted *tieredEntry
+	c.mutex.Lock()
+	if c.generation == generation {
+		evicted = c.insertLocked(&tieredEntry{key: key, value: value})
+	}
+	c.mutex.Unlock()
+	c.writeBack(evicted)
+	return value, nil
+}
+
+// Put 写入键值对；写穿模式下存储写入失败时返回错误且不更新内存，写回模式下只有淘汰的脏条目写回失败时才返回错误
+func (c *TieredCache) Put(key string, value []byte) error {
+	if c.mode == WriteThrough {
+		c.writeMutex.Lock()
+		defer c.writeMutex.Unlock()
+		atomic.AddUint64(&c.storeWrites, 1)
+		if err := c.store.Store(key, value); err != nil {
+			return fmt.Errorf("写入存储失败 %s: %w", key, err)
+		}
+	}
+
+	c.mutex.Lock()
+	c.generation++
+	delete(c.pending, key) // 新值取代还没写回的旧值
+	var evicted *tieredEntry
+	if element, ok := c.cache[key]; ok {
+		entry := element.Value.(*tieredEntry)
+		entry.value = value
+		entry.dirty = c.mode == WriteBack
+		entry.version++
+		c.list.MoveToFront(element)
+	} else {
+		evicted = c.insertLocked(&tieredEntry{key: key, value: value, dirty: c.mode == WriteBack})
+	}
+	c.mutex.Unlock()
+
+	if c.mode == WriteThrough {
+		return nil // 写穿模式下内存中没有脏条目，淘汰无需写回
+	}
+	return c.writeBack(evicted)
+}
+
+// insertLocked 把新节点放到链表头部，超出容量时淘汰尾部节点；被淘汰的是脏节点时放入 pending 并返回它，调用方需持有锁
+func (c *TieredCache) insertLocked(entry *tieredEntry) *tieredEntry {
+	c.cache[entry.key] = c.list.PushFront(entry)
+	if c.list.Len() <= c.capacity {
+		return nil
+	}
+
+	oldest := c.list.Back()
+	c.list.Remove(oldest)
+	old := oldest.Value.(*tieredEntry)
+	delete(c.cache, old.key)
+	c.stats.RecordEvictions(1)
+	if !old.dirty {
+		return nil
+	}
+	c.pending[old.key] = old
+	return old
+}
+
+// writeBack 把被淘汰的脏条目写回存储；写回前该键已被重新写入或删除时跳过，写回失败时留在 pending 中等待下次刷盘
+func (c *TieredCache) writeBack(entry *tieredEntry) error {
+	if entry == nil {
+		return nil
+	}
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	c.mutex.Lock()
+	current := c.pending[entry.key] == entry
+	c.mutex.Unlock()
+	if !current {
+		return nil
+	}
+
+	atomic.AddUint64(&c.storeWrites, 1)
+	if err := c.store.Store(entry.key, entry.value); err != nil {
+		atomic.AddUint64(&c.writeErrors, 1)
+		return fmt.Errorf("写回淘汰的脏数据失败 %s: %w", entry.key, err)
+	}
+	c.mutex.Lock()
+	if c.pending[entry.key] == entry {
+		delete(c.pending, entry.key)
+	}
+	c.mutex.Unlock()
+	return nil
+}
+
+// Delete 从存储和内存中删除键，存储删除失败时内存不变
+func (c *TieredCache) Delete(key string) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	if err := c.store.Delete(key); err != nil {
+		return fmt.Errorf("从存储删除失败 %s: %w", key, err)
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.generation++
+	if element, ok := c.cache[key]; ok {
+		c.list.Remove(element)
+		delete(c.cache, key)
+	}
+	delete(c.pending, key)
+	return nil
+}
+
+// Flush 把所有脏条目写入存储，返回写入失败的错误（多个错误合并返回），失败的条目保持为脏
+func (c *TieredCache) Flush() error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	type flushItem struct {
+		entry   *tieredEntry
+		value   []byte
+		version uint64
+	}
+	c.mutex.Lock()
+	items := make([]flushItem, 0, len(c.pending))
+	for _, entry := range c.pending {
+		items = append(items, flushItem{entry: entry, value: entry.value, version: entry.version})
+	}
+	for e := c.list.Back(); e != nil; e = e.Prev() {
+		if entry := e.Value.(*tieredEntry); entry.dirty {
+			items = append(items, flushItem{entry: entry, value: entry.value, version: entry.version})
+		}
+	}
+	c.mutex.Unlock()
+
+	var errs []error
+	flushed := make([]flushItem, 0, len(items))
+	for _, item := range items {
+		atomic.AddUint64(&c.storeWrites, 1)
+		if err := c.store.Store(item.entry.key, item.value); err != nil {
+			atomic.AddUint64(&c.writeErrors, 1)
+			errs = append(errs, fmt.Errorf("刷盘失败 %s: %w", item.entry.key, err))
+			continue
+		}
+		flushed = append(flushed, item)
+	}
+
+	// 刷盘期间被重新写入的条目版本已经变化，保持为脏
+	c.mutex.Lock()
+	for _, item := range flushed {
+		if c.pending[item.entry.key] == item.entry {
+			delete(c.pending, item.entry.key)
+		} else if item.entry.version == item.version {
+			item.entry.dirty = false
+		}
+	}
+	c.mutex.Unlock()
+	return errors.Join(errs...)
+}
+
+// Close 停止后台刷盘并把剩余的脏条目写入存储，可以重复调用
+func (c *TieredCache) Close() error {
+	c.stopOnce.Do(func() { close(c.stopFlush) })
+	<-c.flushDone
+	return c.Flush()
+}
+
+// Size 返回内存层的条目数量
+func (c *TieredCache) Size() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.cache)
+}
+
+// DirtyCount 返回尚未写入存储的条目数量（包括已被淘汰、等待写回的条目）
+func (c *TieredCache) DirtyCount() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	count := len(c.pending)
+	for _, element := range c.cache {
+		if element.Value.(*tieredEntry).dirty {
+			count++
+		}
+	}
+	return count
+}
+
+// CacheStats 返回内存层的命中、未命中和淘汰次数的快照
+func (c *TieredCache) CacheStats() CacheStats {
+	return c.stats.Snapshot()
+}
+
+// Stats 返回内存层的访问统计以及读写存储的次数
+func (c *TieredCache) Stats() map[string]interface{} {
+	stats := c.stats.Snapshot().Map()
+	stats["capacity"] = c.capacity
+	stats["size"] = c.Size()
+	stats["mode"] = c.mode.String()
+	stats["dirty"] = c.DirtyCount()
+	stats["storeReads"] = atomic.LoadUint64(&c.storeReads)
+	stats["storeWrites"] = atomic.LoadUint64(&c.storeWrites)
+	stats["writeErrors"] = atomic.LoadUint64(&c.writeErrors)
+	return stats
+}
+
+// MemoryUsage 返回内存层深度估算的内存占用（字节）
+func (c *TieredCache) MemoryUsage() int64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return sizeof.Of(c.cache) + sizeof.Of(c.list) + sizeof.Of(c.pending)
+}
+
+// DirStore 把每个键保存为目录下一个文件的慢速存储，文件名为键的十六进制编码
+type DirStore struct {
+	dir string
+}
+
+// NewDirStore 创建以 dir 为根目录的存储，目录不存在时自动创建
+func NewDirStore(dir string) (*DirStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建存储目录失败: %w", err)
+	}
+	return &DirStore{dir: dir}, nil
+}
+
+// path 返回键对应的文件路径
+func (s *DirStore) path(key string) string {
+	return filepath.Join(s.dir, hex.EncodeToString([]byte(key)))
+}
+
+// Load 读取键对应的文件
+func (s *DirStore) Load(key string) ([]byte, error) {
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrStoreKeyNotFound, key)
+	}
+	return data, err
+}
+
+// Store 先写临时文件再重命名，进程在写入途中崩溃也不会留下半个文件
+func (s *DirStore) Store(key string, value []byte) error {
+	file, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmp := file.Name()
+	if _, err := file.Write(value); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, s.path(key))
+}
+
+// Delete 删除键对应的文件
+func (s *DirStore) Delete(key string) error {
+	if err := os.Remove(s.path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// countingStore 统计读写次数的存储包装，示例中用来观察两种写入策略对存储的压力
+type countingStore struct {
+	BackingStore
+	writes atomic.Int64
+}
+
+func (s *countingStore) Store(key string, value []byte) error {
+	s.writes.Add(1)
+	return s.BackingStore.Store(key, value)
+}
+
+// 场景示例：文章阅读数计数器，内存层容量为3，底层是磁盘目录
+func TieredCacheDemo() {
+	fmt.Println("二级缓存示例 (内存LRU容量=3 + 磁盘目录):")
+
+	dir, err := os.MkdirTemp("", "tiered-cache")
+	if err != nil {
+		fmt.Printf("创建临时目录失败: %v\n", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	// 5篇文章，热门文章的阅读数被频繁更新
+	articles := []string{"article:1", "article:1", "article:2", "article:1", "article:3",
+		"article:2", "article:1", "article:4", "article:1", "article:5", "article:1", "article:2"}
+	incr := func(cache *TieredCache, key string) {
+		views := 0
+		if value, err := cache.Get(key); err == nil {
+			fmt.Sscanf(string(value), "%d", &views)
+		} else if !errors.Is(err, ErrStoreKeyNotFound) {
+			fmt.Printf("读取 %s 失败: %v\n", key, err)
+			return
+		}
+		if err := cache.Put(key, []byte(fmt.Sprint(views+1))); err != nil {
+			fmt.Printf("写入 %s 失败: %v\n", key, err)
+		}
+	}
+
+	for _, mode := range []WriteMode{WriteThrough, WriteBack} {
+		disk, err := NewDirStore(filepath.Join(dir, mode.String()))
+		if err != nil {
+			fmt.Printf("创建存储失败: %v\n", err)
+			return
+		}
+		store := &countingStore{BackingStore: disk}
+		cache := NewTieredCache(store, TieredCacheOptions{Capacity: 3, Mode: mode})
+
+		for _, key := range articles {
+			incr(cache, key)
+		}
+		fmt.Printf("\n=== %s ===\n", mode)
+		fmt.Printf("%d 次更新后: 磁盘写入 %d 次，尚未落盘 %d 条\n", len(articles), store.writes.Load(), cache.DirtyCount())
+		if err := cache.Close(); err != nil {
+			fmt.Printf("刷盘失败: %v\n", err)
+		}
+		views, _ := disk.Load("article:1")
+		fmt.Printf("关闭时刷盘后: 磁盘写入 %d 次，磁盘上 article:1 的阅读数 = %s\n", store.writes.Load(), views)
+	}
+}
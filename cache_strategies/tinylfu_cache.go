@@ -0,0 +1,431 @@
+package cache_strategies
+
+/*
+W-TinyLFU 准入策略
+
+原理：
+LRU、SLRU 等策略只决定"淘汰谁"，却从不拒绝新数据：任何一个只会被访问一次的新键都能挤掉一个已知的热点。
+TinyLFU（Einziger 2015）增加了"准入"这一步：缓存满时，新来的候选者要和将被淘汰的牺牲者比较历史访问频率，
+只有候选者更热才能进入缓存，否则直接丢弃候选者，牺牲者保留。
+- 频率由 Count-Min Sketch 近似统计：d 行计数器，每个键在每行映射到一个计数器，估计值取 d 个计数器的最小值，
+  每个计数器只有4位（最大15），内存只有精确计数的很小一部分，且包含已经不在缓存中的键的历史
+- 保鲜（aging）：总访问次数达到采样上限后所有计数器减半，旧的热点会逐渐冷却，适应访问模式的变化
+- W-TinyLFU（Caffeine 使用的方案）在准入过滤前加一个很小的窗口 LRU（默认占总容量1%），
+  新键先进入窗口积累频率，被挤出窗口时再参加准入比较，避免突发的新热点因频率为0而被一概拒绝
+
+关键特点：
+1. 新键不能凭一次访问挤掉热点，对扫描和一次性访问有很强的抵抗力
+2. 频率统计包括缓存外的键，能识别"反复被淘汰又反复被访问"的键
+3. 主缓存可替换：默认使用SLRU，也可以放在任何实现 AdmissionTarget 的缓存（如主包中的LRU）前面
+4. 所有操作都是O(1)，频率统计的内存与容量成正比而与键空间大小无关
+
+实现方式：
+- FrequencySketch：4行 uint8 计数器（上限15），每行约为容量的8倍并取2的幂，行之间使用不同的种子混合哈希值
+- 窗口是一个普通的LRU链表；主缓存通过 Victim 报告下一个将被淘汰的键，准入比较后由本结构决定淘汰谁
+
+应用场景：
+- 访问分布偏斜且夹杂扫描的通用缓存（数据库查询缓存、对象缓存）
+- Caffeine、Ristretto 等高性能缓存库的默认策略
+
+优缺点：
+- 优点：命中率在多数真实负载上接近最优，额外内存很小
+- 缺点：实现比LRU复杂；频率估计有误差（只会高估）；访问模式剧烈变化时需要等待保鲜才能适应
+
+以下实现了 Count-Min 频率统计和 W-TinyLFU 缓存，主缓存默认为SLRU。
+*/
+
+import (
+	"container/list"
+	"fmt"
+	"math/bits"
+	"math/rand"
+
+	"github.com/strive/scenario/hashing"
+	"github.com/strive/scenario/sizeof"
+)
+
+// W-TinyLFU 参数常量
+const (
+	DefaultWindowRatio = 0.01 // 窗口默认占总容量的比例
+	sketchDepth        = 4    // Count-Min Sketch 的行数
+	sketchMaxCount     = 15   // 4位计数器的上限
+	sketchSampleFactor = 10   // 采样上限 = 容量 × sketchSampleFactor
+	sketchWidthFactor  = 8    // 每行计数器数 ≈ 容量 × sketchWidthFactor，减少哈希冲突
+)
+
+// FrequencySketch 带保鲜的 Count-Min Sketch，估计键的近期访问频率
+type FrequencySketch struct {
+	table     [sketchDepth][]uint8
+	seeds     [sketchDepth]uint64
+	mask      uint64
+	additions int // 自上次保鲜以来的计数次数
+	sample    int // 达到此次数时所有计数器减半
+	resets    int // 保鲜次数
+}
+
+// NewFrequencySketch 创建频率统计，capacity 为缓存容量
+func NewFrequencySketch(capacity int) *FrequencySketch {
+	if capacity < 16 {
+		capacity = 16
+	}
+	width := 1 << bits.Len(uint(capacity*sketchWidthFactor-1)) // 2的幂，便于用掩码取下标
+	s := &FrequencySketch{
+		mask:   uint64(width - 1),
+		sample: capacity * sketchSampleFactor,
+		seeds:  [sketchDepth]uint64{0xc3a5c85c97cb3127, 0xb492b66fbe98f273, 0x9ae16a3b2f90404f, 0xcbf29ce484222325},
+	}
+	for i := range s.table {
+		s.table[i] = make([]uint8, width)
+	}
+	return s
+}
+
+// index 返回哈希值在第 row 行的计数器下标
+func (s *FrequencySketch) index(hash uint64, row int) uint64 {
+	h := (hash ^ s.seeds[row]) * 0x9e3779b97f4a7c15
+	return (h ^ (h >> 32)) & s.mask
+}
+
+// Increment 记录一次访问，计数器已达上限时不再增加
+func (s *FrequencySketch) Increment(hash uint64) {
+	added := false
+	for row := range s.table {
+		i := s.index(hash, row)
+		if s.table[row][i] < sketchMaxCount {
+			s.table[row][i]++
+			added = true
+		}
+	}
+	if added {
+		s.additions++
+		if s.additions >= s.sample {
+			s.reset()
+		}
+	}
+}
+
+// Estimate 估计访问频率（d 个计数器中的最小值）
+func (s *FrequencySketch) Estimate(hash uint64) int {
+	estimate := sketchMaxCount
+	for row := range s.table {
+		if c := int(s.table[row][s.index(hash, row)]); c < estimate {
+			estimate = c
+		}
+	}
+	return estimate
+}
+
+// reset 保鲜：所有计数器减半
+func (s *FrequencySketch) reset() {
+	for row := range s.table {
+		for i := range s.table[row] {
+			s.table[row][i] >>= 1
+		}
+	}
+	s.additions /= 2
+	s.resets++
+}
+
+// AdmissionTarget 可以放在 TinyLFU 准入过滤之后的主缓存
+type AdmissionTarget[K comparable, V any] interface {
+	Cache[K, V]
+	Resizable
+	Victim() (K, bool)   // 缓存满时下一个将被淘汰的键
+	Contains(key K) bool // 判断键是否存在，不影响淘汰顺序
+}
+
+// WTinyLFUEntry 窗口中的节点
+type WTinyLFUEntry[K comparable, V any] struct {
+	Key   K
+	Value V
+}
+
+// WTinyLFU 泛型 W-TinyLFU 缓存结构
+type WTinyLFU[K comparable, V any] struct {
+	windowCap   int                   // 窗口容量
+	windowRatio float64               // 窗口占总容量的比例，Resize 时按它重新划分
+	windowOnly  bool                  // 总容量为1时只有窗口，主缓存不接收任何键
+	window      *list.List            // 窗口LRU：新键先进入这里
+	windowMap   map[K]*list.Element   // 窗口中的键 -> 链表节点
+	main        AdmissionTarget[K, V] // 主缓存
+	sketch      *FrequencySketch      // 频率统计
+	hasher      hashing.Hasher        // 计算键的哈希值
+	admitted    int                   // 候选者胜出、进入主缓存的次数
+	rejected    int                   // 候选者频率不够、被丢弃的次数
+	stats       StatsCounter          // 访问统计（淘汰包括被拒绝的候选者和被替换的牺牲者）
+}
+
+// WTinyLFUCache 字符串键、任意值的 W-TinyLFU 缓存
+type WTinyLFUCache = WTinyLFU[string, interface{}]
+
+// NewWTinyLFUCache 创建指定容量的 W-TinyLFU 缓存
+func NewWTinyLFUCache(capacity int) *WTinyLFUCache {
+	return NewWTinyLFU[string, interface{}](capacity)
+}
+
+// NewWTinyLFU 创建指定容量的泛型 W-TinyLFU 缓存，窗口占1%（至少1个），主缓存为保护段占80%的SLRU；
+// 容量为1时只有窗口、主缓存为空，小于1时按1处理
+func NewWTinyLFU[K comparable, V any](capacity int) *WTinyLFU[K, V] {
+	if capacity <= 1 {
+		c := NewWTinyLFUWith[K, V](1, NewSLRU[K, V](1, DefaultProtectedRatio))
+		c.windowOnly = true
+		c.windowRatio = DefaultWindowRatio
+		return c
+	}
+	window := max(int(float64(capacity)*DefaultWindowRatio), 1)
+	c := NewWTinyLFUWith[K, V](window, NewSLRU[K, V](capacity-window, DefaultProtectedRatio))
+	c.windowRatio = DefaultWindowRatio
+	return c
+}
+
+// NewWTinyLFUWith 在任意主缓存前加上容量为 windowCapacity 的窗口和 TinyLFU 准入过滤
+func NewWTinyLFUWith[K comparable, V any](windowCapacity int, main AdmissionTarget[K, V]) *WTinyLFU[K, V] {
+	if windowCapacity < 1 {
+		windowCapacity = 1
+	}
+	return &WTinyLFU[K, V]{
+		windowCap:   windowCapacity,
+		windowRatio: float64(windowCapacity) / float64(windowCapacity+main.Capacity()),
+		window:      list.New(),
+		windowMap:   make(map[K]*list.Element),
+		main:        main,
+		sketch:      NewFrequencySketch(windowCapacity + main.Capacity()),
+		hasher:      hashing.NewFNV1a(0),
+	}
+}
+
+// hash 计算键的哈希值
+func (c *WTinyLFU[K, V]) hash(key K) uint64 {
+	return hashing.SumString(c.hasher, keyString(key))
+}
+
+// Get 获取缓存中的值，不存在返回零值和false；命中与否都会计入频率
+func (c *WTinyLFU[K, V]) Get(key K) (V, bool) {
+	c.sketch.Increment(c.hash(key))
+
+	if element, exists := c.windowMap[key]; exists {
+		c.stats.RecordHit()
+		c.window.MoveToFront(element)
+		return element.Value.(*WTinyLFUEntry[K, V]).Value, true
+	}
+	if value, ok := c.main.Get(key); ok {
+		c.stats.RecordHit()
+		return value, true
+	}
+	c.stats.RecordMiss()
+	var zero V
+	return zero, false
+}
+
+// Put 插入或更新缓存中的键值对：新键进入窗口，被挤出窗口的键参加准入比较
+func (c *WTinyLFU[K, V]) Put(key K, value V) {
+	if element, exists := c.windowMap[key]; exists {
+		element.Value.(*WTinyLFUEntry[K, V]).Value = value
+		c.window.MoveToFront(element)
+		return
+	}
+	if c.main.Contains(key) {
+		c.main.Put(key, value)
+		return
+	}
+
+	c.windowMap[key] = c.window.PushFront(&WTinyLFUEntry[K, V]{Key: key, Value: value})
+	for c.window.Len() > c.windowCap {
+		oldest := c.window.Back()
+		c.window.Remove(oldest)
+		candidate := oldest.Value.(*WTinyLFUEntry[K, V])
+		delete(c.windowMap, candidate.Key)
+		c.admit(candidate)
+	}
+}
+
+// admit 准入比较：主缓存未满时直接进入，否则候选者频率高于牺牲者才替换它；只有窗口时直接淘汰候选者
+func (c *WTinyLFU[K, V]) admit(candidate *WTinyLFUEntry[K, V]) {
+	if c.windowOnly {
+		c.stats.RecordEvictions(1)
+		return
+	}
+	if c.main.Size() < c.main.Capacity() {
+		c.main.Put(candidate.Key, candidate.Value)
+		return
+	}
+	victim, ok := c.main.Victim()
+	if ok && c.sketch.Estimate(c.hash(candidate.Key)) <= c.sketch.Estimate(c.hash(victim)) {
+		c.rejected++
+		c.stats.RecordEvictions(1)
+		return
+	}
+	if ok {
+		c.main.Remove(victim)
+		c.stats.RecordEvictions(1)
+	}
+	c.main.Put(candidate.Key, candidate.Value)
+	c.admitted++
+}
+
+// Remove 从缓存中删除指定键
+func (c *WTinyLFU[K, V]) Remove(key K) bool {
+	if element, exists := c.windowMap[key]; exists {
+		c.window.Remove(element)
+		delete(c.windowMap, key)
+		return true
+	}
+	return c.main.Remove(key)
+}
+
+// Size 返回当前缓存中的元素数量
+func (c *WTinyLFU[K, V]) Size() int {
+	return c.window.Len() + c.main.Size()
+}
+
+// Capacity 返回最大容量（窗口与主缓存之和）
+func (c *WTinyLFU[K, V]) Capacity() int {
+	return c.windowCap + c.mainCapacity()
+}
+
+// mainCapacity 返回主缓存容量，只有窗口时为0
+func (c *WTinyLFU[K, V]) mainCapacity() int {
+	if c.windowOnly {
+		return 0
+	}
+	return c.main.Capacity()
+}
+
+// Resize 按原有窗口比例调整总容量，返回淘汰的数量；容量为1时只保留窗口，小于1时按1处理；频率统计保持不变
+func (c *WTinyLFU[K, V]) Resize(capacity int) int {
+	evicted := 0
+	if capacity <= 1 {
+		c.windowOnly = true
+		c.windowCap = 1
+		evicted = c.main.Size()
+		c.main.Clear()
+	} else {
+		c.windowOnly = false
+		c.windowCap = max(int(float64(capacity)*c.windowRatio), 1)
+		evicted = c.main.Resize(capacity - c.windowCap)
+	}
+	for c.window.Len() > c.windowCap {
+		oldest := c.window.Back()
+		c.window.Remove(oldest)
+		delete(c.windowMap, oldest.Value.(*WTinyLFUEntry[K, V]).Key)
+		evicted++
+	}
+	c.stats.RecordEvictions(evicted)
+	return evicted
+}
+
+// Keys 返回缓存中所有键的列表（先窗口后主缓存）
+func (c *WTinyLFU[K, V]) Keys() []K {
+	keys := make([]K, 0, c.Size())
+	for e := c.window.Front(); e != nil; e = e.Next() {
+		keys = append(keys, e.Value.(*WTinyLFUEntry[K, V]).Key)
+	}
+	return append(keys, c.main.Keys()...)
+}
+
+// Clear 清空缓存，频率统计保留
+func (c *WTinyLFU[K, V]) Clear() {
+	c.window = list.New()
+	c.windowMap = make(map[K]*list.Element)
+	c.main.Clear()
+}
+
+// Frequency 返回键的估计访问频率
+func (c *WTinyLFU[K, V]) Frequency(key K) int {
+	return c.sketch.Estimate(c.hash(key))
+}
+
+// CacheStats 返回命中、未命中、淘汰和过期次数的快照
+func (c *WTinyLFU[K, V]) CacheStats() CacheStats {
+	return c.stats.Snapshot()
+}
+
+// Stats 返回窗口、主缓存的占用情况和访问统计
+func (c *WTinyLFU[K, V]) Stats() map[string]interface{} {
+	stats := c.stats.Snapshot().Map()
+	stats["capacity"] = c.Capacity()
+	stats["size"] = c.Size()
+	stats["windowSize"] = c.window.Len()
+	stats["windowCapacity"] = c.windowCap
+	stats["mainSize"] = c.main.Size()
+	stats["mainCapacity"] = c.mainCapacity()
+	stats["admitted"] = c.admitted
+	stats["rejected"] = c.rejected
+	stats["sketchResets"] = c.sketch.resets
+	return stats
+}
+
+// MemoryUsage 返回深度估算的内存占用（字节）
+func (c *WTinyLFU[K, V]) MemoryUsage() int64 {
+	return sizeof.Of(c)
+}
+
+// 场景示例：热点商品缓存遭遇爬虫遍历
+func WTinyLFUCacheDemo() {
+	fmt.Println("W-TinyLFU 准入策略示例 (容量=100):")
+
+	cache := NewWTinyLFUCache(100)
+	slru := NewSLRUCache(100, DefaultProtectedRatio)
+	caches := []Cache[string, interface{}]{cache, slru}
+	var browseHits [2]int
+	browses := 0
+
+	// 访问一个键，返回各缓存是否命中；未命中时回填
+	access := func(key string) (hit [2]bool) {
+		for i, c := range caches {
+			if _, hit[i] = c.Get(key); !hit[i] {
+				c.Put(key, "详情:"+key)
+			}
+		}
+		return hit
+	}
+
+	// 300 个商品的访问高度偏斜：编号越小越热门
+	rng := rand.New(rand.NewSource(7))
+	browse := func(n int, record bool) {
+		for i := 0; i < n; i++ {
+			r := rng.Float64()
+			hit := access(fmt.Sprintf("hot-%03d", int(r*r*r*300)))
+			if record {
+				browses++
+				for j := range hit {
+					if hit[j] {
+						browseHits[j]++
+					}
+				}
+			}
+		}
+	}
+	browse(5000, false)
+	fmt.Printf("\n热点预热后: hot-000 的估计频率 %d，hot-299 的估计频率 %d，窗口 %d 个键，主缓存 %d 个键\n",
+		cache.Frequency("hot-000"), cache.Frequency("hot-299"), cache.Stats()["windowSize"], cache.Stats()["mainSize"])
+
+	// 爬虫遍历 6000 个只访问一次的冷门商品，期间用户浏览照常进行
+	for i := 0; i < 6000; i++ {
+		access(fmt.Sprintf("crawl-%04d", i))
+		if i%2 == 1 {
+			browse(1, true)
+		}
+	}
+
+	// 爬虫结束后统计最热的50个商品还有多少在缓存中
+	survived := func(c Cache[string, interface{}]) int {
+		keys := make(map[string]bool)
+		for _, key := range c.Keys() {
+			keys[key] = true
+		}
+		count := 0
+		for i := 0; i < 50; i++ {
+			if keys[fmt.Sprintf("hot-%03d", i)] {
+				count++
+			}
+		}
+		return count
+	}
+	stats := cache.Stats()
+	fmt.Println("爬虫期间用户浏览的命中率:")
+	fmt.Printf("  W-TinyLFU: %.1f%%，最热的50个商品留存 %d 个，准入 %d 次，拒绝 %d 次\n",
+		float64(browseHits[0])/float64(browses)*100, survived(cache), stats["admitted"], stats["rejected"])
+	fmt.Printf("  SLRU:      %.1f%%，最热的50个商品留存 %d 个\n",
+		float64(browseHits[1])/float64(browses)*100, survived(slru))
+}
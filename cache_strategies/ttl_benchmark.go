@@ -0,0 +1,91 @@
+package cache_strategies
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/strive/scenario/report"
+)
+
+// DefaultTTLCleanupRatios 默认测量的过期比例
+var DefaultTTLCleanupRatios = []float64{0, 0.001, 0.01, 0.1}
+
+// cleanupMetric 返回某个过期比例的清理耗时指标名
+func cleanupMetric(ratio float64) string {
+	return fmt.Sprintf("过期%g%%清理(ms)", ratio*100)
+}
+
+// ttlBenchmarkExpireTime 第 i 个条目的过期时间：前 ratio 部分已过期，其余一小时后过期
+func ttlBenchmarkExpireTime(i, entries int, ratio float64, now time.Time) time.Time {
+	if i < int(float64(entries)*ratio) {
+		return now.Add(-time.Second)
+	}
+	return now.Add(time.Hour + time.Duration(i)*time.Millisecond)
+}
+
+// scanCleanup 遍历全部条目删除已过期的条目，即改用过期堆之前 Cleanup 的做法，返回删除的条目数
+func scanCleanup(items map[int]*TTLItem[int, int]) int {
+	removed := 0
+	now := time.Now()
+	for key, item := range items {
+		if !item.ExpireTime.IsZero() && now.After(item.ExpireTime) {
+			delete(items, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// TTLCleanupBenchmark 对比全表扫描和过期堆在 entries 个条目、不同过期比例下单次清理的耗时
+func TTLCleanupBenchmark(entries int, ratios []float64) *report.Comparison {
+	metrics := make([]report.Metric, 0, len(ratios))
+	for _, ratio := range ratios {
+		metrics = append(metrics, report.Metric{Name: cleanupMetric(ratio), LowerIsBetter: true, Precision: 3})
+	}
+	input := fmt.Sprintf("%d 个条目，每种过期比例单独写入后执行一次清理；总耗时包含写入", entries)
+	comparison := report.NewComparison("TTL缓存过期清理对比", input, metrics...)
+
+	comparison.Measure("全表扫描", func() (map[string]float64, error) {
+		values := make(map[string]float64, len(ratios))
+		for _, ratio := range ratios {
+			now := time.Now()
+			items := make(map[int]*TTLItem[int, int], entries)
+			for i := 0; i < entries; i++ {
+				items[i] = &TTLItem[int, int]{Key: i, Value: i, ExpireTime: ttlBenchmarkExpireTime(i, entries, ratio, now)}
+			}
+			start := time.Now()
+			removed := scanCleanup(items)
+			values[cleanupMetric(ratio)] = float64(time.Since(start).Microseconds()) / 1000
+			if expected := int(float64(entries) * ratio); removed != expected {
+				return values, fmt.Errorf("过期比例 %g: 删除 %d 个条目，应为 %d 个", ratio, removed, expected)
+			}
+		}
+		return values, nil
+	})
+
+	comparison.Measure("过期堆", func() (map[string]float64, error) {
+		values := make(map[string]float64, len(ratios))
+		for _, ratio := range ratios {
+			now := time.Now()
+			cache := NewTTL[int, int](TTLCacheOptions{}) // 不启动后台清理，只测量手动调用的 Cleanup
+			for i := 0; i < entries; i++ {
+				cache.set(i, i, ttlBenchmarkExpireTime(i, entries, ratio, now))
+			}
+			start := time.Now()
+			cache.Cleanup()
+			values[cleanupMetric(ratio)] = float64(time.Since(start).Microseconds()) / 1000
+			if expected := entries - int(float64(entries)*ratio); cache.Size() != expected {
+				return values, fmt.Errorf("过期比例 %g: 剩余 %d 个条目，应为 %d 个", ratio, cache.Size(), expected)
+			}
+		}
+		return values, nil
+	})
+	return comparison
+}
+
+// 场景示例：100万个会话中只有少量到期，后台清理不应每次都扫描全部会话
+func TTLCleanupBenchmarkDemo() {
+	fmt.Println("TTL缓存过期清理基准 (100万个条目):")
+	TTLCleanupBenchmark(1000000, DefaultTTLCleanupRatios).Write(os.Stdout, report.FormatMarkdown)
+}
@@ -17,8 +17,16 @@ TTL缓存为每个缓存项设置一个过期时间，当缓存项被访问时
 
 实现方式：
 - 哈希表存储缓存项及其元数据(过期时间等)
-- 可选的定时器进行周期性清理
+- 可选的周期性清理，由进程内共享的时间轮（concurrency.SharedTimingWheel）定时触发，不再为每个缓存启动一个清理协程
+- 有过期时间的条目同时放入按过期时间排序的最小堆，清理时只需不断弹出堆顶已过期的条目，
+  代价与过期条目数成正比（O(k log n)），而不是每次扫描全部条目；
+  大部分条目同时过期时逐个出堆反而比扫描一遍更慢，见 TTLCleanupBenchmark
 - 访问时进行过期检查
+- 写入、删除、过期时发布键空间事件，订阅者无需轮询即可感知会话过期
+- 过期条目被删除后调用 OnExpire 注册的回调，回调拿到值本身，可以释放值持有的资源
+- 设置 MaxWeight 后按条目权重之和限制内存，超出时先淘汰最早过期的条目
+- 设置 NegativeTTL 后 GetOrLoad 会把加载函数返回的 ErrNotFound 缓存一段时间，防止缓存穿透（见 negative_cache.go）
+- 设置 RefreshAhead 后，GetOrLoad 读到接近过期的条目时在后台重新加载，热点键在过期前就被替换，读取不会因过期而阻塞在回源上
 
 应用场景：
 - 会话管理（Session缓存）
@@ -29,42 +37,73 @@ TTL缓存为每个缓存项设置一个过期时间，当缓存项被访问时
 
 优缺点：
 - 优点：自动管理数据新鲜度，不需要手动清理
-- 缺点：需要额外存储过期时间信息，检查过期会有小的性能开销
+- 缺点：需要额外存储过期时间信息，检查过期会有小的性能开销；写入和删除要维护过期堆，代价为 O(log n)
 
 以下实现了一个带TTL功能的缓存，支持懒惰过期和周期性清理。
 */
 
 import (
+	"container/heap"
+	"errors"
 	"fmt"
+	"io"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/strive/scenario/concurrency"
+	"github.com/strive/scenario/keyspace"
+	"github.com/strive/scenario/sizeof"
 )
 
-// TTLCacheItem TTL缓存项结构
-type TTLCacheItem struct {
-	Key        string
-	Value      interface{}
+// TTLItem TTL缓存项结构
+type TTLItem[K comparable, V any] struct {
+	Key        K
+	Value      V
 	ExpireTime time.Time // 过期时间点
+	weight     int64     // 写入时计算的权重
+	index      int       // 在过期堆中的下标，不在堆中时为-1
+	negative   bool      // 记录的是"数据不存在"，Value 为零值
+	refreshAt  time.Time // 此后被 GetOrLoad 读到时触发后台刷新，零值表示不提前刷新
+	refreshing atomic.Bool
 }
 
 // IsExpired 检查缓存项是否已过期
-func (item *TTLCacheItem) IsExpired() bool {
+func (item *TTLItem[K, V]) IsExpired() bool {
 	return !item.ExpireTime.IsZero() && time.Now().After(item.ExpireTime)
 }
 
-// TTLCache TTL缓存结构
-type TTLCache struct {
-	items           map[string]*TTLCacheItem // 缓存项
-	mutex           sync.RWMutex             // 读写锁
-	defaultTTL      time.Duration            // 默认过期时间
-	cleanupInterval time.Duration            // 清理间隔
-	stopCleanup     chan bool                // 停止清理的信号
+// TTL 泛型TTL缓存结构
+type TTL[K comparable, V any] struct {
+	items           map[K]*TTLItem[K, V]    // 缓存项
+	expiry          ttlHeap[K, V]           // 按过期时间排序的最小堆，不包含永不过期的条目
+	mutex           sync.RWMutex            // 读写锁
+	defaultTTL      time.Duration           // 默认过期时间
+	cleanupInterval time.Duration           // 清理间隔
+	cleanupTimer    *concurrency.WheelTimer // 共享时间轮上的周期清理定时器，未启用清理时为 nil
+	stopOnce        sync.Once               // 保证只停止一次
+	events          *keyspace.Notifier      // 键空间事件
+	stats           StatsCounter            // 访问统计
+	maxWeight       int64                   // 权重上限，0表示不限制
+	weight          int64                   // 当前总权重
+	weigher         Weigher[K, V]           // 权重函数
+	negativeTTL     time.Duration           // 缓存"不存在"的时长
+	refreshAhead    float64                 // 经过 TTL 的这一比例后触发后台刷新
+	refreshes       atomic.Int64            // 后台刷新成功次数
+	refreshFailures atomic.Int64            // 后台刷新失败次数
+	onExpire        EvictionListeners[K, V]
+	onEvict         EvictionListeners[K, V]
+	loads           LoadGroup[K, V] // GetOrLoad 正在进行的加载
 }
 
 // TTLCacheOptions TTL缓存配置选项
 type TTLCacheOptions struct {
 	DefaultTTL      time.Duration // 默认过期时间
 	CleanupInterval time.Duration // 清理间隔
+	MaxWeight       int64         // 所有条目的权重之和上限，0表示不限制；NewTTL 创建的缓存按 SizeOfWeigher 计算权重
+	NegativeTTL     time.Duration // GetOrLoad 的加载函数返回 ErrNotFound 时缓存"不存在"的时长，0表示不缓存
+	RefreshAhead    float64       // 条目经过 TTL 的这一比例后被 GetOrLoad 读到时，在后台用同一个加载函数刷新；0表示不提前刷新
 }
 
 // DefaultTTLCacheOptions 默认的TTL缓存配置
@@ -73,152 +112,560 @@ var DefaultTTLCacheOptions = TTLCacheOptions{
 	CleanupInterval: time.Minute * 1, // 每分钟清理一次
 }
 
+// TTLCacheItem 字符串键TTL缓存项（兼容旧接口）
+type TTLCacheItem = TTLItem[string, interface{}]
+
+// TTLCache 字符串键、任意值的TTL缓存（兼容旧接口）
+type TTLCache = TTL[string, interface{}]
+
 // NewTTLCache 创建新的TTL缓存
 func NewTTLCache(options ...TTLCacheOptions) *TTLCache {
+	return NewTTL[string, interface{}](options...)
+}
+
+// NewTTL 创建新的泛型TTL缓存
+func NewTTL[K comparable, V any](options ...TTLCacheOptions) *TTL[K, V] {
+	return NewWeightedTTL[K, V](nil, options...)
+}
+
+// NewWeightedTTL 创建按 weigher 计算权重的TTL缓存，权重上限由选项中的 MaxWeight 指定，weigher 为 nil 时使用 SizeOfWeigher
+func NewWeightedTTL[K comparable, V any](weigher Weigher[K, V], options ...TTLCacheOptions) *TTL[K, V] {
 	opts := DefaultTTLCacheOptions
 	if len(options) > 0 {
 		opts = options[0]
 	}
+	if weigher == nil {
+		weigher = SizeOfWeigher[K, V]()
+	}
 
-	cache := &TTLCache{
-		items:           make(map[string]*TTLCacheItem),
+	cache := &TTL[K, V]{
+		items:           make(map[K]*TTLItem[K, V]),
 		defaultTTL:      opts.DefaultTTL,
 		cleanupInterval: opts.CleanupInterval,
-		stopCleanup:     make(chan bool),
+		events:          keyspace.NewNotifier(keyspace.DefaultBufferSize),
+		maxWeight:       max(opts.MaxWeight, 0),
+		weigher:         weigher,
+		negativeTTL:     max(opts.NegativeTTL, 0),
+	}
+	if opts.RefreshAhead > 0 && opts.RefreshAhead < 1 {
+		cache.refreshAhead = opts.RefreshAhead
 	}
 
-	// 启动后台清理任务
+	// 在共享时间轮上注册周期清理
 	if opts.CleanupInterval > 0 {
-		go cache.startCleanupTimer()
+		cache.cleanupTimer = concurrency.SharedTimingWheel().Every(opts.CleanupInterval, cache.Cleanup)
 	}
 
 	return cache
 }
 
-// startCleanupTimer 启动清理定时器
-func (c *TTLCache) startCleanupTimer() {
-	ticker := time.NewTicker(c.cleanupInterval)
-	defer ticker.Stop()
+// keyString 把键转换为键空间事件使用的字符串
+func keyString[K comparable](key K) string {
+	if s, ok := any(key).(string); ok {
+		return s
+	}
+	return fmt.Sprint(key)
+}
 
-	for {
-		select {
-		case <-ticker.C:
-			c.Cleanup()
-		case <-c.stopCleanup:
-			return
+// StopCleanup 停止周期清理并关闭所有事件订阅，可重复调用；正在进行的一次清理不受影响
+func (c *TTL[K, V]) StopCleanup() {
+	c.stopOnce.Do(func() {
+		if c.cleanupTimer != nil {
+			c.cleanupTimer.Stop()
 		}
+		c.events.Close()
+	})
+}
+
+// Subscribe 订阅匹配 pattern 的键的 set/del/expired 事件
+func (c *TTL[K, V]) Subscribe(pattern string) <-chan keyspace.KeyEvent {
+	return c.events.Subscribe(pattern)
+}
+
+// Unsubscribe 取消订阅并关闭通道
+func (c *TTL[K, V]) Unsubscribe(ch <-chan keyspace.KeyEvent) bool {
+	return c.events.Unsubscribe(ch)
+}
+
+// OnEvict 注册淘汰回调，设置了 MaxWeight 的缓存因总权重超限淘汰条目时调用
+func (c *TTL[K, V]) OnEvict(callback EvictionCallback[K, V]) {
+	c.onEvict.Add(callback)
+}
+
+// OnExpire 注册过期回调，过期条目在懒惰过期或 Cleanup 中被删除时调用；Remove、Clear 不会触发
+func (c *TTL[K, V]) OnExpire(callback EvictionCallback[K, V]) {
+	c.onExpire.Add(callback)
+}
+
+// ttlHeap 按过期时间排序的最小堆，实现 heap.Interface
+type ttlHeap[K comparable, V any] []*TTLItem[K, V]
+
+func (h ttlHeap[K, V]) Len() int           { return len(h) }
+func (h ttlHeap[K, V]) Less(i, j int) bool { return h[i].ExpireTime.Before(h[j].ExpireTime) }
+func (h ttlHeap[K, V]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *ttlHeap[K, V]) Push(x any) {
+	item := x.(*TTLItem[K, V])
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *ttlHeap[K, V]) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	old[len(old)-1] = nil // 避免底层数组继续引用已删除的条目
+	item.index = -1
+	*h = old[:len(old)-1]
+	return item
+}
+
+// addLocked 把条目加入哈希表和过期堆，调用方需持有写锁
+func (c *TTL[K, V]) addLocked(item *TTLItem[K, V]) {
+	c.items[item.Key] = item
+	c.weight += item.weight
+	item.index = -1
+	if !item.ExpireTime.IsZero() {
+		heap.Push(&c.expiry, item)
 	}
 }
 
-// StopCleanup 停止清理定时器
-func (c *TTLCache) StopCleanup() {
-	c.stopCleanup <- true
+// deleteLocked 把条目从哈希表和过期堆中删除，调用方需持有写锁
+func (c *TTL[K, V]) deleteLocked(item *TTLItem[K, V]) {
+	delete(c.items, item.Key)
+	c.weight -= item.weight
+	if item.index >= 0 {
+		heap.Remove(&c.expiry, item.index)
+	}
 }
 
-// Cleanup 执行过期项清理
-func (c *TTLCache) Cleanup() {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
+// Cleanup 执行过期项清理，只检查过期堆顶部已过期的条目
+func (c *TTL[K, V]) Cleanup() {
+	// 没有过期回调时不需要收集被删除的条目
+	notify := c.onExpire.Len() > 0
+	var expired []*TTLItem[K, V]
 
+	c.mutex.Lock()
 	now := time.Now()
-	for key, item := range c.items {
-		if !item.ExpireTime.IsZero() && now.After(item.ExpireTime) {
-			delete(c.items, key)
+	for len(c.expiry) > 0 && now.After(c.expiry[0].ExpireTime) {
+		item := c.expiry[0]
+		c.deleteLocked(item)
+		c.stats.RecordExpirations(1)
+		c.events.Publish(keyspace.EventExpired, keyString(item.Key))
+		if notify && !item.negative {
+			expired = append(expired, item)
 		}
 	}
+	c.mutex.Unlock()
+
+	// 解锁后再通知，回调中可以访问缓存
+	for _, item := range expired {
+		c.onExpire.Notify(item.Key, item.Value, EvictionExpired)
+	}
 }
 
 // Set 设置缓存，使用默认过期时间
-func (c *TTLCache) Set(key string, value interface{}) {
+func (c *TTL[K, V]) Set(key K, value V) {
 	c.SetWithTTL(key, value, c.defaultTTL)
 }
 
 // SetWithTTL 设置缓存，指定过期时间
-func (c *TTLCache) SetWithTTL(key string, value interface{}, ttl time.Duration) {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-
+func (c *TTL[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
 	var expireTime time.Time
 	if ttl > 0 {
 		expireTime = time.Now().Add(ttl)
 	}
+	c.set(key, value, expireTime)
+}
+
+// ttlEviction 因超出权重上限被删除的条目
+type ttlEviction[K comparable, V any] struct {
+	item   *TTLItem[K, V]
+	reason EvictionReason
+}
+
+// SetNotFound 记录 key 对应的数据不存在，ttl 小于等于0时使用 NegativeTTL，两者都为0时不记录；
+// 在过期之前 Lookup 返回 LookupNegative，GetOrLoad 直接返回 ErrNotFound 而不调用加载函数
+func (c *TTL[K, V]) SetNotFound(key K, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = c.negativeTTL
+	}
+	if ttl <= 0 {
+		return
+	}
+	var zero V
+	c.setItem(&TTLItem[K, V]{Key: key, Value: zero, ExpireTime: time.Now().Add(ttl), negative: true})
+}
 
-	c.items[key] = &TTLCacheItem{
+// set 写入条目，总权重超出上限时淘汰，回调在解锁后执行
+func (c *TTL[K, V]) set(key K, value V, expireTime time.Time) {
+	c.setItem(c.newItem(key, value, expireTime))
+}
+
+// newItem 构造条目，设置了 RefreshAhead 时计算刷新时间
+func (c *TTL[K, V]) newItem(key K, value V, expireTime time.Time) *TTLItem[K, V] {
+	item := &TTLItem[K, V]{
 		Key:        key,
 		Value:      value,
 		ExpireTime: expireTime,
 	}
+	if c.refreshAhead > 0 && !expireTime.IsZero() {
+		now := time.Now()
+		item.refreshAt = now.Add(time.Duration(float64(expireTime.Sub(now)) * c.refreshAhead))
+	}
+	return item
+}
+
+// setItem 写入构造好的条目
+func (c *TTL[K, V]) setItem(item *TTLItem[K, V]) {
+	if c.maxWeight > 0 {
+		item.weight = c.weigher(item.Key, item.Value) // 权重函数可能较慢，在锁外计算
+	}
+
+	c.mutex.Lock()
+	evicted := c.setItemLocked(item)
+	c.mutex.Unlock()
+	c.notifyEvictions(evicted)
+}
+
+// setItemLocked 写入已计算权重的条目，返回因此被删除的条目，调用方需持有写锁
+func (c *TTL[K, V]) setItemLocked(item *TTLItem[K, V]) []ttlEviction[K, V] {
+	if old, found := c.items[item.Key]; found {
+		c.deleteLocked(old)
+	}
+	if c.maxWeight > 0 && item.weight > c.maxWeight {
+		// 单个条目超过上限：不写入，旧值已被覆盖，视为新值写入后立即被淘汰
+		c.stats.RecordEvictions(1)
+		c.events.Publish(keyspace.EventEvicted, keyString(item.Key))
+		return []ttlEviction[K, V]{{item: item, reason: EvictionCapacity}}
+	}
+	c.addLocked(item)
+	c.events.Publish(keyspace.EventSet, keyString(item.Key))
+	return c.trimLocked()
+}
+
+// notifyEvictions 触发被删除条目的回调，调用方不能持有锁
+func (c *TTL[K, V]) notifyEvictions(evicted []ttlEviction[K, V]) {
+	for _, e := range evicted {
+		if e.item.negative {
+			continue // "不存在"的记录没有值，不通知回调
+		}
+		if e.reason == EvictionExpired {
+			c.onExpire.Notify(e.item.Key, e.item.Value, e.reason)
+		} else {
+			c.onEvict.Notify(e.item.Key, e.item.Value, e.reason)
+		}
+	}
+}
+
+// trimLocked 总权重超出上限时，按过期堆的顺序先删除已过期的条目，再按过期时间从早到晚淘汰，
+// 堆为空后再淘汰永不过期的条目，调用方需持有写锁
+func (c *TTL[K, V]) trimLocked() []ttlEviction[K, V] {
+	var evicted []ttlEviction[K, V]
+	now := time.Now()
+	for c.maxWeight > 0 && c.weight > c.maxWeight {
+		var item *TTLItem[K, V]
+		if len(c.expiry) > 0 {
+			item = c.expiry[0]
+		} else {
+			for _, forever := range c.items {
+				item = forever
+				break
+			}
+		}
+		c.deleteLocked(item)
+		if !item.ExpireTime.IsZero() && now.After(item.ExpireTime) {
+			c.stats.RecordExpirations(1)
+			c.events.Publish(keyspace.EventExpired, keyString(item.Key))
+			evicted = append(evicted, ttlEviction[K, V]{item: item, reason: EvictionExpired})
+		} else {
+			c.stats.RecordEvictions(1)
+			c.events.Publish(keyspace.EventEvicted, keyString(item.Key))
+			evicted = append(evicted, ttlEviction[K, V]{item: item, reason: EvictionCapacity})
+		}
+	}
+	return evicted
+}
+
+// expiresBefore 比较两个过期时间，零值表示永不过期，排在最后
+func expiresBefore(a, b time.Time) bool {
+	if a.IsZero() || b.IsZero() {
+		return !a.IsZero() && b.IsZero()
+	}
+	return a.Before(b)
+}
+
+// Put 设置缓存，使用默认过期时间（实现 Cache 接口）
+func (c *TTL[K, V]) Put(key K, value V) {
+	c.SetWithTTL(key, value, c.defaultTTL)
+}
+
+// PutMulti 使用默认过期时间批量写入，所有条目在同一次加锁内完成；权重在锁外计算，回调在解锁后执行
+func (c *TTL[K, V]) PutMulti(items map[K]V) {
+	var expireTime time.Time
+	if c.defaultTTL > 0 {
+		expireTime = time.Now().Add(c.defaultTTL)
+	}
+	batch := make([]*TTLItem[K, V], 0, len(items))
+	for key, value := range items {
+		item := c.newItem(key, value, expireTime)
+		if c.maxWeight > 0 {
+			item.weight = c.weigher(key, value)
+		}
+		batch = append(batch, item)
+	}
+
+	c.mutex.Lock()
+	var evicted []ttlEviction[K, V]
+	for _, item := range batch {
+		evicted = append(evicted, c.setItemLocked(item)...)
+	}
+	c.mutex.Unlock()
+	c.notifyEvictions(evicted)
 }
 
 // SetForever 设置永不过期的缓存项
-func (c *TTLCache) SetForever(key string, value interface{}) {
+func (c *TTL[K, V]) SetForever(key K, value V) {
+	c.set(key, value, time.Time{}) // 零值表示永不过期
+}
+
+// Get 获取缓存值，如果不存在或已过期则返回零值和false
+func (c *TTL[K, V]) Get(key K) (V, bool) {
+	value, _, ok := c.GetWithExpiration(key)
+	return value, ok
+}
+
+// GetWithExpiration 获取缓存值及其过期时间，过期时间为零值表示永不过期；不存在、已过期或记录为"不存在"时返回零值和false
+func (c *TTL[K, V]) GetWithExpiration(key K) (V, time.Time, bool) {
+	item := c.lookup(key)
+	if item == nil || item.negative {
+		c.stats.RecordMiss()
+		var zero V
+		return zero, time.Time{}, false
+	}
+	c.stats.RecordHit()
+	return item.Value, item.ExpireTime, true
+}
+
+// GetMulti 批量读取，返回命中的键值；所有键在同一次读锁内查找，已过期的条目在同一次写锁内删除
+func (c *TTL[K, V]) GetMulti(keys []K) map[K]V {
+	result := make(map[K]V, len(keys))
+	var expired []*TTLItem[K, V]
+
+	c.mutex.RLock()
+	for _, key := range keys {
+		item, found := c.items[key]
+		switch {
+		case !found:
+			c.stats.RecordMiss()
+		case item.IsExpired():
+			c.stats.RecordMiss()
+			expired = append(expired, item)
+		case item.negative:
+			c.stats.RecordMiss()
+		default:
+			c.stats.RecordHit()
+			result[key] = item.Value
+		}
+	}
+	c.mutex.RUnlock()
+
+	if len(expired) == 0 {
+		return result
+	}
+	var removed []*TTLItem[K, V]
 	c.mutex.Lock()
-	defer c.mutex.Unlock()
+	for _, item := range expired {
+		if c.items[item.Key] != item { // 期间可能已被重新写入或清理，重复的键也只删除一次
+			continue
+		}
+		c.deleteLocked(item)
+		c.stats.RecordExpirations(1)
+		c.events.Publish(keyspace.EventExpired, keyString(item.Key))
+		if !item.negative {
+			removed = append(removed, item)
+		}
+	}
+	c.mutex.Unlock()
+	for _, item := range removed {
+		c.onExpire.Notify(item.Key, item.Value, EvictionExpired)
+	}
+	return result
+}
 
-	c.items[key] = &TTLCacheItem{
-		Key:        key,
-		Value:      value,
-		ExpireTime: time.Time{}, // 零值表示永不过期
+// Lookup 获取缓存值并区分三种结果：命中、记录为"不存在"、未命中；记录为"不存在"也计为一次命中
+func (c *TTL[K, V]) Lookup(key K) (V, LookupStatus) {
+	var zero V
+	item := c.lookup(key)
+	switch {
+	case item == nil:
+		c.stats.RecordMiss()
+		return zero, LookupMiss
+	case item.negative:
+		c.stats.RecordHit()
+		return zero, LookupNegative
+	default:
+		c.stats.RecordHit()
+		return item.Value, LookupHit
 	}
 }
 
-// Get 获取缓存值，如果不存在或已过期则返回nil和false
-func (c *TTLCache) Get(key string) (interface{}, bool) {
+// lookup 返回未过期的条目，已过期的条目被删除后返回nil，不记录命中统计
+func (c *TTL[K, V]) lookup(key K) *TTLItem[K, V] {
 	c.mutex.RLock()
 	item, found := c.items[key]
 	c.mutex.RUnlock()
 
 	if !found {
-		return nil, false
+		return nil
 	}
 
 	// 懒惰过期检查
 	if item.IsExpired() {
 		c.mutex.Lock()
-		delete(c.items, key)
+		removed := c.items[key] == item // 期间可能已被重新写入或清理
+		if removed {
+			c.deleteLocked(item)
+			c.stats.RecordExpirations(1)
+			c.events.Publish(keyspace.EventExpired, keyString(key))
+		}
 		c.mutex.Unlock()
-		return nil, false
+		if removed && !item.negative {
+			c.onExpire.Notify(item.Key, item.Value, EvictionExpired)
+		}
+		return nil
+	}
+	return item
+}
+
+// GetOrLoad 获取缓存值，不存在或已过期时调用 loader 加载并以默认过期时间回填；
+// 同一个键的并发加载只执行一次，其余调用等待并共享结果，加载失败时不回填。
+// 设置了 NegativeTTL 时，loader 返回 ErrNotFound 会被缓存，过期之前直接返回 ErrNotFound；
+// 设置了 RefreshAhead 时，命中的条目进入刷新窗口后在后台用 loader 重新加载，本次仍返回旧值
+func (c *TTL[K, V]) GetOrLoad(key K, loader func() (V, error)) (V, error) {
+	item := c.lookup(key)
+	switch {
+	case item == nil:
+		c.stats.RecordMiss()
+	case item.negative:
+		c.stats.RecordHit()
+		return item.Value, ErrNotFound
+	default:
+		c.stats.RecordHit()
+		c.refresh(item, loader)
+		return item.Value, nil
+	}
+	value, err, _ := c.loads.Do(key, func() (V, error) {
+		return c.load(key, loader)
+	})
+	return value, err
+}
+
+// load 调用 loader 并回填：成功时以默认过期时间写入，返回 ErrNotFound 时写入"不存在"的记录
+func (c *TTL[K, V]) load(key K, loader func() (V, error)) (V, error) {
+	value, err := loader()
+	switch {
+	case err == nil:
+		c.Put(key, value)
+	case errors.Is(err, ErrNotFound):
+		c.SetNotFound(key, c.negativeTTL)
 	}
+	return value, err
+}
 
-	return item.Value, true
+// refresh 条目进入刷新窗口时启动后台刷新，每个条目只启动一次；刷新失败时旧值保留到过期，之后的读取会再次尝试
+func (c *TTL[K, V]) refresh(item *TTLItem[K, V], loader func() (V, error)) {
+	if item.refreshAt.IsZero() || time.Now().Before(item.refreshAt) || !item.refreshing.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		_, err, _ := c.loads.Do(item.Key, func() (V, error) {
+			return c.load(item.Key, loader)
+		})
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			c.refreshFailures.Add(1)
+			item.refreshing.Store(false)
+			return
+		}
+		c.refreshes.Add(1)
+	}()
 }
 
 // Remove 删除缓存项
-func (c *TTLCache) Remove(key string) bool {
+func (c *TTL[K, V]) Remove(key K) bool {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	if _, found := c.items[key]; found {
-		delete(c.items, key)
+	if item, found := c.items[key]; found {
+		c.deleteLocked(item)
+		c.events.Publish(keyspace.EventDelete, keyString(key))
 		return true
 	}
 	return false
 }
 
 // Size 返回当前缓存中的元素数量（包括已过期但未清理的）
-func (c *TTLCache) Size() int {
+func (c *TTL[K, V]) Size() int {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
 	return len(c.items)
 }
 
+// ItemCount 返回未过期的条目数量
+func (c *TTL[K, V]) ItemCount() int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return len(c.items) - c.expiredCountLocked(time.Now())
+}
+
+// ExpiredCount 返回已过期但还没有被懒惰过期或 Cleanup 删除的条目数量
+func (c *TTL[K, V]) ExpiredCount() int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.expiredCountLocked(time.Now())
+}
+
+// expiredCountLocked 统计过期堆中已过期的条目，子树的根未过期时整棵子树都未过期，代价与过期条目数成正比
+func (c *TTL[K, V]) expiredCountLocked(now time.Time) int {
+	count := 0
+	stack := []int{0}
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if i >= len(c.expiry) || !now.After(c.expiry[i].ExpireTime) {
+			continue
+		}
+		count++
+		stack = append(stack, 2*i+1, 2*i+2)
+	}
+	return count
+}
+
 // Clear 清空缓存
-func (c *TTLCache) Clear() {
+func (c *TTL[K, V]) Clear() {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
-	c.items = make(map[string]*TTLCacheItem)
+	for key := range c.items {
+		c.events.Publish(keyspace.EventDelete, keyString(key))
+	}
+	c.items = make(map[K]*TTLItem[K, V])
+	c.expiry = nil
+	c.weight = 0
 }
 
-// Keys 返回缓存中所有未过期键的列表
-func (c *TTLCache) Keys() []string {
+// Keys 返回缓存中所有未过期键的列表，不包括记录为"不存在"的键
+func (c *TTL[K, V]) Keys() []K {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
 
-	keys := make([]string, 0, len(c.items))
+	keys := make([]K, 0, len(c.items))
 	now := time.Now()
 
 	for key, item := range c.items {
-		if item.ExpireTime.IsZero() || now.Before(item.ExpireTime) {
+		if !item.negative && (item.ExpireTime.IsZero() || now.Before(item.ExpireTime)) {
 			keys = append(keys, key)
 		}
 	}
@@ -226,6 +673,102 @@ func (c *TTLCache) Keys() []string {
 	return keys
 }
 
+// Save 把未过期的条目及其过期时间写成快照，按过期时间先后排列，永不过期的条目排在最后；"不存在"的记录不保存
+func (c *TTL[K, V]) Save(w io.Writer) error {
+	c.mutex.RLock()
+	now := time.Now()
+	entries := make([]SnapshotEntry[K, V], 0, len(c.items))
+	for _, item := range c.items {
+		if !item.negative && (item.ExpireTime.IsZero() || now.Before(item.ExpireTime)) {
+			entries = append(entries, SnapshotEntry[K, V]{Key: item.Key, Value: item.Value, ExpireTime: item.ExpireTime})
+		}
+	}
+	c.mutex.RUnlock()
+
+	sort.Slice(entries, func(i, j int) bool { return expiresBefore(entries[i].ExpireTime, entries[j].ExpireTime) })
+	return WriteSnapshot(w, "ttl", 0, entries)
+}
+
+// Load 清空缓存并从快照恢复，保留原来的过期时间，快照保存后已经过期的条目被丢弃
+func (c *TTL[K, V]) Load(r io.Reader) error {
+	_, entries, err := ReadSnapshot[K, V](r, "ttl")
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for key := range c.items {
+		c.events.Publish(keyspace.EventDelete, keyString(key))
+	}
+	c.items = make(map[K]*TTLItem[K, V], len(entries))
+	c.expiry = nil
+	c.weight = 0
+	now := time.Now()
+	// 从最晚过期的条目开始恢复，超出权重上限时丢弃其余较早过期的条目
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if !entry.ExpireTime.IsZero() && !now.Before(entry.ExpireTime) {
+			continue
+		}
+		item := &TTLItem[K, V]{Key: entry.Key, Value: entry.Value, ExpireTime: entry.ExpireTime}
+		if c.maxWeight > 0 {
+			item.weight = c.weigher(entry.Key, entry.Value)
+			if c.weight+item.weight > c.maxWeight {
+				continue
+			}
+		}
+		c.addLocked(item)
+		c.events.Publish(keyspace.EventSet, keyString(entry.Key))
+	}
+	return nil
+}
+
+// Weight 返回当前总权重，没有设置 MaxWeight 时为0
+func (c *TTL[K, V]) Weight() int64 {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.weight
+}
+
+// MaxWeight 返回权重上限，0表示不限制
+func (c *TTL[K, V]) MaxWeight() int64 {
+	return c.maxWeight
+}
+
+// CacheStats 返回命中、未命中、淘汰和过期次数的快照，只有设置了 MaxWeight 的缓存才会淘汰条目
+func (c *TTL[K, V]) CacheStats() CacheStats {
+	return c.stats.Snapshot()
+}
+
+// Stats 返回访问统计和当前条目数（包括已过期但未清理的）
+func (c *TTL[K, V]) Stats() map[string]interface{} {
+	stats := c.stats.Snapshot().Map()
+	stats["size"] = c.Size()
+	stats["expiredCount"] = c.ExpiredCount()
+	stats["defaultTTL"] = c.defaultTTL.String()
+	if c.negativeTTL > 0 {
+		stats["negativeTTL"] = c.negativeTTL.String()
+	}
+	if c.refreshAhead > 0 {
+		stats["refreshAhead"] = c.refreshAhead
+		stats["refreshes"] = c.refreshes.Load()
+		stats["refreshFailures"] = c.refreshFailures.Load()
+	}
+	if c.maxWeight > 0 {
+		stats["weight"] = c.Weight()
+		stats["maxWeight"] = c.maxWeight
+	}
+	return stats
+}
+
+// MemoryUsage 返回深度估算的内存占用（字节）
+func (c *TTL[K, V]) MemoryUsage() int64 {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return sizeof.Of(c)
+}
+
 // 场景示例：会话管理系统
 func TTLCacheDemo() {
 	// 创建TTL缓存，设置较短的过期时间用于演示
@@ -238,6 +781,9 @@ func TTLCacheDemo() {
 
 	fmt.Println("会话管理系统示例 (TTL缓存):")
 
+	// 订阅会话相关的键空间事件，会话过期时无需轮询即可得知
+	sessionEvents := cache.Subscribe("session:*")
+
 	// 模拟用户登录，创建会话
 	cache.Set("session:user1", map[string]string{
 		"userId": "user1",
@@ -301,29 +847,52 @@ func TTLCacheDemo() {
 
 	fmt.Println("\n=== 6秒后状态（两个用户会话均已过期） ===")
 	printTTLCacheStatus(cache)
-}
 
-// 辅助函数：打印TTL缓存状态
-func printTTLCacheStatus(cache *TTLCache) {
-	cache.mutex.RLock()
-	defer cache.mutex.RUnlock()
-
-	now := time.Now()
-	fmt.Printf("当前缓存项数量: %d\n", len(cache.items))
+	fmt.Println("\n=== 订阅 session:* 收到的键空间事件 ===")
+	cache.Unsubscribe(sessionEvents)
+	for event := range sessionEvents {
+		fmt.Printf("%s %s\n", event.Type, event.Key)
+	}
 
-	for key, item := range cache.items {
-		var expireInfo string
-		if item.ExpireTime.IsZero() {
-			expireInfo = "永不过期"
-		} else {
-			remaining := item.ExpireTime.Sub(now)
-			if remaining > 0 {
-				expireInfo = fmt.Sprintf("剩余 %.1f 秒", remaining.Seconds())
-			} else {
-				expireInfo = "已过期"
+	// 热点配置每10ms读取一次，TTL为100ms，从配置中心加载需要30ms
+	fmt.Println("\n=== 提前刷新：热点配置连续读取300ms ===")
+	for _, refreshAhead := range []float64{0, 0.5} {
+		config := NewTTL[string, int](TTLCacheOptions{DefaultTTL: 100 * time.Millisecond, RefreshAhead: refreshAhead})
+		var loads atomic.Int32
+		loader := func() (int, error) {
+			time.Sleep(30 * time.Millisecond)
+			return int(loads.Add(1)), nil
+		}
+		slowest := time.Duration(0)
+		for i := 0; i < 30; i++ {
+			start := time.Now()
+			config.GetOrLoad("config:feature-flags", loader)
+			if i > 0 { // 第一次读取必然未命中
+				slowest = max(slowest, time.Since(start))
 			}
+			time.Sleep(10 * time.Millisecond)
 		}
+		fmt.Printf("RefreshAhead=%.1f: 未命中 %d 次，加载 %d 次，首次之后最慢一次读取 %dms\n",
+			refreshAhead, config.CacheStats().Misses, loads.Load(), slowest.Milliseconds())
+		config.StopCleanup()
+	}
+}
 
+// 辅助函数：打印TTL缓存状态
+func printTTLCacheStatus(cache *TTLCache) {
+	fmt.Printf("当前缓存项数量: %d (已过期未清理: %d)\n", cache.ItemCount(), cache.ExpiredCount())
+
+	keys := cache.Keys()
+	sort.Strings(keys)
+	for _, key := range keys {
+		_, expireTime, ok := cache.GetWithExpiration(key)
+		if !ok {
+			continue // 打印期间刚好过期
+		}
+		expireInfo := "永不过期"
+		if !expireTime.IsZero() {
+			expireInfo = fmt.Sprintf("剩余 %.1f 秒", time.Until(expireTime).Seconds())
+		}
 		fmt.Printf("键: %s, 过期状态: %s\n", key, expireInfo)
 	}
 }
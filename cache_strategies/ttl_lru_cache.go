@@ -0,0 +1,493 @@
+package cache_strategies
+
+/*
+TTL + LRU 缓存
+
+原理：
+TTL 缓存只按时间删除数据，写入的键越来越多时会无限增长；LRU 缓存只按容量淘汰，
+容量足够时过期的数据会一直留在缓存中被读到。实际业务（DNS 解析、会话、API 响应）两者都需要：
+条目数量有上限，超出时淘汰最久未使用的；每个条目还有自己的有效期，过期后不再返回。
+
+关键特点：
+1. 容量满时按 LRU 淘汰，读取时检查过期，过期的条目立即删除并视为未命中
+2. 每个条目可以有自己的 TTL，TTL 小于等于0表示永不过期
+3. 过期的条目不会再被读取"续命"，会逐渐沉到链表尾部，容量不足时最先被淘汰，因此无需后台清理也不会长期占用容量
+4. 淘汰链表尾部时如果该条目已经过期，计为过期而不是淘汰，回调原因分别为 expired 和 capacity
+5. 并发安全；Cleanup 可以主动删除所有过期条目
+6. SetNegativeTTL 之后 GetOrLoad 会缓存"数据不存在"的结果，详见 negative_cache.go
+
+实现方式：
+- 哈希表 + 双向链表维护访问顺序，与 LRU 相同；每个节点额外记录过期时间
+- 读取时在同一把锁内完成过期检查和移动到链表头部
+- 淘汰和过期回调在释放锁之后执行
+
+应用场景：
+- DNS 解析缓存：记录数有限，每条记录有各自的 TTL
+- 会话、令牌缓存：需要过期，也需要防止恶意请求撑爆内存
+- 下游 API 响应缓存
+
+优缺点：
+- 优点：内存有上限，过期数据不会被读到，两种淘汰互相配合
+- 缺点：读取也需要互斥锁（LRU 要移动节点）；未被访问的过期条目在被淘汰或 Cleanup 之前仍占用内存
+
+以下实现了同时具有容量上限和过期时间的缓存。
+*/
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/strive/scenario/sizeof"
+)
+
+// TTLLRUEntry TTL+LRU缓存节点
+type TTLLRUEntry[K comparable, V any] struct {
+	Key        K
+	Value      V
+	ExpireTime time.Time // 过期时间点，零值表示永不过期
+	negative   bool      // 记录的是"数据不存在"，Value 为零值
+}
+
+// expired 判断节点在 now 时是否已过期
+func (e *TTLLRUEntry[K, V]) expired(now time.Time) bool {
+	return !e.ExpireTime.IsZero() && now.After(e.ExpireTime)
+}
+
+// TTLLRU 同时限制条目数量和有效期的泛型缓存，可被多个协程并发使用
+type TTLLRU[K comparable, V any] struct {
+	mutex      sync.Mutex
+	capacity   int                 // 最大容量
+	defaultTTL time.Duration       // Put 使用的过期时间
+	cache      map[K]*list.Element // 键 -> 链表节点
+	list       *list.List          // 访问顺序，头部为最近使用
+	stats      StatsCounter        // 访问统计
+	onEvict    EvictionListeners[K, V]
+	onExpire   EvictionListeners[K, V]
+	loads      LoadGroup[K, V] // GetOrLoad 正在进行的加载
+
+	negativeTTL time.Duration // GetOrLoad 缓存"不存在"的时长，0表示不缓存
+}
+
+// TTLLRUCache 字符串键、任意值的TTL+LRU缓存
+type TTLLRUCache = TTLLRU[string, interface{}]
+
+// NewTTLLRUCache 创建指定容量和默认过期时间的TTL+LRU缓存
+func NewTTLLRUCache(capacity int, defaultTTL time.Duration) *TTLLRUCache {
+	return NewTTLLRU[string, interface{}](capacity, defaultTTL)
+}
+
+// NewTTLLRU 创建指定容量（至少为1）和默认过期时间的泛型TTL+LRU缓存，defaultTTL 小于等于0表示默认永不过期
+func NewTTLLRU[K comparable, V any](capacity int, defaultTTL time.Duration) *TTLLRU[K, V] {
+	return &TTLLRU[K, V]{
+		capacity:   max(capacity, 1),
+		defaultTTL: defaultTTL,
+		cache:      make(map[K]*list.Element),
+		list:       list.New(),
+	}
+}
+
+// Get 获取未过期的值；已过期的条目被删除并视为未命中，记录为"不存在"的键也返回未命中
+func (c *TTLLRU[K, V]) Get(key K) (V, bool) {
+	entry := c.lookup(key)
+	if entry == nil || entry.negative {
+		c.stats.RecordMiss()
+		var zero V
+		return zero, false
+	}
+	c.stats.RecordHit()
+	return entry.Value, true
+}
+
+// Lookup 获取未过期的值并区分命中、记录为"不存在"和未命中；记录为"不存在"也计为一次命中
+func (c *TTLLRU[K, V]) Lookup(key K) (V, LookupStatus) {
+	var zero V
+	entry := c.lookup(key)
+	switch {
+	case entry == nil:
+		c.stats.RecordMiss()
+		return zero, LookupMiss
+	case entry.negative:
+		c.stats.RecordHit()
+		return zero, LookupNegative
+	default:
+		c.stats.RecordHit()
+		return entry.Value, LookupHit
+	}
+}
+
+// lookup 返回未过期的节点并移到链表头部，已过期的节点被删除后返回nil，不记录命中统计
+func (c *TTLLRU[K, V]) lookup(key K) *TTLLRUEntry[K, V] {
+	c.mutex.Lock()
+	entry, expired := c.lookupLocked(key, time.Now())
+	c.mutex.Unlock()
+	if expired != nil {
+		c.notifyExpired(expired)
+	}
+	return entry
+}
+
+// lookupLocked 返回未过期的节点并移到链表头部；已过期的节点被删除后作为第二个返回值返回，调用方需持有锁
+func (c *TTLLRU[K, V]) lookupLocked(key K, now time.Time) (entry, expired *TTLLRUEntry[K, V]) {
+	element, exists := c.cache[key]
+	if !exists {
+		return nil, nil
+	}
+	entry = element.Value.(*TTLLRUEntry[K, V])
+	if entry.expired(now) {
+		c.removeElement(element)
+		return nil, entry
+	}
+	c.list.MoveToFront(element)
+	return entry, nil
+}
+
+// notifyExpired 统计读取时发现的过期条目并触发回调，调用方不能持有锁
+func (c *TTLLRU[K, V]) notifyExpired(entry *TTLLRUEntry[K, V]) {
+	c.stats.RecordExpirations(1)
+	if !entry.negative {
+		c.onExpire.Notify(entry.Key, entry.Value, EvictionExpired)
+	}
+}
+
+// GetMulti 批量读取未过期的值，返回命中的键值；所有键在同一次加锁内查找并移到链表头部，过期回调在解锁后执行
+func (c *TTLLRU[K, V]) GetMulti(keys []K) map[K]V {
+	result := make(map[K]V, len(keys))
+	var expired []*TTLLRUEntry[K, V]
+	now := time.Now()
+
+	c.mutex.Lock()
+	for _, key := range keys {
+		entry, removed := c.lookupLocked(key, now)
+		if removed != nil {
+			expired = append(expired, removed)
+		}
+		if entry == nil || entry.negative {
+			c.stats.RecordMiss()
+			continue
+		}
+		c.stats.RecordHit()
+		result[key] = entry.Value
+	}
+	c.mutex.Unlock()
+
+	for _, entry := range expired {
+		c.notifyExpired(entry)
+	}
+	return result
+}
+
+// Put 使用默认过期时间写入
+func (c *TTLLRU[K, V]) Put(key K, value V) {
+	c.SetWithTTL(key, value, c.defaultTTL)
+}
+
+// SetWithTTL 写入并指定过期时间，ttl 小于等于0表示永不过期；容量已满时淘汰最久未使用的条目
+func (c *TTLLRU[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
+	c.set(key, value, ttl, false)
+}
+
+// SetNegativeTTL 设置 GetOrLoad 缓存"不存在"的时长：加载函数返回 ErrNotFound 时记录该键不存在，0表示不缓存
+func (c *TTLLRU[K, V]) SetNegativeTTL(ttl time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.negativeTTL = max(ttl, 0)
+}
+
+// SetNotFound 记录 key 对应的数据不存在，ttl 必须大于0；记录与普通条目一样占用容量、参与LRU淘汰
+func (c *TTLLRU[K, V]) SetNotFound(key K, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	var zero V
+	c.set(key, zero, ttl, true)
+}
+
+// set 写入或覆盖节点
+func (c *TTLLRU[K, V]) set(key K, value V, ttl time.Duration, negative bool) {
+	now := time.Now()
+	var expireTime time.Time
+	if ttl > 0 {
+		expireTime = now.Add(ttl)
+	}
+
+	c.mutex.Lock()
+	victim := c.setLocked(key, value, expireTime, negative)
+	c.mutex.Unlock()
+
+	if victim != nil {
+		c.notifyRemoved([]*TTLLRUEntry[K, V]{victim}, now, EvictionCapacity)
+	}
+}
+
+// setLocked 写入或覆盖节点，容量已满时返回被淘汰的节点，调用方需持有锁
+func (c *TTLLRU[K, V]) setLocked(key K, value V, expireTime time.Time, negative bool) *TTLLRUEntry[K, V] {
+	if element, exists := c.cache[key]; exists {
+		entry := element.Value.(*TTLLRUEntry[K, V])
+		entry.Value = value
+		entry.ExpireTime = expireTime
+		entry.negative = negative
+		c.list.MoveToFront(element)
+		return nil
+	}
+
+	var victim *TTLLRUEntry[K, V]
+	if c.list.Len() >= c.capacity {
+		victim = c.removeElement(c.list.Back())
+	}
+	c.cache[key] = c.list.PushFront(&TTLLRUEntry[K, V]{Key: key, Value: value, ExpireTime: expireTime, negative: negative})
+	return victim
+}
+
+// PutMulti 使用默认过期时间批量写入，所有条目在同一次加锁内完成，淘汰回调在解锁后执行
+func (c *TTLLRU[K, V]) PutMulti(items map[K]V) {
+	now := time.Now()
+	var expireTime time.Time
+	if c.defaultTTL > 0 {
+		expireTime = now.Add(c.defaultTTL)
+	}
+
+	var victims []*TTLLRUEntry[K, V]
+	c.mutex.Lock()
+	for key, value := range items {
+		if victim := c.setLocked(key, value, expireTime, false); victim != nil {
+			victims = append(victims, victim)
+		}
+	}
+	c.mutex.Unlock()
+	c.notifyRemoved(victims, now, EvictionCapacity)
+}
+
+// removeElement 从链表和哈希表中删除节点，调用方需持有锁
+func (c *TTLLRU[K, V]) removeElement(element *list.Element) *TTLLRUEntry[K, V] {
+	entry := element.Value.(*TTLLRUEntry[K, V])
+	c.list.Remove(element)
+	delete(c.cache, entry.Key)
+	return entry
+}
+
+// notifyRemoved 统计被容量淘汰的条目并触发回调，其中已过期的条目按过期处理，"不存在"的记录不触发回调。调用方不能持有锁
+func (c *TTLLRU[K, V]) notifyRemoved(entries []*TTLLRUEntry[K, V], now time.Time, reason EvictionReason) {
+	for _, entry := range entries {
+		if entry.expired(now) {
+			c.stats.RecordExpirations(1)
+			if !entry.negative {
+				c.onExpire.Notify(entry.Key, entry.Value, EvictionExpired)
+			}
+		} else {
+			c.stats.RecordEvictions(1)
+			if !entry.negative {
+				c.onEvict.Notify(entry.Key, entry.Value, reason)
+			}
+		}
+	}
+}
+
+// GetOrLoad 获取未过期的值，不存在或已过期时调用 loader 加载并以默认过期时间回填；
+// 同一个键的并发加载只执行一次，加载失败时不回填。
+// 通过 SetNegativeTTL 设置了时长时，loader 返回 ErrNotFound 会被缓存，过期之前直接返回 ErrNotFound
+func (c *TTLLRU[K, V]) GetOrLoad(key K, loader func() (V, error)) (V, error) {
+	switch value, status := c.Lookup(key); status {
+	case LookupHit:
+		return value, nil
+	case LookupNegative:
+		return value, ErrNotFound
+	}
+	value, err, _ := c.loads.Do(key, func() (V, error) {
+		value, err := loader()
+		switch {
+		case err == nil:
+			c.Put(key, value)
+		case errors.Is(err, ErrNotFound):
+			c.mutex.Lock()
+			ttl := c.negativeTTL
+			c.mutex.Unlock()
+			c.SetNotFound(key, ttl)
+		}
+		return value, err
+	})
+	return value, err
+}
+
+// Remove 删除指定键
+func (c *TTLLRU[K, V]) Remove(key K) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if element, exists := c.cache[key]; exists {
+		c.removeElement(element)
+		return true
+	}
+	return false
+}
+
+// Cleanup 删除所有过期条目，返回删除的数量
+func (c *TTLLRU[K, V]) Cleanup() int {
+	now := time.Now()
+	var expired []*TTLLRUEntry[K, V]
+
+	c.mutex.Lock()
+	for element := c.list.Back(); element != nil; {
+		prev := element.Prev()
+		if element.Value.(*TTLLRUEntry[K, V]).expired(now) {
+			expired = append(expired, c.removeElement(element))
+		}
+		element = prev
+	}
+	c.mutex.Unlock()
+
+	c.stats.RecordExpirations(len(expired))
+	for _, entry := range expired {
+		if !entry.negative {
+			c.onExpire.Notify(entry.Key, entry.Value, EvictionExpired)
+		}
+	}
+	return len(expired)
+}
+
+// Size 返回当前条目数量（包括已过期但未删除的）
+func (c *TTLLRU[K, V]) Size() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.list.Len()
+}
+
+// Capacity 返回最大容量
+func (c *TTLLRU[K, V]) Capacity() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.capacity
+}
+
+// Resize 调整最大容量（至少为1），容量变小时从链表尾部淘汰，返回淘汰的数量
+func (c *TTLLRU[K, V]) Resize(capacity int) int {
+	capacity = max(capacity, 1)
+
+	c.mutex.Lock()
+	c.capacity = capacity
+	var evicted []*TTLLRUEntry[K, V]
+	for c.list.Len() > capacity {
+		evicted = append(evicted, c.removeElement(c.list.Back()))
+	}
+	c.mutex.Unlock()
+
+	c.notifyRemoved(evicted, time.Now(), EvictionResize)
+	return len(evicted)
+}
+
+// Evict 从链表尾部淘汰最多 n 个最久未使用的条目，容量保持不变，返回淘汰的数量
+func (c *TTLLRU[K, V]) Evict(n int) int {
+	c.mutex.Lock()
+	var evicted []*TTLLRUEntry[K, V]
+	for ; n > 0 && c.list.Len() > 0; n-- {
+		evicted = append(evicted, c.removeElement(c.list.Back()))
+	}
+	c.mutex.Unlock()
+
+	c.notifyRemoved(evicted, time.Now(), EvictionMemoryPressure)
+	return len(evicted)
+}
+
+// Keys 返回所有未过期的键（从最近使用到最久未使用），不包括记录为"不存在"的键
+func (c *TTLLRU[K, V]) Keys() []K {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	keys := make([]K, 0, c.list.Len())
+	for e := c.list.Front(); e != nil; e = e.Next() {
+		if entry := e.Value.(*TTLLRUEntry[K, V]); !entry.negative && !entry.expired(now) {
+			keys = append(keys, entry.Key)
+		}
+	}
+	return keys
+}
+
+// Clear 清空缓存
+func (c *TTLLRU[K, V]) Clear() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.cache = make(map[K]*list.Element)
+	c.list = list.New()
+}
+
+// OnEvict 注册淘汰回调，未过期的条目因容量不足、Resize 缩容或 Evict 被淘汰时调用
+func (c *TTLLRU[K, V]) OnEvict(callback EvictionCallback[K, V]) {
+	c.onEvict.Add(callback)
+}
+
+// OnExpire 注册过期回调，过期条目在读取、Cleanup 或被挤出链表尾部时删除后调用
+func (c *TTLLRU[K, V]) OnExpire(callback EvictionCallback[K, V]) {
+	c.onExpire.Add(callback)
+}
+
+// CacheStats 返回命中、未命中、淘汰和过期次数的快照
+func (c *TTLLRU[K, V]) CacheStats() CacheStats {
+	return c.stats.Snapshot()
+}
+
+// Stats 返回访问统计、容量和默认过期时间
+func (c *TTLLRU[K, V]) Stats() map[string]interface{} {
+	stats := c.stats.Snapshot().Map()
+	c.mutex.Lock()
+	stats["capacity"] = c.capacity
+	stats["size"] = c.list.Len()
+	c.mutex.Unlock()
+	stats["defaultTTL"] = c.defaultTTL.String()
+	return stats
+}
+
+// MemoryUsage 返回深度估算的内存占用（字节）
+func (c *TTLLRU[K, V]) MemoryUsage() int64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return sizeof.Of(c)
+}
+
+// 场景示例：DNS解析缓存，记录数有限，每条记录有各自的TTL
+func TTLLRUCacheDemo() {
+	fmt.Println("DNS解析缓存示例 (TTL+LRU，容量=3):")
+
+	dns := NewTTLLRU[string, string](3, time.Minute)
+	dns.OnEvict(func(host, ip string, reason EvictionReason) {
+		fmt.Printf("  [淘汰] %s -> %s (%s)\n", host, ip, reason)
+	})
+	dns.OnExpire(func(host, ip string, reason EvictionReason) {
+		fmt.Printf("  [过期] %s -> %s\n", host, ip)
+	})
+
+	resolve := func(host string) {
+		if ip, ok := dns.Get(host); ok {
+			fmt.Printf("%-16s 命中 %s\n", host, ip)
+		} else {
+			fmt.Printf("%-16s 未命中，需要查询DNS服务器\n", host)
+		}
+	}
+
+	dns.SetWithTTL("api.example.com", "10.0.0.1", 30*time.Millisecond) // 短TTL，便于故障切换
+	dns.Put("www.example.com", "10.0.0.2")
+	dns.Put("cdn.example.com", "10.0.0.3")
+	resolve("api.example.com")
+
+	// 容量已满，写入第4条记录时淘汰最久未使用的 www.example.com
+	fmt.Println("\n写入 img.example.com:")
+	dns.Put("img.example.com", "10.0.0.4")
+	resolve("www.example.com")
+
+	// api.example.com 虽然刚被访问过，TTL到期后也不再返回
+	time.Sleep(40 * time.Millisecond)
+	fmt.Println("\n40ms后:")
+	resolve("api.example.com")
+	resolve("cdn.example.com")
+
+	// 过期但未被读取的记录沉到链表尾部，容量不足时最先被挤出，按过期计数
+	dns.SetWithTTL("tmp.example.com", "10.0.0.5", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	resolve("img.example.com")
+	resolve("cdn.example.com")
+	fmt.Println("\n写入 mail.example.com:")
+	dns.Put("mail.example.com", "10.0.0.6")
+
+	fmt.Printf("\n当前记录: %v\n统计: %s\n", dns.Keys(), dns.CacheStats())
+}
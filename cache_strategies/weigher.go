@@ -0,0 +1,102 @@
+package cache_strategies
+
+/*
+按权重淘汰：以内存占用而不是条目数限制缓存
+
+原理：
+按条目数限制容量隐含了"每个条目一样大"的假设。缓存 API 响应、图片缩略图、序列化对象这类大小差异很大的数据时，
+容量为1000的缓存可能只占几百KB，也可能占上百MB，条目数根本无法约束内存。
+按权重淘汰给每个条目计算一个权重（通常是字节数），缓存保证所有条目的权重之和不超过 MaxWeight，
+超出时按淘汰策略依次删除条目，直到总权重回到上限以内。
+
+关键特点：
+1. 权重由 Weigher 在写入时计算一次并保存，删除时减去同一个值，权重函数不必是确定性的
+2. 没有指定 Weigher 时使用 SizeOfWeigher，按 sizeof 深度估算键和值的内存占用
+3. 单个条目的权重超过 MaxWeight 时不会写入（计为一次淘汰并触发回调），不会为了它把其他条目全部挤出去
+4. LRU 按最久未使用的顺序淘汰；TTL 缓存先删除已过期的条目，再按过期时间从早到晚淘汰，永不过期的条目最后淘汰；
+   GreedyDualSize 同样按大小之和限制容量，但按单位大小的回源代价决定淘汰顺序（见 greedy_dual_size.go）
+
+实现方式：
+- LRU 和 TTL 缓存的每个条目记录自己的权重，缓存维护总权重
+- 写入后总权重超过上限时循环淘汰，被淘汰的条目计入淘汰统计并触发 OnEvict 回调（原因为 capacity）
+- TTL 缓存超限时从过期堆的堆顶开始淘汰，每淘汰一个条目的代价为 O(log n)
+
+应用场景：
+- API 响应缓存、页面片段缓存等大小差异大的数据
+- 按内存预算配置缓存，而不是猜测平均条目大小
+- 多个缓存共享一台机器的内存时分别设定预算
+
+优缺点：
+- 优点：内存占用有明确上限，不会因为少数大对象撑爆内存
+- 缺点：每次写入都要计算权重，SizeOfWeigher 基于反射，值较大时有一定开销，对性能敏感的场景应提供自定义 Weigher
+
+以下实现了权重函数类型和默认的内存估算权重函数，以及按条目数和按内存限制的 API 响应缓存对比示例。
+*/
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/strive/scenario/sizeof"
+)
+
+// Weigher 计算条目的权重（通常为字节数），返回值应为非负数
+type Weigher[K comparable, V any] func(key K, value V) int64
+
+// SizeOfWeigher 返回按 sizeof 深度估算键和值内存占用的权重函数
+func SizeOfWeigher[K comparable, V any]() Weigher[K, V] {
+	return func(key K, value V) int64 {
+		return sizeof.Of(key) + sizeof.Of(value)
+	}
+}
+
+// 场景示例：API 响应大小从几百字节到几十KB不等，按条目数限制无法约束内存
+func WeightedEvictionDemo() {
+	fmt.Println("按权重淘汰示例 (API响应缓存):")
+
+	// 大多数接口返回几百字节，导出类接口返回几十KB
+	responseSize := func(i int) int {
+		if i%10 == 0 {
+			return 32 * 1024
+		}
+		return 512
+	}
+	bodyWeigher := func(path string, body string) int64 { return int64(len(path) + len(body)) }
+
+	byCount := NewTTL[string, string](TTLCacheOptions{DefaultTTL: time.Minute})
+	defer byCount.StopCleanup()
+	const maxWeight = 128 * 1024
+	byWeight := NewWeightedTTL(bodyWeigher, TTLCacheOptions{DefaultTTL: time.Minute, MaxWeight: maxWeight})
+	defer byWeight.StopCleanup()
+
+	evictedBytes := int64(0)
+	byWeight.OnEvict(func(path string, body string, reason EvictionReason) {
+		evictedBytes += bodyWeigher(path, body)
+	})
+
+	for i := 0; i < 200; i++ {
+		path := fmt.Sprintf("/api/v1/items/%03d", i)
+		body := strings.Repeat("x", responseSize(i))
+		// 越晚写入的响应过期越晚，内存不足时先淘汰最早过期的
+		ttl := time.Minute + time.Duration(i)*time.Second
+		byCount.SetWithTTL(path, body, ttl)
+		byWeight.SetWithTTL(path, body, ttl)
+	}
+
+	countWeight := int64(0)
+	for _, path := range byCount.Keys() {
+		body, _ := byCount.Get(path)
+		countWeight += bodyWeigher(path, body)
+	}
+	fmt.Printf("  不限制权重: %d 个响应，占用 %s\n", byCount.Size(), sizeof.FormatBytes(countWeight))
+	fmt.Printf("  MaxWeight=%s: %d 个响应，占用 %s，淘汰 %d 个 (%s)\n",
+		sizeof.FormatBytes(maxWeight), byWeight.Size(), sizeof.FormatBytes(byWeight.Weight()),
+		byWeight.CacheStats().Evictions, sizeof.FormatBytes(evictedBytes))
+
+	// 单个响应超过上限时不会挤掉其他条目
+	before := byWeight.Size()
+	byWeight.Set("/api/v1/export/all", strings.Repeat("x", 256*1024))
+	_, cached := byWeight.Get("/api/v1/export/all")
+	fmt.Printf("  写入256KB的导出响应: 被缓存=%v，其他响应 %d -> %d 个\n", cached, before, byWeight.Size())
+}
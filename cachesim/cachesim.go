@@ -0,0 +1,483 @@
+package cachesim
+
+/*
+基于访问轨迹的缓存模拟器
+
+原理：
+评估缓存淘汰策略最可靠的方法是用真实的访问日志（trace）回放：
+按顺序读取每一次访问，命中则计数，未命中则写入缓存（模拟回源后回填），
+最终得到命中率。在多个容量下重复这一过程，就得到"命中率-容量"曲线，
+它回答了"缓存加到多大才值得"以及"哪种策略在这个负载下更好"两个问题。
+
+关键特点：
+1. 支持多种常见的trace格式：每行一个键、ARC论文格式、LIRS论文格式，也可自动识别
+2. cache_strategies 统一注册表中所有有容量限制的策略自动参与模拟，注册新策略后无需再在这里登记；
+   本包也可以注册只用于模拟的策略变体（如不同 K 值的 LRU-K），外部包可以注册自己的策略
+3. 一次运行输出多个容量下、多个策略的命中率表格（Markdown或CSV）
+
+实现方式：
+- ARC格式每行为 "起始块号 块数 忽略 请求序号"，展开为连续的块号访问
+- LIRS格式每行一个块号，忽略 "*" 标记行；明确指定LIRS格式时其他非数字行报错（带行号），
+  自动识别为LIRS时非数字行按普通键回放，不会悄悄截断轨迹
+- 容量未指定时，按去重键数的对数等分自动选取若干容量点
+- 每个(策略, 容量)组合使用全新的缓存实例回放完整轨迹
+
+应用场景：
+- 在真实负载上选择淘汰策略
+- 为缓存容量规划提供依据
+- 对比新实现的策略与经典策略
+
+优缺点：
+- 优点：结论来自真实负载，可复现
+- 缺点：整条轨迹加载到内存中；只模拟命中率，不模拟并发和延迟
+
+以下实现了trace解析、策略注册和命中率曲线模拟。
+*/
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/strive/scenario/cache_strategies"
+)
+
+// trace格式
+const (
+	FormatAuto  = "auto"  // 根据首行内容自动识别
+	FormatPlain = "plain" // 每行一个键（取第一列）
+	FormatARC   = "arc"   // ARC论文格式：起始块号 块数 忽略 请求序号
+	FormatLIRS  = "lirs"  // LIRS论文格式：每行一个块号
+)
+
+// Cache 参与模拟的缓存需要实现的接口
+type Cache interface {
+	Get(key string) (interface{}, bool)
+	Put(key string, value interface{})
+}
+
+// Factory 按容量创建缓存实例
+type Factory func(capacity int) Cache
+
+var (
+	registry      = make(map[string]Factory)
+	registryMutex sync.RWMutex
+)
+
+// 只用于模拟的策略变体；其余策略来自 cache_strategies 的统一注册表
+func init() {
+	RegisterPolicy("lru-2", func(capacity int) Cache { return cache_strategies.NewLRUKCache(capacity, 2) })
+	RegisterPolicy("lru-3", func(capacity int) Cache { return cache_strategies.NewLRUKCache(capacity, 3) })
+}
+
+// boundedCache 有容量限制的缓存，没有容量限制的策略（如纯TTL缓存）在回放中命中率恒为最优，不参与比较
+type boundedCache interface {
+	Capacity() int
+}
+
+// sharedPolicies 返回 cache_strategies 注册表中有容量限制的策略
+func sharedPolicies() []string {
+	var names []string
+	for _, name := range cache_strategies.Policies() {
+		cache, err := cache_strategies.NewCache(name, 1)
+		if err != nil {
+			continue
+		}
+		if _, ok := cache.(boundedCache); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// RegisterPolicy 注册只用于模拟的淘汰策略，同名时覆盖 cache_strategies 注册表中的策略
+func RegisterPolicy(name string, factory Factory) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+	registry[strings.ToLower(name)] = factory
+}
+
+// Policies 返回所有可以参与模拟的策略名称（按字母序）：本包注册的策略加上
+// cache_strategies 注册表中有容量限制的策略
+func Policies() []string {
+	seen := make(map[string]bool)
+	names := sharedPolicies()
+	for _, name := range names {
+		seen[name] = true
+	}
+
+	registryMutex.RLock()
+	for name := range registry {
+		if !seen[name] {
+			names = append(names, name)
+		}
+	}
+	registryMutex.RUnlock()
+
+	sort.Strings(names)
+	return names
+}
+
+// lookupPolicy 查找策略：先查本包注册的策略，再查 cache_strategies 注册表
+func lookupPolicy(name string) (Factory, error) {
+	registryMutex.RLock()
+	factory, ok := registry[strings.ToLower(name)]
+	registryMutex.RUnlock()
+	if ok {
+		return factory, nil
+	}
+
+	if _, err := cache_strategies.NewCache(name, 1); err != nil {
+		if errors.Is(err, cache_strategies.ErrUnknownPolicy) {
+			return nil, fmt.Errorf("未知的淘汰策略: %s（可选: %s）", name, strings.Join(Policies(), ", "))
+		}
+		return nil, err
+	}
+	return func(capacity int) Cache {
+		cache, _ := cache_strategies.NewCache(name, capacity)
+		return cache
+	}, nil
+}
+
+// ReadTrace 读取访问轨迹，limit > 0 时最多读取 limit 次访问
+func ReadTrace(r io.Reader, format string, limit int) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	trace := make([]string, 0, 1024)
+	full := func() bool { return limit > 0 && len(trace) >= limit }
+	lineNo := 0
+	detected := format == FormatAuto || format == "" // 格式由首个有效行推断，之后的行可能不完全符合
+
+	for scanner.Scan() && !full() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+
+		if format == FormatAuto || format == "" {
+			format = detectFormat(fields)
+		}
+
+		switch format {
+		case FormatPlain:
+			trace = append(trace, fields[0])
+
+		case FormatLIRS:
+			if strings.Trim(fields[0], "*") == "" {
+				continue // LIRS trace中的 "*" 标记行
+			}
+			// 推断为LIRS格式时，非数字的键按普通键处理，不能悄悄丢掉；明确指定LIRS格式时报错
+			if _, err := strconv.ParseInt(fields[0], 10, 64); err != nil && !detected {
+				return nil, fmt.Errorf("第 %d 行不是LIRS格式: %q", lineNo, line)
+			}
+			trace = append(trace, fields[0])
+
+		case FormatARC:
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("第 %d 行不是ARC格式: %q", lineNo, line)
+			}
+			start, err1 := strconv.ParseInt(fields[0], 10, 64)
+			count, err2 := strconv.ParseInt(fields[1], 10, 64)
+			if err1 != nil || err2 != nil || count < 0 {
+				return nil, fmt.Errorf("第 %d 行不是ARC格式: %q", lineNo, line)
+			}
+			for i := int64(0); i < count && !full(); i++ {
+				trace = append(trace, strconv.FormatInt(start+i, 10))
+			}
+
+		default:
+			return nil, fmt.Errorf("不支持的trace格式: %s", format)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(trace) == 0 {
+		return nil, errors.New("trace为空")
+	}
+	return trace, nil
+}
+
+// detectFormat 根据首个有效行识别trace格式
+func detectFormat(fields []string) string {
+	allInts := true
+	for _, f := range fields {
+		if _, err := strconv.ParseInt(f, 10, 64); err != nil {
+			allInts = false
+			break
+		}
+	}
+
+	switch {
+	case allInts && len(fields) == 4:
+		return FormatARC
+	case allInts && len(fields) == 1:
+		return FormatLIRS
+	default:
+		return FormatPlain
+	}
+}
+
+// UniqueKeys 统计轨迹中的去重键数
+func UniqueKeys(trace []string) int {
+	seen := make(map[string]struct{}, len(trace)/4)
+	for _, key := range trace {
+		seen[key] = struct{}{}
+	}
+	return len(seen)
+}
+
+// DefaultCapacities 在 [1, unique] 区间按对数等分选取 points 个容量点
+func DefaultCapacities(unique, points int) []int {
+	if unique <= 0 || points <= 0 {
+		return nil
+	}
+
+	capacities := make([]int, 0, points)
+	last := 0
+	for i := 1; i <= points; i++ {
+		// 从去重键数的 1/1000 开始，到全部键数为止
+		exponent := -3 + 3*float64(i)/float64(points)
+		capacity := int(math.Round(float64(unique) * math.Pow(10, exponent)))
+		if capacity < 1 {
+			capacity = 1
+		}
+		if capacity > last {
+			capacities = append(capacities, capacity)
+			last = capacity
+		}
+	}
+	return capacities
+}
+
+// Replay 在一个缓存实例上回放轨迹，返回命中次数
+func Replay(cache Cache, trace []string) int {
+	hits := 0
+	for _, key := range trace {
+		if _, ok := cache.Get(key); ok {
+			hits++
+		} else {
+			cache.Put(key, struct{}{})
+		}
+	}
+	return hits
+}
+
+// Curve 命中率-容量曲线
+type Curve struct {
+	Requests   int                  // 访问次数
+	Unique     int                  // 去重键数
+	Policies   []string             // 参与模拟的策略
+	Capacities []int                // 容量点
+	HitRatio   map[string][]float64 // 策略 -> 各容量下的命中率
+}
+
+// Simulate 对每个策略、每个容量回放轨迹，得到命中率曲线
+func Simulate(trace []string, policies []string, capacities []int) (*Curve, error) {
+	factories := make([]Factory, len(policies))
+	for i, name := range policies {
+		factory, err := lookupPolicy(name)
+		if err != nil {
+			return nil, err
+		}
+		factories[i] = factory
+	}
+
+	curve := &Curve{
+		Requests:   len(trace),
+		Unique:     UniqueKeys(trace),
+		Policies:   policies,
+		Capacities: capacities,
+		HitRatio:   make(map[string][]float64, len(policies)),
+	}
+
+	// 各(策略, 容量)组合互不影响，并行回放
+	var wg sync.WaitGroup
+	for i, name := range policies {
+		ratios := make([]float64, len(capacities))
+		curve.HitRatio[name] = ratios
+		for j, capacity := range capacities {
+			wg.Add(1)
+			go func(factory Factory, j, capacity int) {
+				defer wg.Done()
+				hits := Replay(factory(capacity), trace)
+				ratios[j] = float64(hits) / float64(len(trace))
+			}(factories[i], j, capacity)
+		}
+	}
+	wg.Wait()
+
+	return curve, nil
+}
+
+// WriteMarkdown 以Markdown表格输出命中率曲线，每个容量下的最优策略加粗
+func (c *Curve) WriteMarkdown(w io.Writer) {
+	fmt.Fprintf(w, "访问次数: %d, 去重键数: %d\n\n", c.Requests, c.Unique)
+
+	fmt.Fprint(w, "| 容量 |")
+	for _, name := range c.Policies {
+		fmt.Fprintf(w, " %s |", name)
+	}
+	fmt.Fprint(w, "\n|---:|")
+	for range c.Policies {
+		fmt.Fprint(w, "---:|")
+	}
+	fmt.Fprintln(w)
+
+	for j, capacity := range c.Capacities {
+		best, worst := 0.0, 1.0
+		for _, name := range c.Policies {
+			best = math.Max(best, c.HitRatio[name][j])
+			worst = math.Min(worst, c.HitRatio[name][j])
+		}
+
+		fmt.Fprintf(w, "| %d |", capacity)
+		for _, name := range c.Policies {
+			ratio := c.HitRatio[name][j]
+			cell := fmt.Sprintf("%.2f%%", ratio*100)
+			if ratio == best && best > worst {
+				cell = "**" + cell + "**"
+			}
+			fmt.Fprintf(w, " %s |", cell)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// WriteCSV 以CSV格式输出命中率曲线，便于绘图
+func (c *Curve) WriteCSV(w io.Writer) {
+	fmt.Fprintf(w, "capacity,%s\n", strings.Join(c.Policies, ","))
+	for j, capacity := range c.Capacities {
+		cells := make([]string, len(c.Policies))
+		for i, name := range c.Policies {
+			cells[i] = strconv.FormatFloat(c.HitRatio[name][j], 'f', 6, 64)
+		}
+		fmt.Fprintf(w, "%d,%s\n", capacity, strings.Join(cells, ","))
+	}
+}
+
+// RunCLI 命令行入口：cachesim -trace 文件 [-format auto|plain|arc|lirs] [-policies a,b] [-capacities 100,1000]
+func RunCLI(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("cachesim", flag.ContinueOnError)
+	fs.SetOutput(out)
+	tracePath := fs.String("trace", "", "trace文件路径（- 表示标准输入）")
+	format := fs.String("format", FormatAuto, "trace格式: auto, plain, arc, lirs")
+	policies := fs.String("policies", strings.Join(Policies(), ","), "参与模拟的策略，逗号分隔")
+	capacities := fs.String("capacities", "", "容量点，逗号分隔（为空时自动选取）")
+	points := fs.Int("points", 8, "自动选取的容量点数量")
+	limit := fs.Int("limit", 0, "最多读取的访问次数（0表示不限制）")
+	output := fs.String("output", "markdown", "输出格式: markdown, csv")
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil // -h 已经输出了用法
+		}
+		return err
+	}
+	if *tracePath == "" {
+		fs.Usage()
+		return errors.New("必须指定 -trace")
+	}
+
+	var reader io.Reader = os.Stdin
+	if *tracePath != "-" {
+		file, err := os.Open(*tracePath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	trace, err := ReadTrace(reader, *format, *limit)
+	if err != nil {
+		return err
+	}
+
+	var caps []int
+	if *capacities == "" {
+		caps = DefaultCapacities(UniqueKeys(trace), *points)
+	} else {
+		for _, s := range strings.Split(*capacities, ",") {
+			capacity, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil || capacity <= 0 {
+				return fmt.Errorf("无效的容量: %q", s)
+			}
+			caps = append(caps, capacity)
+		}
+	}
+
+	names := make([]string, 0)
+	for _, name := range strings.Split(*policies, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	curve, err := Simulate(trace, names, caps)
+	if err != nil {
+		return err
+	}
+
+	switch *output {
+	case "csv":
+		curve.WriteCSV(out)
+	case "markdown":
+		curve.WriteMarkdown(out)
+	default:
+		return fmt.Errorf("不支持的输出格式: %s", *output)
+	}
+	return nil
+}
+
+// 场景示例：回放一段ARC格式的数据库块访问轨迹
+func CacheSimDemo() {
+	fmt.Println("基于访问轨迹的缓存模拟:")
+
+	// 构造一段ARC格式的trace：热点索引块反复访问，夹杂大范围的顺序表扫描
+	var sb strings.Builder
+	rng := rand.New(rand.NewSource(1))
+	zipf := rand.NewZipf(rng, 1.2, 1, 2000)
+	request := 0
+	for round := 0; round < 200; round++ {
+		for i := 0; i < 50; i++ {
+			block := zipf.Uint64() // 热点索引块
+			fmt.Fprintf(&sb, "%d 1 0 %d\n", block, request)
+			request++
+		}
+		if round%10 == 0 {
+			fmt.Fprintf(&sb, "%d 500 0 %d\n", 10000+round*500, request) // 全表扫描
+			request++
+		}
+	}
+
+	trace, err := ReadTrace(strings.NewReader(sb.String()), FormatAuto, 0)
+	if err != nil {
+		fmt.Printf("读取trace失败: %v\n", err)
+		return
+	}
+	fmt.Printf("trace共 %d 行，展开后 %d 次访问\n\n", request, len(trace))
+
+	curve, err := Simulate(trace, Policies(), DefaultCapacities(UniqueKeys(trace), 6))
+	if err != nil {
+		fmt.Printf("模拟失败: %v\n", err)
+		return
+	}
+	curve.WriteMarkdown(os.Stdout)
+
+	fmt.Println("\nCSV格式:")
+	curve.WriteCSV(os.Stdout)
+}
@@ -0,0 +1,40 @@
+package cachesim
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// 首行是数字时自动识别为LIRS格式，之后的非数字键仍然要回放，只跳过 "*" 标记行
+func TestReadTraceMixedKeys(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&sb, "%d\n", i)
+		fmt.Fprintf(&sb, "user-%d\n", i)
+	}
+	sb.WriteString("*\n")
+
+	trace, err := ReadTrace(strings.NewReader(sb.String()), FormatAuto, 0)
+	if err != nil {
+		t.Fatalf("读取trace失败: %v", err)
+	}
+	if len(trace) != 200 {
+		t.Fatalf("读到 %d 次访问，期望 200", len(trace))
+	}
+
+	if _, err := ReadTrace(strings.NewReader(sb.String()), FormatLIRS, 0); err == nil || !strings.Contains(err.Error(), "第 2 行") {
+		t.Fatalf("明确指定LIRS格式时非数字行返回 %v，期望带行号的错误", err)
+	}
+}
+
+// -h 只输出用法，不算失败
+func TestRunCLIHelp(t *testing.T) {
+	var out strings.Builder
+	if err := RunCLI([]string{"-h"}, &out); err != nil {
+		t.Fatalf("-h 返回错误: %v", err)
+	}
+	if !strings.Contains(out.String(), "-trace") {
+		t.Fatalf("-h 没有输出用法: %q", out.String())
+	}
+}
@@ -0,0 +1,348 @@
+package cachesim
+
+/*
+分布式缓存集群的扩缩容模拟
+
+原理：
+多个缓存节点通过一致性哈希分担键空间，每个键只缓存在它所属的节点上。
+节点加入或离开时，哈希环上一部分键的归属发生变化：
+- 加入节点：新节点接管相邻区间的键，这些键在新节点上是冷的，命中率短暂下降
+- 移除节点：该节点缓存的数据全部丢失，它的键分散到其余节点，同样需要回源重新填充
+一致性哈希保证每次变化只迁移约 1/N 的键，而不是像取模哈希那样几乎全部重新分布。
+模拟按时间窗口统计命中率，量化每次拓扑变化带来的命中率下跌、迁移的键数和恢复所需的请求数。
+
+关键特点：
+1. 串联一致性哈希、淘汰策略和负载生成三个模块，任意已注册的淘汰策略都可以作为节点缓存
+2. 拓扑变化在指定的请求序号上发生，可以连续加入、移除多个节点
+3. 同时统计一致性哈希与取模哈希的迁移比例，直观对比两者的差异
+4. 输出事件报告表格和 ASCII 命中率时间线
+
+实现方式：
+- 每个节点是一个独立的缓存实例，请求先按哈希环路由到节点，未命中时回填该节点
+- 迁移比例按整个键空间计算：变化前后归属节点不同的键所占比例
+- 事件前若干个窗口的平均命中率作为基线，事件后命中率首次回到基线的一定比例即视为恢复
+
+应用场景：
+- 评估扩容、缩容、节点故障对缓存命中率和后端压力的影响
+- 选择虚拟节点数量和单节点容量
+- 教学中演示一致性哈希的价值
+
+优缺点：
+- 优点：结论可量化、可复现，运行速度快
+- 缺点：单线程模拟，不包含网络延迟和数据预热迁移；节点移除时假设数据全部丢失
+
+以下实现了缓存集群的扩缩容模拟和时间线可视化。
+*/
+
+import (
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/strive/scenario/practical_applications"
+	"github.com/strive/scenario/workload"
+)
+
+// ClusterEvent 拓扑变化事件
+type ClusterEvent struct {
+	At     int    // 发生在第几次请求之前
+	Node   string // 节点名称
+	Remove bool   // true 表示移除节点，否则为加入节点
+}
+
+// String 返回事件的描述
+func (e ClusterEvent) String() string {
+	if e.Remove {
+		return "移除 " + e.Node
+	}
+	return "加入 " + e.Node
+}
+
+// ClusterOptions 集群模拟选项
+type ClusterOptions struct {
+	Nodes             int            // 初始节点数
+	CapacityPerNode   int            // 每个节点的缓存容量
+	VirtualNodes      int            // 每个节点的虚拟节点数
+	Policy            string         // 节点缓存的淘汰策略
+	Keys              uint64         // 键空间大小
+	Skew              float64        // Zipf 分布的倾斜度
+	Requests          int            // 总请求数
+	Window            int            // 统计命中率的窗口大小（请求数）
+	BaselineWindows   int            // 事件前用于计算基线命中率的窗口数
+	RecoveryThreshold float64        // 命中率恢复到基线的比例
+	Seed              int64          // 负载随机数种子
+	Events            []ClusterEvent // 拓扑变化事件，按 At 排序
+}
+
+// DefaultClusterOptions 默认的集群模拟选项
+var DefaultClusterOptions = ClusterOptions{
+	Nodes:             4,
+	CapacityPerNode:   2000,
+	VirtualNodes:      practical_applications.DefaultVirtualNodes,
+	Policy:            "lru-2",
+	Keys:              100000,
+	Skew:              1.1,
+	Requests:          400000,
+	Window:            10000,
+	BaselineWindows:   3,
+	RecoveryThreshold: 0.95,
+	Seed:              1,
+}
+
+// ClusterWindow 一个统计窗口
+type ClusterWindow struct {
+	Start   int     // 窗口起始请求序号
+	HitRate float64 // 窗口内的命中率
+	Nodes   int     // 窗口结束时的节点数
+	Events  []int   // 窗口内发生的事件下标
+}
+
+// ClusterEventReport 一次拓扑变化的影响
+type ClusterEventReport struct {
+	Event            ClusterEvent
+	Applied          bool    // 事件是否生效（重复加入、移除不存在的节点时为 false）
+	MovedKeys        float64 // 一致性哈希下归属变化的键比例
+	ModuloMovedKeys  float64 // 取模哈希下归属变化的键比例
+	LostEntries      int     // 移除节点时丢失的缓存条目数
+	BaselineHitRate  float64 // 事件前的基线命中率
+	MinHitRate       float64 // 事件后到恢复前的最低窗口命中率
+	RecoveryRequests int     // 命中率恢复所需的请求数，-1 表示模拟结束前未恢复
+}
+
+// ClusterResult 集群模拟结果
+type ClusterResult struct {
+	Options ClusterOptions
+	Windows []ClusterWindow
+	Reports []ClusterEventReport
+	HitRate float64 // 整体命中率
+}
+
+// clusterNodeName 返回第 i 个初始节点的名称
+func clusterNodeName(i int) string {
+	return fmt.Sprintf("cache-%d", i+1)
+}
+
+// keyOwners 计算键空间中每个键的归属节点
+func keyOwners(ring *practical_applications.ConsistentHash, keys []string) []string {
+	owners := make([]string, len(keys))
+	for i, key := range keys {
+		owners[i], _ = ring.GetNode(key)
+	}
+	return owners
+}
+
+// moduloMoved 计算节点数从 before 变为 after 时取模哈希下归属变化的键比例
+func moduloMoved(keys []string, before, after int) float64 {
+	if before <= 0 || after <= 0 {
+		return 1
+	}
+	moved := 0
+	for _, key := range keys {
+		h := crc32.ChecksumIEEE([]byte(key))
+		if h%uint32(before) != h%uint32(after) {
+			moved++
+		}
+	}
+	return float64(moved) / float64(len(keys))
+}
+
+// SimulateCluster 运行集群模拟
+func SimulateCluster(options ClusterOptions) (*ClusterResult, error) {
+	factory, err := lookupPolicy(options.Policy)
+	if err != nil {
+		return nil, err
+	}
+	if options.Nodes <= 0 || options.CapacityPerNode <= 0 || options.Keys == 0 || options.Requests <= 0 {
+		return nil, fmt.Errorf("节点数、节点容量、键空间和请求数必须大于0")
+	}
+	if options.Window <= 0 {
+		options.Window = DefaultClusterOptions.Window
+	}
+	if options.BaselineWindows <= 0 {
+		options.BaselineWindows = DefaultClusterOptions.BaselineWindows
+	}
+	if options.RecoveryThreshold <= 0 {
+		options.RecoveryThreshold = DefaultClusterOptions.RecoveryThreshold
+	}
+	for i := 1; i < len(options.Events); i++ {
+		if options.Events[i].At < options.Events[i-1].At {
+			return nil, fmt.Errorf("事件必须按请求序号排序")
+		}
+	}
+
+	ring := practical_applications.NewConsistentHash(options.VirtualNodes)
+	caches := make(map[string]Cache)
+	for i := 0; i < options.Nodes; i++ {
+		name := clusterNodeName(i)
+		ring.AddNode(name)
+		caches[name] = factory(options.CapacityPerNode)
+	}
+
+	keySpace := make([]string, options.Keys)
+	for i := range keySpace {
+		keySpace[i] = workload.FormatKey(workload.DefaultKeyPrefix, uint64(i))
+	}
+
+	result := &ClusterResult{Options: options}
+	generator := workload.NewZipf(options.Keys, options.Skew, options.Seed)
+	next, hits, windowHits := 0, 0, 0
+	var window *ClusterWindow
+
+	for request := 0; request < options.Requests; request++ {
+		// 应用在本次请求之前发生的拓扑变化
+		for next < len(options.Events) && options.Events[next].At <= request {
+			event := options.Events[next]
+			report := ClusterEventReport{Event: event, RecoveryRequests: -1}
+			before := keyOwners(ring, keySpace)
+			nodesBefore := ring.GetNodeCount()
+			if event.Remove {
+				if cache, ok := caches[event.Node]; ok && nodesBefore > 1 {
+					ring.RemoveNode(event.Node)
+					if sized, ok := cache.(interface{ Size() int }); ok {
+						report.LostEntries = sized.Size()
+					}
+					delete(caches, event.Node)
+					report.Applied = true
+				}
+			} else if ring.AddNode(event.Node) {
+				caches[event.Node] = factory(options.CapacityPerNode)
+				report.Applied = true
+			}
+			if report.Applied {
+				after := keyOwners(ring, keySpace)
+				moved := 0
+				for i := range before {
+					if before[i] != after[i] {
+						moved++
+					}
+				}
+				report.MovedKeys = float64(moved) / float64(len(keySpace))
+				report.ModuloMovedKeys = moduloMoved(keySpace, nodesBefore, ring.GetNodeCount())
+			}
+			if window == nil {
+				window = &ClusterWindow{Start: request}
+			}
+			window.Events = append(window.Events, len(result.Reports))
+			result.Reports = append(result.Reports, report)
+			next++
+		}
+
+		if window == nil {
+			window = &ClusterWindow{Start: request}
+		}
+		key := keySpace[generator.Next()]
+		node, _ := ring.GetNode(key)
+		cache := caches[node]
+		if _, ok := cache.Get(key); ok {
+			hits++
+			windowHits++
+		} else {
+			cache.Put(key, struct{}{})
+		}
+
+		if request+1-window.Start == options.Window || request+1 == options.Requests {
+			window.HitRate = float64(windowHits) / float64(request+1-window.Start)
+			window.Nodes = ring.GetNodeCount()
+			result.Windows = append(result.Windows, *window)
+			window, windowHits = nil, 0
+		}
+	}
+	result.HitRate = float64(hits) / float64(options.Requests)
+	result.analyzeRecovery()
+	return result, nil
+}
+
+// analyzeRecovery 根据窗口命中率计算每个事件的基线、最低命中率和恢复时间
+func (r *ClusterResult) analyzeRecovery() {
+	for w, window := range r.Windows {
+		for _, index := range window.Events {
+			report := &r.Reports[index]
+
+			baseline, count := 0.0, 0
+			for i := w - 1; i >= 0 && count < r.Options.BaselineWindows; i-- {
+				baseline += r.Windows[i].HitRate
+				count++
+			}
+			if count == 0 {
+				continue // 第一个窗口之前没有基线
+			}
+			report.BaselineHitRate = baseline / float64(count)
+
+			report.MinHitRate = window.HitRate
+			target := report.BaselineHitRate * r.Options.RecoveryThreshold
+			for i := w; i < len(r.Windows); i++ {
+				if r.Windows[i].HitRate < report.MinHitRate {
+					report.MinHitRate = r.Windows[i].HitRate
+				}
+				if r.Windows[i].HitRate >= target {
+					end := r.Windows[i].Start + r.Options.Window
+					report.RecoveryRequests = end - report.Event.At
+					break
+				}
+			}
+		}
+	}
+}
+
+// WriteMarkdown 以Markdown表格输出每次拓扑变化的影响
+func (r *ClusterResult) WriteMarkdown(w io.Writer) {
+	o := r.Options
+	fmt.Fprintf(w, "%d 个节点 × 容量 %d（%s），键空间 %d，Zipf(%.2f)，%d 次请求，整体命中率 %.2f%%\n\n",
+		o.Nodes, o.CapacityPerNode, o.Policy, o.Keys, o.Skew, o.Requests, r.HitRate*100)
+	fmt.Fprintln(w, "| 请求序号 | 事件 | 迁移键比例 | 取模哈希迁移比例 | 丢失条目 | 基线命中率 | 最低命中率 | 恢复所需请求 |")
+	fmt.Fprintln(w, "|---:|---|---:|---:|---:|---:|---:|---:|")
+	for _, report := range r.Reports {
+		if !report.Applied {
+			fmt.Fprintf(w, "| %d | %s | - | - | - | - | - | 未生效 |\n", report.Event.At, report.Event)
+			continue
+		}
+		recovery := "未恢复"
+		if report.RecoveryRequests >= 0 {
+			recovery = fmt.Sprintf("%d", report.RecoveryRequests)
+		}
+		fmt.Fprintf(w, "| %d | %s | %.1f%% | %.1f%% | %d | %.2f%% | %.2f%% | %s |\n",
+			report.Event.At, report.Event, report.MovedKeys*100, report.ModuloMovedKeys*100,
+			report.LostEntries, report.BaselineHitRate*100, report.MinHitRate*100, recovery)
+	}
+}
+
+// WriteTimeline 输出 ASCII 命中率时间线，width 为命中率100%时条形的宽度
+func (r *ClusterResult) WriteTimeline(w io.Writer, width int) {
+	if width <= 0 {
+		width = 40
+	}
+	for _, window := range r.Windows {
+		filled := int(window.HitRate*float64(width) + 0.5)
+		bar := strings.Repeat("█", filled) + strings.Repeat("░", width-filled)
+		line := fmt.Sprintf("%8d %s %6.2f%% %d节点", window.Start, bar, window.HitRate*100, window.Nodes)
+		for _, index := range window.Events {
+			line += " ← " + r.Reports[index].Event.String()
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+// 场景示例：4 节点缓存集群在运行中扩容、节点故障、再恢复
+func ClusterSimDemo() {
+	fmt.Println("分布式缓存集群扩缩容模拟:")
+
+	options := DefaultClusterOptions
+	options.Events = []ClusterEvent{
+		{At: 100000, Node: "cache-5"},               // 扩容
+		{At: 200000, Node: "cache-2", Remove: true}, // 节点故障
+		{At: 300000, Node: "cache-2"},               // 故障节点恢复（缓存为空）
+	}
+	result, err := SimulateCluster(options)
+	if err != nil {
+		fmt.Printf("模拟失败: %v\n", err)
+		return
+	}
+
+	fmt.Println()
+	result.WriteMarkdown(os.Stdout)
+	fmt.Println("\n命中率时间线（每行一个窗口）:")
+	result.WriteTimeline(os.Stdout, 40)
+	fmt.Println("\n加入节点只迁移约 1/N 的键，取模哈希则几乎全部重新分布；节点恢复时它原来的键回到空缓存上，命中率再次下跌")
+}
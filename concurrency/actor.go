@@ -0,0 +1,374 @@
+package concurrency
+
+/*
+Actor 模型 - 邮箱与监督
+
+原理：
+共享内存加锁的并发模型中，状态被多个协程直接读写，锁的粒度、顺序稍有不慎就会出现竞争或死锁。
+Actor 模型把状态封装在 actor 内部，外部只能通过发送消息与它交互：
+每个 actor 有一个邮箱，一个专属协程按到达顺序逐条处理邮箱中的消息，
+同一时刻只有这个协程访问 actor 的状态，因此状态本身不需要加锁。
+处理消息时发生 panic 不会让整个进程崩溃，而是由监督者捕获，丢弃可能已经损坏的状态并重启 actor。
+
+关键特点：
+1. 顺序处理：消息按进入邮箱的顺序逐条处理，处理函数内部访问状态不需要加锁
+2. Tell 只投递消息不等待结果（发后即忘），Ask 投递消息并等待处理函数的返回值，可以用上下文控制等待时间
+3. 监督：处理消息时 panic，Ask 方收到 PanicError，actor 用工厂函数重新创建处理函数（即重建状态）后继续处理后续消息；
+   重启次数超过上限时 actor 停止，Err 返回停止原因
+4. Stop 不再接收新消息，处理完邮箱中已有的消息后退出
+5. Ask 的上下文在消息被处理前已经结束时，消息直接以上下文的错误完成，不再调用处理函数
+
+实现方式：
+- 邮箱是带缓冲的通道，邮箱满时 Tell、Ask 阻塞，形成背压
+- 读写锁保护"是否已停止"和关闭邮箱：发送方持有读锁发送，Stop 持有写锁关闭邮箱，避免向已关闭的通道发送
+- Ask 的回复通过 Promise 传递，等待时同时监听 actor 退出，actor 因重启次数超限退出时不会让 Ask 永远等待
+- 处理函数由工厂函数创建，状态放在工厂函数创建的闭包中，重启即调用工厂函数得到全新的状态
+
+应用场景：
+- 有状态的后台组件：心跳检测、会话管理、连接状态机
+- 需要严格按顺序处理事件的场景：账户流水、设备状态变更
+- 把"多个协程加锁修改同一份状态"改造为"多个协程给同一个 actor 发消息"
+
+优缺点：
+- 优点：状态无需加锁，并发推理简单；故障被隔离在单个 actor 内并能自动恢复
+- 缺点：单个 actor 是串行的，吞吐量受限于一个协程；Ask 比直接函数调用多了通道往返的开销；
+  重启会丢失内存中的状态，需要在工厂函数中从外部重建
+
+以下实现了泛型的 Actor，包含 Tell/Ask、panic 监督和重启上限，以及账户余额 actor 的示例。
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrActorStopped actor 已停止，不再接收消息
+var ErrActorStopped = errors.New("actor 已停止")
+
+// ActorHandler 处理一条消息，返回值作为 Ask 的回复，Tell 发送的消息忽略返回值
+type ActorHandler[M, R any] func(ctx context.Context, msg M) (R, error)
+
+// ActorOptions actor 配置
+type ActorOptions struct {
+	MailboxSize    int                                // 邮箱容量
+	MaxRestarts    int                                // 最大重启次数，超过后 actor 停止；小于0表示不限
+	RestartBackoff time.Duration                      // 重启前的等待时间
+	OnFailure      func(name string, err *PanicError) // 处理消息 panic 时调用，可以为 nil
+}
+
+// DefaultActorOptions 默认 actor 配置
+var DefaultActorOptions = ActorOptions{
+	MailboxSize:    64,
+	MaxRestarts:    10,
+	RestartBackoff: 0,
+}
+
+// envelope 邮箱中的一条消息
+type envelope[M, R any] struct {
+	ctx   context.Context
+	msg   M
+	reply *Promise[R] // Tell 发送的消息为 nil
+}
+
+// Actor 拥有邮箱的顺序消息处理者，处理消息 panic 时自动重启
+type Actor[M, R any] struct {
+	name       string
+	newHandler func() ActorHandler[M, R]
+	options    ActorOptions
+	mailbox    chan envelope[M, R]
+	mu         sync.RWMutex // 保护 stopped 和关闭邮箱
+	stopped    bool
+	done       chan struct{} // 处理协程退出时关闭
+	err        error         // 异常停止的原因，done 关闭后读取
+	processed  int64         // 处理完成的消息数
+	failures   int64         // 处理时 panic 的次数
+	restarts   int64         // 重启次数
+	expired    int64         // 处理前 Ask 的上下文已经结束而跳过的消息数
+}
+
+// NewActor 创建并启动 actor；newHandler 在启动和每次重启时调用，处理函数的状态应放在它创建的闭包中
+func NewActor[M, R any](name string, newHandler func() ActorHandler[M, R], options ActorOptions) *Actor[M, R] {
+	if options.MailboxSize <= 0 {
+		options.MailboxSize = DefaultActorOptions.MailboxSize
+	}
+	a := &Actor[M, R]{
+		name:       name,
+		newHandler: newHandler,
+		options:    options,
+		mailbox:    make(chan envelope[M, R], options.MailboxSize),
+		done:       make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+// Name 返回 actor 的名称
+func (a *Actor[M, R]) Name() string {
+	return a.name
+}
+
+// Tell 投递消息，不等待处理结果；邮箱满时阻塞，actor 已停止时返回 ErrActorStopped
+func (a *Actor[M, R]) Tell(msg M) error {
+	return a.send(envelope[M, R]{ctx: context.Background(), msg: msg})
+}
+
+// Ask 投递消息并等待处理函数的返回值；上下文先结束时返回上下文的错误，
+// 处理时 panic 返回 *PanicError，actor 在处理之前停止时返回 ErrActorStopped
+func (a *Actor[M, R]) Ask(ctx context.Context, msg M) (R, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var zero R
+	reply := NewPromise[R]()
+	if err := a.sendWithContext(ctx, envelope[M, R]{ctx: ctx, msg: msg, reply: reply}); err != nil {
+		return zero, err
+	}
+
+	future := reply.Future()
+	select {
+	case <-future.Done():
+		return future.Get(ctx)
+	case <-a.done:
+		// actor 退出前可能刚好处理完这条消息
+		if future.IsDone() {
+			return future.Get(ctx)
+		}
+		return zero, ErrActorStopped
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
+
+// send 把消息放入邮箱
+func (a *Actor[M, R]) send(env envelope[M, R]) error {
+	return a.sendWithContext(context.Background(), env)
+}
+
+// sendWithContext 把消息放入邮箱，邮箱满时等待到有空位、actor 退出或上下文结束
+func (a *Actor[M, R]) sendWithContext(ctx context.Context, env envelope[M, R]) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.stopped {
+		return ErrActorStopped
+	}
+	select {
+	case <-a.done:
+		return ErrActorStopped
+	default:
+	}
+	select {
+	case a.mailbox <- env:
+		return nil
+	case <-a.done:
+		return ErrActorStopped
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run 逐条处理邮箱中的消息，处理函数 panic 时按配置重启
+func (a *Actor[M, R]) run() {
+	defer close(a.done)
+
+	handler := a.newHandler()
+	for env := range a.mailbox {
+		if env.reply != nil && env.ctx.Err() != nil {
+			// 等待回复的一方已经放弃，不再处理
+			atomic.AddInt64(&a.expired, 1)
+			env.reply.Reject(env.ctx.Err())
+			continue
+		}
+
+		value, err, panicErr := a.invoke(handler, env)
+		if panicErr == nil {
+			atomic.AddInt64(&a.processed, 1)
+			if env.reply != nil {
+				env.reply.settle(value, err)
+			}
+			continue
+		}
+
+		// 监督：记录失败，丢弃可能已损坏的状态并重启
+		atomic.AddInt64(&a.failures, 1)
+		if env.reply != nil {
+			env.reply.Reject(panicErr)
+		}
+		if a.options.OnFailure != nil {
+			a.options.OnFailure(a.name, panicErr)
+		}
+		if a.options.MaxRestarts >= 0 && atomic.LoadInt64(&a.restarts) >= int64(a.options.MaxRestarts) {
+			a.err = fmt.Errorf("actor %s 重启次数超过上限 %d: %w", a.name, a.options.MaxRestarts, panicErr)
+			return
+		}
+		if a.options.RestartBackoff > 0 {
+			time.Sleep(a.options.RestartBackoff)
+		}
+		handler = a.newHandler()
+		atomic.AddInt64(&a.restarts, 1)
+	}
+}
+
+// invoke 调用处理函数，把 panic 转换为 PanicError
+func (a *Actor[M, R]) invoke(handler ActorHandler[M, R], env envelope[M, R]) (value R, err error, panicErr *PanicError) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicErr = newPanicError(r)
+		}
+	}()
+	value, err = handler(env.ctx, env.msg)
+	return value, err, nil
+}
+
+// Stop 停止接收新消息，等待邮箱中已有的消息处理完毕；重复调用是安全的
+func (a *Actor[M, R]) Stop() {
+	a.mu.Lock()
+	if !a.stopped {
+		a.stopped = true
+		close(a.mailbox)
+	}
+	a.mu.Unlock()
+	<-a.done
+}
+
+// Done 返回 actor 退出时关闭的通道
+func (a *Actor[M, R]) Done() <-chan struct{} {
+	return a.done
+}
+
+// Err 返回 actor 异常停止的原因；仍在运行或通过 Stop 正常停止时返回 nil
+func (a *Actor[M, R]) Err() error {
+	select {
+	case <-a.done:
+		return a.err
+	default:
+		return nil
+	}
+}
+
+// Stats 返回 actor 的统计信息
+func (a *Actor[M, R]) Stats() map[string]interface{} {
+	a.mu.RLock()
+	stopped := a.stopped
+	a.mu.RUnlock()
+	select {
+	case <-a.done:
+		stopped = true
+	default:
+	}
+	return map[string]interface{}{
+		"name":            a.name,
+		"mailboxSize":     len(a.mailbox),
+		"mailboxCapacity": cap(a.mailbox),
+		"processed":       atomic.LoadInt64(&a.processed),
+		"failures":        atomic.LoadInt64(&a.failures),
+		"restarts":        atomic.LoadInt64(&a.restarts),
+		"expired":         atomic.LoadInt64(&a.expired),
+		"stopped":         stopped,
+	}
+}
+
+// 场景示例：账户余额由一个 actor 持有，并发的存取款消息按顺序处理，异常消息触发重启并从账本重建余额
+func ActorDemo() {
+	fmt.Println("Actor 示例 (账户余额):")
+
+	type accountMsg struct {
+		op     string // deposit / withdraw / balance / corrupt
+		amount int64
+	}
+	errInsufficient := errors.New("余额不足")
+
+	// 账本是 actor 外部的持久化记录，重启时从它重建内存中的余额
+	var ledgerMu sync.Mutex
+	var ledger []int64
+	newAccount := func() ActorHandler[accountMsg, int64] {
+		ledgerMu.Lock()
+		balance := int64(0)
+		for _, delta := range ledger {
+			balance += delta
+		}
+		ledgerMu.Unlock()
+
+		return func(ctx context.Context, msg accountMsg) (int64, error) {
+			switch msg.op {
+			case "deposit":
+				balance += msg.amount
+			case "withdraw":
+				if balance < msg.amount {
+					return balance, errInsufficient
+				}
+				balance -= msg.amount
+			case "corrupt":
+				var risk map[string]int64
+				risk["score"] = msg.amount // 写入 nil map 导致 panic
+			case "balance":
+				return balance, nil
+			}
+			ledgerMu.Lock()
+			if msg.op == "deposit" {
+				ledger = append(ledger, msg.amount)
+			} else {
+				ledger = append(ledger, -msg.amount)
+			}
+			ledgerMu.Unlock()
+			return balance, nil
+		}
+	}
+
+	var failures []string
+	account := NewActor("account-1001", newAccount, ActorOptions{
+		MailboxSize: 16,
+		MaxRestarts: 1,
+		OnFailure: func(name string, err *PanicError) {
+			failures = append(failures, fmt.Sprintf("%s: %v", name, err.Value))
+		},
+	})
+
+	// 100 个协程并发存入 10 元，余额不需要加锁
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			account.Tell(accountMsg{op: "deposit", amount: 10})
+		}()
+	}
+	wg.Wait()
+	balance, _ := account.Ask(context.Background(), accountMsg{op: "balance"})
+	fmt.Printf("100 次并发存款后余额: %d\n", balance)
+
+	balance, err := account.Ask(context.Background(), accountMsg{op: "withdraw", amount: 5000})
+	fmt.Printf("取款 5000: 余额 %d，错误: %v\n", balance, err)
+
+	// 监督：处理消息 panic，Ask 方收到 PanicError，actor 从账本重建余额后继续服务
+	_, err = account.Ask(context.Background(), accountMsg{op: "corrupt", amount: 1})
+	var panicErr *PanicError
+	fmt.Printf("异常消息: 收到 PanicError=%v\n", errors.As(err, &panicErr))
+	balance, _ = account.Ask(context.Background(), accountMsg{op: "withdraw", amount: 300})
+	fmt.Printf("重启后取款 300: 余额 %d\n", balance)
+
+	// Ask 超时：上下文在消息被处理前结束
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	_, err = account.Ask(ctx, accountMsg{op: "balance"})
+	cancel()
+	fmt.Printf("上下文已结束的 Ask: %v\n", err)
+
+	// 重启次数超过上限后 actor 停止
+	account.Ask(context.Background(), accountMsg{op: "corrupt", amount: 2})
+	<-account.Done()
+	fmt.Printf("第二次异常后: %v\n", account.Err())
+	fmt.Printf("停止后 Tell: %v\n", account.Tell(accountMsg{op: "deposit", amount: 1}))
+	for _, failure := range failures {
+		fmt.Printf("监督记录 %s\n", failure)
+	}
+
+	stats := account.Stats()
+	fmt.Printf("统计: 处理 %d 条，失败 %d 次，重启 %d 次，已停止 %v\n",
+		stats["processed"], stats["failures"], stats["restarts"], stats["stopped"])
+	account.Stop()
+}
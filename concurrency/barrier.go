@@ -0,0 +1,342 @@
+package concurrency
+
+/*
+循环屏障（CyclicBarrier）与倒计时门闩（CountDownLatch）
+
+原理：
+分阶段的并行计算要求所有协程完成第 N 阶段之后，任何一个协程才能开始第 N+1 阶段，
+例如迭代求解时每一轮都要读取上一轮全部分块的结果。
+循环屏障让一组固定数量的协程在屏障处互相等待，最后一个到达的协程打开屏障，所有协程一起进入下一阶段，
+屏障随即自动复位，可以在下一阶段继续使用。
+倒计时门闩是一次性的：计数减到0时打开，之后所有等待都立即返回，
+适合"等待 N 件事情完成"（例如所有依赖服务初始化完毕）而等待者与完成者不是同一组协程的场景。
+
+关键特点：
+1. 屏障可重复使用，每一轮称为一代；可以设置屏障动作，由最后到达的协程在放行所有协程之前执行，
+   用来合并本阶段的结果
+2. 任何一个等待者超时或上下文取消、或屏障动作 panic 时屏障被破坏，本代其他等待者立即返回 ErrBarrierBroken，
+   避免一个协程出错后其余协程永远等待；Reset 之后可以重新使用
+3. Await 返回到达序号：0 表示最后一个到达，可以用来挑选一个协程做收尾工作
+4. 门闩的计数只减不增，打开后不能复位；CountDown 可以在任意协程中调用，调用次数多于计数时忽略
+
+实现方式：
+- 屏障用互斥锁保护到达计数，每一代有一个通道，本代结束（放行或破坏）时关闭，等待者在通道和上下文之间 select
+- 门闩用互斥锁保护计数，减到0时关闭通道
+
+应用场景：
+- 分阶段的并行计算：迭代求解、模拟仿真的每一步、多轮归并
+- 压测时让所有协程同时开始发请求
+- 等待多个依赖初始化完毕后再对外提供服务
+
+优缺点：
+- 优点：阶段之间的同步语义清晰，不需要为每一阶段重新创建 WaitGroup；破坏机制避免了部分失败时的永久等待
+- 缺点：屏障的参与者数量固定，中途有协程退出时需要破坏屏障；各阶段耗时不均时快的协程在屏障处空等
+
+以下实现了可复用的循环屏障和一次性的倒计时门闩，以及分阶段热传导仿真的示例。
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrBarrierBroken 屏障已被破坏：有等待者超时、取消或屏障被重置
+var ErrBarrierBroken = errors.New("屏障已被破坏")
+
+// barrierGeneration 屏障的一代
+type barrierGeneration struct {
+	done   chan struct{} // 本代放行或被破坏时关闭
+	broken bool          // 关闭 done 之前设置，读取方在 done 关闭后读取
+}
+
+// CyclicBarrier 循环屏障，固定数量的协程互相等待，全部到达后一起放行并自动复位
+type CyclicBarrier struct {
+	mu      sync.Mutex
+	parties int                // 参与的协程数
+	action  func()             // 最后到达的协程在放行前执行，可以为 nil
+	waiting int                // 本代已到达的协程数
+	gen     *barrierGeneration // 当前代
+	trips   int                // 已放行的代数
+	breaks  int                // 被破坏的次数
+}
+
+// NewCyclicBarrier 创建 parties 个协程参与的循环屏障，action 在每一代全部到达后、放行前执行，可以为 nil
+func NewCyclicBarrier(parties int, action func()) *CyclicBarrier {
+	if parties <= 0 {
+		parties = 1
+	}
+	return &CyclicBarrier{parties: parties, action: action, gen: &barrierGeneration{done: make(chan struct{})}}
+}
+
+// Await 等待所有协程到达，返回到达序号（parties-1 表示第一个到达，0 表示最后一个到达）
+func (b *CyclicBarrier) Await() (int, error) {
+	return b.AwaitWithContext(context.Background())
+}
+
+// AwaitWithTimeout 在指定时间内等待所有协程到达，超时时破坏屏障并返回 context.DeadlineExceeded
+func (b *CyclicBarrier) AwaitWithTimeout(timeout time.Duration) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return b.AwaitWithContext(ctx)
+}
+
+// AwaitWithContext 等待所有协程到达，上下文先结束时破坏屏障并返回上下文的错误；
+// 屏障被其他等待者破坏时返回 ErrBarrierBroken
+func (b *CyclicBarrier) AwaitWithContext(ctx context.Context) (int, error) {
+	b.mu.Lock()
+	gen := b.gen
+	if gen.broken {
+		b.mu.Unlock()
+		return 0, ErrBarrierBroken
+	}
+	if ctx.Err() != nil {
+		b.breakLocked()
+		b.mu.Unlock()
+		return 0, ctx.Err()
+	}
+
+	b.waiting++
+	index := b.parties - b.waiting
+	if index == 0 {
+		// 最后一个到达：执行屏障动作，放行本代并开始下一代；
+		// 屏障动作 panic 时破坏本代并释放锁，panic 继续传给调用方
+		defer b.mu.Unlock()
+		if b.action != nil {
+			completed := false
+			defer func() {
+				if !completed {
+					b.breakLocked()
+				}
+			}()
+			b.action()
+			completed = true
+		}
+		b.trips++
+		b.nextGenerationLocked()
+		return 0, nil
+	}
+	b.mu.Unlock()
+
+	select {
+	case <-gen.done:
+		if gen.broken {
+			return index, ErrBarrierBroken
+		}
+		return index, nil
+	case <-ctx.Done():
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if gen != b.gen {
+		// 上下文结束的同时本代已经结束
+		if gen.broken {
+			return index, ErrBarrierBroken
+		}
+		return index, nil
+	}
+	b.breakLocked()
+	return index, ctx.Err()
+}
+
+// nextGenerationLocked 结束当前代并开始新的一代，调用方需持有 mu
+func (b *CyclicBarrier) nextGenerationLocked() {
+	close(b.gen.done)
+	b.gen = &barrierGeneration{done: make(chan struct{})}
+	b.waiting = 0
+}
+
+// breakLocked 破坏当前代，唤醒所有等待者，调用方需持有 mu
+func (b *CyclicBarrier) breakLocked() {
+	if b.gen.broken {
+		return
+	}
+	b.gen.broken = true
+	b.waiting = 0
+	b.breaks++
+	close(b.gen.done)
+}
+
+// IsBroken 返回屏障当前是否处于破坏状态
+func (b *CyclicBarrier) IsBroken() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.gen.broken
+}
+
+// Reset 复位屏障：正在等待的协程收到 ErrBarrierBroken，之后屏障可以重新使用
+func (b *CyclicBarrier) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.waiting > 0 {
+		b.breakLocked()
+	}
+	if b.gen.broken {
+		b.gen = &barrierGeneration{done: make(chan struct{})}
+	}
+}
+
+// Stats 返回屏障的统计信息
+func (b *CyclicBarrier) Stats() map[string]interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return map[string]interface{}{
+		"parties": b.parties,
+		"waiting": b.waiting,
+		"trips":   b.trips,
+		"breaks":  b.breaks,
+		"broken":  b.gen.broken,
+	}
+}
+
+// CountDownLatch 倒计时门闩，计数减到0时打开，一次性使用
+type CountDownLatch struct {
+	mu    sync.Mutex
+	count int
+	done  chan struct{} // 计数减到0时关闭
+}
+
+// NewCountDownLatch 创建计数为 count 的门闩，count 小于等于0时门闩直接打开
+func NewCountDownLatch(count int) *CountDownLatch {
+	l := &CountDownLatch{count: max(count, 0), done: make(chan struct{})}
+	if l.count == 0 {
+		close(l.done)
+	}
+	return l
+}
+
+// CountDown 计数减1，减到0时打开门闩；门闩已打开时忽略
+func (l *CountDownLatch) CountDown() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.count == 0 {
+		return
+	}
+	l.count--
+	if l.count == 0 {
+		close(l.done)
+	}
+}
+
+// Count 返回剩余计数
+func (l *CountDownLatch) Count() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.count
+}
+
+// Await 等待门闩打开
+func (l *CountDownLatch) Await() {
+	<-l.done
+}
+
+// AwaitWithTimeout 在指定时间内等待门闩打开，超时返回false
+func (l *CountDownLatch) AwaitWithTimeout(timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return l.AwaitWithContext(ctx)
+}
+
+// AwaitWithContext 在上下文结束前等待门闩打开，门闩已打开时总是返回true
+func (l *CountDownLatch) AwaitWithContext(ctx context.Context) bool {
+	select {
+	case <-l.done:
+		return true
+	default:
+	}
+	select {
+	case <-l.done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Done 返回门闩打开时关闭的通道
+func (l *CountDownLatch) Done() <-chan struct{} {
+	return l.done
+}
+
+// 场景示例：一维杆的热传导仿真，每一步所有分块计算完成后才能进入下一步
+func BarrierDemo() {
+	fmt.Println("循环屏障与倒计时门闩示例 (热传导仿真):")
+
+	const (
+		workers = 4
+		cells   = 40
+		steps   = 200
+	)
+	// 杆的左端保持100度，其余初始为0度
+	current := make([]float64, cells)
+	next := make([]float64, cells)
+	current[0], next[0] = 100, 100
+
+	// 门闩：所有工作协程加载完自己的分块后，主协程才开始计时
+	ready := NewCountDownLatch(workers)
+	step := 0
+	var history []string
+	// 屏障动作：本步全部分块算完后交换新旧数组，由最后到达的协程执行，此时其他协程都在屏障处等待
+	barrier := NewCyclicBarrier(workers, func() {
+		current, next = next, current
+		step++
+		if step%50 == 0 {
+			total := 0.0
+			for _, t := range current {
+				total += t
+			}
+			history = append(history, fmt.Sprintf("第%3d步: 中点温度 %5.2f 度，平均温度 %5.2f 度", step, current[cells/2], total/cells))
+		}
+	})
+
+	var wg sync.WaitGroup
+	chunk := cells / workers
+	lastArrivals := make([]int, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			lo, hi := w*chunk, (w+1)*chunk
+			ready.CountDown()
+			for s := 0; s < steps; s++ {
+				for i := max(lo, 1); i < hi; i++ {
+					right := current[i]
+					if i+1 < cells {
+						right = current[i+1]
+					}
+					next[i] = current[i] + 0.4*(current[i-1]-2*current[i]+right)
+				}
+				// 第 s 步所有分块写完 next 之前，任何协程都不能开始第 s+1 步
+				if index, err := barrier.Await(); err != nil {
+					return
+				} else if index == 0 {
+					lastArrivals[w]++
+				}
+			}
+		}(w)
+	}
+
+	ready.Await()
+	start := time.Now()
+	fmt.Printf("%d 个分块加载完毕，门闩剩余计数 %d\n", workers, ready.Count())
+	wg.Wait()
+	for _, line := range history {
+		fmt.Println(line)
+	}
+	stats := barrier.Stats()
+	fmt.Printf("%d 步完成，屏障放行 %d 次，耗时 %v，各分块最后到达的次数: %v\n",
+		steps, stats["trips"], time.Since(start).Round(time.Millisecond), lastArrivals)
+
+	// 破坏：一个协程等待超时，同一代的其他等待者不会永远阻塞
+	broken := NewCyclicBarrier(3, nil)
+	results := make(chan error, 2)
+	go func() { _, err := broken.AwaitWithTimeout(20 * time.Millisecond); results <- err }()
+	go func() { _, err := broken.Await(); results <- err }()
+	// 第三个参与者迟迟不到
+	fmt.Printf("\n一个参与者缺席时: %v / %v\n", <-results, <-results)
+	fmt.Printf("屏障已破坏: %v，复位后", broken.IsBroken())
+	broken.Reset()
+	fmt.Printf("已破坏: %v\n", broken.IsBroken())
+}
@@ -0,0 +1,57 @@
+package concurrency
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// 屏障动作 panic 时本代被破坏、锁被释放：其他等待者返回 ErrBarrierBroken，Reset 之后可以继续使用
+func TestCyclicBarrierActionPanic(t *testing.T) {
+	fail := true
+	b := NewCyclicBarrier(2, func() {
+		if fail {
+			panic("屏障动作失败")
+		}
+	})
+
+	waiter := make(chan error, 1)
+	go func() {
+		_, err := b.AwaitWithTimeout(time.Second)
+		waiter <- err
+	}()
+	for b.Stats()["waiting"] != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("屏障动作的 panic 应传给最后到达的协程")
+			}
+		}()
+		b.Await()
+	}()
+
+	if err := <-waiter; !errors.Is(err, ErrBarrierBroken) {
+		t.Fatalf("其他等待者返回 %v，期望 ErrBarrierBroken", err)
+	}
+	if !b.IsBroken() {
+		t.Fatal("屏障动作 panic 后屏障应处于破坏状态")
+	}
+
+	fail = false
+	b.Reset()
+	done := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := b.AwaitWithTimeout(time.Second)
+			done <- err
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != nil {
+			t.Fatalf("Reset 之后 Await 失败: %v", err)
+		}
+	}
+}
@@ -0,0 +1,337 @@
+package concurrency
+
+/*
+批量消费者
+
+原理：
+日志、指标等数据量大、单条很小，逐条写入存储时每条都要付出一次网络往返和事务开销。
+批量消费者从队列中攒够一批再统一处理：批次攒满立即发送（按大小触发），
+流量低时等待最多一段时间也会发送（按时间触发），在吞吐量和延迟之间取得平衡。
+批量写入的失败往往只涉及其中几条（例如个别字段不合法），
+因此处理函数可以报告"部分失败"，只重试失败的条目，而不是整批重发。
+
+关键特点：
+1. 批次大小和最长等待时间两个触发条件，先满足哪个就发送哪个
+2. 处理函数返回 *BatchError 表示部分失败，只重试失败的条目
+3. 重试采用指数退避，重试耗尽的条目交给失败回调（例如写入死信队列）
+4. 队列关闭后发送最后一个不完整的批次，保证已入队的数据不丢失
+
+实现方式：
+- 一个读取协程通过 DequeueWithContext 从有界队列取数据（自动丢弃已过期的任务）并转发到通道
+- 批处理协程在通道和定时器之间 select，满足任一条件就发送批次
+- 批次的处理在批处理协程中串行进行，天然形成背压：处理变慢时队列被填满，生产者阻塞
+
+应用场景：
+- 日志、埋点、监控指标批量写入存储
+- 消息批量投递、数据库批量插入
+- 合并多个小请求为一次远程调用
+
+优缺点：
+- 优点：大幅减少写入次数，部分失败只重试必要的条目
+- 缺点：单条数据的延迟最多增加一个等待时间；处理函数需要能够报告哪些条目失败
+
+以下实现了基于有界队列的批量消费者。
+*/
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BatchHandler 批次处理函数，返回 *BatchError 表示部分失败
+type BatchHandler func(batch []interface{}) error
+
+// BatchError 批次部分失败，记录失败条目在批次中的下标和原因
+type BatchError struct {
+	Failed map[int]error
+}
+
+// Error 返回错误描述
+func (e *BatchError) Error() string {
+	indexes := make([]int, 0, len(e.Failed))
+	for i := range e.Failed {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	parts := make([]string, 0, len(indexes))
+	for _, i := range indexes {
+		parts = append(parts, fmt.Sprintf("#%d: %v", i, e.Failed[i]))
+	}
+	return fmt.Sprintf("批次中 %d 条失败: %s", len(e.Failed), strings.Join(parts, "; "))
+}
+
+// BatchConsumerOptions 批量消费者配置
+type BatchConsumerOptions struct {
+	MaxBatchSize int                                  // 批次大小上限，攒满立即发送
+	MaxWait      time.Duration                        // 批次中第一条数据最多等待的时间
+	MaxRetries   int                                  // 失败条目的最大重试次数
+	RetryBackoff time.Duration                        // 首次重试的等待时间，之后每次翻倍
+	OnFailure    func(items []interface{}, err error) // 重试耗尽的条目回调，例如写入死信队列
+}
+
+// DefaultBatchConsumerOptions 默认批量消费者配置
+var DefaultBatchConsumerOptions = BatchConsumerOptions{
+	MaxBatchSize: 100,
+	MaxWait:      time.Second,
+	MaxRetries:   3,
+	RetryBackoff: 50 * time.Millisecond,
+}
+
+// BatchConsumer 批量消费者
+type BatchConsumer struct {
+	queue   *BoundedQueue
+	handler BatchHandler
+	options BatchConsumerOptions
+	items   chan interface{} // 读取协程转发的数据
+	done    chan struct{}    // 批处理协程退出后关闭
+	once    sync.Once
+
+	batches      int64 // 发送的批次数
+	sizeFlushes  int64 // 按大小触发的批次数
+	timeFlushes  int64 // 按时间触发的批次数
+	retries      int64 // 重试次数
+	succeeded    int64 // 处理成功的条目数
+	failed       int64 // 重试耗尽的条目数
+	handlerCalls int64 // 处理函数的调用次数（含重试）
+}
+
+// NewBatchConsumer 创建批量消费者，需要调用 Start 开始消费
+func NewBatchConsumer(queue *BoundedQueue, handler BatchHandler, options BatchConsumerOptions) *BatchConsumer {
+	if options.MaxBatchSize <= 0 {
+		options.MaxBatchSize = DefaultBatchConsumerOptions.MaxBatchSize
+	}
+	if options.MaxWait <= 0 {
+		options.MaxWait = DefaultBatchConsumerOptions.MaxWait
+	}
+	if options.MaxRetries < 0 {
+		options.MaxRetries = 0
+	}
+	return &BatchConsumer{
+		queue:   queue,
+		handler: handler,
+		options: options,
+		items:   make(chan interface{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// Start 启动读取协程和批处理协程，重复调用无效
+func (c *BatchConsumer) Start() {
+	c.once.Do(func() {
+		go c.read()
+		go c.run()
+	})
+}
+
+// read 从队列读取数据转发给批处理协程，队列关闭且为空时结束
+func (c *BatchConsumer) read() {
+	defer close(c.items)
+	for {
+		item, _, err := c.queue.DequeueWithContext()
+		if err != nil {
+			return
+		}
+		c.items <- item
+	}
+}
+
+// run 攒批并发送，满足大小或时间条件时触发
+func (c *BatchConsumer) run() {
+	defer close(c.done)
+
+	batch := make([]interface{}, 0, c.options.MaxBatchSize)
+	timer := time.NewTimer(c.options.MaxWait)
+	timer.Stop()
+
+	for {
+		select {
+		case item, ok := <-c.items:
+			if !ok {
+				timer.Stop()
+				if len(batch) > 0 {
+					c.flush(batch)
+				}
+				return
+			}
+			// 批次中的第一条数据开始计时
+			if len(batch) == 0 {
+				timer.Reset(c.options.MaxWait)
+			}
+			batch = append(batch, item)
+			if len(batch) >= c.options.MaxBatchSize {
+				timer.Stop()
+				atomic.AddInt64(&c.sizeFlushes, 1)
+				c.flush(batch)
+				batch = make([]interface{}, 0, c.options.MaxBatchSize)
+			}
+		case <-timer.C:
+			if len(batch) > 0 {
+				atomic.AddInt64(&c.timeFlushes, 1)
+				c.flush(batch)
+				batch = make([]interface{}, 0, c.options.MaxBatchSize)
+			}
+		}
+	}
+}
+
+// flush 处理一个批次，部分失败时只重试失败的条目
+func (c *BatchConsumer) flush(batch []interface{}) {
+	atomic.AddInt64(&c.batches, 1)
+	pending := batch
+	backoff := c.options.RetryBackoff
+
+	for attempt := 0; ; attempt++ {
+		atomic.AddInt64(&c.handlerCalls, 1)
+		err := c.handler(pending)
+		if err == nil {
+			atomic.AddInt64(&c.succeeded, int64(len(pending)))
+			return
+		}
+
+		// 部分失败：成功的条目不再重试
+		var batchErr *BatchError
+		if errors.As(err, &batchErr) {
+			failed := make([]interface{}, 0, len(batchErr.Failed))
+			for i, item := range pending {
+				if _, ok := batchErr.Failed[i]; ok {
+					failed = append(failed, item)
+				}
+			}
+			atomic.AddInt64(&c.succeeded, int64(len(pending)-len(failed)))
+			pending = failed
+			if len(pending) == 0 {
+				return
+			}
+		}
+
+		if attempt >= c.options.MaxRetries {
+			atomic.AddInt64(&c.failed, int64(len(pending)))
+			if c.options.OnFailure != nil {
+				c.options.OnFailure(pending, err)
+			}
+			return
+		}
+
+		atomic.AddInt64(&c.retries, 1)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// Close 关闭队列并等待剩余数据处理完毕
+func (c *BatchConsumer) Close() {
+	c.queue.Close()
+	c.Start()
+	<-c.done
+}
+
+// Done 返回批处理协程退出时关闭的通道
+func (c *BatchConsumer) Done() <-chan struct{} {
+	return c.done
+}
+
+// Stats 返回批量消费者的统计信息
+func (c *BatchConsumer) Stats() map[string]interface{} {
+	batches := atomic.LoadInt64(&c.batches)
+	succeeded := atomic.LoadInt64(&c.succeeded)
+	failed := atomic.LoadInt64(&c.failed)
+
+	avgBatchSize := 0.0
+	if batches > 0 {
+		avgBatchSize = float64(succeeded+failed) / float64(batches)
+	}
+	return map[string]interface{}{
+		"batches":      batches,
+		"sizeFlushes":  atomic.LoadInt64(&c.sizeFlushes),
+		"timeFlushes":  atomic.LoadInt64(&c.timeFlushes),
+		"handlerCalls": atomic.LoadInt64(&c.handlerCalls),
+		"retries":      atomic.LoadInt64(&c.retries),
+		"succeeded":    succeeded,
+		"failed":       failed,
+		"avgBatchSize": avgBatchSize,
+	}
+}
+
+// 场景示例：日志批量写入存储，存储偶尔拒绝个别条目
+func BatchConsumerDemo() {
+	fmt.Println("批量消费者示例（日志批量写入存储）:")
+
+	queue := NewBoundedQueue(50)
+
+	// 模拟存储：包含"非法"的日志永远写入失败，其他日志第一次写入时每7条失败1条
+	var mu sync.Mutex
+	stored := 0
+	attempts := make(map[string]int)
+	store := func(batch []interface{}) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		failed := make(map[int]error)
+		for i, item := range batch {
+			log := item.(string)
+			attempts[log]++
+			switch {
+			case strings.Contains(log, "非法"):
+				failed[i] = errors.New("格式错误")
+			case attempts[log] == 1 && len(attempts)%7 == 0:
+				failed[i] = errors.New("写入超时")
+			default:
+				stored++
+			}
+		}
+		fmt.Printf("  写入批次: %d 条，失败 %d 条\n", len(batch), len(failed))
+		if len(failed) > 0 {
+			return &BatchError{Failed: failed}
+		}
+		return nil
+	}
+
+	options := DefaultBatchConsumerOptions
+	options.MaxBatchSize = 10
+	options.MaxWait = 100 * time.Millisecond
+	options.MaxRetries = 2
+	options.RetryBackoff = 10 * time.Millisecond
+	options.OnFailure = func(items []interface{}, err error) {
+		fmt.Printf("  重试耗尽，转入死信队列: %v（%v）\n", items, err)
+	}
+
+	consumer := NewBatchConsumer(queue, store, options)
+	consumer.Start()
+
+	// 高峰期：一次产生25条日志，按大小触发
+	fmt.Println("\n高峰期快速产生25条日志:")
+	for i := 0; i < 25; i++ {
+		log := fmt.Sprintf("log-%02d", i)
+		if i == 13 {
+			log = "log-13-非法"
+		}
+		queue.Enqueue(log)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	// 低峰期：零星日志，按时间触发
+	fmt.Println("\n低峰期零星产生3条日志:")
+	for i := 25; i < 28; i++ {
+		queue.Enqueue(fmt.Sprintf("log-%02d", i))
+		time.Sleep(30 * time.Millisecond)
+	}
+	time.Sleep(150 * time.Millisecond)
+
+	// 关闭时发送最后一个不完整的批次
+	fmt.Println("\n关闭前再产生2条日志:")
+	queue.Enqueue("log-28")
+	queue.Enqueue("log-29")
+	consumer.Close()
+
+	stats := consumer.Stats()
+	fmt.Printf("\n统计: 批次=%d（按大小 %d，按时间 %d），处理函数调用=%d，重试=%d\n",
+		stats["batches"], stats["sizeFlushes"], stats["timeFlushes"], stats["handlerCalls"], stats["retries"])
+	fmt.Printf("成功=%d，失败=%d，平均批次大小=%.1f，存储中共 %d 条\n",
+		stats["succeeded"], stats["failed"], stats["avgBatchSize"], stored)
+}
@@ -0,0 +1,396 @@
+package concurrency
+
+/*
+广播中心（Broadcast Hub，扇出式发布订阅）
+
+原理：
+Go 的通道是点对点的：一条消息只会被一个接收者取走。监控面板、审计日志、告警推送都想看到同一份事件流时，
+发布者要么为每个观察者各发一次（发布者必须知道所有观察者），要么自己维护一组通道并处理关闭、满载等细节。
+广播中心把这部分逻辑集中起来：发布者只发送一次，每个订阅者有自己的带缓冲通道，广播中心负责把消息复制到每个通道。
+真正的难点在于慢消费者：某个订阅者处理不过来、缓冲区满了以后怎么办，不同的订阅者需要不同的答案。
+
+关键特点：
+1. 发布一次，所有订阅者各自收到一份，订阅者之间互不影响
+2. 每个订阅者独立选择慢消费者策略：
+   - DropOldest：丢弃缓冲区中最旧的消息再放入新消息，发布者从不阻塞，订阅者总能看到最新的状态（适合监控面板）
+   - Block：发布者等待订阅者腾出空间，不丢消息，可设置最长等待时间，超时后丢弃本条（适合审计日志）
+   - Disconnect：缓冲区满时断开该订阅者并关闭其通道，Err 返回 ErrSlowConsumer，由订阅者决定是否重新订阅（适合外部推送）
+3. 取消订阅或关闭广播中心时关闭订阅通道，消费者的 range 循环自然结束
+4. 零值可直接使用，并发安全
+
+实现方式：
+- 订阅列表采用写时复制的切片：订阅和取消订阅时复制一份新切片，发布时只在读锁下取当前切片，发送时不持有广播中心的锁，
+  Block 策略的订阅者阻塞发布者时不会卡住其他协程的订阅和取消订阅
+- 每个订阅者有自己的互斥锁保护通道的发送和关闭，避免向已关闭的通道发送；
+  另有一个 done 通道，取消订阅时先关闭它，让阻塞在发送上的发布者立即放弃并释放锁
+- DropOldest 在订阅者的锁内先从通道中取出一条最旧的消息，再放入新消息
+
+应用场景：
+- 缓存变更事件同时交给指标采集器和副本缓存
+- 容灾系统的状态变化同时推送给监控面板、审计日志和外部告警
+- 配置变更、行情推送等一对多的通知
+
+优缺点：
+- 优点：发布者与订阅者解耦；慢消费者的处理方式可按订阅者选择，不会因为一个订阅者拖垮全部
+- 缺点：每个订阅者都持有一份消息副本；Block 策略会把订阅者的处理速度传导给发布者；消息只在进程内传递，不持久化
+
+以下实现了泛型的广播中心，并演示三种慢消费者策略的行为差异。
+*/
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrSlowConsumer 订阅者处理过慢，缓冲区满后被断开
+var ErrSlowConsumer = errors.New("订阅者处理过慢，已断开")
+
+// SlowConsumerPolicy 订阅者缓冲区满时的处理策略
+type SlowConsumerPolicy int
+
+const (
+	DropOldest SlowConsumerPolicy = iota // 丢弃最旧的消息，放入新消息
+	Block                                // 等待订阅者腾出空间
+	Disconnect                           // 断开订阅者
+)
+
+// String 返回策略名称
+func (p SlowConsumerPolicy) String() string {
+	switch p {
+	case DropOldest:
+		return "丢弃最旧"
+	case Block:
+		return "阻塞"
+	case Disconnect:
+		return "断开"
+	default:
+		return "未知策略"
+	}
+}
+
+// SubscribeOptions 订阅选项
+type SubscribeOptions struct {
+	Buffer       int                // 通道缓冲大小，小于等于0时使用 DefaultSubscribeOptions.Buffer
+	Policy       SlowConsumerPolicy // 缓冲区满时的处理策略
+	BlockTimeout time.Duration      // Block 策略下发布者最长等待时间，超时后丢弃本条消息；为0时一直等待
+}
+
+// DefaultSubscribeOptions 默认的订阅选项：缓冲64条，缓冲区满时丢弃最旧的消息
+var DefaultSubscribeOptions = SubscribeOptions{
+	Buffer: 64,
+	Policy: DropOldest,
+}
+
+// Subscription 一个订阅，通过 C 接收消息，Close 取消订阅
+type Subscription[T any] struct {
+	name    string
+	hub     *Hub[T]
+	options SubscribeOptions
+
+	mu     sync.Mutex // 保护 ch 的发送和关闭
+	ch     chan T
+	closed bool
+	done   chan struct{} // 取消订阅时关闭，唤醒阻塞在发送上的发布者
+	once   sync.Once
+	err    atomic.Pointer[error] // 被断开的原因
+
+	delivered int64 // 已送达的消息数
+	dropped   int64 // 丢弃的消息数
+}
+
+// Name 返回订阅者名称
+func (s *Subscription[T]) Name() string {
+	return s.name
+}
+
+// C 返回接收消息的通道，取消订阅、被断开或广播中心关闭后通道被关闭
+func (s *Subscription[T]) C() <-chan T {
+	return s.ch
+}
+
+// Err 返回订阅被断开的原因，正常取消订阅或仍在订阅时返回 nil
+func (s *Subscription[T]) Err() error {
+	if err := s.err.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
+
+// Dropped 返回该订阅者丢弃的消息数
+func (s *Subscription[T]) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// Closed 订阅是否已经关闭（取消订阅、被断开或广播中心关闭）
+func (s *Subscription[T]) Closed() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close 取消订阅并关闭通道，可重复调用
+func (s *Subscription[T]) Close() {
+	s.shutdown(nil)
+}
+
+// shutdown 关闭订阅：先关闭 done 唤醒阻塞的发布者，再从广播中心移除并关闭通道
+func (s *Subscription[T]) shutdown(reason error) {
+	s.once.Do(func() {
+		if reason != nil {
+			s.err.Store(&reason)
+		}
+		close(s.done)
+		s.hub.remove(s)
+		s.mu.Lock()
+		s.closed = true
+		close(s.ch)
+		s.mu.Unlock()
+	})
+}
+
+// deliver 按订阅者的策略发送一条消息，返回是否送达
+func (s *Subscription[T]) deliver(msg T) bool {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return false
+	}
+
+	select {
+	case s.ch <- msg:
+		s.mu.Unlock()
+		atomic.AddInt64(&s.delivered, 1)
+		return true
+	default:
+	}
+
+	switch s.options.Policy {
+	case Block:
+		defer s.mu.Unlock()
+		var timeout <-chan time.Time
+		if s.options.BlockTimeout > 0 {
+			timer := time.NewTimer(s.options.BlockTimeout)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+		select {
+		case s.ch <- msg:
+			atomic.AddInt64(&s.delivered, 1)
+			return true
+		case <-s.done:
+		case <-timeout:
+		}
+		s.drop()
+		return false
+
+	case Disconnect:
+		s.mu.Unlock()
+		s.drop()
+		atomic.AddInt64(&s.hub.disconnected, 1)
+		s.shutdown(ErrSlowConsumer)
+		return false
+
+	default:
+		defer s.mu.Unlock()
+		// 持有 mu 时没有其他发布者向该通道发送，取出一条后必然有空位（或者消费者已经取走了一条）
+		for {
+			select {
+			case <-s.ch:
+				s.drop()
+			default:
+			}
+			select {
+			case s.ch <- msg:
+				atomic.AddInt64(&s.delivered, 1)
+				return true
+			default:
+			}
+		}
+	}
+}
+
+// drop 记录一条被丢弃的消息
+func (s *Subscription[T]) drop() {
+	atomic.AddInt64(&s.dropped, 1)
+	atomic.AddInt64(&s.hub.dropped, 1)
+}
+
+// Hub 广播中心，零值可直接使用，并发安全
+type Hub[T any] struct {
+	mu     sync.RWMutex
+	subs   []*Subscription[T] // 写时复制，发布后的切片不再修改
+	closed bool
+
+	published    int64 // 发布的消息数
+	dropped      int64 // 所有订阅者丢弃的消息总数，包括已经取消的订阅
+	disconnected int64 // 因处理过慢被断开的订阅者数
+}
+
+// NewHub 创建广播中心
+func NewHub[T any]() *Hub[T] {
+	return &Hub[T]{}
+}
+
+// Subscribe 订阅，name 用于统计和日志；广播中心已关闭时返回通道已关闭的订阅
+func (h *Hub[T]) Subscribe(name string, options SubscribeOptions) *Subscription[T] {
+	if options.Buffer <= 0 {
+		options.Buffer = DefaultSubscribeOptions.Buffer
+	}
+	s := &Subscription[T]{
+		name:    name,
+		hub:     h,
+		options: options,
+		ch:      make(chan T, options.Buffer),
+		done:    make(chan struct{}),
+	}
+
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		s.Close()
+		return s
+	}
+	subs := make([]*Subscription[T], len(h.subs), len(h.subs)+1)
+	copy(subs, h.subs)
+	h.subs = append(subs, s)
+	h.mu.Unlock()
+	return s
+}
+
+// remove 把订阅从列表中移除
+func (h *Hub[T]) remove(s *Subscription[T]) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, sub := range h.subs {
+		if sub == s {
+			subs := make([]*Subscription[T], 0, len(h.subs)-1)
+			subs = append(subs, h.subs[:i]...)
+			h.subs = append(subs, h.subs[i+1:]...)
+			return
+		}
+	}
+}
+
+// Publish 把消息发送给所有订阅者，返回送达的订阅者数；有 Block 策略的订阅者时可能阻塞
+func (h *Hub[T]) Publish(msg T) int {
+	h.mu.RLock()
+	subs := h.subs
+	closed := h.closed
+	h.mu.RUnlock()
+	if closed {
+		return 0
+	}
+
+	atomic.AddInt64(&h.published, 1)
+	delivered := 0
+	for _, s := range subs {
+		if s.deliver(msg) {
+			delivered++
+		}
+	}
+	return delivered
+}
+
+// Subscribers 返回当前订阅者数
+func (h *Hub[T]) Subscribers() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subs)
+}
+
+// Dropped 返回所有订阅者丢弃的消息总数，包括已经取消的订阅
+func (h *Hub[T]) Dropped() int64 {
+	return atomic.LoadInt64(&h.dropped)
+}
+
+// Close 关闭广播中心及所有订阅，之后的发布被忽略、订阅立即得到已关闭的通道；可重复调用
+func (h *Hub[T]) Close() {
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return
+	}
+	h.closed = true
+	subs := h.subs
+	h.mu.Unlock()
+
+	for _, s := range subs {
+		s.Close()
+	}
+}
+
+// Stats 返回广播中心的统计信息，subscribers 中是每个订阅者的名称、策略、送达数和丢弃数
+func (h *Hub[T]) Stats() map[string]interface{} {
+	h.mu.RLock()
+	subs := h.subs
+	closed := h.closed
+	h.mu.RUnlock()
+
+	perSub := make([]map[string]interface{}, 0, len(subs))
+	for _, s := range subs {
+		perSub = append(perSub, map[string]interface{}{
+			"name":      s.name,
+			"policy":    s.options.Policy.String(),
+			"pending":   len(s.ch),
+			"delivered": atomic.LoadInt64(&s.delivered),
+			"dropped":   atomic.LoadInt64(&s.dropped),
+		})
+	}
+	return map[string]interface{}{
+		"closed":       closed,
+		"published":    atomic.LoadInt64(&h.published),
+		"dropped":      atomic.LoadInt64(&h.dropped),
+		"disconnected": atomic.LoadInt64(&h.disconnected),
+		"subscribers":  perSub,
+	}
+}
+
+// 场景示例：行情推送给处理速度不同的三个订阅者
+func HubDemo() {
+	fmt.Println("广播中心示例（行情推送）:")
+
+	hub := NewHub[int]()
+	dashboard := hub.Subscribe("监控面板", SubscribeOptions{Buffer: 4, Policy: DropOldest})
+	audit := hub.Subscribe("审计日志", SubscribeOptions{Buffer: 4, Policy: Block})
+	webhook := hub.Subscribe("外部推送", SubscribeOptions{Buffer: 4, Policy: Disconnect})
+
+	// 三个订阅者都比发布者慢：面板只关心最新价格，审计必须完整，外部推送跟不上就断开
+	subs := []*Subscription[int]{dashboard, audit, webhook}
+	delays := []time.Duration{5 * time.Millisecond, 2 * time.Millisecond, 10 * time.Millisecond}
+	var wg sync.WaitGroup
+	received := make([][]int, len(subs))
+	for i, sub := range subs {
+		wg.Add(1)
+		go func(i int, sub *Subscription[int]) {
+			defer wg.Done()
+			for price := range sub.C() {
+				received[i] = append(received[i], price)
+				time.Sleep(delays[i])
+			}
+		}(i, sub)
+	}
+
+	start := time.Now()
+	for price := 1; price <= 20; price++ {
+		hub.Publish(price)
+	}
+	fmt.Printf("发布 20 条行情耗时 %v（审计日志的阻塞策略让发布者跟着它的速度走）\n", time.Since(start).Round(time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+	hub.Close()
+	wg.Wait()
+
+	for i, sub := range subs {
+		fmt.Printf("  %s（%s）: 收到 %d 条，丢弃 %d 条，收到的行情 %v",
+			sub.Name(), sub.options.Policy, len(received[i]), sub.Dropped(), received[i])
+		if err := sub.Err(); err != nil {
+			fmt.Printf("，%v", err)
+		}
+		fmt.Println()
+	}
+}
@@ -0,0 +1,432 @@
+package concurrency
+
+/*
+熔断器（CircuitBreaker）
+
+原理：
+下游服务故障或过载时，调用方如果继续按原来的速率请求，每个请求都要等到超时才失败，
+调用方的协程、连接被大量占住，故障沿调用链向上蔓延（雪崩）；持续的请求也让下游更难恢复。
+熔断器像电路中的保险丝：统计最近一段调用的失败率和慢调用比例，超过阈值时"跳闸"，
+之后的请求不再发往下游而是立即失败，给下游留出恢复时间；
+一段时间后放行少量试探请求，试探成功说明下游已恢复，熔断器闭合，否则继续保持打开。
+
+关键特点：
+1. 三种状态：关闭（正常放行并统计）、打开（直接拒绝）、半开（只放行有限个试探请求）
+2. 统计最近 WindowSize 次调用，调用次数达到 MinimumCalls 后，失败率或慢调用比例达到阈值即打开
+3. 慢调用：耗时超过 SlowCallDuration 的调用即使成功也计入慢调用，下游变慢往往是过载的前兆
+4. 打开 OpenTimeout 后第一次请求时进入半开；HalfOpenMaxCalls 个试探请求都有结果后按同样的阈值判断关闭还是重新打开
+5. IsFailure 决定哪些错误算作失败（例如参数错误不应让熔断器打开）；OnStateChange 在状态变化时调用，可用于告警和日志
+6. 状态切换后，切换前放行的请求再返回结果会被忽略，不影响新状态的统计
+
+实现方式：
+- 互斥锁保护状态和滑动窗口，滑动窗口是固定长度的环形数组，同时维护失败数和慢调用数，记录一次调用是 O(1)
+- 打开到半开的切换不使用定时器，而是在请求到来或查询状态时检查是否已超过 OpenTimeout
+- 每次状态切换把代数加1，Allow 返回的完成函数记住放行时的代数，代数不一致的结果直接丢弃
+- OnStateChange 在持有锁时同步调用，回调中不能再调用同一个熔断器的方法
+
+应用场景：
+- 调用第三方支付、短信、推荐等外部接口
+- 微服务之间的同步调用，防止单个服务故障拖垮整条调用链
+- 与限流（保护自己不被调用方压垮）、重试（应对短暂故障）配合形成分层保护
+
+优缺点：
+- 优点：快速失败，释放调用方资源；给下游恢复的机会；慢调用检测能在下游彻底失败前提前介入
+- 缺点：阈值和窗口需要按业务调整，窗口过小容易误判；打开期间所有请求都被拒绝，需要配合降级逻辑；
+  只统计本进程的调用，多实例之间各自判断
+
+以下实现了基于调用次数滑动窗口的熔断器，以及不稳定的下游接口熔断与恢复的示例。
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BreakerState 熔断器状态
+type BreakerState int
+
+const (
+	BreakerClosed   BreakerState = iota // 关闭：正常放行
+	BreakerOpen                         // 打开：拒绝所有请求
+	BreakerHalfOpen                     // 半开：放行有限个试探请求
+)
+
+// String 返回状态名称
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "关闭"
+	case BreakerOpen:
+		return "打开"
+	case BreakerHalfOpen:
+		return "半开"
+	default:
+		return "未知状态"
+	}
+}
+
+// ErrBreakerOpen 熔断器打开或半开时试探名额已满，请求被拒绝
+var ErrBreakerOpen = errors.New("熔断器打开，请求被拒绝")
+
+// CircuitBreakerOptions 熔断器配置
+type CircuitBreakerOptions struct {
+	WindowSize            int                                      // 滑动窗口统计最近多少次调用
+	MinimumCalls          int                                      // 窗口内至少有多少次调用才开始判断
+	FailureRateThreshold  float64                                  // 失败率阈值（0~1），达到后打开
+	SlowCallDuration      time.Duration                            // 耗时超过该值的调用算作慢调用
+	SlowCallRateThreshold float64                                  // 慢调用比例阈值（0~1），达到后打开
+	OpenTimeout           time.Duration                            // 打开状态持续多久后进入半开
+	HalfOpenMaxCalls      int                                      // 半开状态放行的试探请求数
+	IsFailure             func(err error) bool                     // 判断错误是否算作失败，为 nil 时所有错误都算
+	OnStateChange         func(name string, from, to BreakerState) // 状态变化时调用，可以为 nil
+}
+
+// DefaultCircuitBreakerOptions 默认熔断器配置：最近20次调用中失败率或慢调用比例达到50%时打开，5秒后试探
+var DefaultCircuitBreakerOptions = CircuitBreakerOptions{
+	WindowSize:            20,
+	MinimumCalls:          10,
+	FailureRateThreshold:  0.5,
+	SlowCallDuration:      time.Second,
+	SlowCallRateThreshold: 0.5,
+	OpenTimeout:           5 * time.Second,
+	HalfOpenMaxCalls:      3,
+}
+
+// callOutcome 一次调用的结果
+type callOutcome struct {
+	failed bool
+	slow   bool
+}
+
+// CircuitBreaker 基于调用次数滑动窗口的熔断器
+type CircuitBreaker struct {
+	name    string
+	options CircuitBreakerOptions
+
+	mu         sync.Mutex
+	state      BreakerState
+	generation uint64        // 状态切换次数，用于丢弃切换前放行的请求的结果
+	openedAt   time.Time     // 最近一次打开的时间
+	window     []callOutcome // 环形数组
+	next       int           // 下一个写入位置
+	calls      int           // 窗口内的调用数
+	failures   int           // 窗口内的失败数
+	slowCalls  int           // 窗口内的慢调用数
+	probes     int           // 半开状态已放行的试探请求数
+
+	successCount int64 // 没有计为失败的调用数
+	failureCount int64 // 失败调用数
+	slowCount    int64 // 慢调用数
+	rejected     int64 // 被拒绝的请求数
+	transitions  int64 // 状态切换次数
+}
+
+// NewCircuitBreaker 创建熔断器，未设置或无效的配置使用默认值
+func NewCircuitBreaker(name string, options CircuitBreakerOptions) *CircuitBreaker {
+	if options.WindowSize <= 0 {
+		options.WindowSize = DefaultCircuitBreakerOptions.WindowSize
+	}
+	if options.MinimumCalls <= 0 || options.MinimumCalls > options.WindowSize {
+		options.MinimumCalls = min(DefaultCircuitBreakerOptions.MinimumCalls, options.WindowSize)
+	}
+	if options.FailureRateThreshold <= 0 || options.FailureRateThreshold > 1 {
+		options.FailureRateThreshold = DefaultCircuitBreakerOptions.FailureRateThreshold
+	}
+	if options.SlowCallDuration <= 0 {
+		options.SlowCallDuration = DefaultCircuitBreakerOptions.SlowCallDuration
+	}
+	if options.SlowCallRateThreshold <= 0 || options.SlowCallRateThreshold > 1 {
+		options.SlowCallRateThreshold = DefaultCircuitBreakerOptions.SlowCallRateThreshold
+	}
+	if options.OpenTimeout <= 0 {
+		options.OpenTimeout = DefaultCircuitBreakerOptions.OpenTimeout
+	}
+	if options.HalfOpenMaxCalls <= 0 {
+		options.HalfOpenMaxCalls = DefaultCircuitBreakerOptions.HalfOpenMaxCalls
+	}
+	return &CircuitBreaker{
+		name:    name,
+		options: options,
+		window:  make([]callOutcome, options.WindowSize),
+	}
+}
+
+// Name 返回熔断器名称
+func (cb *CircuitBreaker) Name() string {
+	return cb.name
+}
+
+// State 返回当前状态，打开时间已超过 OpenTimeout 时返回半开
+func (cb *CircuitBreaker) State() BreakerState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.checkOpenTimeoutLocked(time.Now())
+	return cb.state
+}
+
+// checkOpenTimeoutLocked 打开时间超过 OpenTimeout 时切换到半开（调用方需持有锁）
+func (cb *CircuitBreaker) checkOpenTimeoutLocked(now time.Time) {
+	if cb.state == BreakerOpen && now.Sub(cb.openedAt) >= cb.options.OpenTimeout {
+		cb.setStateLocked(BreakerHalfOpen, now)
+	}
+}
+
+// setStateLocked 切换状态并清空统计窗口（调用方需持有锁）
+func (cb *CircuitBreaker) setStateLocked(state BreakerState, now time.Time) {
+	from := cb.state
+	if from == state {
+		return
+	}
+	cb.state = state
+	cb.generation++
+	cb.transitions++
+	cb.resetWindowLocked()
+	cb.probes = 0
+	if state == BreakerOpen {
+		cb.openedAt = now
+	}
+	if cb.options.OnStateChange != nil {
+		cb.options.OnStateChange(cb.name, from, state)
+	}
+}
+
+// resetWindowLocked 清空滑动窗口（调用方需持有锁）
+func (cb *CircuitBreaker) resetWindowLocked() {
+	clear(cb.window)
+	cb.next, cb.calls, cb.failures, cb.slowCalls = 0, 0, 0, 0
+}
+
+// Allow 判断请求是否可以放行，放行时返回完成函数，调用方在请求结束后必须用请求的错误调用它恰好一次；
+// 拒绝时返回 ErrBreakerOpen
+func (cb *CircuitBreaker) Allow() (func(err error), error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	now := time.Now()
+	cb.checkOpenTimeoutLocked(now)
+	switch cb.state {
+	case BreakerOpen:
+		atomic.AddInt64(&cb.rejected, 1)
+		return nil, ErrBreakerOpen
+	case BreakerHalfOpen:
+		if cb.probes >= cb.options.HalfOpenMaxCalls {
+			atomic.AddInt64(&cb.rejected, 1)
+			return nil, ErrBreakerOpen
+		}
+		cb.probes++
+	}
+
+	generation := cb.generation
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() { cb.record(generation, err, time.Since(now)) })
+	}, nil
+}
+
+// Execute 熔断器放行时执行 fn 并记录结果，返回 fn 的错误；拒绝时不执行 fn，返回 ErrBreakerOpen。
+// fn panic 时记为失败后继续向上 panic
+func (cb *CircuitBreaker) Execute(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	done, err := cb.Allow()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			done(newPanicError(r))
+			panic(r)
+		}
+	}()
+	err = fn(ctx)
+	done(err)
+	return err
+}
+
+// record 记录一次调用的结果，并按阈值判断是否切换状态
+func (cb *CircuitBreaker) record(generation uint64, err error, elapsed time.Duration) {
+	failed := err != nil && (cb.options.IsFailure == nil || cb.options.IsFailure(err))
+	slow := elapsed >= cb.options.SlowCallDuration
+	if failed {
+		atomic.AddInt64(&cb.failureCount, 1)
+	} else {
+		atomic.AddInt64(&cb.successCount, 1)
+	}
+	if slow {
+		atomic.AddInt64(&cb.slowCount, 1)
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if generation != cb.generation {
+		return // 状态已经切换，切换前放行的请求不影响新状态的统计
+	}
+
+	// 环形数组满时先移除最老的结果
+	if cb.calls == len(cb.window) {
+		old := cb.window[cb.next]
+		cb.calls--
+		if old.failed {
+			cb.failures--
+		}
+		if old.slow {
+			cb.slowCalls--
+		}
+	}
+	cb.window[cb.next] = callOutcome{failed: failed, slow: slow}
+	cb.next = (cb.next + 1) % len(cb.window)
+	cb.calls++
+	if failed {
+		cb.failures++
+	}
+	if slow {
+		cb.slowCalls++
+	}
+
+	now := time.Now()
+	switch cb.state {
+	case BreakerClosed:
+		if cb.calls >= cb.options.MinimumCalls && cb.overThresholdLocked() {
+			cb.setStateLocked(BreakerOpen, now)
+		}
+	case BreakerHalfOpen:
+		// 所有试探请求都有结果后再判断
+		if cb.calls >= cb.options.HalfOpenMaxCalls {
+			if cb.overThresholdLocked() {
+				cb.setStateLocked(BreakerOpen, now)
+			} else {
+				cb.setStateLocked(BreakerClosed, now)
+			}
+		}
+	}
+}
+
+// overThresholdLocked 判断窗口内失败率或慢调用比例是否达到阈值（调用方需持有锁）
+func (cb *CircuitBreaker) overThresholdLocked() bool {
+	if cb.calls == 0 {
+		return false
+	}
+	return float64(cb.failures)/float64(cb.calls) >= cb.options.FailureRateThreshold ||
+		float64(cb.slowCalls)/float64(cb.calls) >= cb.options.SlowCallRateThreshold
+}
+
+// Stats 获取熔断器统计信息
+func (cb *CircuitBreaker) Stats() map[string]interface{} {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.checkOpenTimeoutLocked(time.Now())
+
+	stats := map[string]interface{}{
+		"name":         cb.name,
+		"state":        cb.state.String(),
+		"windowCalls":  cb.calls,
+		"successCount": atomic.LoadInt64(&cb.successCount),
+		"failureCount": atomic.LoadInt64(&cb.failureCount),
+		"slowCount":    atomic.LoadInt64(&cb.slowCount),
+		"rejected":     atomic.LoadInt64(&cb.rejected),
+		"transitions":  cb.transitions,
+	}
+	if cb.calls > 0 {
+		stats["failureRate"] = float64(cb.failures) / float64(cb.calls)
+		stats["slowCallRate"] = float64(cb.slowCalls) / float64(cb.calls)
+	}
+	if cb.state == BreakerOpen {
+		stats["halfOpenIn"] = (cb.options.OpenTimeout - time.Since(cb.openedAt)).Round(time.Millisecond).String()
+	}
+	return stats
+}
+
+// 场景示例：订单服务调用第三方物流接口，接口先大量报错、之后变慢，最后恢复
+func CircuitBreakerDemo() {
+	fmt.Println("熔断器示例 (调用第三方物流接口):")
+
+	errUnavailable := errors.New("物流接口返回 503")
+	errBadAddress := errors.New("收货地址格式错误")
+
+	var mode atomic.Int32 // 0 正常，1 报错，2 变慢
+	logistics := func(ctx context.Context, address string) error {
+		if address == "" {
+			return errBadAddress
+		}
+		switch mode.Load() {
+		case 1:
+			return errUnavailable
+		case 2:
+			time.Sleep(15 * time.Millisecond)
+		}
+		return nil
+	}
+
+	start := time.Now()
+	breaker := NewCircuitBreaker("物流接口", CircuitBreakerOptions{
+		WindowSize:            10,
+		MinimumCalls:          5,
+		FailureRateThreshold:  0.5,
+		SlowCallDuration:      10 * time.Millisecond,
+		SlowCallRateThreshold: 0.6,
+		OpenTimeout:           50 * time.Millisecond,
+		HalfOpenMaxCalls:      2,
+		// 地址错误是调用方的问题，不说明物流接口不健康
+		IsFailure: func(err error) bool { return !errors.Is(err, errBadAddress) },
+		OnStateChange: func(name string, from, to BreakerState) {
+			fmt.Printf("  [%v] %s: %s -> %s\n", time.Since(start).Round(10*time.Millisecond), name, from, to)
+		},
+	})
+
+	// call 发起 n 次请求，返回成功、失败、被拒绝的次数
+	call := func(n int, address string) (ok, failed, rejected int) {
+		for i := 0; i < n; i++ {
+			err := breaker.Execute(context.Background(), func(ctx context.Context) error {
+				return logistics(ctx, address)
+			})
+			switch {
+			case errors.Is(err, ErrBreakerOpen):
+				rejected++
+			case err != nil:
+				failed++
+			default:
+				ok++
+			}
+		}
+		return
+	}
+	report := func(title string, ok, failed, rejected int) {
+		fmt.Printf("%s: 成功 %d，出错 %d，被熔断拒绝 %d，当前状态 %s\n", title, ok, failed, rejected, breaker.State())
+	}
+
+	ok, failed, rejected := call(8, "")
+	report("1. 地址错误的请求（不计入失败）", ok, failed, rejected)
+
+	mode.Store(1)
+	ok, failed, rejected = call(10, "上海市浦东新区")
+	report("2. 物流接口报错", ok, failed, rejected)
+
+	// 打开期间物流接口已经恢复，但要等到半开试探成功才放行
+	mode.Store(0)
+	time.Sleep(60 * time.Millisecond)
+	ok, failed, rejected = call(5, "上海市浦东新区")
+	report("3. 等待超过 OpenTimeout 后", ok, failed, rejected)
+
+	mode.Store(2)
+	ok, failed, rejected = call(6, "上海市浦东新区")
+	report("4. 物流接口变慢（请求都成功但超过 10ms）", ok, failed, rejected)
+
+	// 半开时试探请求仍然很慢，重新打开
+	time.Sleep(60 * time.Millisecond)
+	ok, failed, rejected = call(4, "上海市浦东新区")
+	report("5. 半开试探时仍然很慢", ok, failed, rejected)
+
+	mode.Store(0)
+	time.Sleep(60 * time.Millisecond)
+	ok, failed, rejected = call(5, "上海市浦东新区")
+	report("6. 物流接口恢复", ok, failed, rejected)
+
+	stats := breaker.Stats()
+	fmt.Printf("统计: 成功 %d，失败 %d，慢调用 %d，拒绝 %d，状态切换 %d 次\n",
+		stats["successCount"], stats["failureCount"], stats["slowCount"], stats["rejected"], stats["transitions"])
+}
@@ -0,0 +1,130 @@
+package concurrency
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/strive/scenario/report"
+)
+
+// DefaultCOWBenchmarkReadPermilles 默认测量的读操作占比（千分比），99% 对应共享配置的典型读写比例
+var DefaultCOWBenchmarkReadPermilles = []int{999, 990, 900}
+
+// rwLocker 读写锁的最小接口，sync.RWMutex 和 CustomRWMutex 都实现了它
+type rwLocker interface {
+	Lock()
+	Unlock()
+	RLock()
+	RUnlock()
+}
+
+// lockedConfig 与 SharedConfig 相同的读写锁保护的配置表，去掉了模拟延迟
+type lockedConfig struct {
+	mu   rwLocker
+	data map[string]interface{}
+}
+
+func (c *lockedConfig) Load(key string) (interface{}, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	value, ok := c.data[key]
+	return value, ok
+}
+
+func (c *lockedConfig) Store(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.data[key] = value
+}
+
+// cowMetric 返回某个读占比的吞吐量指标名
+func cowMetric(readPermille int) string {
+	return fmt.Sprintf("读%.1f%%(万次/秒)", float64(readPermille)/10)
+}
+
+// measureConfig 用 workers 个协程对配置表执行共 ops 次操作，读操作占 readPermille‰，返回每秒操作数（万）
+func measureConfig(load func(string) (interface{}, bool), store func(string, interface{}), keys []string, workers, ops, readPermille int) float64 {
+	perWorker := ops / workers
+	var wg sync.WaitGroup
+	start := time.Now()
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(int64(w)))
+			for i := 0; i < perWorker; i++ {
+				key := keys[rng.Intn(len(keys))]
+				if rng.Intn(1000) < readPermille {
+					load(key)
+				} else {
+					store(key, i)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	return float64(workers*perWorker) / time.Since(start).Seconds() / 1e4
+}
+
+// COWBenchmark 对比读写锁配置表和写时复制映射在高读占比下的吞吐量，配置表有 keyCount 个键
+func COWBenchmark(keyCount, workers, ops int, readPermilles []int) *report.Comparison {
+	metrics := make([]report.Metric, 0, len(readPermilles))
+	for _, p := range readPermilles {
+		metrics = append(metrics, report.Metric{Name: cowMetric(p), Precision: 0})
+	}
+	keys := make([]string, keyCount)
+	initial := make(map[string]interface{}, keyCount)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("config.item-%d", i)
+		initial[keys[i]] = i
+	}
+	input := fmt.Sprintf("%d 个配置项，%d 个协程共 %d 次随机读写，GOMAXPROCS=%d", keyCount, workers, ops, runtime.GOMAXPROCS(0))
+	comparison := report.NewComparison("共享配置读写锁与写时复制对比", input, metrics...)
+
+	newLocked := func(mu rwLocker) *lockedConfig {
+		c := &lockedConfig{mu: mu, data: make(map[string]interface{}, keyCount)}
+		for k, v := range initial {
+			c.data[k] = v
+		}
+		return c
+	}
+	contenders := []struct {
+		name    string
+		measure func(readPermille int) float64
+	}{
+		{"sync.RWMutex", func(p int) float64 {
+			c := newLocked(&sync.RWMutex{})
+			return measureConfig(c.Load, c.Store, keys, workers, ops, p)
+		}},
+		{"CustomRWMutex(SharedConfig)", func(p int) float64 {
+			c := newLocked(NewCustomRWMutex())
+			return measureConfig(c.Load, c.Store, keys, workers, ops, p)
+		}},
+		{"COWMap", func(p int) float64 {
+			m := NewCOWMap(initial)
+			return measureConfig(m.Load, m.Store, keys, workers, ops, p)
+		}},
+	}
+	for _, c := range contenders {
+		comparison.Measure(c.name, func() (map[string]float64, error) {
+			values := make(map[string]float64, len(readPermilles))
+			for _, p := range readPermilles {
+				values[cowMetric(p)] = c.measure(p)
+			}
+			return values, nil
+		})
+	}
+	return comparison
+}
+
+// 场景示例：共享配置的存储选型，比较读写锁和写时复制在不同读占比下的吞吐量
+func COWBenchmarkDemo() {
+	fmt.Println("共享配置吞吐量基准:")
+	COWBenchmark(50, 8, 500000, DefaultCOWBenchmarkReadPermilles).Write(os.Stdout, report.FormatMarkdown)
+	fmt.Println("写时复制的读取只是一次原子指针加载，读占比越高、核数越多领先越明显；" +
+		"每次写入都要复制整张表，写入比例升高或表变大后，复制的代价会超过读取节省的部分")
+}
@@ -0,0 +1,198 @@
+package concurrency
+
+/*
+写时复制映射（Copy-On-Write Map）
+
+原理：
+配置、路由表、黑白名单这类数据每秒被读取成千上万次，却可能几分钟才修改一次。
+读写锁虽然允许读取者并发，但每次 RLock/RUnlock 都要原子修改同一个读者计数，
+核数多时这个计数所在的缓存行在各个核之间来回传递，读多的场景下反而成为瓶颈。
+写时复制让读取者完全不加锁：当前版本的 map 通过原子指针发布，读取只是一次原子指针加载加一次 map 查找；
+写入者复制一份完整的 map，在副本上修改后原子地替换指针，已经拿到旧版本的读取者继续读旧版本，不受影响。
+旧版本在没有读取者引用后由垃圾回收释放。
+
+关键特点：
+1. 读取无锁、无等待，读取者之间以及读取者与写入者之间都没有竞争
+2. 每次写入复制整个 map，代价与元素数量成正比；Update 在一次复制中完成多个修改
+3. 写入者之间用互斥锁串行，保证不会有两个写入者基于同一个旧版本各自修改导致更新丢失
+4. Snapshot 返回的 map 是某一时刻的完整版本，多个键的读取彼此一致，但调用方不能修改它
+
+实现方式：
+- atomic.Pointer 保存当前版本的 map，发布后的 map 永不修改
+- 写入时在互斥锁内复制当前 map、修改副本、Store 新指针
+- 统计复制次数和复制的元素总数，用于评估写入代价
+
+应用场景：
+- 共享配置、功能开关、路由表、证书列表等读远多于写的数据
+- 需要一次读取多个键且要求彼此一致的场景（同一个快照中读取）
+- 与读写锁映射的对比见 cow_benchmark.go
+
+优缺点：
+- 优点：读取性能随核数线性扩展；读取者不会被写入者阻塞；天然提供一致的快照
+- 缺点：写入代价与 map 大小成正比，写入频繁或 map 很大时不适用；
+  写入期间新旧两个版本同时存在，内存占用翻倍；值本身是引用类型时，修改值的内容仍然需要额外同步
+
+以下实现了泛型的写时复制映射，以及配置热更新的示例。
+*/
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// COWMap 写时复制映射，读取无锁，写入复制整个 map；零值不可用，需通过 NewCOWMap 创建
+type COWMap[K comparable, V any] struct {
+	current atomic.Pointer[map[K]V] // 当前版本，发布后不再修改
+	mu      sync.Mutex              // 串行化写入者
+	version uint64                  // 已发布的版本数
+	copies  int64                   // 复制 map 的次数
+	copied  int64                   // 累计复制的元素数
+}
+
+// NewCOWMap 创建写时复制映射，initial 中的元素会被复制，之后修改 initial 不影响映射
+func NewCOWMap[K comparable, V any](initial map[K]V) *COWMap[K, V] {
+	m := &COWMap[K, V]{}
+	data := make(map[K]V, len(initial))
+	for k, v := range initial {
+		data[k] = v
+	}
+	m.current.Store(&data)
+	return m
+}
+
+// Load 读取键的值，不加锁
+func (m *COWMap[K, V]) Load(key K) (V, bool) {
+	value, ok := (*m.current.Load())[key]
+	return value, ok
+}
+
+// Len 返回当前版本的元素数
+func (m *COWMap[K, V]) Len() int {
+	return len(*m.current.Load())
+}
+
+// Snapshot 返回当前版本的 map，其中的键值彼此一致；返回值是只读的，不能修改
+func (m *COWMap[K, V]) Snapshot() map[K]V {
+	return *m.current.Load()
+}
+
+// Range 在当前版本上依次调用 fn，fn 返回 false 时停止；遍历期间的写入不影响本次遍历
+func (m *COWMap[K, V]) Range(fn func(key K, value V) bool) {
+	for k, v := range *m.current.Load() {
+		if !fn(k, v) {
+			return
+		}
+	}
+}
+
+// Store 写入键值对
+func (m *COWMap[K, V]) Store(key K, value V) {
+	m.Update(func(data map[K]V) {
+		data[key] = value
+	})
+}
+
+// Delete 删除键，键不存在时不复制
+func (m *COWMap[K, V]) Delete(key K) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := (*m.current.Load())[key]; !ok {
+		return
+	}
+	m.updateLocked(func(data map[K]V) {
+		delete(data, key)
+	})
+}
+
+// Update 复制当前版本，在副本上执行 fn 后发布为新版本；多个修改放在一次 Update 中只复制一次
+func (m *COWMap[K, V]) Update(fn func(data map[K]V)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.updateLocked(fn)
+}
+
+// updateLocked 复制、修改并发布新版本，调用方需持有 mu
+func (m *COWMap[K, V]) updateLocked(fn func(data map[K]V)) {
+	old := *m.current.Load()
+	data := make(map[K]V, len(old)+1)
+	for k, v := range old {
+		data[k] = v
+	}
+	fn(data)
+	m.current.Store(&data)
+	m.version++
+	atomic.AddInt64(&m.copies, 1)
+	atomic.AddInt64(&m.copied, int64(len(old)))
+}
+
+// Stats 返回映射的统计信息
+func (m *COWMap[K, V]) Stats() map[string]interface{} {
+	m.mu.Lock()
+	version := m.version
+	m.mu.Unlock()
+	return map[string]interface{}{
+		"size":          m.Len(),
+		"version":       version,
+		"copies":        atomic.LoadInt64(&m.copies),
+		"copiedEntries": atomic.LoadInt64(&m.copied),
+	}
+}
+
+// 场景示例：网关的路由配置被每个请求读取，配置中心偶尔推送整批更新
+func COWMapDemo() {
+	fmt.Println("写时复制映射示例 (网关配置热更新):")
+
+	config := NewCOWMap(map[string]string{
+		"route./api/user":  "user-service:v1",
+		"route./api/order": "order-service:v1",
+		"timeout":          "3s",
+	})
+
+	var requests, mixed int64
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				// 同一个快照中读取两条路由，保证不会看到一半新一半旧的配置
+				snapshot := config.Snapshot()
+				user, order := snapshot["route./api/user"], snapshot["route./api/order"]
+				if user[len(user)-2:] != order[len(order)-2:] {
+					atomic.AddInt64(&mixed, 1)
+				}
+				atomic.AddInt64(&requests, 1)
+			}
+		}()
+	}
+
+	// 配置中心推送：两条路由在一次 Update 中同时切换版本
+	for v := 2; v <= 5; v++ {
+		time.Sleep(10 * time.Millisecond)
+		version := fmt.Sprintf("v%d", v)
+		config.Update(func(data map[string]string) {
+			data["route./api/user"] = "user-service:" + version
+			data["route./api/order"] = "order-service:" + version
+		})
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(stop)
+	wg.Wait()
+
+	route, _ := config.Load("route./api/user")
+	fmt.Printf("处理请求 %d 次，读到新旧混合配置 %d 次，当前路由: %s\n", requests, mixed, route)
+
+	config.Delete("timeout")
+	config.Delete("timeout") // 键已不存在，不复制
+	stats := config.Stats()
+	fmt.Printf("版本: %d，复制 %d 次，共复制元素 %d 个，当前元素数: %d\n",
+		stats["version"], stats["copies"], stats["copiedEntries"], stats["size"])
+}
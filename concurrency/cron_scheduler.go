@@ -0,0 +1,646 @@
+package concurrency
+
+/*
+定时任务调度器（Cron Scheduler）
+
+原理：
+定时任务由"什么时候执行"和"怎么执行"两部分组成。
+"什么时候"用调度规则描述：cron 表达式按秒、分、时、日、月、星期逐个字段匹配，
+Next 从给定时间开始找到下一个所有字段都匹配的时刻；固定间隔规则则只是在上一次计划时间上加一个间隔。
+调度器为每个任务在时间轮上挂一个定时器，到点后先算出并挂上下一次的定时器，再把本次执行提交给协程池。
+下一次的时间从本次的计划时间而不是实际触发时间算起，定时器的误差不会累积。
+
+关键特点：
+1. 支持5个字段（分 时 日 月 星期）和6个字段（秒 分 时 日 月 星期）的 cron 表达式，
+   字段支持 *、数字、范围 a-b、步长 a-b/n（范围写成 * 时表示整个取值范围）、列表 a,b，月份和星期支持英文缩写；
+   支持 @yearly、@monthly、@weekly、@daily、@hourly 和 @every 1m30s
+2. 日和星期同时受限时满足其一即可，与标准 cron 相同
+3. 任务在 GoroutinePool 上执行，并发度由协程池控制，调度器本身不创建工作协程
+4. 抖动（Jitter）：每次触发在计划时间之后再随机延迟 [0, Jitter)，避免大量任务在整点同时执行
+5. 上一次执行还没结束时又到了下一次的触发时间，按重叠策略处理：
+   跳过（Skip）本次、排队（Queue）等上一次结束后立即补执行、并发（Concurrent）直接执行
+6. 优雅关闭：Shutdown 停止所有定时器、丢弃排队中的补执行，等待正在执行的任务结束；
+   上下文先结束时取消任务的上下文并返回错误
+
+实现方式：
+- cron 的每个字段解析为一个 uint64 位图，匹配只是一次位运算
+- Next 从下一秒开始，哪个字段不匹配就把该字段加1并把更低的字段清零，最多向后查找5年
+- 定时器挂在进程内共享的时间轮上，精度为时间轮的一个刻度；触发回调在自己的协程中提交任务，协程池队列满时不会拖住其他任务
+- 一把互斥锁保护任务表和每个任务的运行状态；正在执行的任务数用 WaitGroup 跟踪，关闭时等待它归零
+- 任务中的 panic 被恢复为 PanicError，计为一次失败，不影响后续调度
+
+应用场景：
+- 定时生成报表、清理过期数据、同步配置
+- 周期性的健康检查和指标上报
+- 大量实例共享同一个定时规则时用抖动错开执行时间
+
+优缺点：
+- 优点：规则表达能力与标准 cron 一致；执行并发度可控；重叠和关闭行为明确
+- 缺点：只在单个进程内调度，多实例部署时需要配合分布式锁或租约避免重复执行；
+  进程不在运行期间错过的触发不会补执行；时区固定为 time.Local
+
+以下实现了 cron 表达式解析、基于时间轮和协程池的调度器，以及运维后台定时任务的示例。
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Schedule 调度规则，Next 返回 after 之后的下一个执行时间，没有时返回零值
+type Schedule interface {
+	Next(after time.Time) time.Time
+}
+
+// intervalSchedule 固定间隔的调度规则
+type intervalSchedule struct {
+	every time.Duration
+}
+
+// Interval 返回每隔 d 执行一次的调度规则，d 不足1毫秒时按1毫秒计算
+func Interval(d time.Duration) Schedule {
+	return intervalSchedule{every: max(d, time.Millisecond)}
+}
+
+// Next 返回 after 加上间隔
+func (s intervalSchedule) Next(after time.Time) time.Time {
+	return after.Add(s.every)
+}
+
+// String 返回 @every 形式的描述
+func (s intervalSchedule) String() string {
+	return "@every " + s.every.String()
+}
+
+// CronSchedule 解析后的 cron 表达式
+type CronSchedule struct {
+	expr                                  string
+	second, minute, hour, dom, month, dow uint64 // 每个字段允许的取值位图
+	domRestricted, dowRestricted          bool   // 日、星期字段是否不是 *
+}
+
+// cronField cron 字段的取值范围和名称
+type cronField struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	cronSecond = cronField{name: "秒", min: 0, max: 59}
+	cronMinute = cronField{name: "分", min: 0, max: 59}
+	cronHour   = cronField{name: "时", min: 0, max: 23}
+	cronDom    = cronField{name: "日", min: 1, max: 31}
+	cronMonth  = cronField{name: "月", min: 1, max: 12, names: map[string]int{
+		"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+		"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+	}}
+	// 星期允许写成7表示周日，解析后并入0
+	cronDow = cronField{name: "星期", min: 0, max: 7, names: map[string]int{
+		"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+	}}
+)
+
+// cronMacros 预定义的表达式，均为6字段形式
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+// ParseSchedule 解析调度规则："@every 时长" 返回固定间隔规则，其余按 cron 表达式解析
+func ParseSchedule(expr string) (Schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if rest, ok := strings.CutPrefix(expr, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("调度规则 %q 的间隔无效", expr)
+		}
+		return Interval(d), nil
+	}
+	return ParseCron(expr)
+}
+
+// ParseCron 解析5字段（分 时 日 月 星期）或6字段（秒 分 时 日 月 星期）的 cron 表达式，或 @daily 等预定义表达式
+func ParseCron(expr string) (*CronSchedule, error) {
+	expr = strings.TrimSpace(expr)
+	spec := expr
+	if macro, ok := cronMacros[strings.ToLower(expr)]; ok {
+		spec = macro
+	}
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("cron 表达式 %q 应有5个或6个字段，实际为 %d 个", expr, len(fields))
+	}
+
+	s := &CronSchedule{expr: expr}
+	targets := []*uint64{&s.second, &s.minute, &s.hour, &s.dom, &s.month, &s.dow}
+	for i, field := range []cronField{cronSecond, cronMinute, cronHour, cronDom, cronMonth, cronDow} {
+		bits, err := field.parse(fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("cron 表达式 %q: %w", expr, err)
+		}
+		*targets[i] = bits
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow = s.dow&^(1<<7) | 1
+	}
+	s.domRestricted = fields[3] != "*" && fields[3] != "?"
+	s.dowRestricted = fields[5] != "*" && fields[5] != "?"
+	return s, nil
+}
+
+// parse 解析一个字段，返回允许取值的位图
+func (f cronField) parse(text string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(text, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("%s字段 %q 的步长无效", f.name, part)
+			}
+			step = n
+		}
+
+		var lo, hi int
+		switch {
+		case rangePart == "*" || rangePart == "?":
+			lo, hi = f.min, f.max
+		case strings.Contains(rangePart, "-"):
+			a, b, _ := strings.Cut(rangePart, "-")
+			var err error
+			if lo, err = f.value(a); err != nil {
+				return 0, err
+			}
+			if hi, err = f.value(b); err != nil {
+				return 0, err
+			}
+		default:
+			var err error
+			if lo, err = f.value(rangePart); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if hasStep {
+				hi = f.max // "5/15" 表示从5开始每15个
+			}
+		}
+		if lo > hi {
+			return 0, fmt.Errorf("%s字段 %q 的范围起点大于终点", f.name, part)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// value 解析单个取值（数字或名称）并检查范围
+func (f cronField) value(text string) (int, error) {
+	if v, ok := f.names[strings.ToUpper(text)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(text)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("%s字段的取值 %q 无效，应在 %d-%d 之间", f.name, text, f.min, f.max)
+	}
+	return v, nil
+}
+
+// dayMatches 判断日期是否匹配：日和星期都受限时满足其一即可，否则以受限的那个为准
+func (s *CronSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domRestricted && s.dowRestricted {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
+
+// Next 返回 after 之后（不含）的下一个匹配时刻，5年内没有匹配时返回零值
+func (s *CronSchedule) Next(after time.Time) time.Time {
+	loc := after.Location()
+	t := after.Truncate(time.Second).Add(time.Second)
+	limit := t.Year() + 5
+	for t.Year() <= limit {
+		y, mo, d := t.Date()
+		h, mi, sec := t.Clock()
+		switch {
+		case s.month&(1<<uint(mo)) == 0:
+			t = time.Date(y, mo+1, 1, 0, 0, 0, 0, loc)
+		case !s.dayMatches(t):
+			t = time.Date(y, mo, d+1, 0, 0, 0, 0, loc)
+		case s.hour&(1<<uint(h)) == 0:
+			t = time.Date(y, mo, d, h+1, 0, 0, 0, loc)
+		case s.minute&(1<<uint(mi)) == 0:
+			t = time.Date(y, mo, d, h, mi+1, 0, 0, loc)
+		case s.second&(1<<uint(sec)) == 0:
+			t = time.Date(y, mo, d, h, mi, sec+1, 0, loc)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// String 返回原始表达式
+func (s *CronSchedule) String() string {
+	return s.expr
+}
+
+// OverlapPolicy 上一次执行还没结束时又到了触发时间的处理方式
+type OverlapPolicy int
+
+const (
+	OverlapSkip       OverlapPolicy = iota // 跳过本次触发
+	OverlapQueue                           // 记下本次触发，上一次结束后立即补执行
+	OverlapConcurrent                      // 与上一次并发执行
+)
+
+// String 返回策略名称
+func (p OverlapPolicy) String() string {
+	switch p {
+	case OverlapSkip:
+		return "跳过"
+	case OverlapQueue:
+		return "排队"
+	case OverlapConcurrent:
+		return "并发"
+	default:
+		return "未知策略"
+	}
+}
+
+// JobOptions 定时任务配置
+type JobOptions struct {
+	Jitter  time.Duration // 每次触发在计划时间之后随机延迟 [0, Jitter)
+	Overlap OverlapPolicy // 重叠策略
+}
+
+// DefaultJobOptions 默认任务配置：不抖动，上一次没结束时跳过
+var DefaultJobOptions = JobOptions{Overlap: OverlapSkip}
+
+var (
+	ErrSchedulerClosed = errors.New("调度器已关闭")
+	ErrJobExists       = errors.New("同名任务已存在")
+)
+
+// cronJob 调度器中的一个任务，除 task 外的字段由 Scheduler.mu 保护
+type cronJob struct {
+	name     string
+	schedule Schedule
+	options  JobOptions
+	task     GoroutineTask
+	timer    *WheelTimer
+	next     time.Time // 下一次的计划时间（不含抖动），零值表示不再执行
+	running  int       // 正在执行（包括已提交、等待协程池执行）的次数
+	queued   int       // 排队等待补执行的次数
+	removed  bool
+	runs     int64
+	skipped  int64
+	failures int64
+	lastRun  time.Time
+	lastErr  error
+}
+
+// Scheduler 定时任务调度器，任务在协程池上执行
+type Scheduler struct {
+	pool     *GoroutinePool
+	wheel    *TimingWheel
+	mu       sync.Mutex
+	jobs     map[string]*cronJob
+	closed   bool
+	inflight sync.WaitGroup // 正在执行的任务
+	ctx      context.Context
+	cancel   context.CancelFunc
+	dropped  int64 // 关闭或删除任务时丢弃的排队补执行次数
+	rejected int64 // 协程池拒绝（已关闭）的次数
+}
+
+// NewScheduler 创建调度器，任务提交到 pool 执行；调度器不负责关闭 pool，应在 Shutdown 之后再关闭它
+func NewScheduler(pool *GoroutinePool) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		pool:   pool,
+		wheel:  SharedTimingWheel(),
+		jobs:   make(map[string]*cronJob),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// AddCron 按 cron 表达式或 "@every 时长" 添加任务
+func (s *Scheduler) AddCron(name, expr string, options JobOptions, task GoroutineTask) error {
+	schedule, err := ParseSchedule(expr)
+	if err != nil {
+		return err
+	}
+	return s.AddJob(name, schedule, options, task)
+}
+
+// AddJob 按调度规则添加任务，任务名不能重复
+func (s *Scheduler) AddJob(name string, schedule Schedule, options JobOptions, task GoroutineTask) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrSchedulerClosed
+	}
+	if _, ok := s.jobs[name]; ok {
+		return fmt.Errorf("%w: %s", ErrJobExists, name)
+	}
+	job := &cronJob{name: name, schedule: schedule, options: options, task: task}
+	s.jobs[name] = job
+	s.scheduleLocked(job, schedule.Next(time.Now()))
+	return nil
+}
+
+// Remove 删除任务，正在执行的那一次不受影响，排队中的补执行被丢弃；任务不存在时返回 false
+func (s *Scheduler) Remove(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[name]
+	if !ok {
+		return false
+	}
+	delete(s.jobs, name)
+	job.removed = true
+	if job.timer != nil {
+		job.timer.Stop()
+	}
+	s.dropped += int64(job.queued)
+	job.queued = 0
+	return true
+}
+
+// scheduleLocked 在时间轮上安排任务在 next（加上抖动）触发，next 为零值时不再安排
+func (s *Scheduler) scheduleLocked(job *cronJob, next time.Time) {
+	job.next = next
+	if next.IsZero() {
+		return
+	}
+	delay := time.Until(next)
+	if job.options.Jitter > 0 {
+		delay += rand.N(job.options.Jitter)
+	}
+	job.timer = s.wheel.AfterFunc(delay, func() { s.trigger(job) })
+}
+
+// trigger 到达计划时间：先安排下一次，再按重叠策略决定是否提交本次执行
+func (s *Scheduler) trigger(job *cronJob) {
+	s.mu.Lock()
+	if s.closed || job.removed {
+		s.mu.Unlock()
+		return
+	}
+	// 从计划时间算下一次，避免误差累积；落后太多时跳过已经错过的时间点
+	now := time.Now()
+	next := job.schedule.Next(job.next)
+	if !next.IsZero() && next.Before(now) {
+		next = job.schedule.Next(now)
+	}
+	s.scheduleLocked(job, next)
+
+	if job.running > 0 {
+		switch job.options.Overlap {
+		case OverlapSkip:
+			job.skipped++
+			s.mu.Unlock()
+			return
+		case OverlapQueue:
+			job.queued++
+			s.mu.Unlock()
+			return
+		}
+	}
+	job.running++
+	s.inflight.Add(1)
+	s.mu.Unlock()
+
+	if err := s.pool.Submit(func(context.Context) error { return s.run(job) }); err != nil {
+		// 协程池已关闭，本次执行作废
+		s.mu.Lock()
+		s.rejected++
+		job.running--
+		s.mu.Unlock()
+		s.inflight.Done()
+	}
+}
+
+// run 在协程池中执行任务，排队策略下执行完后继续补执行排队的次数
+func (s *Scheduler) run(job *cronJob) error {
+	for {
+		err := safeCall(s.ctx, job.task)
+
+		s.mu.Lock()
+		job.runs++
+		job.lastRun = time.Now()
+		job.lastErr = err
+		if err != nil {
+			job.failures++
+		}
+		if job.queued > 0 && !s.closed && !job.removed {
+			job.queued--
+			s.mu.Unlock()
+			continue
+		}
+		job.running--
+		s.mu.Unlock()
+		s.inflight.Done()
+		return err
+	}
+}
+
+// Shutdown 停止调度：不再触发新的执行，丢弃排队中的补执行，等待正在执行的任务结束；
+// ctx 先结束时取消任务的上下文并返回 ctx 的错误。可重复调用
+func (s *Scheduler) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		for _, job := range s.jobs {
+			if job.timer != nil {
+				job.timer.Stop()
+			}
+			s.dropped += int64(job.queued)
+			job.queued = 0
+		}
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.inflight.Wait()
+		close(done)
+	}()
+	defer s.cancel()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats 返回调度器和每个任务的统计信息
+func (s *Scheduler) Stats() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := make(map[string]interface{}, len(s.jobs))
+	running := 0
+	for name, job := range s.jobs {
+		running += job.running
+		stats := map[string]interface{}{
+			"runs":     job.runs,
+			"skipped":  job.skipped,
+			"queued":   job.queued,
+			"failures": job.failures,
+			"running":  job.running,
+			"overlap":  job.options.Overlap.String(),
+		}
+		if !job.next.IsZero() && !s.closed {
+			stats["next"] = job.next
+		}
+		if job.lastErr != nil {
+			stats["lastError"] = job.lastErr.Error()
+		}
+		jobs[name] = stats
+	}
+	return map[string]interface{}{
+		"jobs":     jobs,
+		"running":  running,
+		"closed":   s.closed,
+		"dropped":  s.dropped,
+		"rejected": s.rejected,
+	}
+}
+
+// 场景示例：运维后台的定时任务——报表、巡检、缓存预热，以及发布时的优雅关闭
+func CronSchedulerDemo() {
+	fmt.Println("定时任务调度器示例 (运维后台):")
+
+	// 1. 解析 cron 表达式，从固定时间开始计算接下来的执行时间
+	base := time.Date(2024, 2, 28, 23, 50, 0, 0, time.Local)
+	fmt.Printf("从 %s 开始:\n", base.Format("2006-01-02 15:04:05 Mon"))
+	for _, expr := range []string{"*/15 * * * *", "0 9 * * MON-FRI", "@daily", "0 0 29 2 *", "30 */20 * * * *", "0 0 13 * FRI"} {
+		schedule, err := ParseSchedule(expr)
+		if err != nil {
+			fmt.Printf("  %-18s 解析失败: %v\n", expr, err)
+			continue
+		}
+		var times []string
+		t := base
+		for i := 0; i < 3; i++ {
+			t = schedule.Next(t)
+			times = append(times, t.Format("2006-01-02 15:04:05 Mon"))
+		}
+		fmt.Printf("  %-18s %s\n", expr, strings.Join(times, " | "))
+	}
+	for _, expr := range []string{"0 25 * * *", "* * *", "@every abc"} {
+		_, err := ParseSchedule(expr)
+		fmt.Printf("  %-18s %v\n", expr, err)
+	}
+
+	// 2. 重叠策略：每50ms触发一次，每次执行需要120ms
+	pool := NewGoroutinePool(8, 100)
+	defer pool.Shutdown()
+	scheduler := NewScheduler(pool)
+	slow := func(ctx context.Context) error {
+		select {
+		case <-time.After(120 * time.Millisecond):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	var maxConcurrent, concurrent atomic.Int32
+	for _, policy := range []OverlapPolicy{OverlapSkip, OverlapQueue, OverlapConcurrent} {
+		task := slow
+		if policy == OverlapConcurrent {
+			task = func(ctx context.Context) error {
+				n := concurrent.Add(1)
+				defer concurrent.Add(-1)
+				for {
+					m := maxConcurrent.Load()
+					if n <= m || maxConcurrent.CompareAndSwap(m, n) {
+						break
+					}
+				}
+				return slow(ctx)
+			}
+		}
+		scheduler.AddJob("报表-"+policy.String(), Interval(50*time.Millisecond), JobOptions{Overlap: policy}, task)
+	}
+
+	// 3. 抖动：5个实例的缓存预热都配置为每100ms一次，抖动把它们错开
+	var offsetsMu sync.Mutex
+	var offsets []int64
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		scheduler.AddCron(fmt.Sprintf("缓存预热-%d", i), "@every 100ms", JobOptions{Jitter: 80 * time.Millisecond}, func(ctx context.Context) error {
+			offsetsMu.Lock()
+			if len(offsets) < 5 {
+				offsets = append(offsets, time.Since(start).Milliseconds())
+			}
+			offsetsMu.Unlock()
+			return nil
+		})
+	}
+
+	// 4. 任务 panic 被恢复，计为失败，下一次照常调度
+	scheduler.AddJob("巡检", Interval(100*time.Millisecond), DefaultJobOptions, func(ctx context.Context) error {
+		panic("巡检脚本空指针")
+	})
+
+	time.Sleep(520 * time.Millisecond)
+	stats := scheduler.Stats()
+	jobs := stats["jobs"].(map[string]interface{})
+	for _, policy := range []OverlapPolicy{OverlapSkip, OverlapQueue, OverlapConcurrent} {
+		job := jobs["报表-"+policy.String()].(map[string]interface{})
+		fmt.Printf("%s策略: 执行完成 %d 次，跳过 %d 次，排队 %d 次，正在执行 %d 个\n",
+			policy, job["runs"], job["skipped"], job["queued"], job["running"])
+	}
+	fmt.Printf("并发策略同时执行的最大数量: %d\n", maxConcurrent.Load())
+	offsetsMu.Lock()
+	fmt.Printf("5个缓存预热实例第一次执行的时间(ms): %v（不加抖动时都在100ms附近）\n", offsets)
+	offsetsMu.Unlock()
+	inspect := jobs["巡检"].(map[string]interface{})
+	fmt.Printf("巡检: 执行 %d 次，失败 %d 次，最后一次错误: %v\n", inspect["runs"], inspect["failures"], inspect["lastError"])
+
+	// 5. 优雅关闭：等待正在执行的报表完成，排队中的补执行被丢弃
+	shutdownStart := time.Now()
+	err := scheduler.Shutdown(context.Background())
+	stats = scheduler.Stats()
+	fmt.Printf("关闭: 耗时 %dms，错误: %v，丢弃排队 %d 次，剩余执行中 %d 个\n",
+		time.Since(shutdownStart).Milliseconds(), err, stats["dropped"], stats["running"])
+	fmt.Printf("关闭后添加任务: %v\n", scheduler.AddCron("迟到的任务", "@hourly", DefaultJobOptions, slow))
+
+	// 6. 关闭超时：长任务在关闭期限内没有结束，任务的上下文被取消
+	longRunning := NewScheduler(pool)
+	canceled := make(chan error, 1)
+	longRunning.AddJob("数据归档", Interval(10*time.Millisecond), DefaultJobOptions, func(ctx context.Context) error {
+		<-ctx.Done()
+		canceled <- ctx.Err()
+		return ctx.Err()
+	})
+	time.Sleep(50 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	err = longRunning.Shutdown(ctx)
+	cancel()
+	fmt.Printf("关闭期限30ms: %v，归档任务收到: %v\n", err, <-canceled)
+}
@@ -0,0 +1,531 @@
+package concurrency
+
+/*
+死锁检测器（Deadlock Detector）
+
+原理：
+死锁的必要条件之一是循环等待：协程 A 持有锁1等待锁2，协程 B 持有锁2等待锁1，谁也无法继续。
+检测器记录每把锁当前被哪些协程持有、每个协程正在等待哪把锁，构成一张"等待图"：
+协程 g 等待锁 L，就依赖于 L 的持有者；协程准备阻塞时，如果沿着依赖关系能回到它自己，就形成了循环等待。
+读写锁的写等待需要所有持有者都释放，任一持有者依赖 g 即构成死锁；
+信号量的等待只需要任一持有者释放，只有所有持有者都依赖 g 时才构成死锁。
+另外，检测器还可以记录锁的获取顺序（持有 A 时获取 B，记为 A→B），
+一旦出现与历史相反的顺序就报告"锁顺序反转"：这次没有死锁，但换一种调度就可能死锁。
+
+关键特点：
+1. 可选启用：锁默认不跟踪，调用 EnableDeadlockDetection 后才会记录持有和等待关系，未启用时只多一次 nil 判断
+2. 覆盖 CustomRWMutex 的读锁、写锁、锁降级、Try 系列和超时加锁，以及 Semaphore 的各种获取方式
+3. 能发现自死锁（持有写锁再次加锁、持有读锁再加写锁）和读写锁写优先导致的"重入读锁"死锁：
+   协程持有读锁时有写入者在等待，再次 RLock 会被写入者挡住，而写入者在等它释放读锁
+4. 检测到循环等待时，按配置 panic（*DeadlockError）或只记录日志；锁顺序反转只记录日志
+5. 在形成循环的最后一个协程准备阻塞时检测，panic 发生在该协程中，它释放已持有的锁后其他协程可以继续
+
+实现方式：
+- Go 不提供协程ID，通过解析 runtime.Stack 输出的第一行得到，开销较大，只适合调试和教学
+- 检测器用一把互斥锁保护持有表、等待表和锁顺序图；锁在自己的内部互斥锁内调用检测器，顺序固定，不会互相死锁
+- 深度优先搜索等待图；已访问的协程不再展开，保证不会误报
+- 信号量的令牌可以由其他协程归还，归还者不持有时从任意一个持有者名下扣除
+
+应用场景：
+- 教学：直观展示循环等待、自死锁和锁顺序问题
+- 调试：在测试环境启用，把"程序卡住"变成带有持有和等待关系的 panic
+- 组合使用多把锁的场景：转账、多资源分配
+
+优缺点：
+- 优点：死锁发生的瞬间就能发现，并给出涉及的协程、持有的锁和等待的锁
+- 缺点：获取协程ID和维护全局表有明显开销，不适合生产环境的热路径；
+  只能发现由被跟踪的锁构成的死锁，通道、sync.Mutex 等参与的死锁无法发现
+
+以下实现了基于等待图的死锁检测器和锁顺序检查，以及转账、重入读锁和资源池死锁的示例。
+*/
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DeadlockAction 检测到死锁时的处理方式
+type DeadlockAction int
+
+const (
+	DeadlockPanic DeadlockAction = iota // 在形成循环的协程中 panic
+	DeadlockLog                         // 只记录日志，协程继续阻塞
+)
+
+// DeadlockDetectorOptions 死锁检测器配置
+type DeadlockDetectorOptions struct {
+	Action     DeadlockAction                           // 检测到死锁时的处理方式
+	CheckOrder bool                                     // 是否检查锁顺序反转
+	Logger     func(format string, args ...interface{}) // 日志函数，为 nil 时使用 log.Printf
+}
+
+// DefaultDeadlockDetectorOptions 默认配置：检测到死锁时 panic，检查锁顺序
+var DefaultDeadlockDetectorOptions = DeadlockDetectorOptions{
+	Action:     DeadlockPanic,
+	CheckOrder: true,
+}
+
+// DeadlockError 检测到的循环等待，Cycle 按依赖顺序描述涉及的协程
+type DeadlockError struct {
+	Cycle []string
+}
+
+// Error 实现 error 接口
+func (e *DeadlockError) Error() string {
+	return "检测到死锁: " + strings.Join(e.Cycle, " -> ")
+}
+
+// lockKind 被跟踪的锁的类型
+type lockKind int
+
+const (
+	lockRW        lockKind = iota // 读写锁，写优先
+	lockSemaphore                 // 信号量
+)
+
+// lockTracker 一把被跟踪的锁，方法在接收者为 nil 时什么也不做
+type lockTracker struct {
+	d              *DeadlockDetector
+	name           string
+	kind           lockKind
+	holders        map[int64]int  // 协程ID -> 持有次数
+	writer         int64          // 持有写锁的协程ID，0表示没有
+	waitingWriters map[int64]bool // 正在等待写锁的协程
+}
+
+// waitRecord 协程正在等待的锁
+type waitRecord struct {
+	lock  *lockTracker
+	write bool
+}
+
+// DeadlockDetector 基于等待图的死锁检测器
+type DeadlockDetector struct {
+	mu         sync.Mutex
+	options    DeadlockDetectorOptions
+	locks      int
+	waits      map[int64]waitRecord                   // 协程ID -> 正在等待的锁
+	held       map[int64]map[*lockTracker]int         // 协程ID -> 持有的锁和次数
+	order      map[*lockTracker]map[*lockTracker]bool // 锁顺序图：持有 A 时获取过 B
+	reported   map[[2]*lockTracker]bool               // 已报告过的顺序反转
+	deadlocks  int64
+	inversions int64
+}
+
+// NewDeadlockDetector 创建死锁检测器
+func NewDeadlockDetector(options DeadlockDetectorOptions) *DeadlockDetector {
+	if options.Logger == nil {
+		options.Logger = log.Printf
+	}
+	return &DeadlockDetector{
+		options:  options,
+		waits:    make(map[int64]waitRecord),
+		held:     make(map[int64]map[*lockTracker]int),
+		order:    make(map[*lockTracker]map[*lockTracker]bool),
+		reported: make(map[[2]*lockTracker]bool),
+	}
+}
+
+// track 注册一把需要跟踪的锁
+func (d *DeadlockDetector) track(name string, kind lockKind) *lockTracker {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.locks++
+	return &lockTracker{
+		d:              d,
+		name:           name,
+		kind:           kind,
+		holders:        make(map[int64]int),
+		waitingWriters: make(map[int64]bool),
+	}
+}
+
+// goroutineID 从 runtime.Stack 的第一行 "goroutine 123 [running]:" 解析当前协程ID
+func goroutineID() int64 {
+	var buf [64]byte
+	n := runtime.Stack(buf[:], false)
+	fields := bytes.Fields(bytes.TrimPrefix(buf[:n], []byte("goroutine ")))
+	if len(fields) == 0 {
+		return 0
+	}
+	id, _ := strconv.ParseInt(string(fields[0]), 10, 64)
+	return id
+}
+
+// beforeWait 当前协程即将阻塞等待这把锁：登记等待并检查循环等待。
+// 配置为 panic 时返回 *DeadlockError 且不登记等待，调用方恢复自身状态后 panic；配置为记录日志时返回 nil
+func (l *lockTracker) beforeWait(write bool) *DeadlockError {
+	if l == nil {
+		return nil
+	}
+	d := l.d
+	gid := goroutineID()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	rec := waitRecord{lock: l, write: write}
+	if path := d.cycleLocked(gid, rec); path != nil {
+		d.deadlocks++
+		err := &DeadlockError{Cycle: d.describeLocked(gid, rec, path)}
+		if d.options.Action == DeadlockPanic {
+			return err
+		}
+		d.options.Logger("%v", err)
+	}
+	d.waits[gid] = rec
+	if write {
+		l.waitingWriters[gid] = true
+	}
+	return nil
+}
+
+// giveUp 当前协程放弃等待（超时或取消）
+func (l *lockTracker) giveUp() {
+	if l == nil {
+		return
+	}
+	gid := goroutineID()
+	l.d.mu.Lock()
+	defer l.d.mu.Unlock()
+	delete(l.d.waits, gid)
+	delete(l.waitingWriters, gid)
+}
+
+// acquired 当前协程获得了这把锁，清除等待记录、登记持有并检查锁顺序
+func (l *lockTracker) acquired(write bool) {
+	if l == nil {
+		return
+	}
+	d := l.d
+	gid := goroutineID()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.waits, gid)
+	delete(l.waitingWriters, gid)
+	if d.options.CheckOrder {
+		d.checkOrderLocked(gid, l)
+	}
+	l.holders[gid]++
+	if write {
+		l.writer = gid
+	}
+	if d.held[gid] == nil {
+		d.held[gid] = make(map[*lockTracker]int)
+	}
+	d.held[gid][l]++
+}
+
+// released 当前协程释放了这把锁；不是持有者时（信号量由其他协程归还）从任意一个持有者名下扣除
+func (l *lockTracker) released(write bool) {
+	if l == nil {
+		return
+	}
+	d := l.d
+	gid := goroutineID()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if write {
+		gid, l.writer = l.writer, 0
+	} else if l.holders[gid] == 0 {
+		for holder := range l.holders {
+			gid = holder
+			break
+		}
+	}
+	if l.holders[gid] == 0 {
+		return
+	}
+	l.holders[gid]--
+	if l.holders[gid] == 0 {
+		delete(l.holders, gid)
+	}
+	if d.held[gid][l]--; d.held[gid][l] <= 0 {
+		delete(d.held[gid], l)
+		if len(d.held[gid]) == 0 {
+			delete(d.held, gid)
+		}
+	}
+}
+
+// downgraded 当前协程把写锁降级为读锁，持有关系不变
+func (l *lockTracker) downgraded() {
+	if l == nil {
+		return
+	}
+	l.d.mu.Lock()
+	defer l.d.mu.Unlock()
+	l.writer = 0
+}
+
+// blockersLocked 返回等待 rec 的协程 gid 依赖的协程，以及是否需要全部释放（true）还是任一释放（false）
+func (d *DeadlockDetector) blockersLocked(gid int64, rec waitRecord) ([]int64, bool) {
+	l := rec.lock
+	var blockers []int64
+	switch {
+	case l.kind == lockSemaphore:
+		for holder := range l.holders {
+			blockers = append(blockers, holder)
+		}
+		return blockers, false
+	case rec.write:
+		for holder := range l.holders {
+			blockers = append(blockers, holder)
+		}
+	default:
+		// 读锁被持有的写锁和等待中的写入者挡住（写优先）
+		if l.writer != 0 {
+			blockers = append(blockers, l.writer)
+		}
+		for writer := range l.waitingWriters {
+			if writer != gid {
+				blockers = append(blockers, writer)
+			}
+		}
+	}
+	sort.Slice(blockers, func(i, j int) bool { return blockers[i] < blockers[j] })
+	return blockers, true
+}
+
+// cycleLocked 检查协程 gid 开始等待 rec 后是否形成循环等待，返回依赖链上的协程（不含 gid），没有循环时返回 nil
+func (d *DeadlockDetector) cycleLocked(gid int64, rec waitRecord) []int64 {
+	visited := map[int64]bool{gid: true}
+	return d.dependsLocked(gid, rec, gid, visited)
+}
+
+// dependsLocked 判断等待 rec 的协程 waiter 是否（间接）依赖 target，返回依赖链
+func (d *DeadlockDetector) dependsLocked(waiter int64, rec waitRecord, target int64, visited map[int64]bool) []int64 {
+	blockers, all := d.blockersLocked(waiter, rec)
+	if len(blockers) == 0 {
+		return nil
+	}
+	var chain []int64
+	for _, b := range blockers {
+		var path []int64
+		if b == target {
+			path = []int64{}
+		} else if next, waiting := d.waits[b]; waiting && !visited[b] {
+			visited[b] = true
+			if sub := d.dependsLocked(b, next, target, visited); sub != nil {
+				path = append([]int64{b}, sub...)
+			}
+		}
+		if all && path != nil {
+			return path // 需要全部释放：任一持有者依赖 target 即死锁
+		}
+		if !all && path == nil {
+			return nil // 任一释放即可：有一个持有者不依赖 target 就不是死锁
+		}
+		if chain == nil {
+			chain = path
+		}
+	}
+	if all {
+		return nil
+	}
+	return chain
+}
+
+// describeLocked 描述依赖链上每个协程持有和等待的锁
+func (d *DeadlockDetector) describeLocked(gid int64, rec waitRecord, path []int64) []string {
+	describe := func(g int64, w waitRecord) string {
+		names := make([]string, 0, len(d.held[g]))
+		for l := range d.held[g] {
+			names = append(names, l.name)
+		}
+		sort.Strings(names)
+		mode := "读"
+		if w.lock.kind == lockSemaphore {
+			mode = "许可"
+		} else if w.write {
+			mode = "写"
+		}
+		return fmt.Sprintf("goroutine %d 持有 %v 等待 %s(%s)", g, names, w.lock.name, mode)
+	}
+	cycle := []string{describe(gid, rec)}
+	for _, g := range path {
+		cycle = append(cycle, describe(g, d.waits[g]))
+	}
+	return cycle
+}
+
+// checkOrderLocked 记录协程 gid 持有的锁到 l 的顺序，出现与历史相反的顺序时报告
+func (d *DeadlockDetector) checkOrderLocked(gid int64, l *lockTracker) {
+	for prev := range d.held[gid] {
+		if prev == l {
+			continue
+		}
+		if d.order[prev] == nil {
+			d.order[prev] = make(map[*lockTracker]bool)
+		}
+		d.order[prev][l] = true
+		if d.reachableLocked(l, prev, map[*lockTracker]bool{}) && !d.reported[[2]*lockTracker{prev, l}] {
+			d.reported[[2]*lockTracker{prev, l}] = true
+			d.reported[[2]*lockTracker{l, prev}] = true
+			d.inversions++
+			d.options.Logger("锁顺序反转: goroutine %d 持有 %s 后获取 %s，之前出现过相反的顺序，可能死锁", gid, prev.name, l.name)
+		}
+	}
+}
+
+// reachableLocked 判断锁顺序图中是否存在 from 到 to 的路径
+func (d *DeadlockDetector) reachableLocked(from, to *lockTracker, visited map[*lockTracker]bool) bool {
+	if from == to {
+		return true
+	}
+	visited[from] = true
+	for next := range d.order[from] {
+		if !visited[next] && d.reachableLocked(next, to, visited) {
+			return true
+		}
+	}
+	return false
+}
+
+// Stats 返回死锁检测器的统计信息
+func (d *DeadlockDetector) Stats() map[string]interface{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return map[string]interface{}{
+		"locks":      d.locks,
+		"holders":    len(d.held),
+		"waiting":    len(d.waits),
+		"deadlocks":  d.deadlocks,
+		"inversions": d.inversions,
+	}
+}
+
+// 场景示例：转账时按相反顺序加锁、重入读锁、两个资源池交叉申请
+func DeadlockDetectorDemo() {
+	fmt.Println("死锁检测器示例:")
+
+	var logs []string
+	var logsMu sync.Mutex
+	detector := NewDeadlockDetector(DeadlockDetectorOptions{
+		Action:     DeadlockPanic,
+		CheckOrder: true,
+		Logger: func(format string, args ...interface{}) {
+			logsMu.Lock()
+			logs = append(logs, fmt.Sprintf(format, args...))
+			logsMu.Unlock()
+		},
+	})
+	// catch 把检测器的 panic 转换为错误，其他 panic 继续向上传播
+	catch := func(err *error) {
+		if r := recover(); r != nil {
+			deadlock, ok := r.(*DeadlockError)
+			if !ok {
+				panic(r)
+			}
+			*err = deadlock
+		}
+	}
+
+	// 1. 转账：A 转给 B 先锁 A 再锁 B，B 转给 A 先锁 B 再锁 A
+	type account struct {
+		mu      *CustomRWMutex
+		balance int
+	}
+	newAccount := func(name string, balance int) *account {
+		a := &account{mu: NewCustomRWMutex(), balance: balance}
+		a.mu.EnableDeadlockDetection(detector, name)
+		return a
+	}
+	alice, bob := newAccount("账户A", 100), newAccount("账户B", 100)
+	transfer := func(from, to *account, amount int, results chan<- error) {
+		var err error
+		defer func() { results <- err }()
+		defer catch(&err)
+		from.mu.Lock()
+		defer from.mu.Unlock()
+		time.Sleep(20 * time.Millisecond) // 让两个转账都拿到第一把锁
+		to.mu.Lock()
+		defer to.mu.Unlock()
+		from.balance -= amount
+		to.balance += amount
+	}
+	results := make(chan error, 2)
+	go transfer(alice, bob, 30, results)
+	go transfer(bob, alice, 50, results)
+	for i := 0; i < 2; i++ {
+		if err := <-results; err != nil {
+			fmt.Printf("转账失败: %v\n", err)
+		}
+	}
+	fmt.Printf("一笔转账因死锁放弃并释放锁，另一笔完成: A=%d, B=%d\n", alice.balance, bob.balance)
+
+	// 2. 重入读锁：持有读锁时有写入者在等待，再次 RLock 被写入者挡住
+	config := NewCustomRWMutex()
+	config.EnableDeadlockDetection(detector, "配置锁")
+	writerDone := make(chan struct{})
+	err := func() (err error) {
+		defer catch(&err)
+		config.RLock()
+		defer config.RUnlock()
+		go func() {
+			config.Lock() // 等待上面的读锁释放
+			config.Unlock()
+			close(writerDone)
+		}()
+		time.Sleep(20 * time.Millisecond)
+		config.RLock() // 写优先：被等待中的写入者挡住
+		config.RUnlock()
+		return nil
+	}()
+	<-writerDone
+	fmt.Printf("重入读锁: %v\n", err)
+
+	// 3. 自死锁：持有写锁时再次加写锁
+	err = func() (err error) {
+		defer catch(&err)
+		config.Lock()
+		defer config.Unlock()
+		config.Lock()
+		return nil
+	}()
+	fmt.Printf("重复加写锁: %v\n", err)
+
+	// 4. 资源池：数据库连接和缓存连接各只有1个，两个任务按相反顺序申请
+	dbPool, cachePool := NewSemaphore(1), NewSemaphore(1)
+	dbPool.EnableDeadlockDetection(detector, "数据库连接池")
+	cachePool.EnableDeadlockDetection(detector, "缓存连接池")
+	job := func(first, second *Semaphore, results chan<- error) {
+		var err error
+		defer func() { results <- err }()
+		defer catch(&err)
+		first.Acquire()
+		defer first.Release()
+		time.Sleep(20 * time.Millisecond)
+		second.Acquire()
+		second.Release()
+	}
+	go job(dbPool, cachePool, results)
+	go job(cachePool, dbPool, results)
+	for i := 0; i < 2; i++ {
+		if err := <-results; err != nil {
+			fmt.Printf("资源池: %v\n", err)
+		}
+	}
+
+	// 5. 锁顺序反转：两笔转账先后执行，没有发生死锁，但加锁顺序相反，检测器记录日志提醒
+	carol, dave := newAccount("账户C", 100), newAccount("账户D", 100)
+	transfer(carol, dave, 10, results)
+	transfer(dave, carol, 20, results)
+	fmt.Printf("顺序转账: %v, %v，C=%d, D=%d\n", <-results, <-results, carol.balance, dave.balance)
+
+	logsMu.Lock()
+	for _, line := range logs {
+		fmt.Println(line)
+	}
+	logsMu.Unlock()
+	stats := detector.Stats()
+	fmt.Printf("统计: 跟踪 %d 把锁，发现死锁 %d 次，锁顺序反转 %d 次\n", stats["locks"], stats["deadlocks"], stats["inversions"])
+}
@@ -0,0 +1,277 @@
+package concurrency
+
+/*
+延迟队列（DelayQueue）
+
+原理：
+很多场景需要"过一段时间再处理"：失败的请求退避后重试、订单30分钟未支付自动取消、缓存条目到期清理。
+为每个元素启动一个定时器或协程在元素很多时开销很大，也难以统一关闭；
+延迟队列把所有元素按到期时间放进最小堆，取出方只需要等待堆顶元素到期，
+到期前插入了更早到期的元素时，等待中的取出方被唤醒并重新计算等待时间。
+
+关键特点：
+1. 元素只有在到期后才能被取出，按到期时间从早到晚出队，到期时间相同时按放入顺序出队
+2. Take 阻塞到有元素到期、队列关闭或上下文结束，Poll 不阻塞
+3. 任意时刻只有正在等待的 Take 持有定时器，元素数量不影响定时器数量
+4. Close 后不再接受新元素，返回尚未到期的元素，调用方可以把它们持久化或立即处理
+
+实现方式：
+- container/heap 实现的最小堆，按到期时间和放入序号排序
+- 队列内容变化时关闭并替换一个通知通道，相当于广播；等待的 Take 在通知、定时器和上下文之间 select
+- 互斥锁保护堆，等待期间不持有锁
+
+应用场景：
+- 失败任务按指数退避安排重试
+- TTL 过期清理、会话超时
+- 限流后延迟执行的请求、定时提醒
+
+优缺点：
+- 优点：元素再多也只需要一个等待中的定时器；出队顺序确定；与上下文配合可以随时取消等待
+- 缺点：单个堆和一把锁，大量元素同时到期时取出方会竞争同一把锁；
+  只在内存中保存，进程退出时未到期的元素会丢失；时间精度受定时器精度影响
+
+以下实现了泛型的延迟队列，以及 Webhook 投递失败后按退避时间重试的示例。
+*/
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// delayItem 延迟队列中的元素
+type delayItem[T any] struct {
+	value T
+	at    time.Time // 到期时间
+	seq   uint64    // 放入序号，到期时间相同时按放入顺序出队
+}
+
+// delayHeap 按到期时间排序的最小堆
+type delayHeap[T any] []delayItem[T]
+
+func (h delayHeap[T]) Len() int { return len(h) }
+func (h delayHeap[T]) Less(i, j int) bool {
+	if !h[i].at.Equal(h[j].at) {
+		return h[i].at.Before(h[j].at)
+	}
+	return h[i].seq < h[j].seq
+}
+func (h delayHeap[T]) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *delayHeap[T]) Push(x any)   { *h = append(*h, x.(delayItem[T])) }
+func (h *delayHeap[T]) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	old[len(old)-1] = delayItem[T]{} // 释放元素的引用
+	*h = old[:len(old)-1]
+	return item
+}
+
+// DelayQueue 延迟队列，元素到期后才能取出
+type DelayQueue[T any] struct {
+	mu        sync.Mutex
+	items     delayHeap[T]
+	seq       uint64
+	changed   chan struct{} // 队列内容变化或关闭时关闭并替换
+	closed    bool
+	putCount  int64 // 放入的元素数
+	takeCount int64 // 取出的元素数
+}
+
+// NewDelayQueue 创建延迟队列
+func NewDelayQueue[T any]() *DelayQueue[T] {
+	return &DelayQueue[T]{changed: make(chan struct{})}
+}
+
+// Put 放入元素，delay 之后可以取出；delay 小于等于0时立即可取
+func (q *DelayQueue[T]) Put(value T, delay time.Duration) error {
+	return q.PutAt(value, time.Now().Add(delay))
+}
+
+// PutAt 放入元素，到达 at 之后可以取出
+func (q *DelayQueue[T]) PutAt(value T, at time.Time) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+	q.seq++
+	heap.Push(&q.items, delayItem[T]{value: value, at: at, seq: q.seq})
+	q.putCount++
+	// 只有新元素成为堆顶时等待者才需要重新计算等待时间
+	if q.items[0].seq == q.seq {
+		q.notifyLocked()
+	}
+	return nil
+}
+
+// notifyLocked 唤醒所有等待的 Take，调用方需持有 mu
+func (q *DelayQueue[T]) notifyLocked() {
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
+
+// Poll 取出一个已到期的元素，没有到期元素时立即返回 false
+func (q *DelayQueue[T]) Poll() (T, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.items) == 0 || q.items[0].at.After(time.Now()) {
+		var zero T
+		return zero, false
+	}
+	return q.popLocked(), true
+}
+
+// popLocked 取出堆顶元素，调用方需持有 mu
+func (q *DelayQueue[T]) popLocked() T {
+	q.takeCount++
+	return heap.Pop(&q.items).(delayItem[T]).value
+}
+
+// Take 取出最早到期的元素，没有到期元素时阻塞；队列关闭时返回 ErrQueueClosed，上下文结束时返回上下文的错误
+func (q *DelayQueue[T]) Take(ctx context.Context) (T, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var zero T
+	var timer *time.Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	for {
+		q.mu.Lock()
+		if q.closed {
+			q.mu.Unlock()
+			return zero, ErrQueueClosed
+		}
+		var wait <-chan time.Time
+		if len(q.items) > 0 {
+			delay := time.Until(q.items[0].at)
+			if delay <= 0 {
+				value := q.popLocked()
+				q.mu.Unlock()
+				return value, nil
+			}
+			if timer == nil {
+				timer = time.NewTimer(delay)
+			} else {
+				timer.Reset(delay)
+			}
+			wait = timer.C
+		}
+		changed := q.changed
+		q.mu.Unlock()
+
+		select {
+		case <-wait:
+		case <-changed:
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		}
+	}
+}
+
+// Len 返回队列中的元素数（包括未到期的）
+func (q *DelayQueue[T]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+// Close 关闭队列并唤醒所有等待的 Take，返回尚未取出的元素（按到期时间排序）；重复调用返回 nil
+func (q *DelayQueue[T]) Close() []T {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return nil
+	}
+	q.closed = true
+	remaining := make([]T, 0, len(q.items))
+	for len(q.items) > 0 {
+		remaining = append(remaining, heap.Pop(&q.items).(delayItem[T]).value)
+	}
+	q.notifyLocked()
+	return remaining
+}
+
+// Stats 返回延迟队列的统计信息
+func (q *DelayQueue[T]) Stats() map[string]interface{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	stats := map[string]interface{}{
+		"size":      len(q.items),
+		"putCount":  q.putCount,
+		"takeCount": q.takeCount,
+		"closed":    q.closed,
+		"nextDue":   time.Duration(0), // 距离最早元素到期的时间，已到期或队列为空时为0
+	}
+	if len(q.items) > 0 {
+		stats["nextDue"] = max(time.Until(q.items[0].at), 0)
+	}
+	return stats
+}
+
+// 场景示例：Webhook 投递失败后按指数退避放回延迟队列，投递协程只处理到期的重试
+func DelayQueueDemo() {
+	fmt.Println("延迟队列示例 (Webhook 重试调度):")
+
+	type delivery struct {
+		endpoint string
+		attempt  int
+	}
+	errUnavailable := errors.New("对方服务返回 503")
+	// 每个地址在成功之前会失败的次数
+	failures := map[string]int{"商户A": 0, "商户B": 2, "商户C": 1, "商户D": 5}
+
+	policy := RetryPolicy{MaxAttempts: 4, InitialBackoff: 20 * time.Millisecond, Multiplier: 2}
+	queue := NewDelayQueue[delivery]()
+	for _, endpoint := range []string{"商户A", "商户B", "商户C", "商户D"} {
+		queue.Put(delivery{endpoint: endpoint, attempt: 1}, 0)
+	}
+
+	start := time.Now()
+	var logs []string
+	pending := len(failures)
+	for pending > 0 {
+		d, err := queue.Take(context.Background())
+		if err != nil {
+			break
+		}
+		elapsed := time.Since(start).Round(10 * time.Millisecond)
+		if d.attempt > failures[d.endpoint] {
+			logs = append(logs, fmt.Sprintf("%6v %s 第%d次投递成功", elapsed, d.endpoint, d.attempt))
+			pending--
+			continue
+		}
+		if !policy.shouldRetry(d.attempt, errUnavailable) {
+			logs = append(logs, fmt.Sprintf("%6v %s 第%d次投递失败，放弃并记录告警", elapsed, d.endpoint, d.attempt))
+			pending--
+			continue
+		}
+		backoff := policy.Backoff(d.attempt)
+		logs = append(logs, fmt.Sprintf("%6v %s 第%d次投递失败 (%v)，%v 后重试", elapsed, d.endpoint, d.attempt, errUnavailable, backoff))
+		queue.Put(delivery{endpoint: d.endpoint, attempt: d.attempt + 1}, backoff)
+	}
+	for _, line := range logs {
+		fmt.Println(line)
+	}
+
+	// 关闭时取回尚未到期的元素
+	queue.Put(delivery{endpoint: "商户E", attempt: 2}, time.Minute)
+	stats := queue.Stats()
+	fmt.Printf("放入 %d 次，取出 %d 次，队列中 %d 个，最早 %v 后到期\n",
+		stats["putCount"], stats["takeCount"], stats["size"], stats["nextDue"].(time.Duration).Round(time.Second))
+	remaining := queue.Close()
+	fmt.Printf("关闭时取回未到期的投递: %v\n", remaining)
+	_, err := queue.Take(context.Background())
+	fmt.Printf("关闭后 Take: %v\n", err)
+}
@@ -0,0 +1,368 @@
+package concurrency
+
+/*
+Future / Promise - 异步任务的结果及其组合
+
+原理：
+协程池的 Submit 只接收 func(context.Context) error，任务的结果需要调用方自己建通道、在任务里写入、在外面读取，
+每个调用点都要重复这套代码，而且结果与提交顺序的对应关系要自己维护。
+Future 代表一个尚未完成的计算结果：提交任务时立即拿到 Future，任务完成时写入结果，
+调用方在需要结果的时候调用 Get 等待，等待可以被上下文取消或超时打断。
+
+关键特点：
+1. 泛型：Future[T] 直接携带任务返回值的类型，读取时无需类型断言
+2. Get(ctx) 阻塞到任务完成或上下文结束，上下文先结束时返回上下文的错误，任务本身不受影响、继续执行
+3. 结果只写入一次，之后可以被任意多个协程反复读取
+4. Done 返回任务完成时关闭的通道，可以放进 select 与其他事件一起等待
+5. SubmitFunc 提交失败（协程池已关闭）时返回一个已经带着错误完成的 Future，调用方统一在 Get 时处理错误；
+   已入队的任务在协程池关闭时仍会执行完，对应的 Future 总会完成
+6. Promise 是 Future 的写入端：不经过协程池的异步操作（回调、队列消费结果）通过 Resolve、Reject 完成 Future，
+   只有第一次完成生效
+7. 组合子：Then 在成功时转换结果，Catch 在失败时恢复，All 等待全部成功（任一失败立即失败），
+   Any 取第一个成功的结果（全部失败时返回所有错误），组合的结果仍是 Future，可以继续组合
+
+实现方式：
+- 结果和错误写入后关闭 done 通道，关闭通道建立 happens-before 关系，读取方看到关闭后读到的一定是完整结果
+- SubmitFunc 把 func() (T, error) 包装成 GoroutineTask，任务的错误照常计入协程池的错误统计，panic 以 PanicError 的形式返回
+- 组合子各启动一个协程等待上游 Future 完成，回调中的 panic 转换为 PanicError；
+  Then、Catch 的回调在上游完成后才执行，上游失败时 Then 直接传递错误，上游成功时 Catch 直接传递结果
+
+应用场景：
+- 并发查询多个数据源后按提交顺序汇总结果
+- 发起请求后先做其他工作，需要时再取结果
+- 给单个结果的等待设置超时
+
+优缺点：
+- 优点：调用方不再需要手写结果通道，结果与任务一一对应，等待可取消；多个异步结果可以声明式地组合
+- 缺点：每个任务多一次分配，每个组合子多一个等待协程；Get 超时并不会取消任务，任务需要取消时要自己检查上下文；
+  All 失败、Any 成功后其余任务仍会继续执行
+
+以下实现了 Future、Promise、向协程池提交有返回值任务的 SubmitFunc，以及 Then、Catch、All、Any 组合子。
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Future 异步任务的结果，完成后可被多个协程反复读取
+type Future[T any] struct {
+	done  chan struct{} // 完成时关闭
+	value T
+	err   error
+}
+
+// newFuture 创建未完成的 Future
+func newFuture[T any]() *Future[T] {
+	return &Future[T]{done: make(chan struct{})}
+}
+
+// complete 写入结果，只能调用一次
+func (f *Future[T]) complete(value T, err error) {
+	f.value, f.err = value, err
+	close(f.done)
+}
+
+// Get 等待任务完成并返回结果；上下文先结束时返回零值和上下文的错误，任务继续执行。
+// 任务已经完成时总是返回结果，即使上下文已经结束
+func (f *Future[T]) Get(ctx context.Context) (T, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if f.IsDone() {
+		return f.value, f.err
+	}
+	select {
+	case <-f.done:
+		return f.value, f.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// Done 返回任务完成时关闭的通道
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// IsDone 返回任务是否已完成
+func (f *Future[T]) IsDone() bool {
+	select {
+	case <-f.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// SubmitFunc 向协程池提交有返回值的任务，返回代表结果的 Future；提交失败时 Future 立即以该错误完成
+func SubmitFunc[T any](p *GoroutinePool, fn func() (T, error)) *Future[T] {
+	future := newFuture[T]()
+	err := p.Submit(func(context.Context) (err error) {
+		var value T
+		defer func() {
+			// fn 发生 panic 时也要完成 Future，否则 Get 会一直等待；panic 交给协程池统计
+			if r := recover(); r != nil {
+				err = newPanicError(r)
+			}
+			future.complete(value, err)
+		}()
+		value, err = fn()
+		return err
+	})
+	if err != nil {
+		var zero T
+		future.complete(zero, err)
+	}
+	return future
+}
+
+// Promise Future 的写入端，只有第一次 Resolve 或 Reject 生效
+type Promise[T any] struct {
+	future *Future[T]
+	once   sync.Once
+}
+
+// NewPromise 创建未完成的 Promise
+func NewPromise[T any]() *Promise[T] {
+	return &Promise[T]{future: newFuture[T]()}
+}
+
+// Future 返回 Promise 对应的 Future
+func (p *Promise[T]) Future() *Future[T] {
+	return p.future
+}
+
+// Resolve 以 value 成功完成，返回本次调用是否生效
+func (p *Promise[T]) Resolve(value T) bool {
+	return p.settle(value, nil)
+}
+
+// Reject 以 err 失败完成，返回本次调用是否生效
+func (p *Promise[T]) Reject(err error) bool {
+	var zero T
+	return p.settle(zero, err)
+}
+
+// settle 完成 Future，只有第一次调用生效
+func (p *Promise[T]) settle(value T, err error) bool {
+	settled := false
+	p.once.Do(func() {
+		p.future.complete(value, err)
+		settled = true
+	})
+	return settled
+}
+
+// Resolved 返回已经成功完成的 Future
+func Resolved[T any](value T) *Future[T] {
+	f := newFuture[T]()
+	f.complete(value, nil)
+	return f
+}
+
+// Rejected 返回已经失败完成的 Future
+func Rejected[T any](err error) *Future[T] {
+	var zero T
+	f := newFuture[T]()
+	f.complete(zero, err)
+	return f
+}
+
+// chain 在 f 完成后执行 fn 得到新 Future 的结果，fn 的 panic 转换为 PanicError
+func chain[T, U any](f *Future[T], fn func(value T, err error) (U, error)) *Future[U] {
+	next := newFuture[U]()
+	go func() {
+		<-f.done
+		var value U
+		var err error
+		defer func() {
+			if r := recover(); r != nil {
+				err = newPanicError(r)
+			}
+			next.complete(value, err)
+		}()
+		value, err = fn(f.value, f.err)
+	}()
+	return next
+}
+
+// Then f 成功后用 fn 转换结果；f 失败时直接传递错误，不调用 fn
+func Then[T, U any](f *Future[T], fn func(T) (U, error)) *Future[U] {
+	return chain(f, func(value T, err error) (U, error) {
+		if err != nil {
+			var zero U
+			return zero, err
+		}
+		return fn(value)
+	})
+}
+
+// Catch f 失败后用 fn 从错误中恢复（例如返回默认值），fn 也可以返回新的错误；f 成功时直接传递结果
+func Catch[T any](f *Future[T], fn func(error) (T, error)) *Future[T] {
+	return chain(f, func(value T, err error) (T, error) {
+		if err != nil {
+			return fn(err)
+		}
+		return value, nil
+	})
+}
+
+// All 等待所有 Future 成功，结果按参数顺序排列；任一失败时立即以该错误完成，不再等待其余 Future
+func All[T any](futures ...*Future[T]) *Future[[]T] {
+	promise := NewPromise[[]T]()
+	values := make([]T, len(futures))
+	var remaining sync.WaitGroup
+	remaining.Add(len(futures))
+	for i, f := range futures {
+		go func() {
+			defer remaining.Done()
+			<-f.done
+			if f.err != nil {
+				promise.Reject(f.err)
+				return
+			}
+			values[i] = f.value
+		}()
+	}
+	go func() {
+		remaining.Wait()
+		promise.Resolve(values) // 已经因失败完成时不生效
+	}()
+	return promise.Future()
+}
+
+// Any 以第一个成功的 Future 的结果完成；全部失败时以所有错误的组合完成，没有 Future 时立即失败
+func Any[T any](futures ...*Future[T]) *Future[T] {
+	if len(futures) == 0 {
+		return Rejected[T](errors.New("Any: 没有可等待的 Future"))
+	}
+	promise := NewPromise[T]()
+	errs := make([]error, len(futures))
+	var remaining sync.WaitGroup
+	remaining.Add(len(futures))
+	for i, f := range futures {
+		go func() {
+			defer remaining.Done()
+			<-f.done
+			if f.err != nil {
+				errs[i] = f.err
+				return
+			}
+			promise.Resolve(f.value)
+		}()
+	}
+	go func() {
+		remaining.Wait()
+		promise.Reject(errors.Join(errs...)) // 已经有成功的结果时不生效
+	}()
+	return promise.Future()
+}
+
+// 场景示例：比价服务并发查询多个电商平台的价格，按提交顺序汇总，单个平台等待超时则跳过
+func FutureDemo() {
+	fmt.Println("Future 示例 (并发比价):")
+
+	pool := NewGoroutinePool(4, 16)
+	type quote struct {
+		shop  string
+		price float64
+	}
+	shops := []struct {
+		name    string
+		price   float64
+		latency time.Duration
+		err     error
+	}{
+		{"平台A", 5999, 30 * time.Millisecond, nil},
+		{"平台B", 5799, 60 * time.Millisecond, nil},
+		{"平台C", 0, 20 * time.Millisecond, fmt.Errorf("平台C: 商品已下架")},
+		{"平台D", 5699, 300 * time.Millisecond, nil}, // 响应太慢
+		{"平台E", 5899, 10 * time.Millisecond, nil},
+	}
+
+	futures := make([]*Future[quote], len(shops))
+	for i, shop := range shops {
+		futures[i] = SubmitFunc(pool, func() (quote, error) {
+			time.Sleep(shop.latency)
+			return quote{shop.name, shop.price}, shop.err
+		})
+	}
+
+	// 所有平台共用 100ms 的等待预算
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	best := quote{}
+	for i, future := range futures {
+		q, err := future.Get(ctx)
+		if err != nil {
+			fmt.Printf("  %s: 跳过 (%v)\n", shops[i].name, err)
+			continue
+		}
+		fmt.Printf("  %s: ¥%.0f\n", q.shop, q.price)
+		if best.shop == "" || q.price < best.price {
+			best = q
+		}
+	}
+	fmt.Printf("最低价: %s ¥%.0f\n", best.shop, best.price)
+
+	// 慢平台的任务并没有被取消，完成后 Future 仍然可以读取
+	late, err := futures[3].Get(context.Background())
+	fmt.Printf("平台D 最终返回: ¥%.0f (错误: %v)\n", late.price, err)
+
+	pool.Shutdown()
+	_, err = SubmitFunc(pool, func() (quote, error) { return quote{}, nil }).Get(context.Background())
+	fmt.Printf("协程池关闭后提交: %v\n", err)
+	stats := pool.Stats()
+	fmt.Printf("协程池统计: 提交 %d，成功 %d，失败 %d\n", stats["taskCount"], stats["successCount"], stats["errorCount"])
+
+	// 组合子：不写结果通道，把多个异步结果声明式地组合起来
+	fmt.Println("\n组合子:")
+	pool = NewGoroutinePool(4, 16)
+	defer pool.Shutdown()
+	query := func(name string, latency time.Duration, price float64, err error) *Future[float64] {
+		return SubmitFunc(pool, func() (float64, error) {
+			time.Sleep(latency)
+			return price, err
+		})
+	}
+	ctx = context.Background()
+
+	// All：购物车中所有商品的价格都查到后计算总价，任一商品查询失败则整单失败
+	total := Then(All(query("手机", 20*time.Millisecond, 5999, nil), query("耳机", 10*time.Millisecond, 899, nil)),
+		func(prices []float64) (float64, error) {
+			sum := 0.0
+			for _, p := range prices {
+				sum += p
+			}
+			return sum, nil
+		})
+	sum, err := total.Get(ctx)
+	fmt.Printf("All + Then 购物车总价: ¥%.0f (错误: %v)\n", sum, err)
+
+	_, err = All(query("手机", 20*time.Millisecond, 5999, nil), query("手表", 5*time.Millisecond, 0, fmt.Errorf("手表: 库存服务超时"))).Get(ctx)
+	fmt.Printf("All 任一失败: %v\n", err)
+
+	// Any：同一商品向多个镜像查询，取最先成功的结果
+	price, err := Any(query("镜像1", 50*time.Millisecond, 5999, nil),
+		query("镜像2", 5*time.Millisecond, 0, fmt.Errorf("镜像2: 连接被拒绝")),
+		query("镜像3", 15*time.Millisecond, 5998, nil)).Get(ctx)
+	fmt.Printf("Any 最先成功的镜像报价: ¥%.0f (错误: %v)\n", price, err)
+
+	// Catch：价格服务故障时降级为缓存中的价格
+	cached := Catch(query("手表", 5*time.Millisecond, 0, fmt.Errorf("价格服务故障")), func(err error) (float64, error) {
+		return 1299, nil
+	})
+	price, err = cached.Get(ctx)
+	fmt.Printf("Catch 降级为缓存价格: ¥%.0f (错误: %v)\n", price, err)
+
+	// Promise：回调风格的支付结果转换为 Future
+	payment := NewPromise[string]()
+	time.AfterFunc(10*time.Millisecond, func() { payment.Resolve("支付成功") })
+	status, _ := payment.Future().Get(ctx)
+	fmt.Printf("Promise 支付回调: %s，重复完成是否生效: %v\n", status, payment.Reject(errors.New("重复回调")))
+}
@@ -12,11 +12,17 @@ package concurrency
 2. 重用goroutine，避免频繁的创建和销毁
 3. 管理任务队列，提供优雅的提交和处理机制
 4. 支持优雅关闭，等待所有任务完成
+5. 任务可以带优先级提交，优先级高的任务插队先执行，同一优先级按提交顺序执行
+6. 任务执行时收到上下文，SubmitWithTimeout 为单个任务设置超时，超时的任务在统计中单独计数
+7. 任务中的 panic 被恢复并计入统计，工作协程不会因此退出；任务可以带重试策略提交，失败后按退避策略重新入队（见 pool_retry.go）
+8. 运行时可以用 Resize 增减工作协程数，AutoScaler 按队列积压和任务耗时自动调整（见 pool_autoscaler.go）
 
 实现方式：
-- 使用通道(channel)作为任务队列
+- 使用按优先级排序的堆作为任务队列，由互斥锁和条件变量保护：队列为空时工作协程等待，队列已满时提交者等待
 - 创建固定数量的worker goroutine处理任务
+- 超时从任务开始执行时计算，排队时间不计入；任务需要检查上下文才能在超时后提前返回，协程池不会强行中断任务
 - 提供提交任务和关闭池的接口
+- 缩容时记录需要退出的协程数并唤醒空闲协程，最先取任务的几个协程退出，忙碌的协程执行完手头的任务后再检查
 
 应用场景：
 - Web服务器处理大量并发请求
@@ -32,6 +38,7 @@ package concurrency
 */
 
 import (
+	"container/heap"
 	"context"
 	"errors"
 	"fmt"
@@ -40,20 +47,72 @@ import (
 	"time"
 )
 
-// GoroutineTask 表示要执行的任务
-type GoroutineTask func() error
+// GoroutineTask 表示要执行的任务，ctx 在任务超时时结束，长时间运行的任务应当检查它
+type GoroutineTask func(ctx context.Context) error
+
+// TaskPriority 任务优先级，数值越大越先执行，也可以使用下列常量之外的任意整数
+type TaskPriority int
+
+const (
+	PriorityLow    TaskPriority = -1 // 批量、后台任务
+	PriorityNormal TaskPriority = 0  // Submit 使用的默认优先级
+	PriorityHigh   TaskPriority = 1  // 需要插队的紧急任务
+)
+
+// queuedTask 队列中的任务
+type queuedTask struct {
+	task     GoroutineTask
+	priority TaskPriority
+	seq      uint64        // 提交序号，同一优先级按提交顺序执行
+	retry    *RetryPolicy  // 失败后的重试策略，为 nil 时不重试
+	attempt  int           // 第几次执行，从1开始
+	backoff  time.Duration // 上一次重试前的等待时间，去相关抖动在此基础上随机
+	timeout  time.Duration // 单次执行的超时时间，为0时不限制
+}
+
+// taskHeap 按优先级从高到低、同一优先级按提交顺序排列的堆
+type taskHeap []queuedTask
+
+func (h taskHeap) Len() int { return len(h) }
+func (h taskHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+func (h taskHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *taskHeap) Push(x any)   { *h = append(*h, x.(queuedTask)) }
+func (h *taskHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	old[len(old)-1] = queuedTask{} // 释放任务闭包的引用
+	*h = old[:len(old)-1]
+	return item
+}
 
 // GoroutinePool 协程池
 type GoroutinePool struct {
-	workers      int                // 工作协程数量
-	taskQueue    chan GoroutineTask // 任务队列
-	ctx          context.Context    // 用于控制池生命周期的上下文
-	cancel       context.CancelFunc // 取消函数
-	wg           sync.WaitGroup     // 等待所有工作协程完成
-	running      int32              // 是否正在运行的标志
-	taskCount    int32              // 已提交任务数
-	errorCount   int32              // 错误任务数
-	successCount int32              // 成功任务数
+	mu            sync.Mutex     // 保护任务队列和工作协程数
+	notEmpty      *sync.Cond     // 队列中有任务、有工作协程需要退出或协程池关闭
+	notFull       *sync.Cond     // 队列中有空位或协程池关闭
+	queue         taskHeap       // 任务队列
+	queueSize     int            // 任务队列容量
+	seq           uint64         // 下一个任务的提交序号
+	workers       int            // 工作协程数量
+	retiring      int            // 缩容后还需要退出的工作协程数
+	delayed       int            // 正在等待退避时间、稍后重新入队的重试任务数
+	nextID        int            // 下一个工作协程的ID
+	wg            sync.WaitGroup // 等待所有工作协程完成
+	running       int32          // 是否正在运行的标志
+	taskCount     int32          // 已提交任务数
+	errorCount    int32          // 错误任务数
+	successCount  int32          // 成功任务数
+	canceledCount int32          // 因上下文取消而未执行的任务数
+	timeoutCount  int32          // 执行超时的任务数
+	panicCount    int32          // 发生 panic 的执行次数
+	retryCount    int32          // 失败后重试的次数
+	activeCount   int32          // 正在执行任务的工作协程数
+	busyNanos     int64          // 所有任务累计执行时间（纳秒）
 }
 
 // NewGoroutinePool 创建新的协程池
@@ -66,21 +125,17 @@ func NewGoroutinePool(workers int, queueSize int) *GoroutinePool {
 		queueSize = 100
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-
 	pool := &GoroutinePool{
-		workers:   workers,
-		taskQueue: make(chan GoroutineTask, queueSize),
-		ctx:       ctx,
-		cancel:    cancel,
+		queueSize: queueSize,
 		running:   1, // 初始为运行状态
 	}
+	pool.notEmpty = sync.NewCond(&pool.mu)
+	pool.notFull = sync.NewCond(&pool.mu)
 
 	// 启动工作协程
-	pool.wg.Add(workers)
-	for i := 0; i < workers; i++ {
-		go pool.worker(i)
-	}
+	pool.mu.Lock()
+	pool.resizeLocked(workers)
+	pool.mu.Unlock()
 
 	return pool
 }
@@ -90,68 +145,228 @@ func (p *GoroutinePool) worker(id int) {
 	defer p.wg.Done()
 
 	for {
-		select {
-		case <-p.ctx.Done():
-			// 池已关闭，退出
+		item, ok := p.next()
+		if !ok {
 			return
-		case task, ok := <-p.taskQueue:
-			if !ok {
-				// 任务队列已关闭，退出
-				return
-			}
+		}
+		p.run(item)
+	}
+}
 
-			// 执行任务
-			err := task()
-			if err != nil {
-				atomic.AddInt32(&p.errorCount, 1)
-			} else {
-				atomic.AddInt32(&p.successCount, 1)
-			}
+// next 取出优先级最高的任务，队列为空时等待；需要缩容、或协程池已关闭且队列和等待重试的任务都已清空时返回 false
+func (p *GoroutinePool) next() (queuedTask, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for len(p.queue) == 0 || p.retiring > 0 {
+		if p.retiring > 0 {
+			p.retiring--
+			return queuedTask{}, false
+		}
+		if atomic.LoadInt32(&p.running) == 0 && p.delayed == 0 {
+			// 最后一个重试任务执行完时其他协程可能仍在等待，唤醒它们一起退出
+			p.notEmpty.Broadcast()
+			return queuedTask{}, false
 		}
+		p.notEmpty.Wait()
 	}
+	item := heap.Pop(&p.queue).(queuedTask)
+	p.notFull.Signal()
+	return item, true
 }
 
-// Submit 提交任务到池
-func (p *GoroutinePool) Submit(task GoroutineTask) error {
+// run 执行任务并记录结果和耗时；任务中的 panic 被恢复为 PanicError，失败且策略允许时安排重试
+func (p *GoroutinePool) run(item queuedTask) {
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if item.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, item.timeout)
+	}
+	defer cancel()
+
+	atomic.AddInt32(&p.activeCount, 1)
+	start := time.Now()
+	err := safeCall(ctx, item.task)
+	atomic.AddInt64(&p.busyNanos, int64(time.Since(start)))
+	atomic.AddInt32(&p.activeCount, -1)
+
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		atomic.AddInt32(&p.panicCount, 1)
+	}
+	if errors.Is(err, ErrTaskCanceled) {
+		// 任务执行前上下文已取消（见 SubmitWithContext），不重试，也不计入成功或失败
+		atomic.AddInt32(&p.canceledCount, 1)
+		return
+	}
+	if err != nil && item.retry.shouldRetry(item.attempt, err) {
+		atomic.AddInt32(&p.retryCount, 1)
+		item.backoff = item.retry.NextBackoff(item.attempt, item.backoff)
+		if item.retry.OnRetry != nil {
+			item.retry.OnRetry(item.attempt, err, item.backoff)
+		}
+		p.retryLater(item, item.backoff)
+		return
+	}
+
+	switch {
+	case err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded):
+		// 任务因超时失败，与普通错误分开统计
+		atomic.AddInt32(&p.timeoutCount, 1)
+	case err != nil:
+		atomic.AddInt32(&p.errorCount, 1)
+	default:
+		atomic.AddInt32(&p.successCount, 1)
+	}
+}
+
+// retryLater 等待 delay 后把任务重新放入队列；重新入队不受队列容量限制，协程池关闭后仍会执行完
+func (p *GoroutinePool) retryLater(item queuedTask, delay time.Duration) {
+	p.mu.Lock()
+	p.delayed++
+	p.mu.Unlock()
+
+	time.AfterFunc(delay, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.delayed--
+		p.seq++
+		item.seq = p.seq
+		item.attempt++
+		heap.Push(&p.queue, item)
+		p.notEmpty.Signal()
+	})
+}
+
+// Resize 把工作协程数调整为 n（至少为1）；缩容时多出来的协程执行完手头的任务后退出，不会中断正在执行的任务
+func (p *GoroutinePool) Resize(n int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	if atomic.LoadInt32(&p.running) == 0 {
-		return errors.New("协程池已关闭")
+		return ErrPoolClosed
 	}
+	p.resizeLocked(max(n, 1))
+	return nil
+}
 
-	select {
-	case <-p.ctx.Done():
-		return errors.New("协程池已关闭")
-	case p.taskQueue <- task:
-		atomic.AddInt32(&p.taskCount, 1)
-		return nil
+// resizeLocked 启动或停止工作协程，调用方需持有 mu
+func (p *GoroutinePool) resizeLocked(n int) {
+	for ; p.workers < n; p.workers++ {
+		if p.retiring > 0 {
+			p.retiring-- // 还没来得及退出的协程留下继续工作
+			continue
+		}
+		p.wg.Add(1)
+		go p.worker(p.nextID)
+		p.nextID++
+	}
+	if p.workers > n {
+		p.retiring += p.workers - n
+		p.workers = n
+		p.notEmpty.Broadcast()
 	}
 }
 
-// Shutdown 关闭协程池并等待所有任务完成
+// Workers 返回当前的工作协程数
+func (p *GoroutinePool) Workers() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.workers
+}
+
+// Pending 返回队列中等待执行的任务数
+func (p *GoroutinePool) Pending() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.queue)
+}
+
+// Submit 以普通优先级提交任务到池，队列已满时阻塞
+func (p *GoroutinePool) Submit(task GoroutineTask) error {
+	return p.enqueue(nil, task, PriorityNormal)
+}
+
+// SubmitWithPriority 按优先级提交任务，优先级高的任务先执行，同一优先级按提交顺序执行；队列已满时阻塞
+func (p *GoroutinePool) SubmitWithPriority(task GoroutineTask, priority TaskPriority) error {
+	return p.enqueue(nil, task, priority)
+}
+
+// SubmitWithTimeout 以普通优先级提交任务，任务开始执行 d 之后其上下文结束；d 小于等于0表示不限制。
+// 超时后返回错误的任务计入 timeoutCount 而不是 errorCount
+func (p *GoroutinePool) SubmitWithTimeout(task GoroutineTask, d time.Duration) error {
+	return p.enqueueItem(nil, queuedTask{task: task, priority: PriorityNormal, attempt: 1, timeout: max(d, 0)})
+}
+
+// enqueue 把任务放入队列，队列已满时等待空位；ctx 不为 nil 时在等待期间结束则返回 ErrTaskCanceled
+func (p *GoroutinePool) enqueue(ctx context.Context, task GoroutineTask, priority TaskPriority) error {
+	return p.enqueueItem(ctx, queuedTask{task: task, priority: priority, attempt: 1})
+}
+
+// enqueueItem 把构造好的任务放入队列
+func (p *GoroutinePool) enqueueItem(ctx context.Context, item queuedTask) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if ctx != nil && len(p.queue) >= p.queueSize {
+		// 条件变量不能和通道一起 select，上下文结束时唤醒所有等待空位的协程，由它们各自检查
+		stop := context.AfterFunc(ctx, func() {
+			p.mu.Lock()
+			p.notFull.Broadcast()
+			p.mu.Unlock()
+		})
+		defer stop()
+	}
+	for {
+		if atomic.LoadInt32(&p.running) == 0 {
+			return ErrPoolClosed
+		}
+		if ctx != nil && ctx.Err() != nil {
+			return ErrTaskCanceled
+		}
+		if len(p.queue) < p.queueSize {
+			break
+		}
+		p.notFull.Wait()
+	}
+
+	p.seq++
+	item.seq = p.seq
+	heap.Push(&p.queue, item)
+	atomic.AddInt32(&p.taskCount, 1)
+	p.notEmpty.Signal()
+	return nil
+}
+
+// Shutdown 关闭协程池，停止接受新任务，等待队列中的任务（包括等待重试的任务）全部执行完毕
 func (p *GoroutinePool) Shutdown() {
-	// 如果已经关闭，直接返回
+	// 如果已经关闭，直接返回；持有 mu 保证不会与 Resize 同时启动新的工作协程
+	p.mu.Lock()
 	if atomic.SwapInt32(&p.running, 0) == 0 {
+		p.mu.Unlock()
 		return
 	}
+	// 唤醒空闲的工作协程和等待空位的提交者
+	p.notEmpty.Broadcast()
+	p.notFull.Broadcast()
+	p.mu.Unlock()
 
-	// 发送取消信号
-	p.cancel()
-
-	// 关闭任务队列
-	close(p.taskQueue)
-
-	// 等待所有工作协程退出
+	// 等待所有工作协程清空队列后退出
 	p.wg.Wait()
 }
 
 // Stats 返回协程池统计信息
 func (p *GoroutinePool) Stats() map[string]interface{} {
 	return map[string]interface{}{
-		"workers":      p.workers,
-		"running":      atomic.LoadInt32(&p.running) == 1,
-		"taskCount":    atomic.LoadInt32(&p.taskCount),
-		"errorCount":   atomic.LoadInt32(&p.errorCount),
-		"successCount": atomic.LoadInt32(&p.successCount),
-		"pendingTasks": len(p.taskQueue),
+		"workers":       p.Workers(),
+		"activeWorkers": atomic.LoadInt32(&p.activeCount),
+		"running":       atomic.LoadInt32(&p.running) == 1,
+		"taskCount":     atomic.LoadInt32(&p.taskCount),
+		"errorCount":    atomic.LoadInt32(&p.errorCount),
+		"successCount":  atomic.LoadInt32(&p.successCount),
+		"canceledCount": atomic.LoadInt32(&p.canceledCount),
+		"timeoutCount":  atomic.LoadInt32(&p.timeoutCount),
+		"panicCount":    atomic.LoadInt32(&p.panicCount),
+		"retryCount":    atomic.LoadInt32(&p.retryCount),
+		"pendingTasks":  p.Pending(),
 	}
 }
 
@@ -165,50 +380,43 @@ func GoroutinePoolDemo() {
 	// 模拟50个并发请求
 	requestCount := 50
 
-	// 创建一个通道用于收集任务执行结果
-	results := make(chan string, requestCount)
-
-	// 提交请求处理任务
+	// 提交请求处理任务，每个请求的结果由对应的 Future 携带
+	results := make([]*Future[string], requestCount)
 	for i := 0; i < requestCount; i++ {
 		requestID := i
 
-		// 创建并提交任务
-		err := pool.Submit(func() error {
+		results[i] = SubmitFunc(pool, func() (string, error) {
 			// 模拟请求处理
 			processingTime := time.Duration(50+(requestID%100)) * time.Millisecond
 			time.Sleep(processingTime)
 
 			// 模拟一些随机失败（每10个请求中有1个失败）
 			if requestID%10 == 0 {
-				results <- fmt.Sprintf("请求-%d: 失败 (处理时间: %v)", requestID, processingTime)
-				return fmt.Errorf("请求-%d 处理失败", requestID)
+				return fmt.Sprintf("请求-%d: 失败 (处理时间: %v)", requestID, processingTime), fmt.Errorf("请求-%d 处理失败", requestID)
 			}
 
 			// 请求成功
-			results <- fmt.Sprintf("请求-%d: 成功 (处理时间: %v)", requestID, processingTime)
-			return nil
+			return fmt.Sprintf("请求-%d: 成功 (处理时间: %v)", requestID, processingTime), nil
 		})
-
-		if err != nil {
-			fmt.Printf("提交任务失败: %v\n", err)
-		}
 	}
 
-	// 收集前10个结果并显示
+	// 按提交顺序显示前10个请求的结果
 	fmt.Println("\n前10个请求处理结果:")
-	for i := 0; i < 10; i++ {
-		select {
-		case result := <-results:
-			fmt.Println(result)
-		case <-time.After(time.Second):
+	for _, future := range results[:10] {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		result, err := future.Get(ctx)
+		cancel()
+		if errors.Is(err, context.DeadlineExceeded) {
 			fmt.Println("等待处理结果超时")
+			continue
 		}
+		fmt.Println(result)
 	}
 
 	// 等待所有请求处理完成
 	fmt.Println("\n等待剩余请求处理完成...")
-	for i := 0; i < requestCount-10; i++ {
-		<-results
+	for _, future := range results[10:] {
+		future.Get(context.Background())
 	}
 
 	// 显示池统计信息
@@ -218,8 +426,57 @@ func GoroutinePoolDemo() {
 	fmt.Printf("提交任务总数: %d\n", stats["taskCount"])
 	fmt.Printf("成功任务数: %d\n", stats["successCount"])
 	fmt.Printf("失败任务数: %d\n", stats["errorCount"])
+	fmt.Printf("超时任务数: %d\n", stats["timeoutCount"])
 
 	// 关闭池
 	pool.Shutdown()
 	fmt.Println("\n协程池已关闭")
+
+	// 优先级调度：唯一的工作协程忙碌时，后提交的高优先级请求插到批量任务前面
+	fmt.Println("\n优先级调度（1个工作协程）:")
+	priorityPool := NewGoroutinePool(1, 20)
+	var mu sync.Mutex
+	var order []string
+	record := func(name string) GoroutineTask {
+		return func(context.Context) error {
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			return nil
+		}
+	}
+	priorityPool.Submit(record("正在处理的请求")) // 占住工作协程，后面的任务都在队列中排序
+	time.Sleep(time.Millisecond)
+	for i := 1; i <= 3; i++ {
+		priorityPool.SubmitWithPriority(record(fmt.Sprintf("报表导出-%d", i)), PriorityLow)
+	}
+	priorityPool.Submit(record("普通请求-1"))
+	priorityPool.SubmitWithPriority(record("支付回调"), PriorityHigh)
+	priorityPool.Submit(record("普通请求-2"))
+	priorityPool.SubmitWithPriority(record("风控告警"), PriorityHigh)
+	priorityPool.Shutdown() // 等待队列中的任务全部执行完毕
+	fmt.Printf("执行顺序: %v\n", order)
+
+	// 任务超时：查询按上下文提前返回，超时的任务单独计数
+	fmt.Println("\n任务超时（每个查询限时50ms）:")
+	timeoutPool := NewGoroutinePool(2, 10)
+	query := func(name string, cost time.Duration) GoroutineTask {
+		return func(ctx context.Context) error {
+			select {
+			case <-time.After(cost):
+				fmt.Printf("%s: 完成，耗时 %v\n", name, cost)
+				return nil
+			case <-ctx.Done():
+				fmt.Printf("%s: 放弃 (%v)\n", name, ctx.Err())
+				return ctx.Err()
+			}
+		}
+	}
+	timeoutPool.SubmitWithTimeout(query("订单查询", 20*time.Millisecond), 50*time.Millisecond)
+	timeoutPool.SubmitWithTimeout(query("报表查询", 200*time.Millisecond), 50*time.Millisecond)
+	timeoutPool.SubmitWithTimeout(query("用户查询", 30*time.Millisecond), 50*time.Millisecond)
+	timeoutPool.Shutdown()
+	stats = timeoutPool.Stats()
+	fmt.Printf("成功 %d，超时 %d，失败 %d\n", stats["successCount"], stats["timeoutCount"], stats["errorCount"])
 }
@@ -0,0 +1,222 @@
+package concurrency
+
+/*
+任务组（Group）- 结构化的并发错误处理
+
+原理：
+一个请求经常需要并发执行一组相互独立的子任务（同时调用多个下游服务、并行读取多个分片），
+只要其中一个失败整个请求就失败。直接用 sync.WaitGroup 时需要自己收集错误、
+自己通知其他子任务停止，还要自己限制并发数，每个调用点都要重复这套代码。
+Group 把这三件事打包：Go 启动子任务，Wait 等待全部结束并返回第一个错误，
+第一个错误出现时取消组的上下文，其余子任务通过上下文得知应当尽快退出。
+
+关键特点：
+1. 零值可直接使用（此时没有关联的上下文，出错时不会取消任何东西）
+2. NewGroup 基于父上下文创建组，返回的上下文在第一个子任务出错或 Wait 返回时取消，
+   context.Cause 可以取到导致取消的那个错误
+3. SetLimit 限制同时运行的子任务数，达到上限时 Go 阻塞，TryGo 立即返回 false
+4. 只保留第一个错误，后续错误通常是被取消引起的连锁失败，没有参考价值
+
+实现方式：
+- sync.WaitGroup 等待子任务结束，sync.Once 记录第一个错误并取消上下文
+- 并发上限用带缓冲的通道作为令牌，子任务开始前放入、结束后取出
+
+应用场景：
+- 聚合多个下游服务的结果，任一失败则整体失败
+- 并行处理一批文件、分片，出错时尽快停止剩余工作
+- 需要限制并发度的批量请求
+
+优缺点：
+- 优点：调用代码简洁，错误传播和取消语义统一，不会泄漏协程
+- 缺点：子任务必须自己检查上下文才能被提前终止；只返回第一个错误，需要全部错误时要自行收集
+
+以下实现了任务组，以及商品详情页并发聚合多个下游服务的示例。
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Group 一组并发执行的子任务，返回第一个错误；零值可直接使用
+type Group struct {
+	cancel  context.CancelCauseFunc // 取消组的上下文，零值的组为 nil
+	wg      sync.WaitGroup
+	sem     chan struct{} // 并发上限的令牌，为 nil 时不限制
+	errOnce sync.Once
+	err     error
+	started int32 // 已启动的子任务数
+	failed  int32 // 返回错误的子任务数
+}
+
+// NewGroup 创建与上下文关联的任务组，返回的上下文在第一个子任务出错或 Wait 返回时取消
+func NewGroup(ctx context.Context) (*Group, context.Context) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	return &Group{cancel: cancel}, ctx
+}
+
+// SetLimit 限制同时运行的子任务数，n 小于等于0表示不限制；必须在启动子任务之前调用
+func (g *Group) SetLimit(n int) {
+	if n <= 0 {
+		g.sem = nil
+		return
+	}
+	if len(g.sem) != 0 {
+		panic(fmt.Sprintf("任务组仍有 %d 个子任务在运行时不能修改并发上限", len(g.sem)))
+	}
+	g.sem = make(chan struct{}, n)
+}
+
+// Go 在新的协程中执行 fn，达到并发上限时阻塞到有子任务结束
+func (g *Group) Go(fn func() error) {
+	if g.sem != nil {
+		g.sem <- struct{}{}
+	}
+	g.start(fn)
+}
+
+// TryGo 在未达到并发上限时启动 fn 并返回 true，否则不启动并返回 false
+func (g *Group) TryGo(fn func() error) bool {
+	if g.sem != nil {
+		select {
+		case g.sem <- struct{}{}:
+		default:
+			return false
+		}
+	}
+	g.start(fn)
+	return true
+}
+
+// start 启动已拿到令牌的子任务
+func (g *Group) start(fn func() error) {
+	atomic.AddInt32(&g.started, 1)
+	g.wg.Add(1)
+	go func() {
+		defer g.done()
+		if err := fn(); err != nil {
+			atomic.AddInt32(&g.failed, 1)
+			g.errOnce.Do(func() {
+				g.err = err
+				if g.cancel != nil {
+					g.cancel(err)
+				}
+			})
+		}
+	}()
+}
+
+// done 归还令牌并标记子任务结束
+func (g *Group) done() {
+	if g.sem != nil {
+		<-g.sem
+	}
+	g.wg.Done()
+}
+
+// Wait 等待所有子任务结束，返回第一个错误，并取消组的上下文
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	if g.cancel != nil {
+		g.cancel(g.err)
+	}
+	return g.err
+}
+
+// Stats 返回任务组的统计信息
+func (g *Group) Stats() map[string]interface{} {
+	return map[string]interface{}{
+		"started": atomic.LoadInt32(&g.started),
+		"failed":  atomic.LoadInt32(&g.failed),
+		"running": len(g.sem),
+		"limit":   cap(g.sem),
+	}
+}
+
+// 场景示例：商品详情页并发调用多个下游服务，任一失败则整体失败并取消其余调用
+func GroupDemo() {
+	fmt.Println("任务组示例 (商品详情页聚合):")
+
+	errInventory := errors.New("库存服务不可用")
+	type service struct {
+		name    string
+		latency time.Duration
+		err     error
+	}
+	// call 模拟一次下游调用，上下文取消时提前返回
+	call := func(ctx context.Context, s service) (string, error) {
+		select {
+		case <-time.After(s.latency):
+			if s.err != nil {
+				return "", s.err
+			}
+			return s.name + "数据", nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+
+	render := func(title string, services []service, limit int) {
+		group, ctx := NewGroup(context.Background())
+		group.SetLimit(limit)
+		results := make([]string, len(services))
+		var canceled int32
+
+		start := time.Now()
+		for i, s := range services {
+			group.Go(func() error {
+				data, err := call(ctx, s)
+				if errors.Is(err, context.Canceled) {
+					atomic.AddInt32(&canceled, 1)
+				}
+				results[i] = data
+				return err
+			})
+		}
+		err := group.Wait()
+		elapsed := time.Since(start).Round(10 * time.Millisecond)
+
+		stats := group.Stats()
+		if limit > 0 {
+			fmt.Printf("\n%s (并发上限 %d):\n", title, limit)
+		} else {
+			fmt.Printf("\n%s (不限并发):\n", title)
+		}
+		if err != nil {
+			fmt.Printf("  页面渲染失败: %v，耗时 %v\n", err, elapsed)
+			fmt.Printf("  启动 %d 个调用，%d 个失败，其中 %d 个因取消而提前返回\n",
+				stats["started"], stats["failed"], atomic.LoadInt32(&canceled))
+			fmt.Printf("  上下文取消原因: %v\n", context.Cause(ctx))
+			return
+		}
+		fmt.Printf("  页面渲染成功: %v，耗时 %v\n", results, elapsed)
+	}
+
+	services := []service{
+		{"商品", 50 * time.Millisecond, nil},
+		{"价格", 30 * time.Millisecond, nil},
+		{"库存", 40 * time.Millisecond, nil},
+		{"评论", 80 * time.Millisecond, nil},
+		{"推荐", 100 * time.Millisecond, nil},
+	}
+	render("所有服务正常", services, 0)
+	render("所有服务正常", services, 2)
+
+	services[2].err = errInventory
+	render("库存服务故障", services, 2)
+
+	// TryGo：达到上限时不排队，直接降级
+	group, _ := NewGroup(context.Background())
+	group.SetLimit(1)
+	group.Go(func() error { time.Sleep(20 * time.Millisecond); return nil })
+	accepted := group.TryGo(func() error { return nil })
+	group.Wait()
+	fmt.Printf("\n并发上限为1且已有任务在运行时 TryGo 接受新任务: %v\n", accepted)
+}
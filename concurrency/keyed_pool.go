@@ -0,0 +1,225 @@
+package concurrency
+
+/*
+按键保序的协程池
+
+原理：
+普通协程池中任意空闲的工作协程都可以领取任务，同一个用户、同一个会话的两个任务可能被两个协程同时执行，
+执行顺序与提交顺序不再一致。对于聊天消息、账户流水、数据复制这类"同一个键内必须按顺序处理"的场景，
+这会导致消息乱序、余额计算错误或旧数据覆盖新数据。
+按键保序的协程池把任务的键哈希到固定的工作协程，每个工作协程有自己的先进先出队列：
+同一个键的任务总是由同一个协程串行执行，不同的键分散到不同协程并行执行。
+
+关键特点：
+1. 同一个键的任务按提交顺序串行执行，不需要业务代码加锁
+2. 不同键的任务并行执行，并行度等于工作协程数量
+3. 每个工作协程有独立的有界队列，队列满时只阻塞提交到该协程的任务
+4. 关闭时先处理完所有已提交的任务再退出
+
+实现方式：
+- 使用 FNV-1a 哈希把键映射到工作协程
+- 每个工作协程一个带缓冲的通道作为队列
+- 读写锁保护关闭状态，避免向已关闭的通道发送任务
+
+应用场景：
+- 聊天系统中同一会话的消息按序投递
+- 数据复制、Outbox 模式中同一实体的变更按序应用
+- 同一账户的交易流水按序记账
+
+优缺点：
+- 优点：用分区代替加锁，同一个键天然无竞争，实现简单
+- 缺点：热点键会让对应的工作协程成为瓶颈，其他协程空闲也无法帮忙；一个慢任务会阻塞同一协程上其他键的任务
+
+以下实现了按键保序的协程池，并与普通协程池的执行顺序进行对比。
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrPoolClosed 协程池已关闭
+var ErrPoolClosed = errors.New("协程池已关闭")
+
+// keyedWorker 按键保序协程池中的工作协程
+type keyedWorker struct {
+	tasks     chan GoroutineTask // 该工作协程的任务队列
+	processed int64              // 已执行的任务数
+}
+
+// KeyedPool 按键保序的协程池
+type KeyedPool struct {
+	workers      []*keyedWorker
+	wg           sync.WaitGroup
+	mu           sync.RWMutex // 保护 closed，提交持读锁，关闭持写锁
+	closed       bool
+	taskCount    int64 // 已提交任务数
+	successCount int64 // 成功任务数
+	errorCount   int64 // 失败任务数
+}
+
+// NewKeyedPool 创建按键保序的协程池，queueSize 为每个工作协程的队列容量
+func NewKeyedPool(workers int, queueSize int) *KeyedPool {
+	if workers <= 0 {
+		workers = 1
+	}
+	if queueSize <= 0 {
+		queueSize = 100
+	}
+
+	pool := &KeyedPool{workers: make([]*keyedWorker, workers)}
+	pool.wg.Add(workers)
+	for i := range pool.workers {
+		pool.workers[i] = &keyedWorker{tasks: make(chan GoroutineTask, queueSize)}
+		go pool.run(pool.workers[i])
+	}
+	return pool
+}
+
+// run 工作协程主循环，任务队列关闭且处理完毕后退出
+func (p *KeyedPool) run(w *keyedWorker) {
+	defer p.wg.Done()
+
+	for task := range w.tasks {
+		if err := task(context.Background()); err != nil {
+			atomic.AddInt64(&p.errorCount, 1)
+		} else {
+			atomic.AddInt64(&p.successCount, 1)
+		}
+		atomic.AddInt64(&w.processed, 1)
+	}
+}
+
+// WorkerFor 返回键对应的工作协程编号
+func (p *KeyedPool) WorkerFor(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(p.workers)))
+}
+
+// Submit 提交任务，同一个键的任务按提交顺序串行执行；对应工作协程的队列已满时阻塞
+func (p *KeyedPool) Submit(key string, task GoroutineTask) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		return ErrPoolClosed
+	}
+	p.workers[p.WorkerFor(key)].tasks <- task
+	atomic.AddInt64(&p.taskCount, 1)
+	return nil
+}
+
+// Shutdown 停止接受新任务，等待已提交的任务全部执行完毕
+func (p *KeyedPool) Shutdown() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	for _, w := range p.workers {
+		close(w.tasks)
+	}
+	p.mu.Unlock()
+
+	p.wg.Wait()
+}
+
+// Stats 返回协程池统计信息
+func (p *KeyedPool) Stats() map[string]interface{} {
+	pending := make([]int, len(p.workers))
+	processed := make([]int64, len(p.workers))
+	for i, w := range p.workers {
+		pending[i] = len(w.tasks)
+		processed[i] = atomic.LoadInt64(&w.processed)
+	}
+
+	p.mu.RLock()
+	closed := p.closed
+	p.mu.RUnlock()
+
+	return map[string]interface{}{
+		"workers":      len(p.workers),
+		"running":      !closed,
+		"taskCount":    atomic.LoadInt64(&p.taskCount),
+		"successCount": atomic.LoadInt64(&p.successCount),
+		"errorCount":   atomic.LoadInt64(&p.errorCount),
+		"pendingTasks": pending,
+		"processed":    processed,
+	}
+}
+
+// 场景示例：聊天消息按会话保序投递
+func KeyedPoolDemo() {
+	fmt.Println("按键保序协程池示例（聊天消息投递）:")
+
+	conversations := []string{"会话-张三", "会话-李四", "会话-王五", "会话-赵六"}
+	const messagesPerConversation = 20
+
+	// deliver 投递消息并检查顺序，返回乱序次数
+	deliver := func(submit func(key string, task GoroutineTask) error, wait func()) int64 {
+		var mu sync.Mutex
+		last := make(map[string]int)
+		var outOfOrder int64
+
+		for seq := 1; seq <= messagesPerConversation; seq++ {
+			for _, conv := range conversations {
+				conv, seq := conv, seq
+				submit(conv, func(context.Context) error {
+					// 模拟投递耗时抖动
+					time.Sleep(time.Duration(rand.Intn(3)) * time.Millisecond)
+
+					mu.Lock()
+					defer mu.Unlock()
+					if seq < last[conv] {
+						outOfOrder++
+					} else {
+						last[conv] = seq
+					}
+					return nil
+				})
+			}
+		}
+		wait()
+		return outOfOrder
+	}
+
+	// 1. 普通协程池：同一会话的消息可能被不同协程并发投递
+	pool := NewGoroutinePool(4, 100)
+	var wg sync.WaitGroup
+	outOfOrder := deliver(func(key string, task GoroutineTask) error {
+		wg.Add(1)
+		return pool.Submit(func(ctx context.Context) error {
+			defer wg.Done()
+			return task(ctx)
+		})
+	}, wg.Wait)
+	pool.Shutdown()
+	fmt.Printf("\n普通协程池（4个协程）: %d 个会话共 %d 条消息，乱序 %d 次\n",
+		len(conversations), len(conversations)*messagesPerConversation, outOfOrder)
+
+	// 2. 按键保序协程池：同一会话的消息总是由同一个协程按序投递
+	keyed := NewKeyedPool(4, 100)
+	fmt.Println("\n按键保序协程池（4个协程）:")
+	for _, conv := range conversations {
+		fmt.Printf("  %s -> 工作协程 %d\n", conv, keyed.WorkerFor(conv))
+	}
+	start := time.Now()
+	outOfOrder = deliver(keyed.Submit, keyed.Shutdown)
+	fmt.Printf("  乱序 %d 次，耗时 %v\n", outOfOrder, time.Since(start).Round(time.Millisecond))
+
+	stats := keyed.Stats()
+	fmt.Printf("  各工作协程处理的任务数: %v（哈希分布不均时部分协程空闲）\n", stats["processed"])
+	fmt.Printf("  成功 %d，失败 %d\n", stats["successCount"], stats["errorCount"])
+
+	if err := keyed.Submit("会话-张三", func(context.Context) error { return nil }); err != nil {
+		fmt.Printf("  关闭后提交: %v\n", err)
+	}
+}
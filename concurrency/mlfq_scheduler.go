@@ -0,0 +1,469 @@
+package concurrency
+
+/*
+多级反馈队列调度（MLFQ）
+
+原理：
+协程池按提交顺序执行任务，本质上是先来先服务（FIFO）：一个长任务会让后面的短任务一直等待。
+操作系统的CPU调度面临同样的问题，并且事先不知道每个任务要运行多久。
+多级反馈队列根据任务过去的行为推测它的性质：新任务先放在最高优先级，
+用完本层的时间配额就降一级，频繁因I/O让出CPU的交互式任务因此留在高优先级，响应迅速；
+CPU密集的批处理任务逐渐沉到低优先级，用更长的时间片运行。
+为了防止低优先级任务被源源不断的高优先级任务饿死，等待过久的任务会被提升（老化）。
+
+关键特点：
+1. 高优先级队列非空时总是先运行高优先级任务，并抢占正在运行的低优先级任务
+2. 同一优先级内轮转调度，越低的层级时间片越长
+3. 每层有独立的时间配额，跨CPU突发累计，任务无法通过"时间片用完前主动让出"一直占据高优先级
+4. 老化：在就绪队列中等待超过阈值的任务提升一级
+
+实现方式：
+- 以离散时间单位模拟单个CPU，任务由若干CPU突发组成，突发之间进行固定时长的I/O
+- 调度策略实现 CPUScheduler 接口：FIFO、轮转（RR）、MLFQ
+- 模拟器统计周转时间、响应时间、最长等待和上下文切换次数，并用对比报告输出
+
+应用场景：
+- 理解操作系统调度器（Solaris、Windows、早期BSD都采用了MLFQ的变体）
+- 设计任务队列的优先级与公平性策略
+- 评估协程池等任务调度方案对长短任务混合负载的影响
+
+优缺点：
+- 优点：无需预知任务运行时间，兼顾交互式任务的响应时间和批处理任务的吞吐量
+- 缺点：参数（层数、时间片、老化阈值）较多且相互影响；频繁抢占带来更多上下文切换
+
+以下实现了调度模拟器以及 FIFO、RR、MLFQ 三种调度策略。
+*/
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/strive/scenario/report"
+)
+
+// SchedJob 待调度任务的描述
+type SchedJob struct {
+	ID      string // 任务标识，甘特图中使用首字符表示
+	Arrival int    // 到达时间
+	Bursts  []int  // 各CPU突发的长度
+	IOTime  int    // 两次CPU突发之间的I/O时长
+}
+
+// Interactive 是否为交互式任务（多次CPU突发，中间穿插I/O）
+func (j SchedJob) Interactive() bool {
+	return len(j.Bursts) > 1
+}
+
+// SchedTask 模拟运行中的任务状态
+type SchedTask struct {
+	Job        SchedJob
+	burst      int // 当前CPU突发的序号
+	remaining  int // 当前CPU突发剩余的时间
+	firstRun   int // 第一次运行的时间，-1 表示尚未运行
+	finish     int // 完成时间
+	readySince int // 进入就绪队列的时间
+
+	level      int // MLFQ：当前优先级层级，0 为最高
+	levelUsed  int // MLFQ：在当前层级已用的时间配额
+	enqueuedAt int // MLFQ：进入当前层级队列的时间，用于老化
+}
+
+// CPUScheduler 调度策略
+type CPUScheduler interface {
+	// Name 返回策略名称
+	Name() string
+	// Ready 任务进入就绪状态（到达或I/O完成）
+	Ready(task *SchedTask, now int)
+	// Next 选出下一个运行的任务及其时间片，时间片<=0表示运行到当前CPU突发结束；没有就绪任务时返回nil
+	Next(now int) (*SchedTask, int)
+	// Stop 任务离开CPU：ran 为本次运行的时间，blocked 表示任务因I/O或完成而让出，否则需要重新排队
+	Stop(task *SchedTask, ran int, blocked bool, now int)
+	// ShouldPreempt 是否应抢占正在运行的任务
+	ShouldPreempt(running *SchedTask, now int) bool
+}
+
+// FIFOScheduler 先来先服务
+type FIFOScheduler struct {
+	queue []*SchedTask
+}
+
+// NewFIFOScheduler 创建先来先服务调度器
+func NewFIFOScheduler() *FIFOScheduler {
+	return &FIFOScheduler{}
+}
+
+// Name 返回策略名称
+func (s *FIFOScheduler) Name() string { return "FIFO" }
+
+// Ready 加入队尾
+func (s *FIFOScheduler) Ready(task *SchedTask, now int) {
+	s.queue = append(s.queue, task)
+}
+
+// Next 取出队首任务，运行到CPU突发结束
+func (s *FIFOScheduler) Next(now int) (*SchedTask, int) {
+	if len(s.queue) == 0 {
+		return nil, 0
+	}
+	task := s.queue[0]
+	s.queue = s.queue[1:]
+	return task, 0
+}
+
+// Stop 被抢占的任务重新排到队尾（FIFO不会主动抢占）
+func (s *FIFOScheduler) Stop(task *SchedTask, ran int, blocked bool, now int) {
+	if !blocked {
+		s.queue = append(s.queue, task)
+	}
+}
+
+// ShouldPreempt 从不抢占
+func (s *FIFOScheduler) ShouldPreempt(running *SchedTask, now int) bool { return false }
+
+// RoundRobinScheduler 时间片轮转
+type RoundRobinScheduler struct {
+	FIFOScheduler
+	quantum int // 时间片
+}
+
+// NewRoundRobinScheduler 创建时间片轮转调度器
+func NewRoundRobinScheduler(quantum int) *RoundRobinScheduler {
+	if quantum <= 0 {
+		quantum = 1
+	}
+	return &RoundRobinScheduler{quantum: quantum}
+}
+
+// Name 返回策略名称
+func (s *RoundRobinScheduler) Name() string { return fmt.Sprintf("RR(q=%d)", s.quantum) }
+
+// Next 取出队首任务，最多运行一个时间片
+func (s *RoundRobinScheduler) Next(now int) (*SchedTask, int) {
+	task, _ := s.FIFOScheduler.Next(now)
+	return task, s.quantum
+}
+
+// MLFQOptions 多级反馈队列配置
+type MLFQOptions struct {
+	Quantums       []int // 各层级的时间片，层数等于长度
+	Allotments     []int // 各层级的时间配额，用完后降级；缺省或为0表示不降级
+	AgingThreshold int   // 等待超过该时间的任务提升一级，0表示不老化
+}
+
+// DefaultMLFQOptions 默认多级反馈队列配置
+var DefaultMLFQOptions = MLFQOptions{
+	Quantums:       []int{2, 4, 8},
+	Allotments:     []int{16, 32},
+	AgingThreshold: 30,
+}
+
+// MLFQScheduler 多级反馈队列调度器
+type MLFQScheduler struct {
+	options MLFQOptions
+	queues  [][]*SchedTask // 各层级的就绪队列
+	aged    int            // 老化提升的次数
+}
+
+// NewMLFQScheduler 创建多级反馈队列调度器
+func NewMLFQScheduler(options MLFQOptions) *MLFQScheduler {
+	if len(options.Quantums) == 0 {
+		options.Quantums = DefaultMLFQOptions.Quantums
+		options.Allotments = DefaultMLFQOptions.Allotments
+	}
+	return &MLFQScheduler{options: options, queues: make([][]*SchedTask, len(options.Quantums))}
+}
+
+// Name 返回策略名称
+func (s *MLFQScheduler) Name() string {
+	if s.options.AgingThreshold <= 0 {
+		return "MLFQ(无老化)"
+	}
+	return "MLFQ"
+}
+
+// Aged 返回老化提升的次数
+func (s *MLFQScheduler) Aged() int {
+	return s.aged
+}
+
+// push 加入任务当前层级的队尾
+func (s *MLFQScheduler) push(task *SchedTask, now int) {
+	task.enqueuedAt = now
+	s.queues[task.level] = append(s.queues[task.level], task)
+}
+
+// Ready 新任务进入最高层级，I/O完成的任务回到原层级
+func (s *MLFQScheduler) Ready(task *SchedTask, now int) {
+	s.push(task, now)
+}
+
+// age 把等待过久的任务提升一级
+func (s *MLFQScheduler) age(now int) {
+	if s.options.AgingThreshold <= 0 {
+		return
+	}
+	for level := 1; level < len(s.queues); level++ {
+		kept := s.queues[level][:0]
+		for _, task := range s.queues[level] {
+			if now-task.enqueuedAt < s.options.AgingThreshold {
+				kept = append(kept, task)
+				continue
+			}
+			task.level, task.levelUsed = level-1, 0
+			s.push(task, now)
+			s.aged++
+		}
+		s.queues[level] = kept
+	}
+}
+
+// allotment 返回层级的时间配额，0表示不降级
+func (s *MLFQScheduler) allotment(level int) int {
+	if level >= len(s.options.Allotments) || level == len(s.queues)-1 {
+		return 0
+	}
+	return s.options.Allotments[level]
+}
+
+// highest 返回最高的非空层级，全部为空时返回-1
+func (s *MLFQScheduler) highest() int {
+	for level, queue := range s.queues {
+		if len(queue) > 0 {
+			return level
+		}
+	}
+	return -1
+}
+
+// Next 从最高的非空层级取出任务，时间片不超过本层级剩余的配额
+func (s *MLFQScheduler) Next(now int) (*SchedTask, int) {
+	s.age(now)
+	level := s.highest()
+	if level < 0 {
+		return nil, 0
+	}
+	task := s.queues[level][0]
+	s.queues[level] = s.queues[level][1:]
+
+	slice := s.options.Quantums[level]
+	if allotment := s.allotment(level); allotment > 0 && allotment-task.levelUsed < slice {
+		slice = allotment - task.levelUsed
+	}
+	return task, slice
+}
+
+// Stop 累计时间配额，用完则降级，被抢占或时间片用完的任务重新排队
+func (s *MLFQScheduler) Stop(task *SchedTask, ran int, blocked bool, now int) {
+	task.levelUsed += ran
+	if allotment := s.allotment(task.level); allotment > 0 && task.levelUsed >= allotment {
+		task.level++
+		task.levelUsed = 0
+	}
+	if !blocked {
+		s.push(task, now)
+	}
+}
+
+// ShouldPreempt 有更高层级的任务就绪时抢占
+func (s *MLFQScheduler) ShouldPreempt(running *SchedTask, now int) bool {
+	s.age(now)
+	level := s.highest()
+	return level >= 0 && level < running.level
+}
+
+// ScheduleResult 一次调度模拟的结果
+type ScheduleResult struct {
+	Policy                   string  // 调度策略
+	Makespan                 int     // 全部任务完成的时间
+	AvgTurnaround            float64 // 平均周转时间（完成 − 到达）
+	AvgResponse              float64 // 平均响应时间（首次运行 − 到达）
+	AvgInteractiveTurnaround float64 // 交互式任务的平均周转时间
+	MaxWait                  int     // 单次在就绪队列中等待的最长时间，反映饥饿程度
+	ContextSwitches          int     // 切换到另一个任务的次数
+	Timeline                 string  // 每个时间单位运行的任务，"." 表示空闲
+}
+
+// SimulateScheduling 在单个CPU上模拟调度一组任务
+func SimulateScheduling(scheduler CPUScheduler, jobs []SchedJob) *ScheduleResult {
+	tasks := make([]*SchedTask, len(jobs))
+	for i, job := range jobs {
+		tasks[i] = &SchedTask{Job: job, remaining: job.Bursts[0], firstRun: -1}
+	}
+	ioUntil := make(map[*SchedTask]int)
+
+	result := &ScheduleResult{Policy: scheduler.Name()}
+	var timeline strings.Builder
+	var running, previous *SchedTask
+	slice, ran, finished := 0, 0, 0
+
+	for now := 0; finished < len(tasks); now++ {
+		// 到达和I/O完成的任务进入就绪状态
+		for _, task := range tasks {
+			until, waiting := ioUntil[task]
+			if task.Job.Arrival == now || (waiting && until == now) {
+				delete(ioUntil, task)
+				task.readySince = now
+				scheduler.Ready(task, now)
+			}
+		}
+
+		if running != nil && scheduler.ShouldPreempt(running, now) {
+			scheduler.Stop(running, ran, false, now)
+			running.readySince = now
+			running = nil
+		}
+
+		if running == nil {
+			running, slice = scheduler.Next(now)
+			ran = 0
+			if running != nil {
+				if running != previous {
+					result.ContextSwitches++
+				}
+				if running.firstRun < 0 {
+					running.firstRun = now
+				}
+				if wait := now - running.readySince; wait > result.MaxWait {
+					result.MaxWait = wait
+				}
+			}
+		}
+
+		if running == nil {
+			timeline.WriteByte('.')
+			continue
+		}
+
+		// 运行一个时间单位
+		timeline.WriteByte(running.Job.ID[0])
+		running.remaining--
+		ran++
+		previous = running
+
+		switch {
+		case running.remaining == 0:
+			running.burst++
+			if running.burst < len(running.Job.Bursts) {
+				running.remaining = running.Job.Bursts[running.burst]
+				ioUntil[running] = now + 1 + running.Job.IOTime
+			} else {
+				running.finish = now + 1
+				finished++
+			}
+			scheduler.Stop(running, ran, true, now+1)
+			running = nil
+		case slice > 0 && ran >= slice:
+			scheduler.Stop(running, ran, false, now+1)
+			running.readySince = now + 1
+			running = nil
+		}
+	}
+
+	interactive := 0
+	for _, task := range tasks {
+		turnaround := float64(task.finish - task.Job.Arrival)
+		result.AvgTurnaround += turnaround
+		result.AvgResponse += float64(task.firstRun - task.Job.Arrival)
+		if task.Job.Interactive() {
+			result.AvgInteractiveTurnaround += turnaround
+			interactive++
+		}
+		if task.finish > result.Makespan {
+			result.Makespan = task.finish
+		}
+	}
+	if len(tasks) > 0 {
+		result.AvgTurnaround /= float64(len(tasks))
+		result.AvgResponse /= float64(len(tasks))
+	}
+	if interactive > 0 {
+		result.AvgInteractiveTurnaround /= float64(interactive)
+	}
+	result.Timeline = timeline.String()
+	return result
+}
+
+// compareSchedulers 在同一组任务上运行多种调度策略，打印甘特图并返回对比报告
+func compareSchedulers(title, input string, jobs []SchedJob, schedulers ...CPUScheduler) *report.Comparison {
+	comparison := report.NewComparison(title, input,
+		report.Metric{Name: "平均周转时间", LowerIsBetter: true, Precision: 1},
+		report.Metric{Name: "平均响应时间", LowerIsBetter: true, Precision: 1},
+		report.Metric{Name: "交互式平均周转", LowerIsBetter: true, Precision: 1},
+		report.Metric{Name: "最长等待", LowerIsBetter: true},
+		report.Metric{Name: "上下文切换", LowerIsBetter: true},
+	)
+
+	fmt.Println("甘特图（前80个时间单位）:")
+	for _, scheduler := range schedulers {
+		result := SimulateScheduling(scheduler, jobs)
+		timeline := result.Timeline
+		if len(timeline) > 80 {
+			timeline = timeline[:80] + "..."
+		}
+		fmt.Printf("  %-10s %s\n", result.Policy, timeline)
+
+		values := map[string]float64{
+			"平均周转时间": result.AvgTurnaround,
+			"平均响应时间": result.AvgResponse,
+			"最长等待":   float64(result.MaxWait),
+			"上下文切换":  float64(result.ContextSwitches),
+		}
+		if result.AvgInteractiveTurnaround > 0 {
+			values["交互式平均周转"] = result.AvgInteractiveTurnaround
+		}
+		row := comparison.Add(result.Policy, values)
+		if mlfq, ok := scheduler.(*MLFQScheduler); ok && mlfq.Aged() > 0 {
+			row.Note = fmt.Sprintf("老化提升 %d 次", mlfq.Aged())
+		}
+	}
+	fmt.Println()
+	return comparison
+}
+
+// 场景示例：批处理任务与交互式任务混合负载下的调度策略对比
+func MLFQSchedulerDemo() {
+	fmt.Println("多级反馈队列调度示例:")
+
+	// 1. 混合负载：A、B 为CPU密集的批处理任务；C、D 为频繁I/O的交互式任务；E 为中途到达的短任务
+	interactive := []int{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1}
+	mixed := []SchedJob{
+		{ID: "A", Arrival: 0, Bursts: []int{40}},
+		{ID: "B", Arrival: 2, Bursts: []int{30}},
+		{ID: "C", Arrival: 1, Bursts: interactive, IOTime: 3},
+		{ID: "D", Arrival: 3, Bursts: interactive, IOTime: 3},
+		{ID: "E", Arrival: 10, Bursts: []int{5}},
+	}
+	fmt.Println("\n混合负载:")
+	for _, job := range mixed {
+		kind := "批处理"
+		if job.Interactive() {
+			kind = fmt.Sprintf("交互式，%d 次CPU突发，每次I/O %d", len(job.Bursts), job.IOTime)
+		}
+		fmt.Printf("  %s: 到达=%d, CPU总时间=%d, %s\n", job.ID, job.Arrival, sum(job.Bursts), kind)
+	}
+	fmt.Println()
+	comparison := compareSchedulers("CPU调度策略对比", "2个批处理、2个交互式、1个短任务，单CPU", mixed,
+		NewFIFOScheduler(), NewRoundRobinScheduler(4), NewMLFQScheduler(DefaultMLFQOptions))
+	fmt.Print(comparison.Markdown())
+
+	// 2. 持续到达的短任务流让CPU一直忙碌，降到低优先级的长任务 L 只能依靠老化获得运行机会
+	stream := []SchedJob{{ID: "L", Arrival: 0, Bursts: []int{30}}}
+	for i := 0; i < 30; i++ {
+		stream = append(stream, SchedJob{ID: fmt.Sprintf("s%d", i), Arrival: 2 + i*4, Bursts: []int{4}})
+	}
+	noAging := DefaultMLFQOptions
+	noAging.AgingThreshold = 0
+	fmt.Println("\n短任务流（每4个时间单位到达一个长度为4的任务，共30个）与长任务 L:")
+	comparison = compareSchedulers("老化对饥饿的影响", "1个长任务 + 30个连续到达的短任务，单CPU", stream,
+		NewMLFQScheduler(DefaultMLFQOptions), NewMLFQScheduler(noAging))
+	fmt.Print(comparison.Markdown())
+}
+
+// sum 求和
+func sum(values []int) int {
+	total := 0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
@@ -0,0 +1,100 @@
+package concurrency
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/strive/scenario/report"
+)
+
+// DefaultMPMCBenchmarkWorkers 默认测量的生产者数量，消费者数量与生产者相同
+var DefaultMPMCBenchmarkWorkers = []int{1, 4, 16}
+
+// mpmcMetric 返回某个并发度的吞吐量指标名
+func mpmcMetric(workers int) string {
+	return fmt.Sprintf("%d生产%d消费(万次/秒)", workers, workers)
+}
+
+// measureMPMC 启动 workers 个生产者和 workers 个消费者传递 items 个元素，返回每秒传递的元素数（万）
+func measureMPMC(workers, items int, enqueue func(int), dequeue func() int) (float64, error) {
+	perWorker := items / workers
+	var wg sync.WaitGroup
+	sums := make([]int, workers)
+	start := time.Now()
+	for w := 0; w < workers; w++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				enqueue(i)
+			}
+		}()
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				sums[w] += dequeue()
+			}
+		}(w)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	total := 0
+	for _, sum := range sums {
+		total += sum
+	}
+	if expected := workers * perWorker * (perWorker - 1) / 2; total != expected {
+		return 0, fmt.Errorf("%d个生产者: 取出元素之和为 %d，应为 %d", workers, total, expected)
+	}
+	return float64(workers*perWorker) / elapsed.Seconds() / 1e4, nil
+}
+
+// MPMCBenchmark 对比互斥锁有界队列和无锁环形缓冲区在不同并发度下的吞吐量，容量均为 capacity
+func MPMCBenchmark(items, capacity int, workers []int) *report.Comparison {
+	metrics := make([]report.Metric, 0, len(workers))
+	for _, w := range workers {
+		metrics = append(metrics, report.Metric{Name: mpmcMetric(w), Precision: 0})
+	}
+	input := fmt.Sprintf("每种并发度传递 %d 个元素，容量 %d，GOMAXPROCS=%d", items, capacity, runtime.GOMAXPROCS(0))
+	comparison := report.NewComparison("有界队列与无锁环形缓冲区对比", input, metrics...)
+
+	comparison.Measure("BoundedQueue(互斥锁)", func() (map[string]float64, error) {
+		values := make(map[string]float64, len(workers))
+		for _, w := range workers {
+			queue := NewBoundedQueue(capacity)
+			rate, err := measureMPMC(w, items,
+				func(v int) { queue.Enqueue(v) },
+				func() int { item, _ := queue.Dequeue(); return item.(int) })
+			if err != nil {
+				return values, err
+			}
+			values[mpmcMetric(w)] = rate
+		}
+		return values, nil
+	})
+
+	comparison.Measure("MPMCRing(无锁)", func() (map[string]float64, error) {
+		values := make(map[string]float64, len(workers))
+		for _, w := range workers {
+			ring := NewMPMCRing[int](capacity)
+			rate, err := measureMPMC(w, items, ring.Enqueue, ring.Dequeue)
+			if err != nil {
+				return values, err
+			}
+			values[mpmcMetric(w)] = rate
+		}
+		return values, nil
+	})
+	return comparison
+}
+
+// 场景示例：日志采集缓冲区选型，比较两种队列在不同并发度下的吞吐量
+func MPMCBenchmarkDemo() {
+	fmt.Println("有界队列吞吐量基准:")
+	MPMCBenchmark(1000000, 1024, DefaultMPMCBenchmarkWorkers).Write(os.Stdout, report.FormatMarkdown)
+	fmt.Println("无锁版本省去了加锁、条件变量唤醒和 interface{} 装箱，元素处理越快、核数越多领先越明显；" +
+		"队列长期满或空时自旋让出会白白消耗CPU，这时阻塞队列更合适")
+}
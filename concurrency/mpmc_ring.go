@@ -0,0 +1,230 @@
+package concurrency
+
+/*
+无锁多生产者多消费者环形缓冲区（MPMC Ring）
+
+原理：
+BoundedQueue 用一把互斥锁保护整个队列，所有生产者和消费者排队进入同一个临界区，
+核数越多，花在争抢锁、挂起和唤醒协程上的时间占比越大。
+Dmitry Vyukov 的有界 MPMC 队列不用锁：每个槽位带一个序号，序号说明这个槽位当前处于哪一"轮"、
+是等待写入还是等待读取。生产者用 CAS 抢占写位置，抢到后独占对应的槽位写入数据，
+再把槽位序号加1发布给消费者；消费者同理抢占读位置，读完后把序号推进一整圈，把槽位还给下一轮的生产者。
+
+关键特点：
+1. 入队、出队各只需一次成功的 CAS，没有锁，不会因为持锁协程被调度走而让所有人一起等待
+2. 生产者之间、消费者之间各自竞争，生产者和消费者只在同一个槽位上通过序号交接
+3. 非阻塞：队列满时 TryEnqueue、队列空时 TryDequeue 立即返回 false，等待策略（自旋、让出、休眠）由调用方决定
+4. 容量向上取整为2的幂，位置对容量取模用位运算
+
+实现方式：
+- 槽位 i 初始序号为 i；写位置 pos 的槽位序号等于 pos 时可以写，写完设为 pos+1
+- 读位置 pos 的槽位序号等于 pos+1 时可以读，读完设为 pos+容量，即下一轮写位置
+- 序号小于期望值说明槽位还被上一轮占用（满或空），大于期望值说明位置已被其他协程抢走，重新读取位置
+- 读写位置之间填充一个缓存行，避免生产者和消费者互相使对方的缓存行失效（伪共享）
+
+应用场景：
+- 多核上生产和消费都很频繁、每个元素处理很快的场景，例如日志、指标的采集缓冲
+- 需要 TryEnqueue 语义、宁可丢弃也不阻塞的热路径
+- 与 BoundedQueue 的对比见 mpmc_benchmark.go
+
+优缺点：
+- 优点：高并发、短临界区下吞吐量高，延迟稳定，不受持锁协程被抢占的影响
+- 缺点：没有阻塞等待，队列经常满或空时调用方只能自旋或休眠，白白消耗CPU；
+  每个元素的处理远比入队出队慢时，队列本身不是瓶颈，无锁带来的收益可以忽略；没有关闭、优先级等功能
+
+以下实现了基于序号的无锁有界 MPMC 环形缓冲区。
+*/
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// mpmcCell 环形缓冲区的槽位
+type mpmcCell[T any] struct {
+	seq   uint64 // 槽位序号，决定当前可写还是可读
+	value T
+}
+
+// MPMCRing 无锁有界多生产者多消费者环形缓冲区
+type MPMCRing[T any] struct {
+	_     [64]byte
+	tail  uint64 // 下一个写位置
+	_     [56]byte
+	head  uint64 // 下一个读位置
+	_     [56]byte
+	mask  uint64
+	cells []mpmcCell[T]
+}
+
+// NewMPMCRing 创建环形缓冲区，容量向上取整为2的幂，最小为2
+func NewMPMCRing[T any](capacity int) *MPMCRing[T] {
+	size := 2
+	for size < capacity {
+		size <<= 1
+	}
+	r := &MPMCRing[T]{mask: uint64(size - 1), cells: make([]mpmcCell[T], size)}
+	for i := range r.cells {
+		r.cells[i].seq = uint64(i)
+	}
+	return r
+}
+
+// TryEnqueue 写入一个元素，队列已满时立即返回 false
+func (r *MPMCRing[T]) TryEnqueue(value T) bool {
+	pos := atomic.LoadUint64(&r.tail)
+	for {
+		cell := &r.cells[pos&r.mask]
+		seq := atomic.LoadUint64(&cell.seq)
+		switch diff := int64(seq) - int64(pos); {
+		case diff == 0:
+			// 槽位可写，抢占写位置
+			if atomic.CompareAndSwapUint64(&r.tail, pos, pos+1) {
+				cell.value = value
+				atomic.StoreUint64(&cell.seq, pos+1) // 发布给消费者
+				return true
+			}
+			pos = atomic.LoadUint64(&r.tail)
+		case diff < 0:
+			// 槽位还没被上一轮的消费者读走，队列已满
+			return false
+		default:
+			// 写位置已被其他生产者推进
+			pos = atomic.LoadUint64(&r.tail)
+		}
+	}
+}
+
+// TryDequeue 取出一个元素，队列为空时立即返回 false
+func (r *MPMCRing[T]) TryDequeue() (T, bool) {
+	pos := atomic.LoadUint64(&r.head)
+	for {
+		cell := &r.cells[pos&r.mask]
+		seq := atomic.LoadUint64(&cell.seq)
+		switch diff := int64(seq) - int64(pos+1); {
+		case diff == 0:
+			// 槽位可读，抢占读位置
+			if atomic.CompareAndSwapUint64(&r.head, pos, pos+1) {
+				value := cell.value
+				var zero T
+				cell.value = zero                           // 释放引用
+				atomic.StoreUint64(&cell.seq, pos+r.mask+1) // 还给下一轮的生产者
+				return value, true
+			}
+			pos = atomic.LoadUint64(&r.head)
+		case diff < 0:
+			// 槽位还没有写入，队列为空
+			var zero T
+			return zero, false
+		default:
+			// 读位置已被其他消费者推进
+			pos = atomic.LoadUint64(&r.head)
+		}
+	}
+}
+
+// Enqueue 写入一个元素，队列已满时让出CPU后重试
+func (r *MPMCRing[T]) Enqueue(value T) {
+	for !r.TryEnqueue(value) {
+		runtime.Gosched()
+	}
+}
+
+// Dequeue 取出一个元素，队列为空时让出CPU后重试
+func (r *MPMCRing[T]) Dequeue() T {
+	for {
+		if value, ok := r.TryDequeue(); ok {
+			return value
+		}
+		runtime.Gosched()
+	}
+}
+
+// Len 返回近似的元素数，并发修改时只是某一时刻的估计
+func (r *MPMCRing[T]) Len() int {
+	head := atomic.LoadUint64(&r.head)
+	tail := atomic.LoadUint64(&r.tail)
+	if tail < head {
+		return 0
+	}
+	return int(min(tail-head, r.mask+1))
+}
+
+// Cap 返回容量
+func (r *MPMCRing[T]) Cap() int {
+	return int(r.mask + 1)
+}
+
+// Stats 返回环形缓冲区的统计信息
+func (r *MPMCRing[T]) Stats() map[string]interface{} {
+	return map[string]interface{}{
+		"capacity":     r.Cap(),
+		"size":         r.Len(),
+		"enqueueCount": atomic.LoadUint64(&r.tail),
+		"dequeueCount": atomic.LoadUint64(&r.head),
+	}
+}
+
+// 场景示例：多个采集协程写入指标，多个上报协程取出，缓冲区满时丢弃而不阻塞采集
+func MPMCRingDemo() {
+	fmt.Println("无锁 MPMC 环形缓冲区示例 (指标采集):")
+
+	ring := NewMPMCRing[int](1000) // 取整为1024
+	const (
+		producers = 4
+		consumers = 2
+		perWorker = 5000
+	)
+
+	var produced, dropped, consumed int64
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				if ring.TryEnqueue(p*perWorker + i) {
+					atomic.AddInt64(&produced, 1)
+				} else {
+					atomic.AddInt64(&dropped, 1) // 缓冲区满，丢弃这个采样点
+					runtime.Gosched()
+				}
+			}
+		}(p)
+	}
+
+	done := make(chan struct{})
+	var consumerWg sync.WaitGroup
+	for c := 0; c < consumers; c++ {
+		consumerWg.Add(1)
+		go func() {
+			defer consumerWg.Done()
+			for {
+				if _, ok := ring.TryDequeue(); ok {
+					atomic.AddInt64(&consumed, 1)
+					continue
+				}
+				select {
+				case <-done:
+					// 生产者已结束，取完剩余元素后退出
+					if ring.Len() == 0 {
+						return
+					}
+				default:
+					runtime.Gosched()
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(done)
+	consumerWg.Wait()
+
+	stats := ring.Stats()
+	fmt.Printf("容量: %d\n", stats["capacity"])
+	fmt.Printf("写入: %d，丢弃: %d，取出: %d，剩余: %d\n", produced, dropped, consumed, stats["size"])
+	fmt.Printf("写入与取出数量一致: %v\n", produced == consumed)
+}
@@ -0,0 +1,342 @@
+package concurrency
+
+/*
+对象池（Object Pool）
+
+原理：
+数据库连接、RPC 客户端、大块缓冲区这类对象创建代价高（建立 TCP 连接、握手、认证、分配内存），
+每次使用都新建再销毁会让延迟和资源消耗都很高。对象池把用完的对象放回空闲列表，下次借用时直接复用。
+只用信号量限制并发数只能保证"同时最多 N 个人在用"，但每个人拿到的仍然只是一个许可，
+连接本身还要自己创建和关闭；对象池在许可之外真正管理对象的整个生命周期：创建、借出、健康检查、归还、空闲回收、销毁。
+
+关键特点：
+1. MaxActive 限制同时存在（借出 + 空闲）的对象数，借完时借用者排队等待，支持上下文超时
+2. MaxIdle 限制空闲对象数，归还时空闲列表已满则直接销毁，避免低峰期占用过多资源
+3. IdleTimeout 空闲超过该时间的对象在借用时或周期回收时被销毁，防止使用已经被服务端断开的连接
+4. 借用时执行健康检查（Validate），检查失败的对象被销毁并继续尝试下一个，必要时新建
+5. 生命周期钩子：Validate 借用前检查，Reset 归还时重置状态，Destroy 销毁时释放资源
+6. Invalidate 归还一个已损坏的对象，直接销毁而不放回池中
+
+实现方式：
+- 公平信号量（Semaphore）控制借出的对象数，等待者按到达顺序获得名额
+- 空闲列表是一个后进先出的切片，优先复用最近归还的对象，较旧的对象自然沉到底部等待空闲回收
+- 借出的对象包装为 PooledObject，记录创建时间和借用次数，并防止重复归还
+- 周期回收在进程内共享的时间轮（SharedTimingWheel）上触发，不额外启动协程
+
+应用场景：
+- 数据库连接池、Redis 连接池、HTTP/RPC 客户端连接池
+- 大对象复用（编解码器、压缩器、缓冲区）
+- 需要限制对下游的并发连接数，同时避免频繁建连
+
+优缺点：
+- 优点：复用昂贵对象，降低延迟；同时限制并发，保护下游；坏对象在借出前被剔除
+- 缺点：健康检查本身有开销；空闲对象仍然占用资源；借用者忘记归还会导致名额泄漏
+
+以下实现了泛型对象池，并在信号量示例的数据库连接池场景中借用真实的连接对象。
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrObjectPoolClosed 对象池已关闭
+	ErrObjectPoolClosed = errors.New("对象池已关闭")
+	// ErrObjectReturned 对象已经归还或失效，不能再次归还
+	ErrObjectReturned = errors.New("对象已归还")
+)
+
+// ObjectPoolOptions 对象池配置，T 为池中对象的类型
+type ObjectPoolOptions[T any] struct {
+	MaxActive        int               // 同时存在的对象数上限（借出 + 空闲）
+	MaxIdle          int               // 空闲对象数上限
+	IdleTimeout      time.Duration     // 空闲超过该时间的对象被销毁，为0时不过期
+	EvictionInterval time.Duration     // 周期回收空闲超时对象的间隔，为0时只在借用时检查
+	Validate         func(obj T) error // 借用前的健康检查，返回错误的对象被销毁，可以为 nil
+	Reset            func(obj T) error // 归还时重置对象状态，返回错误的对象被销毁，可以为 nil
+	Destroy          func(obj T)       // 销毁对象时释放资源，可以为 nil
+	OnCreate         func(obj T)       // 新建对象后调用，可以为 nil
+}
+
+// defaultObjectPoolMaxActive 未设置 MaxActive 时的对象数上限
+const defaultObjectPoolMaxActive = 8
+
+// PooledObject 从对象池借出的对象，使用完毕后通过 Return 或 Invalidate 归还
+type PooledObject[T any] struct {
+	Value     T
+	createdAt time.Time
+	idleSince time.Time
+	borrows   int64
+	returned  atomic.Bool
+}
+
+// CreatedAt 返回对象的创建时间
+func (o *PooledObject[T]) CreatedAt() time.Time {
+	return o.createdAt
+}
+
+// Borrows 返回对象被借用的次数（包括本次）
+func (o *PooledObject[T]) Borrows() int64 {
+	return o.borrows
+}
+
+// ObjectPool 泛型对象池
+type ObjectPool[T any] struct {
+	factory func(ctx context.Context) (T, error)
+	options ObjectPoolOptions[T]
+	permits *Semaphore // 借出名额，容量为 MaxActive
+
+	mu     sync.Mutex
+	idle   []*PooledObject[T] // 空闲对象，末尾是最近归还的
+	closed bool
+
+	evictTimer *WheelTimer // 周期回收定时器，未启用时为 nil
+
+	created     int64 // 新建的对象数
+	destroyed   int64 // 销毁的对象数
+	borrowed    int64 // 借用次数
+	reused      int64 // 复用空闲对象的次数
+	failedCheck int64 // 健康检查失败的次数
+	expired     int64 // 因空闲超时销毁的对象数
+	waitTimeout int64 // 等待名额超时或取消的次数
+}
+
+// NewObjectPool 创建对象池，factory 负责创建新对象；MaxActive 未设置时为8，MaxIdle 未设置或超过 MaxActive 时等于 MaxActive
+func NewObjectPool[T any](factory func(ctx context.Context) (T, error), options ObjectPoolOptions[T]) *ObjectPool[T] {
+	if options.MaxActive <= 0 {
+		options.MaxActive = defaultObjectPoolMaxActive
+	}
+	if options.MaxIdle <= 0 || options.MaxIdle > options.MaxActive {
+		options.MaxIdle = options.MaxActive
+	}
+	if options.IdleTimeout < 0 {
+		options.IdleTimeout = 0
+	}
+
+	p := &ObjectPool[T]{
+		factory: factory,
+		options: options,
+		permits: NewSemaphoreWithOptions(options.MaxActive, SemaphoreOptions{Fair: true}),
+	}
+	// 在共享时间轮上注册周期回收
+	if options.IdleTimeout > 0 && options.EvictionInterval > 0 {
+		p.evictTimer = SharedTimingWheel().Every(options.EvictionInterval, func() { p.EvictIdle() })
+	}
+	return p
+}
+
+// Borrow 借用一个对象：优先复用通过健康检查的空闲对象，否则新建；名额用完时等待到 ctx 结束
+func (p *ObjectPool[T]) Borrow(ctx context.Context) (*PooledObject[T], error) {
+	if p.isClosed() {
+		return nil, ErrObjectPoolClosed
+	}
+
+	if !p.permits.AcquireWithContext(ctx) {
+		atomic.AddInt64(&p.waitTimeout, 1)
+		return nil, fmt.Errorf("等待对象池名额: %w", ctx.Err())
+	}
+
+	for {
+		obj, err := p.takeIdle()
+		if err != nil {
+			p.permits.Release()
+			return nil, err
+		}
+		if obj == nil {
+			break
+		}
+		if p.options.Validate != nil {
+			if err := p.options.Validate(obj.Value); err != nil {
+				atomic.AddInt64(&p.failedCheck, 1)
+				p.destroy(obj)
+				continue
+			}
+		}
+		atomic.AddInt64(&p.reused, 1)
+		return p.lend(obj), nil
+	}
+
+	// 没有可用的空闲对象，新建一个
+	value, err := p.factory(ctx)
+	if err != nil {
+		p.permits.Release()
+		return nil, fmt.Errorf("创建对象: %w", err)
+	}
+	atomic.AddInt64(&p.created, 1)
+	if p.options.OnCreate != nil {
+		p.options.OnCreate(value)
+	}
+	return p.lend(&PooledObject[T]{Value: value, createdAt: time.Now()}), nil
+}
+
+// takeIdle 取出最近归还且未过期的空闲对象，途中遇到的过期对象被销毁；没有空闲对象时返回 nil
+func (p *ObjectPool[T]) takeIdle() (*PooledObject[T], error) {
+	var stale []*PooledObject[T]
+	defer func() {
+		for _, obj := range stale {
+			atomic.AddInt64(&p.expired, 1)
+			p.destroy(obj)
+		}
+	}()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, ErrObjectPoolClosed
+	}
+	for len(p.idle) > 0 {
+		obj := p.idle[len(p.idle)-1]
+		p.idle[len(p.idle)-1] = nil
+		p.idle = p.idle[:len(p.idle)-1]
+		if p.expiredLocked(obj, time.Now()) {
+			stale = append(stale, obj)
+			continue
+		}
+		return obj, nil
+	}
+	return nil, nil
+}
+
+// expiredLocked 判断空闲对象是否已经超时
+func (p *ObjectPool[T]) expiredLocked(obj *PooledObject[T], now time.Time) bool {
+	return p.options.IdleTimeout > 0 && now.Sub(obj.idleSince) >= p.options.IdleTimeout
+}
+
+// lend 标记对象为借出状态
+func (p *ObjectPool[T]) lend(obj *PooledObject[T]) *PooledObject[T] {
+	obj.borrows++
+	obj.returned.Store(false)
+	atomic.AddInt64(&p.borrowed, 1)
+	return obj
+}
+
+// Return 归还对象：重置失败、池已关闭或空闲列表已满时销毁，否则放回空闲列表
+func (p *ObjectPool[T]) Return(obj *PooledObject[T]) error {
+	if !obj.returned.CompareAndSwap(false, true) {
+		return ErrObjectReturned
+	}
+	defer p.permits.Release()
+
+	if p.options.Reset != nil {
+		if err := p.options.Reset(obj.Value); err != nil {
+			p.destroy(obj)
+			return nil
+		}
+	}
+
+	p.mu.Lock()
+	if p.closed || len(p.idle) >= p.options.MaxIdle {
+		p.mu.Unlock()
+		p.destroy(obj)
+		return nil
+	}
+	obj.idleSince = time.Now()
+	p.idle = append(p.idle, obj)
+	p.mu.Unlock()
+	return nil
+}
+
+// Invalidate 归还一个已损坏的对象，直接销毁而不放回池中
+func (p *ObjectPool[T]) Invalidate(obj *PooledObject[T]) error {
+	if !obj.returned.CompareAndSwap(false, true) {
+		return ErrObjectReturned
+	}
+	p.destroy(obj)
+	p.permits.Release()
+	return nil
+}
+
+// destroy 调用销毁钩子
+func (p *ObjectPool[T]) destroy(obj *PooledObject[T]) {
+	atomic.AddInt64(&p.destroyed, 1)
+	if p.options.Destroy != nil {
+		p.options.Destroy(obj.Value)
+	}
+}
+
+// EvictIdle 销毁所有空闲超时的对象，返回销毁的数量
+func (p *ObjectPool[T]) EvictIdle() int {
+	if p.options.IdleTimeout <= 0 {
+		return 0
+	}
+
+	p.mu.Lock()
+	now := time.Now()
+	kept := p.idle[:0]
+	var stale []*PooledObject[T]
+	for _, obj := range p.idle {
+		if p.expiredLocked(obj, now) {
+			stale = append(stale, obj)
+		} else {
+			kept = append(kept, obj)
+		}
+	}
+	for i := len(kept); i < len(p.idle); i++ {
+		p.idle[i] = nil
+	}
+	p.idle = kept
+	p.mu.Unlock()
+
+	for _, obj := range stale {
+		atomic.AddInt64(&p.expired, 1)
+		p.destroy(obj)
+	}
+	return len(stale)
+}
+
+// isClosed 返回对象池是否已关闭
+func (p *ObjectPool[T]) isClosed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closed
+}
+
+// Close 关闭对象池：销毁所有空闲对象，之后归还的对象也会被直接销毁；可重复调用
+func (p *ObjectPool[T]) Close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	p.mu.Unlock()
+
+	if p.evictTimer != nil {
+		p.evictTimer.Stop()
+	}
+	for _, obj := range idle {
+		p.destroy(obj)
+	}
+}
+
+// Stats 返回对象池的统计信息
+func (p *ObjectPool[T]) Stats() map[string]interface{} {
+	p.mu.Lock()
+	idle := len(p.idle)
+	closed := p.closed
+	p.mu.Unlock()
+
+	permits := p.permits.Stats()
+	return map[string]interface{}{
+		"maxActive":    p.options.MaxActive,
+		"maxIdle":      p.options.MaxIdle,
+		"borrowedNow":  permits["acquired"],
+		"idle":         idle,
+		"waiting":      permits["waiting"],
+		"closed":       closed,
+		"created":      atomic.LoadInt64(&p.created),
+		"destroyed":    atomic.LoadInt64(&p.destroyed),
+		"borrowed":     atomic.LoadInt64(&p.borrowed),
+		"reused":       atomic.LoadInt64(&p.reused),
+		"failedChecks": atomic.LoadInt64(&p.failedCheck),
+		"expired":      atomic.LoadInt64(&p.expired),
+		"waitTimeouts": atomic.LoadInt64(&p.waitTimeout),
+	}
+}
@@ -0,0 +1,299 @@
+package concurrency
+
+/*
+多阶段流水线（Pipeline）
+
+原理：
+流水线把一个处理过程拆成若干个顺序阶段，阶段之间通过有界队列连接。
+每个阶段可以有多个工作协程并行处理，上一阶段的输出即为下一阶段的输入。
+每个数据项都携带一个 TaskContext，随数据在各阶段之间流动，
+任一阶段发现上下文已取消或超时，就停止处理该数据项。
+
+关键特点：
+1. 阶段之间使用 BoundedQueue 连接，天然具备背压（下游慢时上游阻塞）
+2. 每个阶段可以独立配置并发度
+3. 每个阶段为数据项派生子上下文（新的SpanID），形成完整的调用链
+4. 关闭时按阶段顺序排空，保证已提交的数据项处理完毕
+
+实现方式：
+- N个阶段对应N个输入队列，最后一个阶段的输出写入结果通道
+- 阶段失败或上下文取消时，数据项直接以错误形式输出到结果通道
+- 使用 WaitGroup 按顺序等待每个阶段退出，再关闭下一个队列
+
+应用场景：
+- 订单处理：校验 → 计价 → 落库
+- 日志处理：解析 → 过滤 → 聚合
+- 内容审核：分词 → 过滤 → 打标
+
+优缺点：
+- 优点：阶段解耦，易于扩展，每个阶段可以独立调优并发度
+- 缺点：数据项在阶段间多次入队出队，有额外的同步开销
+
+以下实现了一个基于有界队列和任务上下文的简单流水线。
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// StageFunc 流水线阶段处理函数
+type StageFunc func(tc *TaskContext, item interface{}) (interface{}, error)
+
+// Stage 流水线阶段定义
+type Stage struct {
+	Name    string    // 阶段名称
+	Workers int       // 工作协程数
+	Fn      StageFunc // 处理函数
+}
+
+// PipelineResult 流水线输出结果
+type PipelineResult struct {
+	Item  interface{}  // 最后一个成功阶段的输出
+	Ctx   *TaskContext // 数据项的任务上下文
+	Err   error        // 失败原因（成功时为nil）
+	Stage string       // 失败所在阶段（成功时为空）
+}
+
+// stageStats 阶段统计
+type stageStats struct {
+	processed int64 // 处理成功数
+	failed    int64 // 处理失败数
+	canceled  int64 // 因上下文取消跳过数
+}
+
+// Pipeline 多阶段流水线
+type Pipeline struct {
+	stages  []Stage              // 阶段定义
+	queues  []*BoundedQueue      // 每个阶段的输入队列
+	results chan *PipelineResult // 输出结果通道
+	stats   []*stageStats        // 每个阶段的统计
+	wgs     []*sync.WaitGroup    // 每个阶段的工作协程
+	started int32                // 是否已启动
+	once    sync.Once            // 保证只关闭一次
+}
+
+// NewPipeline 创建流水线，bufferSize 为每个阶段输入队列的容量
+func NewPipeline(bufferSize int, stages ...Stage) *Pipeline {
+	p := &Pipeline{
+		stages:  stages,
+		queues:  make([]*BoundedQueue, len(stages)),
+		results: make(chan *PipelineResult, bufferSize),
+		stats:   make([]*stageStats, len(stages)),
+		wgs:     make([]*sync.WaitGroup, len(stages)),
+	}
+
+	for i := range stages {
+		if p.stages[i].Workers <= 0 {
+			p.stages[i].Workers = 1
+		}
+		p.queues[i] = NewBoundedQueue(bufferSize)
+		p.stats[i] = &stageStats{}
+		p.wgs[i] = &sync.WaitGroup{}
+	}
+
+	return p
+}
+
+// Start 启动所有阶段的工作协程
+func (p *Pipeline) Start() {
+	if !atomic.CompareAndSwapInt32(&p.started, 0, 1) {
+		return
+	}
+
+	for i, stage := range p.stages {
+		p.wgs[i].Add(stage.Workers)
+		for w := 0; w < stage.Workers; w++ {
+			go p.runStage(i)
+		}
+	}
+}
+
+// runStage 阶段工作协程主循环
+func (p *Pipeline) runStage(index int) {
+	defer p.wgs[index].Done()
+
+	for {
+		// 直接出队而非 DequeueWithContext：在队列中过期的数据项也需要以错误形式输出
+		raw, err := p.queues[index].Dequeue()
+		if err != nil {
+			// 输入队列已关闭且为空
+			return
+		}
+
+		item, tc := raw, (*TaskContext)(nil)
+		if envelope, ok := raw.(*TaskEnvelope); ok {
+			item, tc = envelope.Item, envelope.Ctx
+		}
+		if tc == nil {
+			tc = NewTaskContext(context.Background())
+		}
+
+		p.process(index, item, tc)
+	}
+}
+
+// process 在本阶段处理一个数据项，输出到下一阶段或结果通道
+func (p *Pipeline) process(index int, item interface{}, tc *TaskContext) {
+	stage := p.stages[index]
+	stats := p.stats[index]
+
+	// 每个阶段派生子上下文，记录阶段名称；阶段处理完毕即取消，不在父上下文中长期挂着
+	stageCtx := tc.Child()
+	defer stageCtx.Cancel()
+	stageCtx.SetMetadata("stage", stage.Name)
+
+	if stageCtx.Err() != nil {
+		atomic.AddInt64(&stats.canceled, 1)
+		p.results <- &PipelineResult{Item: item, Ctx: stageCtx.handoff(tc), Err: stageCtx.Err(), Stage: stage.Name}
+		return
+	}
+
+	output, err := stage.Fn(stageCtx, item)
+	if err == nil && stageCtx.Err() != nil {
+		err = stageCtx.Err()
+	}
+	// 交给下游和结果通道的上下文沿用本阶段的 Span（包括处理函数写入的元数据），
+	// 但取消信号来自输入的上下文，不受阶段结束时的 Cancel 影响
+	span := stageCtx.handoff(tc)
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			atomic.AddInt64(&stats.canceled, 1)
+		} else {
+			atomic.AddInt64(&stats.failed, 1)
+		}
+		p.results <- &PipelineResult{Item: item, Ctx: span, Err: err, Stage: stage.Name}
+		return
+	}
+
+	atomic.AddInt64(&stats.processed, 1)
+
+	// 最后一个阶段：输出结果
+	if index == len(p.stages)-1 {
+		p.results <- &PipelineResult{Item: output, Ctx: span}
+		return
+	}
+
+	// 传递给下一个阶段（携带本阶段的 Span，保持调用链）
+	if err := p.queues[index+1].EnqueueWithContext(span, output); err != nil {
+		atomic.AddInt64(&stats.canceled, 1)
+		p.results <- &PipelineResult{Item: output, Ctx: span, Err: err, Stage: p.stages[index+1].Name}
+	}
+}
+
+// Submit 向流水线提交一个数据项
+func (p *Pipeline) Submit(tc *TaskContext, item interface{}) error {
+	if len(p.queues) == 0 {
+		return errors.New("流水线没有任何阶段")
+	}
+	return p.queues[0].EnqueueWithContext(tc, item)
+}
+
+// Results 返回结果通道，Close 完成后该通道会被关闭
+func (p *Pipeline) Results() <-chan *PipelineResult {
+	return p.results
+}
+
+// Close 停止接收新数据，按阶段顺序排空后关闭结果通道
+func (p *Pipeline) Close() {
+	p.once.Do(func() {
+		go func() {
+			for i := range p.stages {
+				p.queues[i].Close()
+				p.wgs[i].Wait()
+			}
+			close(p.results)
+		}()
+	})
+}
+
+// Stats 返回每个阶段的统计信息
+func (p *Pipeline) Stats() map[string]interface{} {
+	result := make(map[string]interface{}, len(p.stages))
+	for i, stage := range p.stages {
+		result[stage.Name] = map[string]interface{}{
+			"workers":   stage.Workers,
+			"pending":   p.queues[i].Size(),
+			"processed": atomic.LoadInt64(&p.stats[i].processed),
+			"failed":    atomic.LoadInt64(&p.stats[i].failed),
+			"canceled":  atomic.LoadInt64(&p.stats[i].canceled),
+		}
+	}
+	return result
+}
+
+// 场景示例：订单处理流水线（校验 → 计价 → 落库）
+func PipelineDemo() {
+	fmt.Println("订单处理流水线场景（任务上下文贯穿所有阶段）:")
+
+	type Order struct {
+		ID     string
+		Amount float64
+	}
+
+	pipeline := NewPipeline(10,
+		Stage{Name: "校验", Workers: 2, Fn: func(tc *TaskContext, item interface{}) (interface{}, error) {
+			order := item.(Order)
+			if order.Amount <= 0 {
+				return nil, fmt.Errorf("订单 %s 金额非法", order.ID)
+			}
+			time.Sleep(10 * time.Millisecond)
+			return order, nil
+		}},
+		Stage{Name: "计价", Workers: 2, Fn: func(tc *TaskContext, item interface{}) (interface{}, error) {
+			order := item.(Order)
+			// 模拟慢速的计价服务，响应受上下文截止时间约束
+			select {
+			case <-time.After(30 * time.Millisecond):
+			case <-tc.Done():
+				return nil, tc.Err()
+			}
+			order.Amount *= 0.9
+			return order, nil
+		}},
+		Stage{Name: "落库", Workers: 1, Fn: func(tc *TaskContext, item interface{}) (interface{}, error) {
+			time.Sleep(5 * time.Millisecond)
+			return item, nil
+		}},
+	)
+	pipeline.Start()
+
+	orders := []struct {
+		order   Order
+		timeout time.Duration
+	}{
+		{Order{"O-1", 100}, time.Second},
+		{Order{"O-2", -5}, time.Second},
+		{Order{"O-3", 250}, 20 * time.Millisecond}, // 截止时间太短，会在计价阶段超时
+		{Order{"O-4", 80}, time.Second},
+	}
+
+	for _, o := range orders {
+		tc := NewTaskContextWithTimeout(context.Background(), o.timeout)
+		tc.SetMetadata("order", o.order.ID)
+		if err := pipeline.Submit(tc, o.order); err != nil {
+			fmt.Printf("提交订单 %s 失败: %v\n", o.order.ID, err)
+		}
+	}
+	pipeline.Close()
+
+	fmt.Println("\n处理结果:")
+	for result := range pipeline.Results() {
+		orderID, _ := result.Ctx.Metadata("order")
+		if result.Err != nil {
+			fmt.Printf("  订单 %s 在[%s]阶段失败: %v (trace=%s)\n",
+				orderID, result.Stage, result.Err, shortID(result.Ctx.TraceID))
+		} else {
+			fmt.Printf("  订单 %s 完成: %+v (trace=%s)\n", orderID, result.Item, shortID(result.Ctx.TraceID))
+		}
+	}
+
+	fmt.Println("\n阶段统计:")
+	for _, stage := range []string{"校验", "计价", "落库"} {
+		fmt.Printf("  %s: %v\n", stage, pipeline.Stats()[stage])
+	}
+}
@@ -0,0 +1,238 @@
+package concurrency
+
+/*
+协程池自动扩缩容（AutoScaler）
+
+原理：
+固定大小的协程池要按峰值流量配置才不会积压，但大部分时间流量远低于峰值，多余的协程只是空等；
+按平均流量配置又会在高峰期积压，任务排队时间成倍增长。
+AutoScaler 定期观察协程池，根据利特尔法则估算需要的工作协程数：
+  稳态需要的协程数 = 任务到达速率 × 平均任务耗时 / 目标利用率
+再加上在一个检查周期内消化当前队列积压所需的协程数，调整到 [MinWorkers, MaxWorkers] 范围内。
+
+关键特点：
+1. 扩容一步到位：积压出现时立即扩到估算值，尽快止住排队时间的增长
+2. 缩容逐步进行：每次最多减少与估算值差距的一半，避免流量短暂回落时过早缩容、随即又要扩容
+3. 还没有任务完成、无法估算耗时时，只要队列有积压就把协程数翻倍
+4. Check 可以手动调用，Start 在后台按 Interval 定期调用
+
+实现方式：
+- 协程池记录已提交任务数、已完成任务数和累计执行时间，两次检查之间的差值给出到达速率和平均耗时
+- 调整通过 GoroutinePool.Resize 完成，缩容时被停止的协程执行完手头的任务才退出
+
+应用场景：
+- 流量有明显波峰波谷的后台任务处理
+- 下游耗时会变化的调用（下游变慢时需要更多协程维持吞吐量）
+
+优缺点：
+- 优点：低峰期少占资源，高峰期自动扩容，不需要人工估算池的大小
+- 缺点：平均耗时只统计已完成的任务，执行时间远长于检查周期的任务会让估算滞后；
+  下游本身已经过载时扩容只会加重下游负担，需要配合限流或熔断
+
+以下实现了协程池的自动扩缩容器，以及流量突增时协程数随之变化的示例。
+*/
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AutoScalerOptions 自动扩缩容选项
+type AutoScalerOptions struct {
+	MinWorkers        int           // 最少工作协程数
+	MaxWorkers        int           // 最多工作协程数
+	Interval          time.Duration // Start 后台检查的间隔
+	TargetUtilization float64       // 期望的工作协程忙碌比例，越小预留的余量越多
+}
+
+// DefaultAutoScalerOptions 默认的自动扩缩容选项
+var DefaultAutoScalerOptions = AutoScalerOptions{
+	MinWorkers:        1,
+	MaxWorkers:        64,
+	Interval:          time.Second,
+	TargetUtilization: 0.8,
+}
+
+// AutoScaler 根据队列积压和任务耗时调整协程池的工作协程数
+type AutoScaler struct {
+	mu         sync.Mutex
+	pool       *GoroutinePool
+	options    AutoScalerOptions
+	lastCheck  time.Time
+	submitted  int32         // 上次检查时的已提交任务数
+	completed  int32         // 上次检查时的已完成任务数
+	busy       int64         // 上次检查时的累计执行时间
+	latency    time.Duration // 最近一个检查周期的平均任务耗时
+	scaleUps   int           // 扩容次数
+	scaleDowns int           // 缩容次数
+	stop       chan struct{}
+	stopOnce   sync.Once
+}
+
+// NewAutoScaler 创建协程池的自动扩缩容器，未设置的选项使用默认值
+func NewAutoScaler(pool *GoroutinePool, options AutoScalerOptions) *AutoScaler {
+	if options.MinWorkers <= 0 {
+		options.MinWorkers = DefaultAutoScalerOptions.MinWorkers
+	}
+	if options.MaxWorkers <= 0 {
+		options.MaxWorkers = DefaultAutoScalerOptions.MaxWorkers
+	}
+	options.MaxWorkers = max(options.MaxWorkers, options.MinWorkers)
+	if options.Interval <= 0 {
+		options.Interval = DefaultAutoScalerOptions.Interval
+	}
+	if options.TargetUtilization <= 0 || options.TargetUtilization > 1 {
+		options.TargetUtilization = DefaultAutoScalerOptions.TargetUtilization
+	}
+
+	a := &AutoScaler{pool: pool, options: options, stop: make(chan struct{})}
+	a.lastCheck, a.submitted, a.completed, a.busy = a.sample()
+	return a
+}
+
+// sample 读取协程池的累计计数
+func (a *AutoScaler) sample() (time.Time, int32, int32, int64) {
+	p := a.pool
+	// 失败后重试的执行也占用了工作协程的时间，计入完成数才能和累计执行时间对应
+	completed := atomic.LoadInt32(&p.successCount) + atomic.LoadInt32(&p.errorCount) +
+		atomic.LoadInt32(&p.timeoutCount) + atomic.LoadInt32(&p.retryCount)
+	return time.Now(), atomic.LoadInt32(&p.taskCount), completed, atomic.LoadInt64(&p.busyNanos)
+}
+
+// Check 根据上次检查以来的到达速率、平均耗时和当前积压调整一次工作协程数，返回调整后的数量
+func (a *AutoScaler) Check() int {
+	now, submitted, completed, busy := a.sample()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	elapsed := now.Sub(a.lastCheck)
+	arrivals := submitted - a.submitted
+	if done := completed - a.completed; done > 0 {
+		a.latency = time.Duration((busy - a.busy) / int64(done))
+	}
+	a.lastCheck, a.submitted, a.completed, a.busy = now, submitted, completed, busy
+
+	current := a.pool.Workers()
+	queued := a.pool.Pending()
+	desired := current
+	switch {
+	case a.latency > 0 && elapsed > 0:
+		// 利特尔法则：稳态需要的协程数 = 到达速率 × 平均耗时，再按目标利用率留出余量
+		rate := float64(arrivals) / elapsed.Seconds()
+		need := rate * a.latency.Seconds() / a.options.TargetUtilization
+		// 积压的任务在一个检查周期内处理完
+		need += float64(queued) * a.latency.Seconds() / elapsed.Seconds()
+		desired = int(math.Ceil(need))
+	case queued > 0:
+		desired = current * 2
+	}
+	if desired < current {
+		desired = current - max((current-desired)/2, 1)
+	}
+	desired = min(max(desired, a.options.MinWorkers), a.options.MaxWorkers)
+
+	if desired == current || a.pool.Resize(desired) != nil {
+		return current
+	}
+	if desired > current {
+		a.scaleUps++
+	} else {
+		a.scaleDowns++
+	}
+	return desired
+}
+
+// Start 启动后台定时检查
+func (a *AutoScaler) Start() {
+	go func() {
+		ticker := time.NewTicker(a.options.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				a.Check()
+			case <-a.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止后台检查，可重复调用
+func (a *AutoScaler) Stop() {
+	a.stopOnce.Do(func() { close(a.stop) })
+}
+
+// Stats 返回扩缩容统计信息
+func (a *AutoScaler) Stats() map[string]interface{} {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return map[string]interface{}{
+		"workers":    a.pool.Workers(),
+		"minWorkers": a.options.MinWorkers,
+		"maxWorkers": a.options.MaxWorkers,
+		"latency":    a.latency,
+		"scaleUps":   a.scaleUps,
+		"scaleDowns": a.scaleDowns,
+	}
+}
+
+// 场景示例：图片处理服务在促销开始时流量突增，结束后回落
+func AutoScalerDemo() {
+	fmt.Println("协程池自动扩缩容示例 (每个任务耗时20ms):")
+
+	pool := NewGoroutinePool(2, 1000)
+	defer pool.Shutdown()
+	scaler := NewAutoScaler(pool, AutoScalerOptions{
+		MinWorkers:        2,
+		MaxWorkers:        32,
+		Interval:          100 * time.Millisecond,
+		TargetUtilization: 0.8,
+	})
+
+	// 每个阶段持续 600ms，按给定速率提交任务
+	phases := []struct {
+		name string
+		rate int // 每秒提交的任务数
+	}{
+		{"平时", 50},
+		{"促销开始", 500},
+		{"促销高峰", 1000},
+		{"促销结束", 50},
+		{"深夜", 0},
+	}
+	task := func(context.Context) error {
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	}
+
+	for _, phase := range phases {
+		start := time.Now()
+		nextCheck := start.Add(100 * time.Millisecond)
+		submitted := 0
+		for checks := 0; time.Since(start) < 600*time.Millisecond; {
+			// 按速率补齐到目前为止应当提交的任务，sleep 精度不够时一次提交多个
+			for due := int(time.Since(start).Seconds() * float64(phase.rate)); submitted < due; submitted++ {
+				pool.Submit(task)
+			}
+			time.Sleep(2 * time.Millisecond)
+			if time.Now().After(nextCheck) {
+				queued := pool.Pending()
+				workers := scaler.Check()
+				checks++
+				if checks%2 == 0 {
+					fmt.Printf("%s: 速率 %d 个/秒，队列积压 %d，平均耗时 %v，工作协程 %d\n", phase.name, phase.rate, queued,
+						scaler.Stats()["latency"].(time.Duration).Round(time.Millisecond), workers)
+				}
+				nextCheck = nextCheck.Add(100 * time.Millisecond)
+			}
+		}
+	}
+
+	stats := scaler.Stats()
+	fmt.Printf("\n扩容 %d 次，缩容 %d 次，最终工作协程数 %d\n", stats["scaleUps"], stats["scaleDowns"], stats["workers"])
+}
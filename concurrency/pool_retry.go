@@ -0,0 +1,147 @@
+package concurrency
+
+/*
+协程池任务的 panic 恢复与失败重试
+
+原理：
+任务中的 panic 如果没有被恢复，会沿着工作协程的调用栈一路向上，最终让整个进程崩溃；
+即使在任务外层恢复，也要保证工作协程继续运行、协程池的统计和等待逻辑不被打乱。
+另一方面，很多失败是暂时的（网络抖动、下游限流、锁冲突），稍等一会儿再试就能成功，
+立即重试往往又撞上同一个问题，所以重试之间要退避，而且间隔逐次增大（指数退避）。
+
+关键特点：
+1. 每次执行都在 recover 保护下进行，panic 被转换为 PanicError（带有 panic 的值和调用栈），计入 panicCount
+2. 任务按 RetryPolicy 重试（见 retry.go）：最多执行次数、退避策略和上限，RetryIf 与 Permanent 决定哪些错误值得重试
+3. 退避期间任务不占用工作协程：失败的任务交给定时器，到时间后以原优先级重新放回队列末尾
+4. 中间失败计入 retryCount，只有最后一次仍然失败才计入 errorCount
+5. Shutdown 会等待正在退避的任务重新入队并执行完毕
+
+实现方式：
+- safeCall 用 defer + recover 包装任务调用
+- 退避时间由重试策略计算，任务记录上一次的等待时间，供去相关抖动策略在此基础上随机
+- 协程池记录正在退避的任务数，关闭时队列为空但仍有任务在退避，工作协程继续等待
+
+应用场景：
+- 调用不稳定的下游服务、写入偶尔冲突的数据库
+- 第三方库可能 panic 的数据解析任务
+
+优缺点：
+- 优点：单个任务的 panic 不会影响其他任务和进程本身；暂时性故障自动恢复，重试不阻塞工作协程
+- 缺点：重试会放大下游压力，下游整体故障时应配合熔断；非幂等的任务重试前要确认可以重复执行
+
+以下实现了 panic 恢复和协程池任务的退避重试，以及不稳定的支付网关回调处理示例。
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+)
+
+// PanicError 任务执行时发生的 panic
+type PanicError struct {
+	Value interface{} // panic 的值
+	Stack []byte      // 发生 panic 时的调用栈
+}
+
+// newPanicError 记录 panic 的值和当前调用栈，需要在 recover 所在的 defer 中调用
+func newPanicError(value interface{}) *PanicError {
+	return &PanicError{Value: value, Stack: debug.Stack()}
+}
+
+// Error 实现 error 接口
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("任务发生panic: %v", e.Value)
+}
+
+// Unwrap panic 的值本身是错误时返回它
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
+// safeCall 执行任务，把 panic 转换为 PanicError
+func safeCall(ctx context.Context, task GoroutineTask) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = newPanicError(r)
+		}
+	}()
+	return task(ctx)
+}
+
+// SubmitWithRetry 以普通优先级提交任务，失败（返回错误或 panic）后按策略退避重试；未设置的策略字段使用默认值
+func (p *GoroutinePool) SubmitWithRetry(task GoroutineTask, policy RetryPolicy) error {
+	policy = policy.normalize()
+	return p.enqueueItem(nil, queuedTask{task: task, priority: PriorityNormal, retry: &policy, attempt: 1})
+}
+
+// 场景示例：处理支付网关的回调通知，网关偶尔超时，解析代码在遇到畸形报文时会 panic
+func PoolRetryDemo() {
+	fmt.Println("协程池 panic 恢复与重试示例 (支付回调处理):")
+
+	errTimeout := errors.New("网关超时")
+	errSignature := errors.New("签名校验失败")
+	pool := NewGoroutinePool(2, 20)
+
+	var attempts [6]int32
+	// 各订单的回调前几次处理会遇到的问题
+	handle := func(order int) GoroutineTask {
+		return func(context.Context) error {
+			n := atomic.AddInt32(&attempts[order], 1)
+			switch {
+			case order == 1 && n <= 2:
+				return errTimeout // 前两次超时，第三次成功
+			case order == 2:
+				return errTimeout // 一直超时，重试次数用完
+			case order == 3:
+				return Permanent(errSignature) // 签名错误重试也不会成功，标记为不可重试
+			case order == 4 && n == 1:
+				var payload map[string]string
+				payload["amount"] = "100" // 畸形报文导致写入 nil map，第一次 panic
+			case order == 5:
+				panic("无法解析的回调报文") // 每次都 panic
+			}
+			return nil
+		}
+	}
+
+	// 多个回调同时超时时，去相关抖动把它们的重试时间错开，避免一起打到网关
+	policy := RetryPolicy{
+		MaxAttempts:    3,
+		Strategy:       BackoffDecorrelatedJitter,
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     50 * time.Millisecond,
+	}
+	fmt.Printf("重试策略: 最多执行 %d 次，%s，每次等待 %v~%v\n",
+		policy.MaxAttempts, policy.Strategy, policy.InitialBackoff, policy.Backoff(2))
+
+	start := time.Now()
+	for order := range attempts {
+		pool.SubmitWithRetry(handle(order), policy)
+	}
+	// 不带重试策略的任务 panic 后同样被恢复，工作协程继续处理后续任务
+	pool.Submit(func(context.Context) error { panic("对账任务 panic") })
+	pool.Submit(func(context.Context) error { return nil })
+	pool.Shutdown() // 等待退避中的任务也执行完毕
+
+	for order := range attempts {
+		fmt.Printf("  订单%d: 执行 %d 次\n", order, atomic.LoadInt32(&attempts[order]))
+	}
+	stats := pool.Stats()
+	fmt.Printf("耗时 %v；成功 %d，最终失败 %d，重试 %d 次，panic %d 次，工作协程 %d 个\n",
+		time.Since(start).Round(10*time.Millisecond), stats["successCount"], stats["errorCount"],
+		stats["retryCount"], stats["panicCount"], stats["workers"])
+
+	// SubmitFunc 的任务 panic 时，Future 以 PanicError 完成
+	futurePool := NewGoroutinePool(1, 1)
+	_, err := SubmitFunc(futurePool, func() (int, error) { panic("计算金额时除以0") }).Get(context.Background())
+	var panicErr *PanicError
+	fmt.Printf("Future 的错误: %v (是 PanicError: %v)\n", err, errors.As(err, &panicErr))
+	futurePool.Shutdown()
+}
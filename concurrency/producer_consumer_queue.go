@@ -12,11 +12,18 @@ package concurrency
 2. 队列作为缓冲区，平衡生产和消费的速率
 3. 支持阻塞操作（队列满时生产者阻塞，队列空时消费者阻塞）
 4. 支持多个生产者和多个消费者
+5. 可选的优先级：紧急的项可以越过已排队的普通项先被取出，阻塞语义不变
+6. 批量入队、出队：一次加锁移动多个项，高吞吐场景下加锁和唤醒的开销按批次分摊
+7. 超时语义精确：带超时的入队、出队返回超时错误时，项一定没有入队、出队，也不会留下后台协程
 
 实现方式：
 - 使用通道(channel)作为共享队列
 - 使用互斥锁和条件变量实现阻塞行为
+- 条件变量没有超时等待，带超时的操作用 context.AfterFunc 在到期时唤醒等待者，由等待者自己检查是否到期；
+  到期后注册的回调随之注销，不需要额外的协程
 - 提供优雅关闭机制
+- 每个优先级一个环形缓冲区，所有优先级共享同一个容量；出队时从最高的非空优先级取，
+  同一优先级内先进先出。优先级数量固定且很少，逐级查找比堆更简单，也保持了同级的顺序
 
 应用场景：
 - 并发数据处理
@@ -26,14 +33,16 @@ package concurrency
 
 优缺点：
 - 优点：解耦任务生产和消费，提高并发处理能力
-- 缺点：需要额外的同步机制，可能增加复杂性
+- 缺点：需要额外的同步机制，可能增加复杂性；高优先级的项持续到来时低优先级的项会一直得不到处理
 
 以下实现了一个线程安全的生产者-消费者队列，支持阻塞操作和优雅关闭。
 */
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"runtime"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -41,39 +50,56 @@ import (
 
 // 错误定义
 var (
-	ErrQueueClosed = errors.New("队列已关闭")
-	ErrQueueFull   = errors.New("队列已满")
+	ErrQueueClosed    = errors.New("队列已关闭")
+	ErrQueueFull      = errors.New("队列已满")
+	ErrDequeueTimeout = errors.New("出队超时")
 )
 
+// ring 一个优先级的环形缓冲区
+type ring struct {
+	items []interface{} // 队列项
+	head  int           // 队列头索引
+	tail  int           // 队列尾索引
+	count int           // 该优先级的项数
+}
+
 // BoundedQueue 有界队列，支持生产者-消费者模式
 type BoundedQueue struct {
-	items        []interface{} // 队列项
-	capacity     int           // 队列容量
-	head         int           // 队列头索引
-	tail         int           // 队列尾索引
-	count        int           // 队列中的项数
-	mu           sync.Mutex    // 互斥锁
-	notEmpty     *sync.Cond    // 非空条件变量
-	notFull      *sync.Cond    // 非满条件变量
-	closed       int32         // 关闭标志
-	enqueueCount int64         // 入队计数
-	dequeueCount int64         // 出队计数
+	rings        []ring         // 每个优先级一个环形缓冲区，下标越大优先级越高
+	capacity     int            // 队列容量，所有优先级共享
+	count        int            // 队列中的项数
+	mu           sync.Mutex     // 互斥锁
+	notEmpty     *sync.Cond     // 非空条件变量
+	notFull      *sync.Cond     // 非满条件变量
+	closed       int32          // 关闭标志
+	enqueueCount StripedCounter // 入队计数
+	dequeueCount StripedCounter // 出队计数
+	expiredCount StripedCounter // 因上下文过期被丢弃的项数
 }
 
 // NewBoundedQueue 创建新的有界队列
 func NewBoundedQueue(capacity int) *BoundedQueue {
+	return NewPriorityBoundedQueue(capacity, 1)
+}
+
+// NewPriorityBoundedQueue 创建有 levels 个优先级的有界队列，优先级为 0 到 levels-1，数值越大越先出队
+func NewPriorityBoundedQueue(capacity int, levels int) *BoundedQueue {
 	if capacity <= 0 {
 		capacity = 10
 	}
+	if levels <= 0 {
+		levels = 1
+	}
 
 	q := &BoundedQueue{
-		items:    make([]interface{}, capacity),
+		rings:    make([]ring, levels),
 		capacity: capacity,
-		head:     0,
-		tail:     0,
 		count:    0,
 		closed:   0,
 	}
+	for i := range q.rings {
+		q.rings[i].items = make([]interface{}, capacity)
+	}
 
 	q.notEmpty = sync.NewCond(&q.mu)
 	q.notFull = sync.NewCond(&q.mu)
@@ -81,8 +107,18 @@ func NewBoundedQueue(capacity int) *BoundedQueue {
 	return q
 }
 
-// Enqueue 将项添加到队列，如果队列已满则阻塞
+// Enqueue 以最低优先级将项添加到队列，如果队列已满则阻塞
 func (q *BoundedQueue) Enqueue(item interface{}) error {
+	return q.EnqueuePriority(item, 0)
+}
+
+// EnqueuePriority 按优先级将项添加到队列，如果队列已满则阻塞；超出范围的优先级取最近的有效值
+func (q *BoundedQueue) EnqueuePriority(item interface{}, priority int) error {
+	return q.enqueue(nil, item, priority)
+}
+
+// enqueue 等待空位后入队；ctx 不为 nil 且在等到空位之前结束时返回 ErrQueueFull
+func (q *BoundedQueue) enqueue(ctx context.Context, item interface{}, priority int) error {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
@@ -91,109 +127,167 @@ func (q *BoundedQueue) Enqueue(item interface{}) error {
 		return ErrQueueClosed
 	}
 
-	// 等待直到队列非满或关闭
-	for q.count == q.capacity && atomic.LoadInt32(&q.closed) == 0 {
-		q.notFull.Wait()
-	}
+	// 等待直到队列非满、关闭或 ctx 结束
+	expired := q.waitLocked(ctx, q.notFull, func() bool { return q.count < q.capacity })
 
 	// 再次检查队列是否已关闭（等待期间可能已关闭）
 	if atomic.LoadInt32(&q.closed) != 0 {
 		return ErrQueueClosed
 	}
+	if expired {
+		return ErrQueueFull
+	}
+
+	q.pushLocked(item, priority)
+	return nil
+}
+
+// waitLocked 在 cond 上等待，直到 ready 返回 true、队列关闭或 ctx 结束，返回是否因 ctx 结束而停止等待；
+// ctx 为 nil 时不限时。ready 已经满足时即使 ctx 已结束也不算超时。调用方必须持有锁
+func (q *BoundedQueue) waitLocked(ctx context.Context, cond *sync.Cond, ready func() bool) bool {
+	if ready() || atomic.LoadInt32(&q.closed) != 0 {
+		return false
+	}
+	if ctx != nil {
+		// ctx 结束时唤醒所有等待者，由它们各自检查
+		stop := context.AfterFunc(ctx, func() {
+			q.mu.Lock()
+			cond.Broadcast()
+			q.mu.Unlock()
+		})
+		defer stop()
+	}
+	for !ready() && atomic.LoadInt32(&q.closed) == 0 {
+		if ctx != nil && ctx.Err() != nil {
+			return true
+		}
+		cond.Wait()
+	}
+	return false
+}
+
+// pushLocked 将项添加到对应优先级的队尾并通知消费者，调用方必须持有锁且已确认队列未满
+func (q *BoundedQueue) pushLocked(item interface{}, priority int) {
+	r := &q.rings[min(max(priority, 0), len(q.rings)-1)]
 
 	// 添加项到队尾
-	q.items[q.tail] = item
-	q.tail = (q.tail + 1) % q.capacity
+	r.items[r.tail] = item
+	r.tail = (r.tail + 1) % q.capacity
+	r.count++
 	q.count++
 
 	// 增加入队计数
-	atomic.AddInt64(&q.enqueueCount, 1)
+	q.enqueueCount.Inc()
 
 	// 通知等待的消费者
 	q.notEmpty.Signal()
+}
 
-	return nil
+// EnqueueBatch 以最低优先级按顺序添加一批项，每次加锁放入尽可能多的项，队列已满时阻塞等待空位；
+// 返回已入队的项数，队列在中途关闭时返回已入队的项数和 ErrQueueClosed
+func (q *BoundedQueue) EnqueueBatch(items []interface{}) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	n := 0
+	for n < len(items) {
+		for q.count == q.capacity && atomic.LoadInt32(&q.closed) == 0 {
+			q.notFull.Wait()
+		}
+		if atomic.LoadInt32(&q.closed) != 0 {
+			return n, ErrQueueClosed
+		}
+		for ; n < len(items) && q.count < q.capacity; n++ {
+			q.pushLocked(items[n], 0)
+		}
+	}
+	return n, nil
 }
 
-// EnqueueWithTimeout 将项添加到队列，如果队列已满则在超时后返回错误
+// EnqueueWithTimeout 将项添加到队列，如果队列已满则在超时后返回 ErrQueueFull；返回错误时项一定没有入队
 func (q *BoundedQueue) EnqueueWithTimeout(item interface{}, timeout time.Duration) error {
-	timer := time.NewTimer(timeout)
-	defer timer.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return q.enqueue(ctx, item, 0)
+}
 
-	// 创建一个完成通道
-	done := make(chan struct{})
+// Dequeue 从队列中取出项，如果队列为空则阻塞
+func (q *BoundedQueue) Dequeue() (interface{}, error) {
+	return q.dequeue(nil)
+}
 
-	// 使用goroutine尝试入队
-	var enqueueErr error
-	go func() {
-		enqueueErr = q.Enqueue(item)
-		close(done)
-	}()
+// dequeue 等待队列非空后出队；ctx 不为 nil 且在等到数据之前结束时返回 ErrDequeueTimeout
+func (q *BoundedQueue) dequeue(ctx context.Context) (interface{}, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
 
-	// 等待入队完成或超时
-	select {
-	case <-done:
-		return enqueueErr
-	case <-timer.C:
-		// 超时，但goroutine可能仍在尝试入队，无法取消
-		// 如果后续入队成功，数据会被加入队列，这是预期行为
-		return ErrQueueFull
+	// 等待直到队列非空、关闭或 ctx 结束
+	expired := q.waitLocked(ctx, q.notEmpty, func() bool { return q.count > 0 })
+
+	if q.count == 0 {
+		// 如果队列为空且已关闭，返回错误
+		if atomic.LoadInt32(&q.closed) != 0 {
+			return nil, ErrQueueClosed
+		}
+		if expired {
+			return nil, ErrDequeueTimeout
+		}
 	}
+
+	return q.popLocked(), nil
 }
 
-// Dequeue 从队列中取出项，如果队列为空则阻塞
-func (q *BoundedQueue) Dequeue() (interface{}, error) {
+// DequeueUpTo 取出最多 n 个项，队列为空时阻塞到至少有一项；队列已关闭且为空时返回 ErrQueueClosed
+func (q *BoundedQueue) DequeueUpTo(n int) ([]interface{}, error) {
+	if n <= 0 {
+		n = 1
+	}
+
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
-	// 等待直到队列非空或关闭
 	for q.count == 0 && atomic.LoadInt32(&q.closed) == 0 {
 		q.notEmpty.Wait()
 	}
-
-	// 如果队列为空且已关闭，返回错误
-	if q.count == 0 && atomic.LoadInt32(&q.closed) != 0 {
+	if q.count == 0 {
 		return nil, ErrQueueClosed
 	}
 
+	items := make([]interface{}, min(n, q.count))
+	for i := range items {
+		items[i] = q.popLocked()
+	}
+	return items, nil
+}
+
+// popLocked 从最高的非空优先级队头取出项并通知生产者，调用方必须持有锁且已确认队列非空
+func (q *BoundedQueue) popLocked() interface{} {
+	r := &q.rings[len(q.rings)-1]
+	for i := len(q.rings) - 2; r.count == 0; i-- {
+		r = &q.rings[i]
+	}
+
 	// 从队头取出项
-	item := q.items[q.head]
-	q.items[q.head] = nil // 避免内存泄漏
-	q.head = (q.head + 1) % q.capacity
+	item := r.items[r.head]
+	r.items[r.head] = nil // 避免内存泄漏
+	r.head = (r.head + 1) % q.capacity
+	r.count--
 	q.count--
 
 	// 增加出队计数
-	atomic.AddInt64(&q.dequeueCount, 1)
+	q.dequeueCount.Inc()
 
 	// 通知等待的生产者
 	q.notFull.Signal()
 
-	return item, nil
+	return item
 }
 
-// DequeueWithTimeout 从队列中取出项，如果队列为空则在超时后返回错误
+// DequeueWithTimeout 从队列中取出项，如果队列为空则在超时后返回 ErrDequeueTimeout；返回错误时不会有项被取走
 func (q *BoundedQueue) DequeueWithTimeout(timeout time.Duration) (interface{}, error) {
-	timer := time.NewTimer(timeout)
-	defer timer.Stop()
-
-	// 创建一个完成通道
-	done := make(chan struct{})
-
-	// 使用goroutine尝试出队
-	var item interface{}
-	var dequeueErr error
-	go func() {
-		item, dequeueErr = q.Dequeue()
-		close(done)
-	}()
-
-	// 等待出队完成或超时
-	select {
-	case <-done:
-		return item, dequeueErr
-	case <-timer.C:
-		return nil, errors.New("出队超时")
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return q.dequeue(ctx)
 }
 
 // Close 关闭队列，阻止进一步入队，允许已入队的项被出队
@@ -230,11 +324,17 @@ func (q *BoundedQueue) Stats() map[string]interface{} {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
+	levelSizes := make([]int, len(q.rings))
+	for i, r := range q.rings {
+		levelSizes[i] = r.count
+	}
 	return map[string]interface{}{
 		"capacity":     q.capacity,
 		"size":         q.count,
-		"enqueueCount": atomic.LoadInt64(&q.enqueueCount),
-		"dequeueCount": atomic.LoadInt64(&q.dequeueCount),
+		"levelSizes":   levelSizes, // 各优先级的项数，下标为优先级
+		"enqueueCount": q.enqueueCount.Sum(),
+		"dequeueCount": q.dequeueCount.Sum(),
+		"expiredCount": q.expiredCount.Sum(),
 		"closed":       atomic.LoadInt32(&q.closed) != 0,
 	}
 }
@@ -332,4 +432,105 @@ func ProducerConsumerDemo() {
 	fmt.Printf("最终大小: %d\n", stats["size"])
 	fmt.Printf("总入队数: %d\n", stats["enqueueCount"])
 	fmt.Printf("总出队数: %d\n", stats["dequeueCount"])
+
+	// 优先级：告警日志越过积压的普通日志先被处理
+	fmt.Println("\n日志优先级（普通=0，告警=1）:")
+	const (
+		levelNormal = 0
+		levelAlert  = 1
+	)
+	priorityQueue := NewPriorityBoundedQueue(6, 2)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			log, err := priorityQueue.Dequeue()
+			if err != nil {
+				return
+			}
+			fmt.Printf("处理: %s\n", log)
+			time.Sleep(10 * time.Millisecond) // 消费速度慢于生产速度，普通日志积压
+		}
+	}()
+	for i := 1; i <= 8; i++ {
+		priorityQueue.EnqueuePriority(fmt.Sprintf("访问日志-%d", i), levelNormal) // 队列满时阻塞
+		if i == 5 {
+			priorityQueue.EnqueuePriority("告警: 磁盘使用率 95%", levelAlert)
+			fmt.Printf("告警入队时各优先级积压: %v\n", priorityQueue.Stats()["levelSizes"])
+		}
+	}
+	priorityQueue.Close()
+	<-done
+
+	// 批量入队、出队：采集端攒一批再入队，写入端一次取一批
+	const (
+		collectors = 4
+		perWorker  = 50000
+		batchSize  = 64
+	)
+	fmt.Printf("\n日志批量收集（%d个采集协程，每个%d条，队列容量1024）:\n", collectors, perWorker)
+	collect := func(name string, batch int) {
+		queue := NewBoundedQueue(1024)
+		var wg sync.WaitGroup
+		start := time.Now()
+		for i := 0; i < collectors; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if batch == 1 {
+					for j := 0; j < perWorker; j++ {
+						queue.Enqueue(j)
+					}
+					return
+				}
+				buf := make([]interface{}, 0, batch)
+				for j := 0; j < perWorker; j++ {
+					if buf = append(buf, j); len(buf) == batch {
+						queue.EnqueueBatch(buf)
+						buf = buf[:0]
+					}
+				}
+				queue.EnqueueBatch(buf)
+			}()
+		}
+		go func() {
+			wg.Wait()
+			queue.Close()
+		}()
+
+		written, calls := 0, 0
+		for ; ; calls++ {
+			if batch == 1 {
+				if _, err := queue.Dequeue(); err != nil {
+					break
+				}
+				written++
+				continue
+			}
+			items, err := queue.DequeueUpTo(batch)
+			if err != nil {
+				break
+			}
+			written += len(items)
+		}
+		fmt.Printf("%s: 写入 %d 条，出队调用 %d 次，耗时 %v\n", name, written, calls, time.Since(start).Round(time.Millisecond))
+	}
+	collect("逐条", 1)
+	collect(fmt.Sprintf("批量(每批%d条)", batchSize), batchSize)
+
+	// 超时语义：超时返回的入队不会在之后悄悄完成，也不留下协程
+	fmt.Println("\n超时入队、出队:")
+	full := NewBoundedQueue(1)
+	full.Enqueue("日志-1")
+	before := runtime.NumGoroutine()
+	timeouts := 0
+	for i := 0; i < 100; i++ {
+		if err := full.EnqueueWithTimeout(fmt.Sprintf("日志-%d", i+2), time.Millisecond); err == ErrQueueFull {
+			timeouts++
+		}
+	}
+	fmt.Printf("队列已满时 100 次超时入队: 超时 %d 次，协程数变化 %d\n", timeouts, runtime.NumGoroutine()-before)
+	full.Dequeue()
+	_, err := full.DequeueWithTimeout(10 * time.Millisecond)
+	fmt.Printf("取走唯一的日志后，队列中剩余 %d 条，再次超时出队: %v\n", full.Size(), err)
 }
@@ -0,0 +1,235 @@
+package concurrency
+
+/*
+读-复制-更新（RCU，Read-Copy-Update）
+
+原理：
+RCU 是 Linux 内核中为读多写少的数据设计的同步机制。读取者不加锁，只是原子地取得当前版本的指针；
+写入者复制当前版本、在副本上修改，再原子地把指针换成新版本。与写时复制映射（cow_map.go）相比，
+RCU 多了"宽限期"的概念：旧版本被替换后，可能还有读取者正在使用它，
+必须等到替换之前开始的所有读取者都结束（宽限期结束），才能回收旧版本占用的资源。
+Go 有垃圾回收，内存本身不需要手动释放；但版本可能持有内存以外的资源（文件句柄、连接、池化的缓冲区、
+预计算的索引），或者调用方需要知道"已经没有人在用旧数据了"（例如确认旧路网不再被任何导航请求使用后再下线），
+这时就需要宽限期。
+
+关键特点：
+1. 读取者进入读临界区只需要两次原子操作，不会被写入者阻塞，写入者也不会被读取者阻塞
+2. 读取者在整个读临界区内看到同一个不可变的版本，多次读取彼此一致
+3. 写入者之间用互斥锁串行，每次发布一个新版本并递增版本号
+4. 旧版本的最后一个读取者离开时执行回收回调；Synchronize 等待所有已替换版本的宽限期结束
+
+实现方式：
+- atomic.Pointer 保存当前版本，每个版本记录自己的读取者数量和是否已被替换
+- 读取者先增加版本的读取者计数，再检查该版本是否已被替换：已被替换则撤销计数、重新读取当前版本。
+  写入者先标记替换再检查读取者计数，两边都使用原子操作，至少有一方能看到对方的修改，
+  因此不会出现"回收之后还有读取者进入"的情况
+- 被替换的版本读取者计数归零时，由最后离开的读取者（或写入者自己）执行回收，回收只执行一次
+
+应用场景：
+- 路由表、导航路网、配置、黑白名单等读多写少且需要一致快照的数据
+- 旧版本持有需要显式释放的资源
+- 需要确认旧数据已不再被使用的热更新
+
+优缺点：
+- 优点：读取几乎没有开销且随核数扩展；读取者看到一致的快照；回收时机精确
+- 缺点：写入需要复制整个数据结构；读取者长时间不释放会推迟回收，旧版本堆积占用内存；
+  读取者必须成对调用释放函数，遗漏会导致旧版本永远不被回收
+
+以下实现了泛型的 RCU 容器，导航路网在计算路线时被实时路况更新的示例见 graph_algorithms/shortest_path_navigation.go。
+*/
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// rcuVersion RCU 的一个版本
+type rcuVersion[T any] struct {
+	value     *T
+	number    uint64        // 版本号，从1开始
+	readers   int64         // 正在读取该版本的读取者数
+	replaced  atomic.Bool   // 是否已被新版本替换
+	reclaimed atomic.Bool   // 是否已回收
+	done      chan struct{} // 回收后关闭
+}
+
+// RCU 读-复制-更新容器，读取无锁，写入发布新版本，旧版本在宽限期结束后回收
+type RCU[T any] struct {
+	current   atomic.Pointer[rcuVersion[T]]
+	mu        sync.Mutex // 串行化写入者，保护 retiring
+	retiring  map[*rcuVersion[T]]struct{}
+	onReclaim func(old *T, version uint64)
+
+	reads       int64 // 读临界区次数
+	retries     int64 // 读取时遇到已替换版本而重试的次数
+	updates     int64 // 发布的版本数
+	reclaims    int64 // 回收的版本数
+	maxRetiring int64 // 同时等待宽限期结束的版本数的最大值
+}
+
+// NewRCU 创建 RCU 容器，initial 为第一个版本；onReclaim 在旧版本宽限期结束后调用，可以为 nil
+func NewRCU[T any](initial *T, onReclaim func(old *T, version uint64)) *RCU[T] {
+	r := &RCU[T]{retiring: make(map[*rcuVersion[T]]struct{}), onReclaim: onReclaim}
+	r.current.Store(&rcuVersion[T]{value: initial, number: 1, done: make(chan struct{})})
+	return r
+}
+
+// Read 进入读临界区，返回当前版本及其版本号；使用完毕后必须调用 release，之后不能再访问返回的值
+func (r *RCU[T]) Read() (value *T, version uint64, release func()) {
+	atomic.AddInt64(&r.reads, 1)
+	for {
+		v := r.current.Load()
+		atomic.AddInt64(&v.readers, 1)
+		if !v.replaced.Load() {
+			return v.value, v.number, func() { r.leave(v) }
+		}
+		// 读取期间版本被替换：撤销计数，读取新版本
+		atomic.AddInt64(&r.retries, 1)
+		r.leave(v)
+	}
+}
+
+// View 在读临界区内调用 fn，fn 返回后自动离开
+func (r *RCU[T]) View(fn func(value *T, version uint64)) {
+	value, version, release := r.Read()
+	defer release()
+	fn(value, version)
+}
+
+// leave 离开读临界区，已替换版本的最后一个读取者负责回收
+func (r *RCU[T]) leave(v *rcuVersion[T]) {
+	if atomic.AddInt64(&v.readers, -1) == 0 && v.replaced.Load() {
+		r.reclaim(v)
+	}
+}
+
+// Update 复制并修改：fn 收到当前版本，返回新版本（不能修改收到的旧版本），发布后返回新版本号
+func (r *RCU[T]) Update(fn func(old *T) *T) uint64 {
+	r.mu.Lock()
+	old := r.current.Load()
+	next := &rcuVersion[T]{value: fn(old.value), number: old.number + 1, done: make(chan struct{})}
+	r.current.Store(next)
+	old.replaced.Store(true)
+	r.retiring[old] = struct{}{}
+	if n := int64(len(r.retiring)); n > atomic.LoadInt64(&r.maxRetiring) {
+		atomic.StoreInt64(&r.maxRetiring, n)
+	}
+	r.mu.Unlock()
+
+	atomic.AddInt64(&r.updates, 1)
+	// 没有读取者时立即回收
+	if atomic.LoadInt64(&old.readers) == 0 {
+		r.reclaim(old)
+	}
+	return next.number
+}
+
+// Store 直接发布新版本
+func (r *RCU[T]) Store(value *T) uint64 {
+	return r.Update(func(*T) *T { return value })
+}
+
+// reclaim 回收已替换的版本，只执行一次
+func (r *RCU[T]) reclaim(v *rcuVersion[T]) {
+	if !v.reclaimed.CompareAndSwap(false, true) {
+		return
+	}
+	if r.onReclaim != nil {
+		r.onReclaim(v.value, v.number)
+	}
+	atomic.AddInt64(&r.reclaims, 1)
+
+	r.mu.Lock()
+	delete(r.retiring, v)
+	r.mu.Unlock()
+	close(v.done)
+}
+
+// Synchronize 等待当前所有已替换版本的宽限期结束（回收完成），ctx 结束时返回其错误
+func (r *RCU[T]) Synchronize(ctx context.Context) error {
+	r.mu.Lock()
+	waiting := make([]chan struct{}, 0, len(r.retiring))
+	for v := range r.retiring {
+		waiting = append(waiting, v.done)
+	}
+	r.mu.Unlock()
+
+	for _, done := range waiting {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Version 返回当前版本号
+func (r *RCU[T]) Version() uint64 {
+	return r.current.Load().number
+}
+
+// Stats 返回 RCU 容器的统计信息
+func (r *RCU[T]) Stats() map[string]interface{} {
+	r.mu.Lock()
+	retiring := len(r.retiring)
+	r.mu.Unlock()
+	return map[string]interface{}{
+		"version":     r.Version(),
+		"reads":       atomic.LoadInt64(&r.reads),
+		"readRetries": atomic.LoadInt64(&r.retries),
+		"updates":     atomic.LoadInt64(&r.updates),
+		"reclaims":    atomic.LoadInt64(&r.reclaims),
+		"retiring":    retiring,
+		"maxRetiring": atomic.LoadInt64(&r.maxRetiring),
+	}
+}
+
+// 场景示例：限流规则热更新，确认旧规则不再被任何请求使用后才下线
+func RCUDemo() {
+	fmt.Println("RCU 示例（限流规则热更新）:")
+
+	type rules struct {
+		limits map[string]int
+	}
+	table := NewRCU(&rules{limits: map[string]int{"/api/order": 100, "/api/user": 200}},
+		func(old *rules, version uint64) {
+			fmt.Printf("  规则版本 %d 的宽限期结束，已无请求使用，可以下线\n", version)
+		})
+
+	// 一个慢请求在读临界区内停留较久，期间规则被更新两次
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		table.View(func(r *rules, version uint64) {
+			before := r.limits["/api/order"]
+			time.Sleep(30 * time.Millisecond)
+			fmt.Printf("  慢请求在版本 %d 上两次读取 /api/order 的限额: %d, %d（同一快照内保持一致）\n",
+				version, before, r.limits["/api/order"])
+		})
+	}()
+	time.Sleep(5 * time.Millisecond)
+
+	for _, limit := range []int{80, 50} {
+		version := table.Update(func(old *rules) *rules {
+			limits := make(map[string]int, len(old.limits))
+			for k, v := range old.limits {
+				limits[k] = v
+			}
+			limits["/api/order"] = limit
+			return &rules{limits: limits}
+		})
+		fmt.Printf("发布规则版本 %d: /api/order 限额 %d\n", version, limit)
+	}
+
+	table.View(func(r *rules, version uint64) {
+		fmt.Printf("新请求读取版本 %d: /api/order 限额 %d\n", version, r.limits["/api/order"])
+	})
+	table.Synchronize(context.Background())
+	wg.Wait()
+	fmt.Printf("统计: %v\n", table.Stats())
+}
@@ -0,0 +1,332 @@
+package concurrency
+
+/*
+失败重试与退避策略（Retry）
+
+原理：
+调用下游服务、写数据库时的很多失败是暂时的：网络抖动、下游限流、主从切换、锁冲突，稍等一会儿再试就能成功。
+立即重试往往又撞上同一个问题，所以两次尝试之间要等待（退避）。
+退避的方式决定了重试对下游的压力：固定间隔最简单；指数退避让间隔逐次翻倍，下游故障越久重试越稀疏；
+大量客户端同时失败时，相同的退避序列会让它们在同一时刻一起重试，形成一波波的请求尖峰，
+去相关抖动（decorrelated jitter）在上一次等待时间的基础上随机选取下一次的等待时间，把重试打散到不同时刻。
+并不是所有错误都值得重试：参数错误、余额不足这类确定性错误重试多少次都一样，应当立即放弃。
+
+关键特点：
+1. RetryPolicy 指定最多执行次数、退避策略、初始等待时间、增长倍数和上限，零值字段使用默认值
+2. 三种退避策略：固定间隔、指数退避、去相关抖动
+3. 错误分类：RetryIf 判断错误是否值得重试，Permanent 包装的错误总是不重试
+4. Retry 在调用方协程中同步执行，退避期间上下文取消时立即返回；失败时返回 RetryError，记录执行次数、最后一次错误和放弃原因
+5. 协程池的 SubmitWithRetry 使用同一套策略（见 pool_retry.go），退避期间不占用工作协程
+
+实现方式：
+- 指数退避：等待时间 = InitialBackoff × Multiplier^(第几次失败-1)，不超过 MaxBackoff
+- 去相关抖动：等待时间在 [InitialBackoff, 上一次等待时间×3) 中随机选取，不超过 MaxBackoff
+- Permanent 返回的错误实现了 Is 方法，errors.Is(err, ErrNotRetryable) 为 true，包装在其他错误里也能识别
+- RetryError 的 Unwrap 同时返回最后一次错误和放弃原因，两者都可以用 errors.Is 判断
+- 退避用 time.Timer 等待，与上下文的 Done 通道一起 select
+
+应用场景：
+- 调用不稳定的下游服务、第三方接口
+- 数据库写入冲突、乐观锁失败后的重试
+- 异地容灾中向备份数据中心复制失败后的退避（见 practical_applications/replication_queue.go）
+
+优缺点：
+- 优点：暂时性故障自动恢复；抖动避免重试风暴；确定性错误立即失败，不浪费时间
+- 缺点：重试会放大下游压力，下游整体故障时应配合熔断；非幂等的操作重试前要确认可以重复执行；
+  同步重试期间调用方一直阻塞
+
+以下实现了可配置退避策略的 Retry 函数，以及调用不稳定的库存服务的示例。
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// BackoffStrategy 两次尝试之间等待时间的计算方式
+type BackoffStrategy int
+
+const (
+	BackoffExponential        BackoffStrategy = iota // 指数退避
+	BackoffConstant                                  // 固定间隔
+	BackoffDecorrelatedJitter                        // 去相关抖动
+)
+
+// String 返回策略名称
+func (s BackoffStrategy) String() string {
+	switch s {
+	case BackoffExponential:
+		return "指数退避"
+	case BackoffConstant:
+		return "固定间隔"
+	case BackoffDecorrelatedJitter:
+		return "去相关抖动"
+	default:
+		return "未知策略"
+	}
+}
+
+var (
+	// ErrNotRetryable 错误不值得重试，Permanent 包装的错误与它匹配
+	ErrNotRetryable = errors.New("错误不可重试")
+	// ErrRetryExhausted 执行次数已经用完
+	ErrRetryExhausted = errors.New("重试次数已用完")
+)
+
+// permanentError 不应重试的错误
+type permanentError struct {
+	err error
+}
+
+// Error 实现 error 接口
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap 返回被包装的错误
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Is 与 ErrNotRetryable 匹配
+func (e *permanentError) Is(target error) bool {
+	return target == ErrNotRetryable
+}
+
+// Permanent 把 err 标记为不可重试，重试策略遇到它时立即放弃；err 为 nil 时返回 nil
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// RetryError Retry 放弃时返回的错误
+type RetryError struct {
+	Attempts int   // 已执行次数
+	Err      error // 最后一次执行返回的错误，执行前上下文就已取消时为 nil
+	Reason   error // 放弃的原因：ErrRetryExhausted、ErrNotRetryable 或上下文的错误
+}
+
+// Error 实现 error 接口
+func (e *RetryError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("执行 %d 次后放弃: %v", e.Attempts, e.Reason)
+	}
+	return fmt.Sprintf("执行 %d 次后放弃(%v): %v", e.Attempts, e.Reason, e.Err)
+}
+
+// Unwrap 返回最后一次的错误和放弃原因
+func (e *RetryError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Reason}
+	}
+	return []error{e.Err, e.Reason}
+}
+
+// RetryPolicy 失败后的重试策略
+type RetryPolicy struct {
+	MaxAttempts    int                                               // 最多执行次数（包括第一次），小于等于1表示不重试
+	Strategy       BackoffStrategy                                   // 退避策略，零值为指数退避
+	InitialBackoff time.Duration                                     // 第一次失败后的等待时间，也是各策略等待时间的下限
+	MaxBackoff     time.Duration                                     // 等待时间上限
+	Multiplier     float64                                           // 指数退避时每次失败后等待时间的增长倍数
+	RetryIf        func(error) bool                                  // 判断错误是否值得重试，为 nil 时除 Permanent 以外的错误（包括 panic）都重试
+	OnRetry        func(attempt int, err error, delay time.Duration) // 第 attempt 次执行失败、等待 delay 后重试前调用，可以为 nil
+}
+
+// DefaultRetryPolicy 默认的重试策略：最多执行3次，等待 100ms、200ms
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	Strategy:       BackoffExponential,
+	InitialBackoff: 100 * time.Millisecond,
+	MaxBackoff:     5 * time.Second,
+	Multiplier:     2,
+}
+
+// normalize 把未设置或无效的字段替换为默认值
+func (r RetryPolicy) normalize() RetryPolicy {
+	if r.MaxAttempts <= 0 {
+		r.MaxAttempts = DefaultRetryPolicy.MaxAttempts
+	}
+	if r.InitialBackoff <= 0 {
+		r.InitialBackoff = DefaultRetryPolicy.InitialBackoff
+	}
+	if r.MaxBackoff <= 0 {
+		r.MaxBackoff = DefaultRetryPolicy.MaxBackoff
+	}
+	if r.MaxBackoff < r.InitialBackoff {
+		r.MaxBackoff = r.InitialBackoff
+	}
+	if r.Multiplier < 1 {
+		r.Multiplier = DefaultRetryPolicy.Multiplier
+	}
+	return r
+}
+
+// Backoff 返回第 attempt 次执行失败后的等待时间，MaxBackoff 小于等于0时不设上限；
+// 去相关抖动的等待时间是随机的，返回的是可能的最大值
+func (r RetryPolicy) Backoff(attempt int) time.Duration {
+	var backoff float64
+	switch r.Strategy {
+	case BackoffConstant:
+		backoff = float64(r.InitialBackoff)
+	case BackoffDecorrelatedJitter:
+		backoff = float64(r.InitialBackoff) * math.Pow(3, float64(max(attempt, 1)))
+	default:
+		backoff = float64(r.InitialBackoff) * math.Pow(r.Multiplier, float64(max(attempt-1, 0)))
+	}
+	if r.MaxBackoff > 0 && backoff > float64(r.MaxBackoff) {
+		return r.MaxBackoff
+	}
+	return time.Duration(backoff)
+}
+
+// NextBackoff 返回第 attempt 次执行失败后实际的等待时间，prev 是上一次的等待时间（第一次失败时为0）；
+// 只有去相关抖动依赖 prev，其他策略与 Backoff 相同
+func (r RetryPolicy) NextBackoff(attempt int, prev time.Duration) time.Duration {
+	if r.Strategy != BackoffDecorrelatedJitter {
+		return r.Backoff(attempt)
+	}
+	prev = max(prev, r.InitialBackoff)
+	delay := r.InitialBackoff
+	if upper := prev * 3; upper > r.InitialBackoff {
+		delay += rand.N(upper - r.InitialBackoff)
+	}
+	if r.MaxBackoff > 0 && delay > r.MaxBackoff {
+		return r.MaxBackoff
+	}
+	return delay
+}
+
+// retryable 判断错误是否值得重试
+func (r *RetryPolicy) retryable(err error) bool {
+	if errors.Is(err, ErrNotRetryable) {
+		return false
+	}
+	return r.RetryIf == nil || r.RetryIf(err)
+}
+
+// shouldRetry 判断第 attempt 次执行返回 err 后是否重试，r 为 nil 时不重试
+func (r *RetryPolicy) shouldRetry(attempt int, err error) bool {
+	if r == nil || attempt >= r.MaxAttempts {
+		return false
+	}
+	return r.retryable(err)
+}
+
+// Retry 按策略执行 fn 直到成功，成功时返回 nil；未设置的策略字段使用默认值。
+// 错误不值得重试、执行次数用完或上下文取消时返回 *RetryError；fn 中的 panic 不会被恢复
+func Retry(ctx context.Context, policy RetryPolicy, fn func(ctx context.Context) error) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	policy = policy.normalize()
+	if err := ctx.Err(); err != nil {
+		return &RetryError{Attempts: 0, Reason: err}
+	}
+
+	var delay time.Duration
+	for attempt := 1; ; attempt++ {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+		if !policy.retryable(err) {
+			return &RetryError{Attempts: attempt, Err: err, Reason: ErrNotRetryable}
+		}
+		if attempt >= policy.MaxAttempts {
+			return &RetryError{Attempts: attempt, Err: err, Reason: ErrRetryExhausted}
+		}
+
+		delay = policy.NextBackoff(attempt, delay)
+		if policy.OnRetry != nil {
+			policy.OnRetry(attempt, err, delay)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return &RetryError{Attempts: attempt, Err: err, Reason: ctx.Err()}
+		case <-timer.C:
+		}
+	}
+}
+
+// 场景示例：下单时调用库存服务扣减库存，库存服务偶尔限流，库存不足时重试没有意义
+func RetryDemo() {
+	fmt.Println("失败重试示例 (调用库存服务):")
+
+	// 三种策略的等待时间序列
+	for _, strategy := range []BackoffStrategy{BackoffConstant, BackoffExponential, BackoffDecorrelatedJitter} {
+		policy := RetryPolicy{Strategy: strategy, InitialBackoff: 10 * time.Millisecond, MaxBackoff: 200 * time.Millisecond}.normalize()
+		var delays []time.Duration
+		var delay time.Duration
+		for attempt := 1; attempt <= 6; attempt++ {
+			delay = policy.NextBackoff(attempt, delay)
+			delays = append(delays, delay.Round(time.Millisecond))
+		}
+		fmt.Printf("  %s: %v\n", strategy, delays)
+	}
+
+	errThrottled := errors.New("库存服务限流")
+	errOutOfStock := errors.New("库存不足")
+	// deduct 返回扣减库存的调用，前 failures 次返回 err
+	deduct := func(failures int, err error) (func(context.Context) error, *int) {
+		calls := 0
+		return func(context.Context) error {
+			calls++
+			if calls <= failures {
+				return err
+			}
+			return nil
+		}, &calls
+	}
+
+	policy := RetryPolicy{
+		MaxAttempts:    4,
+		Strategy:       BackoffDecorrelatedJitter,
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     100 * time.Millisecond,
+		OnRetry: func(attempt int, err error, delay time.Duration) {
+			fmt.Printf("    第 %d 次失败: %v，等待 %v 后重试\n", attempt, err, delay.Round(time.Millisecond))
+		},
+	}
+
+	// 限流两次后成功
+	fmt.Println("\n订单1（限流两次）:")
+	call, calls := deduct(2, errThrottled)
+	err := Retry(context.Background(), policy, call)
+	fmt.Printf("  结果: %v，调用 %d 次\n", err, *calls)
+
+	// 库存不足是确定性错误，用 Permanent 标记后立即放弃
+	fmt.Println("\n订单2（库存不足）:")
+	call, calls = deduct(10, Permanent(errOutOfStock))
+	err = Retry(context.Background(), policy, call)
+	fmt.Printf("  结果: %v，调用 %d 次\n", err, *calls)
+	fmt.Printf("  是库存不足: %v，是不可重试: %v\n", errors.Is(err, errOutOfStock), errors.Is(err, ErrNotRetryable))
+
+	// 一直限流，执行次数用完
+	fmt.Println("\n订单3（持续限流）:")
+	call, calls = deduct(10, errThrottled)
+	err = Retry(context.Background(), policy, call)
+	var retryErr *RetryError
+	if errors.As(err, &retryErr) {
+		fmt.Printf("  执行 %d 次，最后的错误: %v，原因: %v\n", retryErr.Attempts, retryErr.Err, retryErr.Reason)
+	}
+	fmt.Printf("  是重试用完: %v，是限流: %v\n", errors.Is(err, ErrRetryExhausted), errors.Is(err, errThrottled))
+
+	// 下单接口的超时先到，退避期间放弃
+	fmt.Println("\n订单4（下单接口超时 30ms，固定间隔 50ms）:")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	call, calls = deduct(10, errThrottled)
+	start := time.Now()
+	err = Retry(ctx, RetryPolicy{MaxAttempts: 5, Strategy: BackoffConstant, InitialBackoff: 50 * time.Millisecond}, call)
+	cancel()
+	fmt.Printf("  结果: %v，调用 %d 次，耗时约 %v\n", err, *calls, time.Since(start).Round(10*time.Millisecond))
+	fmt.Printf("  是超时: %v\n", errors.Is(err, context.DeadlineExceeded))
+}
@@ -12,10 +12,16 @@ package concurrency
 2. 写入者必须等待所有读取者释放锁
 3. 读取者必须等待写入者释放锁
 4. 防止写入者饥饿（即优先处理等待的写入者）
+5. 支持非阻塞的 TryLock、TryRLock 和带超时的 LockWithTimeout，拿不到锁时调用方可以降级处理而不是一直阻塞
+6. 支持锁降级：DowngradeToRLock 把持有的写锁原子地转换为读锁，中间不会有其他写入者插入，
+   写入者可以继续读取自己刚写入的数据，同时允许其他读取者并发读取
+7. 可选的死锁检测：EnableDeadlockDetection 后，即将阻塞时检查循环等待，能发现重入读锁、重复加写锁和交叉加锁（见 deadlock_detector.go）
 
 实现方式：
 - 使用两个锁(读锁和写锁)和计数器跟踪读取者和写入者
 - 使用条件变量进行等待和通知
+- 条件变量不支持超时，LockWithTimeout 用定时器在到期时唤醒等待的写入者，由它自己检查是否已超时；
+  超时放弃的写入者如果是最后一个等待者，需要唤醒被它挡住的读取者
 
 应用场景：
 - 并发读取、偶尔写入的数据结构
@@ -40,11 +46,13 @@ import (
 type CustomRWMutex struct {
 	mu            sync.Mutex // 保护内部状态的互斥锁
 	readerCount   int32      // 当前持有读锁的数量
-	writerWaiting int32      // 等待写锁的标志（0无等待，1有等待）
+	writerWaiting int32      // 等待写锁的写入者数
 	writerActive  int32      // 活跃写锁的标志（0无活跃，1有活跃）
 
 	readerCond *sync.Cond // 读取者条件变量
 	writerCond *sync.Cond // 写入者条件变量
+
+	deadlock *lockTracker // 死锁检测，未启用时为 nil
 }
 
 // NewCustomRWMutex 创建新的自定义读写锁
@@ -55,6 +63,11 @@ func NewCustomRWMutex() *CustomRWMutex {
 	return rw
 }
 
+// EnableDeadlockDetection 启用死锁检测，由 detector 跟踪这把锁的持有和等待关系；需要在使用锁之前调用
+func (rw *CustomRWMutex) EnableDeadlockDetection(detector *DeadlockDetector, name string) {
+	rw.deadlock = detector.track(name, lockRW)
+}
+
 // RLock 获取读锁
 func (rw *CustomRWMutex) RLock() {
 	// 先获取互斥锁，以便安全检查和修改内部状态
@@ -62,12 +75,19 @@ func (rw *CustomRWMutex) RLock() {
 
 	// 如果有写入者等待或活跃，读取者需要等待
 	// 这样可以防止写入者饥饿
+	if atomic.LoadInt32(&rw.writerWaiting) > 0 || atomic.LoadInt32(&rw.writerActive) > 0 {
+		if err := rw.deadlock.beforeWait(false); err != nil {
+			rw.mu.Unlock()
+			panic(err)
+		}
+	}
 	for atomic.LoadInt32(&rw.writerWaiting) > 0 || atomic.LoadInt32(&rw.writerActive) > 0 {
 		rw.readerCond.Wait()
 	}
 
 	// 增加读取者计数
 	atomic.AddInt32(&rw.readerCount, 1)
+	rw.deadlock.acquired(false)
 
 	rw.mu.Unlock()
 }
@@ -82,6 +102,7 @@ func (rw *CustomRWMutex) RUnlock() {
 		panic("RUnlock called without a preceding RLock")
 	}
 
+	rw.deadlock.released(false)
 	if atomic.AddInt32(&rw.readerCount, -1) == 0 {
 		// 如果没有读取者了，通知等待的写入者
 		rw.writerCond.Signal()
@@ -95,20 +116,128 @@ func (rw *CustomRWMutex) Lock() {
 	rw.mu.Lock()
 
 	// 标记有写入者等待
-	atomic.StoreInt32(&rw.writerWaiting, 1)
+	atomic.AddInt32(&rw.writerWaiting, 1)
 
 	// 等待直到没有读取者和其他写入者
+	if atomic.LoadInt32(&rw.readerCount) > 0 || atomic.LoadInt32(&rw.writerActive) > 0 {
+		if err := rw.deadlock.beforeWait(true); err != nil {
+			rw.abandonWriteLocked()
+			rw.mu.Unlock()
+			panic(err)
+		}
+	}
 	for atomic.LoadInt32(&rw.readerCount) > 0 || atomic.LoadInt32(&rw.writerActive) > 0 {
 		rw.writerCond.Wait()
 	}
 
-	// 标记有活跃的写入者，并清除等待标志
+	// 标记有活跃的写入者，并减少等待计数
 	atomic.StoreInt32(&rw.writerActive, 1)
-	atomic.StoreInt32(&rw.writerWaiting, 0)
+	atomic.AddInt32(&rw.writerWaiting, -1)
+	rw.deadlock.acquired(true)
 
 	rw.mu.Unlock()
 }
 
+// abandonWriteLocked 等待写锁的写入者放弃等待；没有其他写入者在等待时，唤醒被它挡住的读取者，
+// 还有写入者在等待且锁已空闲时，把可能被自己消耗掉的唤醒信号转交给下一个写入者；调用方需持有 mu
+func (rw *CustomRWMutex) abandonWriteLocked() {
+	waiting := atomic.AddInt32(&rw.writerWaiting, -1)
+	if atomic.LoadInt32(&rw.writerActive) != 0 {
+		return
+	}
+	if waiting == 0 {
+		rw.readerCond.Broadcast()
+	} else if atomic.LoadInt32(&rw.readerCount) == 0 {
+		// 放弃的写入者可能刚好消耗了 Unlock 发给写入者的 Signal，锁已空闲时把信号转交给下一个写入者
+		rw.writerCond.Signal()
+	}
+}
+
+// DowngradeToRLock 把持有的写锁原子地转换为读锁，之后需要调用 RUnlock 释放。
+// 转换在同一次加锁内完成，其他写入者没有机会在写锁释放和读锁获取之间插入
+func (rw *CustomRWMutex) DowngradeToRLock() {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	if atomic.LoadInt32(&rw.writerActive) == 0 {
+		panic("DowngradeToRLock called without a preceding Lock")
+	}
+	atomic.AddInt32(&rw.readerCount, 1)
+	atomic.StoreInt32(&rw.writerActive, 0)
+	rw.deadlock.downgraded()
+
+	// 没有写入者等待时，其他读取者可以和降级后的读锁共享；
+	// 有写入者等待时读取者继续等待，写入者要等到降级后的读锁释放
+	if atomic.LoadInt32(&rw.writerWaiting) == 0 {
+		rw.readerCond.Broadcast()
+	}
+}
+
+// TryLock 尝试获取写锁，有读取者、写入者持有锁或有写入者在等待时立即返回false
+func (rw *CustomRWMutex) TryLock() bool {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	if atomic.LoadInt32(&rw.readerCount) > 0 || atomic.LoadInt32(&rw.writerActive) > 0 ||
+		atomic.LoadInt32(&rw.writerWaiting) > 0 {
+		return false
+	}
+	atomic.StoreInt32(&rw.writerActive, 1)
+	rw.deadlock.acquired(true)
+	return true
+}
+
+// TryRLock 尝试获取读锁，有写入者持有或等待写锁时立即返回false
+func (rw *CustomRWMutex) TryRLock() bool {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	if atomic.LoadInt32(&rw.writerWaiting) > 0 || atomic.LoadInt32(&rw.writerActive) > 0 {
+		return false
+	}
+	atomic.AddInt32(&rw.readerCount, 1)
+	rw.deadlock.acquired(false)
+	return true
+}
+
+// LockWithTimeout 在指定时间内获取写锁，超时返回false；等待期间同样会阻止新的读取者进入
+func (rw *CustomRWMutex) LockWithTimeout(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	atomic.AddInt32(&rw.writerWaiting, 1)
+	// 条件变量没有超时等待，到期时唤醒所有写入者，各自检查条件
+	timer := time.AfterFunc(timeout, func() {
+		rw.mu.Lock()
+		rw.writerCond.Broadcast()
+		rw.mu.Unlock()
+	})
+	defer timer.Stop()
+
+	if atomic.LoadInt32(&rw.readerCount) > 0 || atomic.LoadInt32(&rw.writerActive) > 0 {
+		if err := rw.deadlock.beforeWait(true); err != nil {
+			rw.abandonWriteLocked()
+			panic(err) // 由 defer 释放 mu
+		}
+	}
+	for atomic.LoadInt32(&rw.readerCount) > 0 || atomic.LoadInt32(&rw.writerActive) > 0 {
+		if !time.Now().Before(deadline) {
+			// 放弃等待；没有其他写入者在等待时，唤醒被挡住的读取者
+			rw.abandonWriteLocked()
+			rw.deadlock.giveUp()
+			return false
+		}
+		rw.writerCond.Wait()
+	}
+
+	atomic.StoreInt32(&rw.writerActive, 1)
+	atomic.AddInt32(&rw.writerWaiting, -1)
+	rw.deadlock.acquired(true)
+	return true
+}
+
 // Unlock 释放写锁
 func (rw *CustomRWMutex) Unlock() {
 	rw.mu.Lock()
@@ -121,6 +250,7 @@ func (rw *CustomRWMutex) Unlock() {
 
 	// 清除活跃写入者标志
 	atomic.StoreInt32(&rw.writerActive, 0)
+	rw.deadlock.released(true)
 
 	// 优先唤醒等待的写入者，否则唤醒所有读取者
 	if atomic.LoadInt32(&rw.writerWaiting) > 0 {
@@ -247,4 +377,70 @@ func CustomRWMutexDemo() {
 	for key, value := range config.GetAll() {
 		fmt.Printf("%s = %v\n", key, value)
 	}
+
+	// 降级处理：拿不到锁时不阻塞
+	fmt.Println("\n拿不到锁时的降级处理:")
+	snapshot := config.GetAll() // 本地快照，读锁不可用时使用
+
+	// 配置中心推送大批量更新，长时间持有写锁
+	config.mu.Lock()
+	if config.mu.TryRLock() {
+		config.mu.RUnlock()
+	} else {
+		fmt.Printf("写锁被占用，TryRLock 失败，读取本地快照: database.host = %v\n", snapshot["database.host"])
+	}
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		config.mu.Unlock()
+	}()
+	start := time.Now()
+	if config.mu.LockWithTimeout(30 * time.Millisecond) {
+		config.mu.Unlock()
+	} else {
+		fmt.Printf("管理后台等待写锁 %v 后放弃，提示稍后重试\n", time.Since(start).Round(10*time.Millisecond))
+	}
+	if config.mu.LockWithTimeout(200 * time.Millisecond) {
+		config.data["feature.flag"] = true
+		config.mu.Unlock()
+		fmt.Printf("再次尝试在 %v 后获得写锁并完成更新\n", time.Since(start).Round(10*time.Millisecond))
+	}
+	if config.mu.TryLock() {
+		config.mu.Unlock()
+		fmt.Println("锁空闲时 TryLock 立即成功")
+	}
+
+	// 锁降级：写入新版本后降级为读锁校验，期间其他写入者不能修改，读取者可以并发读取
+	fmt.Println("\n锁降级:")
+	config.mu.Lock()
+	config.data["app.version"] = "2.0"
+	config.mu.DowngradeToRLock()
+
+	var events []string
+	var eventsMu sync.Mutex
+	addEvent := func(e string) {
+		eventsMu.Lock()
+		events = append(events, e)
+		eventsMu.Unlock()
+	}
+	done := make(chan struct{})
+	go func() {
+		config.mu.Lock() // 等到校验结束
+		config.data["app.version"] = "2.1"
+		addEvent("其他写入者更新版本")
+		config.mu.Unlock()
+		done <- struct{}{}
+	}()
+	time.Sleep(10 * time.Millisecond)
+	if !config.mu.TryRLock() {
+		// 有写入者在等待，新的读取者不插队
+		addEvent("新读取者让位给等待的写入者")
+	} else {
+		config.mu.RUnlock()
+	}
+	addEvent(fmt.Sprintf("校验刚写入的版本: %v", config.data["app.version"]))
+	config.mu.RUnlock()
+	<-done
+	for _, e := range events {
+		fmt.Println(e)
+	}
 }
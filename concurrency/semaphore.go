@@ -11,15 +11,21 @@ package concurrency
 1. 计数器维护可用资源数量
 2. 支持阻塞操作（当计数器为0时，请求资源的线程会被阻塞）
 3. 支持超时获取资源
-4. 支持资源的公平分配（可选）
+4. 支持资源的公平分配（可选）：公平模式下等待者严格按到达顺序获得资源，
+   有人排队时 TryAcquire 不会插队，Stats 可以看到队列长度以及每个等待者的到达序号、排队位置和已等待时间，
+   AcquireTicket 在排队时把到达序号告诉调用方，之后可以用 Position 查询它当前排在第几位
+5. 可选的死锁检测：EnableDeadlockDetection 后，即将阻塞时检查是否与其他被跟踪的锁形成循环等待（见 deadlock_detector.go）
 
 实现方式：
 - 使用互斥锁和条件变量实现基本的同步机制
 - 使用通道（channel）实现信号量行为
 - 提供带超时的资源获取方法
+- 公平模式不使用令牌通道，而是维护可用数量和等待队列（链表），每个等待者有自己的通知通道；
+  释放资源时直接把资源交给队首等待者再关闭它的通道，新来的协程拿不到这个资源。
+  等待者超时离开队列时，如果此时有可用资源，会继续唤醒排在它后面的等待者
 
 应用场景：
-- 限制对数据库连接的并发访问
+- 限制对数据库连接的并发访问（对象池 ObjectPool 用它控制借出的连接数，见 object_pool.go）
 - 控制对物理资源（如打印机）的访问
 - 实现并发限制和速率限制
 - 保护共享内存区域
@@ -28,30 +34,71 @@ package concurrency
 - 优点：简单有效的并发控制机制，可防止资源耗尽
 - 缺点：可能导致死锁，使用不当会影响性能
 
-以下实现了一个计数信号量，支持阻塞和超时获取资源。
+以下实现了一个计数信号量，支持阻塞和超时获取资源，以及按到达顺序分配资源的公平模式。
 */
 
 import (
+	"container/list"
 	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// SemaphoreOptions 信号量选项
+type SemaphoreOptions struct {
+	Fair bool // 公平模式：等待者按到达顺序获得资源
+}
+
+// DefaultSemaphoreOptions 默认的信号量选项（非公平）
+var DefaultSemaphoreOptions = SemaphoreOptions{}
+
 // Semaphore 计数信号量
 type Semaphore struct {
 	capacity int           // 信号量容量（最大可用资源数）
-	tokens   chan struct{} // 表示可用资源的令牌通道
+	tokens   chan struct{} // 表示可用资源的令牌通道，公平模式下为 nil
 	mu       sync.Mutex    // 用于保护内部状态的互斥锁
 	waiting  int           // 当前等待获取资源的协程数
 	acquired int           // 当前已获取资源的协程数
+
+	// 以下字段只在公平模式下使用
+	fair      bool
+	available int        // 可用资源数
+	queue     *list.List // 按到达顺序排列的 *semWaiter
+	arrivals  uint64     // 已排队的等待者数，用作到达序号
+
+	deadlock *lockTracker // 死锁检测，未启用时为 nil
+}
+
+// SemaphoreWaiter 公平模式下一个等待者的排队情况
+type SemaphoreWaiter struct {
+	Ticket   uint64        // 到达序号
+	Position int           // 当前排在第几位，队首为1
+	Wait     time.Duration // 已等待的时间
+}
+
+// semWaiter 公平模式下排队的等待者
+type semWaiter struct {
+	ticket   uint64        // 到达序号，从1开始
+	since    time.Time     // 开始排队的时间
+	ready    chan struct{} // 获得资源时关闭
+	acquired bool          // 是否已获得资源，由持有 mu 的一方修改
 }
 
 // NewSemaphore 创建新的信号量
 func NewSemaphore(capacity int) *Semaphore {
+	return NewSemaphoreWithOptions(capacity, DefaultSemaphoreOptions)
+}
+
+// NewSemaphoreWithOptions 按选项创建信号量
+func NewSemaphoreWithOptions(capacity int, options SemaphoreOptions) *Semaphore {
 	if capacity <= 0 {
 		capacity = 1
 	}
+	if options.Fair {
+		return &Semaphore{capacity: capacity, fair: true, available: capacity, queue: list.New()}
+	}
 
 	// 创建一个带缓冲的通道作为令牌桶
 	tokens := make(chan struct{}, capacity)
@@ -69,28 +116,66 @@ func NewSemaphore(capacity int) *Semaphore {
 	}
 }
 
+// EnableDeadlockDetection 启用死锁检测，由 detector 跟踪信号量的持有和等待关系；需要在使用信号量之前调用
+func (s *Semaphore) EnableDeadlockDetection(detector *DeadlockDetector, name string) {
+	s.deadlock = detector.track(name, lockSemaphore)
+}
+
 // Acquire 获取一个资源，如果没有可用资源则阻塞
 func (s *Semaphore) Acquire() {
+	if s.fair {
+		s.acquireFair(context.Background(), nil)
+		return
+	}
 	s.mu.Lock()
 	s.waiting++
 	s.mu.Unlock()
 
 	// 从令牌通道获取一个令牌（阻塞操作）
-	<-s.tokens
+	select {
+	case <-s.tokens:
+	default:
+		s.beforeWait()
+		<-s.tokens
+	}
 
 	s.mu.Lock()
 	s.waiting--
 	s.acquired++
 	s.mu.Unlock()
+	s.deadlock.acquired(false)
+}
+
+// beforeWait 非公平模式下即将阻塞等待令牌时检查死锁，检测到死锁时撤销等待计数并 panic
+func (s *Semaphore) beforeWait() {
+	if err := s.deadlock.beforeWait(false); err != nil {
+		s.mu.Lock()
+		s.waiting--
+		s.mu.Unlock()
+		panic(err)
+	}
 }
 
 // TryAcquire 尝试获取一个资源，如果没有可用资源则立即返回false
 func (s *Semaphore) TryAcquire() bool {
+	if s.fair {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		// 有人排队时不插队
+		if s.available == 0 || s.queue.Len() > 0 {
+			return false
+		}
+		s.available--
+		s.acquired++
+		s.deadlock.acquired(false)
+		return true
+	}
 	select {
 	case <-s.tokens:
 		s.mu.Lock()
 		s.acquired++
 		s.mu.Unlock()
+		s.deadlock.acquired(false)
 		return true
 	default:
 		return false
@@ -107,6 +192,10 @@ func (s *Semaphore) AcquireWithTimeout(timeout time.Duration) bool {
 
 // AcquireWithContext 尝试在上下文取消前获取资源
 func (s *Semaphore) AcquireWithContext(ctx context.Context) bool {
+	if s.fair {
+		_, ok := s.acquireFair(ctx, nil)
+		return ok
+	}
 	s.mu.Lock()
 	s.waiting++
 	s.mu.Unlock()
@@ -114,26 +203,122 @@ func (s *Semaphore) AcquireWithContext(ctx context.Context) bool {
 	// 尝试在上下文取消前获取令牌
 	select {
 	case <-s.tokens:
-		s.mu.Lock()
-		s.waiting--
+	default:
+		if s.deadlock != nil && ctx.Err() == nil {
+			s.beforeWait()
+		}
+		select {
+		case <-s.tokens:
+		case <-ctx.Done():
+			s.mu.Lock()
+			s.waiting--
+			s.mu.Unlock()
+			s.deadlock.giveUp()
+			return false
+		}
+	}
+	s.mu.Lock()
+	s.waiting--
+	s.acquired++
+	s.mu.Unlock()
+	s.deadlock.acquired(false)
+	return true
+}
+
+// AcquireTicket 在上下文取消前获取资源，返回排队时分配的到达序号，没有排队就获得资源时为0；
+// 需要排队时先在等待期间以到达序号调用 queued（可以为 nil），调用方据此用 Position 查询排队位置。
+// 非公平模式没有等待队列，不调用 queued，到达序号始终为0。上下文结束前没有获得资源时返回 ctx.Err()
+func (s *Semaphore) AcquireTicket(ctx context.Context, queued func(ticket uint64)) (uint64, error) {
+	if !s.fair {
+		if !s.AcquireWithContext(ctx) {
+			return 0, ctx.Err()
+		}
+		return 0, nil
+	}
+	ticket, ok := s.acquireFair(ctx, queued)
+	if !ok {
+		return ticket, ctx.Err()
+	}
+	return ticket, nil
+}
+
+// acquireFair 公平模式下获取资源：没有人排队且有可用资源时直接获得，否则排到队尾等待；
+// 返回排队时的到达序号（没有排队时为0）以及是否获得资源，排队后以到达序号调用 queued
+func (s *Semaphore) acquireFair(ctx context.Context, queued func(ticket uint64)) (uint64, bool) {
+	s.mu.Lock()
+	if s.available > 0 && s.queue.Len() == 0 {
+		s.available--
 		s.acquired++
 		s.mu.Unlock()
-		return true
+		s.deadlock.acquired(false)
+		return 0, true
+	}
+	if err := s.deadlock.beforeWait(false); err != nil {
+		s.mu.Unlock()
+		panic(err)
+	}
+	s.arrivals++
+	w := &semWaiter{ticket: s.arrivals, since: time.Now(), ready: make(chan struct{})}
+	elem := s.queue.PushBack(w)
+	s.waiting++
+	s.mu.Unlock()
+	if queued != nil {
+		queued(w.ticket)
+	}
+
+	select {
+	case <-w.ready:
+		s.deadlock.acquired(false)
+		return w.ticket, true
 	case <-ctx.Done():
-		s.mu.Lock()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if w.acquired {
+		// 上下文结束的同时已经被分配了资源，当作获取成功
+		s.deadlock.acquired(false)
+		return w.ticket, true
+	}
+	s.deadlock.giveUp()
+	s.queue.Remove(elem)
+	s.waiting--
+	// 离开的等待者可能正挡在队首，有可用资源时唤醒后面的等待者
+	s.grantLocked()
+	return w.ticket, false
+}
+
+// grantLocked 把可用资源按到达顺序分配给队首的等待者，调用方需持有 mu
+func (s *Semaphore) grantLocked() {
+	for s.available > 0 && s.queue.Len() > 0 {
+		w := s.queue.Remove(s.queue.Front()).(*semWaiter)
+		w.acquired = true
+		s.available--
 		s.waiting--
-		s.mu.Unlock()
-		return false
+		s.acquired++
+		close(w.ready)
 	}
 }
 
 // Release 释放一个资源
 func (s *Semaphore) Release() {
+	if s.fair {
+		s.mu.Lock()
+		if s.acquired > 0 {
+			s.acquired--
+			s.available++
+			s.deadlock.released(false)
+			s.grantLocked()
+		}
+		s.mu.Unlock()
+		return
+	}
 	s.mu.Lock()
 	// 只有在已获取资源的情况下才释放
 	if s.acquired > 0 {
 		s.acquired--
 		s.mu.Unlock()
+		s.deadlock.released(false)
 		// 将令牌放回通道
 		s.tokens <- struct{}{}
 	} else {
@@ -143,49 +328,127 @@ func (s *Semaphore) Release() {
 
 // AvailablePermits 返回当前可用的资源数量
 func (s *Semaphore) AvailablePermits() int {
+	if s.fair {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.available
+	}
 	return len(s.tokens)
 }
 
-// Stats 返回信号量的统计信息
+// Position 返回到达序号为 ticket（由 AcquireTicket 得到）的等待者当前排在第几位（队首为1）；
+// 非公平模式、该等待者已获得资源或已离开队列时返回 false
+func (s *Semaphore) Position(ticket uint64) (int, bool) {
+	if !s.fair {
+		return 0, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	position := 1
+	for e := s.queue.Front(); e != nil; e = e.Next() {
+		if e.Value.(*semWaiter).ticket == ticket {
+			return position, true
+		}
+		position++
+	}
+	return 0, false
+}
+
+// Stats 返回信号量的统计信息；公平模式下还包括队列长度、队首等待者的到达序号和等待时间，
+// 以及 waiters：按排队顺序列出的每个等待者（[]SemaphoreWaiter）
 func (s *Semaphore) Stats() map[string]interface{} {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	return map[string]interface{}{
-		"capacity":  s.capacity,
-		"available": len(s.tokens),
-		"acquired":  s.acquired,
-		"waiting":   s.waiting,
+	if !s.fair {
+		return map[string]interface{}{
+			"capacity":  s.capacity,
+			"available": len(s.tokens),
+			"acquired":  s.acquired,
+			"waiting":   s.waiting,
+			"fair":      false,
+		}
+	}
+	stats := map[string]interface{}{
+		"capacity":    s.capacity,
+		"available":   s.available,
+		"acquired":    s.acquired,
+		"waiting":     s.waiting,
+		"fair":        true,
+		"queueLength": s.queue.Len(),
+		"arrivals":    s.arrivals,
+		"queueHead":   uint64(0), // 队首等待者的到达序号，队列为空时为0
+		"headWait":    time.Duration(0),
 	}
+	if front := s.queue.Front(); front != nil {
+		w := front.Value.(*semWaiter)
+		stats["queueHead"] = w.ticket
+		stats["headWait"] = time.Since(w.since)
+	}
+	waiters := make([]SemaphoreWaiter, 0, s.queue.Len())
+	for e := s.queue.Front(); e != nil; e = e.Next() {
+		w := e.Value.(*semWaiter)
+		waiters = append(waiters, SemaphoreWaiter{Ticket: w.ticket, Position: len(waiters) + 1, Wait: time.Since(w.since)})
+	}
+	stats["waiters"] = waiters
+	return stats
+}
+
+// dbConn 示例中的数据库连接
+type dbConn struct {
+	id      int
+	queries int  // 该连接上执行过的查询数
+	broken  bool // 模拟被服务端断开
 }
 
 // 场景示例：模拟数据库连接池
 func SemaphoreDemo() {
 	fmt.Println("数据库连接池场景（信号量使用示例）:")
 
-	// 创建一个容量为3的信号量，模拟最多3个并发数据库连接
-	dbConnPool := NewSemaphore(3)
+	// 对象池内部用公平信号量限制最多3个并发连接，借到的是真实的连接对象而不只是一个许可
+	var nextID int64
+	dbConnPool := NewObjectPool(func(ctx context.Context) (*dbConn, error) {
+		conn := &dbConn{id: int(atomic.AddInt64(&nextID, 1))}
+		fmt.Printf("  建立新连接 #%d\n", conn.id)
+		return conn, nil
+	}, ObjectPoolOptions[*dbConn]{
+		MaxActive: 3,
+		MaxIdle:   2,
+		Validate: func(conn *dbConn) error {
+			if conn.broken {
+				return fmt.Errorf("连接 #%d 已断开", conn.id)
+			}
+			return nil
+		},
+		Destroy: func(conn *dbConn) {
+			fmt.Printf("  关闭连接 #%d（执行过 %d 次查询）\n", conn.id, conn.queries)
+		},
+	})
+	defer dbConnPool.Close()
 
 	// 模拟数据库查询
 	queryDB := func(id int, query string, duration time.Duration) {
 		fmt.Printf("客户端 %d: 尝试获取数据库连接执行查询: %s\n", id, query)
 
 		// 尝试获取连接，超时时间为500毫秒
-		acquired := dbConnPool.AcquireWithTimeout(time.Millisecond * 500)
-		if !acquired {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*500)
+		defer cancel()
+		conn, err := dbConnPool.Borrow(ctx)
+		if err != nil {
 			fmt.Printf("客户端 %d: 获取连接超时，查询失败: %s\n", id, query)
 			return
 		}
 
 		// 成功获取连接
-		fmt.Printf("客户端 %d: 成功获取连接，执行查询: %s\n", id, query)
+		fmt.Printf("客户端 %d: 成功获取连接 #%d（第 %d 次借出），执行查询: %s\n", id, conn.Value.id, conn.Borrows(), query)
 
 		// 模拟查询执行时间
 		time.Sleep(duration)
+		conn.Value.queries++
 
-		// 释放连接
-		dbConnPool.Release()
-		fmt.Printf("客户端 %d: 查询完成，释放连接: %s\n", id, query)
+		// 归还连接
+		dbConnPool.Return(conn)
+		fmt.Printf("客户端 %d: 查询完成，归还连接 #%d: %s\n", id, conn.Value.id, query)
 	}
 
 	// 启动多个客户端并发请求数据库连接
@@ -222,11 +485,70 @@ func SemaphoreDemo() {
 	// 等待所有查询完成
 	wg.Wait()
 
+	// 空闲连接被服务端断开后，借用时的健康检查会剔除它并换一个可用的连接
+	fmt.Println("\n模拟服务端断开一个空闲连接:")
+	conn, err := dbConnPool.Borrow(context.Background())
+	if err == nil {
+		conn.Value.broken = true
+		dbConnPool.Return(conn)
+		if conn, err = dbConnPool.Borrow(context.Background()); err == nil {
+			fmt.Printf("健康检查后借到连接 #%d\n", conn.Value.id)
+			dbConnPool.Return(conn)
+		}
+	}
+
 	// 显示最终统计信息
 	stats := dbConnPool.Stats()
 	fmt.Println("\n连接池统计:")
-	fmt.Printf("总容量: %d\n", stats["capacity"])
-	fmt.Printf("可用连接: %d\n", stats["available"])
-	fmt.Printf("已获取连接: %d\n", stats["acquired"])
+	fmt.Printf("总容量: %d\n", stats["maxActive"])
+	fmt.Printf("空闲连接: %d\n", stats["idle"])
+	fmt.Printf("借出中的连接: %d\n", stats["borrowedNow"])
 	fmt.Printf("等待连接: %d\n", stats["waiting"])
+	fmt.Printf("新建 %d 个连接，复用 %d 次，健康检查失败 %d 次，等待超时 %d 次\n",
+		stats["created"], stats["reused"], stats["failedChecks"], stats["waitTimeouts"])
+
+	// 公平模式：报表任务长时间占用唯一的导出通道，后来的请求严格按到达顺序获得通道
+	fmt.Println("\n公平模式（导出通道容量为1）:")
+	exporter := NewSemaphoreWithOptions(1, SemaphoreOptions{Fair: true})
+	exporter.Acquire() // 报表任务占用通道
+	var orderMu sync.Mutex
+	var order []string
+	tickets := make(map[string]uint64)
+	for i := 1; i <= 4; i++ {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			exporter.AcquireTicket(context.Background(), func(ticket uint64) {
+				orderMu.Lock()
+				tickets[name] = ticket
+				orderMu.Unlock()
+			})
+			orderMu.Lock()
+			order = append(order, name)
+			orderMu.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			exporter.Release()
+		}(fmt.Sprintf("用户-%d", i))
+		time.Sleep(2 * time.Millisecond) // 保证到达顺序
+	}
+	// 超时离开队列的等待者不影响其他人的顺序
+	impatient := exporter.AcquireWithTimeout(5 * time.Millisecond)
+	fmt.Printf("没有耐心的用户等待5ms: 获得通道=%v\n", impatient)
+	fmt.Printf("有人排队时 TryAcquire: %v\n", exporter.TryAcquire())
+
+	fairStats := exporter.Stats()
+	fmt.Printf("排队中: %d 人，队首是第 %d 个到达的，已等待 %v\n",
+		fairStats["queueLength"], fairStats["queueHead"], fairStats["headWait"].(time.Duration).Round(time.Millisecond))
+	for _, w := range fairStats["waiters"].([]SemaphoreWaiter) {
+		fmt.Printf("  第%d位: 第 %d 个到达，已等待 %v\n", w.Position, w.Ticket, w.Wait.Round(time.Millisecond))
+	}
+	orderMu.Lock()
+	ticket := tickets["用户-3"]
+	orderMu.Unlock()
+	if position, ok := exporter.Position(ticket); ok {
+		fmt.Printf("用户-3 的到达序号是 %d，排在第 %d 位\n", ticket, position)
+	}
+	exporter.Release() // 报表任务完成
+	wg.Wait()
+	fmt.Printf("获得通道的顺序: %v\n", order)
 }
@@ -0,0 +1,63 @@
+package concurrency
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+)
+
+// 并发到达的等待者各自拿到自己的到达序号，Position 按到达顺序返回排队位置
+func TestSemaphoreAcquireTicketPosition(t *testing.T) {
+	s := NewSemaphoreWithOptions(1, SemaphoreOptions{Fair: true})
+	if ticket, err := s.AcquireTicket(context.Background(), nil); err != nil || ticket != 0 {
+		t.Fatalf("有可用资源时应直接获得，到达序号 %d，错误 %v", ticket, err)
+	}
+
+	const n = 3
+	queued := make(chan uint64, n)
+	acquired := make(chan uint64, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			ticket, err := s.AcquireTicket(context.Background(), func(ticket uint64) { queued <- ticket })
+			if err != nil {
+				t.Errorf("AcquireTicket 失败: %v", err)
+			}
+			acquired <- ticket
+		}()
+	}
+
+	tickets := make([]uint64, 0, n)
+	for i := 0; i < n; i++ {
+		tickets = append(tickets, <-queued)
+	}
+	sort.Slice(tickets, func(i, j int) bool { return tickets[i] < tickets[j] })
+	for i, ticket := range tickets {
+		if position, ok := s.Position(ticket); !ok || position != i+1 {
+			t.Errorf("到达序号 %d 排在第 %d 位（在队列中 %v），期望第 %d 位", ticket, position, ok, i+1)
+		}
+	}
+
+	// 按到达顺序获得资源，获得后不再在队列中
+	for i := 0; i < n; i++ {
+		s.Release()
+		select {
+		case ticket := <-acquired:
+			if ticket != tickets[i] {
+				t.Errorf("第 %d 个获得资源的到达序号为 %d，期望 %d", i+1, ticket, tickets[i])
+			}
+			if _, ok := s.Position(ticket); ok {
+				t.Errorf("到达序号 %d 获得资源后仍在队列中", ticket)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("释放资源后等待者没有获得资源")
+		}
+	}
+
+	// 超时离开队列时返回上下文的错误
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if ticket, err := s.AcquireTicket(ctx, nil); err != context.DeadlineExceeded || ticket == 0 {
+		t.Errorf("超时离开时到达序号 %d，错误 %v，期望非0序号和 DeadlineExceeded", ticket, err)
+	}
+}
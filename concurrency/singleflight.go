@@ -0,0 +1,212 @@
+package concurrency
+
+/*
+SingleFlight - 并发重复调用合并
+
+原理：
+同一时刻对同一个键的多次相同调用（查询同一个用户、加载同一个缓存键、读取同一个文件）结果必然相同，
+逐个执行只会给下游带来成倍的压力。SingleFlight 记录每个键正在进行的调用，
+第一个到达的协程执行函数，之后到达的协程不再执行，而是等待并共享这次调用的结果。
+调用结束后记录被删除，之后的调用会重新执行，所以它合并的是"同时"发生的调用，而不是缓存结果。
+
+关键特点：
+1. 按键合并，不同键的调用互不影响、可以并行
+2. Do 返回 shared 标记，说明结果是否与其他协程共享（共享的结果是同一个值，引用类型不要修改）
+3. Forget 让某个键之后的调用不再等待正在进行的那次调用，而是发起新的调用，
+   适合调用可能卡住、或者已知正在进行的调用会返回过时数据的场景
+4. panic 传播：函数 panic 时，执行者和所有等待者都以同一个 PanicError 重新 panic，
+   不会有等待者永远阻塞，也不会把 panic 伪装成普通错误吞掉
+5. 函数调用 runtime.Goexit 时，等待者收到 ErrSingleFlightGoexit
+
+实现方式：
+- 互斥锁保护键到调用记录的映射，调用记录中的通道在调用结束时关闭
+- 执行函数时用 defer 处理正常返回、panic 和 Goexit 三种结束方式，保证通道一定关闭、记录一定删除
+- Forget 只删除映射中的记录，正在进行的调用照常完成并把结果交给已经在等待的协程
+
+应用场景：
+- 缓存未命中时的回源合并（防止缓存击穿），cache_strategies 的 LoadGroup 基于它实现
+- KV 存储、配置中心等对同一个键的并发读取
+- 令牌刷新、服务发现等"多个协程同时发现需要刷新"的场景
+
+优缺点：
+- 优点：把 N 次并发调用降为1次，对调用方透明
+- 缺点：等待者的延迟取决于那一次调用，调用卡住时所有等待者一起卡住（可以配合 Forget 或超时）；
+  结果被共享，调用方不能修改返回的引用类型
+
+以下实现了泛型的 SingleFlight，以及热点用户资料查询的示例。
+*/
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrSingleFlightGoexit 执行的函数调用了 runtime.Goexit，没有返回结果
+var ErrSingleFlightGoexit = errors.New("合并调用的函数调用了 runtime.Goexit")
+
+// flightCall 一次正在进行或已完成的调用
+type flightCall[V any] struct {
+	done    chan struct{} // 调用结束时关闭
+	value   V
+	err     error
+	panic   *PanicError // 函数 panic 时不为 nil
+	waiters int32       // 共享本次结果的等待者数
+}
+
+// SingleFlight 按键合并并发的重复调用，零值可直接使用
+type SingleFlight[K comparable, V any] struct {
+	mu         sync.Mutex
+	calls      map[K]*flightCall[V]
+	executions int64 // 实际执行的次数
+	shared     int64 // 共享了其他调用结果的次数
+}
+
+// Do 执行 key 对应的 fn 并返回结果；同一个键已有调用在进行时等待它的结果，shared 为 true 表示结果被多个调用方共享。
+// fn panic 时，执行者和所有等待者都以同一个 *PanicError 重新 panic
+func (g *SingleFlight[K, V]) Do(key K, fn func() (V, error)) (value V, err error, shared bool) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[K]*flightCall[V])
+	}
+	if call, ok := g.calls[key]; ok {
+		atomic.AddInt32(&call.waiters, 1)
+		g.mu.Unlock()
+		atomic.AddInt64(&g.shared, 1)
+		<-call.done
+		if call.panic != nil {
+			panic(call.panic)
+		}
+		return call.value, call.err, true
+	}
+	call := &flightCall[V]{done: make(chan struct{})}
+	g.calls[key] = call
+	g.mu.Unlock()
+	atomic.AddInt64(&g.executions, 1)
+
+	g.call(key, call, fn)
+	if call.panic != nil {
+		panic(call.panic)
+	}
+	return call.value, call.err, atomic.LoadInt32(&call.waiters) > 0
+}
+
+// call 执行 fn 并记录结果；无论正常返回、panic 还是 Goexit，都会删除记录并唤醒等待者
+func (g *SingleFlight[K, V]) call(key K, call *flightCall[V], fn func() (V, error)) {
+	normalReturn, recovered := false, false
+	defer func() {
+		if !normalReturn && !recovered {
+			// 既没有正常返回也没有 panic，只能是 Goexit
+			call.err = ErrSingleFlightGoexit
+		}
+		g.mu.Lock()
+		// Forget 之后同一个键可能已经有了新的调用，只删除自己的记录
+		if g.calls[key] == call {
+			delete(g.calls, key)
+		}
+		g.mu.Unlock()
+		close(call.done)
+	}()
+
+	func() {
+		defer func() {
+			if !normalReturn {
+				if r := recover(); r != nil {
+					recovered = true
+					call.panic = newPanicError(r)
+				}
+			}
+		}()
+		call.value, call.err = fn()
+		normalReturn = true
+	}()
+}
+
+// Forget 忘记 key 正在进行的调用，之后对该键的 Do 会发起新的调用；已经在等待的协程仍然得到原调用的结果
+func (g *SingleFlight[K, V]) Forget(key K) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.calls, key)
+}
+
+// Stats 返回合并调用的统计信息
+func (g *SingleFlight[K, V]) Stats() map[string]interface{} {
+	g.mu.Lock()
+	inFlight := len(g.calls)
+	g.mu.Unlock()
+	return map[string]interface{}{
+		"executions": atomic.LoadInt64(&g.executions),
+		"shared":     atomic.LoadInt64(&g.shared),
+		"inFlight":   inFlight,
+	}
+}
+
+// 场景示例：热门主播开播时大量观众同时请求主播资料，资料服务只被调用一次
+func SingleFlightDemo() {
+	fmt.Println("SingleFlight 示例 (热门主播资料查询):")
+
+	var group SingleFlight[string, string]
+	var serviceCalls int64
+	fetchProfile := func(id string) (string, error) {
+		atomic.AddInt64(&serviceCalls, 1)
+		time.Sleep(30 * time.Millisecond)
+		return "主播资料:" + id, nil
+	}
+
+	var wg sync.WaitGroup
+	var sharedCount int64
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, shared := group.Do("anchor-42", func() (string, error) { return fetchProfile("anchor-42") })
+			if shared {
+				atomic.AddInt64(&sharedCount, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	fmt.Printf("100 个并发请求: 资料服务调用 %d 次，%d 个请求拿到共享结果\n", serviceCalls, sharedCount)
+
+	// Forget：资料服务卡住时，忘记这次调用，后来的请求发起新的调用而不是一起卡住
+	stuck := make(chan struct{})
+	go group.Do("anchor-7", func() (string, error) {
+		<-stuck
+		return "过时的资料", nil
+	})
+	time.Sleep(10 * time.Millisecond)
+	group.Forget("anchor-7")
+	profile, _, _ := group.Do("anchor-7", func() (string, error) { return "最新的资料", nil })
+	fmt.Printf("Forget 之后的请求: %s\n", profile)
+	close(stuck)
+
+	// panic 传播：执行者和等待者都收到同一个 PanicError，没有人永远等待
+	start := make(chan struct{})
+	results := make(chan string, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			defer func() {
+				var panicErr *PanicError
+				if err, ok := recover().(error); ok && errors.As(err, &panicErr) {
+					results <- fmt.Sprintf("%v", panicErr.Value)
+				}
+			}()
+			group.Do("anchor-13", func() (string, error) {
+				<-start
+				var profile map[string]string
+				profile["name"] = "缺少初始化" // 写入 nil map 导致 panic
+				return "", nil
+			})
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(start)
+	for i := 0; i < 3; i++ {
+		fmt.Printf("调用方 %d 收到 panic: %s\n", i+1, <-results)
+	}
+
+	stats := group.Stats()
+	fmt.Printf("统计: 执行 %d 次，共享 %d 次，进行中 %d 个\n", stats["executions"], stats["shared"], stats["inFlight"])
+}
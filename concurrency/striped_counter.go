@@ -0,0 +1,156 @@
+package concurrency
+
+/*
+分段原子计数器（StripedCounter）
+
+原理：
+限流器的请求数、队列的入队数这类统计计数器在每次请求时都要加1，却很少被读取。
+所有协程对同一个 int64 做 atomic.AddInt64 时，这个变量所在的缓存行要在各个核之间轮流独占，
+核数越多，每次加1等待缓存行的时间越长，计数器本身成为热点。
+分段计数器把计数分散到多个单元，每个单元独占一个缓存行，不同核上的协程大概率写不同的单元，互不干扰；
+读取时把所有单元相加得到总数。这与 Java 的 LongAdder 思路相同，用读取变慢换取写入的可扩展性。
+
+关键特点：
+1. 单元数为不小于 GOMAXPROCS 的2的幂，大致每个 P 对应一个单元
+2. Add 随机选择一个单元原子累加，随机数取自运行时每个线程独立的生成器，本身没有共享状态
+3. 每个单元填充到64字节，避免相邻单元落在同一个缓存行上（伪共享）
+4. Sum 不是原子快照：并发累加时返回的是读取各单元期间某个近似值，静止时精确
+5. 零值可直接使用，单元在第一次 Add 时分配，适合直接作为结构体字段；使用后不能复制
+
+实现方式：
+- atomic.Pointer 保存单元数组，第一次使用时通过 CAS 安装，并发初始化时只有一个数组生效
+- math/rand/v2 的全局函数选择单元
+- Reset 把各单元依次置0，与并发的 Add 同时进行时不保证结果为0
+
+应用场景：
+- 限流器的请求数、通过数、被限制数
+- 队列、协程池的入队、出队、过期计数
+- 写远多于读的各类指标计数
+
+优缺点：
+- 优点：高并发累加时吞吐量随核数扩展；对调用方与普通计数器用法相同
+- 缺点：每个计数器占用 64×单元数 字节；Sum 需要遍历所有单元，比读取单个 int64 慢；
+  单核或并发很低时比单个原子变量多了选择单元的开销
+
+以下实现了零值可用的分段原子计数器，以及与单个原子变量的对比示例。
+*/
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// counterCell 计数器单元，填充到一个缓存行
+type counterCell struct {
+	value int64
+	_     [56]byte
+}
+
+// StripedCounter 分段原子计数器，零值可直接使用，使用后不能复制
+type StripedCounter struct {
+	cells atomic.Pointer[[]counterCell]
+}
+
+// NewStripedCounter 创建分段原子计数器，等价于使用零值
+func NewStripedCounter() *StripedCounter {
+	return &StripedCounter{}
+}
+
+// load 返回单元数组，第一次调用时分配
+func (c *StripedCounter) load() []counterCell {
+	if cells := c.cells.Load(); cells != nil {
+		return *cells
+	}
+	size := 1
+	for size < runtime.GOMAXPROCS(0) {
+		size <<= 1
+	}
+	cells := make([]counterCell, size)
+	if !c.cells.CompareAndSwap(nil, &cells) {
+		// 其他协程已经安装了单元数组
+		return *c.cells.Load()
+	}
+	return cells
+}
+
+// Add 把计数增加 delta
+func (c *StripedCounter) Add(delta int64) {
+	cells := c.load()
+	if len(cells) == 1 {
+		atomic.AddInt64(&cells[0].value, delta)
+		return
+	}
+	atomic.AddInt64(&cells[rand.Uint32()&uint32(len(cells)-1)].value, delta)
+}
+
+// Inc 把计数加1
+func (c *StripedCounter) Inc() {
+	c.Add(1)
+}
+
+// Sum 返回各单元之和；并发累加时是近似值
+func (c *StripedCounter) Sum() int64 {
+	cells := c.cells.Load()
+	if cells == nil {
+		return 0
+	}
+	var sum int64
+	for i := range *cells {
+		sum += atomic.LoadInt64(&(*cells)[i].value)
+	}
+	return sum
+}
+
+// Reset 把计数清零；与并发的 Add 同时进行时不保证结果为0
+func (c *StripedCounter) Reset() {
+	cells := c.cells.Load()
+	if cells == nil {
+		return
+	}
+	for i := range *cells {
+		atomic.StoreInt64(&(*cells)[i].value, 0)
+	}
+}
+
+// 场景示例：网关每个请求都要累加访问计数，对比单个原子变量和分段计数器
+func StripedCounterDemo() {
+	fmt.Println("分段原子计数器示例 (网关访问计数):")
+
+	const (
+		workers   = 8
+		perWorker = 200000
+	)
+	measure := func(add func()) time.Duration {
+		var wg sync.WaitGroup
+		start := time.Now()
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < perWorker; i++ {
+					add()
+				}
+			}()
+		}
+		wg.Wait()
+		return time.Since(start)
+	}
+
+	var single int64
+	singleTime := measure(func() { atomic.AddInt64(&single, 1) })
+	var striped StripedCounter
+	stripedTime := measure(striped.Inc)
+
+	fmt.Printf("%d 个协程各累加 %d 次，GOMAXPROCS=%d，分段数 %d\n",
+		workers, perWorker, runtime.GOMAXPROCS(0), len(striped.load()))
+	fmt.Printf("单个原子变量: 结果 %d，耗时 %v\n", single, singleTime.Round(time.Millisecond))
+	fmt.Printf("分段计数器:   结果 %d，耗时 %v\n", striped.Sum(), stripedTime.Round(time.Millisecond))
+	fmt.Println("单核时分段没有收益；核数越多，单个原子变量的缓存行争用越严重，分段计数器的优势越明显")
+
+	striped.Reset()
+	fmt.Printf("Reset 后: %d\n", striped.Sum())
+}
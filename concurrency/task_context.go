@@ -0,0 +1,366 @@
+package concurrency
+
+/*
+任务上下文（TaskContext）传播
+
+原理：
+在多阶段的并发处理流程中（协程池 → 队列 → 流水线各阶段），一个请求往往会被拆分成多个任务，
+在不同的goroutine中执行。如果每个阶段都各自创建上下文，截止时间、取消信号和追踪信息就会在阶段之间丢失。
+TaskContext 把 context.Context 与追踪ID（TraceID/SpanID）和业务元数据打包在一起，
+随任务一同提交、入队、出队，使得整个链路可以被统一取消和追踪。
+
+关键特点：
+1. 实现了 context.Context 接口，可以直接传给任何接受上下文的函数
+2. 携带 TraceID（整条链路共享）和 SpanID（每个阶段独立），支持父子关系
+3. 携带键值对形式的元数据（如用户ID、请求来源）
+4. 截止时间和取消信号在协程池、有界队列和流水线之间自动传播
+
+实现方式：
+- 内部包装一个标准 context.Context，截止时间/取消由标准库负责
+- Child() 派生子上下文：沿用 TraceID，生成新的 SpanID，记录父 SpanID
+- 协程池在执行任务前检查上下文是否已取消，取消的任务不会执行
+- 有界队列使用 TaskEnvelope 包装入队项，出队时丢弃已过期的任务
+
+应用场景：
+- 多阶段请求处理链路的端到端超时控制
+- 分布式追踪的本地模拟
+- 在后台任务中传递用户身份、租户等元数据
+
+优缺点：
+- 优点：统一的取消和追踪语义，避免各阶段重复传参
+- 缺点：每个任务多一次包装，元数据拷贝有少量开销
+
+以下实现了 TaskContext 及其在协程池和有界队列上的集成。
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/strive/scenario/tracing"
+)
+
+// ErrTaskCanceled 任务在执行前上下文已取消或超时
+var ErrTaskCanceled = errors.New("任务上下文已取消")
+
+// taskContextKey 用于在标准 context 中存取 TaskContext 的键
+type taskContextKey struct{}
+
+// TaskContext 携带截止时间、追踪ID和元数据的任务上下文
+type TaskContext struct {
+	ctx          context.Context    // 底层标准上下文
+	cancel       context.CancelFunc // 取消函数
+	TraceID      string             // 链路追踪ID，整条链路共享
+	SpanID       string             // 当前阶段的SpanID
+	ParentSpanID string             // 父阶段的SpanID（根上下文为空）
+	metadata     map[string]string  // 元数据
+	mu           sync.RWMutex       // 保护元数据
+}
+
+// NewTaskContext 基于父上下文创建一个新的根任务上下文（生成新的TraceID）
+func NewTaskContext(parent context.Context) *TaskContext {
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	return newRootTaskContext(ctx, cancel)
+}
+
+// NewTaskContextWithTimeout 创建带超时的根任务上下文
+func NewTaskContextWithTimeout(parent context.Context, timeout time.Duration) *TaskContext {
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	return newRootTaskContext(ctx, cancel)
+}
+
+// newRootTaskContext 包装已创建的标准上下文，生成新的TraceID
+func newRootTaskContext(ctx context.Context, cancel context.CancelFunc) *TaskContext {
+	return &TaskContext{
+		ctx:      ctx,
+		cancel:   cancel,
+		TraceID:  tracing.NewID(16),
+		SpanID:   tracing.NewID(8),
+		metadata: make(map[string]string),
+	}
+}
+
+// Child 派生子上下文：沿用TraceID，生成新的SpanID，并拷贝元数据
+func (tc *TaskContext) Child() *TaskContext {
+	ctx, cancel := context.WithCancel(tc.ctx)
+	child := &TaskContext{
+		ctx:          ctx,
+		cancel:       cancel,
+		TraceID:      tc.TraceID,
+		SpanID:       tracing.NewID(8),
+		ParentSpanID: tc.SpanID,
+		metadata:     tc.AllMetadata(),
+	}
+	return child
+}
+
+// handoff 返回与 tc 属于同一个 Span（TraceID、SpanID、父 SpanID 和元数据相同）、但截止时间和取消信号来自 parent 的上下文。
+// tc 用完即取消时，用它把调用链交给下游；返回值的 Cancel 不起作用，取消应通过 parent 进行
+func (tc *TaskContext) handoff(parent *TaskContext) *TaskContext {
+	return &TaskContext{
+		ctx:          parent.ctx,
+		cancel:       func() {},
+		TraceID:      tc.TraceID,
+		SpanID:       tc.SpanID,
+		ParentSpanID: tc.ParentSpanID,
+		metadata:     tc.AllMetadata(),
+	}
+}
+
+// Deadline 实现 context.Context 接口
+func (tc *TaskContext) Deadline() (time.Time, bool) {
+	return tc.ctx.Deadline()
+}
+
+// Done 实现 context.Context 接口
+func (tc *TaskContext) Done() <-chan struct{} {
+	return tc.ctx.Done()
+}
+
+// Err 实现 context.Context 接口
+func (tc *TaskContext) Err() error {
+	return tc.ctx.Err()
+}
+
+// Value 实现 context.Context 接口
+func (tc *TaskContext) Value(key interface{}) interface{} {
+	if _, ok := key.(taskContextKey); ok {
+		return tc
+	}
+	return tc.ctx.Value(key)
+}
+
+// Cancel 取消该上下文及其所有子上下文
+func (tc *TaskContext) Cancel() {
+	tc.cancel()
+}
+
+// SetMetadata 设置元数据
+func (tc *TaskContext) SetMetadata(key, value string) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	tc.metadata[key] = value
+}
+
+// Metadata 读取元数据
+func (tc *TaskContext) Metadata(key string) (string, bool) {
+	tc.mu.RLock()
+	defer tc.mu.RUnlock()
+	value, ok := tc.metadata[key]
+	return value, ok
+}
+
+// AllMetadata 返回元数据的拷贝
+func (tc *TaskContext) AllMetadata() map[string]string {
+	tc.mu.RLock()
+	defer tc.mu.RUnlock()
+	result := make(map[string]string, len(tc.metadata))
+	for k, v := range tc.metadata {
+		result[k] = v
+	}
+	return result
+}
+
+// String 返回便于日志输出的描述
+func (tc *TaskContext) String() string {
+	meta := tc.AllMetadata()
+	keys := make([]string, 0, len(meta))
+	for k := range meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+meta[k])
+	}
+	return fmt.Sprintf("trace=%s span=%s parent=%s meta={%s}",
+		shortID(tc.TraceID), shortID(tc.SpanID), shortID(tc.ParentSpanID), strings.Join(pairs, ","))
+}
+
+// shortID 截取ID的前8位，便于打印
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+// ContextWithTaskContext 将 TaskContext 放入标准上下文中
+func ContextWithTaskContext(ctx context.Context, tc *TaskContext) context.Context {
+	return context.WithValue(ctx, taskContextKey{}, tc)
+}
+
+// TaskContextFromContext 从标准上下文中取出 TaskContext
+func TaskContextFromContext(ctx context.Context) (*TaskContext, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	tc, ok := ctx.Value(taskContextKey{}).(*TaskContext)
+	return tc, ok
+}
+
+// ContextTask 接收任务上下文的任务
+type ContextTask func(tc *TaskContext) error
+
+// SubmitWithContext 提交携带任务上下文的任务
+// 队列已满时会阻塞，直到有空位、协程池关闭或上下文取消；
+// 任务真正执行前会再次检查上下文，已取消的任务不会执行，并计入取消统计
+func (p *GoroutinePool) SubmitWithContext(tc *TaskContext, task ContextTask) error {
+	if err := tc.Err(); err != nil {
+		atomic.AddInt32(&p.canceledCount, 1)
+		return ErrTaskCanceled
+	}
+	if atomic.LoadInt32(&p.running) == 0 {
+		return ErrPoolClosed
+	}
+
+	err := p.enqueue(tc, contextTask(tc, task), PriorityNormal)
+	if errors.Is(err, ErrTaskCanceled) {
+		atomic.AddInt32(&p.canceledCount, 1)
+	}
+	return err
+}
+
+// contextTask 把 ContextTask 包装为协程池任务：执行前上下文已取消时不执行并返回 ErrTaskCanceled，
+// 协程池把它计入取消统计，不计入成功或失败
+func contextTask(tc *TaskContext, task ContextTask) GoroutineTask {
+	return func(context.Context) error {
+		if tc.Err() != nil {
+			return ErrTaskCanceled
+		}
+		return task(tc)
+	}
+}
+
+// TaskEnvelope 携带任务上下文的队列项
+type TaskEnvelope struct {
+	Ctx  *TaskContext // 任务上下文
+	Item interface{}  // 实际数据
+}
+
+// EnqueueWithContext 携带任务上下文入队
+// 队列已满时阻塞，直到有空位、队列关闭或上下文取消
+func (q *BoundedQueue) EnqueueWithContext(tc *TaskContext, item interface{}) error {
+	if q.IsClosed() {
+		return ErrQueueClosed
+	}
+	if tc.Err() != nil {
+		return ErrTaskCanceled
+	}
+	// 等待空位期间上下文结束时 enqueue 返回 ErrQueueFull
+	if err := q.enqueue(tc, &TaskEnvelope{Ctx: tc, Item: item}, 0); err != ErrQueueFull {
+		return err
+	}
+	return ErrTaskCanceled
+}
+
+// DequeueWithContext 出队并拆出任务上下文，已过期的任务会被丢弃并计数
+// 对于不携带上下文的普通项，返回的 TaskContext 为 nil
+func (q *BoundedQueue) DequeueWithContext() (interface{}, *TaskContext, error) {
+	for {
+		item, err := q.Dequeue()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		envelope, ok := item.(*TaskEnvelope)
+		if !ok {
+			return item, nil, nil
+		}
+
+		if envelope.Ctx.Err() != nil {
+			q.expiredCount.Inc()
+			continue
+		}
+
+		return envelope.Item, envelope.Ctx, nil
+	}
+}
+
+// 场景示例：订单处理链路的端到端超时与追踪
+func TaskContextDemo() {
+	fmt.Println("订单处理链路场景（任务上下文传播）:")
+
+	// 1. 协程池中传递上下文（每个子任务都有独立的工作协程，同时开始执行）
+	pool := NewGoroutinePool(5, 10)
+
+	root := NewTaskContextWithTimeout(context.Background(), 150*time.Millisecond)
+	root.SetMetadata("user", "u-1001")
+	root.SetMetadata("source", "app")
+	fmt.Printf("\n根上下文: %s\n", root)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		id := i
+		child := root.Child()
+		child.SetMetadata("step", fmt.Sprintf("%d", id))
+		step := contextTask(child, func(tc *TaskContext) error {
+			// 每个子任务耗时递增，后面的任务会超过根上下文的截止时间
+			select {
+			case <-time.After(time.Duration(40*(id+1)) * time.Millisecond):
+				fmt.Printf("  子任务%d 完成: %s\n", id, tc)
+				return nil
+			case <-tc.Done():
+				fmt.Printf("  子任务%d 被取消: %v\n", id, tc.Err())
+				return tc.Err()
+			}
+		})
+		// wg.Done 放在外层包装中，上下文已取消、子任务被跳过时也会执行
+		err := pool.Submit(func(ctx context.Context) error {
+			defer wg.Done()
+			return step(ctx)
+		})
+		if err != nil {
+			wg.Done()
+			fmt.Printf("  子任务%d 提交失败: %v\n", id, err)
+		}
+	}
+	wg.Wait()
+
+	// 截止时间已过，再提交的任务会被直接拒绝
+	if err := pool.SubmitWithContext(root.Child(), func(tc *TaskContext) error { return nil }); err != nil {
+		fmt.Printf("  截止时间后提交: %v\n", err)
+	}
+	pool.Shutdown()
+
+	stats := pool.Stats()
+	fmt.Printf("协程池统计: 成功=%d, 失败=%d, 取消=%d\n",
+		stats["successCount"], stats["errorCount"], stats["canceledCount"])
+
+	// 2. 有界队列中传递上下文
+	fmt.Println("\n有界队列中的上下文:")
+	queue := NewBoundedQueue(5)
+
+	fresh := NewTaskContext(context.Background())
+	fresh.SetMetadata("order", "A-1")
+	expired := NewTaskContextWithTimeout(context.Background(), 10*time.Millisecond)
+	expired.SetMetadata("order", "A-2")
+
+	queue.EnqueueWithContext(expired, "订单A-2")
+	queue.EnqueueWithContext(fresh, "订单A-1")
+	time.Sleep(20 * time.Millisecond) // A-2 在队列中等待时已过期
+	queue.Close()
+
+	for {
+		item, tc, err := queue.DequeueWithContext()
+		if err != nil {
+			break
+		}
+		fmt.Printf("  出队: %v (%s)\n", item, tc)
+	}
+	fmt.Printf("队列统计: 过期丢弃=%d\n", queue.Stats()["expiredCount"])
+}
@@ -0,0 +1,483 @@
+package concurrency
+
+/*
+分层时间轮（Hierarchical Timing Wheel）
+
+原理：
+时间轮像一个钟表盘：表盘分成 N 格，每格代表一个刻度（Tick），指针每过一个刻度前进一格，
+定时器按到期刻度挂在对应的格子上，指针走到哪一格就触发哪一格上的全部定时器。
+插入只需要算出格子下标再挂到链表上，删除只需要从链表中摘下，都是 O(1)，与定时器数量无关。
+单层表盘能表示的最长延迟只有 N 个刻度，分层时间轮像时、分、秒针一样叠加多层表盘：
+第 L 层每格代表 N^L 个刻度，延迟越长挂在越高的层；低一层的指针转完一圈时，
+把高一层当前格上的定时器取下来按剩余时间重新挂到低层（降级，cascade），最终都会落到第0层并触发。
+Kafka、Netty 的延迟任务和 Linux 内核的定时器都使用这种结构。
+
+关键特点：
+1. 插入、停止、重置都是 O(1)：格子是双向链表，定时器记录自己所在的格子
+2. 整个时间轮只有一个驱动协程和一个 Ticker，百万个定时器也不会增加协程和运行时定时器
+3. 精度为一个刻度：定时器在到期后的下一个刻度触发，不会提前
+4. 没有定时器时驱动协程停止 Ticker 并休眠，插入新定时器时唤醒并直接跳到当前刻度，空闲的时间轮不消耗 CPU
+5. 回调在新的协程中执行（与 time.AfterFunc 相同），慢回调不会拖慢其他定时器
+6. Every 创建周期定时器，上一次回调结束后才开始计算下一次的间隔，回调不会重叠
+7. SharedTimingWheel 返回进程内共享的时间轮，各个缓存、存储不再需要各自的清理协程
+
+实现方式：
+- 每层格数为2的幂，格子下标由到期刻度的对应二进制位直接得到：第 L 层下标 = (到期刻度 >> (L×位数)) & 掩码
+- 刻度由创建时间起经过的单调时间计算，驱动协程被唤醒时一次补齐所有落后的刻度，Ticker 的抖动不会累积
+- 超过最高层范围的延迟先挂在最高层最远的格子上，降级时按真实到期刻度重新放置，相当于多转几圈
+- 一把互斥锁保护所有格子；到期的定时器在锁内摘下，解锁后再启动回调
+
+应用场景：
+- 缓存和键值存储的 TTL 过期（每个键一个定时器）
+- 连接空闲超时、请求超时、心跳超时
+- 延迟消息、订单超时取消等大量短生命周期的定时任务
+
+优缺点：
+- 优点：定时器再多，插入和取消的代价也不变；内存只有每个定时器一个小结构体加固定数量的格子
+- 缺点：精度受刻度限制，不适合需要亚毫秒精度的场景；驱动协程每个刻度都要醒来一次；
+  定时器集中在同一刻度到期时，会在一瞬间启动大量回调协程
+
+以下实现了支持一次性和周期定时器的分层时间轮、进程内共享的时间轮，以及连接空闲超时的示例。
+*/
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// TimingWheelOptions 时间轮配置
+type TimingWheelOptions struct {
+	Tick      time.Duration // 每个刻度的时长，也是定时器的精度
+	WheelSize int           // 每层的格数，向上取整为2的幂
+	Levels    int           // 层数，不需要多转圈的最长延迟为 Tick×WheelSize^Levels
+}
+
+// DefaultTimingWheelOptions 默认配置：刻度10ms，每层64格，4层，约46小时内的延迟不需要多转圈
+var DefaultTimingWheelOptions = TimingWheelOptions{
+	Tick:      10 * time.Millisecond,
+	WheelSize: 64,
+	Levels:    4,
+}
+
+// WheelTimer 时间轮上的定时器，通过 TimingWheel.AfterFunc 或 Every 创建
+type WheelTimer struct {
+	wheel      *TimingWheel
+	fn         func()
+	expires    uint64        // 到期刻度
+	interval   time.Duration // 周期定时器的间隔，0表示一次性定时器
+	slot       *wheelSlot    // 所在的格子，nil 表示不在时间轮上
+	prev, next *WheelTimer
+	stopped    bool // 周期定时器已停止，回调结束后不再重新挂上
+}
+
+// wheelSlot 时间轮的一格，挂着到期刻度落在这一格的定时器
+type wheelSlot struct {
+	head *WheelTimer
+}
+
+// push 把定时器挂到格子上
+func (s *wheelSlot) push(t *WheelTimer) {
+	t.slot = s
+	t.prev = nil
+	t.next = s.head
+	if s.head != nil {
+		s.head.prev = t
+	}
+	s.head = t
+}
+
+// remove 把定时器从格子上摘下
+func (s *wheelSlot) remove(t *WheelTimer) {
+	if t.prev != nil {
+		t.prev.next = t.next
+	} else {
+		s.head = t.next
+	}
+	if t.next != nil {
+		t.next.prev = t.prev
+	}
+	t.slot, t.prev, t.next = nil, nil, nil
+}
+
+// detach 摘下格子上的全部定时器，返回链表头
+func (s *wheelSlot) detach() *WheelTimer {
+	head := s.head
+	s.head = nil
+	return head
+}
+
+// TimingWheel 分层时间轮
+type TimingWheel struct {
+	mu       sync.Mutex
+	tick     time.Duration
+	bits     uint          // 每层格数的二进制位数
+	mask     uint64        // 每层格数减1
+	levels   [][]wheelSlot // levels[L][i] 第 L 层第 i 格
+	start    time.Time     // 刻度0对应的时间
+	current  uint64        // 下一个要处理的刻度
+	count    int           // 时间轮上的定时器数量
+	wake     chan struct{} // 从空闲状态唤醒驱动协程
+	done     chan struct{}
+	stopped  bool
+	fired    int64 // 触发的次数
+	cascaded int64 // 降级重新放置的次数
+}
+
+// NewTimingWheel 创建时间轮并启动驱动协程，不再使用时需要调用 Stop
+func NewTimingWheel(options TimingWheelOptions) *TimingWheel {
+	w := newTimingWheel(options)
+	go w.run()
+	return w
+}
+
+// newTimingWheel 创建时间轮，不启动驱动协程
+func newTimingWheel(options TimingWheelOptions) *TimingWheel {
+	if options.Tick <= 0 {
+		options.Tick = DefaultTimingWheelOptions.Tick
+	}
+	if options.WheelSize < 2 {
+		options.WheelSize = DefaultTimingWheelOptions.WheelSize
+	}
+	if options.Levels <= 0 {
+		options.Levels = DefaultTimingWheelOptions.Levels
+	}
+	bits := uint(1)
+	for 1<<bits < options.WheelSize {
+		bits++
+	}
+	// 所有层的位数之和不能超过刻度计数的位数
+	if maxLevels := 62 / int(bits); options.Levels > maxLevels {
+		options.Levels = maxLevels
+	}
+
+	w := &TimingWheel{
+		tick:   options.Tick,
+		bits:   bits,
+		mask:   1<<bits - 1,
+		levels: make([][]wheelSlot, options.Levels),
+		start:  time.Now(),
+		wake:   make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	for i := range w.levels {
+		w.levels[i] = make([]wheelSlot, 1<<bits)
+	}
+	return w
+}
+
+var (
+	sharedWheel     *TimingWheel
+	sharedWheelOnce sync.Once
+)
+
+// SharedTimingWheel 返回进程内共享的时间轮（默认配置），第一次调用时创建；它常驻到进程退出，不能 Stop
+func SharedTimingWheel() *TimingWheel {
+	sharedWheelOnce.Do(func() {
+		sharedWheel = newTimingWheel(DefaultTimingWheelOptions)
+		go sharedWheel.run() // 在这里启动，泄漏检查据此识别常驻的驱动协程
+	})
+	return sharedWheel
+}
+
+// span 不需要多转圈的最长延迟（刻度数）
+func (w *TimingWheel) span() uint64 {
+	return 1 << (w.bits * uint(len(w.levels)))
+}
+
+// expiresLocked 返回从现在起经过 d 的到期刻度，向上取整，保证不会提前触发
+func (w *TimingWheel) expiresLocked(d time.Duration) uint64 {
+	elapsed := time.Since(w.start) + max(d, 0)
+	return uint64((elapsed + w.tick - 1) / w.tick)
+}
+
+// addLocked 按到期刻度把定时器挂到对应的层和格子上
+func (w *TimingWheel) addLocked(t *WheelTimer) {
+	expires := max(t.expires, w.current)
+	delta := expires - w.current
+	if delta >= w.span() {
+		// 超出最高层的范围：先挂在最远的格子上，降级时再按真实到期刻度放置
+		delta = w.span() - 1
+		expires = w.current + delta
+	}
+	level := 0
+	for delta >= 1<<(w.bits*uint(level+1)) {
+		level++
+	}
+	w.levels[level][(expires>>(w.bits*uint(level)))&w.mask].push(t)
+	w.count++
+}
+
+// insertLocked 挂上新的定时器，时间轮从空变为非空时唤醒驱动协程。
+// 空闲期间驱动协程休眠，current 停在休眠前的刻度；先把它移到当前刻度，
+// 否则唤醒后的第一次 advance 要在锁内逐格走完整个空闲期间的刻度
+func (w *TimingWheel) insertLocked(t *WheelTimer) {
+	if w.count == 0 {
+		w.current = max(w.current, uint64(time.Since(w.start)/w.tick))
+	}
+	w.addLocked(t)
+	if w.count == 1 {
+		select {
+		case w.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// removeLocked 把定时器从时间轮上摘下
+func (w *TimingWheel) removeLocked(t *WheelTimer) {
+	t.slot.remove(t)
+	w.count--
+}
+
+// AfterFunc 在 d 之后在新的协程中调用 fn；时间轮已停止时返回的定时器不会触发
+func (w *TimingWheel) AfterFunc(d time.Duration, fn func()) *WheelTimer {
+	t := &WheelTimer{wheel: w, fn: fn}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.stopped {
+		t.expires = w.expiresLocked(d)
+		w.insertLocked(t)
+	}
+	return t
+}
+
+// Every 每隔 interval 在新的协程中调用一次 fn，上一次调用结束后才开始计算下一次的间隔
+func (w *TimingWheel) Every(interval time.Duration, fn func()) *WheelTimer {
+	t := &WheelTimer{wheel: w, fn: fn, interval: max(interval, w.tick)}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.stopped {
+		t.expires = w.expiresLocked(t.interval)
+		w.insertLocked(t)
+	}
+	return t
+}
+
+// Stop 停止定时器，返回是否在触发前停止；周期定时器停止后不再触发，正在执行的回调不受影响
+func (t *WheelTimer) Stop() bool {
+	w := t.wheel
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	t.stopped = true
+	if t.slot == nil {
+		return false
+	}
+	w.removeLocked(t)
+	return true
+}
+
+// Reset 把定时器改为从现在起 d 之后触发，返回重置前是否还在等待触发；周期定时器之后仍按原间隔重复
+func (t *WheelTimer) Reset(d time.Duration) bool {
+	w := t.wheel
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	pending := t.slot != nil
+	if pending {
+		w.removeLocked(t)
+	}
+	t.stopped = false
+	if !w.stopped {
+		t.expires = w.expiresLocked(d)
+		w.insertLocked(t)
+	}
+	return pending
+}
+
+// fire 执行回调，周期定时器在回调结束后重新挂上
+func (t *WheelTimer) fire() {
+	t.fn()
+	if t.interval == 0 {
+		return
+	}
+	w := t.wheel
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// 回调期间被 Stop 或 Reset 过时不再重新挂上
+	if !t.stopped && t.slot == nil && !w.stopped {
+		t.expires = w.expiresLocked(t.interval)
+		w.insertLocked(t)
+	}
+}
+
+// run 驱动协程：每个刻度推进一次，没有定时器时休眠到有新的定时器
+func (w *TimingWheel) run() {
+	ticker := time.NewTicker(w.tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-w.done:
+			return
+		}
+		if w.advance() {
+			continue
+		}
+		ticker.Stop()
+		select {
+		case <-w.wake:
+			ticker.Reset(w.tick)
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// advance 处理到当前时间为止的所有刻度，解锁后启动到期的回调，返回时间轮上是否还有定时器
+func (w *TimingWheel) advance() bool {
+	w.mu.Lock()
+	target := uint64(time.Since(w.start) / w.tick)
+	var due []*WheelTimer
+	for w.current <= target && w.count > 0 {
+		due = w.tickLocked(due)
+	}
+	if w.current <= target {
+		// 没有定时器时不需要逐格推进
+		w.current = target + 1
+	}
+	pending := w.count > 0
+	w.mu.Unlock()
+
+	for _, t := range due {
+		go t.fire()
+	}
+	return pending
+}
+
+// tickLocked 处理刻度 current：第0层转完一圈时先把高层当前格的定时器降级，再摘下第0层当前格的定时器
+func (w *TimingWheel) tickLocked(due []*WheelTimer) []*WheelTimer {
+	index := w.current & w.mask
+	if index == 0 {
+		for level := 1; level < len(w.levels); level++ {
+			i := (w.current >> (w.bits * uint(level))) & w.mask
+			for t := w.levels[level][i].detach(); t != nil; {
+				next := t.next
+				t.slot, t.prev, t.next = nil, nil, nil
+				w.count--
+				w.addLocked(t)
+				w.cascaded++
+				t = next
+			}
+			if i != 0 {
+				break
+			}
+		}
+	}
+	for t := w.levels[0][index].detach(); t != nil; {
+		next := t.next
+		t.slot, t.prev, t.next = nil, nil, nil
+		w.count--
+		w.fired++
+		due = append(due, t)
+		t = next
+	}
+	w.current++
+	return due
+}
+
+// Len 返回等待触发的定时器数量
+func (w *TimingWheel) Len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.count
+}
+
+// Stop 停止时间轮和驱动协程，返回被丢弃的定时器数量；之后创建的定时器不会触发，可重复调用
+func (w *TimingWheel) Stop() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stopped {
+		return 0
+	}
+	w.stopped = true
+	dropped := w.count
+	for _, level := range w.levels {
+		for i := range level {
+			for t := level[i].detach(); t != nil; {
+				next := t.next
+				t.slot, t.prev, t.next = nil, nil, nil
+				t = next
+			}
+		}
+	}
+	w.count = 0
+	close(w.done)
+	return dropped
+}
+
+// Stats 返回时间轮的统计信息
+func (w *TimingWheel) Stats() map[string]interface{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return map[string]interface{}{
+		"timers":    w.count,
+		"tick":      w.tick.String(),
+		"wheelSize": len(w.levels[0]),
+		"levels":    len(w.levels),
+		"span":      (time.Duration(w.span()) * w.tick).String(),
+		"fired":     w.fired,
+		"cascaded":  w.cascaded,
+	}
+}
+
+// 场景示例：网关为每个连接维护空闲超时，连接有数据时重置定时器，超时后关闭连接
+func TimingWheelDemo() {
+	fmt.Println("分层时间轮示例 (连接空闲超时):")
+
+	wheel := NewTimingWheel(TimingWheelOptions{Tick: 5 * time.Millisecond, WheelSize: 8, Levels: 3})
+	defer wheel.Stop()
+
+	// 1. 少量连接：每层8格、刻度5ms，第0层覆盖40ms、第1层覆盖320ms，更长的超时挂在第2层
+	var mu sync.Mutex
+	var closed []string
+	start := time.Now()
+	conns := make(map[string]*WheelTimer)
+	for name, idle := range map[string]time.Duration{"conn-a": 30 * time.Millisecond, "conn-b": 60 * time.Millisecond, "conn-c": 400 * time.Millisecond} {
+		name := name
+		conns[name] = wheel.AfterFunc(idle, func() {
+			mu.Lock()
+			closed = append(closed, fmt.Sprintf("%s(%dms)", name, time.Since(start).Milliseconds()/10*10))
+			mu.Unlock()
+		})
+	}
+	time.Sleep(40 * time.Millisecond)
+	conns["conn-b"].Reset(60 * time.Millisecond) // conn-b 收到数据，重新计时
+	conns["conn-c"].Stop()                       // conn-c 主动关闭，不再需要超时
+	var heartbeats int
+	ticker := wheel.Every(20*time.Millisecond, func() {
+		mu.Lock()
+		heartbeats++
+		mu.Unlock()
+	})
+	time.Sleep(150 * time.Millisecond)
+	ticker.Stop()
+	mu.Lock()
+	fmt.Printf("超时关闭的连接: %v（conn-b 重置后挂在第二层，降级后按时触发）\n", closed)
+	fmt.Printf("150ms 内每20ms一次的心跳: %d 次\n", heartbeats)
+	mu.Unlock()
+
+	// 2. 百万连接：插入和取消的代价与定时器数量无关，且只有一个驱动协程
+	const n = 1000000
+	before := runtime.NumGoroutine()
+	big := NewTimingWheel(DefaultTimingWheelOptions)
+	timers := make([]*WheelTimer, n)
+	rng := rand.New(rand.NewSource(1))
+	insertStart := time.Now()
+	for i := range timers {
+		timers[i] = big.AfterFunc(time.Minute+time.Duration(rng.Int63n(int64(59*time.Minute))), func() {})
+	}
+	insertTime := time.Since(insertStart)
+	goroutines := runtime.NumGoroutine() - before
+	cancelStart := time.Now()
+	for i := 0; i < n; i += 2 {
+		timers[i].Stop()
+	}
+	cancelTime := time.Since(cancelStart)
+	fmt.Printf("插入 %d 个1分钟到1小时内随机到期的定时器: %v（平均 %dns），新增协程 %d 个\n",
+		n, insertTime.Round(time.Millisecond), insertTime.Nanoseconds()/n, goroutines)
+	fmt.Printf("取消其中一半: %v，剩余 %d 个\n", cancelTime.Round(time.Millisecond), big.Len())
+	fmt.Printf("时间轮停止，丢弃 %d 个定时器\n", big.Stop())
+	fmt.Printf("小时间轮统计: %v\n", wheel.Stats())
+}
@@ -0,0 +1,34 @@
+package concurrency
+
+import (
+	"testing"
+	"time"
+)
+
+// 时间轮空闲很久之后插入定时器，current 直接跳到当前刻度，不需要逐格补齐空闲期间的刻度
+func TestTimingWheelSkipsIdleTicks(t *testing.T) {
+	w := newTimingWheel(TimingWheelOptions{Tick: time.Millisecond, WheelSize: 64, Levels: 4})
+	// 不启动驱动协程，把起点往前拨一小时，模拟时间轮空闲了一小时
+	w.start = w.start.Add(-time.Hour)
+
+	fired := make(chan struct{})
+	w.AfterFunc(2*time.Millisecond, func() { close(fired) })
+
+	w.mu.Lock()
+	current := w.current
+	w.mu.Unlock()
+	if idle := uint64(time.Hour / time.Millisecond); current < idle {
+		t.Fatalf("插入定时器后 current 为 %d，期望跳到空闲后的刻度（至少 %d）", current, idle)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	w.advance()
+	select {
+	case <-fired:
+	case <-time.After(time.Second):
+		t.Fatal("定时器没有触发")
+	}
+	if n := w.Len(); n != 0 {
+		t.Errorf("触发后时间轮上还有 %d 个定时器", n)
+	}
+}
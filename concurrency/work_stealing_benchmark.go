@@ -0,0 +1,211 @@
+package concurrency
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/strive/scenario/report"
+)
+
+// 工作窃取基准中的负载
+const (
+	workloadUniform  = "均匀小任务(ms)"
+	workloadSkewed   = "倾斜负载(ms)"
+	workloadForkJoin = "分治展开(ms)"
+)
+
+// stealingBenchPool 基准中被比较的协程池：Submit 从外部提交，Fork 在任务内部提交子任务
+type stealingBenchPool interface {
+	Submit(task GoroutineTask) error
+	Fork(ctx context.Context, task GoroutineTask) error
+	Shutdown()
+}
+
+// channelPool 所有工作协程共享一个带缓冲通道的最简协程池，作为对比基线
+type channelPool struct {
+	tasks chan GoroutineTask
+	wg    sync.WaitGroup
+}
+
+// newChannelPool 创建共享通道协程池，queueSize 需要容纳所有任务，否则在任务内部 Fork 可能因通道满而死锁
+func newChannelPool(workers, queueSize int) *channelPool {
+	p := &channelPool{tasks: make(chan GoroutineTask, queueSize)}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer p.wg.Done()
+			for task := range p.tasks {
+				task(context.Background())
+			}
+		}()
+	}
+	return p
+}
+
+func (p *channelPool) Submit(task GoroutineTask) error {
+	p.tasks <- task
+	return nil
+}
+
+func (p *channelPool) Fork(ctx context.Context, task GoroutineTask) error {
+	return p.Submit(task)
+}
+
+func (p *channelPool) Shutdown() {
+	close(p.tasks)
+	p.wg.Wait()
+}
+
+// sharedHeapPool 让 GoroutinePool 满足基准接口，Fork 直接提交到共享队列
+type sharedHeapPool struct {
+	*GoroutinePool
+}
+
+func (p sharedHeapPool) Fork(ctx context.Context, task GoroutineTask) error {
+	return p.Submit(task)
+}
+
+// spin 执行 units 个单位的计算，模拟CPU密集的任务
+func spin(units int) uint64 {
+	x := uint64(units) | 1
+	for i := 0; i < units*64; i++ {
+		x ^= x << 13
+		x ^= x >> 7
+		x ^= x << 17
+	}
+	return x
+}
+
+// stealingWorkloads 返回各负载的执行函数：向协程池提交任务并等待全部完成，返回所有任务的计算结果之和用于校验
+func stealingWorkloads(tasks int) map[string]func(pool stealingBenchPool) uint64 {
+	run := func(pool stealingBenchPool, submit func(done func(uint64), wg *sync.WaitGroup)) uint64 {
+		var sum uint64
+		var wg sync.WaitGroup
+		submit(func(v uint64) { atomic.AddUint64(&sum, v) }, &wg)
+		wg.Wait()
+		return sum
+	}
+
+	return map[string]func(pool stealingBenchPool) uint64{
+		// 大量耗时相同的小任务，考验共享队列的竞争
+		workloadUniform: func(pool stealingBenchPool) uint64 {
+			return run(pool, func(done func(uint64), wg *sync.WaitGroup) {
+				wg.Add(tasks)
+				for i := 0; i < tasks; i++ {
+					pool.Submit(func(context.Context) error {
+						defer wg.Done()
+						done(spin(1))
+						return nil
+					})
+				}
+			})
+		},
+		// 每32个任务中有一个重200倍，重任务集中在前面提交
+		workloadSkewed: func(pool stealingBenchPool) uint64 {
+			heavy := tasks / 32
+			return run(pool, func(done func(uint64), wg *sync.WaitGroup) {
+				wg.Add(tasks / 8)
+				for i := 0; i < tasks/8; i++ {
+					units := 1
+					if i < heavy/8 {
+						units = 200
+					}
+					pool.Submit(func(context.Context) error {
+						defer wg.Done()
+						done(spin(units))
+						return nil
+					})
+				}
+			})
+		},
+		// 少量根任务递归二分，叶子任务在执行过程中突发产生
+		workloadForkJoin: func(pool stealingBenchPool) uint64 {
+			const roots = 8
+			leaves := max(tasks/roots, 1)
+			return run(pool, func(done func(uint64), wg *sync.WaitGroup) {
+				var split func(n int) GoroutineTask
+				split = func(n int) GoroutineTask {
+					return func(ctx context.Context) error {
+						defer wg.Done()
+						if n == 1 {
+							done(spin(1))
+							return nil
+						}
+						wg.Add(2)
+						pool.Fork(ctx, split(n/2))
+						pool.Fork(ctx, split(n-n/2))
+						return nil
+					}
+				}
+				wg.Add(roots)
+				for i := 0; i < roots; i++ {
+					pool.Submit(split(leaves))
+				}
+			})
+		},
+	}
+}
+
+// WorkStealingBenchmark 对比共享通道、共享堆（GoroutinePool）和工作窃取三种协程池在均匀、倾斜和分治负载下的完成时间
+func WorkStealingBenchmark(tasks, workers int) *report.Comparison {
+	order := []string{workloadUniform, workloadSkewed, workloadForkJoin}
+	metrics := make([]report.Metric, 0, len(order))
+	for _, name := range order {
+		metrics = append(metrics, report.Metric{Name: name, LowerIsBetter: true, Precision: 1})
+	}
+	input := fmt.Sprintf("%d 个工作协程，均匀负载 %d 个任务，倾斜负载 %d 个任务（其中 1/32 重200倍），分治负载 %d 个叶子任务，GOMAXPROCS=%d",
+		workers, tasks, tasks/8, tasks, runtime.GOMAXPROCS(0))
+	comparison := report.NewComparison("共享队列与工作窃取协程池对比", input, metrics...)
+	workloads := stealingWorkloads(tasks)
+
+	// 每种负载单独创建协程池，queueSize 足够容纳全部任务，避免任务内部 Fork 时阻塞
+	queueSize := 2 * tasks
+	candidates := []struct {
+		name string
+		new  func() stealingBenchPool
+	}{
+		{"共享通道", func() stealingBenchPool { return newChannelPool(workers, queueSize) }},
+		{"GoroutinePool(共享堆)", func() stealingBenchPool { return sharedHeapPool{NewGoroutinePool(workers, queueSize)} }},
+		{"WorkStealingPool", func() stealingBenchPool { return NewWorkStealingPool(workers) }},
+	}
+
+	var expected map[string]uint64
+	for _, candidate := range candidates {
+		comparison.Measure(candidate.name, func() (map[string]float64, error) {
+			values := make(map[string]float64, len(order))
+			sums := make(map[string]uint64, len(order))
+			for _, name := range order {
+				pool := candidate.new()
+				start := time.Now()
+				sums[name] = workloads[name](pool)
+				values[name] = float64(time.Since(start).Microseconds()) / 1000
+				pool.Shutdown()
+			}
+			if expected == nil {
+				expected = sums
+				return values, nil
+			}
+			for _, name := range order {
+				if sums[name] != expected[name] {
+					return values, fmt.Errorf("%s: 任务结果校验失败", name)
+				}
+			}
+			return values, nil
+		})
+	}
+	return comparison
+}
+
+// 场景示例：批处理协程池选型，比较三种协程池在不同负载形态下的完成时间
+func WorkStealingBenchmarkDemo() {
+	fmt.Println("工作窃取协程池基准:")
+	WorkStealingBenchmark(200000, max(runtime.GOMAXPROCS(0), 4)).Write(os.Stdout, report.FormatMarkdown)
+	fmt.Println("共享队列天然均衡，但每个任务都要竞争同一把锁或同一个通道，任务越小、协程越多，竞争越明显；" +
+		"工作窃取平时只访问自己的队列，分治负载中子任务就地入队、空闲协程成批窃取，优势最大；" +
+		"重任务集中时三者都能均衡，差别主要来自取任务的开销")
+}
@@ -0,0 +1,391 @@
+package concurrency
+
+/*
+工作窃取调度（Work Stealing）
+
+原理：
+协程池的所有工作协程共享一个任务队列（通道或加锁的堆），每取一个任务都要竞争同一把锁或同一个通道，
+任务很短、核数很多时，这个共享队列本身就成了瓶颈。
+工作窃取为每个工作协程分配一个自己的双端队列：工作协程从自己队列的尾部存取任务（后进先出，刚产生的子任务数据还在缓存里），
+自己的队列空了才去随机挑一个其他工作协程，从它队列的头部"偷"走一半任务（先进先出，偷走的是最老、通常也是最大的任务）。
+大多数时候每个工作协程只访问自己的队列，竞争只发生在窃取时；
+任务耗时不均或集中在少数工作协程上时，空闲的协程会主动把活抢过来，负载自动均衡。
+Go 运行时的调度器（每个 P 一个本地运行队列）和 Java 的 ForkJoinPool 都采用了这种设计。
+
+关键特点：
+1. 每个工作协程一个双端队列，所有者在尾部后进先出，窃取者在头部先进先出
+2. 一次窃取拿走受害者一半的任务，减少窃取次数
+3. 任务可以通过 Fork 把子任务放进当前工作协程自己的队列，适合分治、递归展开这类突发产生大量子任务的负载
+4. 外部提交的任务轮流放入各工作协程的队列
+5. 没有任务时工作协程在条件变量上休眠，不会空转
+6. 任务中的 panic 被恢复并计入统计，关闭时等待所有队列清空
+
+实现方式：
+- 双端队列用互斥锁保护的切片实现，所有者和窃取者操作不同的一端，锁的持有时间极短
+- 全局原子计数器记录尚未执行的任务数，工作协程在计数为0时才休眠，提交者计数后再唤醒，不会丢失唤醒
+- 窃取从随机位置开始依次尝试其他工作协程，避免所有空闲协程都去偷同一个受害者
+- 执行任务时把当前工作协程放入上下文，Fork 据此找到本地队列
+
+应用场景：
+- 分治算法、并行归并排序、图遍历等递归产生子任务的计算
+- 任务耗时差异大、同一批提交中少数任务特别重的批处理
+- 与共享通道协程池的对比见 work_stealing_benchmark.go
+
+优缺点：
+- 优点：常规路径上无共享竞争，负载自动均衡，子任务就地执行局部性好
+- 缺点：不保证任务执行顺序，也不支持优先级；队列不设上限，提交速度长期超过处理速度时内存会增长；
+  任务都很均匀、数量又少时，窃取带来的额外开销得不偿失
+
+以下实现了基于工作窃取的协程池，并演示任务集中提交到一个工作协程时的负载均衡。
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// stealingWorkerKey 在任务上下文中存取当前工作协程的键
+type stealingWorkerKey struct{}
+
+// wsDeque 工作协程的双端队列，所有者从尾部存取，窃取者从头部拿走
+type wsDeque struct {
+	mu    sync.Mutex
+	tasks []GoroutineTask
+	head  int // 第一个有效任务的下标，头部被拿走后前移，积累过多时整体搬移
+}
+
+// pushBack 所有者在尾部放入任务
+func (d *wsDeque) pushBack(task GoroutineTask) {
+	d.mu.Lock()
+	d.tasks = append(d.tasks, task)
+	d.mu.Unlock()
+}
+
+// popBack 所有者从尾部取出最近放入的任务
+func (d *wsDeque) popBack() (GoroutineTask, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.tasks) == d.head {
+		return nil, false
+	}
+	task := d.tasks[len(d.tasks)-1]
+	d.tasks[len(d.tasks)-1] = nil
+	d.tasks = d.tasks[:len(d.tasks)-1]
+	if len(d.tasks) == d.head {
+		d.tasks, d.head = d.tasks[:0], 0
+	}
+	return task, true
+}
+
+// stealHalf 窃取者从头部拿走一半（至少一个）任务
+func (d *wsDeque) stealHalf() []GoroutineTask {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	n := len(d.tasks) - d.head
+	if n == 0 {
+		return nil
+	}
+	n = (n + 1) / 2
+	stolen := make([]GoroutineTask, n)
+	copy(stolen, d.tasks[d.head:d.head+n])
+	clear(d.tasks[d.head : d.head+n])
+	d.head += n
+	if d.head == len(d.tasks) {
+		d.tasks, d.head = d.tasks[:0], 0
+	} else if d.head > len(d.tasks)/2 {
+		// 头部空出一半以上时整体搬移，避免切片只增不减
+		remaining := copy(d.tasks, d.tasks[d.head:])
+		clear(d.tasks[remaining:])
+		d.tasks, d.head = d.tasks[:remaining], 0
+	}
+	return stolen
+}
+
+// size 返回队列中的任务数
+func (d *wsDeque) size() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.tasks) - d.head
+}
+
+// stealingWorker 工作窃取池中的工作协程
+type stealingWorker struct {
+	pool      *WorkStealingPool
+	deque     wsDeque
+	rng       *rand.Rand // 只由该工作协程使用
+	executed  int64      // 执行的任务数
+	steals    int64      // 成功窃取的次数
+	stolen    int64      // 窃取到的任务数
+	forked    int64      // 通过 Fork 放入本地队列的任务数
+	idleNanos int64      // 休眠的累计时间
+}
+
+// WorkStealingPool 基于工作窃取的协程池
+type WorkStealingPool struct {
+	workers []*stealingWorker
+	next    uint64 // 外部提交轮询到的工作协程
+	pending int64  // 已提交但尚未开始执行的任务数
+
+	mu      sync.Mutex
+	wake    *sync.Cond // 有新任务或协程池关闭
+	idle    int32      // 准备休眠或正在休眠的工作协程数，提交者据此判断是否需要加锁唤醒
+	running bool
+	wg      sync.WaitGroup
+
+	taskCount    int64
+	successCount int64
+	errorCount   int64
+	panicCount   int64
+}
+
+// NewWorkStealingPool 创建工作窃取协程池，workers 小于等于0时为1
+func NewWorkStealingPool(workers int) *WorkStealingPool {
+	if workers <= 0 {
+		workers = 1
+	}
+	p := &WorkStealingPool{workers: make([]*stealingWorker, workers), running: true}
+	p.wake = sync.NewCond(&p.mu)
+	for i := range p.workers {
+		p.workers[i] = &stealingWorker{pool: p, rng: rand.New(rand.NewSource(time.Now().UnixNano() + int64(i)))}
+	}
+	p.wg.Add(workers)
+	for _, w := range p.workers {
+		go w.run()
+	}
+	return p
+}
+
+// Submit 提交任务，任务轮流放入各工作协程的队列；协程池关闭后返回 ErrPoolClosed
+func (p *WorkStealingPool) Submit(task GoroutineTask) error {
+	if !p.reserve() {
+		return ErrPoolClosed
+	}
+	w := p.workers[(atomic.AddUint64(&p.next, 1)-1)%uint64(len(p.workers))]
+	p.push(w, task)
+	return nil
+}
+
+// SubmitTo 把任务放入指定工作协程的队列，用于模拟提交集中在少数工作协程上的情况
+func (p *WorkStealingPool) SubmitTo(worker int, task GoroutineTask) error {
+	if !p.reserve() {
+		return ErrPoolClosed
+	}
+	p.push(p.workers[worker%len(p.workers)], task)
+	return nil
+}
+
+// Fork 在任务内部提交子任务：ctx 来自本池的任务时放入当前工作协程的队列尾部，否则等同于 Submit。
+// 正在执行的任务在协程池关闭后仍然可以 Fork，保证已经开始的分治计算能够完成
+func (p *WorkStealingPool) Fork(ctx context.Context, task GoroutineTask) error {
+	w, ok := ctx.Value(stealingWorkerKey{}).(*stealingWorker)
+	if !ok || w.pool != p {
+		return p.Submit(task)
+	}
+	atomic.AddInt64(&w.forked, 1)
+	atomic.AddInt64(&p.pending, 1)
+	p.push(w, task)
+	return nil
+}
+
+// reserve 协程池运行中时预先计入一个待执行的任务。与 Shutdown 在同一把锁下判断，
+// 关闭前预留成功的任务一定会被执行：工作协程看到 pending 大于0就不会退出
+func (p *WorkStealingPool) reserve() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.running {
+		return false
+	}
+	atomic.AddInt64(&p.pending, 1)
+	return true
+}
+
+// push 把已经计入 pending 的任务放入工作协程的队列，唤醒一个休眠的工作协程
+func (p *WorkStealingPool) push(w *stealingWorker, task GoroutineTask) {
+	atomic.AddInt64(&p.taskCount, 1)
+	w.deque.pushBack(task)
+
+	// 没有工作协程休眠时不碰全局锁：休眠者先增加 idle 再检查 pending，
+	// 调用方先增加 pending 这里再检查 idle，两边至少有一方能看到对方的修改
+	if atomic.LoadInt32(&p.idle) > 0 {
+		p.mu.Lock()
+		p.wake.Signal()
+		p.mu.Unlock()
+	}
+}
+
+// run 工作协程主循环：先取自己的任务，再去窃取，都没有时休眠
+func (w *stealingWorker) run() {
+	defer w.pool.wg.Done()
+	ctx := context.WithValue(context.Background(), stealingWorkerKey{}, w)
+
+	for {
+		task, ok := w.deque.popBack()
+		if !ok {
+			task, ok = w.steal()
+		}
+		if ok {
+			atomic.AddInt64(&w.pool.pending, -1)
+			w.execute(ctx, task)
+			continue
+		}
+		if !w.sleep() {
+			return
+		}
+	}
+}
+
+// steal 从随机位置开始依次尝试其他工作协程，拿走第一个非空队列的一半任务；
+// 返回其中一个直接执行，其余放入自己的队列
+func (w *stealingWorker) steal() (GoroutineTask, bool) {
+	workers := w.pool.workers
+	start := w.rng.Intn(len(workers))
+	for i := range workers {
+		victim := workers[(start+i)%len(workers)]
+		if victim == w {
+			continue
+		}
+		stolen := victim.deque.stealHalf()
+		if len(stolen) == 0 {
+			continue
+		}
+		atomic.AddInt64(&w.steals, 1)
+		atomic.AddInt64(&w.stolen, int64(len(stolen)))
+		if len(stolen) > 1 {
+			w.deque.mu.Lock()
+			w.deque.tasks = append(w.deque.tasks, stolen[1:]...)
+			w.deque.mu.Unlock()
+		}
+		return stolen[0], true
+	}
+	return nil, false
+}
+
+// sleep 没有可执行的任务时休眠，直到有新任务；协程池已关闭且没有待执行的任务时返回 false
+func (w *stealingWorker) sleep() bool {
+	p := w.pool
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	start := time.Now()
+	defer func() { atomic.AddInt64(&w.idleNanos, int64(time.Since(start))) }()
+	for {
+		atomic.AddInt32(&p.idle, 1)
+		if atomic.LoadInt64(&p.pending) > 0 {
+			atomic.AddInt32(&p.idle, -1)
+			return true
+		}
+		if !p.running {
+			atomic.AddInt32(&p.idle, -1)
+			return false
+		}
+		p.wake.Wait()
+		atomic.AddInt32(&p.idle, -1)
+	}
+}
+
+// execute 执行任务并记录结果，panic 被恢复为 PanicError
+func (w *stealingWorker) execute(ctx context.Context, task GoroutineTask) {
+	err := safeCall(ctx, task)
+	atomic.AddInt64(&w.executed, 1)
+
+	var panicErr *PanicError
+	switch {
+	case errors.As(err, &panicErr):
+		atomic.AddInt64(&w.pool.panicCount, 1)
+		atomic.AddInt64(&w.pool.errorCount, 1)
+	case err != nil:
+		atomic.AddInt64(&w.pool.errorCount, 1)
+	default:
+		atomic.AddInt64(&w.pool.successCount, 1)
+	}
+}
+
+// Shutdown 停止接受外部提交，等待所有队列中的任务（包括执行期间 Fork 出的子任务）执行完毕
+func (p *WorkStealingPool) Shutdown() {
+	p.mu.Lock()
+	if !p.running {
+		p.mu.Unlock()
+		return
+	}
+	p.running = false
+	p.wake.Broadcast()
+	p.mu.Unlock()
+
+	p.wg.Wait()
+}
+
+// Stats 返回协程池统计信息，executed、steals、stolen、forked、queued 为每个工作协程的数值
+func (p *WorkStealingPool) Stats() map[string]interface{} {
+	n := len(p.workers)
+	executed := make([]int64, n)
+	steals := make([]int64, n)
+	stolen := make([]int64, n)
+	forked := make([]int64, n)
+	queued := make([]int, n)
+	idle := make([]time.Duration, n)
+	for i, w := range p.workers {
+		executed[i] = atomic.LoadInt64(&w.executed)
+		steals[i] = atomic.LoadInt64(&w.steals)
+		stolen[i] = atomic.LoadInt64(&w.stolen)
+		forked[i] = atomic.LoadInt64(&w.forked)
+		queued[i] = w.deque.size()
+		idle[i] = time.Duration(atomic.LoadInt64(&w.idleNanos))
+	}
+
+	p.mu.Lock()
+	running := p.running
+	p.mu.Unlock()
+
+	return map[string]interface{}{
+		"workers":      n,
+		"running":      running,
+		"pendingTasks": atomic.LoadInt64(&p.pending),
+		"taskCount":    atomic.LoadInt64(&p.taskCount),
+		"successCount": atomic.LoadInt64(&p.successCount),
+		"errorCount":   atomic.LoadInt64(&p.errorCount),
+		"panicCount":   atomic.LoadInt64(&p.panicCount),
+		"executed":     executed,
+		"steals":       steals,
+		"stolen":       stolen,
+		"forked":       forked,
+		"queued":       queued,
+		"idle":         idle,
+	}
+}
+
+// 场景示例：所有图片缩略图任务都被提交到同一个工作协程，其余协程靠窃取分担
+func WorkStealingPoolDemo() {
+	fmt.Println("工作窃取协程池示例（缩略图生成）:")
+
+	pool := NewWorkStealingPool(4)
+	var processed int64
+	// 每个相册一个任务，相册内的每张图片再 Fork 一个子任务；大相册的图片多
+	albums := []int{40, 5, 5, 30, 5, 15}
+	for _, photos := range albums {
+		photos := photos
+		pool.SubmitTo(0, func(ctx context.Context) error {
+			for j := 0; j < photos; j++ {
+				pool.Fork(ctx, func(context.Context) error {
+					time.Sleep(time.Millisecond) // 模拟缩放一张图片
+					atomic.AddInt64(&processed, 1)
+					return nil
+				})
+			}
+			return nil
+		})
+	}
+
+	start := time.Now()
+	pool.Shutdown()
+	elapsed := time.Since(start)
+
+	stats := pool.Stats()
+	fmt.Printf("处理 %d 张图片耗时 %v（单个协程串行约需 %dms）\n", processed, elapsed.Round(time.Millisecond), processed)
+	fmt.Printf("各工作协程执行的任务数: %v\n", stats["executed"])
+	fmt.Printf("各工作协程窃取次数: %v，窃取到的任务数: %v\n", stats["steals"], stats["stolen"])
+	fmt.Println("- 所有任务都提交给了0号协程，其余协程把它队列头部的任务成批偷走，四个协程的工作量大致相当")
+}
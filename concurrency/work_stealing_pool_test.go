@@ -0,0 +1,45 @@
+package concurrency
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// 与 Shutdown 并发提交时，Submit 返回 nil 的任务都必须被执行
+func TestWorkStealingPoolSubmitDuringShutdown(t *testing.T) {
+	for round := 0; round < 200; round++ {
+		pool := NewWorkStealingPool(2)
+		var accepted, executed int64
+		task := func(ctx context.Context) error {
+			atomic.AddInt64(&executed, 1)
+			return nil
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 20; j++ {
+					var err error
+					if j%2 == 0 {
+						err = pool.Submit(task)
+					} else {
+						err = pool.SubmitTo(i, task)
+					}
+					if err == nil {
+						atomic.AddInt64(&accepted, 1)
+					}
+				}
+			}(i)
+		}
+		pool.Shutdown()
+		wg.Wait()
+
+		if accepted != executed {
+			t.Fatalf("第%d轮: 接受了 %d 个任务，执行了 %d 个", round, accepted, executed)
+		}
+	}
+}
@@ -1,45 +1,26 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"sync"
 )
 
 // AlternatePrintNumbers 使用两个线程交替打印数字到100
 func AlternatePrintNumbers() {
-	var wg sync.WaitGroup
-	ch1 := make(chan struct{})
-	ch2 := make(chan struct{})
-
-	// 添加两个等待组
-	wg.Add(2)
+	// 通道策略：两个线程通过无缓冲通道传递令牌
+	rotation := NewRotation(DefaultRotationOptions)
 
 	// 第一个线程打印奇数
-	go func() {
-		defer wg.Done()
-		for i := 1; i <= 100; i += 2 {
-			<-ch1 // 等待信号
-			fmt.Printf("线程1: %d\n", i)
-			ch2 <- struct{}{} // 发送信号给线程2
-		}
-	}()
+	rotation.Register("线程1", func(n int) bool { return n%2 == 1 }, func(n int) {
+		fmt.Printf("线程1: %d\n", n)
+	})
 
 	// 第二个线程打印偶数
-	go func() {
-		defer wg.Done()
-		for i := 2; i <= 100; i += 2 {
-			<-ch2 // 等待信号
-			fmt.Printf("线程2: %d\n", i)
-			if i < 100 {
-				ch1 <- struct{}{} // 发送信号给线程1
-			}
-		}
-	}()
-
-	// 启动第一个线程
-	ch1 <- struct{}{}
+	rotation.Register("线程2", func(n int) bool { return n%2 == 0 }, func(n int) {
+		fmt.Printf("线程2: %d\n", n)
+	})
 
 	// 等待两个线程完成
-	wg.Wait()
+	rotation.Run(context.Background())
 	fmt.Println("交替打印完成")
 }
@@ -0,0 +1,533 @@
+package main
+
+/*
+线程安全的LRU/LFU缓存：互斥锁版本与分片版本
+
+原理：
+LRU、LFU 的读操作也会修改内部结构（LRU 把节点移到链表头部，LFU 增加频率并在链表之间移动节点），
+因此不能用读写锁让读操作并发执行，多个协程同时访问时必须互斥。
+- 互斥锁版本：一把锁保护整个缓存，实现最简单，淘汰顺序与单线程版本完全一致
+- 分片版本：按键的哈希值把数据分到 N 个独立的缓存中，每个分片有自己的锁，
+  不同分片上的操作可以并行，锁竞争降低到原来的约 1/N
+
+关键特点：
+1. SyncCache 可以包装任何实现 cache_strategies.Cache 接口的缓存，包括标准库链表版本和自定义链表版本
+2. ShardedCache 的总容量平均分给各分片，每个分片独立淘汰，整体近似于全局的LRU/LFU
+3. 两种版本都实现 Cache 接口，可以直接替换原来的单线程缓存
+4. 底层缓存提供 CacheStats 时，统计信息会按分片汇总
+5. 两种版本都实现 BatchCache 接口：GetMulti、PutMulti 在互斥锁版本中只加一次锁，在分片版本中每个分片加一次锁
+
+实现方式：
+- SyncCache：sync.Mutex 保护被包装的缓存，所有操作都在锁内执行
+- ShardedCache：FNV-1a 哈希键后对分片数取模，选中的分片是一个 SyncCache；批量操作先按分片分组，再对每个分片加一次锁
+- GetOrLoad：未命中时同一个键只有一个协程执行加载，其余协程等待结果，避免热点键失效时的缓存击穿
+
+应用场景：
+- 在协程池、生产者消费者等并发场景中共享缓存
+- Web 服务的进程内缓存
+- 读写都很频繁、单锁成为瓶颈时改用分片版本
+
+优缺点：
+- 优点：互斥锁版本简单可靠；分片版本在多核下吞吐量更高
+- 缺点：分片版本的淘汰只在分片内进行，热点集中在少数分片时这些分片会过早淘汰；
+  Keys、Size 需要依次锁住所有分片，结果不是某一时刻的精确快照
+
+以下实现了互斥锁版本和分片版本的线程安全缓存，并在协程池中对比两者的吞吐量。
+*/
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/strive/scenario/cache_strategies"
+	"github.com/strive/scenario/concurrency"
+	"github.com/strive/scenario/hashing"
+	"github.com/strive/scenario/workload"
+)
+
+// DefaultCacheShards 分片缓存的默认分片数
+const DefaultCacheShards = 16
+
+// SyncCache 用互斥锁保护任意缓存，可被多个协程并发使用
+type SyncCache[K comparable, V any] struct {
+	mu    sync.Mutex
+	cache cache_strategies.Cache[K, V]
+	loads cache_strategies.LoadGroup[K, V] // GetOrLoad 正在进行的加载
+}
+
+// NewSyncCache 用互斥锁包装缓存，包装后不应再直接访问原缓存
+func NewSyncCache[K comparable, V any](cache cache_strategies.Cache[K, V]) *SyncCache[K, V] {
+	return &SyncCache[K, V]{cache: cache}
+}
+
+// NewSyncLRUCache 创建线程安全的LRU缓存（标准库链表实现）
+func NewSyncLRUCache(capacity int) *SyncCache[string, interface{}] {
+	return NewSyncCache[string, interface{}](NewLRUCache(capacity))
+}
+
+// NewSyncLFUCache 创建线程安全的LFU缓存（标准库链表实现）
+func NewSyncLFUCache(capacity int) *SyncCache[string, interface{}] {
+	return NewSyncCache[string, interface{}](NewLFUCache(capacity))
+}
+
+// NewSyncCustomLRUCache 创建线程安全的LRU缓存（自定义链表实现）
+func NewSyncCustomLRUCache(capacity int) *SyncCache[string, interface{}] {
+	return NewSyncCache[string, interface{}](NewCustomLRUCache(capacity))
+}
+
+// NewSyncCustomLFUCache 创建线程安全的LFU缓存（自定义链表实现）
+func NewSyncCustomLFUCache(capacity int) *SyncCache[string, interface{}] {
+	return NewSyncCache[string, interface{}](NewCustomLFUCache(capacity))
+}
+
+// Get 获取缓存中的值，不存在返回零值和false
+func (c *SyncCache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cache.Get(key)
+}
+
+// GetOrLoad 获取缓存中的值，不存在时调用 loader 加载并回填；
+// 同一个键的并发加载只执行一次，loader 在锁外执行，加载失败时不回填
+func (c *SyncCache[K, V]) GetOrLoad(key K, loader func() (V, error)) (V, error) {
+	if value, ok := c.Get(key); ok {
+		return value, nil
+	}
+	value, err, _ := c.loads.Do(key, func() (V, error) {
+		value, err := loader()
+		if err == nil {
+			c.Put(key, value)
+		}
+		return value, err
+	})
+	return value, err
+}
+
+// Put 插入或更新缓存中的键值对
+func (c *SyncCache[K, V]) Put(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cache.Put(key, value)
+}
+
+// Remove 删除指定键
+func (c *SyncCache[K, V]) Remove(key K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cache.Remove(key)
+}
+
+// GetMulti 批量读取，返回命中的键值，整个批次只加一次锁
+func (c *SyncCache[K, V]) GetMulti(keys []K) map[K]V {
+	result := make(map[K]V, len(keys))
+	c.getMulti(keys, result)
+	return result
+}
+
+// getMulti 在一次加锁内读取 keys，把命中的键值写入 result
+func (c *SyncCache[K, V]) getMulti(keys []K, result map[K]V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if batch, ok := c.cache.(cache_strategies.BatchCache[K, V]); ok {
+		for key, value := range batch.GetMulti(keys) {
+			result[key] = value
+		}
+		return
+	}
+	for _, key := range keys {
+		if value, ok := c.cache.Get(key); ok {
+			result[key] = value
+		}
+	}
+}
+
+// PutMulti 批量写入，整个批次只加一次锁
+func (c *SyncCache[K, V]) PutMulti(items map[K]V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cache_strategies.PutMulti(c.cache, items)
+}
+
+// putMulti 在一次加锁内写入 items 中属于 keys 的条目
+func (c *SyncCache[K, V]) putMulti(keys []K, items map[K]V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range keys {
+		c.cache.Put(key, items[key])
+	}
+}
+
+// Size 返回当前缓存中的元素数量
+func (c *SyncCache[K, V]) Size() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cache.Size()
+}
+
+// Keys 返回所有键
+func (c *SyncCache[K, V]) Keys() []K {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cache.Keys()
+}
+
+// Clear 清空缓存
+func (c *SyncCache[K, V]) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cache.Clear()
+}
+
+// Do 在锁内对被包装的缓存执行一组操作，用于需要原子完成的复合操作（如先读后写）
+func (c *SyncCache[K, V]) Do(fn func(cache cache_strategies.Cache[K, V])) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fn(c.cache)
+}
+
+// CacheStats 返回被包装缓存的访问统计，不支持统计时返回零值
+func (c *SyncCache[K, V]) CacheStats() cache_strategies.CacheStats {
+	// 统计计数器本身是原子的，不需要持有锁
+	if reporter, ok := c.cache.(interface {
+		CacheStats() cache_strategies.CacheStats
+	}); ok {
+		return reporter.CacheStats()
+	}
+	return cache_strategies.CacheStats{}
+}
+
+// Stats 返回访问统计和当前大小
+func (c *SyncCache[K, V]) Stats() map[string]interface{} {
+	stats := c.CacheStats().Map()
+	stats["size"] = c.Size()
+	return stats
+}
+
+// ShardedCache 按键哈希分片的线程安全缓存，每个分片有独立的锁
+type ShardedCache[K comparable, V any] struct {
+	shards []*SyncCache[K, V]
+	hasher hashing.Hasher
+}
+
+// NewShardedCache 创建分片缓存，总容量平均分给各分片（余数分给前几个分片，每个分片至少为1），newShard 用于创建每个分片的缓存
+func NewShardedCache[K comparable, V any](shards, capacity int, newShard func(capacity int) cache_strategies.Cache[K, V]) *ShardedCache[K, V] {
+	if shards <= 0 {
+		shards = DefaultCacheShards
+	}
+	c := &ShardedCache[K, V]{
+		shards: make([]*SyncCache[K, V], shards),
+		hasher: hashing.NewFNV1a(0),
+	}
+	for i := range c.shards {
+		perShard := capacity / shards
+		if i < capacity%shards {
+			perShard++
+		}
+		c.shards[i] = NewSyncCache(newShard(max(perShard, 1)))
+	}
+	return c
+}
+
+// NewShardedLRUCache 创建分片的LRU缓存
+func NewShardedLRUCache(shards, capacity int) *ShardedCache[string, interface{}] {
+	return NewShardedCache(shards, capacity, func(capacity int) cache_strategies.Cache[string, interface{}] {
+		return NewLRUCache(capacity)
+	})
+}
+
+// NewShardedLFUCache 创建分片的LFU缓存
+func NewShardedLFUCache(shards, capacity int) *ShardedCache[string, interface{}] {
+	return NewShardedCache(shards, capacity, func(capacity int) cache_strategies.Cache[string, interface{}] {
+		return NewLFUCache(capacity)
+	})
+}
+
+// shard 返回键所在的分片
+func (c *ShardedCache[K, V]) shard(key K) *SyncCache[K, V] {
+	return c.shards[c.shardIndex(key)]
+}
+
+// shardIndex 返回键所在分片的下标
+func (c *ShardedCache[K, V]) shardIndex(key K) int {
+	var s string
+	if str, ok := any(key).(string); ok {
+		s = str
+	} else {
+		s = fmt.Sprint(key)
+	}
+	return int(hashing.SumString(c.hasher, s) % uint64(len(c.shards)))
+}
+
+// Get 获取缓存中的值，不存在返回零值和false
+func (c *ShardedCache[K, V]) Get(key K) (V, bool) {
+	return c.shard(key).Get(key)
+}
+
+// GetOrLoad 获取缓存中的值，不存在时由键所在的分片合并加载并回填
+func (c *ShardedCache[K, V]) GetOrLoad(key K, loader func() (V, error)) (V, error) {
+	return c.shard(key).GetOrLoad(key, loader)
+}
+
+// Put 插入或更新缓存中的键值对
+func (c *ShardedCache[K, V]) Put(key K, value V) {
+	c.shard(key).Put(key, value)
+}
+
+// Remove 删除指定键
+func (c *ShardedCache[K, V]) Remove(key K) bool {
+	return c.shard(key).Remove(key)
+}
+
+// GetMulti 按分片分组后批量读取，每个分片只加一次锁
+func (c *ShardedCache[K, V]) GetMulti(keys []K) map[K]V {
+	result := make(map[K]V, len(keys))
+	c.groupByShard(keys, func(shard *SyncCache[K, V], group []K) {
+		shard.getMulti(group, result)
+	})
+	return result
+}
+
+// PutMulti 按分片分组后批量写入，每个分片只加一次锁
+func (c *ShardedCache[K, V]) PutMulti(items map[K]V) {
+	keys := make([]K, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	c.groupByShard(keys, func(shard *SyncCache[K, V], group []K) {
+		shard.putMulti(group, items)
+	})
+}
+
+// groupByShard 把 keys 按所在分片分组，对每个非空分组调用一次 fn；分组复用同一个缓冲区，fn 不能保留 group
+func (c *ShardedCache[K, V]) groupByShard(keys []K, fn func(shard *SyncCache[K, V], group []K)) {
+	indexes := make([]int, len(keys))
+	for i, key := range keys {
+		indexes[i] = c.shardIndex(key)
+	}
+	group := make([]K, 0, len(keys))
+	for i, shard := range c.shards {
+		group = group[:0]
+		for j, index := range indexes {
+			if index == i {
+				group = append(group, keys[j])
+			}
+		}
+		if len(group) > 0 {
+			fn(shard, group)
+		}
+	}
+}
+
+// Size 返回所有分片的元素数量之和
+func (c *ShardedCache[K, V]) Size() int {
+	size := 0
+	for _, shard := range c.shards {
+		size += shard.Size()
+	}
+	return size
+}
+
+// Keys 依次返回各分片的键
+func (c *ShardedCache[K, V]) Keys() []K {
+	var keys []K
+	for _, shard := range c.shards {
+		keys = append(keys, shard.Keys()...)
+	}
+	return keys
+}
+
+// Clear 清空所有分片
+func (c *ShardedCache[K, V]) Clear() {
+	for _, shard := range c.shards {
+		shard.Clear()
+	}
+}
+
+// Shards 返回分片数
+func (c *ShardedCache[K, V]) Shards() int {
+	return len(c.shards)
+}
+
+// CacheStats 返回所有分片汇总的访问统计
+func (c *ShardedCache[K, V]) CacheStats() cache_strategies.CacheStats {
+	var total cache_strategies.CacheStats
+	for _, shard := range c.shards {
+		stats := shard.CacheStats()
+		total.Hits += stats.Hits
+		total.Misses += stats.Misses
+		total.Evictions += stats.Evictions
+		total.Expirations += stats.Expirations
+	}
+	return total
+}
+
+// Stats 返回汇总的访问统计、分片数和各分片的大小
+func (c *ShardedCache[K, V]) Stats() map[string]interface{} {
+	sizes := make([]int, len(c.shards))
+	for i, shard := range c.shards {
+		sizes[i] = shard.Size()
+	}
+	stats := c.CacheStats().Map()
+	stats["shards"] = len(c.shards)
+	stats["shardSizes"] = sizes
+	stats["size"] = c.Size()
+	return stats
+}
+
+// 场景示例：协程池中的多个工作协程共享商品缓存
+func ConcurrentCacheDemo() {
+	const (
+		workers    = 8
+		tasks      = 64
+		perTask    = 5000
+		capacity   = 1000
+		keys       = 10000
+		zipfFactor = 1.1
+	)
+	fmt.Printf("线程安全缓存示例 (%d个工作协程，%d次访问，容量=%d):\n", workers, tasks*perTask, capacity)
+
+	// 每个任务有自己的访问轨迹，避免在任务之间共享随机数生成器
+	traces := make([][]string, tasks)
+	for i := range traces {
+		traces[i] = workload.Strings(workload.NewZipf(keys, zipfFactor, int64(i)), perTask, "sku-")
+	}
+
+	caches := []struct {
+		name  string
+		cache cache_strategies.Cache[string, interface{}]
+	}{
+		{"LRU (互斥锁)", NewSyncLRUCache(capacity)},
+		{"LFU (互斥锁)", NewSyncLFUCache(capacity)},
+		{"自定义LRU (互斥锁)", NewSyncCustomLRUCache(capacity)},
+		{"自定义LFU (互斥锁)", NewSyncCustomLFUCache(capacity)},
+		{"LRU (16分片)", NewShardedLRUCache(DefaultCacheShards, capacity)},
+		{"LFU (16分片)", NewShardedLFUCache(DefaultCacheShards, capacity)},
+	}
+
+	for _, c := range caches {
+		pool := concurrency.NewGoroutinePool(workers, tasks)
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		hits, total := 0, 0
+
+		start := time.Now()
+		for _, trace := range traces {
+			wg.Add(1)
+			err := pool.Submit(func(context.Context) error {
+				defer wg.Done()
+				localHits := 0
+				for _, key := range trace {
+					if _, ok := c.cache.Get(key); ok {
+						localHits++
+					} else {
+						c.cache.Put(key, "详情:"+key) // 未命中时回源并回填
+					}
+				}
+				mu.Lock()
+				hits += localHits
+				total += len(trace)
+				mu.Unlock()
+				return nil
+			})
+			if err != nil {
+				wg.Done()
+				fmt.Printf("提交任务失败: %v\n", err)
+			}
+		}
+		wg.Wait()
+		elapsed := time.Since(start)
+		pool.Shutdown()
+
+		fmt.Printf("  %-20s 耗时 %-10v 吞吐 %6.2f 百万次/秒  命中率 %5.1f%%  大小 %d/%d\n",
+			c.name, elapsed.Round(time.Millisecond), float64(total)/elapsed.Seconds()/1e6,
+			float64(hits)/float64(total)*100, c.cache.Size(), capacity)
+	}
+
+	// 批量读取：每连续16个键作为一次请求，用 GetMulti 一次读完，未命中的键回源后用 PutMulti 一次写回
+	const batchSize = 16
+	fmt.Printf("\n批量读写 (每批%d个键):\n", batchSize)
+	for _, c := range []struct {
+		name  string
+		cache cache_strategies.BatchCache[string, interface{}]
+	}{
+		{"LRU (互斥锁)", NewSyncLRUCache(capacity)},
+		{"LRU (16分片)", NewShardedLRUCache(DefaultCacheShards, capacity)},
+	} {
+		pool := concurrency.NewGoroutinePool(workers, tasks)
+		var wg sync.WaitGroup
+		var hits, total int64
+
+		start := time.Now()
+		for _, trace := range traces {
+			wg.Add(1)
+			err := pool.Submit(func(context.Context) error {
+				defer wg.Done()
+				for i := 0; i < len(trace); i += batchSize {
+					keys := trace[i:min(i+batchSize, len(trace))]
+					found := c.cache.GetMulti(keys)
+					missing := make(map[string]interface{})
+					for _, key := range keys {
+						if _, ok := found[key]; !ok {
+							missing[key] = "详情:" + key
+						}
+					}
+					c.cache.PutMulti(missing)
+					atomic.AddInt64(&hits, int64(len(keys)-len(missing)))
+				}
+				atomic.AddInt64(&total, int64(len(trace)))
+				return nil
+			})
+			if err != nil {
+				wg.Done()
+				fmt.Printf("提交任务失败: %v\n", err)
+			}
+		}
+		wg.Wait()
+		elapsed := time.Since(start)
+		pool.Shutdown()
+
+		fmt.Printf("  %-20s 耗时 %-10v 吞吐 %6.2f 百万次/秒  命中率 %5.1f%%  大小 %d/%d\n",
+			c.name+" 批量", elapsed.Round(time.Millisecond), float64(total)/elapsed.Seconds()/1e6,
+			float64(hits)/float64(total)*100, c.cache.Size(), capacity)
+	}
+
+	// 复合操作：库存扣减需要读和写在同一把锁内完成
+	stock := NewSyncLRUCache(10)
+	stock.Put("iPhone", 100)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stock.Do(func(cache cache_strategies.Cache[string, interface{}]) {
+				if value, ok := cache.Get("iPhone"); ok {
+					cache.Put("iPhone", value.(int)-1)
+				}
+			})
+		}()
+	}
+	wg.Wait()
+	remaining, _ := stock.Get("iPhone")
+	fmt.Printf("\n50个协程并发扣减库存后剩余: %v（预期50）\n", remaining)
+	fmt.Printf("库存缓存统计: %s\n", stock.CacheStats())
+
+	// 热门商品详情被淘汰后，并发请求通过 GetOrLoad 只回源一次
+	details := NewShardedLRUCache(DefaultCacheShards, capacity)
+	var loads int64
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			details.GetOrLoad("sku-hot", func() (interface{}, error) {
+				atomic.AddInt64(&loads, 1)
+				time.Sleep(10 * time.Millisecond) // 模拟查询数据库
+				return "详情:sku-hot", nil
+			})
+		}()
+	}
+	wg.Wait()
+	fmt.Printf("50个协程并发读取未缓存的热门商品: 回源 %d 次\n", atomic.LoadInt64(&loads))
+}
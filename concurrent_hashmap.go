@@ -1,64 +1,194 @@
 package main
 
+/*
+分段锁并发哈希映射
+
+原理：
+用一把读写锁保护整个 map 时，所有写操作（以及与写操作重叠的读操作）都在同一把锁上排队，
+核数越多，锁竞争越严重。分段锁（lock striping）把 map 按键的哈希拆成 N 个段，每段有自己的锁和 map，
+不同段上的操作互不阻塞，冲突概率大约降为原来的 1/N。
+只涉及一个键的复合操作（不存在才写入、比较后交换、读-改-写）在该键所在段的锁内完成，因此是原子的。
+
+关键特点：
+1. 段数在创建时确定，键用 FNV-1a 哈希选择段，同一个键总是落在同一个段
+2. Get 只获取段的读锁，Set、Delete 只获取段的写锁
+3. LoadOrStore、CompareAndSwap、Compute 在段锁内完成读和写，不会被其他协程插入修改
+4. Size、Keys 需要依次获取所有段的读锁，得到的是各段在不同时刻的快照之和，不是整个 map 的原子快照
+
+实现方式：
+- 段数组，每个段是读写锁加普通 map
+- Compute 的回调在段锁内执行，回调中不能再访问同一个 map，否则可能死锁
+- CompareAndSwap 用 == 比较旧值，旧值必须是可比较的类型，与 sync.Map 相同
+
+应用场景：
+- 读写都很频繁、键分布较均匀的共享状态：会话表、连接表、计数器表
+- 需要"检查后写入"原子语义的场景：去重、幂等键、按键累加
+- 与 sync.Map 的对比见 concurrent_hashmap_benchmark.go
+
+优缺点：
+- 优点：实现简单，写多的场景比单锁和 sync.Map 更稳定；复合操作原子
+- 缺点：每次操作都要计算哈希；热点键集中在一个段时退化为单锁；
+  Size、Keys 需要遍历所有段；段数固定，不随并发度自动调整
+
+以下实现了分段锁并发哈希映射，以及 LoadOrStore、CompareAndSwap、Compute 原子操作的示例。
+*/
+
 import (
 	"fmt"
 	"sync"
+
+	"github.com/strive/scenario/hashing"
 )
 
-// ConcurrentHashMap 是一个线程安全的哈希映射实现
-type ConcurrentHashMap struct {
+// DefaultHashMapSegments 默认段数
+const DefaultHashMapSegments = 16
+
+// hashMapSegment 并发哈希映射的一个段
+type hashMapSegment struct {
 	mu    sync.RWMutex
 	items map[string]interface{}
 }
 
-// NewConcurrentHashMap 创建一个新的并发哈希映射
+// ConcurrentHashMap 是一个线程安全的哈希映射实现，按键的哈希分段加锁
+type ConcurrentHashMap struct {
+	segments []*hashMapSegment
+	hasher   hashing.Hasher
+}
+
+// NewConcurrentHashMap 创建一个新的并发哈希映射，使用默认段数
 func NewConcurrentHashMap() *ConcurrentHashMap {
-	return &ConcurrentHashMap{
-		items: make(map[string]interface{}),
+	return NewConcurrentHashMapWithSegments(DefaultHashMapSegments)
+}
+
+// NewConcurrentHashMapWithSegments 创建指定段数的并发哈希映射，段数为1时等价于单锁实现
+func NewConcurrentHashMapWithSegments(segments int) *ConcurrentHashMap {
+	if segments <= 0 {
+		segments = DefaultHashMapSegments
 	}
+	m := &ConcurrentHashMap{
+		segments: make([]*hashMapSegment, segments),
+		hasher:   hashing.NewFNV1a(0),
+	}
+	for i := range m.segments {
+		m.segments[i] = &hashMapSegment{items: make(map[string]interface{})}
+	}
+	return m
+}
+
+// segment 返回键所在的段
+func (m *ConcurrentHashMap) segment(key string) *hashMapSegment {
+	if len(m.segments) == 1 {
+		return m.segments[0]
+	}
+	return m.segments[hashing.SumString(m.hasher, key)%uint64(len(m.segments))]
 }
 
 // Set 添加或更新键值对
 func (m *ConcurrentHashMap) Set(key string, value interface{}) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	m.items[key] = value
+	seg := m.segment(key)
+	seg.mu.Lock()
+	defer seg.mu.Unlock()
+	seg.items[key] = value
 }
 
 // Get 获取指定键的值
 func (m *ConcurrentHashMap) Get(key string) (interface{}, bool) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	value, exists := m.items[key]
+	seg := m.segment(key)
+	seg.mu.RLock()
+	defer seg.mu.RUnlock()
+	value, exists := seg.items[key]
 	return value, exists
 }
 
 // Delete 删除指定键值对
 func (m *ConcurrentHashMap) Delete(key string) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	delete(m.items, key)
+	seg := m.segment(key)
+	seg.mu.Lock()
+	defer seg.mu.Unlock()
+	delete(seg.items, key)
+}
+
+// LoadOrStore 键存在时返回已有的值和 true，否则写入 value 并返回 value 和 false
+func (m *ConcurrentHashMap) LoadOrStore(key string, value interface{}) (actual interface{}, loaded bool) {
+	seg := m.segment(key)
+	seg.mu.Lock()
+	defer seg.mu.Unlock()
+	if existing, exists := seg.items[key]; exists {
+		return existing, true
+	}
+	seg.items[key] = value
+	return value, false
+}
+
+// CompareAndSwap 键存在且当前值等于 old 时替换为 new 并返回 true；old 必须是可比较的类型
+func (m *ConcurrentHashMap) CompareAndSwap(key string, old, new interface{}) bool {
+	seg := m.segment(key)
+	seg.mu.Lock()
+	defer seg.mu.Unlock()
+	if current, exists := seg.items[key]; !exists || current != old {
+		return false
+	}
+	seg.items[key] = new
+	return true
+}
+
+// Compute 在段锁内用 fn 计算键的新值：fn 收到当前值和键是否存在，返回新值和是否保留；
+// keep 为 false 时删除该键。返回计算后的值和键是否存在。fn 中不能再访问同一个 map
+func (m *ConcurrentHashMap) Compute(key string, fn func(old interface{}, exists bool) (value interface{}, keep bool)) (interface{}, bool) {
+	seg := m.segment(key)
+	seg.mu.Lock()
+	defer seg.mu.Unlock()
+	old, exists := seg.items[key]
+	value, keep := fn(old, exists)
+	if !keep {
+		delete(seg.items, key)
+		return nil, false
+	}
+	seg.items[key] = value
+	return value, true
 }
 
 // Size 返回映射大小
 func (m *ConcurrentHashMap) Size() int {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	return len(m.items)
+	size := 0
+	for _, seg := range m.segments {
+		seg.mu.RLock()
+		size += len(seg.items)
+		seg.mu.RUnlock()
+	}
+	return size
 }
 
 // Keys 返回所有键的列表
 func (m *ConcurrentHashMap) Keys() []string {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
-	keys := make([]string, 0, len(m.items))
-	for k := range m.items {
-		keys = append(keys, k)
+	keys := make([]string, 0, m.Size())
+	for _, seg := range m.segments {
+		seg.mu.RLock()
+		for k := range seg.items {
+			keys = append(keys, k)
+		}
+		seg.mu.RUnlock()
 	}
 	return keys
 }
 
+// Stats 返回映射的统计信息
+func (m *ConcurrentHashMap) Stats() map[string]interface{} {
+	sizes := make([]int, len(m.segments))
+	total := 0
+	for i, seg := range m.segments {
+		seg.mu.RLock()
+		sizes[i] = len(seg.items)
+		seg.mu.RUnlock()
+		total += sizes[i]
+	}
+	return map[string]interface{}{
+		"segments":     len(m.segments),
+		"size":         total,
+		"segmentSizes": sizes,
+	}
+}
+
 // ConcurrentHashMapDemo 演示并发哈希映射的使用
 func ConcurrentHashMapDemo() {
 	hashMap := NewConcurrentHashMap()
@@ -89,4 +219,74 @@ func ConcurrentHashMapDemo() {
 	wg.Wait()
 	fmt.Printf("最终哈希映射大小: %d\n", hashMap.Size())
 	fmt.Printf("所有键: %v\n", hashMap.Keys())
+
+	// LoadOrStore：多个协程同时为同一个会话创建对象，只有一个写入成功，其他协程拿到同一个对象
+	var created int
+	var createdMu sync.Mutex
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			if _, loaded := hashMap.LoadOrStore("session-1", fmt.Sprintf("由协程%d创建", id)); !loaded {
+				createdMu.Lock()
+				created++
+				createdMu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	session, _ := hashMap.Get("session-1")
+	fmt.Printf("\n20 个协程 LoadOrStore 同一个会话: 创建 %d 次，会话 %v\n", created, session)
+
+	// CompareAndSwap：乐观锁更新库存，失败后重新读取再试
+	hashMap.Set("stock", 100)
+	var retries int64
+	var retriesMu sync.Mutex
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				current, _ := hashMap.Get("stock")
+				if hashMap.CompareAndSwap("stock", current, current.(int)-1) {
+					return
+				}
+				retriesMu.Lock()
+				retries++
+				retriesMu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	stock, _ := hashMap.Get("stock")
+	fmt.Printf("50 个协程 CAS 扣减库存: 剩余 %v，冲突重试 %d 次\n", stock, retries)
+
+	// Compute：按键原子累加，计数减到0时删除
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			hashMap.Compute(fmt.Sprintf("page-%d", id%3), func(old interface{}, exists bool) (interface{}, bool) {
+				if !exists {
+					return 1, true
+				}
+				return old.(int) + 1, true
+			})
+		}(i)
+	}
+	wg.Wait()
+	for i := 0; i < 3; i++ {
+		views, _ := hashMap.Get(fmt.Sprintf("page-%d", i))
+		fmt.Printf("page-%d 访问次数: %v\n", i, views)
+	}
+	_, exists := hashMap.Compute("session-1", func(old interface{}, exists bool) (interface{}, bool) {
+		return nil, false // 会话结束，删除
+	})
+	fmt.Printf("Compute 删除 session-1 后是否存在: %v\n", exists)
+
+	stats := hashMap.Stats()
+	fmt.Printf("段数: %d，总大小: %d，各段大小: %v\n", stats["segments"], stats["size"], stats["segmentSizes"])
+
+	fmt.Println()
+	ConcurrentHashMapBenchmarkDemo()
 }
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/strive/scenario/report"
+)
+
+// DefaultHashMapReadPercents 默认测量的读操作占比
+var DefaultHashMapReadPercents = []int{90, 50}
+
+// benchmarkMap 参与基准测试的并发映射需要实现的最小接口
+type benchmarkMap interface {
+	Get(key string) (interface{}, bool)
+	Set(key string, value interface{})
+}
+
+// syncMapAdapter 把 sync.Map 适配为 benchmarkMap
+type syncMapAdapter struct {
+	m sync.Map
+}
+
+func (a *syncMapAdapter) Get(key string) (interface{}, bool) { return a.m.Load(key) }
+func (a *syncMapAdapter) Set(key string, value interface{})  { a.m.Store(key, value) }
+
+// hashMapMetric 返回某个读占比的吞吐量指标名
+func hashMapMetric(readPercent int) string {
+	return fmt.Sprintf("读%d%%写%d%%(万次/秒)", readPercent, 100-readPercent)
+}
+
+// measureHashMap 用 workers 个协程在 keys 上执行共 ops 次操作，读操作占 readPercent%，返回每秒操作数（万）
+func measureHashMap(m benchmarkMap, keys []string, workers, ops, readPercent int) float64 {
+	for i, key := range keys {
+		m.Set(key, i)
+	}
+	perWorker := ops / workers
+	var wg sync.WaitGroup
+	start := time.Now()
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(int64(w)))
+			for i := 0; i < perWorker; i++ {
+				key := keys[rng.Intn(len(keys))]
+				if rng.Intn(100) < readPercent {
+					m.Get(key)
+				} else {
+					m.Set(key, i)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	return float64(workers*perWorker) / time.Since(start).Seconds() / 1e4
+}
+
+// ConcurrentHashMapBenchmark 对比单锁映射、分段锁映射和 sync.Map 在不同读写比例下的吞吐量
+func ConcurrentHashMapBenchmark(keyCount, workers, ops int, readPercents []int) *report.Comparison {
+	metrics := make([]report.Metric, 0, len(readPercents))
+	for _, p := range readPercents {
+		metrics = append(metrics, report.Metric{Name: hashMapMetric(p), Precision: 0})
+	}
+	keys := make([]string, keyCount)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+	}
+	input := fmt.Sprintf("%d 个键，%d 个协程共 %d 次随机读写，GOMAXPROCS=%d", keyCount, workers, ops, runtime.GOMAXPROCS(0))
+	comparison := report.NewComparison("并发哈希映射对比", input, metrics...)
+
+	contenders := []struct {
+		name   string
+		newMap func() benchmarkMap
+	}{
+		{"单锁(1段)", func() benchmarkMap { return NewConcurrentHashMapWithSegments(1) }},
+		{fmt.Sprintf("分段锁(%d段)", DefaultHashMapSegments), func() benchmarkMap { return NewConcurrentHashMap() }},
+		{"sync.Map", func() benchmarkMap { return &syncMapAdapter{} }},
+	}
+	for _, c := range contenders {
+		comparison.Measure(c.name, func() (map[string]float64, error) {
+			values := make(map[string]float64, len(readPercents))
+			for _, p := range readPercents {
+				values[hashMapMetric(p)] = measureHashMap(c.newMap(), keys, workers, ops, p)
+			}
+			return values, nil
+		})
+	}
+	return comparison
+}
+
+// ConcurrentHashMapBenchmarkDemo 输出并发哈希映射的吞吐量对比
+func ConcurrentHashMapBenchmarkDemo() {
+	fmt.Println("并发哈希映射吞吐量基准:")
+	ConcurrentHashMapBenchmark(10000, 8, 1000000, DefaultHashMapReadPercents).Write(os.Stdout, report.FormatMarkdown)
+	fmt.Println("sync.Map 针对读多写少、键集合稳定的场景优化，写入比例升高后需要频繁迁移只读视图；" +
+		"分段锁在各种读写比例下都比较稳定，核数越多相对单锁的优势越明显")
+}
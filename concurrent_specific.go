@@ -1,61 +1,25 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"sync"
 )
 
 // SpecificRulePrint 一个线程打印3的倍数，另一个打印其他数
 func SpecificRulePrint() {
-	var mu sync.Mutex
-	cond := sync.NewCond(&mu)
-
-	currentNum := 1
-	var wg sync.WaitGroup
-	wg.Add(2)
+	// 互斥锁策略：连续的非3的倍数由同一个线程处理，其他线程继续等待
+	rotation := NewRotation(RotationOptions{Strategy: RotationMutex, Start: 1, End: 100})
 
 	// 线程1打印3的倍数
-	go func() {
-		defer wg.Done()
-		for {
-			mu.Lock()
-			for currentNum <= 100 && currentNum%3 != 0 {
-				cond.Wait() // 等待直到轮到3的倍数
-			}
-
-			if currentNum > 100 {
-				mu.Unlock()
-				break
-			}
-
-			fmt.Printf("规则-线程1: %d (3的倍数)\n", currentNum)
-			currentNum++
-			cond.Broadcast() // 通知所有等待的线程
-			mu.Unlock()
-		}
-	}()
+	rotation.Register("线程1", func(n int) bool { return n%3 == 0 }, func(n int) {
+		fmt.Printf("规则-线程1: %d (3的倍数)\n", n)
+	})
 
 	// 线程2打印非3的倍数
-	go func() {
-		defer wg.Done()
-		for {
-			mu.Lock()
-			for currentNum <= 100 && currentNum%3 == 0 {
-				cond.Wait() // 等待直到轮到非3的倍数
-			}
-
-			if currentNum > 100 {
-				mu.Unlock()
-				break
-			}
-
-			fmt.Printf("规则-线程2: %d\n", currentNum)
-			currentNum++
-			cond.Broadcast() // 通知所有等待的线程
-			mu.Unlock()
-		}
-	}()
+	rotation.Register("线程2", func(n int) bool { return n%3 != 0 }, func(n int) {
+		fmt.Printf("规则-线程2: %d\n", n)
+	})
 
-	wg.Wait()
+	rotation.Run(context.Background())
 	fmt.Println("特定规则打印完成")
 }
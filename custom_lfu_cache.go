@@ -15,6 +15,9 @@ LFU缓存淘汰策略：当缓存满时，首先淘汰访问频率最低的数
 
 import (
 	"fmt"
+	"sort"
+
+	"github.com/strive/scenario/sizeof"
 )
 
 // CustomLFUNode 自定义LFU缓存节点结构
@@ -137,6 +140,66 @@ func (c *CustomLFUCache) Put(key string, value interface{}) {
 	c.cache[key] = node
 }
 
+// Remove 删除指定键
+func (c *CustomLFUCache) Remove(key string) bool {
+	node, exists := c.cache[key]
+	if !exists {
+		return false
+	}
+	lfuNode := node.Value.(*CustomLFUNode)
+	freqList := c.freqMap[lfuNode.Freq]
+	freqList.Remove(node)
+	delete(c.cache, key)
+
+	// 删除的是最小频率链表的最后一个节点时，重新计算最小频率，避免淘汰时找不到节点
+	if freqList.Len() == 0 {
+		delete(c.freqMap, lfuNode.Freq)
+		if lfuNode.Freq == c.minFreq {
+			c.minFreq = 0
+			for freq, l := range c.freqMap {
+				if l.Len() > 0 && (c.minFreq == 0 || freq < c.minFreq) {
+					c.minFreq = freq
+				}
+			}
+		}
+	}
+	return true
+}
+
+// Size 返回当前缓存中的元素数量
+func (c *CustomLFUCache) Size() int {
+	return len(c.cache)
+}
+
+// Keys 返回所有键（按频率从低到高）
+func (c *CustomLFUCache) Keys() []string {
+	freqs := make([]int, 0, len(c.freqMap))
+	for freq := range c.freqMap {
+		freqs = append(freqs, freq)
+	}
+	sort.Ints(freqs)
+
+	keys := make([]string, 0, len(c.cache))
+	for _, freq := range freqs {
+		for node := c.freqMap[freq].Back(); node != nil; node = node.Prev() {
+			keys = append(keys, node.Value.(*CustomLFUNode).Key)
+		}
+	}
+	return keys
+}
+
+// Clear 清空缓存
+func (c *CustomLFUCache) Clear() {
+	c.cache = make(map[string]*ListNode)
+	c.freqMap = make(map[int]*List)
+	c.minFreq = 0
+}
+
+// MemoryUsage 返回深度估算的内存占用（字节）
+func (c *CustomLFUCache) MemoryUsage() int64 {
+	return sizeof.Of(c)
+}
+
 // 场景示例：视频播放器缓存
 func CustomLFUCacheDemo() {
 	// 创建容量为4的LFU缓存，用于存储视频片段
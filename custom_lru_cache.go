@@ -13,6 +13,8 @@ LRU缓存淘汰策略：当缓存满时，淘汰最长时间未被访问的数
 
 import (
 	"fmt"
+
+	"github.com/strive/scenario/sizeof"
 )
 
 // CustomLRUNode 自定义LRU缓存节点结构
@@ -81,6 +83,41 @@ func (c *CustomLRUCache) Put(key string, value interface{}) {
 	c.cache[key] = newNode
 }
 
+// Remove 删除指定键
+func (c *CustomLRUCache) Remove(key string) bool {
+	if node, exists := c.cache[key]; exists {
+		c.list.Remove(node)
+		delete(c.cache, key)
+		return true
+	}
+	return false
+}
+
+// Size 返回当前缓存中的元素数量
+func (c *CustomLRUCache) Size() int {
+	return c.list.Len()
+}
+
+// Keys 返回所有键（从最近使用到最久未使用）
+func (c *CustomLRUCache) Keys() []string {
+	keys := make([]string, 0, c.list.Len())
+	for node := c.list.Front(); node != nil; node = node.Next() {
+		keys = append(keys, node.Value.(*CustomLRUNode).Key)
+	}
+	return keys
+}
+
+// Clear 清空缓存
+func (c *CustomLRUCache) Clear() {
+	c.cache = make(map[string]*ListNode)
+	c.list = NewList()
+}
+
+// MemoryUsage 返回深度估算的内存占用（字节）
+func (c *CustomLRUCache) MemoryUsage() int64 {
+	return sizeof.Of(c)
+}
+
 // 场景示例：文件系统缓存
 func CustomLRUCacheDemo() {
 	// 创建容量为4的LRU缓存
@@ -0,0 +1,363 @@
+package graph_algorithms
+
+/*
+中介中心性与接近中心性
+
+原理：
+中心性衡量节点在图中的"重要程度"，不同的定义对应不同的直觉：
+- 中介中心性（Betweenness）：经过该节点的最短路径占所有最短路径的比例。
+  中介中心性高的节点是不同群体之间的"桥梁"，在社交网络中往往是信息传播的关键人物
+- 接近中心性（Closeness）：该节点到其他所有节点的平均最短距离的倒数。
+  接近中心性高的节点位于图的"中心"，低的节点位于边缘
+
+直接按定义计算中介中心性需要枚举所有节点对之间的全部最短路径，复杂度 O(n³)。
+Brandes 算法从每个源点做一次最短路径搜索，按距离从远到近回溯，
+把"依赖度"从后继累加到前驱，总复杂度降为 O(nm + n² log n)。
+
+关键特点：
+1. 支持带权图（Dijkstra）和有向图，无向图的中介中心性按惯例除以2
+2. 接近中心性采用 Wasserman–Faust 修正，对不连通的图也有意义
+3. 社交网络缓存中心性结果，好友关系变化时失效
+4. 中心性分数的两个应用：好友推荐中提升有影响力的用户；为 ALT 路径算法选择地标
+
+实现方式：
+- Brandes：记录每个节点的最短路径条数 σ 和前驱列表，按出栈顺序累加依赖度 δ
+- ALT（A*、地标、三角不等式）：预先计算各地标到所有节点的距离，
+  利用 |d(L,t) − d(L,v)| ≤ d(v,t) 得到比直线距离更紧的下界；
+  地标应分布在图的边缘，因此选择接近中心性最低、且互不相邻的节点
+
+应用场景：
+- 识别社交网络中的意见领袖和跨群体的连接者
+- 发现交通网络中的关键路口和瓶颈路段
+- 为路径查询选择地标，加速大规模路网上的A*搜索
+
+优缺点：
+- 优点：Brandes 算法是精确算法，复杂度远低于朴素方法；ALT 启发式不依赖坐标
+- 缺点：大图上全量计算仍然昂贵，需要缓存或采样；ALT 需要额外存储 地标数×节点数 的距离表
+
+以下实现了两种中心性、社交网络上的缓存与推荐加权，以及导航图的地标选择。
+*/
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// CentralityKind 中心性类型
+type CentralityKind int
+
+// 中心性类型
+const (
+	CentralityBetweenness CentralityKind = iota // 中介中心性
+	CentralityCloseness                         // 接近中心性
+)
+
+// String 返回中心性类型名称
+func (k CentralityKind) String() string {
+	switch k {
+	case CentralityBetweenness:
+		return "中介中心性"
+	case CentralityCloseness:
+		return "接近中心性"
+	default:
+		return fmt.Sprintf("未知(%d)", int(k))
+	}
+}
+
+// shortestPathDAG 从 source 出发的单源最短路径，返回按距离递增的出栈顺序、距离、最短路径条数和前驱
+func shortestPathDAG(g *Graph, source string) ([]string, map[string]float64, map[string]float64, map[string][]string) {
+	order := make([]string, 0, g.NodeCount())
+	dist := map[string]float64{source: 0}
+	sigma := map[string]float64{source: 1}
+	preds := make(map[string][]string)
+	settled := make(map[string]bool)
+
+	pq := PathPriorityQueue{{NodeID: source, Distance: 0}}
+	for pq.Len() > 0 {
+		current := heap.Pop(&pq).(*DijkstraItem)
+		v := current.NodeID
+		if settled[v] {
+			continue
+		}
+		settled[v] = true
+		order = append(order, v)
+
+		for w, weight := range g.adjacency[v] {
+			alt := dist[v] + weight
+			d, seen := dist[w]
+			switch {
+			case !seen || alt < d-1e-12:
+				dist[w] = alt
+				sigma[w] = sigma[v]
+				preds[w] = []string{v}
+				heap.Push(&pq, &DijkstraItem{NodeID: w, Distance: alt})
+			case math.Abs(alt-d) <= 1e-12 && !settled[w]:
+				sigma[w] += sigma[v]
+				preds[w] = append(preds[w], v)
+			}
+		}
+	}
+	return order, dist, sigma, preds
+}
+
+// BetweennessCentrality 使用 Brandes 算法计算中介中心性，normalized 为 true 时除以节点对的数量
+func BetweennessCentrality(g *Graph, normalized bool) map[string]float64 {
+	centrality := make(map[string]float64, g.NodeCount())
+	for _, id := range g.Nodes() {
+		centrality[id] = 0
+	}
+
+	for _, source := range g.Nodes() {
+		order, _, sigma, preds := shortestPathDAG(g, source)
+
+		// 按距离从远到近回溯，累加依赖度
+		delta := make(map[string]float64, len(order))
+		for i := len(order) - 1; i >= 0; i-- {
+			w := order[i]
+			for _, v := range preds[w] {
+				delta[v] += sigma[v] / sigma[w] * (1 + delta[w])
+			}
+			if w != source {
+				centrality[w] += delta[w]
+			}
+		}
+	}
+
+	n := float64(g.NodeCount())
+	scale := 1.0
+	if !g.Directed {
+		scale = 0.5 // 无向图中每条路径被两个端点各计算一次
+	}
+	if normalized && n > 2 {
+		scale /= (n - 1) * (n - 2)
+		if !g.Directed {
+			scale *= 2
+		}
+	}
+	for id := range centrality {
+		centrality[id] *= scale
+	}
+	return centrality
+}
+
+// ClosenessCentrality 计算接近中心性（Wasserman–Faust 修正：乘以可达节点的比例）
+func ClosenessCentrality(g *Graph) map[string]float64 {
+	n := g.NodeCount()
+	centrality := make(map[string]float64, n)
+	for _, source := range g.Nodes() {
+		_, dist, _, _ := shortestPathDAG(g, source)
+		total := 0.0
+		for _, d := range dist {
+			total += d
+		}
+		reachable := float64(len(dist) - 1)
+		if total > 0 && n > 1 {
+			centrality[source] = reachable / total * reachable / float64(n-1)
+		} else {
+			centrality[source] = 0
+		}
+	}
+	return centrality
+}
+
+// Centrality 计算好友关系图上的中心性，结果会被缓存直到好友关系发生变化
+func (sn *SocialNetwork) Centrality(kind CentralityKind) map[int]float64 {
+	if cached, ok := sn.centrality[kind]; ok {
+		return cached
+	}
+
+	var scores map[string]float64
+	switch kind {
+	case CentralityBetweenness:
+		scores = BetweennessCentrality(sn.FriendGraph(), true)
+	case CentralityCloseness:
+		scores = ClosenessCentrality(sn.FriendGraph())
+	default:
+		return nil
+	}
+
+	result := make(map[int]float64, len(scores))
+	for node, score := range scores {
+		id, _ := strconv.Atoi(node)
+		result[id] = score
+	}
+	if sn.centrality == nil {
+		sn.centrality = make(map[CentralityKind]map[int]float64)
+	}
+	sn.centrality[kind] = result
+	return result
+}
+
+// influence 返回用户的影响力：中介中心性相对最大值的比例，范围 [0, 1]
+func (sn *SocialNetwork) influence(userID int) float64 {
+	if sn.influences == nil {
+		scores := sn.Centrality(CentralityBetweenness)
+		maxScore := 0.0
+		for _, score := range scores {
+			if score > maxScore {
+				maxScore = score
+			}
+		}
+		sn.influences = make(map[int]float64, len(scores))
+		for id, score := range scores {
+			if maxScore > 0 {
+				sn.influences[id] = score / maxScore
+			}
+		}
+	}
+	return sn.influences[userID]
+}
+
+// invalidateCentrality 好友关系变化后清除缓存的中心性
+func (sn *SocialNetwork) invalidateCentrality() {
+	sn.centrality = nil
+	sn.influences = nil
+}
+
+// landmarkClosenessSamples 路网超过该节点数时，地标选择改用采样估计的接近中心性
+const landmarkClosenessSamples = 64
+
+// PrepareLandmarks 为 ALT 启发式选择 count 个地标并预计算距离表：
+// 地标取接近中心性最低（位于路网边缘）且互不相邻的节点
+func (g *NavigationGraph) PrepareLandmarks(count int) []string {
+	graph := g.ToGraph()
+
+	// 反向图用于计算各节点到地标的距离
+	reverse := NewGraph(true)
+	for _, from := range graph.Nodes() {
+		reverse.AddNode(from)
+		for _, to := range graph.Neighbors(from) {
+			weight, _ := graph.Weight(from, to)
+			reverse.AddEdge(to, from, weight)
+		}
+	}
+
+	candidates := graph.Nodes()
+	var closeness map[string]float64
+	if len(candidates) <= landmarkClosenessSamples {
+		closeness = ClosenessCentrality(graph)
+	} else {
+		// 精确计算需要对每个节点做一次 Dijkstra，大路网上改为从均匀间隔的采样节点出发，
+		// 在反向图上得到各节点到采样节点的距离，用平均距离的倒数估计接近中心性
+		closeness = make(map[string]float64, len(candidates))
+		totals := make(map[string]float64, len(candidates))
+		for i := 0; i < landmarkClosenessSamples; i++ {
+			_, dist, _, _ := shortestPathDAG(reverse, candidates[i*len(candidates)/landmarkClosenessSamples])
+			for id, d := range dist {
+				totals[id] += d
+			}
+		}
+		for id, total := range totals {
+			if total > 0 {
+				closeness[id] = 1 / total
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return closeness[candidates[i]] < closeness[candidates[j]]
+	})
+
+	g.landmarks = g.landmarks[:0]
+	g.fromLandmark = g.fromLandmark[:0]
+	g.toLandmark = g.toLandmark[:0]
+	chosen := make(map[string]bool)
+	for _, id := range candidates {
+		if len(g.landmarks) >= count {
+			break
+		}
+		adjacent := false
+		for neighbor := range graph.adjacency[id] {
+			if chosen[neighbor] {
+				adjacent = true
+				break
+			}
+		}
+		if adjacent {
+			continue
+		}
+		chosen[id] = true
+		_, from, _, _ := shortestPathDAG(graph, id)
+		_, to, _, _ := shortestPathDAG(reverse, id)
+		g.landmarks = append(g.landmarks, id)
+		g.fromLandmark = append(g.fromLandmark, from)
+		g.toLandmark = append(g.toLandmark, to)
+	}
+	return append([]string(nil), g.landmarks...)
+}
+
+// landmarkHeuristic 基于三角不等式的 ALT 下界，没有预计算地标时返回 0
+func (g *NavigationGraph) landmarkHeuristic(v, t string) float64 {
+	best := 0.0
+	for i := range g.landmarks {
+		// d(L,t) − d(L,v) ≤ d(v,t)
+		if dt, ok := g.fromLandmark[i][t]; ok {
+			if dv, ok := g.fromLandmark[i][v]; ok && dt-dv > best {
+				best = dt - dv
+			}
+		}
+		// d(v,L) − d(t,L) ≤ d(v,t)
+		if dv, ok := g.toLandmark[i][v]; ok {
+			if dt, ok := g.toLandmark[i][t]; ok && dv-dt > best {
+				best = dv - dt
+			}
+		}
+	}
+	return best
+}
+
+// 场景示例：找出社交网络中的关键人物，并用中心性选择导航地标
+func CentralityDemo() {
+	fmt.Println("中心性计算示例:")
+
+	sn := createDemoSocialNetwork()
+	top := func(kind CentralityKind, n int) {
+		scores := sn.Centrality(kind)
+		ids := make([]int, 0, len(scores))
+		for id := range scores {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool {
+			if scores[ids[i]] != scores[ids[j]] {
+				return scores[ids[i]] > scores[ids[j]]
+			}
+			return ids[i] < ids[j]
+		})
+		fmt.Printf("\n%s最高的 %d 个用户:\n", kind, n)
+		for _, id := range ids[:min(n, len(ids))] {
+			fmt.Printf("  %s: %.3f（%d 个好友）\n", sn.Users[id].Name, scores[id], len(sn.Users[id].Friends))
+		}
+	}
+	top(CentralityBetweenness, 5)
+	top(CentralityCloseness, 5)
+
+	// 好友推荐中提升有影响力的用户
+	target := 20
+	fmt.Printf("\n为 %s 推荐好友（影响力权重 %.2f 与 0 对比）:\n", sn.Users[target].Name, sn.InfluenceWeight)
+	for _, weight := range []float64{0, sn.InfluenceWeight} {
+		sn.InfluenceWeight = weight
+		recs, _ := sn.RecommendFriends(target, 3)
+		names := make([]string, len(recs))
+		for i, rec := range recs {
+			names[i] = fmt.Sprintf("%s(%.2f)", sn.Users[rec.ID].Name, rec.Score)
+		}
+		fmt.Printf("  权重 %.2f: %s\n", weight, joinStrings(names, ", "))
+	}
+
+	// 导航图的地标选择与 ALT 启发式
+	cityMap := createCityMap()
+	landmarks := cityMap.PrepareLandmarks(3)
+	names := make([]string, len(landmarks))
+	for i, id := range landmarks {
+		names[i] = cityMap.Nodes[id].Name
+	}
+	fmt.Printf("\n路网地标（接近中心性最低且互不相邻）: %s\n", joinStrings(names, ", "))
+	for _, pair := range [][2]string{{"QHD", "HD"}, {"ZJK", "TS"}} {
+		from, to := cityMap.Nodes[pair[0]], cityMap.Nodes[pair[1]]
+		route, _ := cityMap.FindShortestPath(pair[0], pair[1], RouteOptions{UseAStarAlgorithm: true, UseLandmarks: true})
+		fmt.Printf("  %s→%s: 实际距离 %.0f，直线距离下界 %.1f，ALT下界 %.0f\n", from.Name, to.Name,
+			route.Distance, from.Coordinate.Distance(to.Coordinate), cityMap.landmarkHeuristic(pair[0], pair[1]))
+	}
+}
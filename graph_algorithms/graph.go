@@ -0,0 +1,197 @@
+package graph_algorithms
+
+/*
+通用带权图
+
+原理：
+导航图（NavigationGraph）和社交网络（SocialNetwork）各自用不同的结构保存节点和边，
+同一个图算法（生成、序列化、中心性）要写两遍。通用带权图只保留图的骨架：
+字符串标识的节点、带权重的边，以及节点和边上的字符串属性，
+具体业务图通过转换函数与它互相转换，图算法只需要面向通用图实现一次。
+
+关键特点：
+1. 支持有向图和无向图，无向边在两个方向上共享权重和属性
+2. 节点和边可以附带任意字符串属性（名称、坐标、道路类型等）
+3. 节点和邻居按标识排序返回，输出和遍历顺序稳定
+
+实现方式：
+- 邻接表：节点 → 邻居 → 权重
+- 无向边的属性以排序后的节点对为键，保证两个方向看到同一份属性
+
+应用场景：
+- 图的文件读写和可视化导出
+- 随机图生成和图算法的性能测试
+- 在不同业务图之间复用图算法
+
+优缺点：
+- 优点：结构简单，与业务图解耦
+- 缺点：属性都是字符串，使用时需要自行解析
+
+以下实现了通用带权图。
+*/
+
+import (
+	"sort"
+)
+
+// Graph 通用带权图
+type Graph struct {
+	Directed  bool                            // 是否为有向图
+	adjacency map[string]map[string]float64   // 节点 -> 邻居 -> 权重
+	nodeAttrs map[string]map[string]string    // 节点属性
+	edgeAttrs map[[2]string]map[string]string // 边属性，无向边以排序后的节点对为键
+}
+
+// NewGraph 创建通用带权图
+func NewGraph(directed bool) *Graph {
+	return &Graph{
+		Directed:  directed,
+		adjacency: make(map[string]map[string]float64),
+		nodeAttrs: make(map[string]map[string]string),
+		edgeAttrs: make(map[[2]string]map[string]string),
+	}
+}
+
+// AddNode 添加节点，节点已存在时不做任何事
+func (g *Graph) AddNode(id string) {
+	if _, exists := g.adjacency[id]; !exists {
+		g.adjacency[id] = make(map[string]float64)
+	}
+}
+
+// HasNode 判断节点是否存在
+func (g *Graph) HasNode(id string) bool {
+	_, exists := g.adjacency[id]
+	return exists
+}
+
+// AddEdge 添加边，节点不存在时自动创建；边已存在时更新权重
+func (g *Graph) AddEdge(from, to string, weight float64) {
+	g.AddNode(from)
+	g.AddNode(to)
+	g.adjacency[from][to] = weight
+	if !g.Directed {
+		g.adjacency[to][from] = weight
+	}
+}
+
+// RemoveEdge 删除边及其属性
+func (g *Graph) RemoveEdge(from, to string) {
+	delete(g.adjacency[from], to)
+	if !g.Directed {
+		delete(g.adjacency[to], from)
+	}
+	delete(g.edgeAttrs, g.edgeKey(from, to))
+}
+
+// HasEdge 判断边是否存在
+func (g *Graph) HasEdge(from, to string) bool {
+	_, exists := g.adjacency[from][to]
+	return exists
+}
+
+// Weight 返回边的权重
+func (g *Graph) Weight(from, to string) (float64, bool) {
+	weight, exists := g.adjacency[from][to]
+	return weight, exists
+}
+
+// Nodes 返回排序后的所有节点
+func (g *Graph) Nodes() []string {
+	nodes := make([]string, 0, len(g.adjacency))
+	for id := range g.adjacency {
+		nodes = append(nodes, id)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// Neighbors 返回排序后的邻居（有向图中为出边指向的节点）
+func (g *Graph) Neighbors(id string) []string {
+	neighbors := make([]string, 0, len(g.adjacency[id]))
+	for neighbor := range g.adjacency[id] {
+		neighbors = append(neighbors, neighbor)
+	}
+	sort.Strings(neighbors)
+	return neighbors
+}
+
+// Degree 返回节点的度（有向图中为出度）
+func (g *Graph) Degree(id string) int {
+	return len(g.adjacency[id])
+}
+
+// NodeCount 返回节点数
+func (g *Graph) NodeCount() int {
+	return len(g.adjacency)
+}
+
+// EdgeCount 返回边数，无向边只计一次
+func (g *Graph) EdgeCount() int {
+	count := 0
+	for _, neighbors := range g.adjacency {
+		count += len(neighbors)
+	}
+	if !g.Directed {
+		// 自环在无向图中只存一次
+		loops := 0
+		for id, neighbors := range g.adjacency {
+			if _, ok := neighbors[id]; ok {
+				loops++
+			}
+		}
+		count = (count-loops)/2 + loops
+	}
+	return count
+}
+
+// SetNodeAttr 设置节点属性，节点不存在时自动创建
+func (g *Graph) SetNodeAttr(id, key, value string) {
+	g.AddNode(id)
+	if g.nodeAttrs[id] == nil {
+		g.nodeAttrs[id] = make(map[string]string)
+	}
+	g.nodeAttrs[id][key] = value
+}
+
+// NodeAttr 返回节点属性
+func (g *Graph) NodeAttr(id, key string) (string, bool) {
+	value, exists := g.nodeAttrs[id][key]
+	return value, exists
+}
+
+// NodeAttrs 返回节点的全部属性（只读）
+func (g *Graph) NodeAttrs(id string) map[string]string {
+	return g.nodeAttrs[id]
+}
+
+// edgeKey 返回边属性的键，无向边按节点标识排序
+func (g *Graph) edgeKey(from, to string) [2]string {
+	if !g.Directed && to < from {
+		from, to = to, from
+	}
+	return [2]string{from, to}
+}
+
+// SetEdgeAttr 设置边属性，边不存在时不做任何事
+func (g *Graph) SetEdgeAttr(from, to, key, value string) {
+	if !g.HasEdge(from, to) {
+		return
+	}
+	k := g.edgeKey(from, to)
+	if g.edgeAttrs[k] == nil {
+		g.edgeAttrs[k] = make(map[string]string)
+	}
+	g.edgeAttrs[k][key] = value
+}
+
+// EdgeAttr 返回边属性
+func (g *Graph) EdgeAttr(from, to, key string) (string, bool) {
+	value, exists := g.edgeAttrs[g.edgeKey(from, to)][key]
+	return value, exists
+}
+
+// EdgeAttrs 返回边的全部属性（只读）
+func (g *Graph) EdgeAttrs(from, to string) map[string]string {
+	return g.edgeAttrs[g.edgeKey(from, to)]
+}
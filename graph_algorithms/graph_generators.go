@@ -0,0 +1,324 @@
+package graph_algorithms
+
+/*
+随机图生成器
+
+原理：
+手工构建的示例图只有十几个节点，既看不出算法在大规模数据上的性能，也不具备真实网络的结构特征。
+随机图模型按照一定的规则生成任意规模的图，不同模型刻画了真实网络的不同性质：
+- Erdős–Rényi G(n,p)：每对节点以概率 p 独立连边，度分布近似泊松分布，是最基本的对照模型
+- Barabási–Albert：新节点按"度越大越容易被连接"（优先连接）加入，产生少数度很高的枢纽节点，
+  度分布服从幂律，类似社交网络和互联网
+- Watts–Strogatz：从每个节点连接最近 k 个邻居的环形格子出发，以概率 β 随机重连边，
+  在保持高聚类系数的同时大幅缩短平均路径长度，即"小世界"现象
+
+关键特点：
+1. 三种模型都可以指定规模和平均度，并使用显式的随机数生成器，相同种子生成相同的图
+2. G(n,p) 使用几何分布跳跃采样，期望复杂度 O(n + m)，适合生成稀疏大图
+3. BA 模型通过"按度重复的节点列表"实现 O(1) 的优先连接采样
+4. 提供度分布、聚类系数和平均路径长度等统计量，用于检验生成结果
+
+实现方式：
+- 节点标识为从 1 开始的整数字符串，可以直接转换为社交网络
+- 平均路径长度从若干个采样源点出发做广度优先搜索估计
+
+应用场景：
+- 为社交推荐、最短路径等算法生成大规模测试数据
+- 比较算法在不同网络结构上的表现
+- 教学中演示小世界和无标度网络
+
+优缺点：
+- 优点：规模可控、结果可复现，结构特征有理论保证
+- 缺点：只是真实网络的简化模型，不包含社区结构、节点属性等信息
+
+以下实现了三种随机图模型和常用的图统计量。
+*/
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/strive/scenario/report"
+)
+
+// generatorNodeID 返回第 i 个（从0开始）生成节点的标识
+func generatorNodeID(i int) string {
+	return strconv.Itoa(i + 1)
+}
+
+// newGeneratedGraph 创建包含 n 个孤立节点的无向图
+func newGeneratedGraph(n int) *Graph {
+	g := NewGraph(false)
+	for i := 0; i < n; i++ {
+		g.AddNode(generatorNodeID(i))
+	}
+	return g
+}
+
+// ErdosRenyi 生成 G(n,p) 随机图：每对节点以概率 p 独立连边
+func ErdosRenyi(n int, p float64, rng *rand.Rand) *Graph {
+	g := newGeneratedGraph(n)
+	if p <= 0 || n < 2 {
+		return g
+	}
+	if p >= 1 {
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				g.AddEdge(generatorNodeID(i), generatorNodeID(j), 1)
+			}
+		}
+		return g
+	}
+
+	// 几何分布跳跃：直接计算到下一条边之间跳过的节点对数量（Batagelj & Brandes 2005）
+	logQ := math.Log(1 - p)
+	v, w := 1, -1
+	for v < n {
+		w += 1 + int(math.Log(1-rng.Float64())/logQ)
+		for w >= v && v < n {
+			w -= v
+			v++
+		}
+		if v < n {
+			g.AddEdge(generatorNodeID(v), generatorNodeID(w), 1)
+		}
+	}
+	return g
+}
+
+// BarabasiAlbert 生成 BA 无标度图：每个新节点按优先连接规则连接 m 个已有节点
+func BarabasiAlbert(n, m int, rng *rand.Rand) *Graph {
+	g := newGeneratedGraph(n)
+	if m < 1 || n <= m {
+		return g
+	}
+
+	// 初始为 m+1 个节点的完全图
+	repeated := make([]int, 0, 2*n*m) // 每个节点按度数重复出现，均匀抽样即按度抽样
+	for i := 0; i <= m; i++ {
+		for j := i + 1; j <= m; j++ {
+			g.AddEdge(generatorNodeID(i), generatorNodeID(j), 1)
+			repeated = append(repeated, i, j)
+		}
+	}
+
+	targets := make(map[int]bool, m)
+	for v := m + 1; v < n; v++ {
+		for k := range targets {
+			delete(targets, k)
+		}
+		for len(targets) < m {
+			targets[repeated[rng.Intn(len(repeated))]] = true
+		}
+		// 按标识顺序连边，保证相同种子生成相同的图
+		sorted := make([]int, 0, m)
+		for t := range targets {
+			sorted = append(sorted, t)
+		}
+		sort.Ints(sorted)
+		for _, t := range sorted {
+			g.AddEdge(generatorNodeID(v), generatorNodeID(t), 1)
+			repeated = append(repeated, v, t)
+		}
+	}
+	return g
+}
+
+// WattsStrogatz 生成小世界图：环形格子中每个节点连接最近的 k 个邻居（k 为偶数），每条边以概率 beta 重连
+func WattsStrogatz(n, k int, beta float64, rng *rand.Rand) *Graph {
+	g := newGeneratedGraph(n)
+	k -= k % 2
+	if k < 2 || n <= k {
+		return g
+	}
+
+	for i := 0; i < n; i++ {
+		for j := 1; j <= k/2; j++ {
+			g.AddEdge(generatorNodeID(i), generatorNodeID((i+j)%n), 1)
+		}
+	}
+
+	// 依次考虑每条格子边，以概率 beta 把远端换成随机节点（避免自环和重边）
+	for j := 1; j <= k/2; j++ {
+		for i := 0; i < n; i++ {
+			if rng.Float64() >= beta {
+				continue
+			}
+			u, old := generatorNodeID(i), generatorNodeID((i+j)%n)
+			if !g.HasEdge(u, old) || g.Degree(u) >= n-1 {
+				continue
+			}
+			target := generatorNodeID(rng.Intn(n))
+			for target == u || g.HasEdge(u, target) {
+				target = generatorNodeID(rng.Intn(n))
+			}
+			g.RemoveEdge(u, old)
+			g.AddEdge(u, target, 1)
+		}
+	}
+	return g
+}
+
+// bfsDistances 返回从 source 出发到各可达节点的跳数
+func bfsDistances(g *Graph, source string) map[string]int {
+	distances := map[string]int{source: 0}
+	queue := []string{source}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for neighbor := range g.adjacency[node] {
+			if _, visited := distances[neighbor]; !visited {
+				distances[neighbor] = distances[node] + 1
+				queue = append(queue, neighbor)
+			}
+		}
+	}
+	return distances
+}
+
+// GraphStats 图的结构统计量
+type GraphStats struct {
+	Nodes             int         // 节点数
+	Edges             int         // 边数
+	AvgDegree         float64     // 平均度
+	MaxDegree         int         // 最大度
+	Clustering        float64     // 平均局部聚类系数
+	AvgPathLength     float64     // 可达节点对之间的平均跳数（采样估计）
+	LargestComponent  int         // 最大连通分量的节点数
+	DegreeHistogram   map[int]int // 度 -> 节点数
+	PathSampleSources int         // 估计平均路径长度时的采样源点数
+}
+
+// ComputeGraphStats 计算图的结构统计量，samples 为估计平均路径长度时的采样源点数
+func ComputeGraphStats(g *Graph, samples int, rng *rand.Rand) GraphStats {
+	nodes := g.Nodes()
+	stats := GraphStats{
+		Nodes:           len(nodes),
+		Edges:           g.EdgeCount(),
+		DegreeHistogram: make(map[int]int),
+	}
+	if len(nodes) == 0 {
+		return stats
+	}
+
+	totalDegree, clustering := 0, 0.0
+	for _, id := range nodes {
+		degree := g.Degree(id)
+		totalDegree += degree
+		stats.DegreeHistogram[degree]++
+		if degree > stats.MaxDegree {
+			stats.MaxDegree = degree
+		}
+
+		// 局部聚类系数：邻居之间实际存在的边数 / 可能的边数
+		if degree >= 2 {
+			neighbors := g.Neighbors(id)
+			links := 0
+			for i := 0; i < len(neighbors); i++ {
+				for j := i + 1; j < len(neighbors); j++ {
+					if g.HasEdge(neighbors[i], neighbors[j]) {
+						links++
+					}
+				}
+			}
+			clustering += float64(2*links) / float64(degree*(degree-1))
+		}
+	}
+	stats.AvgDegree = float64(totalDegree) / float64(len(nodes))
+	stats.Clustering = clustering / float64(len(nodes))
+
+	// 最大连通分量
+	visited := make(map[string]bool, len(nodes))
+	for _, id := range nodes {
+		if visited[id] {
+			continue
+		}
+		component := bfsDistances(g, id)
+		for node := range component {
+			visited[node] = true
+		}
+		if len(component) > stats.LargestComponent {
+			stats.LargestComponent = len(component)
+		}
+	}
+
+	// 从采样源点出发估计平均路径长度
+	if samples <= 0 || samples > len(nodes) {
+		samples = len(nodes)
+	}
+	totalHops, pairs := 0, 0
+	for _, i := range rng.Perm(len(nodes))[:samples] {
+		for node, hops := range bfsDistances(g, nodes[i]) {
+			if node != nodes[i] {
+				totalHops += hops
+				pairs++
+			}
+		}
+	}
+	if pairs > 0 {
+		stats.AvgPathLength = float64(totalHops) / float64(pairs)
+	}
+	stats.PathSampleSources = samples
+	return stats
+}
+
+// 场景示例：三种随机图模型的结构对比，以及在大规模社交网络上测试好友推荐
+func GraphGeneratorsDemo() {
+	fmt.Println("随机图生成器示例:")
+
+	const n, avgDegree = 2000, 8
+	generators := []struct {
+		name     string
+		generate func(rng *rand.Rand) *Graph
+	}{
+		{"Erdős–Rényi", func(rng *rand.Rand) *Graph { return ErdosRenyi(n, float64(avgDegree)/float64(n-1), rng) }},
+		{"Barabási–Albert", func(rng *rand.Rand) *Graph { return BarabasiAlbert(n, avgDegree/2, rng) }},
+		{"Watts–Strogatz(β=0)", func(rng *rand.Rand) *Graph { return WattsStrogatz(n, avgDegree, 0, rng) }},
+		{"Watts–Strogatz(β=0.1)", func(rng *rand.Rand) *Graph { return WattsStrogatz(n, avgDegree, 0.1, rng) }},
+	}
+
+	comparison := report.NewComparison(
+		"随机图模型对比",
+		fmt.Sprintf("%d 个节点，期望平均度 %d，平均路径长度由50个源点采样估计", n, avgDegree),
+		report.Metric{Name: "边数", Precision: 0},
+		report.Metric{Name: "最大度", Precision: 0},
+		report.Metric{Name: "聚类系数", Precision: 3},
+		report.Metric{Name: "平均路径长度", LowerIsBetter: true, Precision: 2},
+	)
+	for _, generator := range generators {
+		var stats GraphStats
+		result := comparison.Measure(generator.name, func() (map[string]float64, error) {
+			g := generator.generate(rand.New(rand.NewSource(42)))
+			stats = ComputeGraphStats(g, 50, rand.New(rand.NewSource(1)))
+			return map[string]float64{
+				"边数":     float64(stats.Edges),
+				"最大度":    float64(stats.MaxDegree),
+				"聚类系数":   stats.Clustering,
+				"平均路径长度": stats.AvgPathLength,
+			}, nil
+		})
+		if stats.LargestComponent < stats.Nodes {
+			result.Note = fmt.Sprintf("最大连通分量 %d 个节点", stats.LargestComponent)
+		}
+	}
+	comparison.Write(os.Stdout, report.FormatMarkdown)
+	fmt.Println("耗时包含生成和统计；BA 模型出现度很高的枢纽节点，WS 模型少量重连即可大幅缩短路径且保持较高聚类系数")
+
+	// 用 BA 模型生成大规模社交网络测试好友推荐的性能
+	fmt.Println("\n在BA社交网络上测试好友推荐:")
+	for _, size := range []int{1000, 10000} {
+		rng := rand.New(rand.NewSource(7))
+		sn, _ := NewSocialNetworkFromGraph(BarabasiAlbert(size, 4, rng))
+		sn.InfluenceWeight = 0 // 只测试推荐本身，不计入中介中心性的全量计算
+		start := time.Now()
+		for userID := 1; userID <= 100; userID++ {
+			sn.RecommendFriends(userID, 5)
+		}
+		fmt.Printf("  %5d 个用户: 100 次好友推荐耗时 %v\n", size, time.Since(start).Round(time.Microsecond))
+	}
+}
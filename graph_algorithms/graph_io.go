@@ -0,0 +1,519 @@
+package graph_algorithms
+
+/*
+图的文件读写与 Graphviz 导出
+
+原理：
+示例中的图都是在代码里手工构建的，想用自己的数据集测试算法、或者直观地看看图长什么样都不方便。
+邻接表文本格式每行描述一个节点及其邻居，人可以直接阅读和编辑，也容易由其他工具生成；
+Graphviz 的 DOT 语言是图可视化的事实标准，导出后可以用 dot/neato 渲染成图片。
+
+关键特点：
+1. 邻接表格式：`节点: 邻居1 邻居2=权重;属性=值`，权重缺省为1
+2. 节点属性行：`@节点 属性=值 ...`，用于保存名称、坐标、兴趣等业务信息
+3. 首条数据行可以是 directed 或 undirected，缺省为无向图；# 开头的行为注释
+4. DOT 导出支持高亮一条路径，节点带有坐标属性时按坐标固定位置
+
+实现方式：
+- 写入时节点按标识排序，无向边只写一次，输出稳定、便于比较差异
+- 读取时逐行解析，出错时报告行号
+- 导航图和社交网络通过与通用图的互相转换获得读写能力
+
+应用场景：
+- 保存和加载路网、社交关系等数据集
+- 把导航路线、推荐关系渲染成图片辅助调试
+- 从其他系统导入图数据测试算法
+
+优缺点：
+- 优点：纯文本、可读可编辑，格式简单
+- 缺点：标识和属性值不能包含空白和分隔符；社交网络只保存用户和好友关系，不保存内容和交互
+
+以下实现了邻接表读写、DOT导出，以及导航图和社交网络与通用图的互相转换。
+*/
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 邻接表格式中的分隔符
+const (
+	adjacencyNodeSep  = ":" // 节点与邻居列表之间
+	adjacencyValueSep = "=" // 邻居与权重、属性名与属性值之间
+	adjacencyAttrSep  = ";" // 邻居权重与边属性之间
+	adjacencyAttrLine = "@" // 节点属性行的前缀
+)
+
+// validToken 检查标识或属性值是否可以写入邻接表
+func validToken(token string, allowValueSep bool) error {
+	if token == "" {
+		return fmt.Errorf("标识不能为空")
+	}
+	if strings.ContainsAny(token, " \t\r\n"+adjacencyAttrSep) ||
+		(!allowValueSep && strings.ContainsAny(token, adjacencyValueSep+adjacencyNodeSep)) {
+		return fmt.Errorf("%q 包含空白或分隔符", token)
+	}
+	return nil
+}
+
+// formatAttrs 按属性名排序后格式化为 属性=值 列表
+func formatAttrs(attrs map[string]string, sep string) (string, error) {
+	keys := make([]string, 0, len(attrs))
+	for key := range attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if err := validToken(key, false); err != nil {
+			return "", fmt.Errorf("属性名%w", err)
+		}
+		if err := validToken(attrs[key], true); err != nil {
+			return "", fmt.Errorf("属性 %s 的值%w", key, err)
+		}
+		parts = append(parts, key+adjacencyValueSep+attrs[key])
+	}
+	return strings.Join(parts, sep), nil
+}
+
+// WriteAdjacencyList 以邻接表文本格式写出图
+func (g *Graph) WriteAdjacencyList(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	kind := "undirected"
+	if g.Directed {
+		kind = "directed"
+	}
+	fmt.Fprintf(bw, "# %d 个节点，%d 条边\n%s\n", g.NodeCount(), g.EdgeCount(), kind)
+
+	nodes := g.Nodes()
+	for _, id := range nodes {
+		if err := validToken(id, false); err != nil {
+			return fmt.Errorf("节点%w", err)
+		}
+		if attrs := g.NodeAttrs(id); len(attrs) > 0 {
+			line, err := formatAttrs(attrs, " ")
+			if err != nil {
+				return fmt.Errorf("节点 %s 的%w", id, err)
+			}
+			fmt.Fprintf(bw, "%s%s %s\n", adjacencyAttrLine, id, line)
+		}
+	}
+
+	for _, id := range nodes {
+		bw.WriteString(id + adjacencyNodeSep)
+		for _, neighbor := range g.Neighbors(id) {
+			// 无向边只在标识较小的一端写出
+			if !g.Directed && neighbor < id {
+				continue
+			}
+			bw.WriteString(" " + neighbor)
+			if weight, _ := g.Weight(id, neighbor); weight != 1 {
+				bw.WriteString(adjacencyValueSep + strconv.FormatFloat(weight, 'g', -1, 64))
+			}
+			if attrs := g.EdgeAttrs(id, neighbor); len(attrs) > 0 {
+				line, err := formatAttrs(attrs, adjacencyAttrSep)
+				if err != nil {
+					return fmt.Errorf("边 %s-%s 的%w", id, neighbor, err)
+				}
+				bw.WriteString(adjacencyAttrSep + line)
+			}
+		}
+		bw.WriteString("\n")
+	}
+	return bw.Flush()
+}
+
+// ReadAdjacencyList 从邻接表文本格式读取图
+func ReadAdjacencyList(r io.Reader) (*Graph, error) {
+	var g *Graph
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// 第一条数据行可以声明图的类型
+		if g == nil {
+			switch line {
+			case "directed", "undirected":
+				g = NewGraph(line == "directed")
+				continue
+			default:
+				g = NewGraph(false)
+			}
+		}
+
+		if strings.HasPrefix(line, adjacencyAttrLine) {
+			fields := strings.Fields(strings.TrimPrefix(line, adjacencyAttrLine))
+			if len(fields) == 0 {
+				return nil, fmt.Errorf("第 %d 行: 节点属性行缺少节点标识", lineNo)
+			}
+			g.AddNode(fields[0])
+			for _, field := range fields[1:] {
+				key, value, ok := strings.Cut(field, adjacencyValueSep)
+				if !ok {
+					return nil, fmt.Errorf("第 %d 行: 属性 %q 缺少 %s", lineNo, field, adjacencyValueSep)
+				}
+				g.SetNodeAttr(fields[0], key, value)
+			}
+			continue
+		}
+
+		id, rest, ok := strings.Cut(line, adjacencyNodeSep)
+		id = strings.TrimSpace(id)
+		if !ok || id == "" {
+			return nil, fmt.Errorf("第 %d 行: 格式应为 \"节点: 邻居...\"", lineNo)
+		}
+		g.AddNode(id)
+
+		for _, field := range strings.Fields(rest) {
+			parts := strings.Split(field, adjacencyAttrSep)
+			neighbor, weightText, hasWeight := strings.Cut(parts[0], adjacencyValueSep)
+			weight := 1.0
+			if hasWeight {
+				parsed, err := strconv.ParseFloat(weightText, 64)
+				if err != nil {
+					return nil, fmt.Errorf("第 %d 行: 边 %s-%s 的权重无效: %w", lineNo, id, neighbor, err)
+				}
+				weight = parsed
+			}
+			g.AddEdge(id, neighbor, weight)
+
+			for _, attr := range parts[1:] {
+				key, value, ok := strings.Cut(attr, adjacencyValueSep)
+				if !ok {
+					return nil, fmt.Errorf("第 %d 行: 边属性 %q 缺少 %s", lineNo, attr, adjacencyValueSep)
+				}
+				g.SetEdgeAttr(id, neighbor, key, value)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if g == nil {
+		g = NewGraph(false)
+	}
+	return g, nil
+}
+
+// SaveGraph 将图保存到文件
+func SaveGraph(path string, g *Graph) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := g.WriteAdjacencyList(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// LoadGraph 从文件加载图
+func LoadGraph(path string) (*Graph, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	g, err := ReadAdjacencyList(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return g, nil
+}
+
+// DOTOptions Graphviz导出选项
+type DOTOptions struct {
+	Name      string   // 图名称
+	LabelAttr string   // 作为节点标签的属性，缺省为 name，属性不存在时使用节点标识
+	Highlight []string // 需要高亮的路径（按顺序排列的节点）
+	ShowEdges bool     // 是否在边上显示权重和道路类型
+}
+
+// WriteDOT 导出为 Graphviz DOT 格式，节点带有 x、y 属性时按坐标固定位置（适合 neato 渲染）
+func (g *Graph) WriteDOT(w io.Writer, options DOTOptions) error {
+	if options.Name == "" {
+		options.Name = "G"
+	}
+	if options.LabelAttr == "" {
+		options.LabelAttr = "name"
+	}
+
+	highlightNodes := make(map[string]bool, len(options.Highlight))
+	highlightEdges := make(map[[2]string]bool, len(options.Highlight))
+	for i, id := range options.Highlight {
+		highlightNodes[id] = true
+		if i > 0 {
+			highlightEdges[g.edgeKey(options.Highlight[i-1], id)] = true
+		}
+	}
+
+	keyword, arrow := "graph", "--"
+	if g.Directed {
+		keyword, arrow = "digraph", "->"
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%s %q {\n", keyword, options.Name)
+	bw.WriteString("  node [shape=ellipse, fontname=\"sans-serif\"];\n")
+
+	for _, id := range g.Nodes() {
+		attrs := []string{}
+		if label, ok := g.NodeAttr(id, options.LabelAttr); ok {
+			attrs = append(attrs, fmt.Sprintf("label=%q", label))
+		}
+		x, hasX := g.NodeAttr(id, "x")
+		y, hasY := g.NodeAttr(id, "y")
+		if hasX && hasY {
+			attrs = append(attrs, fmt.Sprintf("pos=\"%s,%s!\"", x, y))
+		}
+		if highlightNodes[id] {
+			attrs = append(attrs, "color=red", "penwidth=2")
+		}
+		fmt.Fprintf(bw, "  %q", id)
+		if len(attrs) > 0 {
+			fmt.Fprintf(bw, " [%s]", strings.Join(attrs, ", "))
+		}
+		bw.WriteString(";\n")
+	}
+
+	for _, from := range g.Nodes() {
+		for _, to := range g.Neighbors(from) {
+			if !g.Directed && to < from {
+				continue
+			}
+			attrs := []string{}
+			if options.ShowEdges {
+				weight, _ := g.Weight(from, to)
+				label := strconv.FormatFloat(weight, 'g', -1, 64)
+				if road, ok := g.EdgeAttr(from, to, "road"); ok {
+					label += " " + road
+				}
+				attrs = append(attrs, fmt.Sprintf("label=%q", label))
+			}
+			if highlightEdges[g.edgeKey(from, to)] {
+				attrs = append(attrs, "color=red", "penwidth=2")
+			}
+			fmt.Fprintf(bw, "  %q %s %q", from, arrow, to)
+			if len(attrs) > 0 {
+				fmt.Fprintf(bw, " [%s]", strings.Join(attrs, ", "))
+			}
+			bw.WriteString(";\n")
+		}
+	}
+
+	bw.WriteString("}\n")
+	return bw.Flush()
+}
+
+// ToGraph 将导航图转换为有向通用图，节点保存名称和坐标，边保存道路类型和是否收费
+func (g *NavigationGraph) ToGraph() *Graph {
+	graph := NewGraph(true)
+	for id, node := range g.Nodes {
+		graph.SetNodeAttr(id, "name", node.Name)
+		graph.SetNodeAttr(id, "x", strconv.FormatFloat(node.Coordinate.X, 'g', -1, 64))
+		graph.SetNodeAttr(id, "y", strconv.FormatFloat(node.Coordinate.Y, 'g', -1, 64))
+		for _, edge := range node.Connections {
+			graph.AddEdge(id, edge.To.ID, edge.Weight)
+			if edge.RoadType != "" {
+				graph.SetEdgeAttr(id, edge.To.ID, "road", edge.RoadType)
+			}
+			if edge.Toll {
+				graph.SetEdgeAttr(id, edge.To.ID, "toll", "true")
+			}
+		}
+	}
+	return graph
+}
+
+// NewNavigationGraphFromGraph 从通用图构建导航图，无向边转换为两条方向相反的道路
+func NewNavigationGraphFromGraph(graph *Graph) (*NavigationGraph, error) {
+	g := NewNavigationGraph()
+	for _, id := range graph.Nodes() {
+		name, ok := graph.NodeAttr(id, "name")
+		if !ok {
+			name = id
+		}
+		var coordinate [2]float64
+		for i, key := range []string{"x", "y"} {
+			if value, ok := graph.NodeAttr(id, key); ok {
+				parsed, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					return nil, fmt.Errorf("节点 %s 的坐标 %s 无效: %w", id, key, err)
+				}
+				coordinate[i] = parsed
+			}
+		}
+		g.AddNode(id, name, coordinate[0], coordinate[1])
+	}
+
+	for _, from := range graph.Nodes() {
+		for _, to := range graph.Neighbors(from) {
+			weight, _ := graph.Weight(from, to)
+			road, _ := graph.EdgeAttr(from, to, "road")
+			toll, _ := graph.EdgeAttr(from, to, "toll")
+			g.AddEdge(from, to, weight, road, toll == "true")
+		}
+	}
+	return g, nil
+}
+
+// FriendGraph 将社交网络的好友关系转换为无向通用图，节点保存用户名和兴趣
+func (sn *SocialNetwork) FriendGraph() *Graph {
+	graph := NewGraph(false)
+	for id, user := range sn.Users {
+		node := strconv.Itoa(id)
+		graph.SetNodeAttr(node, "name", user.Name)
+		if len(user.Interests) > 0 {
+			interests := make([]string, 0, len(user.Interests))
+			for interest, weight := range user.Interests {
+				interests = append(interests, interest+":"+strconv.FormatFloat(weight, 'g', 3, 64))
+			}
+			sort.Strings(interests)
+			graph.SetNodeAttr(node, "interests", strings.Join(interests, ","))
+		}
+		for friendID := range user.Friends {
+			graph.AddEdge(node, strconv.Itoa(friendID), 1)
+		}
+	}
+	return graph
+}
+
+// NewSocialNetworkFromGraph 从通用图构建社交网络，节点标识必须是整数
+func NewSocialNetworkFromGraph(graph *Graph) (*SocialNetwork, error) {
+	sn := NewSocialNetwork()
+	for _, node := range graph.Nodes() {
+		id, err := strconv.Atoi(node)
+		if err != nil {
+			return nil, fmt.Errorf("用户标识 %q 不是整数", node)
+		}
+		name, ok := graph.NodeAttr(node, "name")
+		if !ok {
+			name = fmt.Sprintf("用户%d", id)
+		}
+
+		interests := make(map[string]float64)
+		if value, ok := graph.NodeAttr(node, "interests"); ok && value != "" {
+			for _, item := range strings.Split(value, ",") {
+				interest, weightText, _ := strings.Cut(item, ":")
+				weight, err := strconv.ParseFloat(weightText, 64)
+				if err != nil {
+					return nil, fmt.Errorf("用户 %d 的兴趣 %q 无效", id, item)
+				}
+				interests[interest] = weight
+			}
+		}
+		sn.AddUser(&User{ID: id, Name: name, Interests: interests, Friends: make(map[int]bool)})
+	}
+
+	for _, node := range graph.Nodes() {
+		id, _ := strconv.Atoi(node)
+		for _, neighbor := range graph.Neighbors(node) {
+			friendID, _ := strconv.Atoi(neighbor)
+			sn.AddFriendship(id, friendID)
+		}
+	}
+	return sn, nil
+}
+
+// 场景示例：保存和加载路网，导出导航路线的可视化，以及导入外部的社交关系数据集
+func GraphIODemo() {
+	fmt.Println("图的文件读写与Graphviz导出示例:")
+
+	// 1. 路网保存到文件后重新加载，路径规划结果一致
+	cityMap := createCityMap()
+	dir, err := os.MkdirTemp("", "graph-io")
+	if err != nil {
+		fmt.Printf("创建临时目录失败: %v\n", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "city.adj")
+	if err := SaveGraph(path, cityMap.ToGraph()); err != nil {
+		fmt.Printf("保存失败: %v\n", err)
+		return
+	}
+	content, _ := os.ReadFile(path)
+	fmt.Printf("\n路网已保存为邻接表（%d 字节），前几行:\n", len(content))
+	for i, line := range strings.SplitN(string(content), "\n", 6)[:5] {
+		fmt.Printf("  %d| %s\n", i+1, line)
+	}
+
+	loaded, err := LoadGraph(path)
+	if err != nil {
+		fmt.Printf("加载失败: %v\n", err)
+		return
+	}
+	reloaded, err := NewNavigationGraphFromGraph(loaded)
+	if err != nil {
+		fmt.Printf("转换失败: %v\n", err)
+		return
+	}
+	before, _ := cityMap.FindShortestPath("BJ", "HD", RouteOptions{})
+	after, _ := reloaded.FindShortestPath("BJ", "HD", RouteOptions{})
+	fmt.Printf("\n加载后: %d 个节点，%d 条边；北京→邯郸 保存前 %.0f 公里，加载后 %.0f 公里\n",
+		loaded.NodeCount(), loaded.EdgeCount(), before.Distance, after.Distance)
+
+	// 2. 导出 DOT 并高亮路线
+	route, _ := cityMap.FindShortestPath("QHD", "XT", RouteOptions{})
+	highlight := make([]string, len(route.Path))
+	for i, node := range route.Path {
+		highlight[i] = node.ID
+	}
+	var dot strings.Builder
+	loaded.WriteDOT(&dot, DOTOptions{Name: "河北路网", Highlight: highlight, ShowEdges: true})
+	fmt.Printf("\n导出DOT（高亮 秦皇岛→邢台 路线 %v），可用 neato -Tpng 渲染:\n", highlight)
+	lines := strings.Split(strings.TrimSpace(dot.String()), "\n")
+	for _, line := range lines[:6] {
+		fmt.Printf("  %s\n", line)
+	}
+	fmt.Printf("  ...（共 %d 行）\n", len(lines))
+
+	// 3. 导入外部数据集
+	dataset := `# 读书会成员
+undirected
+@1 name=小王 interests=阅读:0.9,电影:0.5
+@2 name=小李 interests=阅读:0.8,旅游:0.7
+@3 name=小张 interests=电影:0.9,旅游:0.6
+@4 name=小赵 interests=阅读:0.7,电影:0.8
+1: 2 3
+2: 4
+3: 4
+5
+`
+	if _, err := ReadAdjacencyList(strings.NewReader(dataset)); err != nil {
+		fmt.Printf("\n格式错误时报告行号: %v\n", err)
+	}
+	dataset = strings.Replace(dataset, "\n5\n", "\n5:\n", 1)
+	graph, err := ReadAdjacencyList(strings.NewReader(dataset))
+	if err != nil {
+		fmt.Printf("解析失败: %v\n", err)
+		return
+	}
+	sn, err := NewSocialNetworkFromGraph(graph)
+	if err != nil {
+		fmt.Printf("转换失败: %v\n", err)
+		return
+	}
+	fmt.Printf("导入社交网络: %d 个用户，%d 条好友关系\n", len(sn.Users), graph.EdgeCount())
+	recs, _ := sn.RecommendFriends(1, 3)
+	for _, rec := range recs {
+		fmt.Printf("  为小王推荐: %s（得分 %.2f）\n", sn.Users[rec.ID].Name, rec.Score)
+	}
+}
@@ -0,0 +1,517 @@
+package graph_algorithms
+
+/*
+HNSW 近似最近邻索引
+
+原理：
+在百万级的向量（用户兴趣、内容特征等嵌入向量）中找与查询最相似的 k 个，暴力扫描需要计算全部距离。
+HNSW（Hierarchical Navigable Small World）把向量组织成多层的近邻图：
+- 第0层包含所有向量，每个向量连接若干个最近的邻居，构成"小世界"图，贪心地沿边走向更近的邻居即可快速逼近目标
+- 每个向量以指数递减的概率出现在更高的层，高层节点少、边跨度大，相当于跳表的快速通道
+查询时从最高层的入口点出发，在每一层贪心下降到最近点，作为下一层的起点，最后在第0层做宽度为 ef 的束搜索。
+它与跳表思路相同：随机分层 + 自顶向下逐层缩小范围，只是"有序链表"换成了"近邻图"。
+
+关键特点：
+1. 查询复杂度约为 O(log n)，召回率通常在 95% 以上
+2. 支持增量插入，不需要像 IVF 那样预先训练聚类中心
+3. 通过 M（每个节点的邻居数）、efConstruction、efSearch 在速度、内存和召回率之间权衡
+4. 选择邻居时使用启发式规则，优先保留方向分散的邻居，避免在聚簇数据上陷入局部
+
+实现方式：
+- 节点保存向量和每一层的邻居列表，层数按 -ln(U)·mL 随机生成
+- 候选集用最小堆、结果集用最大堆实现束搜索
+- 读写锁保护索引，支持并发查询
+
+应用场景：
+- 相似用户、相似内容推荐
+- 以图搜图、语义检索
+- 推荐系统的召回阶段
+
+优缺点：
+- 优点：查询快、召回率高、支持增量插入
+- 缺点：内存占用大（每个节点保存多层邻居），不支持高效删除；结果是近似的
+
+以下实现了HNSW索引，并与暴力搜索比较召回率和查询速度。
+*/
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/strive/scenario/report"
+)
+
+// 错误定义
+var (
+	ErrDimensionMismatch = errors.New("向量维度不一致")
+	ErrDuplicateVector   = errors.New("向量ID已存在")
+)
+
+// EuclideanDistance 欧氏距离
+func EuclideanDistance(a, b []float64) float64 {
+	sum := 0.0
+	for i := range a {
+		d := a[i] - b[i]
+		sum += d * d
+	}
+	return math.Sqrt(sum)
+}
+
+// CosineDistance 余弦距离（1 − 余弦相似度），零向量与任何向量的距离为1
+func CosineDistance(a, b []float64) float64 {
+	dot, normA, normB := 0.0, 0.0, 0.0
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 1
+	}
+	return 1 - dot/(math.Sqrt(normA)*math.Sqrt(normB))
+}
+
+// HNSWOptions HNSW索引选项
+type HNSWOptions struct {
+	M              int                          // 每个节点在高层的最大邻居数，第0层为 2M
+	EfConstruction int                          // 插入时束搜索的宽度
+	EfSearch       int                          // 查询时束搜索的宽度，小于 k 时按 k 处理
+	Distance       func(a, b []float64) float64 // 距离函数
+	Seed           int64                        // 分层随机数种子
+}
+
+// DefaultHNSWOptions 默认的HNSW索引选项
+var DefaultHNSWOptions = HNSWOptions{
+	M:              16,
+	EfConstruction: 200,
+	EfSearch:       50,
+	Distance:       EuclideanDistance,
+	Seed:           1,
+}
+
+// hnswNode 索引中的一个向量
+type hnswNode struct {
+	id        int
+	vector    []float64
+	neighbors [][]int // 每一层的邻居（节点下标）
+}
+
+// hnswCandidate 搜索过程中的候选节点
+type hnswCandidate struct {
+	node     int
+	distance float64
+}
+
+// hnswQueue 候选节点堆，farthest 为 true 时堆顶是距离最大的节点
+type hnswQueue struct {
+	items    []hnswCandidate
+	farthest bool
+}
+
+func (q *hnswQueue) Len() int { return len(q.items) }
+
+func (q *hnswQueue) Less(i, j int) bool {
+	if q.farthest {
+		return q.items[i].distance > q.items[j].distance
+	}
+	return q.items[i].distance < q.items[j].distance
+}
+
+func (q *hnswQueue) Swap(i, j int) { q.items[i], q.items[j] = q.items[j], q.items[i] }
+
+func (q *hnswQueue) Push(x interface{}) { q.items = append(q.items, x.(hnswCandidate)) }
+
+func (q *hnswQueue) Pop() interface{} {
+	item := q.items[len(q.items)-1]
+	q.items = q.items[:len(q.items)-1]
+	return item
+}
+
+// HNSWResult 查询结果
+type HNSWResult struct {
+	ID       int     // 向量ID
+	Distance float64 // 与查询向量的距离
+}
+
+// HNSWIndex HNSW近似最近邻索引
+type HNSWIndex struct {
+	mu        sync.RWMutex
+	options   HNSWOptions
+	levelMult float64 // 分层系数 mL = 1/ln(M)
+	rng       *rand.Rand
+	nodes     []*hnswNode
+	ids       map[int]int // 向量ID -> 节点下标
+	entry     int         // 入口节点，位于最高层
+	maxLevel  int
+	dimension int
+}
+
+// NewHNSWIndex 创建HNSW索引
+func NewHNSWIndex(options HNSWOptions) *HNSWIndex {
+	if options.M < 2 {
+		options.M = DefaultHNSWOptions.M
+	}
+	if options.EfConstruction < options.M {
+		options.EfConstruction = options.M
+	}
+	if options.EfSearch <= 0 {
+		options.EfSearch = DefaultHNSWOptions.EfSearch
+	}
+	if options.Distance == nil {
+		options.Distance = DefaultHNSWOptions.Distance
+	}
+	return &HNSWIndex{
+		options:   options,
+		levelMult: 1 / math.Log(float64(options.M)),
+		rng:       rand.New(rand.NewSource(options.Seed)),
+		ids:       make(map[int]int),
+		entry:     -1,
+	}
+}
+
+// Len 返回索引中的向量数量
+func (h *HNSWIndex) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.nodes)
+}
+
+// SetEfSearch 调整查询时束搜索的宽度，越大召回率越高、查询越慢
+func (h *HNSWIndex) SetEfSearch(ef int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if ef > 0 {
+		h.options.EfSearch = ef
+	}
+}
+
+// maxNeighbors 返回某一层允许的最大邻居数
+func (h *HNSWIndex) maxNeighbors(level int) int {
+	if level == 0 {
+		return 2 * h.options.M
+	}
+	return h.options.M
+}
+
+// distance 计算查询向量与节点的距离
+func (h *HNSWIndex) distance(query []float64, node int) float64 {
+	return h.options.Distance(query, h.nodes[node].vector)
+}
+
+// Insert 插入向量，ID 不能重复，所有向量的维度必须相同
+func (h *HNSWIndex) Insert(id int, vector []float64) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, exists := h.ids[id]; exists {
+		return fmt.Errorf("%w: %d", ErrDuplicateVector, id)
+	}
+	if h.dimension == 0 {
+		h.dimension = len(vector)
+	} else if len(vector) != h.dimension {
+		return fmt.Errorf("%w: 期望 %d 维，实际 %d 维", ErrDimensionMismatch, h.dimension, len(vector))
+	}
+
+	level := int(-math.Log(1-h.rng.Float64()) * h.levelMult)
+	node := &hnswNode{
+		id:        id,
+		vector:    append([]float64(nil), vector...),
+		neighbors: make([][]int, level+1),
+	}
+	index := len(h.nodes)
+	h.nodes = append(h.nodes, node)
+	h.ids[id] = index
+
+	if h.entry < 0 {
+		h.entry, h.maxLevel = index, level
+		return nil
+	}
+
+	// 在新节点所在层以上贪心下降
+	entry := []hnswCandidate{{h.entry, h.distance(vector, h.entry)}}
+	for l := h.maxLevel; l > level; l-- {
+		entry = h.searchLayer(vector, entry, 1, l)
+	}
+
+	// 在新节点所在的每一层找邻居并双向连接
+	for l := min(level, h.maxLevel); l >= 0; l-- {
+		candidates := h.searchLayer(vector, entry, h.options.EfConstruction, l)
+		node.neighbors[l] = h.selectNeighbors(candidates, h.options.M)
+		for _, neighbor := range node.neighbors[l] {
+			h.connect(neighbor, index, l)
+		}
+		entry = candidates
+	}
+
+	if level > h.maxLevel {
+		h.entry, h.maxLevel = index, level
+	}
+	return nil
+}
+
+// connect 把 to 加入 from 在第 level 层的邻居，超出上限时重新选择邻居
+func (h *HNSWIndex) connect(from, to, level int) {
+	node := h.nodes[from]
+	node.neighbors[level] = append(node.neighbors[level], to)
+	if len(node.neighbors[level]) <= h.maxNeighbors(level) {
+		return
+	}
+
+	candidates := make([]hnswCandidate, len(node.neighbors[level]))
+	for i, neighbor := range node.neighbors[level] {
+		candidates[i] = hnswCandidate{neighbor, h.distance(node.vector, neighbor)}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].distance < candidates[j].distance })
+	node.neighbors[level] = h.selectNeighbors(candidates, h.maxNeighbors(level))
+}
+
+// selectNeighbors 启发式选择邻居：候选按距离从近到远，只有当它离查询点比离所有已选邻居都近时才保留，
+// 使邻居分布在不同方向上；不足 m 个时用被跳过的候选补齐。candidates 需按距离升序排列
+func (h *HNSWIndex) selectNeighbors(candidates []hnswCandidate, m int) []int {
+	selected := make([]int, 0, m)
+	skipped := make([]int, 0)
+	for _, c := range candidates {
+		if len(selected) >= m {
+			break
+		}
+		diverse := true
+		for _, s := range selected {
+			if h.options.Distance(h.nodes[c.node].vector, h.nodes[s].vector) < c.distance {
+				diverse = false
+				break
+			}
+		}
+		if diverse {
+			selected = append(selected, c.node)
+		} else {
+			skipped = append(skipped, c.node)
+		}
+	}
+	for _, node := range skipped {
+		if len(selected) >= m {
+			break
+		}
+		selected = append(selected, node)
+	}
+	return selected
+}
+
+// searchLayer 在第 level 层从入口点出发做宽度为 ef 的束搜索，返回按距离升序排列的最近节点
+func (h *HNSWIndex) searchLayer(query []float64, entry []hnswCandidate, ef, level int) []hnswCandidate {
+	visited := make(map[int]bool, ef*4)
+	candidates := &hnswQueue{}
+	results := &hnswQueue{farthest: true}
+	for _, e := range entry {
+		visited[e.node] = true
+		heap.Push(candidates, e)
+		heap.Push(results, e)
+	}
+	for results.Len() > ef {
+		heap.Pop(results)
+	}
+
+	for candidates.Len() > 0 {
+		current := heap.Pop(candidates).(hnswCandidate)
+		if current.distance > results.items[0].distance && results.Len() >= ef {
+			break // 最近的候选也比结果集中最远的节点远，无法再改进
+		}
+		for _, neighbor := range h.nodes[current.node].neighbors[level] {
+			if visited[neighbor] {
+				continue
+			}
+			visited[neighbor] = true
+			d := h.distance(query, neighbor)
+			if results.Len() < ef || d < results.items[0].distance {
+				heap.Push(candidates, hnswCandidate{neighbor, d})
+				heap.Push(results, hnswCandidate{neighbor, d})
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+
+	sorted := results.items
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].distance < sorted[j].distance })
+	return sorted
+}
+
+// Search 查询与 query 最近的 k 个向量，结果按距离升序排列
+func (h *HNSWIndex) Search(query []float64, k int) ([]HNSWResult, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if h.entry < 0 || k <= 0 {
+		return nil, nil
+	}
+	if len(query) != h.dimension {
+		return nil, fmt.Errorf("%w: 期望 %d 维，实际 %d 维", ErrDimensionMismatch, h.dimension, len(query))
+	}
+
+	entry := []hnswCandidate{{h.entry, h.distance(query, h.entry)}}
+	for l := h.maxLevel; l > 0; l-- {
+		entry = h.searchLayer(query, entry, 1, l)
+	}
+	ef := h.options.EfSearch
+	if ef < k {
+		ef = k
+	}
+	candidates := h.searchLayer(query, entry, ef, 0)
+
+	results := make([]HNSWResult, 0, min(k, len(candidates)))
+	for _, c := range candidates[:min(k, len(candidates))] {
+		results = append(results, HNSWResult{ID: h.nodes[c.node].id, Distance: c.distance})
+	}
+	return results, nil
+}
+
+// Stats 返回索引统计信息
+func (h *HNSWIndex) Stats() map[string]interface{} {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	levels := make([]int, h.maxLevel+1)
+	edges := 0
+	for _, node := range h.nodes {
+		for l, neighbors := range node.neighbors {
+			levels[l]++
+			edges += len(neighbors)
+		}
+	}
+	return map[string]interface{}{
+		"vectors":   len(h.nodes),
+		"dimension": h.dimension,
+		"maxLevel":  h.maxLevel,
+		"levelSize": levels,
+		"edges":     edges,
+	}
+}
+
+// bruteForceSearch 暴力搜索最近的 k 个向量，作为召回率的基准
+func bruteForceSearch(vectors [][]float64, query []float64, k int, distance func(a, b []float64) float64) []HNSWResult {
+	results := make([]HNSWResult, len(vectors))
+	for i, v := range vectors {
+		results[i] = HNSWResult{ID: i, Distance: distance(query, v)}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Distance < results[j].Distance })
+	return results[:min(k, len(results))]
+}
+
+// userInterestVector 把用户兴趣转换为固定词表上的向量
+func userInterestVector(user *User, vocabulary []string) []float64 {
+	vector := make([]float64, len(vocabulary))
+	for i, tag := range vocabulary {
+		vector[i] = user.Interests[tag]
+	}
+	return vector
+}
+
+// 场景示例：在聚簇分布的嵌入向量上比较HNSW与暴力搜索，并用兴趣向量查找相似用户
+func HNSWDemo() {
+	fmt.Println("HNSW近似最近邻索引示例:")
+
+	// 1. 生成聚簇分布的嵌入向量（模拟不同兴趣群体）
+	const count, dim, clusters, queries, k = 10000, 32, 50, 200, 10
+	rng := rand.New(rand.NewSource(42))
+	centers := make([][]float64, clusters)
+	for i := range centers {
+		centers[i] = make([]float64, dim)
+		for j := range centers[i] {
+			centers[i][j] = rng.NormFloat64() * 3
+		}
+	}
+	sample := func() []float64 {
+		center := centers[rng.Intn(clusters)]
+		v := make([]float64, dim)
+		for j := range v {
+			v[j] = center[j] + rng.NormFloat64()
+		}
+		return v
+	}
+	vectors := make([][]float64, count)
+	for i := range vectors {
+		vectors[i] = sample()
+	}
+	queryVectors := make([][]float64, queries)
+	for i := range queryVectors {
+		queryVectors[i] = sample()
+	}
+	exact := make([][]HNSWResult, queries)
+	for i, q := range queryVectors {
+		exact[i] = bruteForceSearch(vectors, q, k, EuclideanDistance)
+	}
+
+	start := time.Now()
+	options := DefaultHNSWOptions
+	options.EfConstruction = 64 // 降低构建开销，演示中召回率仍然足够
+	index := NewHNSWIndex(options)
+	for i, v := range vectors {
+		index.Insert(i, v)
+	}
+	stats := index.Stats()
+	fmt.Printf("\n构建索引: %d 个 %d 维向量，耗时 %v，层数 %d，各层节点数 %v\n",
+		count, dim, time.Since(start).Round(time.Millisecond), stats["maxLevel"].(int)+1, stats["levelSize"])
+
+	comparison := report.NewComparison(
+		"最近邻搜索对比",
+		fmt.Sprintf("%d 个 %d 维聚簇向量，%d 次 top-%d 查询", count, dim, queries, k),
+		report.Metric{Name: "召回率(%)", Precision: 1},
+	)
+	comparison.Measure("暴力搜索", func() (map[string]float64, error) {
+		for _, q := range queryVectors {
+			bruteForceSearch(vectors, q, k, EuclideanDistance)
+		}
+		return map[string]float64{"召回率(%)": 100}, nil
+	})
+	for _, ef := range []int{10, 50, 200} {
+		index.SetEfSearch(ef)
+		comparison.Measure(fmt.Sprintf("HNSW(ef=%d)", ef), func() (map[string]float64, error) {
+			hits := 0
+			for i, q := range queryVectors {
+				results, err := index.Search(q, k)
+				if err != nil {
+					return nil, err
+				}
+				truth := make(map[int]bool, k)
+				for _, r := range exact[i] {
+					truth[r.ID] = true
+				}
+				for _, r := range results {
+					if truth[r.ID] {
+						hits++
+					}
+				}
+			}
+			return map[string]float64{"召回率(%)": float64(hits) / float64(queries*k) * 100}, nil
+		})
+	}
+	comparison.Write(os.Stdout, report.FormatMarkdown)
+
+	// 2. 用兴趣向量查找相似用户
+	sn := createDemoSocialNetwork()
+	vocabulary := []string{"科技", "体育", "音乐", "电影", "旅游", "美食", "健身", "游戏", "汽车", "时尚"}
+	userIndex := NewHNSWIndex(HNSWOptions{M: 4, EfConstruction: 20, EfSearch: 10, Distance: CosineDistance})
+	for id := 1; id <= len(sn.Users); id++ {
+		userIndex.Insert(id, userInterestVector(sn.Users[id], vocabulary))
+	}
+	target := sn.Users[1]
+	similar, _ := userIndex.Search(userInterestVector(target, vocabulary), 4)
+	fmt.Printf("与 %s 兴趣最相似的用户:\n", target.Name)
+	for _, r := range similar {
+		if r.ID == target.ID {
+			continue
+		}
+		fmt.Printf("  %s（余弦相似度 %.2f）\n", sn.Users[r.ID].Name, 1-r.Distance)
+	}
+
+	if err := userIndex.Insert(100, []float64{1, 2}); err != nil {
+		fmt.Printf("插入维度不一致的向量: %v\n", err)
+	}
+}
@@ -36,8 +36,19 @@ package graph_algorithms
 
 import (
 	"container/heap"
+	"context"
 	"fmt"
 	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/strive/scenario/concurrency"
+	"github.com/strive/scenario/report"
+	"github.com/strive/scenario/tracing"
 )
 
 // 位置坐标（用于A*算法的启发式函数）
@@ -72,6 +83,10 @@ type Edge struct {
 // 导航图
 type NavigationGraph struct {
 	Nodes map[string]*Node // 图中所有节点
+
+	landmarks    []string             // ALT 地标
+	fromLandmark []map[string]float64 // 各地标到所有节点的距离
+	toLandmark   []map[string]float64 // 所有节点到各地标的距离
 }
 
 // 创建新的导航图
@@ -90,6 +105,7 @@ func (g *NavigationGraph) AddNode(id, name string, x, y float64) *Node {
 		Connections: make([]*Edge, 0),
 	}
 	g.Nodes[id] = node
+	g.landmarks = nil // 图结构变化后地标距离表失效
 	return node
 }
 
@@ -111,9 +127,52 @@ func (g *NavigationGraph) AddEdge(fromID, toID string, weight float64, roadType
 		Toll:     toll,
 	}
 	fromNode.Connections = append(fromNode.Connections, edge)
+	g.landmarks = nil
 	return true
 }
 
+// SetEdgeWeight 修改边的权重（如实时路况导致的通行时间变化），边不存在时返回 false
+func (g *NavigationGraph) SetEdgeWeight(fromID, toID string, weight float64) bool {
+	fromNode, exists := g.Nodes[fromID]
+	if !exists {
+		return false
+	}
+	for _, edge := range fromNode.Connections {
+		if edge.To.ID == toID {
+			edge.Weight = weight
+			g.landmarks = nil // 边权变化后地标距离表不再是有效下界
+			return true
+		}
+	}
+	return false
+}
+
+// Clone 深拷贝路网的节点和边，副本上的修改不影响原路网；地标距离表不复制，需要时在副本上重新调用 PrepareLandmarks
+func (g *NavigationGraph) Clone() *NavigationGraph {
+	clone := NewNavigationGraph()
+	for id, node := range g.Nodes {
+		clone.Nodes[id] = &Node{
+			ID:          node.ID,
+			Name:        node.Name,
+			Coordinate:  node.Coordinate,
+			Connections: make([]*Edge, 0, len(node.Connections)),
+		}
+	}
+	for id, node := range g.Nodes {
+		from := clone.Nodes[id]
+		for _, edge := range node.Connections {
+			from.Connections = append(from.Connections, &Edge{
+				From:     from,
+				To:       clone.Nodes[edge.To.ID],
+				Weight:   edge.Weight,
+				RoadType: edge.RoadType,
+				Toll:     edge.Toll,
+			})
+		}
+	}
+	return clone
+}
+
 // 用于Dijkstra算法的优先级队列项
 type DijkstraItem struct {
 	NodeID   string  // 节点ID
@@ -159,6 +218,7 @@ type RouteOptions struct {
 	PreferredRoads    []string // 偏好的道路类型
 	MaxDistance       float64  // 最大距离限制
 	UseAStarAlgorithm bool     // 是否使用A*算法
+	UseLandmarks      bool     // A*是否使用ALT地标启发式（需要先调用 PrepareLandmarks）
 }
 
 // 路径结果
@@ -171,6 +231,11 @@ type Route struct {
 
 // 使用Dijkstra算法计算最短路径
 func (g *NavigationGraph) FindShortestPath(fromID, toID string, options RouteOptions) (*Route, error) {
+	span := tracing.StartSpan("FindShortestPath")
+	span.SetAttribute("from", fromID)
+	span.SetAttribute("to", toID)
+	defer span.Finish()
+
 	// 验证起点和终点存在
 	startNode, exists := g.Nodes[fromID]
 	if !exists {
@@ -183,12 +248,24 @@ func (g *NavigationGraph) FindShortestPath(fromID, toID string, options RouteOpt
 	}
 
 	// 如果选择使用A*算法
+	var route *Route
+	var err error
 	if options.UseAStarAlgorithm {
-		return g.findShortestPathAStar(startNode, endNode, options)
+		searchSpan := span.StartChild("A*搜索")
+		route, err = g.findShortestPathAStar(startNode, endNode, options)
+		searchSpan.Finish()
+	} else {
+		// 默认使用Dijkstra算法
+		searchSpan := span.StartChild("Dijkstra搜索")
+		route, err = g.findShortestPathDijkstra(startNode, endNode, options)
+		searchSpan.Finish()
 	}
 
-	// 默认使用Dijkstra算法
-	return g.findShortestPathDijkstra(startNode, endNode, options)
+	if route != nil {
+		span.SetAttribute("hops", len(route.Path)-1)
+		span.SetAttribute("distance", route.Distance)
+	}
+	return route, err
 }
 
 // Dijkstra算法实现
@@ -293,10 +370,20 @@ func (g *NavigationGraph) findShortestPathAStar(startNode, endNode *Node, option
 	fScore := make(map[string]float64)
 	previous := make(map[string]string)
 
+	// 启发式函数：默认使用直线距离，预计算了地标时可以使用更紧的ALT下界
+	heuristic := func(node *Node) float64 {
+		return node.Coordinate.Distance(endNode.Coordinate)
+	}
+	if options.UseLandmarks && len(g.landmarks) > 0 {
+		heuristic = func(node *Node) float64 {
+			return g.landmarkHeuristic(node.ID, endNode.ID)
+		}
+	}
+
 	// 初始化起点数据
 	openSet[startNode.ID] = true
 	gScore[startNode.ID] = 0
-	fScore[startNode.ID] = startNode.Coordinate.Distance(endNode.Coordinate)
+	fScore[startNode.ID] = heuristic(startNode)
 
 	// 初始化优先级队列（基于f-score）
 	pq := make(PathPriorityQueue, 0)
@@ -381,7 +468,7 @@ func (g *NavigationGraph) findShortestPathAStar(startNode, endNode *Node, option
 			// 这是目前为止最好的路径，记录它
 			previous[neighbor.ID] = current.NodeID
 			gScore[neighbor.ID] = tentativeGScore
-			fScore[neighbor.ID] = gScore[neighbor.ID] + neighbor.Coordinate.Distance(endNode.Coordinate)
+			fScore[neighbor.ID] = gScore[neighbor.ID] + heuristic(neighbor)
 
 			// 更新优先级队列
 			heap.Push(&pq, &DijkstraItem{
@@ -510,6 +597,8 @@ func createCityMap() *NavigationGraph {
 // 最短路径导航示例
 func ShortestPathNavigationDemo() {
 	fmt.Println("== 最短路径导航系统示例 ==")
+	tracing.DefaultExporter().Reset()
+	defer tracing.SetEnabled(tracing.SetEnabled(true)) // 只在演示期间开启追踪
 
 	// 创建城市地图
 	cityMap := createCityMap()
@@ -558,4 +647,258 @@ func ShortestPathNavigationDemo() {
 	} else {
 		route4.PrintRoute()
 	}
+
+	// 各次路径规划的耗时（链路追踪）
+	fmt.Println("\n=== 路径规划耗时（链路追踪） ===")
+	tracing.DefaultExporter().Report(os.Stdout)
+}
+
+// createGridMap 创建 rows×cols 的网格路网，边权为坐标距离乘以随机拥堵系数（保证A*启发式可采纳）
+func createGridMap(rows, cols int, rng *rand.Rand) *NavigationGraph {
+	graph := NewNavigationGraph()
+
+	id := func(r, c int) string { return fmt.Sprintf("N%d_%d", r, c) }
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			graph.AddNode(id(r, c), id(r, c), float64(c), float64(r))
+		}
+	}
+
+	connect := func(from, to string) {
+		distance := graph.Nodes[from].Coordinate.Distance(graph.Nodes[to].Coordinate)
+		weight := distance * (1 + rng.Float64())
+		graph.AddEdge(from, to, weight, "城市道路", false)
+		graph.AddEdge(to, from, weight, "城市道路", false)
+	}
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			if c+1 < cols {
+				connect(id(r, c), id(r, c+1))
+			}
+			if r+1 < rows {
+				connect(id(r, c), id(r+1, c))
+			}
+		}
+	}
+
+	return graph
+}
+
+// CompareRoutingAlgorithms 在同一张网格路网和同一批查询上对比Dijkstra与A*
+func CompareRoutingAlgorithms(rows, cols, queries int, seed int64) *report.Comparison {
+	rng := rand.New(rand.NewSource(seed))
+	graph := createGridMap(rows, cols, rng)
+
+	ids := make([]string, 0, len(graph.Nodes))
+	for nodeID := range graph.Nodes {
+		ids = append(ids, nodeID)
+	}
+	sort.Strings(ids)
+
+	pairs := make([][2]*Node, queries)
+	for i := range pairs {
+		pairs[i] = [2]*Node{graph.Nodes[ids[rng.Intn(len(ids))]], graph.Nodes[ids[rng.Intn(len(ids))]]}
+	}
+
+	// ALT 地标的预计算不计入查询耗时
+	graph.PrepareLandmarks(8)
+
+	// 以Dijkstra的结果作为最优距离基准
+	optimal := make([]float64, queries)
+	for i, pair := range pairs {
+		if route, err := graph.findShortestPathDijkstra(pair[0], pair[1], RouteOptions{}); err == nil {
+			optimal[i] = route.Distance
+		}
+	}
+
+	comparison := report.NewComparison(
+		"最短路径算法对比",
+		fmt.Sprintf("%d×%d 网格路网，%d 次随机起终点查询", rows, cols, queries),
+		report.Metric{Name: "最优路径比例(%)", Precision: 1},
+		report.Metric{Name: "平均距离", LowerIsBetter: true, Precision: 2},
+		report.Metric{Name: "平均跳数", LowerIsBetter: true, Precision: 1},
+	)
+
+	algorithms := []struct {
+		name string
+		find func(start, end *Node) (*Route, error)
+	}{
+		{"Dijkstra", func(start, end *Node) (*Route, error) {
+			return graph.findShortestPathDijkstra(start, end, RouteOptions{})
+		}},
+		{"A*", func(start, end *Node) (*Route, error) {
+			return graph.findShortestPathAStar(start, end, RouteOptions{})
+		}},
+		{"A*+ALT", func(start, end *Node) (*Route, error) {
+			return graph.findShortestPathAStar(start, end, RouteOptions{UseLandmarks: true})
+		}},
+	}
+
+	for _, algorithm := range algorithms {
+		comparison.Measure(algorithm.name, func() (map[string]float64, error) {
+			optimalCount, totalDistance, totalHops := 0, 0.0, 0
+			for i, pair := range pairs {
+				route, err := algorithm.find(pair[0], pair[1])
+				if err != nil {
+					return nil, err
+				}
+				if math.Abs(route.Distance-optimal[i]) < 1e-9 {
+					optimalCount++
+				}
+				totalDistance += route.Distance
+				totalHops += len(route.Path) - 1
+			}
+			return map[string]float64{
+				"最优路径比例(%)": float64(optimalCount) / float64(queries) * 100,
+				"平均距离":      totalDistance / float64(queries),
+				"平均跳数":      float64(totalHops) / float64(queries),
+			}, nil
+		})
+	}
+
+	return comparison
+}
+
+// 场景示例：城市路网上的路径算法对比
+func RoutingComparisonDemo() {
+	fmt.Println("最短路径算法对比报告:")
+
+	CompareRoutingAlgorithms(20, 20, 200, 7).Write(os.Stdout, report.FormatMarkdown)
+	CompareRoutingAlgorithms(50, 50, 100, 7).Write(os.Stdout, report.FormatMarkdown)
+}
+
+// routeCost 在路线所属的路网版本上累加路线经过的边权
+func routeCost(route *Route) float64 {
+	cost := 0.0
+	for i := 0; i+1 < len(route.Path); i++ {
+		for _, edge := range route.Path[i].Connections {
+			if edge.To == route.Path[i+1] {
+				cost += edge.Weight
+				break
+			}
+		}
+	}
+	return cost
+}
+
+// routeNames 路线经过的节点名称
+func routeNames(route *Route) string {
+	names := make([]string, len(route.Path))
+	for i, node := range route.Path {
+		names[i] = node.Name
+	}
+	return strings.Join(names, "→")
+}
+
+// 场景示例：导航请求持续计算路线的同时，实时路况不断发布新的路网版本。
+// 每次路况更新复制当前路网、修改边权、重新预计算地标后发布；正在计算的路线继续使用旧版本，
+// 旧版本的最后一个导航请求结束后（宽限期结束）才释放它的地标距离表
+func LiveTrafficNavigationDemo() {
+	fmt.Println("== 实时路况下的导航路网热更新（RCU）==")
+
+	initial := createCityMap()
+	initial.PrepareLandmarks(3)
+	var reclaimMutex sync.Mutex
+	reclaimed := make([]uint64, 0)
+	network := concurrency.NewRCU(initial, func(old *NavigationGraph, version uint64) {
+		// 旧版本已无导航请求使用，可以安全地释放地标距离表
+		old.landmarks, old.fromLandmark, old.toLandmark = nil, nil, nil
+		reclaimMutex.Lock()
+		reclaimed = append(reclaimed, version)
+		reclaimMutex.Unlock()
+	})
+
+	// 路况事件：边的双向权重乘以拥堵系数，系数为1表示恢复畅通
+	events := []struct {
+		from, to string
+		factor   float64
+		desc     string
+	}{
+		{"BJ", "SJZ", 3, "京石高速事故，通行时间变为3倍"},
+		{"SJZ", "XT", 2, "石邢段施工，通行时间翻倍"},
+		{"BJ", "SJZ", 1, "京石高速事故清除"},
+		{"BJ", "TJ", 4, "京津高速大雾限行"},
+	}
+	base := make(map[[2]string]float64)
+	for _, node := range initial.Nodes {
+		for _, edge := range node.Connections {
+			base[[2]string{node.ID, edge.To.ID}] = edge.Weight
+		}
+	}
+
+	queries := [][2]string{{"BJ", "HD"}, {"QHD", "XT"}, {"ZJK", "TS"}, {"TJ", "SJZ"}}
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	var routeMutex sync.Mutex
+	routesPerVersion := make(map[uint64]int)
+	sampleRoutes := make(map[uint64]string) // 每个版本上北京→邯郸的路线
+	inconsistent := 0
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for n := worker; ; n++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				query := queries[n%len(queries)]
+				// 路线在同一个路网快照上计算和校验，路线中的节点属于该快照，离开读临界区后不再访问
+				network.View(func(graph *NavigationGraph, version uint64) {
+					route, err := graph.FindShortestPath(query[0], query[1], RouteOptions{UseAStarAlgorithm: true, UseLandmarks: true})
+					if err != nil {
+						return
+					}
+					// 计算过程中耗时较长的请求会跨越路况更新
+					time.Sleep(time.Millisecond)
+					consistent := math.Abs(routeCost(route)-route.Distance) < 1e-9
+
+					routeMutex.Lock()
+					defer routeMutex.Unlock()
+					routesPerVersion[version]++
+					if !consistent {
+						inconsistent++
+					}
+					if query == queries[0] {
+						if _, ok := sampleRoutes[version]; !ok {
+							sampleRoutes[version] = fmt.Sprintf("%s（%.0f）", routeNames(route), route.Distance)
+						}
+					}
+				})
+			}
+		}(i)
+	}
+
+	for _, event := range events {
+		time.Sleep(15 * time.Millisecond)
+		version := network.Update(func(old *NavigationGraph) *NavigationGraph {
+			graph := old.Clone()
+			graph.SetEdgeWeight(event.from, event.to, base[[2]string{event.from, event.to}]*event.factor)
+			graph.SetEdgeWeight(event.to, event.from, base[[2]string{event.to, event.from}]*event.factor)
+			graph.PrepareLandmarks(3)
+			return graph
+		})
+		fmt.Printf("发布路网版本 %d: %s\n", version, event.desc)
+	}
+	time.Sleep(15 * time.Millisecond)
+	close(stop)
+	wg.Wait()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := network.Synchronize(ctx); err != nil {
+		fmt.Printf("等待旧路网宽限期结束超时: %v\n", err)
+	}
+
+	fmt.Println("\n各版本上的北京→邯郸路线:")
+	for version := uint64(1); version <= network.Version(); version++ {
+		fmt.Printf("  版本 %d（%d 条路线）: %s\n", version, routesPerVersion[version], sampleRoutes[version])
+	}
+	reclaimMutex.Lock()
+	fmt.Printf("已释放的旧路网版本: %v\n", reclaimed)
+	reclaimMutex.Unlock()
+	fmt.Printf("路线距离与其所属快照的边权不一致的次数: %d\n", inconsistent)
+	fmt.Printf("统计: %v\n", network.Stats())
 }
@@ -37,8 +37,13 @@ import (
 	"fmt"
 	"math"
 	"math/rand"
+	"os"
 	"sort"
+	"strconv"
 	"time"
+
+	"github.com/strive/scenario/practical_applications"
+	"github.com/strive/scenario/tracing"
 )
 
 // User 表示社交网络中的用户
@@ -62,17 +67,32 @@ type Post struct {
 
 // SocialNetwork 表示社交网络图
 type SocialNetwork struct {
-	Users          map[int]*User           // 用户节点
-	Posts          map[int]*Post           // 内容节点
-	UserPostMatrix map[int]map[int]float64 // 用户-内容交互矩阵
+	Users           map[int]*User           // 用户节点
+	Posts           map[int]*Post           // 内容节点
+	UserPostMatrix  map[int]map[int]float64 // 用户-内容交互矩阵
+	InfluenceWeight float64                 // 好友推荐中影响力（中介中心性）的加权系数，0表示不考虑
+	Taxonomy        *InterestTaxonomy       // 兴趣分类体系，为空时按扁平标签匹配
+	DedupThreshold  float64                 // 内容推荐结果去重的相似度阈值，0表示不去重
+
+	centrality map[CentralityKind]map[int]float64            // 缓存的中心性，好友关系变化时清空
+	influences map[int]float64                               // 缓存的归一化影响力
+	duplicates *practical_applications.NearDuplicateDetector // 内容的 MinHash 索引，首次使用时构建
 }
 
+// DefaultInfluenceWeight 好友推荐中影响力的默认加权系数
+const DefaultInfluenceWeight = 0.1
+
+// DefaultDedupThreshold 内容推荐结果去重的默认相似度阈值
+const DefaultDedupThreshold = 0.8
+
 // NewSocialNetwork 创建一个新的社交网络
 func NewSocialNetwork() *SocialNetwork {
 	return &SocialNetwork{
-		Users:          make(map[int]*User),
-		Posts:          make(map[int]*Post),
-		UserPostMatrix: make(map[int]map[int]float64),
+		Users:           make(map[int]*User),
+		Posts:           make(map[int]*Post),
+		UserPostMatrix:  make(map[int]map[int]float64),
+		InfluenceWeight: DefaultInfluenceWeight,
+		DedupThreshold:  DefaultDedupThreshold,
 	}
 }
 
@@ -80,11 +100,15 @@ func NewSocialNetwork() *SocialNetwork {
 func (sn *SocialNetwork) AddUser(user *User) {
 	sn.Users[user.ID] = user
 	sn.UserPostMatrix[user.ID] = make(map[int]float64)
+	sn.invalidateCentrality()
 }
 
 // AddPost 添加内容到社交网络
 func (sn *SocialNetwork) AddPost(post *Post) {
 	sn.Posts[post.ID] = post
+	if sn.duplicates != nil {
+		sn.duplicates.Add(strconv.Itoa(post.ID), postText(post))
+	}
 }
 
 // AddFriendship 在两个用户之间建立好友关系
@@ -99,6 +123,7 @@ func (sn *SocialNetwork) AddFriendship(userID1, userID2 int) bool {
 	// 添加双向好友关系
 	user1.Friends[userID2] = true
 	user2.Friends[userID1] = true
+	sn.invalidateCentrality()
 
 	return true
 }
@@ -126,6 +151,129 @@ func (sn *SocialNetwork) AddInteraction(userID, postID int, weight float64) bool
 	return true
 }
 
+// RemoveFriendship 解除两个用户之间的好友关系，好友关系不存在时返回 false
+func (sn *SocialNetwork) RemoveFriendship(userID1, userID2 int) bool {
+	user1, ok1 := sn.Users[userID1]
+	user2, ok2 := sn.Users[userID2]
+
+	if !ok1 || !ok2 || !user1.Friends[userID2] {
+		return false
+	}
+
+	// 双向删除，保证好友关系始终对称
+	delete(user1.Friends, userID2)
+	delete(user2.Friends, userID1)
+	sn.invalidateCentrality()
+
+	return true
+}
+
+// RemovePost 删除内容，同时清除所有用户对它的交互记录
+func (sn *SocialNetwork) RemovePost(postID int) bool {
+	if _, ok := sn.Posts[postID]; !ok {
+		return false
+	}
+
+	// 权重不大于0的交互不在点赞集合中，需要遍历整个交互矩阵
+	for _, row := range sn.UserPostMatrix {
+		delete(row, postID)
+	}
+	delete(sn.Posts, postID)
+	if sn.duplicates != nil {
+		sn.duplicates.Remove(strconv.Itoa(postID))
+	}
+
+	return true
+}
+
+// RemoveUser 删除用户，级联清理其好友关系、交互记录、点赞以及发布的内容
+func (sn *SocialNetwork) RemoveUser(userID int) bool {
+	user, ok := sn.Users[userID]
+	if !ok {
+		return false
+	}
+
+	// 从所有好友的好友列表中移除
+	for friendID := range user.Friends {
+		if friend, exists := sn.Users[friendID]; exists {
+			delete(friend.Friends, userID)
+		}
+	}
+
+	// 撤销该用户的点赞
+	for postID := range sn.UserPostMatrix[userID] {
+		if post, exists := sn.Posts[postID]; exists {
+			delete(post.Likes, userID)
+		}
+	}
+	delete(sn.UserPostMatrix, userID)
+
+	// 作者不存在的内容无法展示和推荐，与作者一起删除
+	for postID, post := range sn.Posts {
+		if post.AuthorID == userID {
+			sn.RemovePost(postID)
+		}
+	}
+
+	delete(sn.Users, userID)
+	sn.invalidateCentrality()
+
+	return true
+}
+
+// CheckIntegrity 检查社交网络的一致性，返回发现的第一个问题：
+// 好友关系双向且指向存在的用户、交互矩阵与用户一一对应、内容的作者和点赞用户都存在且与交互矩阵一致
+func (sn *SocialNetwork) CheckIntegrity() error {
+	for id, user := range sn.Users {
+		if user.ID != id {
+			return fmt.Errorf("用户 %d 的ID字段为 %d", id, user.ID)
+		}
+		for friendID := range user.Friends {
+			friend, ok := sn.Users[friendID]
+			if !ok {
+				return fmt.Errorf("用户 %d 的好友 %d 不存在", id, friendID)
+			}
+			if !friend.Friends[id] {
+				return fmt.Errorf("用户 %d 与 %d 的好友关系不对称", id, friendID)
+			}
+		}
+		if _, ok := sn.UserPostMatrix[id]; !ok {
+			return fmt.Errorf("用户 %d 缺少交互矩阵行", id)
+		}
+	}
+
+	for userID, row := range sn.UserPostMatrix {
+		if _, ok := sn.Users[userID]; !ok {
+			return fmt.Errorf("交互矩阵包含不存在的用户 %d", userID)
+		}
+		for postID, weight := range row {
+			post, ok := sn.Posts[postID]
+			if !ok {
+				return fmt.Errorf("用户 %d 与不存在的内容 %d 有交互", userID, postID)
+			}
+			if weight > 0 && !post.Likes[userID] {
+				return fmt.Errorf("用户 %d 点赞了内容 %d，但点赞集合中没有记录", userID, postID)
+			}
+		}
+	}
+
+	for id, post := range sn.Posts {
+		if _, ok := sn.Users[post.AuthorID]; !ok {
+			return fmt.Errorf("内容 %d 的作者 %d 不存在", id, post.AuthorID)
+		}
+		for userID := range post.Likes {
+			if _, ok := sn.Users[userID]; !ok {
+				return fmt.Errorf("内容 %d 的点赞用户 %d 不存在", id, userID)
+			}
+			if sn.UserPostMatrix[userID][id] <= 0 {
+				return fmt.Errorf("内容 %d 的点赞用户 %d 在交互矩阵中没有记录", id, userID)
+			}
+		}
+	}
+
+	return nil
+}
+
 // 计算两个用户之间的相似度（基于共同好友和共同兴趣）
 func (sn *SocialNetwork) calculateUserSimilarity(userID1, userID2 int) float64 {
 	user1 := sn.Users[userID1]
@@ -215,6 +363,10 @@ func (pq *PriorityQueue) Pop() interface{} {
 
 // RecommendFriends 为指定用户推荐好友
 func (sn *SocialNetwork) RecommendFriends(userID int, count int) ([]*RecommendationItem, error) {
+	span := tracing.StartSpan("RecommendFriends")
+	span.SetAttribute("user", userID)
+	defer span.Finish()
+
 	user, ok := sn.Users[userID]
 	if !ok {
 		return nil, fmt.Errorf("用户ID %d 不存在", userID)
@@ -234,14 +386,18 @@ func (sn *SocialNetwork) RecommendFriends(userID int, count int) ([]*Recommendat
 	}
 
 	// 计算二度好友的推荐得分
+	scoreSpan := span.StartChild("二度好友打分")
 	for friendID := range user.Friends {
 		friend := sn.Users[friendID]
 
 		// 遍历朋友的朋友
 		for fofID := range friend.Friends {
 			if !visited[fofID] {
-				// 计算与这个二度好友的相似度
+				// 计算与这个二度好友的相似度，有影响力的用户适当加分
 				similarity := sn.calculateUserSimilarity(userID, fofID)
+				if sn.InfluenceWeight > 0 {
+					similarity += sn.InfluenceWeight * sn.influence(fofID)
+				}
 
 				// 加入优先队列
 				heap.Push(&pq, &RecommendationItem{
@@ -253,19 +409,27 @@ func (sn *SocialNetwork) RecommendFriends(userID int, count int) ([]*Recommendat
 			}
 		}
 	}
+	scoreSpan.SetAttribute("candidates", pq.Len())
+	scoreSpan.Finish()
 
 	// 获取前count个推荐结果
+	topSpan := span.StartChild("取TopN")
 	result := make([]*RecommendationItem, 0, min(count, pq.Len()))
 	for i := 0; i < count && pq.Len() > 0; i++ {
 		item := heap.Pop(&pq).(*RecommendationItem)
 		result = append(result, item)
 	}
+	topSpan.Finish()
 
 	return result, nil
 }
 
 // RecommendPosts 为指定用户推荐内容
 func (sn *SocialNetwork) RecommendPosts(userID int, count int) ([]*RecommendationItem, error) {
+	span := tracing.StartSpan("RecommendPosts")
+	span.SetAttribute("user", userID)
+	defer span.Finish()
+
 	user, ok := sn.Users[userID]
 	if !ok {
 		return nil, fmt.Errorf("用户ID %d 不存在", userID)
@@ -286,6 +450,7 @@ func (sn *SocialNetwork) RecommendPosts(userID int, count int) ([]*Recommendatio
 	// 2. 与用户兴趣相关的内容
 
 	// 好友互动内容权重
+	friendSpan := span.StartChild("好友互动打分")
 	friendPostScores := make(map[int]float64)
 
 	// 收集好友互动的内容
@@ -309,9 +474,19 @@ func (sn *SocialNetwork) RecommendPosts(userID int, count int) ([]*Recommendatio
 		}
 	}
 
+	friendSpan.SetAttribute("posts", len(friendPostScores))
+	friendSpan.Finish()
+
 	// 根据用户兴趣计算内容相关性
+	interestSpan := span.StartChild("兴趣匹配打分")
 	interestPostScores := make(map[int]float64)
 
+	// 有分类体系时，兴趣沿父子标签传播后再与内容标签匹配
+	interests := user.Interests
+	if sn.Taxonomy != nil {
+		interests = sn.Taxonomy.Expand(interests)
+	}
+
 	for postID, post := range sn.Posts {
 		if !interactedPosts[postID] {
 			// 计算内容与用户兴趣的匹配度
@@ -319,7 +494,7 @@ func (sn *SocialNetwork) RecommendPosts(userID int, count int) ([]*Recommendatio
 
 			// 根据标签计算匹配度
 			for _, tag := range post.Tags {
-				if weight, ok := user.Interests[tag]; ok {
+				if weight, ok := interests[tag]; ok {
 					matchScore += weight
 				}
 			}
@@ -328,7 +503,10 @@ func (sn *SocialNetwork) RecommendPosts(userID int, count int) ([]*Recommendatio
 		}
 	}
 
+	interestSpan.Finish()
+
 	// 结合两种推荐策略的得分
+	rankSpan := span.StartChild("合并排序")
 	combinedScores := make(map[int]float64)
 
 	for postID, friendScore := range friendPostScores {
@@ -354,16 +532,68 @@ func (sn *SocialNetwork) RecommendPosts(userID int, count int) ([]*Recommendatio
 		}
 	}
 
-	// 获取前count个推荐结果
+	// 获取前count个推荐结果，跳过与已选内容近似重复的内容
 	result := make([]*RecommendationItem, 0, min(count, pq.Len()))
-	for i := 0; i < count && pq.Len() > 0; i++ {
+	for len(result) < count && pq.Len() > 0 {
 		item := heap.Pop(&pq).(*RecommendationItem)
+		if sn.DedupThreshold > 0 && sn.duplicateOfAny(item.ID, result) {
+			continue
+		}
 		result = append(result, item)
 	}
+	rankSpan.Finish()
 
 	return result, nil
 }
 
+// PostDuplicate 一对近似重复的内容
+type PostDuplicate struct {
+	First, Second int     // 内容ID，First < Second
+	Similarity    float64 // 估计的 Jaccard 相似度
+}
+
+// postText 返回参与近似重复检测的内容文本
+func postText(post *Post) string {
+	return post.Title + "\n" + post.Content
+}
+
+// postDetector 返回内容的 MinHash 索引，首次调用时构建，之后随内容增删增量维护
+func (sn *SocialNetwork) postDetector() *practical_applications.NearDuplicateDetector {
+	if sn.duplicates == nil {
+		sn.duplicates = practical_applications.NewNearDuplicateDetector(practical_applications.DefaultNearDuplicateOptions)
+		for id, post := range sn.Posts {
+			sn.duplicates.Add(strconv.Itoa(id), postText(post))
+		}
+	}
+	return sn.duplicates
+}
+
+// FindNearDuplicates 找出相似度不低于 threshold 的内容对，按相似度从高到低排列
+func (sn *SocialNetwork) FindNearDuplicates(threshold float64) []PostDuplicate {
+	pairs := sn.postDetector().FindNearDuplicates(threshold)
+	result := make([]PostDuplicate, 0, len(pairs))
+	for _, pair := range pairs {
+		first, _ := strconv.Atoi(pair.A)
+		second, _ := strconv.Atoi(pair.B)
+		if first > second {
+			first, second = second, first
+		}
+		result = append(result, PostDuplicate{First: first, Second: second, Similarity: pair.Similarity})
+	}
+	return result
+}
+
+// duplicateOfAny 判断内容是否与已选的推荐结果近似重复
+func (sn *SocialNetwork) duplicateOfAny(postID int, selected []*RecommendationItem) bool {
+	detector := sn.postDetector()
+	for _, item := range selected {
+		if similarity, ok := detector.Similarity(strconv.Itoa(postID), strconv.Itoa(item.ID)); ok && similarity >= sn.DedupThreshold {
+			return true
+		}
+	}
+	return false
+}
+
 // min 返回两个整数中的较小值
 func min(a, b int) int {
 	if a < b {
@@ -403,15 +633,13 @@ func createDemoSocialNetwork() *SocialNetwork {
 		sn.AddUser(user)
 	}
 
-	// 创建社交关系（随机生成）
-	for i := 1; i <= 20; i++ {
-		// 每个用户随机添加3-7个好友
-		numFriends := 3 + rand.Intn(5)
-		for j := 0; j < numFriends; j++ {
-			friendID := 1 + rand.Intn(20)
-			if friendID != i && !sn.Users[i].Friends[friendID] {
-				sn.AddFriendship(i, friendID)
-			}
+	// 创建社交关系：BA 无标度模型，每个新用户与 3 个已有用户建立好友关系，少数用户成为社交枢纽
+	friendGraph := BarabasiAlbert(20, 3, rand.New(rand.NewSource(rand.Int63())))
+	for _, node := range friendGraph.Nodes() {
+		userID, _ := strconv.Atoi(node)
+		for _, neighbor := range friendGraph.Neighbors(node) {
+			friendID, _ := strconv.Atoi(neighbor)
+			sn.AddFriendship(userID, friendID)
 		}
 	}
 
@@ -469,6 +697,8 @@ func SocialRecommendationDemo() {
 
 	// 设置随机种子
 	rand.Seed(time.Now().UnixNano())
+	tracing.DefaultExporter().Reset()
+	defer tracing.SetEnabled(tracing.SetEnabled(true)) // 只在演示期间开启追踪
 
 	// 创建演示用的社交网络
 	sn := createDemoSocialNetwork()
@@ -637,6 +867,75 @@ func SocialRecommendationDemo() {
 			fmt.Println(joinStrings(reasons, "; "))
 		}
 	}
+
+	// 好友转载了排名第一的内容，推荐结果中去除近似重复
+	if len(postRecs) > 0 {
+		original := sn.Posts[postRecs[0].ID]
+		repost := &Post{
+			ID:        1000,
+			AuthorID:  original.AuthorID,
+			Title:     original.Title,
+			Content:   original.Content + "（转载）",
+			Tags:      original.Tags,
+			Timestamp: time.Now(),
+			Likes:     make(map[int]bool),
+		}
+		for friendID := range targetUser.Friends {
+			repost.AuthorID = friendID
+			break
+		}
+		sn.AddPost(repost)
+
+		fmt.Printf("\n%s 转载了《%s》，近似重复检测:\n", sn.Users[repost.AuthorID].Name, original.Title)
+		for _, dup := range sn.FindNearDuplicates(sn.DedupThreshold) {
+			fmt.Printf("  内容 %d 与 %d 相似度 %.2f\n", dup.First, dup.Second, dup.Similarity)
+		}
+		listPosts := func(recs []*RecommendationItem) string {
+			ids := make([]string, 0, len(recs))
+			for _, rec := range recs {
+				ids = append(ids, strconv.Itoa(rec.ID))
+			}
+			return joinStrings(ids, ", ")
+		}
+		threshold := sn.DedupThreshold
+		sn.DedupThreshold = 0
+		recs, _ := sn.RecommendPosts(targetUserID, 5)
+		fmt.Printf("  不去重的推荐: %s\n", listPosts(recs))
+		sn.DedupThreshold = threshold
+		recs, _ = sn.RecommendPosts(targetUserID, 5)
+		fmt.Printf("  去重后的推荐: %s\n", listPosts(recs))
+	}
+
+	// 注销一个好友，级联清理后重新推荐
+	for friendID := range targetUser.Friends {
+		friend := sn.Users[friendID]
+		authored := 0
+		for _, post := range sn.Posts {
+			if post.AuthorID == friendID {
+				authored++
+			}
+		}
+		sn.RemoveUser(friendID)
+		fmt.Printf("\n好友 %s 注销账号（同时删除其发布的 %d 篇内容），剩余 %d 个用户、%d 篇内容\n",
+			friend.Name, authored, len(sn.Users), len(sn.Posts))
+		if err := sn.CheckIntegrity(); err != nil {
+			fmt.Printf("一致性检查失败: %v\n", err)
+		} else {
+			fmt.Println("一致性检查通过")
+		}
+		if recs, err := sn.RecommendFriends(targetUserID, 3); err == nil {
+			names := make([]string, 0, len(recs))
+			for _, rec := range recs {
+				names = append(names, sn.Users[rec.ID].Name)
+			}
+			fmt.Printf("重新推荐好友: %s\n", joinStrings(names, ", "))
+		}
+		break
+	}
+
+	// 推荐计算的耗时分布（链路追踪）
+	fmt.Printf("\n推荐耗时分析:\n")
+	tracing.DefaultExporter().Report(os.Stdout)
 }
 
 // 辅助函数：连接字符串
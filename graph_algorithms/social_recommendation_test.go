@@ -0,0 +1,109 @@
+package graph_algorithms
+
+import "testing"
+
+// newRemovalNetwork 构造删除测试用的小网络：
+// 用户1、2、3两两为好友，用户1发布内容10并被用户2点赞，用户2发布内容20并被用户1、3点赞
+func newRemovalNetwork(t *testing.T) *SocialNetwork {
+	t.Helper()
+	sn := NewSocialNetwork()
+	for id := 1; id <= 3; id++ {
+		sn.AddUser(&User{ID: id, Interests: map[string]float64{}, Friends: map[int]bool{}})
+	}
+	sn.AddFriendship(1, 2)
+	sn.AddFriendship(1, 3)
+	sn.AddFriendship(2, 3)
+	sn.AddPost(&Post{ID: 10, AuthorID: 1, Title: "用户1的内容"})
+	sn.AddPost(&Post{ID: 20, AuthorID: 2, Title: "用户2的内容"})
+	sn.AddInteraction(2, 10, 1)
+	sn.AddInteraction(1, 20, 1)
+	sn.AddInteraction(3, 20, 0.5)
+	checkIntegrity(t, sn, "初始状态")
+	return sn
+}
+
+func checkIntegrity(t *testing.T, sn *SocialNetwork, step string) {
+	t.Helper()
+	if err := sn.CheckIntegrity(); err != nil {
+		t.Fatalf("%s后一致性检查失败: %v", step, err)
+	}
+}
+
+func TestRemoveUserCascades(t *testing.T) {
+	sn := newRemovalNetwork(t)
+
+	if !sn.RemoveUser(1) {
+		t.Fatal("删除存在的用户1应返回 true")
+	}
+	checkIntegrity(t, sn, "删除用户1")
+
+	if _, ok := sn.Users[1]; ok {
+		t.Error("用户1仍在用户表中")
+	}
+	if _, ok := sn.UserPostMatrix[1]; ok {
+		t.Error("用户1的交互矩阵行未删除")
+	}
+	for _, id := range []int{2, 3} {
+		if sn.Users[id].Friends[1] {
+			t.Errorf("用户 %d 的好友列表中仍有用户1", id)
+		}
+	}
+	if !sn.Users[2].Friends[3] || !sn.Users[3].Friends[2] {
+		t.Error("用户2与3之间的好友关系不应受影响")
+	}
+	if _, ok := sn.Posts[10]; ok {
+		t.Error("用户1发布的内容10未随作者删除")
+	}
+	if _, ok := sn.UserPostMatrix[2][10]; ok {
+		t.Error("用户2对已删除内容10的交互记录未清除")
+	}
+	if sn.Posts[20].Likes[1] {
+		t.Error("用户1对内容20的点赞未撤销")
+	}
+	if !sn.Posts[20].Likes[3] {
+		t.Error("用户3对内容20的点赞不应受影响")
+	}
+}
+
+func TestRemoveFriendshipNotExists(t *testing.T) {
+	sn := newRemovalNetwork(t)
+
+	if !sn.RemoveFriendship(1, 2) {
+		t.Fatal("解除存在的好友关系应返回 true")
+	}
+	checkIntegrity(t, sn, "解除好友关系1-2")
+
+	if sn.RemoveFriendship(1, 2) {
+		t.Error("再次解除已解除的好友关系应返回 false")
+	}
+	if sn.RemoveFriendship(2, 1) {
+		t.Error("反方向解除已解除的好友关系应返回 false")
+	}
+	if sn.RemoveFriendship(1, 99) {
+		t.Error("与不存在的用户解除好友关系应返回 false")
+	}
+	checkIntegrity(t, sn, "解除不存在的好友关系")
+
+	if !sn.Users[1].Friends[3] || !sn.Users[2].Friends[3] {
+		t.Error("其他好友关系不应受影响")
+	}
+}
+
+func TestRemoveUserTwice(t *testing.T) {
+	sn := newRemovalNetwork(t)
+
+	if !sn.RemoveUser(2) {
+		t.Fatal("第一次删除用户2应返回 true")
+	}
+	checkIntegrity(t, sn, "第一次删除用户2")
+
+	users, posts := len(sn.Users), len(sn.Posts)
+	if sn.RemoveUser(2) {
+		t.Error("第二次删除用户2应返回 false")
+	}
+	checkIntegrity(t, sn, "第二次删除用户2")
+
+	if len(sn.Users) != users || len(sn.Posts) != posts {
+		t.Errorf("重复删除改变了网络: 用户 %d -> %d，内容 %d -> %d", users, len(sn.Users), posts, len(sn.Posts))
+	}
+}
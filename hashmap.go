@@ -3,6 +3,8 @@ package main
 import (
 	"fmt"
 	"hash/fnv"
+
+	"github.com/strive/scenario/hashing"
 )
 
 // 哈希表节点
@@ -17,6 +19,7 @@ type HashMap struct {
 	buckets  []*Node
 	size     int
 	capacity int
+	hasher   hashing.Hasher // 可选的哈希函数，为空时使用 FNV-1a 32位
 }
 
 // 创建新的哈希表
@@ -36,8 +39,18 @@ func hash(key string) uint32 {
 	return h.Sum32()
 }
 
+// 创建使用指定哈希函数的哈希表，面向不可信输入时可用随机种子的 SipHash 防御 HashDoS
+func NewHashMapWithHasher(hasher hashing.Hasher) *HashMap {
+	h := NewHashMap()
+	h.hasher = hasher
+	return h
+}
+
 // 获取键在桶中的索引
 func (h *HashMap) getIndex(key string) int {
+	if h.hasher != nil {
+		return int(hashing.SumString(h.hasher, key) % uint64(h.capacity))
+	}
 	return int(hash(key) % uint32(h.capacity))
 }
 
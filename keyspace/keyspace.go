@@ -0,0 +1,207 @@
+package keyspace
+
+/*
+键空间事件通知
+
+原理：
+存储组件里的键被写入、删除或过期时，依赖它的模块（会话管理、缓存失效广播、选主）往往需要立即知道。
+如果靠定时轮询，要么轮询间隔太长导致反应慢，要么间隔太短浪费资源。
+键空间事件借鉴 Redis 的 keyspace notifications：存储在修改键时发布事件，
+订阅者按通配符模式订阅感兴趣的键，通过通道异步收到事件。
+
+关键特点：
+1. 四类事件：set（写入）、del（主动删除）、expired（过期，包括惰性删除和后台清理）、evicted（因容量或内存上限被淘汰）
+2. 订阅模式支持 * 匹配任意长度字符、? 匹配单个字符，如 session:*
+3. 发布不阻塞：订阅者的缓冲区满时丢弃事件并计数，慢消费者不会拖慢存储本身
+4. 没有订阅者时发布只有一次原子读的开销
+
+实现方式：
+- Notifier 维护订阅列表，每个订阅有独立的带缓冲通道
+- 发布时在读锁下遍历订阅并用 select 非阻塞发送
+- 取消订阅或关闭通知器时关闭对应通道，消费者的 range 循环自然结束
+
+应用场景：
+- 会话过期时清理关联资源
+- 缓存项被删除时广播失效消息
+- 租约键过期时触发重新选主
+
+优缺点：
+- 优点：实时、解耦，发布方不关心谁在监听
+- 缺点：事件可能因缓冲区满而丢失（与 Redis 一样是"至多一次"语义），不能代替持久化的变更日志
+
+以下实现了键空间事件的发布与订阅，SkiplistKVStore 和 TTLCache 使用它对外提供 Subscribe 接口。
+*/
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultBufferSize 每个订阅通道的默认缓冲大小
+const DefaultBufferSize = 64
+
+// EventType 事件类型
+type EventType int
+
+const (
+	EventSet     EventType = iota // 写入
+	EventDelete                   // 主动删除
+	EventExpired                  // 过期
+	EventEvicted                  // 因容量或内存上限被淘汰
+)
+
+// String 返回与 Redis 一致的事件名称
+func (t EventType) String() string {
+	switch t {
+	case EventSet:
+		return "set"
+	case EventDelete:
+		return "del"
+	case EventExpired:
+		return "expired"
+	case EventEvicted:
+		return "evicted"
+	default:
+		return "unknown"
+	}
+}
+
+// KeyEvent 键空间事件
+type KeyEvent struct {
+	Type EventType // 事件类型
+	Key  string    // 发生变化的键
+	Time time.Time // 事件发生时间
+}
+
+// subscription 一个订阅
+type subscription struct {
+	pattern string
+	ch      chan KeyEvent
+}
+
+// Notifier 键空间事件通知器，可被多个协程并发使用
+type Notifier struct {
+	mutex   sync.RWMutex
+	subs    map[<-chan KeyEvent]*subscription
+	buffer  int
+	active  int32  // 订阅数，用于无订阅时快速返回
+	dropped uint64 // 因缓冲区满而丢弃的事件数
+	closed  bool
+}
+
+// NewNotifier 创建通知器，buffer 为每个订阅通道的缓冲大小，小于等于0时使用默认值
+func NewNotifier(buffer int) *Notifier {
+	if buffer <= 0 {
+		buffer = DefaultBufferSize
+	}
+	return &Notifier{
+		subs:   make(map[<-chan KeyEvent]*subscription),
+		buffer: buffer,
+	}
+}
+
+// Subscribe 订阅匹配 pattern 的键的事件，通知器关闭后返回已关闭的通道
+func (n *Notifier) Subscribe(pattern string) <-chan KeyEvent {
+	ch := make(chan KeyEvent, n.buffer)
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if n.closed {
+		close(ch)
+		return ch
+	}
+	n.subs[ch] = &subscription{pattern: pattern, ch: ch}
+	atomic.AddInt32(&n.active, 1)
+	return ch
+}
+
+// Unsubscribe 取消订阅并关闭通道，重复取消时返回 false
+func (n *Notifier) Unsubscribe(ch <-chan KeyEvent) bool {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	sub, ok := n.subs[ch]
+	if !ok {
+		return false
+	}
+	delete(n.subs, ch)
+	atomic.AddInt32(&n.active, -1)
+	close(sub.ch)
+	return true
+}
+
+// Publish 发布事件，不会阻塞
+func (n *Notifier) Publish(eventType EventType, key string) {
+	if atomic.LoadInt32(&n.active) == 0 {
+		return
+	}
+	event := KeyEvent{Type: eventType, Key: key, Time: time.Now()}
+
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+	for _, sub := range n.subs {
+		if !Match(sub.pattern, key) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			atomic.AddUint64(&n.dropped, 1)
+		}
+	}
+}
+
+// Dropped 返回因订阅者缓冲区满而丢弃的事件数
+func (n *Notifier) Dropped() uint64 {
+	return atomic.LoadUint64(&n.dropped)
+}
+
+// Subscribers 返回当前订阅数
+func (n *Notifier) Subscribers() int {
+	return int(atomic.LoadInt32(&n.active))
+}
+
+// Close 关闭通知器及所有订阅通道，可重复调用
+func (n *Notifier) Close() {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if n.closed {
+		return
+	}
+	n.closed = true
+	for ch, sub := range n.subs {
+		close(sub.ch)
+		delete(n.subs, ch)
+	}
+	atomic.StoreInt32(&n.active, 0)
+}
+
+// Match 判断键是否匹配模式，* 匹配任意长度字符，? 匹配单个字符
+func Match(pattern, key string) bool {
+	p, k := []rune(pattern), []rune(key)
+	pi, ki := 0, 0
+	star, mark := -1, 0 // 最近一个 * 的位置，以及它当前匹配到的键位置
+
+	for ki < len(k) {
+		switch {
+		case pi < len(p) && p[pi] == '*':
+			star, mark = pi, ki
+			pi++
+		case pi < len(p) && (p[pi] == '?' || p[pi] == k[ki]):
+			pi++
+			ki++
+		case star >= 0:
+			// 回溯：让上一个 * 多吞一个字符
+			mark++
+			pi, ki = star+1, mark
+		default:
+			return false
+		}
+	}
+	for pi < len(p) && p[pi] == '*' {
+		pi++
+	}
+	return pi == len(p)
+}
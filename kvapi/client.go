@@ -0,0 +1,221 @@
+package kvapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/strive/scenario/cache_strategies"
+	"github.com/strive/scenario/practical_applications"
+)
+
+// DefaultClientTimeout 客户端默认的请求超时
+const DefaultClientTimeout = 5 * time.Second
+
+// Client HTTP+JSON 客户端，可被多个协程并发使用
+type Client struct {
+	baseURL string
+	http    *http.Client
+}
+
+// NewClient 创建客户端，baseURL 形如 http://127.0.0.1:8080
+func NewClient(baseURL string) *Client {
+	return &Client{
+		baseURL: baseURL,
+		http:    &http.Client{Timeout: DefaultClientTimeout},
+	}
+}
+
+// WithHTTPClient 替换底层的 http.Client（例如自定义超时或传输层）
+func (c *Client) WithHTTPClient(hc *http.Client) *Client {
+	c.http = hc
+	return c
+}
+
+// do 发送请求并把响应解析到 out，404 映射为 ErrKeyNotFound
+func (c *Client) do(method, path string, in, out interface{}) error {
+	var body bytes.Buffer
+	if in != nil {
+		if err := json.NewEncoder(&body).Encode(in); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, c.baseURL+path, &body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return practical_applications.ErrKeyNotFound
+	}
+	if resp.StatusCode >= 300 {
+		var e errorBody
+		if json.NewDecoder(resp.Body).Decode(&e) == nil && e.Error != "" {
+			return fmt.Errorf("请求失败(%d): %s", resp.StatusCode, e.Error)
+		}
+		return fmt.Errorf("请求失败(%d)", resp.StatusCode)
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Get 读取键值，键不存在时返回 ErrKeyNotFound
+func (c *Client) Get(key string) (string, error) {
+	var entry KVEntry
+	if err := c.do(http.MethodGet, "/kv/"+url.PathEscape(key), nil, &entry); err != nil {
+		return "", err
+	}
+	return entry.Value, nil
+}
+
+// Set 写入键值
+func (c *Client) Set(key, value string) error {
+	return c.do(http.MethodPut, "/kv/"+url.PathEscape(key), KVEntry{Value: value}, nil)
+}
+
+// SetWithTTL 写入带过期时间的键值
+func (c *Client) SetWithTTL(key, value string, ttl time.Duration) error {
+	if ttl < time.Millisecond {
+		return errors.New("TTL 至少为 1 毫秒")
+	}
+	return c.do(http.MethodPut, "/kv/"+url.PathEscape(key), KVEntry{Value: value, TTLMs: ttl.Milliseconds()}, nil)
+}
+
+// TTL 返回键的剩余过期时间，没有设置过期时间时返回 0
+func (c *Client) TTL(key string) (time.Duration, error) {
+	var entry KVEntry
+	if err := c.do(http.MethodGet, "/kv/"+url.PathEscape(key), nil, &entry); err != nil {
+		return 0, err
+	}
+	return time.Duration(entry.TTLMs) * time.Millisecond, nil
+}
+
+// Delete 删除键，键不存在时返回 ErrKeyNotFound
+func (c *Client) Delete(key string) error {
+	return c.do(http.MethodDelete, "/kv/"+url.PathEscape(key), nil, nil)
+}
+
+// Scan 按前缀扫描，limit 为 0 表示不限制
+func (c *Client) Scan(prefix string, limit int) ([]KVEntry, error) {
+	query := url.Values{"prefix": {prefix}, "limit": {strconv.Itoa(limit)}}
+	var entries []KVEntry
+	err := c.do(http.MethodGet, "/kv?"+query.Encode(), nil, &entries)
+	return entries, err
+}
+
+// SetScore 设置玩家分数
+func (c *Client) SetScore(id, name string, score int) error {
+	return c.do(http.MethodPut, "/leaderboard/"+url.PathEscape(id), PlayerScore{Name: name, Score: score}, nil)
+}
+
+// Leaderboard 返回分数最高的 limit 名玩家
+func (c *Client) Leaderboard(limit int) ([]PlayerScore, error) {
+	var players []PlayerScore
+	err := c.do(http.MethodGet, "/leaderboard?limit="+strconv.Itoa(limit), nil, &players)
+	return players, err
+}
+
+// CacheGet 读取缓存，未命中或已过期时返回 false
+func (c *Client) CacheGet(key string) (interface{}, bool, error) {
+	var entry CacheEntry
+	err := c.do(http.MethodGet, "/cache/"+url.PathEscape(key), nil, &entry)
+	if errors.Is(err, practical_applications.ErrKeyNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return entry.Value, true, nil
+}
+
+// CachePut 写入缓存，ttl 为 0 时使用服务端缓存的默认 TTL
+func (c *Client) CachePut(key string, value interface{}, ttl time.Duration) error {
+	return c.do(http.MethodPut, "/cache/"+url.PathEscape(key), CacheEntry{Value: value, TTLMs: ttl.Milliseconds()}, nil)
+}
+
+// CacheRemove 删除缓存项，不存在时返回 ErrKeyNotFound
+func (c *Client) CacheRemove(key string) error {
+	return c.do(http.MethodDelete, "/cache/"+url.PathEscape(key), nil, nil)
+}
+
+// Stats 返回服务端统计信息
+func (c *Client) Stats() (map[string]interface{}, error) {
+	stats := make(map[string]interface{})
+	err := c.do(http.MethodGet, "/stats", nil, &stats)
+	return stats, err
+}
+
+// 场景示例：在本机随机端口启动服务，通过客户端跨进程边界访问
+func KVAPIDemo() {
+	fmt.Println("HTTP+JSON 接口示例:")
+
+	store := practical_applications.NewSkiplistKVStore()
+	defer store.Close()
+	cache := cache_strategies.NewTTLCache()
+	defer cache.StopCleanup()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		fmt.Printf("监听失败: %v\n", err)
+		return
+	}
+	server := &http.Server{Handler: NewServer(store, cache)}
+	go server.Serve(listener)
+	defer server.Close()
+
+	client := NewClient("http://" + listener.Addr().String())
+	fmt.Printf("服务地址: %s\n", listener.Addr())
+
+	fmt.Println("\n1. 键值存储:")
+	client.Set("config:theme", "dark")
+	client.SetWithTTL("session:42", "user-42", 200*time.Millisecond)
+	value, _ := client.Get("config:theme")
+	fmt.Printf("GET config:theme = %s\n", value)
+	ttl, _ := client.TTL("session:42")
+	fmt.Printf("session:42 剩余 TTL ≈ %v\n", ttl.Round(10*time.Millisecond))
+	time.Sleep(300 * time.Millisecond)
+	if _, err := client.Get("session:42"); err != nil {
+		fmt.Printf("300ms 后 GET session:42: %v\n", err)
+	}
+
+	fmt.Println("\n2. 排行榜:")
+	for i, name := range []string{"张三", "李四", "王五", "赵六", "孙七"} {
+		client.SetScore(strconv.Itoa(1001+i), name, 8000+(i*737)%1500)
+	}
+	client.SetScore("1003", "王五", 9900) // 更新分数
+	top, _ := client.Leaderboard(3)
+	for _, p := range top {
+		fmt.Printf("  第%d名: %s - %d分\n", p.Rank, p.Name, p.Score)
+	}
+
+	fmt.Println("\n3. 缓存:")
+	client.CachePut("user:1", map[string]interface{}{"name": "张三", "vip": true}, 0)
+	cached, ok, _ := client.CacheGet("user:1")
+	fmt.Printf("GET user:1 -> %v (命中=%v)\n", cached, ok)
+	client.CacheRemove("user:1")
+	_, ok, _ = client.CacheGet("user:1")
+	fmt.Printf("删除后 GET user:1 命中=%v\n", ok)
+
+	fmt.Println("\n4. 前缀扫描与统计:")
+	players, _ := client.Scan(PlayerKeyPrefix, 0)
+	fmt.Printf("前缀 %q 下共 %d 个键\n", PlayerKeyPrefix, len(players))
+	stats, _ := client.Stats()
+	fmt.Printf("服务端统计: %v\n", stats)
+}
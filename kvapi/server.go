@@ -0,0 +1,359 @@
+package kvapi
+
+/*
+键值存储、排行榜与缓存的 HTTP+JSON 接口
+
+原理：
+仓库里的键值存储、排行榜和缓存原本只能在同一个进程内调用。把它们挂到 HTTP 接口上以后，
+其他进程（压测工具、故障注入脚本、另一种语言写的服务）就可以隔着真实的网络边界访问它们，
+观察超时、连接断开、并发请求等只有跨进程才会出现的情况。
+接口采用 REST 风格：资源路径表示对象，HTTP 方法表示操作，请求体和响应体都是 JSON。
+
+关键特点：
+1. 覆盖三类资源：/kv 键值存储（支持 TTL 和前缀扫描）、/leaderboard 排行榜、/cache TTL 缓存
+2. 错误统一返回 {"error": "..."}，键不存在返回 404，参数错误返回 400
+3. 服务端只依赖标准库 net/http，可以直接嵌入任何 http.Server
+4. 配套的 Client 把 HTTP 细节封装成普通的方法调用，404 映射回 ErrKeyNotFound
+
+实现方式：
+- 使用 Go 1.22 起支持的 "方法 路径/{参数}" 路由模式注册处理函数
+- 排行榜沿用跳表存储示例中的约定：键为 player:<ID>，值为 "名字|分数"，查询时按分数排序
+- 缓存值以任意 JSON 值保存，读取时原样返回
+
+应用场景：
+- 跨进程的集成测试与故障注入
+- 用 curl 手工调试存储组件
+- 作为其他语言客户端访问这些组件的入口
+
+优缺点：
+- 优点：协议通用，调试方便，不需要额外依赖
+- 缺点：JSON 编解码和 HTTP 头部带来额外开销，吞吐不如二进制协议
+
+以下实现了 HTTP+JSON 服务端，客户端见 client.go。
+*/
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/strive/scenario/cache_strategies"
+	"github.com/strive/scenario/lifecycle"
+	"github.com/strive/scenario/practical_applications"
+)
+
+// PlayerKeyPrefix 排行榜玩家在键值存储中的键前缀
+const PlayerKeyPrefix = "player:"
+
+// KVEntry 键值对
+type KVEntry struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	TTLMs int64  `json:"ttl_ms,omitempty"` // 剩余过期时间（毫秒），0 表示不过期
+}
+
+// PlayerScore 排行榜中的一名玩家
+type PlayerScore struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Score int    `json:"score"`
+	Rank  int    `json:"rank,omitempty"` // 名次，从 1 开始
+}
+
+// CacheEntry 缓存项
+type CacheEntry struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+	TTLMs int64       `json:"ttl_ms,omitempty"` // 过期时间（毫秒），0 表示使用缓存的默认 TTL
+}
+
+// errorBody 错误响应
+type errorBody struct {
+	Error string `json:"error"`
+}
+
+// Server HTTP+JSON 服务端
+type Server struct {
+	store *practical_applications.SkiplistKVStore
+	cache *cache_strategies.TTLCache
+	mux   *http.ServeMux
+}
+
+// NewServer 创建服务端，store 和 cache 由调用方负责关闭
+func NewServer(store *practical_applications.SkiplistKVStore, cache *cache_strategies.TTLCache) *Server {
+	s := &Server{store: store, cache: cache, mux: http.NewServeMux()}
+
+	s.mux.HandleFunc("GET /kv", s.handleScan)
+	s.mux.HandleFunc("GET /kv/{key}", s.handleGet)
+	s.mux.HandleFunc("PUT /kv/{key}", s.handleSet)
+	s.mux.HandleFunc("DELETE /kv/{key}", s.handleDelete)
+
+	s.mux.HandleFunc("GET /leaderboard", s.handleLeaderboard)
+	s.mux.HandleFunc("PUT /leaderboard/{id}", s.handleSetScore)
+
+	s.mux.HandleFunc("GET /cache/{key}", s.handleCacheGet)
+	s.mux.HandleFunc("PUT /cache/{key}", s.handleCachePut)
+	s.mux.HandleFunc("DELETE /cache/{key}", s.handleCacheRemove)
+
+	s.mux.HandleFunc("GET /stats", s.handleStats)
+	return s
+}
+
+// ServeHTTP 实现 http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// writeJSON 以 JSON 格式写出响应
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeError 写出错误响应
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorBody{Error: err.Error()})
+}
+
+// decodeBody 解析 JSON 请求体
+func decodeBody(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(v); err != nil {
+		return fmt.Errorf("无效的请求体: %w", err)
+	}
+	return nil
+}
+
+// handleGet GET /kv/{key}
+func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	value, err := s.store.Get([]byte(key))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	entry := KVEntry{Key: key, Value: string(value)}
+	if ttl, ok := s.store.GetTTL([]byte(key)); ok {
+		entry.TTLMs = ttl.Milliseconds()
+	}
+	writeJSON(w, http.StatusOK, entry)
+}
+
+// handleSet PUT /kv/{key}，请求体 {"value": "...", "ttl_ms": 0}
+func (s *Server) handleSet(w http.ResponseWriter, r *http.Request) {
+	var entry KVEntry
+	if err := decodeBody(r, &entry); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if entry.TTLMs < 0 {
+		writeError(w, http.StatusBadRequest, errors.New("ttl_ms 不能为负数"))
+		return
+	}
+	entry.Key = r.PathValue("key")
+	if entry.TTLMs > 0 {
+		s.store.SetWithTTL([]byte(entry.Key), []byte(entry.Value), time.Duration(entry.TTLMs)*time.Millisecond)
+	} else {
+		s.store.Set([]byte(entry.Key), []byte(entry.Value))
+	}
+	writeJSON(w, http.StatusOK, entry)
+}
+
+// handleDelete DELETE /kv/{key}
+func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
+	if !s.store.Delete([]byte(r.PathValue("key"))) {
+		writeError(w, http.StatusNotFound, practical_applications.ErrKeyNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleScan GET /kv?prefix=...&limit=...，结果按键排序
+func (s *Server) handleScan(w http.ResponseWriter, r *http.Request) {
+	limit, err := queryInt(r, "limit", 0)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	data := s.store.Scan([]byte(r.URL.Query().Get("prefix")), limit)
+	entries := make([]KVEntry, 0, len(data))
+	for key, value := range data {
+		entries = append(entries, KVEntry{Key: key, Value: string(value)})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	writeJSON(w, http.StatusOK, entries)
+}
+
+// handleLeaderboard GET /leaderboard?limit=...，按分数从高到低返回
+func (s *Server) handleLeaderboard(w http.ResponseWriter, r *http.Request) {
+	limit, err := queryInt(r, "limit", 10)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	players := make([]PlayerScore, 0)
+	for key, value := range s.store.Scan([]byte(PlayerKeyPrefix), 0) {
+		parts := strings.Split(string(value), "|")
+		if len(parts) != 2 {
+			continue
+		}
+		score, err := strconv.Atoi(parts[1])
+		if err != nil {
+			continue
+		}
+		players = append(players, PlayerScore{ID: strings.TrimPrefix(key, PlayerKeyPrefix), Name: parts[0], Score: score})
+	}
+	sort.Slice(players, func(i, j int) bool {
+		if players[i].Score != players[j].Score {
+			return players[i].Score > players[j].Score
+		}
+		return players[i].ID < players[j].ID
+	})
+	if limit > 0 && len(players) > limit {
+		players = players[:limit]
+	}
+	for i := range players {
+		players[i].Rank = i + 1
+	}
+	writeJSON(w, http.StatusOK, players)
+}
+
+// handleSetScore PUT /leaderboard/{id}，请求体 {"name": "...", "score": 100}
+func (s *Server) handleSetScore(w http.ResponseWriter, r *http.Request) {
+	var player PlayerScore
+	if err := decodeBody(r, &player); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if player.Name == "" || strings.Contains(player.Name, "|") {
+		writeError(w, http.StatusBadRequest, errors.New("玩家名不能为空且不能包含 |"))
+		return
+	}
+	player.ID = r.PathValue("id")
+	player.Rank = 0
+	value := fmt.Sprintf("%s|%d", player.Name, player.Score)
+	s.store.Set([]byte(PlayerKeyPrefix+player.ID), []byte(value))
+	writeJSON(w, http.StatusOK, player)
+}
+
+// handleCacheGet GET /cache/{key}
+func (s *Server) handleCacheGet(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	value, ok := s.cache.Get(key)
+	if !ok {
+		writeError(w, http.StatusNotFound, practical_applications.ErrKeyNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, CacheEntry{Key: key, Value: value})
+}
+
+// handleCachePut PUT /cache/{key}，请求体 {"value": 任意JSON, "ttl_ms": 0}
+func (s *Server) handleCachePut(w http.ResponseWriter, r *http.Request) {
+	var entry CacheEntry
+	if err := decodeBody(r, &entry); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if entry.TTLMs < 0 {
+		writeError(w, http.StatusBadRequest, errors.New("ttl_ms 不能为负数"))
+		return
+	}
+	entry.Key = r.PathValue("key")
+	if entry.TTLMs > 0 {
+		s.cache.SetWithTTL(entry.Key, entry.Value, time.Duration(entry.TTLMs)*time.Millisecond)
+	} else {
+		s.cache.Set(entry.Key, entry.Value)
+	}
+	writeJSON(w, http.StatusOK, entry)
+}
+
+// handleCacheRemove DELETE /cache/{key}
+func (s *Server) handleCacheRemove(w http.ResponseWriter, r *http.Request) {
+	if !s.cache.Remove(r.PathValue("key")) {
+		writeError(w, http.StatusNotFound, practical_applications.ErrKeyNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleStats GET /stats
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"kv_keys":        s.store.Size(),
+		"kv_active_keys": s.store.SizeActive(),
+		"cache_items":    s.cache.Size(),
+	})
+}
+
+// queryInt 读取整数查询参数，缺省时返回 def
+func queryInt(r *http.Request, name string, def int) (int, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("无效的参数 %s: %q", name, raw)
+	}
+	return value, nil
+}
+
+// RunCLI 命令行入口：kvapi [-addr :8080]，运行到收到 SIGINT、SIGTERM 为止，
+// 之后先停止HTTP服务（等待进行中的请求），再停止缓存清理协程、关闭存储
+func RunCLI(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("kvapi", flag.ContinueOnError)
+	fs.SetOutput(out)
+	addr := fs.String("addr", ":8080", "监听地址")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+
+	manager := lifecycle.NewManager()
+	store := practical_applications.NewSkiplistKVStore()
+	manager.RegisterStopper("跳表存储", store.Close)
+	cache := cache_strategies.NewTTLCache()
+	manager.RegisterStopper("TTL缓存清理", cache.StopCleanup)
+
+	// Serve 意外退出时结束 Run，和收到信号一样按顺序关闭
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	serveErr := make(chan error, 1)
+	httpServer := &http.Server{Handler: NewServer(store, cache)}
+	manager.Register(lifecycle.Hook{
+		Name:      "HTTP服务",
+		DependsOn: []string{"跳表存储", "TTL缓存清理"},
+		Start: func(context.Context) error {
+			go func() {
+				serveErr <- httpServer.Serve(listener)
+				cancel()
+			}()
+			return nil
+		},
+		Stop: httpServer.Shutdown,
+	})
+
+	fmt.Fprintf(out, "kvapi 监听于 http://%s\n", listener.Addr())
+	err = manager.Run(ctx)
+	select {
+	case serr := <-serveErr:
+		if !errors.Is(serr, http.ErrServerClosed) {
+			err = errors.Join(serr, err)
+		}
+	default:
+	}
+	return err
+}
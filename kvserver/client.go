@@ -0,0 +1,203 @@
+package kvserver
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/strive/scenario/practical_applications"
+)
+
+// DefaultClientTimeout 客户端默认的请求超时
+const DefaultClientTimeout = 5 * time.Second
+
+// Client 最简单的 RESP 客户端，一个连接，可被多个协程并发使用（请求串行发送）
+type Client struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	writer  *bufio.Writer
+	timeout time.Duration
+	mutex   sync.Mutex
+}
+
+// Dial 连接到 addr，形如 127.0.0.1:6380
+func Dial(addr string) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", addr, DefaultClientTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		conn:    conn,
+		reader:  bufio.NewReader(conn),
+		writer:  bufio.NewWriter(conn),
+		timeout: DefaultClientTimeout,
+	}, nil
+}
+
+// Do 发送一条命令并等待回复：简单字符串和批量字符串为 string，整数为 int64，空回复为 nil，
+// 数组为 []interface{}；服务端回复错误时返回 ServerError
+func (c *Client) Do(args ...string) (interface{}, error) {
+	replies, err := c.Pipeline([][]string{args})
+	if err != nil {
+		return nil, err
+	}
+	if err, ok := replies[0].(ServerError); ok {
+		return nil, err
+	}
+	return replies[0], nil
+}
+
+// Pipeline 一次写出多条命令再依次读取回复，错误回复以 ServerError 的形式放在对应位置
+func (c *Client) Pipeline(commands [][]string) ([]interface{}, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	for _, args := range commands {
+		writeCommand(c.writer, args)
+	}
+	if err := c.writer.Flush(); err != nil {
+		return nil, err
+	}
+
+	replies := make([]interface{}, len(commands))
+	for i := range commands {
+		reply, err := readReply(c.reader)
+		if err != nil {
+			if _, ok := err.(ServerError); !ok {
+				return nil, err
+			}
+			reply = err
+		}
+		replies[i] = reply
+	}
+	return replies, nil
+}
+
+// Close 关闭连接
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// KVServerDemo 在本地端口启动服务端，用客户端演示各条命令、流水线和连接数上限
+func KVServerDemo() {
+	fmt.Println("RESP 协议服务端示例:")
+
+	store := practical_applications.NewSkiplistKVStore()
+	defer store.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		fmt.Printf("监听失败: %v\n", err)
+		return
+	}
+	server := NewServer(store, Options{MaxClients: 2, IdleTimeout: time.Minute})
+	go server.Serve(listener)
+	defer server.Close()
+
+	addr := listener.Addr().String()
+	fmt.Printf("服务地址: %s（也可以用 redis-cli -p %d 访问）\n", addr, listener.Addr().(*net.TCPAddr).Port)
+	client, err := Dial(addr)
+	if err != nil {
+		fmt.Printf("连接失败: %v\n", err)
+		return
+	}
+	defer client.Close()
+
+	show := func(args ...string) {
+		reply, err := client.Do(args...)
+		if err != nil {
+			fmt.Printf("  %v -> (error) %v\n", args, err)
+			return
+		}
+		fmt.Printf("  %v -> %v\n", args, formatReply(reply))
+	}
+
+	fmt.Println("\n1. 字符串与过期时间:")
+	show("PING")
+	show("SET", "config:theme", "dark")
+	show("GET", "config:theme")
+	show("SET", "session:42", "user-42", "PX", "200")
+	show("TTL", "session:42")
+	show("EXPIRE", "config:theme", "3600")
+	show("TTL", "config:theme")
+	time.Sleep(300 * time.Millisecond)
+	show("GET", "session:42")
+	show("TTL", "session:42")
+	show("DEL", "config:theme", "missing")
+
+	fmt.Println("\n2. 流水线写入并用 SCAN 分批遍历:")
+	commands := make([][]string, 0, 25)
+	for i := 0; i < 25; i++ {
+		commands = append(commands, []string{"SET", fmt.Sprintf("user:%03d", i), strconv.Itoa(i)})
+	}
+	replies, _ := client.Pipeline(commands)
+	fmt.Printf("  一次写出 %d 条 SET，收到 %d 个回复\n", len(commands), len(replies))
+	cursor, total, batches := "0", 0, 0
+	for {
+		reply, err := client.Do("SCAN", cursor, "MATCH", "user:*", "COUNT", "10")
+		if err != nil {
+			fmt.Printf("  SCAN 失败: %v\n", err)
+			break
+		}
+		parts := reply.([]interface{})
+		keys := parts[1].([]interface{})
+		batches++
+		total += len(keys)
+		fmt.Printf("  第%d批: %d 个键，首个 %v，下一个游标 %v\n", batches, len(keys), keys[0], parts[0])
+		if cursor = parts[0].(string); cursor == "0" {
+			break
+		}
+	}
+	fmt.Printf("  共 %d 批 %d 个键\n", batches, total)
+
+	fmt.Println("\n3. 有序集合:")
+	show("ZADD", "leaderboard", "8200", "张三", "9100", "李四", "8650", "王五")
+	show("ZADD", "leaderboard", "9900", "王五")
+	show("ZRANGE", "leaderboard", "0", "-1", "WITHSCORES")
+	show("ZRANGE", "leaderboard", "-2", "-1")
+
+	fmt.Println("\n4. 错误回复:")
+	show("GET")
+	show("SET", "k", "v", "EX", "abc")
+	show("FLUSHALL")
+
+	fmt.Println("\n5. 连接数上限:")
+	second, _ := Dial(addr)
+	if second != nil {
+		defer second.Close()
+		second.Do("PING")
+	}
+	if third, err := Dial(addr); err == nil {
+		_, err := third.Do("PING")
+		fmt.Printf("  第3个连接: %v\n", err)
+		third.Close()
+	}
+	fmt.Printf("  服务端统计: %v\n", server.Stats())
+}
+
+// formatReply 把回复格式化为类似 redis-cli 的形式
+func formatReply(reply interface{}) string {
+	switch v := reply.(type) {
+	case nil:
+		return "(nil)"
+	case string:
+		return strconv.Quote(v)
+	case int64:
+		return "(integer) " + strconv.FormatInt(v, 10)
+	case []interface{}:
+		s := "["
+		for i, item := range v {
+			if i > 0 {
+				s += " "
+			}
+			s += formatReply(item)
+		}
+		return s + "]"
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,209 @@
+package kvserver
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// RESP（REdis Serialization Protocol）的编解码。
+// 请求是由批量字符串组成的数组：*<参数个数>\r\n 之后每个参数为 $<长度>\r\n<内容>\r\n；
+// 为了方便用 telnet、nc 手工调试，也接受以空格分隔的单行（inline）命令。
+// 回复有五种类型：简单字符串 +OK、错误 -ERR ...、整数 :1、批量字符串 $3\r\nfoo（$-1 表示空）、数组 *2 ...
+
+const (
+	maxBulkLength = 64 << 20 // 单个参数的长度上限
+	maxArgs       = 1 << 20  // 单条命令的参数个数上限
+	maxInlineSize = 64 << 10 // inline 命令的长度上限
+	maxPrealloc   = 1024     // 按数组头预分配的参数个数上限，更多的参数随读取增长
+)
+
+// ErrProtocol 请求不符合 RESP 格式，服务端回复错误后关闭连接
+var ErrProtocol = errors.New("Protocol error")
+
+// readLine 读取以 \r\n 结尾的一行，返回不含行尾的内容；行长超过读缓冲区时拼接多段，直到超过 limit
+func readLine(r *bufio.Reader, limit int) ([]byte, error) {
+	line, err := r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		long := append([]byte(nil), line...)
+		for err == bufio.ErrBufferFull && len(long) <= limit {
+			line, err = r.ReadSlice('\n')
+			long = append(long, line...)
+		}
+		line = long
+	}
+	if len(line) > limit {
+		return nil, fmt.Errorf("%w: 行过长", ErrProtocol)
+	}
+	if err != nil {
+		return nil, err
+	}
+	line = line[:len(line)-1]
+	if n := len(line); n > 0 && line[n-1] == '\r' {
+		line = line[:n-1]
+	}
+	return line, nil
+}
+
+// readCommand 读取一条命令，连接正常关闭时返回 io.EOF
+func readCommand(r *bufio.Reader) ([][]byte, error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if first[0] != '*' {
+		line, err := readLine(r, maxInlineSize)
+		if err != nil {
+			return nil, err
+		}
+		fields := strings.Fields(string(line))
+		args := make([][]byte, len(fields))
+		for i, field := range fields {
+			args[i] = []byte(field)
+		}
+		return args, nil
+	}
+
+	line, err := readLine(r, maxInlineSize)
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(string(line[1:]))
+	if err != nil || count > maxArgs {
+		return nil, fmt.Errorf("%w: 无效的数组长度", ErrProtocol)
+	}
+	// 不能完全相信数组头：一个很大的参数个数不应该让一行请求就分配出巨大的切片
+	args := make([][]byte, 0, min(max(count, 0), maxPrealloc))
+	for i := 0; i < count; i++ {
+		line, err := readLine(r, maxInlineSize)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, fmt.Errorf("%w: 期望 '$'，收到 %q", ErrProtocol, line)
+		}
+		size, err := strconv.Atoi(string(line[1:]))
+		if err != nil || size < 0 || size > maxBulkLength {
+			return nil, fmt.Errorf("%w: 无效的批量字符串长度", ErrProtocol)
+		}
+		arg := make([]byte, size+2)
+		if _, err := io.ReadFull(r, arg); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if arg[size] != '\r' || arg[size+1] != '\n' {
+			return nil, fmt.Errorf("%w: 批量字符串缺少 CRLF", ErrProtocol)
+		}
+		args = append(args, arg[:size])
+	}
+	return args, nil
+}
+
+// unexpectedEOF 命令读到一半连接关闭不是正常结束
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// replyWriter 写出 RESP 回复，内容先进入缓冲区，由调用方决定何时 Flush
+type replyWriter struct {
+	w *bufio.Writer
+}
+
+func (rw replyWriter) simple(s string) {
+	rw.w.WriteString("+" + s + "\r\n")
+}
+
+func (rw replyWriter) error(msg string) {
+	rw.w.WriteString("-" + strings.NewReplacer("\r", " ", "\n", " ").Replace(msg) + "\r\n")
+}
+
+func (rw replyWriter) integer(n int64) {
+	rw.w.WriteString(":" + strconv.FormatInt(n, 10) + "\r\n")
+}
+
+func (rw replyWriter) bulk(b []byte) {
+	rw.w.WriteString("$" + strconv.Itoa(len(b)) + "\r\n")
+	rw.w.Write(b)
+	rw.w.WriteString("\r\n")
+}
+
+func (rw replyWriter) null() {
+	rw.w.WriteString("$-1\r\n")
+}
+
+func (rw replyWriter) arrayHeader(n int) {
+	rw.w.WriteString("*" + strconv.Itoa(n) + "\r\n")
+}
+
+// writeCommand 把参数编码为 RESP 数组，客户端使用
+func writeCommand(w *bufio.Writer, args []string) {
+	w.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		w.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n" + arg + "\r\n")
+	}
+}
+
+// ServerError 服务端回复的错误
+type ServerError string
+
+func (e ServerError) Error() string { return string(e) }
+
+// readReply 读取一条回复：简单字符串和批量字符串返回 string，整数返回 int64，空批量字符串返回 nil，
+// 数组返回 []interface{}，错误回复返回 ServerError 类型的 error
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := readLine(r, maxBulkLength)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, fmt.Errorf("%w: 空回复", ErrProtocol)
+	}
+	switch line[0] {
+	case '+':
+		return string(line[1:]), nil
+	case '-':
+		return nil, ServerError(line[1:])
+	case ':':
+		return strconv.ParseInt(string(line[1:]), 10, 64)
+	case '$':
+		size, err := strconv.Atoi(string(line[1:]))
+		if err != nil {
+			return nil, fmt.Errorf("%w: 无效的批量字符串长度", ErrProtocol)
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return string(data[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(string(line[1:]))
+		if err != nil {
+			return nil, fmt.Errorf("%w: 无效的数组长度", ErrProtocol)
+		}
+		if count < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, count)
+		for i := range items {
+			item, err := readReply(r)
+			if err != nil {
+				if _, ok := err.(ServerError); !ok {
+					return nil, err
+				}
+				item = err
+			}
+			items[i] = item
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("%w: 未知的回复类型 %q", ErrProtocol, line[0])
+	}
+}
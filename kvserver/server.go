@@ -0,0 +1,632 @@
+package kvserver
+
+/*
+跳表键值存储的 RESP 协议服务端
+
+原理：
+kvapi 把存储挂到了 HTTP+JSON 接口上，方便 curl 调试，但每个请求都要带完整的 HTTP 头部和 JSON 编解码。
+Redis 使用的 RESP 协议是一种简单的文本协议：请求是批量字符串数组，回复按首字节区分类型，
+解析只需要按行读取长度前缀，不需要转义，一条连接上可以连续发送多条命令（流水线）。
+服务端实现 RESP 协议中的一个子集后，redis-cli、redis-benchmark 以及各语言的 Redis 客户端都可以直接访问
+SkiplistKVStore，不需要专门的客户端。
+
+关键特点：
+1. 支持 GET、SET [EX 秒|PX 毫秒]、DEL、EXPIRE、TTL、SCAN、ZADD、ZRANGE [WITHSCORES]，以及 PING、ECHO、COMMAND、QUIT
+2. 错误回复与 Redis 的措辞一致，例如 "ERR wrong number of arguments for 'get' command"
+3. 支持流水线：读缓冲区中没有后续命令时才把回复刷到网络，一批命令只触发一次写系统调用
+4. 每个连接作为一个任务提交到 GoroutinePool，连接数达到上限时回复 "ERR max number of clients reached" 并关闭新连接
+5. 连接空闲超过 IdleTimeout 后由服务端关闭；Close 关闭监听和所有连接，并等待处理连接的任务全部退出
+
+实现方式：
+- 协程池的工作协程数等于最大连接数，每个连接独占一个工作协程直到断开；接受连接前先检查连接数，
+  因此提交任务不会因为队列已满而阻塞接受循环
+- 普通键值对与有序集合分别保存在 SkiplistKVStore 的键空间和 ZSet 中，同名的键与有序集合互不影响；
+  DEL 同时删除二者，没有实现 Redis 的 WRONGTYPE 检查
+- SCAN 的游标是上一批最后一个键的十六进制编码（"0" 表示从头开始），下一批用快照迭代器从该键之后继续；
+  默认存储按键的字典序排列，因此游标是单调的，遍历期间一直存在的键一定会被返回
+- 有序集合只在内存中，使用 -dir 启用预写日志时也只有普通键值对会被持久化
+
+应用场景：
+- 用 redis-cli 手工调试存储
+- 用 redis-benchmark 压测跳表存储
+- 让其他语言的服务通过现成的 Redis 客户端访问存储
+
+优缺点：
+- 优点：协议简单、开销小，可以复用 Redis 生态的工具和客户端
+- 缺点：只实现了命令的一个子集，没有认证、多数据库和发布订阅
+
+以下实现了 RESP 服务端，协议编解码见 resp.go，客户端与示例见 client.go。
+*/
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/strive/scenario/concurrency"
+	"github.com/strive/scenario/keyspace"
+	"github.com/strive/scenario/lifecycle"
+	"github.com/strive/scenario/practical_applications"
+)
+
+// ErrServerClosed Serve 在服务端关闭后返回该错误
+var ErrServerClosed = errors.New("kvserver: 服务端已关闭")
+
+// Options 服务端配置
+type Options struct {
+	MaxClients  int           // 最大连接数，也是协程池的工作协程数
+	IdleTimeout time.Duration // 连接空闲超时，0 表示不限制
+}
+
+// DefaultOptions 默认配置：最多64个连接，空闲5分钟后断开
+var DefaultOptions = Options{
+	MaxClients:  64,
+	IdleTimeout: 5 * time.Minute,
+}
+
+// defaultScanCount SCAN 未指定 COUNT 时每批检查的键数
+const defaultScanCount = 10
+
+// Server RESP 协议服务端
+type Server struct {
+	store   *practical_applications.SkiplistKVStore
+	options Options
+	pool    *concurrency.GoroutinePool
+
+	mutex     sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+
+	totalConns    int64 // 接受的连接总数
+	rejectedConns int64 // 因连接数达到上限被拒绝的连接数
+	commands      int64 // 处理的命令数
+}
+
+// NewServer 创建服务端，options 中为零的字段使用 DefaultOptions 的值
+func NewServer(store *practical_applications.SkiplistKVStore, options Options) *Server {
+	if options.MaxClients <= 0 {
+		options.MaxClients = DefaultOptions.MaxClients
+	}
+	if options.IdleTimeout < 0 {
+		options.IdleTimeout = 0
+	}
+	return &Server{
+		store:     store,
+		options:   options,
+		pool:      concurrency.NewGoroutinePool(options.MaxClients, options.MaxClients),
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+	}
+}
+
+// Serve 在 listener 上接受连接，直到 listener 出错或服务端关闭；关闭后返回 ErrServerClosed
+func (s *Server) Serve(listener net.Listener) error {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		listener.Close()
+		return ErrServerClosed
+	}
+	s.listeners[listener] = struct{}{}
+	s.mutex.Unlock()
+
+	defer func() {
+		s.mutex.Lock()
+		delete(s.listeners, listener)
+		s.mutex.Unlock()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			s.mutex.Lock()
+			closed := s.closed
+			s.mutex.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+
+		if !s.track(conn) {
+			atomic.AddInt64(&s.rejectedConns, 1)
+			conn.SetWriteDeadline(time.Now().Add(time.Second))
+			conn.Write([]byte("-ERR max number of clients reached\r\n"))
+			conn.Close()
+			continue
+		}
+		atomic.AddInt64(&s.totalConns, 1)
+		if err := s.pool.Submit(func(ctx context.Context) error {
+			s.serveConn(conn)
+			return nil
+		}); err != nil {
+			s.untrack(conn)
+			conn.Close()
+		}
+	}
+}
+
+// track 登记新连接，服务端已关闭或连接数达到上限时返回 false
+func (s *Server) track(conn net.Conn) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed || len(s.conns) >= s.options.MaxClients {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	return true
+}
+
+// untrack 注销连接
+func (s *Server) untrack(conn net.Conn) {
+	s.mutex.Lock()
+	delete(s.conns, conn)
+	s.mutex.Unlock()
+}
+
+// Close 停止接受连接，关闭所有连接，并等待处理连接的任务全部退出；可重复调用
+func (s *Server) Close() {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return
+	}
+	s.closed = true
+	for listener := range s.listeners {
+		listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mutex.Unlock()
+
+	s.pool.Shutdown()
+}
+
+// Stats 返回服务端统计信息
+func (s *Server) Stats() map[string]interface{} {
+	s.mutex.Lock()
+	active := len(s.conns)
+	s.mutex.Unlock()
+	return map[string]interface{}{
+		"connected_clients":    active,
+		"max_clients":          s.options.MaxClients,
+		"total_connections":    atomic.LoadInt64(&s.totalConns),
+		"rejected_connections": atomic.LoadInt64(&s.rejectedConns),
+		"total_commands":       atomic.LoadInt64(&s.commands),
+	}
+}
+
+// serveConn 处理一个连接上的全部命令，直到客户端断开、发送 QUIT、空闲超时或协议错误
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		s.untrack(conn)
+		conn.Close()
+	}()
+
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(conn)
+	reply := replyWriter{w: writer}
+
+	for {
+		if s.options.IdleTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(s.options.IdleTimeout))
+		}
+		args, err := readCommand(reader)
+		if err != nil {
+			if errors.Is(err, ErrProtocol) {
+				reply.error("ERR " + err.Error())
+				writer.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		atomic.AddInt64(&s.commands, 1)
+		quit := s.execute(args, reply)
+
+		// 流水线：读缓冲区中还有后续命令时先不刷新，一批命令的回复一起写出
+		if quit || reader.Buffered() == 0 {
+			if err := writer.Flush(); err != nil {
+				return
+			}
+		}
+		if quit {
+			return
+		}
+	}
+}
+
+// execute 执行一条命令并写出回复，命令为 QUIT 时返回 true
+func (s *Server) execute(args [][]byte, reply replyWriter) bool {
+	name := strings.ToLower(string(args[0]))
+	if arity, exists := commandArity[name]; exists && !arityOK(arity, len(args)) {
+		reply.error(fmt.Sprintf("ERR wrong number of arguments for '%s' command", name))
+		return false
+	}
+
+	switch name {
+	case "ping":
+		if len(args) > 2 {
+			reply.error("ERR wrong number of arguments for 'ping' command")
+		} else if len(args) == 2 {
+			reply.bulk(args[1])
+		} else {
+			reply.simple("PONG")
+		}
+	case "echo":
+		reply.bulk(args[1])
+	case "quit":
+		reply.simple("OK")
+		return true
+	case "command":
+		// redis-cli 启动时会发送 COMMAND DOCS 获取命令文档，回复空数组即可
+		reply.arrayHeader(0)
+	case "get":
+		s.get(args, reply)
+	case "set":
+		s.set(args, reply)
+	case "del":
+		s.del(args, reply)
+	case "expire":
+		s.expire(args, reply)
+	case "ttl":
+		s.ttl(args, reply)
+	case "scan":
+		s.scan(args, reply)
+	case "zadd":
+		s.zadd(args, reply)
+	case "zrange":
+		s.zrange(args, reply)
+	default:
+		reply.error(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+	}
+	return false
+}
+
+// commandArity 命令的参数个数（包括命令名），负数 -n 表示至少 n 个
+var commandArity = map[string]int{
+	"ping":    -1,
+	"echo":    2,
+	"quit":    -1,
+	"command": -1,
+	"get":     2,
+	"set":     -3,
+	"del":     -2,
+	"expire":  3,
+	"ttl":     2,
+	"scan":    -2,
+	"zadd":    -4,
+	"zrange":  -4,
+}
+
+// arityOK 判断参数个数是否符合要求
+func arityOK(arity, n int) bool {
+	if arity >= 0 {
+		return n == arity
+	}
+	return n >= -arity
+}
+
+// GET key
+func (s *Server) get(args [][]byte, reply replyWriter) {
+	value, err := s.store.Get(args[1])
+	if err != nil {
+		reply.null()
+		return
+	}
+	reply.bulk(value)
+}
+
+// SET key value [EX seconds|PX milliseconds]
+func (s *Server) set(args [][]byte, reply replyWriter) {
+	var ttl time.Duration
+	for i := 3; i < len(args); i++ {
+		option := strings.ToLower(string(args[i]))
+		if (option != "ex" && option != "px") || ttl != 0 || i+1 >= len(args) {
+			reply.error("ERR syntax error")
+			return
+		}
+		n, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+		if err != nil {
+			reply.error("ERR value is not an integer or out of range")
+			return
+		}
+		unit := time.Second
+		if option == "px" {
+			unit = time.Millisecond
+		}
+		if n <= 0 || n > int64(math.MaxInt64/unit) {
+			reply.error("ERR invalid expire time in 'set' command")
+			return
+		}
+		ttl = time.Duration(n) * unit
+		i++
+	}
+
+	// 存储会直接引用值，复制一份，避免与读缓冲区共享内存
+	value := append([]byte(nil), args[2]...)
+	if ttl > 0 {
+		s.store.SetWithTTL(args[1], value, ttl)
+	} else {
+		s.store.Set(args[1], value)
+	}
+	reply.simple("OK")
+}
+
+// DEL key [key ...]，同名的普通键和有序集合都会被删除
+func (s *Server) del(args [][]byte, reply replyWriter) {
+	var deleted int64
+	for _, key := range args[1:] {
+		_, err := s.store.Get(key)
+		removed := s.store.Delete(key) && err == nil
+		if s.store.DeleteZSet(string(key)) {
+			removed = true
+		}
+		if removed {
+			deleted++
+		}
+	}
+	reply.integer(deleted)
+}
+
+// EXPIRE key seconds
+func (s *Server) expire(args [][]byte, reply replyWriter) {
+	seconds, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil {
+		reply.error("ERR value is not an integer or out of range")
+		return
+	}
+	if seconds > int64(math.MaxInt64/time.Second) {
+		reply.error("ERR invalid expire time in 'expire' command")
+		return
+	}
+	if _, err := s.store.Get(args[1]); err != nil {
+		reply.integer(0)
+		return
+	}
+	// 小于等于0表示立即删除，不做乘法，避免很大的负数溢出成正的过期时间
+	ttl := time.Duration(0)
+	if seconds > 0 {
+		ttl = time.Duration(seconds) * time.Second
+	}
+	if s.store.Expire(args[1], ttl) {
+		reply.integer(1)
+	} else {
+		reply.integer(0)
+	}
+}
+
+// TTL key：键不存在返回 -2，没有过期时间返回 -1，否则返回剩余秒数
+func (s *Server) ttl(args [][]byte, reply replyWriter) {
+	if _, err := s.store.Get(args[1]); err != nil {
+		reply.integer(-2)
+		return
+	}
+	remaining, exists := s.store.GetTTL(args[1])
+	if !exists {
+		reply.integer(-1)
+		return
+	}
+	// 与 Redis 一样四舍五入到秒
+	reply.integer(int64((remaining + time.Second/2) / time.Second))
+}
+
+// SCAN cursor [MATCH pattern] [COUNT count]
+func (s *Server) scan(args [][]byte, reply replyWriter) {
+	var start []byte
+	if cursor := string(args[1]); cursor != "0" {
+		last, err := hex.DecodeString(cursor)
+		if err != nil || len(last) == 0 {
+			reply.error("ERR invalid cursor")
+			return
+		}
+		// 从上一批最后一个键之后继续：字典序中紧跟在 last 之后的键是 last+"\x00"
+		start = append(last, 0)
+	}
+
+	pattern, count := "", defaultScanCount
+	for i := 2; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			reply.error("ERR syntax error")
+			return
+		}
+		switch strings.ToLower(string(args[i])) {
+		case "match":
+			pattern = string(args[i+1])
+		case "count":
+			n, err := strconv.Atoi(string(args[i+1]))
+			if err != nil {
+				reply.error("ERR value is not an integer or out of range")
+				return
+			}
+			if n < 1 {
+				reply.error("ERR syntax error")
+				return
+			}
+			count = n
+		default:
+			reply.error("ERR syntax error")
+			return
+		}
+	}
+
+	// 与 Redis 一样，COUNT 限制的是检查的键数，MATCH 在检查之后过滤，因此一批返回的键可能少于 COUNT
+	it := s.store.Iterate(start, nil)
+	defer it.Close()
+	var keys [][]byte
+	var last []byte
+	examined := 0
+	for examined < count && it.Next() {
+		examined++
+		last = it.Key()
+		if pattern == "" || keyspace.Match(pattern, string(last)) {
+			keys = append(keys, last)
+		}
+	}
+	next := "0"
+	if examined == count && it.Next() {
+		next = hex.EncodeToString(last)
+	}
+
+	reply.arrayHeader(2)
+	reply.bulk([]byte(next))
+	reply.arrayHeader(len(keys))
+	for _, key := range keys {
+		reply.bulk(key)
+	}
+}
+
+// ZADD key score member [score member ...]
+func (s *Server) zadd(args [][]byte, reply replyWriter) {
+	if len(args)%2 != 0 {
+		reply.error("ERR syntax error")
+		return
+	}
+	// 先解析全部分数，任何一个无效时整条命令不生效
+	members := make([]practical_applications.ZMember, 0, (len(args)-2)/2)
+	for i := 2; i < len(args); i += 2 {
+		score, err := strconv.ParseFloat(string(args[i]), 64)
+		if err != nil || math.IsNaN(score) {
+			reply.error("ERR value is not a valid float")
+			return
+		}
+		members = append(members, practical_applications.ZMember{Member: args[i+1], Score: score})
+	}
+
+	zset := s.store.ZSet(string(args[1]))
+	var added int64
+	for _, m := range members {
+		if zset.ZAdd(m.Member, m.Score) {
+			added++
+		}
+	}
+	reply.integer(added)
+}
+
+// ZRANGE key start stop [WITHSCORES]，start 和 stop 可以为负数，-1 表示最后一名
+func (s *Server) zrange(args [][]byte, reply replyWriter) {
+	withScores := false
+	if len(args) == 5 && strings.EqualFold(string(args[4]), "withscores") {
+		withScores = true
+	} else if len(args) != 4 {
+		reply.error("ERR syntax error")
+		return
+	}
+	start, err1 := strconv.Atoi(string(args[2]))
+	stop, err2 := strconv.Atoi(string(args[3]))
+	if err1 != nil || err2 != nil {
+		reply.error("ERR value is not an integer or out of range")
+		return
+	}
+
+	zset, exists := s.store.LookupZSet(string(args[1]))
+	if !exists {
+		reply.arrayHeader(0)
+		return
+	}
+	n := zset.ZCard()
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	members := zset.ZRange(start, stop)
+
+	if withScores {
+		reply.arrayHeader(2 * len(members))
+	} else {
+		reply.arrayHeader(len(members))
+	}
+	for _, m := range members {
+		reply.bulk(m.Member)
+		if withScores {
+			reply.bulk([]byte(strconv.FormatFloat(m.Score, 'g', 17, 64)))
+		}
+	}
+}
+
+// RunCLI 命令行入口：go run . kvserver -addr :6380 [-dir data]，之后可以用 redis-cli -p 6380 访问；
+// 收到 SIGINT、SIGTERM 时先关闭服务端，再关闭存储（刷写预写日志）
+func RunCLI(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("kvserver", flag.ContinueOnError)
+	fs.SetOutput(out)
+	addr := fs.String("addr", ":6380", "监听地址")
+	dir := fs.String("dir", "", "预写日志目录，为空时只保存在内存中")
+	maxClients := fs.Int("max-clients", DefaultOptions.MaxClients, "最大连接数")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var store *practical_applications.SkiplistKVStore
+	if *dir != "" {
+		walOptions := practical_applications.DefaultWALOptions
+		walOptions.Dir = *dir
+		var err error
+		if store, err = practical_applications.OpenSkiplistKVStore(walOptions); err != nil {
+			return err
+		}
+	} else {
+		store = practical_applications.NewSkiplistKVStore()
+	}
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		store.Close()
+		return err
+	}
+	options := DefaultOptions
+	options.MaxClients = *maxClients
+	server := NewServer(store, options)
+
+	// Serve 意外退出时结束 Run，和收到信号一样按顺序关闭
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	serveErr := make(chan error, 1)
+
+	manager := lifecycle.NewManager()
+	manager.RegisterStopper("跳表存储", store.Close)
+	manager.Register(lifecycle.Hook{
+		Name:      "RESP服务端",
+		DependsOn: []string{"跳表存储"},
+		Start: func(context.Context) error {
+			go func() {
+				serveErr <- server.Serve(listener)
+				cancel()
+			}()
+			return nil
+		},
+		Stop: lifecycle.StopFunc(server.Close),
+	})
+
+	fmt.Fprintf(out, "kvserver 监听于 %s，可以用 redis-cli 访问\n", listener.Addr())
+	err = manager.Run(ctx)
+	select {
+	case serr := <-serveErr:
+		if !errors.Is(serr, ErrServerClosed) {
+			err = errors.Join(serr, err)
+		}
+	default:
+	}
+	return err
+}
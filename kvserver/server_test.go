@@ -0,0 +1,69 @@
+package kvserver
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/strive/scenario/practical_applications"
+)
+
+// newTestClient 在本地端口启动服务端并返回连接到它的客户端
+func newTestClient(t *testing.T) *Client {
+	t.Helper()
+	store := practical_applications.NewSkiplistKVStore()
+	t.Cleanup(store.Close)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	server := NewServer(store, Options{IdleTimeout: time.Minute})
+	go server.Serve(listener)
+	t.Cleanup(server.Close)
+
+	client, err := Dial(listener.Addr().String())
+	if err != nil {
+		t.Fatalf("连接失败: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// EXPIRE 的秒数小于等于0时立即删除键，很大的负数不能溢出成正的过期时间
+func TestExpireNonPositiveDeletesKey(t *testing.T) {
+	client := newTestClient(t)
+
+	for _, seconds := range []string{"0", "-1", "-9300000000", "-9223372036854775808"} {
+		if _, err := client.Do("SET", "k", "v"); err != nil {
+			t.Fatalf("SET 失败: %v", err)
+		}
+		reply, err := client.Do("EXPIRE", "k", seconds)
+		if err != nil || reply != int64(1) {
+			t.Fatalf("EXPIRE k %s 返回 %v，错误 %v，期望 1", seconds, reply, err)
+		}
+		if reply, err := client.Do("GET", "k"); err != nil || reply != nil {
+			t.Errorf("EXPIRE k %s 之后 GET 返回 %v，错误 %v，期望键已删除", seconds, reply, err)
+		}
+	}
+}
+
+// 超过读缓冲区（4 KiB）的 inline 命令也能读取，超过 maxInlineSize 时才是协议错误
+func TestReadCommandLongInline(t *testing.T) {
+	value := strings.Repeat("v", 16<<10)
+	args, err := readCommand(bufio.NewReader(strings.NewReader("SET k " + value + "\r\n")))
+	if err != nil {
+		t.Fatalf("读取 16 KiB 的 inline 命令失败: %v", err)
+	}
+	if len(args) != 3 || string(args[2]) != value {
+		t.Fatalf("解析出 %d 个参数，期望 3 个且值完整", len(args))
+	}
+
+	tooLong := "SET k " + strings.Repeat("v", maxInlineSize) + "\r\n"
+	if _, err := readCommand(bufio.NewReader(strings.NewReader(tooLong))); !errors.Is(err, ErrProtocol) {
+		t.Fatalf("超长 inline 命令返回 %v，期望协议错误", err)
+	}
+}
@@ -0,0 +1,331 @@
+package leakcheck
+
+/*
+Goroutine泄漏与死锁检测工具
+
+原理：
+goroutine泄漏是Go程序中最常见的资源泄漏：后台协程没有退出通道、阻塞在无人接收的通道上、
+或者等待一个永远不会到来的信号。泄漏的协程不会报错，只会悄悄占用内存和调度资源。
+检测方法很直接：在运行一段代码之前和之后分别给所有goroutine拍"快照"（runtime.Stack），
+之后多出来且在宽限期内仍未退出的goroutine就是泄漏的协程。
+
+死锁（或长时间卡住）则通过看门狗检测：给一段代码设定超时时间，
+超时后打印所有goroutine的调用栈，帮助定位卡在哪里。
+
+关键特点：
+1. Snapshot 解析 runtime.Stack 的输出，得到每个goroutine的ID、状态和调用栈
+2. Check 对比前后快照，并在宽限期内轮询，等待正在退出的协程
+3. 自动忽略运行时内部协程和检测器自身，有意常驻的后台协程通过 IgnoreCreator 选项忽略
+4. Watchdog 超时后转储所有调用栈，不影响被检测代码的运行
+
+实现方式：
+- 按goroutine ID对比快照（ID在进程内单调递增，不会复用）
+- 通过调用栈中的函数名过滤无关协程
+- 看门狗使用 time.AfterFunc，停止后不留下任何协程
+
+应用场景：
+- 检查演示代码、后台组件（清理协程、漏桶、心跳监控）是否正确退出
+- 在集成测试中发现协程泄漏
+- 排查偶发的卡死问题
+
+优缺点：
+- 优点：零依赖，输出直观，包含完整调用栈
+- 缺点：依赖 runtime.Stack 的文本格式；只能发现"仍然存活"的协程，无法自动区分有意常驻的后台协程，需要通过 IgnoreCreator 声明
+
+以下实现了goroutine快照、泄漏检查和看门狗。
+*/
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultGracePeriod 默认的宽限期：等待正在退出的协程
+const DefaultGracePeriod = 500 * time.Millisecond
+
+// Goroutine 单个goroutine的快照信息
+type Goroutine struct {
+	ID          int64  // goroutine ID
+	State       string // 状态（如 running、chan receive、select）
+	TopFunction string // 栈顶的用户函数
+	CreatedBy   string // 创建该协程的函数
+	Stack       string // 完整调用栈
+}
+
+// String 返回简要描述
+func (g Goroutine) String() string {
+	return fmt.Sprintf("goroutine %d [%s] %s (created by %s)", g.ID, g.State, g.TopFunction, g.CreatedBy)
+}
+
+// ignoredFunctions 检查时忽略的协程（运行时内部协程、测试框架、检测器自身）
+var ignoredFunctions = []string{
+	"runtime.goexit",
+	"runtime.gopark",
+	"runtime.main",
+	"testing.RunTests",
+	"testing.(*T).Run",
+	"os/signal.signal_recv",
+	"os/signal.loop",
+	"leakcheck.Snapshot",
+	"leakcheck.dumpAll",
+}
+
+// Option 检查选项
+type Option func(*options)
+
+// options 一次检查额外忽略的协程
+type options struct {
+	ignoredCreators []string
+}
+
+// IgnoreCreator 忽略由指定函数创建的协程（按子串匹配 created by 行），
+// 用于有意常驻到进程退出的后台协程，例如 "concurrency.SharedTimingWheel"
+func IgnoreCreator(functions ...string) Option {
+	return func(o *options) {
+		o.ignoredCreators = append(o.ignoredCreators, functions...)
+	}
+}
+
+// Snapshot 获取当前所有goroutine的快照
+func Snapshot() []Goroutine {
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, len(buf)*2)
+	}
+	return parseStacks(buf)
+}
+
+// parseStacks 解析 runtime.Stack(all=true) 的输出
+func parseStacks(data []byte) []Goroutine {
+	blocks := bytes.Split(data, []byte("\n\n"))
+	result := make([]Goroutine, 0, len(blocks))
+
+	for _, block := range blocks {
+		text := strings.TrimSpace(string(block))
+		if !strings.HasPrefix(text, "goroutine ") {
+			continue
+		}
+		lines := strings.Split(text, "\n")
+
+		// 首行格式: goroutine 18 [chan receive, 2 minutes]:
+		header := lines[0]
+		g := Goroutine{Stack: text}
+		fields := strings.SplitN(strings.TrimPrefix(header, "goroutine "), " ", 2)
+		g.ID, _ = strconv.ParseInt(fields[0], 10, 64)
+		if len(fields) > 1 {
+			state := strings.TrimSuffix(fields[1], ":")
+			state = strings.Trim(state, "[]")
+			if idx := strings.Index(state, ","); idx >= 0 {
+				state = state[:idx]
+			}
+			g.State = state
+		}
+
+		// 函数行与文件行交替出现，函数行不以制表符开头
+		for i := 1; i < len(lines); i++ {
+			line := lines[i]
+			if strings.HasPrefix(line, "\t") {
+				continue
+			}
+			if strings.HasPrefix(line, "created by ") {
+				g.CreatedBy = trimArgs(strings.TrimPrefix(line, "created by "))
+				continue
+			}
+			if g.TopFunction == "" && !isStdlibFrame(line) {
+				g.TopFunction = trimArgs(line)
+			}
+		}
+		if g.TopFunction == "" && len(lines) > 1 {
+			g.TopFunction = trimArgs(lines[1])
+		}
+
+		result = append(result, g)
+	}
+
+	return result
+}
+
+// stdlibPrefixes 栈顶查找用户函数时跳过的标准库前缀
+var stdlibPrefixes = []string{"runtime.", "sync.", "internal/", "time.", "syscall."}
+
+// isStdlibFrame 判断调用栈帧是否属于标准库的阻塞原语
+func isStdlibFrame(line string) bool {
+	for _, prefix := range stdlibPrefixes {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// trimArgs 去掉函数名后的参数列表和 "in goroutine N" 后缀
+func trimArgs(fn string) string {
+	if idx := strings.Index(fn, " in goroutine"); idx >= 0 {
+		fn = fn[:idx]
+	}
+	if idx := strings.LastIndex(fn, "("); idx > 0 && strings.HasSuffix(fn, ")") {
+		fn = fn[:idx]
+	}
+	return fn
+}
+
+// isIgnored 判断协程是否属于应忽略的类别
+func (o *options) isIgnored(g Goroutine) bool {
+	for _, fn := range ignoredFunctions {
+		if strings.Contains(g.TopFunction, fn) {
+			return true
+		}
+	}
+	for _, fn := range o.ignoredCreators {
+		if strings.Contains(g.CreatedBy, fn) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check 对比快照，返回在宽限期结束后仍然存活的新增goroutine
+func Check(before []Goroutine, grace time.Duration, opts ...Option) []Goroutine {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	known := make(map[int64]bool, len(before))
+	for _, g := range before {
+		known[g.ID] = true
+	}
+
+	deadline := time.Now().Add(grace)
+	for {
+		leaked := make([]Goroutine, 0)
+		for _, g := range Snapshot() {
+			if !known[g.ID] && !o.isIgnored(g) {
+				leaked = append(leaked, g)
+			}
+		}
+
+		if len(leaked) == 0 || time.Now().After(deadline) {
+			sort.Slice(leaked, func(i, j int) bool { return leaked[i].ID < leaked[j].ID })
+			return leaked
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// Report 一次泄漏检查的结果
+type Report struct {
+	Name     string        // 被检查的代码名称
+	Duration time.Duration // 运行耗时
+	Leaked   []Goroutine   // 泄漏的协程
+}
+
+// HasLeaks 是否存在泄漏
+func (r *Report) HasLeaks() bool {
+	return len(r.Leaked) > 0
+}
+
+// Print 输出报告，verbose 为 true 时附带完整调用栈
+func (r *Report) Print(w io.Writer, verbose bool) {
+	if !r.HasLeaks() {
+		fmt.Fprintf(w, "[%s] 未发现goroutine泄漏 (耗时 %v)\n", r.Name, r.Duration.Round(time.Millisecond))
+		return
+	}
+
+	fmt.Fprintf(w, "[%s] 发现 %d 个泄漏的goroutine (耗时 %v):\n", r.Name, len(r.Leaked), r.Duration.Round(time.Millisecond))
+	for _, g := range r.Leaked {
+		fmt.Fprintf(w, "  - %s\n", g)
+		if verbose {
+			for _, line := range strings.Split(g.Stack, "\n") {
+				fmt.Fprintf(w, "      %s\n", line)
+			}
+		}
+	}
+}
+
+// Run 运行一段代码并检查泄漏
+func Run(name string, fn func(), grace time.Duration, opts ...Option) *Report {
+	before := Snapshot()
+	start := time.Now()
+	fn()
+	duration := time.Since(start)
+
+	return &Report{
+		Name:     name,
+		Duration: duration,
+		Leaked:   Check(before, grace, opts...),
+	}
+}
+
+// Watchdog 看门狗：超时后转储所有goroutine的调用栈
+type Watchdog struct {
+	timer   *time.Timer
+	fired   bool
+	done    chan struct{} // 转储写完后关闭
+	stop    sync.Once
+	mutex   sync.Mutex
+	name    string
+	timeout time.Duration
+	output  io.Writer
+}
+
+// NewWatchdog 启动看门狗，超时后将调用栈写入 output（nil 时写入标准错误）
+func NewWatchdog(name string, timeout time.Duration, output io.Writer) *Watchdog {
+	if output == nil {
+		output = os.Stderr
+	}
+	w := &Watchdog{name: name, timeout: timeout, output: output, done: make(chan struct{})}
+	w.timer = time.AfterFunc(timeout, w.dumpAll)
+	return w
+}
+
+// dumpAll 超时回调：转储所有调用栈
+func (w *Watchdog) dumpAll() {
+	defer close(w.done)
+	w.mutex.Lock()
+	w.fired = true
+	w.mutex.Unlock()
+
+	goroutines := Snapshot()
+	fmt.Fprintf(w.output, "看门狗[%s]: 运行超过 %v，可能发生死锁或卡顿，当前共 %d 个goroutine:\n",
+		w.name, w.timeout, len(goroutines))
+	for _, g := range goroutines {
+		fmt.Fprintf(w.output, "\n%s\n", g.Stack)
+	}
+}
+
+// Stop 停止看门狗，返回是否已经超时触发；已经触发时等转储写完再返回，之后可以安全地读取 output。
+// 可以重复调用，只有第一次调用真正停止定时器
+func (w *Watchdog) Stop() bool {
+	w.stop.Do(func() {
+		// 只有第一次 timer.Stop 返回 false 才说明 dumpAll 已经开始运行；
+		// 重复调用时定时器已停，再等 done 会永远阻塞
+		if !w.timer.Stop() {
+			<-w.done
+		}
+	})
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.fired
+}
+
+// RunWithWatchdog 在看门狗保护下运行代码，返回是否超时
+func RunWithWatchdog(name string, timeout time.Duration, fn func()) bool {
+	w := NewWatchdog(name, timeout, nil)
+	defer w.Stop() // fn panic 时也要停掉看门狗
+	fn()
+	return w.Stop()
+}
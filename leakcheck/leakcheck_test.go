@@ -0,0 +1,64 @@
+package leakcheck
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// 未触发的看门狗重复 Stop 不能阻塞
+func TestWatchdogStopTwiceBeforeFire(t *testing.T) {
+	w := NewWatchdog("重复停止", time.Hour, &bytes.Buffer{})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if w.Stop() {
+			t.Error("第一次 Stop: 看门狗不应已触发")
+		}
+		if w.Stop() {
+			t.Error("第二次 Stop: 看门狗不应已触发")
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("第二次 Stop 阻塞")
+	}
+}
+
+// 已触发的看门狗重复 Stop 都返回 true，且转储已写完
+func TestWatchdogStopTwiceAfterFire(t *testing.T) {
+	var output bytes.Buffer
+	w := NewWatchdog("已触发", time.Millisecond, &output)
+	time.Sleep(20 * time.Millisecond)
+
+	if !w.Stop() || !w.Stop() {
+		t.Fatal("超时后 Stop 应返回 true")
+	}
+	if output.Len() == 0 {
+		t.Error("Stop 返回后转储应已写完")
+	}
+}
+
+// startResident 启动一个常驻协程，创建者为 leakcheck.startResident
+func startResident(stop chan struct{}) {
+	go func() { <-stop }()
+}
+
+// IgnoreCreator 只忽略指定创建者的协程
+func TestCheckIgnoreCreator(t *testing.T) {
+	stop := make(chan struct{})
+	defer close(stop)
+
+	before := Snapshot()
+	startResident(stop)
+
+	if leaked := Check(before, 50*time.Millisecond); len(leaked) != 1 {
+		t.Fatalf("未忽略时应发现 1 个泄漏，实际 %d", len(leaked))
+	}
+	if leaked := Check(before, 50*time.Millisecond, IgnoreCreator("leakcheck.startResident")); len(leaked) != 0 {
+		t.Fatalf("忽略创建者后不应有泄漏，实际 %v", leaked)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+/*
+goroutine泄漏检测演示
+
+用 leakcheck 检查漏桶、带超时的出队和TTL缓存清理在停止后是否留下后台协程，
+再用看门狗检测一个永远等不到信号的卡死场景。
+TTL缓存的定时清理挂在进程内共享的时间轮上，时间轮常驻到进程退出，需要通过 IgnoreCreator 忽略。
+*/
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/strive/scenario/cache_strategies"
+	"github.com/strive/scenario/concurrency"
+	"github.com/strive/scenario/leakcheck"
+	"github.com/strive/scenario/practical_applications"
+)
+
+// sharedTimingWheel 进程内共享时间轮的创建者，检查泄漏时忽略
+const sharedTimingWheel = "concurrency.SharedTimingWheel"
+
+// 场景示例：检查各组件的后台协程是否正确退出
+func LeakCheckDemo() {
+	fmt.Println("goroutine泄漏检测场景:")
+
+	// 1. 漏桶限流器：调用 Stop 后后台漏水协程正常退出
+	report := leakcheck.Run("漏桶限流器(已停止)", func() {
+		bucket := practical_applications.NewLeakyBucket(10, 5)
+		for i := 0; i < 3; i++ {
+			bucket.Allow()
+		}
+		bucket.Stop()
+	}, 200*time.Millisecond)
+	report.Print(os.Stdout, false)
+
+	// 2. 带超时的出队：在条件变量上限时等待，超时返回后不留下协程
+	queue := concurrency.NewBoundedQueue(2)
+	report = leakcheck.Run("超时出队", func() {
+		_, err := queue.DequeueWithTimeout(50 * time.Millisecond)
+		fmt.Printf("  出队结果: %v\n", err)
+	}, 200*time.Millisecond)
+	report.Print(os.Stdout, false)
+	queue.Close()
+
+	// 3. TTL缓存：调用 StopCleanup 后清理协程正常退出
+	report = leakcheck.Run("TTL缓存(已停止清理)", func() {
+		cache := cache_strategies.NewTTLCache(cache_strategies.TTLCacheOptions{
+			DefaultTTL:      time.Second,
+			CleanupInterval: 10 * time.Millisecond,
+		})
+		cache.Set("key", "value")
+		cache.StopCleanup()
+	}, 200*time.Millisecond, leakcheck.IgnoreCreator(sharedTimingWheel))
+	report.Print(os.Stdout, false)
+
+	// 4. 看门狗：等待一个永远不会关闭的通道，超时后转储调用栈
+	fmt.Println("\n看门狗检测卡死:")
+	var dump strings.Builder
+	blocked := make(chan struct{})
+	watchdog := leakcheck.NewWatchdog("等待信号", 100*time.Millisecond, &dump)
+	select {
+	case <-blocked:
+	case <-time.After(200 * time.Millisecond):
+	}
+	fired := watchdog.Stop()
+	fmt.Printf("  看门狗是否触发: %v\n", fired)
+
+	lines := strings.Split(dump.String(), "\n")
+	if len(lines) > 6 {
+		lines = append(lines[:6], "  ...")
+	}
+	for _, line := range lines {
+		fmt.Printf("  %s\n", line)
+	}
+}
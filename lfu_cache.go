@@ -11,12 +11,15 @@ LFU算法基于"访问频率"淘汰数据，核心思想是"如果数据过去
 2. 当多个数据访问次数相同时，淘汰最早进入缓存的数据(最少最近使用)
 3. 每次访问数据时，需要增加其访问计数
 4. 新插入数据的访问计数从1开始
+5. 可选的频率衰减（aging）：每经过固定次数的访问，所有访问计数减半，历史上很热、现在已经无人访问的数据会逐渐变得可以被淘汰
 
 实现方式：
 - 需要同时维护数据访问频率和访问时间顺序
 - 通常使用哈希表+多个双向链表的组合结构
 - 每个频率对应一个双向链表，存储相同频率的节点
 - 另一个哈希表记录每个节点的频率
+- 衰减时按频率从低到高把所有节点移到减半后的频率链表，频率相同的节点中原频率较低的排在淘汰端，代价为 O(n)，
+  每 decayPeriod 次访问才发生一次
 
 应用场景：
 - 内容分发网络(CDN)缓存
@@ -26,7 +29,8 @@ LFU算法基于"访问频率"淘汰数据，核心思想是"如果数据过去
 
 优缺点：
 - 优点：能够更好地识别热点数据，提高命中率
-- 缺点：实现复杂，需要额外维护频率计数，可能存在"缓存污染"问题（长时间未使用但历史频率高的数据难以被淘汰）
+- 缺点：实现复杂，需要额外维护频率计数，可能存在"缓存污染"问题（长时间未使用但历史频率高的数据难以被淘汰），
+  NewDecayingLFU 通过定期减半访问计数缓解这一问题，但衰减周期需要根据访问模式变化的快慢调整
 
 以下实现了一个基本的LFU缓存，支持Get和Put操作，容量有限。
 */
@@ -34,36 +38,105 @@ LFU算法基于"访问频率"淘汰数据，核心思想是"如果数据过去
 import (
 	"container/list"
 	"fmt"
+	"io"
+	"sort"
+
+	"github.com/strive/scenario/cache_strategies"
+	"github.com/strive/scenario/sizeof"
 )
 
-// LFUNode LFU缓存节点结构
-type LFUNode struct {
-	Key   string
-	Value interface{}
+// LFUEntry LFU缓存节点结构
+type LFUEntry[K comparable, V any] struct {
+	Key   K
+	Value V
 	Freq  int // 访问频率
 }
 
-// LFUCache LFU缓存结构
-type LFUCache struct {
-	capacity int                      // 最大容量
-	cache    map[string]*list.Element // 键 -> 链表节点
-	freqMap  map[int]*list.List       // 频率 -> 包含该频率节点的链表
-	minFreq  int                      // 当前最小频率
+// LFU 泛型LFU缓存结构
+type LFU[K comparable, V any] struct {
+	capacity int                           // 最大容量
+	cache    map[K]*list.Element           // 键 -> 链表节点
+	freqMap  map[int]*list.List            // 频率 -> 包含该频率节点的链表
+	minFreq  int                           // 当前最小频率
+	stats    cache_strategies.StatsCounter // 访问统计
+	onEvict  cache_strategies.EvictionListeners[K, V]
+
+	decayPeriod int // 每多少次访问衰减一次，0表示不衰减
+	accesses    int // 上次衰减以来的访问次数
+	decays      int // 衰减次数
 }
 
+// LFUNode 字符串键LFU缓存节点（兼容旧接口）
+type LFUNode = LFUEntry[string, interface{}]
+
+// LFUCache 字符串键、任意值的LFU缓存（兼容旧接口）
+type LFUCache = LFU[string, interface{}]
+
 // NewLFUCache 创建指定容量的LFU缓存
 func NewLFUCache(capacity int) *LFUCache {
-	return &LFUCache{
+	return NewLFU[string, interface{}](capacity)
+}
+
+// NewLFU 创建指定容量的泛型LFU缓存
+func NewLFU[K comparable, V any](capacity int) *LFU[K, V] {
+	return &LFU[K, V]{
 		capacity: capacity,
-		cache:    make(map[string]*list.Element),
+		cache:    make(map[K]*list.Element),
 		freqMap:  make(map[int]*list.List),
 		minFreq:  0,
 	}
 }
 
+// NewDecayingLFU 创建带频率衰减的泛型LFU缓存，每 decayPeriod 次访问（Get 和 Put）后所有访问计数减半
+func NewDecayingLFU[K comparable, V any](capacity, decayPeriod int) *LFU[K, V] {
+	c := NewLFU[K, V](capacity)
+	c.decayPeriod = max(decayPeriod, 0)
+	return c
+}
+
+// recordAccess 记录一次访问，达到衰减周期时执行衰减
+func (c *LFU[K, V]) recordAccess() {
+	if c.decayPeriod == 0 {
+		return
+	}
+	c.accesses++
+	if c.accesses >= c.decayPeriod {
+		c.Decay()
+	}
+}
+
+// Decay 把所有访问计数减半（最低为1），可以在 NewDecayingLFU 的自动衰减之外按时间窗口手动调用
+func (c *LFU[K, V]) Decay() {
+	freqs := make([]int, 0, len(c.freqMap))
+	for freq := range c.freqMap {
+		freqs = append(freqs, freq)
+	}
+	sort.Ints(freqs)
+
+	decayed := make(map[int]*list.List, len(freqs))
+	c.minFreq = 0
+	for _, freq := range freqs {
+		// 从低频到高频、每个链表从尾到头依次放到新链表头部：减半后频率相同时，原频率低的更先被淘汰
+		for e := c.freqMap[freq].Back(); e != nil; e = e.Prev() {
+			node := e.Value.(*LFUEntry[K, V])
+			node.Freq = max(node.Freq/2, 1)
+			if _, ok := decayed[node.Freq]; !ok {
+				decayed[node.Freq] = list.New()
+			}
+			c.cache[node.Key] = decayed[node.Freq].PushFront(node)
+			if c.minFreq == 0 || node.Freq < c.minFreq {
+				c.minFreq = node.Freq
+			}
+		}
+	}
+	c.freqMap = decayed
+	c.accesses = 0
+	c.decays++
+}
+
 // 增加节点频率并更新位置
-func (c *LFUCache) incrementFreq(element *list.Element) {
-	node := element.Value.(*LFUNode)
+func (c *LFU[K, V]) incrementFreq(element *list.Element) {
+	node := element.Value.(*LFUEntry[K, V])
 
 	// 从当前频率链表中删除
 	c.freqMap[node.Freq].Remove(element)
@@ -88,15 +161,19 @@ func (c *LFUCache) incrementFreq(element *list.Element) {
 	c.cache[node.Key] = newElement
 }
 
-// Get 获取键对应的值，不存在返回nil和false
-func (c *LFUCache) Get(key string) (interface{}, bool) {
+// Get 获取键对应的值，不存在返回零值和false
+func (c *LFU[K, V]) Get(key K) (V, bool) {
+	defer c.recordAccess()
 	element, exists := c.cache[key]
 	if !exists {
-		return nil, false
+		c.stats.RecordMiss()
+		var zero V
+		return zero, false
 	}
+	c.stats.RecordHit()
 
 	// 获取节点
-	node := element.Value.(*LFUNode)
+	node := element.Value.(*LFUEntry[K, V])
 
 	// 增加访问频率
 	c.incrementFreq(element)
@@ -105,7 +182,7 @@ func (c *LFUCache) Get(key string) (interface{}, bool) {
 }
 
 // Put 插入或更新键值对
-func (c *LFUCache) Put(key string, value interface{}) {
+func (c *LFU[K, V]) Put(key K, value V) {
 	// 如果容量为0，不做任何操作
 	if c.capacity == 0 {
 		return
@@ -113,13 +190,15 @@ func (c *LFUCache) Put(key string, value interface{}) {
 
 	// 如果键已存在，更新值并增加频率
 	if element, exists := c.cache[key]; exists {
-		node := element.Value.(*LFUNode)
+		node := element.Value.(*LFUEntry[K, V])
 		node.Value = value
 		c.incrementFreq(element)
+		c.recordAccess()
 		return
 	}
 
 	// 如果达到容量上限，删除访问频率最低的元素
+	var evicted *LFUEntry[K, V]
 	if len(c.cache) >= c.capacity {
 		// 获取最小频率链表
 		minFreqList := c.freqMap[c.minFreq]
@@ -128,8 +207,10 @@ func (c *LFUCache) Put(key string, value interface{}) {
 		if leastFreqNode != nil {
 			// 从链表中删除
 			minFreqList.Remove(leastFreqNode)
+			evicted = leastFreqNode.Value.(*LFUEntry[K, V])
 			// 从缓存中删除
-			delete(c.cache, leastFreqNode.Value.(*LFUNode).Key)
+			delete(c.cache, evicted.Key)
+			c.stats.RecordEvictions(1)
 		}
 	}
 
@@ -142,7 +223,7 @@ func (c *LFUCache) Put(key string, value interface{}) {
 	}
 
 	// 创建新节点
-	node := &LFUNode{
+	node := &LFUEntry[K, V]{
 		Key:   key,
 		Value: value,
 		Freq:  1,
@@ -153,6 +234,151 @@ func (c *LFUCache) Put(key string, value interface{}) {
 
 	// 更新缓存映射
 	c.cache[key] = element
+	c.recordAccess()
+
+	// 新节点插入后再通知，回调中可以安全地访问缓存
+	if evicted != nil {
+		c.onEvict.Notify(evicted.Key, evicted.Value, cache_strategies.EvictionCapacity)
+	}
+}
+
+// Remove 删除指定键
+func (c *LFU[K, V]) Remove(key K) bool {
+	element, exists := c.cache[key]
+	if !exists {
+		return false
+	}
+	node := element.Value.(*LFUEntry[K, V])
+	freqList := c.freqMap[node.Freq]
+	freqList.Remove(element)
+	delete(c.cache, key)
+
+	// 删除的是最小频率链表的最后一个节点时，重新计算最小频率，避免淘汰时找不到节点
+	if freqList.Len() == 0 {
+		delete(c.freqMap, node.Freq)
+		if node.Freq == c.minFreq {
+			c.minFreq = 0
+			for freq, l := range c.freqMap {
+				if l.Len() > 0 && (c.minFreq == 0 || freq < c.minFreq) {
+					c.minFreq = freq
+				}
+			}
+		}
+	}
+	return true
+}
+
+// Size 返回当前缓存中的元素数量
+func (c *LFU[K, V]) Size() int {
+	return len(c.cache)
+}
+
+// Capacity 返回最大容量
+func (c *LFU[K, V]) Capacity() int {
+	return c.capacity
+}
+
+// Resize 调整最大容量（至少为1），容量变小时依次淘汰访问频率最低的元素，返回淘汰的数量
+func (c *LFU[K, V]) Resize(capacity int) int {
+	if capacity < 1 {
+		capacity = 1
+	}
+	c.capacity = capacity
+
+	var evicted []*LFUEntry[K, V]
+	for len(c.cache) > capacity {
+		// 最小频率链表尾部是频率最低且最早加入的元素；Remove 会在链表清空后重新计算最小频率
+		entry := c.freqMap[c.minFreq].Back().Value.(*LFUEntry[K, V])
+		c.Remove(entry.Key)
+		evicted = append(evicted, entry)
+	}
+	c.stats.RecordEvictions(len(evicted))
+	for _, entry := range evicted {
+		c.onEvict.Notify(entry.Key, entry.Value, cache_strategies.EvictionResize)
+	}
+	return len(evicted)
+}
+
+// OnEvict 注册淘汰回调，条目因容量不足或 Resize 缩容被淘汰时调用；Remove、Clear 不会触发
+func (c *LFU[K, V]) OnEvict(callback cache_strategies.EvictionCallback[K, V]) {
+	c.onEvict.Add(callback)
+}
+
+// Keys 返回所有键（按频率从低到高）
+func (c *LFU[K, V]) Keys() []K {
+	freqs := make([]int, 0, len(c.freqMap))
+	for freq := range c.freqMap {
+		freqs = append(freqs, freq)
+	}
+	sort.Ints(freqs)
+
+	keys := make([]K, 0, len(c.cache))
+	for _, freq := range freqs {
+		for e := c.freqMap[freq].Back(); e != nil; e = e.Prev() {
+			keys = append(keys, e.Value.(*LFUEntry[K, V]).Key)
+		}
+	}
+	return keys
+}
+
+// Clear 清空缓存
+func (c *LFU[K, V]) Clear() {
+	c.cache = make(map[K]*list.Element)
+	c.freqMap = make(map[int]*list.List)
+	c.minFreq = 0
+}
+
+// Save 把缓存内容和访问频率写成快照，按频率从低到高、同频率内从早到晚排列
+func (c *LFU[K, V]) Save(w io.Writer) error {
+	entries := make([]cache_strategies.SnapshotEntry[K, V], 0, len(c.cache))
+	for _, key := range c.Keys() {
+		node := c.cache[key].Value.(*LFUEntry[K, V])
+		entries = append(entries, cache_strategies.SnapshotEntry[K, V]{Key: node.Key, Value: node.Value, Freq: node.Freq})
+	}
+	return cache_strategies.WriteSnapshot(w, "lfu", c.capacity, entries)
+}
+
+// Load 清空缓存并从快照恢复条目和访问频率，快照条目多于容量时丢弃频率最低的条目
+func (c *LFU[K, V]) Load(r io.Reader) error {
+	_, entries, err := cache_strategies.ReadSnapshot[K, V](r, "lfu")
+	if err != nil {
+		return err
+	}
+	c.Clear()
+	for _, entry := range entries[max(len(entries)-c.capacity, 0):] {
+		freq := max(entry.Freq, 1)
+		if _, ok := c.freqMap[freq]; !ok {
+			c.freqMap[freq] = list.New()
+		}
+		node := &LFUEntry[K, V]{Key: entry.Key, Value: entry.Value, Freq: freq}
+		c.cache[entry.Key] = c.freqMap[freq].PushFront(node)
+		if c.minFreq == 0 || freq < c.minFreq {
+			c.minFreq = freq
+		}
+	}
+	return nil
+}
+
+// CacheStats 返回命中、未命中、淘汰和过期次数的快照
+func (c *LFU[K, V]) CacheStats() cache_strategies.CacheStats {
+	return c.stats.Snapshot()
+}
+
+// Stats 返回访问统计和容量信息
+func (c *LFU[K, V]) Stats() map[string]interface{} {
+	stats := c.stats.Snapshot().Map()
+	stats["capacity"] = c.capacity
+	stats["size"] = len(c.cache)
+	if c.decayPeriod > 0 {
+		stats["decayPeriod"] = c.decayPeriod
+		stats["decays"] = c.decays
+	}
+	return stats
+}
+
+// MemoryUsage 返回深度估算的内存占用（字节）
+func (c *LFU[K, V]) MemoryUsage() int64 {
+	return sizeof.Of(c)
 }
 
 // 场景示例：在线商城商品缓存
@@ -202,6 +428,29 @@ func LFUCacheDemo() {
 
 	fmt.Println("\n=== 再次添加新商品后的缓存状态 ===")
 	printLFUStatus(cache)
+
+	// 缓存污染：昨天秒杀的商品积累了很高的访问计数，今天已经没人访问，却一直占着缓存
+	fmt.Println("\n=== 频率衰减：秒杀结束后热点转移 ===")
+	run := func(name string, lfu *LFU[string, string]) {
+		for _, item := range []string{"flash:1", "flash:2", "flash:3"} {
+			lfu.Put(item, "秒杀商品")
+			for i := 0; i < 200; i++ {
+				lfu.Get(item)
+			}
+		}
+		before := lfu.CacheStats()
+		for i := 0; i < 300; i++ {
+			item := fmt.Sprintf("product:%d", 2001+i%2)
+			if _, ok := lfu.Get(item); !ok {
+				lfu.Put(item, "今日热门")
+			}
+		}
+		after := lfu.CacheStats()
+		hits := after.Hits - before.Hits
+		fmt.Printf("%s: 今日热门商品命中 %d/300，缓存中: %v\n", name, hits, lfu.Keys())
+	}
+	run("LFU", NewLFU[string, string](3))
+	run("LFU (每100次访问衰减)", NewDecayingLFU[string, string](3, 100))
 }
 
 // 辅助函数：打印LFU缓存状态
@@ -0,0 +1,384 @@
+package lifecycle
+
+/*
+组件生命周期管理与优雅关闭
+
+原理：
+一个进程中往往有多个带后台协程的组件：缓存清理协程、限流器的漏水协程、容灾系统的心跳检测、协程池的工作协程等。
+每个组件都有自己的停止方式（StopCleanup、Stop、Shutdown、Close），如果没有统一的管理，
+进程退出时很容易遗漏某个组件，或者按错误的顺序关闭（例如先关闭了下游存储，上游仍在写入）。
+
+生命周期管理器让组件以"启动钩子 + 停止钩子"的形式注册，并可以声明依赖的其他组件：
+启动时先启动被依赖的组件，关闭时按相反顺序依次停止（依赖它的组件先停止，后启动的先停止），
+每个停止钩子都有超时限制，整个关闭过程还受调用方上下文的总期限约束，避免某个组件卡住导致整个进程无法退出。
+
+关键特点：
+1. 按依赖关系启动（没有依赖关系的组件保持注册顺序），按启动的逆序停止
+2. 依赖了未注册的组件或存在循环依赖时，启动前报错
+3. 启动中途失败时，自动停止已经启动的组件
+4. 每个停止钩子有独立超时，超时不阻塞后续组件的关闭；Shutdown(ctx) 的期限到达后不再调用剩余的停止钩子，直接标记为失败
+5. 支持监听系统信号（SIGINT、SIGTERM），收到信号后自动优雅关闭
+
+实现方式：
+- 钩子保存在切片中，启动前按依赖关系做拓扑排序（Kahn 算法，入度相同时按注册顺序），记录实际的启动顺序
+- 停止钩子在独立协程中执行，超时取钩子自己的超时与关闭期限中较早的一个
+- 使用 signal.NotifyContext 监听系统信号
+
+应用场景：
+- 服务进程的优雅退出
+- 演示程序、测试中统一清理后台协程
+- 需要按依赖关系关闭的多组件系统
+
+优缺点：
+- 优点：统一的关闭入口，关闭顺序明确，单个组件卡住不影响整体退出
+- 缺点：超时的停止钩子对应的协程仍可能在后台运行，只能记录错误
+
+以下实现了一个支持有序关闭、超时控制和信号处理的生命周期管理器。
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// 组件状态
+const (
+	StateRegistered = "registered" // 已注册
+	StateRunning    = "running"    // 运行中
+	StateStopped    = "stopped"    // 已停止
+	StateFailed     = "failed"     // 启动或停止失败
+)
+
+// HookFunc 启动/停止钩子函数
+type HookFunc func(ctx context.Context) error
+
+// Hook 组件的生命周期钩子
+type Hook struct {
+	Name        string        // 组件名称
+	Start       HookFunc      // 启动钩子（可为nil，适用于构造时已启动的组件）
+	Stop        HookFunc      // 停止钩子（可为nil）
+	StopTimeout time.Duration // 停止超时，0表示使用管理器的默认值
+	DependsOn   []string      // 依赖的组件名称：这些组件先于本组件启动、晚于本组件停止
+}
+
+// ManagerOptions 生命周期管理器配置选项
+type ManagerOptions struct {
+	StartTimeout time.Duration // 单个组件的启动超时
+	StopTimeout  time.Duration // 单个组件的默认停止超时
+	Signals      []os.Signal   // Run 时监听的系统信号
+}
+
+// DefaultManagerOptions 默认的生命周期管理器配置
+var DefaultManagerOptions = ManagerOptions{
+	StartTimeout: time.Second * 10,
+	StopTimeout:  time.Second * 5,
+	Signals:      []os.Signal{syscall.SIGINT, syscall.SIGTERM},
+}
+
+var (
+	// ErrAlreadyStarted 管理器已启动后不能再注册组件
+	ErrAlreadyStarted = errors.New("生命周期管理器已启动")
+	// ErrDependencyCycle 组件之间存在循环依赖
+	ErrDependencyCycle = errors.New("组件存在循环依赖")
+	// ErrShutdownDeadline 关闭期限已到，组件的停止钩子没有被调用
+	ErrShutdownDeadline = errors.New("关闭期限已到，未执行停止钩子")
+)
+
+// component 已注册的组件
+type component struct {
+	hook  Hook
+	state string
+	err   error
+}
+
+// Manager 生命周期管理器
+type Manager struct {
+	components []*component // 按注册顺序保存的组件
+	started    []*component // 已成功启动的组件，按启动顺序
+	running    bool         // 是否处于运行状态
+	stopped    bool         // 是否已经关闭
+	options    ManagerOptions
+	mutex      sync.Mutex
+}
+
+// NewManager 创建新的生命周期管理器
+func NewManager(options ...ManagerOptions) *Manager {
+	opts := DefaultManagerOptions
+	if len(options) > 0 {
+		opts = options[0]
+	}
+	if opts.StopTimeout <= 0 {
+		opts.StopTimeout = DefaultManagerOptions.StopTimeout
+	}
+
+	return &Manager{
+		components: make([]*component, 0),
+		options:    opts,
+	}
+}
+
+// Register 注册组件的生命周期钩子
+func (m *Manager) Register(hook Hook) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.running || m.stopped {
+		return ErrAlreadyStarted
+	}
+	for _, c := range m.components {
+		if c.hook.Name == hook.Name {
+			return fmt.Errorf("组件 %s 已注册", hook.Name)
+		}
+	}
+
+	m.components = append(m.components, &component{hook: hook, state: StateRegistered})
+	return nil
+}
+
+// RegisterStopper 注册只有停止方法的组件（构造时已启动后台协程的组件），dependsOn 为它依赖的组件
+func (m *Manager) RegisterStopper(name string, stop func(), dependsOn ...string) error {
+	return m.Register(Hook{Name: name, Stop: StopFunc(stop), DependsOn: dependsOn})
+}
+
+// orderLocked 按依赖关系对组件做拓扑排序，被依赖的组件在前，没有依赖关系的组件保持注册顺序；调用方需持有锁
+func (m *Manager) orderLocked() ([]*component, error) {
+	index := make(map[string]int, len(m.components))
+	for i, c := range m.components {
+		index[c.hook.Name] = i
+	}
+
+	indegree := make([]int, len(m.components))
+	dependents := make([][]int, len(m.components))
+	for i, c := range m.components {
+		for _, dep := range c.hook.DependsOn {
+			j, ok := index[dep]
+			if !ok {
+				return nil, fmt.Errorf("组件 %s 依赖的组件 %s 未注册", c.hook.Name, dep)
+			}
+			indegree[i]++
+			dependents[j] = append(dependents[j], i)
+		}
+	}
+
+	// 每次取注册顺序最靠前的、依赖都已排好的组件
+	order := make([]*component, 0, len(m.components))
+	placed := make([]bool, len(m.components))
+	for len(order) < len(m.components) {
+		next := -1
+		for i := range m.components {
+			if !placed[i] && indegree[i] == 0 {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			var cycle []string
+			for i, c := range m.components {
+				if !placed[i] {
+					cycle = append(cycle, c.hook.Name)
+				}
+			}
+			return nil, fmt.Errorf("%w: %v", ErrDependencyCycle, cycle)
+		}
+		placed[next] = true
+		order = append(order, m.components[next])
+		for _, d := range dependents[next] {
+			indegree[d]--
+		}
+	}
+	return order, nil
+}
+
+// StopFunc 将无参数的停止方法包装为停止钩子，超时后不再等待
+func StopFunc(stop func()) HookFunc {
+	return func(ctx context.Context) error {
+		done := make(chan struct{})
+		go func() {
+			stop()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Start 按依赖关系依次启动所有组件，任一组件启动失败时停止已启动的组件
+func (m *Manager) Start(ctx context.Context) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.running || m.stopped {
+		return ErrAlreadyStarted
+	}
+	order, err := m.orderLocked()
+	if err != nil {
+		return err
+	}
+	m.running = true
+
+	for _, c := range order {
+		if c.hook.Start != nil {
+			startCtx, cancel := ctx, context.CancelFunc(func() {})
+			if m.options.StartTimeout > 0 {
+				startCtx, cancel = context.WithTimeout(ctx, m.options.StartTimeout)
+			}
+			err := c.hook.Start(startCtx)
+			cancel()
+
+			if err != nil {
+				c.state, c.err = StateFailed, err
+				m.stopLocked(context.Background())
+				return fmt.Errorf("启动组件 %s 失败: %w", c.hook.Name, err)
+			}
+		}
+		c.state = StateRunning
+		m.started = append(m.started, c)
+	}
+
+	return nil
+}
+
+// Stop 按启动的逆序停止所有已启动的组件，返回所有停止错误的合并；只受每个组件各自的停止超时限制
+func (m *Manager) Stop() error {
+	return m.Shutdown(context.Background())
+}
+
+// Shutdown 按启动的逆序停止所有已启动的组件，整个过程不超过 ctx 的期限：
+// 期限到达后剩余组件的停止钩子不再调用，状态标记为失败。返回所有停止错误的合并
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	// 没有调用 Start 的组件（构造时已启动）也需要停止，依赖关系有误时退回到注册顺序
+	var orderErr error
+	if !m.running && !m.stopped {
+		order, err := m.orderLocked()
+		if err != nil {
+			order, orderErr = m.components, err
+		}
+		for _, c := range order {
+			c.state = StateRunning
+		}
+		m.started = order
+		m.running = true
+	}
+
+	return errors.Join(orderErr, m.stopLocked(ctx))
+}
+
+// stopLocked 逆序停止已启动的组件，调用方需持有锁
+func (m *Manager) stopLocked(ctx context.Context) error {
+	if m.stopped {
+		return nil
+	}
+	m.stopped = true
+	m.running = false
+
+	var errs []error
+	for i := len(m.started) - 1; i >= 0; i-- {
+		c := m.started[i]
+		if c.hook.Stop == nil {
+			c.state = StateStopped
+			continue
+		}
+		if ctx.Err() != nil {
+			c.state, c.err = StateFailed, ErrShutdownDeadline
+			errs = append(errs, fmt.Errorf("停止组件 %s 失败: %w", c.hook.Name, ErrShutdownDeadline))
+			continue
+		}
+
+		timeout := c.hook.StopTimeout
+		if timeout <= 0 {
+			timeout = m.options.StopTimeout
+		}
+		stopCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := c.hook.Stop(stopCtx)
+		cancel()
+
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				if ctx.Err() != nil {
+					err = fmt.Errorf("关闭期限已到")
+				} else {
+					err = fmt.Errorf("停止超时(%v)", timeout)
+				}
+			}
+			c.state, c.err = StateFailed, err
+			errs = append(errs, fmt.Errorf("停止组件 %s 失败: %w", c.hook.Name, err))
+			continue
+		}
+		c.state = StateStopped
+	}
+
+	return errors.Join(errs...)
+}
+
+// Run 启动所有组件，阻塞直到收到系统信号或 ctx 结束，然后优雅关闭
+func (m *Manager) Run(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, m.options.Signals...)
+	defer stop()
+
+	if err := m.Start(ctx); err != nil {
+		return err
+	}
+
+	<-ctx.Done()
+	return m.Stop()
+}
+
+// States 返回每个组件的当前状态
+func (m *Manager) States() map[string]string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	result := make(map[string]string, len(m.components))
+	for _, c := range m.components {
+		result[c.hook.Name] = c.state
+	}
+	return result
+}
+
+// StartOrder 返回已启动组件的名称，按启动顺序；关闭时按相反顺序停止
+func (m *Manager) StartOrder() []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	names := make([]string, len(m.started))
+	for i, c := range m.started {
+		names[i] = c.hook.Name
+	}
+	return names
+}
+
+// Stats 返回管理器统计信息
+func (m *Manager) Stats() map[string]interface{} {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	failed := 0
+	for _, c := range m.components {
+		if c.state == StateFailed {
+			failed++
+		}
+	}
+
+	return map[string]interface{}{
+		"components":  len(m.components),
+		"started":     len(m.started),
+		"running":     m.running,
+		"stopped":     m.stopped,
+		"failed":      failed,
+		"stopTimeout": m.options.StopTimeout,
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+/*
+组件生命周期管理演示
+
+原理：
+缓存清理协程、漏桶的漏水协程、容灾系统的心跳检测、协程池的工作协程都注册到同一个 lifecycle.Manager，
+由它按依赖关系排出启动顺序，退出时按相反顺序依次停止，每个停止钩子都有超时，
+最后用 leakcheck 确认所有后台协程都已退出。
+
+以下演示了依赖排序、停止超时、关闭期限和循环依赖检测。
+*/
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/strive/scenario/cache_strategies"
+	"github.com/strive/scenario/concurrency"
+	"github.com/strive/scenario/leakcheck"
+	"github.com/strive/scenario/lifecycle"
+	"github.com/strive/scenario/practical_applications"
+)
+
+// 场景示例：服务进程退出时按依赖关系统一关闭所有后台组件
+func LifecycleDemo() {
+	fmt.Println("生命周期管理场景（按依赖关系优雅关闭所有后台组件）:")
+
+	before := leakcheck.Snapshot()
+
+	manager := lifecycle.NewManager(lifecycle.ManagerOptions{
+		StartTimeout: time.Second,
+		StopTimeout:  200 * time.Millisecond,
+		Signals:      lifecycle.DefaultManagerOptions.Signals,
+	})
+
+	// 构造时即启动后台协程的组件，只需注册停止钩子；注册顺序与依赖关系无关，管理器会排好
+	// 漏桶是请求入口，处理完的请求交给流水线，必须最先停止
+	bucket := practical_applications.NewLeakyBucket(10, 5)
+	manager.RegisterStopper("漏桶漏水协程", bucket.Stop, "处理流水线")
+
+	// 协程池中的任务会写入跳表存储和TTL缓存，要在它们之前停止
+	pool := concurrency.NewGoroutinePool(4, 16)
+	manager.RegisterStopper("协程池", pool.Shutdown, "跳表存储TTL清理", "TTL缓存清理")
+
+	store := practical_applications.NewSkiplistKVStore()
+	manager.RegisterStopper("跳表存储TTL清理", store.Close)
+
+	cache := cache_strategies.NewTTLCache(cache_strategies.TTLCacheOptions{
+		DefaultTTL:      time.Second,
+		CleanupInterval: 50 * time.Millisecond,
+	})
+	manager.RegisterStopper("TTL缓存清理", cache.StopCleanup)
+
+	drs := practical_applications.NewDisasterRecoverySystem(practical_applications.ReplicationAsync, time.Second)
+	drs.AddDataCenter(practical_applications.NewDataCenter("dc-sh", "上海数据中心", "上海", true))
+	manager.RegisterStopper("容灾心跳检测", drs.Shutdown)
+
+	// 显式启动的组件：注册启动和停止钩子，流水线的结果交给协程池处理
+	pipeline := concurrency.NewPipeline(4, concurrency.Stage{
+		Name: "处理", Workers: 2,
+		Fn: func(tc *concurrency.TaskContext, item interface{}) (interface{}, error) {
+			return item, nil
+		},
+	})
+	manager.Register(lifecycle.Hook{
+		Name:      "处理流水线",
+		DependsOn: []string{"协程池"},
+		Start: func(ctx context.Context) error {
+			pipeline.Start()
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			pipeline.Close()
+			for {
+				select {
+				case _, ok := <-pipeline.Results():
+					if !ok {
+						return nil
+					}
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		},
+	})
+
+	// 停止很慢的组件：超过停止超时后不再等待，继续关闭其他组件
+	manager.Register(lifecycle.Hook{
+		Name:        "慢速刷盘组件",
+		StopTimeout: 100 * time.Millisecond,
+		DependsOn:   []string{"跳表存储TTL清理"},
+		Stop: lifecycle.StopFunc(func() {
+			time.Sleep(300 * time.Millisecond)
+		}),
+	})
+
+	if err := manager.Start(context.Background()); err != nil {
+		fmt.Printf("启动失败: %v\n", err)
+		return
+	}
+	fmt.Printf("启动完成: %v\n", manager.Stats())
+	fmt.Printf("启动顺序: %v\n", manager.StartOrder())
+
+	// 模拟服务运行
+	cache.Set("session", "token")
+	bucket.Allow()
+	pool.Submit(func(context.Context) error { return nil })
+	pipeline.Submit(concurrency.NewTaskContext(context.Background()), "请求")
+	store.Set([]byte("key"), []byte("value"))
+	time.Sleep(100 * time.Millisecond)
+
+	// 模拟收到退出信号，整个关闭过程最多1秒
+	fmt.Println("\n收到退出信号，开始优雅关闭...")
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	err := manager.Shutdown(ctx)
+	cancel()
+	fmt.Printf("关闭耗时: %v\n", time.Since(start).Round(time.Millisecond))
+	if err != nil {
+		fmt.Printf("关闭过程中的错误: %v\n", err)
+	}
+
+	fmt.Println("\n组件状态（按停止顺序）:")
+	states := manager.States()
+	order := manager.StartOrder()
+	for i := len(order) - 1; i >= 0; i-- {
+		fmt.Printf("  %s: %s\n", order[i], states[order[i]])
+	}
+
+	// 关闭期限比所有停止钩子加起来还短时，期限到达后剩余的组件不再等待
+	fmt.Println("\n关闭期限只有150ms，三个刷盘组件各需要100ms:")
+	tight := lifecycle.NewManager()
+	for _, name := range []string{"刷盘-订单", "刷盘-日志", "刷盘-指标"} {
+		tight.Register(lifecycle.Hook{Name: name, Stop: lifecycle.StopFunc(func() { time.Sleep(100 * time.Millisecond) })})
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 150*time.Millisecond)
+	err = tight.Shutdown(ctx)
+	cancel()
+	fmt.Printf("  错误: %v\n", err)
+	fmt.Printf("  状态: %v\n", tight.States())
+
+	// 依赖关系有误时启动前报错
+	broken := lifecycle.NewManager()
+	broken.Register(lifecycle.Hook{Name: "A", DependsOn: []string{"B"}})
+	broken.Register(lifecycle.Hook{Name: "B", DependsOn: []string{"A"}})
+	fmt.Printf("\n循环依赖: %v\n", broken.Start(context.Background()))
+
+	// 确认所有后台协程都已退出
+	fmt.Println()
+	report := &leakcheck.Report{Name: "关闭后", Leaked: leakcheck.Check(before, time.Second, leakcheck.IgnoreCreator(sharedTimingWheel))}
+	report.Print(os.Stdout, false)
+}
@@ -15,6 +15,8 @@ LRU算法基于"最近最少使用"原则淘汰数据，核心思想是"如果
 - 采用哈希表+双向链表的组合结构
 - 哈希表提供O(1)时间复杂度的查找能力
 - 双向链表维护数据的访问顺序，支持O(1)删除和添加
+- NewWeightedLRU 按条目权重（如字节数）之和限制容量，超出时从链表尾部连续淘汰
+- Evict 从链表尾部淘汰指定数量的元素而不改变容量，供 PressureEvictor 在内存紧张时释放内存
 
 应用场景：
 - Web页面缓存
@@ -32,72 +34,300 @@ LRU算法基于"最近最少使用"原则淘汰数据，核心思想是"如果
 import (
 	"container/list"
 	"fmt"
+	"io"
+	"math"
+
+	"github.com/strive/scenario/cache_strategies"
+	"github.com/strive/scenario/sizeof"
 )
 
-// LRUNode 双向链表节点结构
-type LRUNode struct {
-	Key   string
-	Value interface{}
+// LRUEntry 双向链表节点结构
+type LRUEntry[K comparable, V any] struct {
+	Key    K
+	Value  V
+	weight int64 // 写入时计算的权重
 }
 
-// LRUCache LRU缓存结构
-type LRUCache struct {
-	capacity int                      // 最大容量
-	cache    map[string]*list.Element // 哈希表: 键 -> 链表节点指针
-	list     *list.List               // 双向链表: 维护访问顺序
+// LRU 泛型LRU缓存结构
+type LRU[K comparable, V any] struct {
+	capacity int                           // 最大容量
+	cache    map[K]*list.Element           // 哈希表: 键 -> 链表节点指针
+	list     *list.List                    // 双向链表: 维护访问顺序
+	stats    cache_strategies.StatsCounter // 访问统计
+	onEvict  cache_strategies.EvictionListeners[K, V]
+
+	maxWeight int64                          // 权重上限，0表示不限制
+	weight    int64                          // 当前总权重
+	weigher   cache_strategies.Weigher[K, V] // 权重函数
 }
 
+// LRUNode 字符串键LRU缓存节点（兼容旧接口）
+type LRUNode = LRUEntry[string, interface{}]
+
+// LRUCache 字符串键、任意值的LRU缓存（兼容旧接口）
+type LRUCache = LRU[string, interface{}]
+
 // NewLRUCache 创建指定容量的LRU缓存
 func NewLRUCache(capacity int) *LRUCache {
-	return &LRUCache{
+	return NewLRU[string, interface{}](capacity)
+}
+
+// NewLRU 创建指定容量的泛型LRU缓存
+func NewLRU[K comparable, V any](capacity int) *LRU[K, V] {
+	return &LRU[K, V]{
 		capacity: capacity,
-		cache:    make(map[string]*list.Element),
+		cache:    make(map[K]*list.Element),
 		list:     list.New(),
 	}
 }
 
-// Get 获取缓存中的值，不存在返回nil和false
-func (c *LRUCache) Get(key string) (interface{}, bool) {
+// NewWeightedLRU 创建按权重限制的泛型LRU缓存，所有条目的权重之和不超过 maxWeight，条目数不限；
+// weigher 为 nil 时按 sizeof 估算的内存占用计算权重
+func NewWeightedLRU[K comparable, V any](maxWeight int64, weigher cache_strategies.Weigher[K, V]) *LRU[K, V] {
+	if weigher == nil {
+		weigher = cache_strategies.SizeOfWeigher[K, V]()
+	}
+	c := NewLRU[K, V](math.MaxInt)
+	c.maxWeight = max(maxWeight, 1)
+	c.weigher = weigher
+	return c
+}
+
+// Get 获取缓存中的值，不存在返回零值和false
+func (c *LRU[K, V]) Get(key K) (V, bool) {
 	// 查找哈希表
 	if element, exists := c.cache[key]; exists {
 		// 找到节点，将其移动到链表头部（表示最近使用）
 		c.list.MoveToFront(element)
+		c.stats.RecordHit()
 		// 返回节点值
-		return element.Value.(*LRUNode).Value, true
+		return element.Value.(*LRUEntry[K, V]).Value, true
 	}
 	// 未找到
-	return nil, false
+	c.stats.RecordMiss()
+	var zero V
+	return zero, false
 }
 
 // Put 插入或更新缓存中的键值对
-func (c *LRUCache) Put(key string, value interface{}) {
+func (c *LRU[K, V]) Put(key K, value V) {
+	var weight int64
+	if c.maxWeight > 0 {
+		weight = c.weigher(key, value)
+		// 单个条目超过权重上限：不写入，视为写入后立即被淘汰，旧值已被覆盖，一并删除
+		if weight > c.maxWeight {
+			c.Remove(key)
+			c.stats.RecordEvictions(1)
+			c.onEvict.Notify(key, value, cache_strategies.EvictionCapacity)
+			return
+		}
+	}
+
+	var evicted []*LRUEntry[K, V]
 	// 如果键已存在，更新值并移动到链表头部
 	if element, exists := c.cache[key]; exists {
-		// 更新值
-		element.Value.(*LRUNode).Value = value
+		node := element.Value.(*LRUEntry[K, V])
+		// 更新值和权重
+		node.Value = value
+		c.weight += weight - node.weight
+		node.weight = weight
 		// 移动到链表头部
 		c.list.MoveToFront(element)
-		return
-	}
-
-	// 如果达到容量上限，删除最近最少使用的元素（链表尾部）
-	if c.list.Len() >= c.capacity {
-		// 获取链表尾部节点
-		leastUsed := c.list.Back()
-		if leastUsed != nil {
-			// 从链表中删除
-			c.list.Remove(leastUsed)
-			// 从哈希表中删除
-			delete(c.cache, leastUsed.Value.(*LRUNode).Key)
+	} else {
+		// 如果达到容量上限，删除最近最少使用的元素（链表尾部）
+		if c.list.Len() >= c.capacity {
+			if leastUsed := c.removeBack(); leastUsed != nil {
+				evicted = append(evicted, leastUsed)
+			}
+		}
+
+		// 创建新节点
+		node := &LRUEntry[K, V]{Key: key, Value: value, weight: weight}
+		// 插入链表头部
+		element := c.list.PushFront(node)
+		// 在哈希表中记录节点位置
+		c.cache[key] = element
+		c.weight += weight
+	}
+
+	// 总权重超过上限时继续从尾部淘汰，刚写入的节点在头部，不会被淘汰
+	for c.maxWeight > 0 && c.weight > c.maxWeight {
+		evicted = append(evicted, c.removeBack())
+	}
+	c.stats.RecordEvictions(len(evicted))
+
+	// 新节点插入后再通知，回调中可以安全地访问缓存
+	for _, node := range evicted {
+		c.onEvict.Notify(node.Key, node.Value, cache_strategies.EvictionCapacity)
+	}
+}
+
+// removeBack 删除链表尾部（最久未使用）的节点，缓存为空时返回 nil
+func (c *LRU[K, V]) removeBack() *LRUEntry[K, V] {
+	leastUsed := c.list.Back()
+	if leastUsed == nil {
+		return nil
+	}
+	// 从链表中删除
+	c.list.Remove(leastUsed)
+	node := leastUsed.Value.(*LRUEntry[K, V])
+	// 从哈希表中删除
+	delete(c.cache, node.Key)
+	c.weight -= node.weight
+	return node
+}
+
+// Remove 删除指定键
+func (c *LRU[K, V]) Remove(key K) bool {
+	if element, exists := c.cache[key]; exists {
+		c.list.Remove(element)
+		delete(c.cache, key)
+		c.weight -= element.Value.(*LRUEntry[K, V]).weight
+		return true
+	}
+	return false
+}
+
+// Victim 返回下一个将被淘汰的键（最久未使用），缓存为空时返回 false
+func (c *LRU[K, V]) Victim() (K, bool) {
+	if leastUsed := c.list.Back(); leastUsed != nil {
+		return leastUsed.Value.(*LRUEntry[K, V]).Key, true
+	}
+	var zero K
+	return zero, false
+}
+
+// Contains 判断键是否存在，不改变访问顺序
+func (c *LRU[K, V]) Contains(key K) bool {
+	_, exists := c.cache[key]
+	return exists
+}
+
+// Size 返回当前缓存中的元素数量
+func (c *LRU[K, V]) Size() int {
+	return c.list.Len()
+}
+
+// Capacity 返回最大容量
+func (c *LRU[K, V]) Capacity() int {
+	return c.capacity
+}
+
+// Resize 调整最大容量（至少为1），容量变小时从链表尾部淘汰最久未使用的元素，返回淘汰的数量
+func (c *LRU[K, V]) Resize(capacity int) int {
+	if capacity < 1 {
+		capacity = 1
+	}
+	c.capacity = capacity
+
+	var evicted []*LRUEntry[K, V]
+	for c.list.Len() > capacity {
+		evicted = append(evicted, c.removeBack())
+	}
+	c.stats.RecordEvictions(len(evicted))
+	for _, entry := range evicted {
+		c.onEvict.Notify(entry.Key, entry.Value, cache_strategies.EvictionResize)
+	}
+	return len(evicted)
+}
+
+// Evict 从链表尾部淘汰最多 n 个最久未使用的元素，容量保持不变，返回淘汰的数量
+func (c *LRU[K, V]) Evict(n int) int {
+	var evicted []*LRUEntry[K, V]
+	for ; n > 0 && c.list.Len() > 0; n-- {
+		evicted = append(evicted, c.removeBack())
+	}
+	c.stats.RecordEvictions(len(evicted))
+	for _, entry := range evicted {
+		c.onEvict.Notify(entry.Key, entry.Value, cache_strategies.EvictionMemoryPressure)
+	}
+	return len(evicted)
+}
+
+// OnEvict 注册淘汰回调，条目因容量不足、Resize 缩容或 Evict 被淘汰时调用；Remove、Clear 不会触发
+func (c *LRU[K, V]) OnEvict(callback cache_strategies.EvictionCallback[K, V]) {
+	c.onEvict.Add(callback)
+}
+
+// Keys 返回所有键（从最近使用到最久未使用）
+func (c *LRU[K, V]) Keys() []K {
+	keys := make([]K, 0, c.list.Len())
+	for e := c.list.Front(); e != nil; e = e.Next() {
+		keys = append(keys, e.Value.(*LRUEntry[K, V]).Key)
+	}
+	return keys
+}
+
+// Clear 清空缓存
+func (c *LRU[K, V]) Clear() {
+	c.cache = make(map[K]*list.Element)
+	c.list = list.New()
+	c.weight = 0
+}
+
+// Save 把缓存内容写成快照，从最久未使用到最近使用排列
+func (c *LRU[K, V]) Save(w io.Writer) error {
+	entries := make([]cache_strategies.SnapshotEntry[K, V], 0, c.list.Len())
+	for e := c.list.Back(); e != nil; e = e.Prev() {
+		node := e.Value.(*LRUEntry[K, V])
+		entries = append(entries, cache_strategies.SnapshotEntry[K, V]{Key: node.Key, Value: node.Value})
+	}
+	return cache_strategies.WriteSnapshot(w, "lru", c.capacity, entries)
+}
+
+// Load 清空缓存并从快照恢复访问顺序，快照超出容量或权重上限时丢弃最久未使用的条目
+func (c *LRU[K, V]) Load(r io.Reader) error {
+	_, entries, err := cache_strategies.ReadSnapshot[K, V](r, "lru")
+	if err != nil {
+		return err
+	}
+	c.Clear()
+	// 从最近使用的条目开始恢复
+	for i := len(entries) - 1; i >= 0 && c.list.Len() < c.capacity; i-- {
+		entry := entries[i]
+		var weight int64
+		if c.maxWeight > 0 {
+			weight = c.weigher(entry.Key, entry.Value)
+			if c.weight+weight > c.maxWeight {
+				break
+			}
 		}
+		c.cache[entry.Key] = c.list.PushBack(&LRUEntry[K, V]{Key: entry.Key, Value: entry.Value, weight: weight})
+		c.weight += weight
 	}
+	return nil
+}
+
+// Weight 返回当前总权重，没有设置权重上限时为0
+func (c *LRU[K, V]) Weight() int64 {
+	return c.weight
+}
 
-	// 创建新节点
-	node := &LRUNode{Key: key, Value: value}
-	// 插入链表头部
-	element := c.list.PushFront(node)
-	// 在哈希表中记录节点位置
-	c.cache[key] = element
+// MaxWeight 返回权重上限，0表示不限制
+func (c *LRU[K, V]) MaxWeight() int64 {
+	return c.maxWeight
+}
+
+// CacheStats 返回命中、未命中、淘汰和过期次数的快照
+func (c *LRU[K, V]) CacheStats() cache_strategies.CacheStats {
+	return c.stats.Snapshot()
+}
+
+// Stats 返回访问统计和容量信息
+func (c *LRU[K, V]) Stats() map[string]interface{} {
+	stats := c.stats.Snapshot().Map()
+	stats["capacity"] = c.capacity
+	stats["size"] = c.list.Len()
+	if c.maxWeight > 0 {
+		stats["weight"] = c.weight
+		stats["maxWeight"] = c.maxWeight
+	}
+	return stats
+}
+
+// MemoryUsage 返回深度估算的内存占用（字节）
+func (c *LRU[K, V]) MemoryUsage() int64 {
+	return sizeof.Of(c)
 }
 
 // 场景示例：网页浏览历史缓存
@@ -134,6 +364,18 @@ func LRUCacheDemo() {
 	} else {
 		fmt.Println("页面不在缓存中: https://example.com/page2 (已被淘汰)")
 	}
+
+	// 页面大小差异很大时按字节限制缓存：一个大页面会挤掉多个小页面
+	fmt.Println("\n按页面大小限制的LRU缓存 (上限=100KB):")
+	pages := NewWeightedLRU(100*1024, func(url string, html []byte) int64 { return int64(len(html)) })
+	pages.Put("/home", make([]byte, 30*1024))
+	pages.Put("/about", make([]byte, 20*1024))
+	pages.Put("/contact", make([]byte, 10*1024))
+	pages.Put("/news", make([]byte, 30*1024))
+	pages.Get("/home")
+	fmt.Printf("缓存 %v，共 %s\n", pages.Keys(), sizeof.FormatBytes(pages.Weight()))
+	pages.Put("/gallery", make([]byte, 45*1024)) // 依次淘汰最久未使用的 /about、/contact、/news
+	fmt.Printf("写入45KB的 /gallery 后: %v，共 %s\n", pages.Keys(), sizeof.FormatBytes(pages.Weight()))
 }
 
 // 辅助函数：打印缓存状态
@@ -2,11 +2,53 @@ package main
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/strive/scenario/cache_strategies"
+	"github.com/strive/scenario/cachesim"
+	"github.com/strive/scenario/hashing"
+	"github.com/strive/scenario/kvapi"
+	"github.com/strive/scenario/kvserver"
+	"github.com/strive/scenario/tracing"
 )
 
 func main() {
+	// 子命令模式：go run . cachesim -trace access.log
+	if len(os.Args) > 1 && os.Args[1] == "cachesim" {
+		if err := cachesim.RunCLI(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// 子命令模式：go run . kvapi -addr :8080
+	if len(os.Args) > 1 && os.Args[1] == "kvapi" {
+		if err := kvapi.RunCLI(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// 子命令模式：go run . kvserver -addr :6380
+	if len(os.Args) > 1 && os.Args[1] == "kvserver" {
+		if err := kvserver.RunCLI(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// 子命令模式：go run . hashing -sizes 8,64,1024
+	if len(os.Args) > 1 && os.Args[1] == "hashing" {
+		if err := hashing.RunCLI(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// 运行哈希表演示
 	HashMapDemo()
 
@@ -28,9 +70,17 @@ func runDemo() {
 	fmt.Println("9. LRU缓存演示 (自定义链表实现)")
 	fmt.Println("10. LFU缓存演示 (自定义链表实现)")
 	fmt.Println("11. TTL缓存演示 (自定义链表实现)")
+	fmt.Println("12. 算法对比报告 (缓存策略/路径算法/TopK)")
+	fmt.Println("13. 数据结构内存占用报告")
+	fmt.Println("14. 限流 + 缓存 + 多副本 API 故障演练")
+	fmt.Println("15. 线程安全LRU/LFU缓存演示 (互斥锁/分片)")
+	fmt.Println("16. 多协程轮转执行器 (自定义规则/策略/取消)")
+	fmt.Println("17. 组件生命周期管理 (依赖排序/超时/优雅关闭)")
+	fmt.Println("18. goroutine泄漏与卡死检测")
+	fmt.Println("19. 链路追踪 (Span父子关系/上下文传递)")
 
 	var choice int
-	fmt.Print("请输入选择 (1-11): ")
+	fmt.Print("请输入选择 (1-19): ")
 	fmt.Scan(&choice)
 
 	fmt.Println("\n--- 开始演示 ---")
@@ -57,6 +107,22 @@ func runDemo() {
 		CustomLFUCacheDemo()
 	case 11:
 		cache_strategies.TTLCacheDemo()
+	case 12:
+		ComparisonReportDemo()
+	case 13:
+		MemoryFootprintDemo()
+	case 14:
+		RateLimitedCachedAPIDemo()
+	case 15:
+		ConcurrentCacheDemo()
+	case 16:
+		RotationDemo()
+	case 17:
+		LifecycleDemo()
+	case 18:
+		LeakCheckDemo()
+	case 19:
+		tracing.TracingDemo()
 	default:
 		fmt.Println("无效选择，默认运行哈希表演示")
 		HashMapDemo()
@@ -0,0 +1,239 @@
+package practical_applications
+
+/*
+Aho-Corasick 多模式匹配 - 敏感词过滤
+
+原理：
+敏感词过滤需要在一段文本中同时查找成百上千个词。逐个词调用 strings.Contains 的复杂度为 O(词数 × 文本长度)，
+词库越大越慢。Aho-Corasick 自动机把所有词建成一棵前缀树，再为每个节点计算失配指针：
+当前字符无法继续匹配时，跳到"当前已匹配串的最长真后缀"对应的节点继续，而不是回到起点重新扫描。
+这样只需要从头到尾扫描一遍文本，就能找出所有词的所有出现位置。
+
+关键特点：
+1. 匹配复杂度为 O(文本长度 + 匹配数)，与词库大小无关
+2. 一次扫描找出所有词的所有出现位置，包括互相重叠的词（如"赌博"和"网络赌博"）
+3. 按字符（rune）匹配，适用于中文；匹配时忽略大小写
+4. 构建完成后只读，可以被多个协程并发使用
+
+实现方式：
+- 前缀树节点保存子节点、失配指针和以该节点结尾的词
+- 按广度优先顺序计算失配指针，并把失配节点的输出合并到当前节点
+- 替换时把所有匹配区间内的字符替换为掩码字符
+
+应用场景：
+- 内容审核中的敏感词过滤与打码
+- 日志中批量查找关键字
+- 入侵检测中的特征串匹配
+
+优缺点：
+- 优点：扫描速度快且稳定，词库可以很大
+- 缺点：构建后不支持增量修改，需要重建；内存占用与词库总字符数成正比
+
+以下实现了 Aho-Corasick 自动机，并与逐词查找进行对比。
+*/
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/strive/scenario/report"
+)
+
+// acNode Aho-Corasick 自动机节点
+type acNode struct {
+	children map[rune]int // 子节点下标
+	fail     int          // 失配指针
+	outputs  []int        // 以该节点结尾的词（包括经由失配指针可达的词）
+}
+
+// ACMatch 一次匹配
+type ACMatch struct {
+	Word  string // 匹配到的词
+	Start int    // 起始位置（按字符计）
+	End   int    // 结束位置（不含，按字符计）
+}
+
+// AhoCorasick 多模式匹配自动机
+type AhoCorasick struct {
+	nodes []acNode
+	words []string
+}
+
+// NewAhoCorasick 用词库构建自动机，空词会被忽略
+func NewAhoCorasick(words []string) *AhoCorasick {
+	ac := &AhoCorasick{nodes: []acNode{{children: make(map[rune]int)}}}
+	seen := make(map[string]bool)
+	for _, word := range words {
+		word = strings.ToLower(word)
+		if word == "" || seen[word] {
+			continue
+		}
+		seen[word] = true
+
+		current := 0
+		for _, r := range word {
+			next, ok := ac.nodes[current].children[r]
+			if !ok {
+				next = len(ac.nodes)
+				ac.nodes = append(ac.nodes, acNode{children: make(map[rune]int)})
+				ac.nodes[current].children[r] = next
+			}
+			current = next
+		}
+		ac.nodes[current].outputs = append(ac.nodes[current].outputs, len(ac.words))
+		ac.words = append(ac.words, word)
+	}
+	ac.buildFailLinks()
+	return ac
+}
+
+// buildFailLinks 按广度优先顺序计算失配指针
+func (ac *AhoCorasick) buildFailLinks() {
+	queue := make([]int, 0, len(ac.nodes))
+	for _, child := range ac.nodes[0].children {
+		queue = append(queue, child) // 第一层的失配指针指向根
+	}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for r, child := range ac.nodes[current].children {
+			// 沿父节点的失配链查找能接受字符 r 的节点
+			fail := ac.nodes[current].fail
+			for fail != 0 {
+				if _, ok := ac.nodes[fail].children[r]; ok {
+					break
+				}
+				fail = ac.nodes[fail].fail
+			}
+			if next, ok := ac.nodes[fail].children[r]; ok && next != child {
+				ac.nodes[child].fail = next
+			}
+			failNode := ac.nodes[child].fail
+			ac.nodes[child].outputs = append(ac.nodes[child].outputs, ac.nodes[failNode].outputs...)
+			queue = append(queue, child)
+		}
+	}
+}
+
+// step 从状态 state 读入字符 r 后的新状态
+func (ac *AhoCorasick) step(state int, r rune) int {
+	for {
+		if next, ok := ac.nodes[state].children[r]; ok {
+			return next
+		}
+		if state == 0 {
+			return 0
+		}
+		state = ac.nodes[state].fail
+	}
+}
+
+// FindAll 返回文本中所有词的所有出现位置，按结束位置排列
+func (ac *AhoCorasick) FindAll(text string) []ACMatch {
+	var matches []ACMatch
+	state, pos := 0, 0
+	for _, r := range text {
+		state = ac.step(state, unicode.ToLower(r))
+		pos++
+		for _, index := range ac.nodes[state].outputs {
+			word := ac.words[index]
+			length := len([]rune(word))
+			matches = append(matches, ACMatch{Word: word, Start: pos - length, End: pos})
+		}
+	}
+	return matches
+}
+
+// Contains 判断文本是否包含任意一个词
+func (ac *AhoCorasick) Contains(text string) bool {
+	state := 0
+	for _, r := range text {
+		state = ac.step(state, unicode.ToLower(r))
+		if len(ac.nodes[state].outputs) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Replace 把文本中所有匹配到的字符替换为 mask
+func (ac *AhoCorasick) Replace(text string, mask rune) string {
+	runes := []rune(text)
+	for _, match := range ac.FindAll(text) {
+		for i := match.Start; i < match.End; i++ {
+			runes[i] = mask
+		}
+	}
+	return string(runes)
+}
+
+// Len 返回词库中的词数
+func (ac *AhoCorasick) Len() int {
+	return len(ac.words)
+}
+
+// 场景示例：敏感词过滤，比较自动机与逐词查找
+func AhoCorasickDemo() {
+	fmt.Println("Aho-Corasick 敏感词过滤示例:")
+
+	words := []string{"赌博", "网络赌博", "博彩", "代开发票", "刷单", "VPN"}
+	ac := NewAhoCorasick(words)
+	text := "本店承接刷单业务，网络赌博请勿靠近，另有代开发票服务，翻墙vpn低价出售"
+	fmt.Printf("\n词库: %v\n原文: %s\n匹配:\n", words, text)
+	for _, match := range ac.FindAll(text) {
+		fmt.Printf("  [%d, %d) %s\n", match.Start, match.End, match.Word)
+	}
+	fmt.Printf("打码: %s\n", ac.Replace(text, '*'))
+
+	// 大词库下的性能对比
+	rng := rand.New(rand.NewSource(42))
+	alphabet := []rune("的一是在不了有和人这中大为上个国我以要他时来用们生到作地于出就分对成会可主发年动同工也能下过子说产种面而方后多定行学法所民得经")
+	seen := make(map[string]bool)
+	dict := make([]string, 0, 2000)
+	for len(dict) < 2000 {
+		var b strings.Builder
+		for i := 0; i < 3+rng.Intn(2); i++ {
+			b.WriteRune(alphabet[rng.Intn(len(alphabet))])
+		}
+		if word := b.String(); !seen[word] {
+			seen[word] = true
+			dict = append(dict, word)
+		}
+	}
+	var doc strings.Builder
+	for i := 0; i < 20000; i++ {
+		doc.WriteRune(alphabet[rng.Intn(len(alphabet))])
+	}
+	content := doc.String()
+
+	comparison := report.NewComparison(
+		"敏感词匹配对比",
+		fmt.Sprintf("词库 %d 个词，文本 %d 个字符", len(dict), 20000),
+		report.Metric{Name: "命中词数", Precision: 0},
+	)
+	comparison.Measure("逐词 strings.Contains", func() (map[string]float64, error) {
+		hits := 0
+		for _, word := range dict {
+			if strings.Contains(content, word) {
+				hits++
+			}
+		}
+		return map[string]float64{"命中词数": float64(hits)}, nil
+	})
+	var automaton *AhoCorasick
+	comparison.Measure("构建 Aho-Corasick", func() (map[string]float64, error) {
+		automaton = NewAhoCorasick(dict)
+		return nil, nil
+	})
+	comparison.Measure("Aho-Corasick 扫描", func() (map[string]float64, error) {
+		hit := make(map[string]bool)
+		for _, match := range automaton.FindAll(content) {
+			hit[match.Word] = true
+		}
+		return map[string]float64{"命中词数": float64(len(hit))}, nil
+	})
+	comparison.Write(os.Stdout, report.FormatMarkdown)
+}
@@ -44,6 +44,9 @@ import (
 	"math"
 	"sync"
 	"time"
+
+	"github.com/strive/scenario/hashing"
+	"github.com/strive/scenario/sizeof"
 )
 
 // BloomFilter 布隆过滤器结构
@@ -113,8 +116,23 @@ func defaultHashFuncGenerator(index uint) func(data []byte) uint {
 		val ^= (val >> 13) * uint64(index+1)
 		val ^= (val << 7) * uint64(index+1)
 
-		// 对数组大小取模得到位置
-		return uint(val%uint64(math.MaxUint32)) % uint(len(sum))
+		// 调用方再对位数组大小取模得到位置
+		return uint(val)
+	}
+}
+
+// SetHasher 使用 hashing 包中的哈希函数生成各位置，需在添加元素之前调用。
+// 采用双重哈希：第 i 个位置为 h1 + i*h2，h2 由 h1 再混合得到，只需一种哈希算法即可模拟 k 个哈希函数
+func (bf *BloomFilter) SetHasher(h hashing.Hasher) {
+	bf.mutex.Lock()
+	defer bf.mutex.Unlock()
+
+	bf.hashFuncGen = func(index uint) func(data []byte) uint {
+		return func(data []byte) uint {
+			h1 := h.Sum64(data)
+			h2 := (h1*0x9e3779b97f4a7c15 ^ h1>>29) | 1 // 保证为奇数，避免步长为0
+			return uint(h1 + uint64(index)*h2)
+		}
 	}
 }
 
@@ -224,6 +242,13 @@ func (bf *BloomFilter) Info() map[string]interface{} {
 	}
 }
 
+// MemoryUsage 返回深度估算的内存占用（字节）
+func (bf *BloomFilter) MemoryUsage() int64 {
+	bf.mutex.RLock()
+	defer bf.mutex.RUnlock()
+	return sizeof.Of(bf)
+}
+
 // 场景示例：网页爬虫URL去重
 func BloomFilterDemo() {
 	fmt.Println("布隆过滤器示例 - 网页爬虫URL去重:")
@@ -296,14 +321,25 @@ func BloomFilterDemo() {
 	fmt.Printf("假阳性数量: %d (%.4f%%)\n", falsePositives, float64(falsePositives)/float64(len(randomURLs))*100)
 	fmt.Printf("理论错误率: %.4f%%\n", filter.EstimatedFalsePositiveRate()*100)
 
-	// 内存占用对比
-	fmt.Println("\n内存占用对比:")
-	bloomSize := float64(filter.size) / 8 / 1024 // 位数组大小转换为KB
-	mapSize := float64(filter.Count()*40) / 1024 // 假设使用map, 每个URL平均40字节
+	// 内存占用对比（实测）
+	fmt.Println("\n内存占用对比（按设计容量100万个URL）:")
+	bloomSize := filter.MemoryUsage()
+	packedSize := int64(filter.size+7) / 8 // 位数组按位压缩存储时的大小
 
-	fmt.Printf("布隆过滤器占用内存: %.2f KB\n", bloomSize)
-	fmt.Printf("使用map存储占用预估: %.2f KB\n", mapSize)
-	fmt.Printf("内存节省: %.1f 倍\n", mapSize/bloomSize)
+	// 用10万个URL实测map的单个URL开销，再按设计容量折算
+	const sampleCount, designCount = 100000, 1000000
+	urlSet := make(map[string]bool, sampleCount)
+	for _, url := range generateRandomURLs(sampleCount) {
+		urlSet[url] = true
+	}
+	perURL := float64(sizeof.Of(urlSet)) / float64(len(urlSet))
+	mapSize := int64(perURL * designCount)
+
+	fmt.Printf("布隆过滤器实测占用: %s（位数组使用[]bool存储，每位占1字节）\n", sizeof.FormatBytes(bloomSize))
+	fmt.Printf("位数组按位压缩后的占用: %s\n", sizeof.FormatBytes(packedSize))
+	fmt.Printf("map[string]bool 实测每个URL平均 %.1f 字节，100万个URL约 %s\n", perURL, sizeof.FormatBytes(mapSize))
+	fmt.Printf("内存节省: %.1f 倍（当前实现），%.1f 倍（按位压缩）\n",
+		float64(mapSize)/float64(bloomSize), float64(mapSize)/float64(packedSize))
 }
 
 // 生成随机URL，用于测试假阳性率
@@ -40,6 +40,8 @@ import (
 	"sort"
 	"strconv"
 	"sync"
+
+	"github.com/strive/scenario/hashing"
 )
 
 // 常量定义
@@ -85,6 +87,11 @@ func (ch *ConsistentHash) SetHashFunc(fn HashFunc) {
 	ch.rebuild()
 }
 
+// SetHasher 使用 hashing 包中的哈希函数（折叠为32位）重建哈希环
+func (ch *ConsistentHash) SetHasher(h hashing.Hasher) {
+	ch.SetHashFunc(hashing.Func32(h))
+}
+
 // AddNode 添加新节点
 func (ch *ConsistentHash) AddNode(node string) bool {
 	ch.mutex.Lock()
@@ -150,13 +157,13 @@ func (ch *ConsistentHash) RemoveNode(node string) bool {
 
 // GetNode 获取键对应的节点
 func (ch *ConsistentHash) GetNode(key string) (string, bool) {
+	ch.mutex.RLock()
+	defer ch.mutex.RUnlock()
+
 	if len(ch.nodes) == 0 {
 		return "", false
 	}
 
-	ch.mutex.RLock()
-	defer ch.mutex.RUnlock()
-
 	hash := ch.hashKey(key)
 
 	// 二分查找最接近的节点
@@ -0,0 +1,477 @@
+package practical_applications
+
+/*
+内容审核流水线
+
+原理：
+用户发布的内容在展示之前要经过一系列检查：发帖是否过于频繁、是否重复发布、是否包含违禁词。
+这些检查彼此独立、耗时不同，适合组织成多阶段流水线并发处理；
+每篇内容的审核状态则由状态机管理，只允许合法的状态流转，避免并发处理或人工操作把状态改乱：
+  待审核 --通过--> 已通过 --举报下架--> 已拒绝
+  待审核 --拒绝--> 已拒绝 --申诉--> 待审核
+
+关键特点：
+1. 限流：按作者的令牌桶限流，刷屏的作者超出部分直接拒绝
+2. 去重：布隆过滤器判断规范化后的正文是否已经发布过，空间占用固定
+3. 违禁词：Aho-Corasick 自动机一次扫描找出所有违禁词，严重违禁词拒绝，一般敏感词打码后通过
+4. 状态机：所有状态变化都经过转移表校验，并记录流转历史
+5. 指标：按阶段、按拒绝原因统计数量和审核耗时
+
+实现方式：
+- 复用并发包中的多阶段流水线，每个阶段独立配置并发度，阶段返回错误即表示在该阶段被拒绝
+- 结果由单独的协程消费，驱动状态机并更新指标
+- 去重的"检查并加入"在互斥锁内完成，避免两篇相同内容同时通过
+
+应用场景：
+- 社区、评论区的先审后发
+- 直播弹幕、私信的实时过滤
+- 商品信息发布前的合规检查
+
+优缺点：
+- 优点：阶段解耦、并发处理，每个环节都可以单独替换或调优
+- 缺点：布隆过滤器存在误判，少量新内容会被当作重复；违禁词匹配只看字面，容易被变形绕过
+
+以下实现了一个串联限流、去重、违禁词过滤和状态机的内容审核流水线。
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/strive/scenario/concurrency"
+)
+
+// PostStatus 内容审核状态
+type PostStatus string
+
+// 审核状态
+const (
+	PostPending  PostStatus = "待审核"
+	PostApproved PostStatus = "已通过"
+	PostRejected PostStatus = "已拒绝"
+)
+
+// PostEvent 触发状态流转的事件
+type PostEvent string
+
+// 审核事件
+const (
+	EventApprove  PostEvent = "通过"
+	EventReject   PostEvent = "拒绝"
+	EventTakeDown PostEvent = "举报下架"
+	EventAppeal   PostEvent = "申诉"
+)
+
+// postTransitions 状态转移表：当前状态 -> 事件 -> 新状态
+var postTransitions = map[PostStatus]map[PostEvent]PostStatus{
+	PostPending:  {EventApprove: PostApproved, EventReject: PostRejected},
+	PostApproved: {EventTakeDown: PostRejected},
+	PostRejected: {EventAppeal: PostPending},
+}
+
+// ErrInvalidTransition 状态转移不合法
+var ErrInvalidTransition = errors.New("非法的状态转移")
+
+// 审核拒绝原因
+var (
+	ErrPostRateLimited = errors.New("发帖过于频繁")
+	ErrPostDuplicate   = errors.New("重复内容")
+	ErrPostBlocked     = errors.New("包含违禁词")
+)
+
+// PostTransition 一次状态流转记录
+type PostTransition struct {
+	From   PostStatus
+	Event  PostEvent
+	To     PostStatus
+	Reason string
+	At     time.Time
+}
+
+// PostStateMachine 内容审核状态机
+type PostStateMachine struct {
+	mu      sync.Mutex
+	state   PostStatus
+	history []PostTransition
+}
+
+// NewPostStateMachine 创建处于待审核状态的状态机
+func NewPostStateMachine() *PostStateMachine {
+	return &PostStateMachine{state: PostPending}
+}
+
+// Fire 触发事件，事件在当前状态下不合法时返回 ErrInvalidTransition
+func (m *PostStateMachine) Fire(event PostEvent, reason string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	next, ok := postTransitions[m.state][event]
+	if !ok {
+		return fmt.Errorf("%w: %s 状态下不能%s", ErrInvalidTransition, m.state, event)
+	}
+	m.history = append(m.history, PostTransition{From: m.state, Event: event, To: next, Reason: reason, At: time.Now()})
+	m.state = next
+	return nil
+}
+
+// State 返回当前状态
+func (m *PostStateMachine) State() PostStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state
+}
+
+// History 返回状态流转历史
+func (m *PostStateMachine) History() []PostTransition {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]PostTransition(nil), m.history...)
+}
+
+// ModerationPost 待审核的内容
+type ModerationPost struct {
+	ID       int
+	Author   string
+	Content  string
+	Masked   string    // 敏感词打码后的正文，审核通过后用于展示
+	Matches  []ACMatch // 命中的违禁词和敏感词
+	Machine  *PostStateMachine
+	Reason   string    // 被拒绝的原因
+	Stage    string    // 被拒绝的阶段
+	Received time.Time // 提交时间
+}
+
+// ModerationOptions 内容审核选项
+type ModerationOptions struct {
+	BlockedWords    []string                   // 严重违禁词，命中即拒绝
+	SensitiveWords  []string                   // 一般敏感词，打码后通过
+	PostsPerSecond  int64                      // 每个作者每秒允许发布的内容数
+	Burst           int64                      // 每个作者允许的突发发布数
+	ExpectedPosts   uint                       // 去重布隆过滤器的设计容量
+	FalsePositive   float64                    // 去重布隆过滤器的误判率
+	FilterWorkers   int                        // 违禁词过滤阶段的协程数
+	BufferSize      int                        // 流水线每个阶段的队列容量
+	OnDecision      func(post *ModerationPost) // 每篇内容审核完成后的回调
+	SlowFilterDelay time.Duration              // 模拟违禁词过滤阶段的额外耗时（如调用外部模型）
+}
+
+// DefaultModerationOptions 默认的内容审核选项
+var DefaultModerationOptions = ModerationOptions{
+	PostsPerSecond: 1,
+	Burst:          3,
+	ExpectedPosts:  100000,
+	FalsePositive:  0.001,
+	FilterWorkers:  4,
+	BufferSize:     64,
+}
+
+// ContentModerator 内容审核流水线
+type ContentModerator struct {
+	options  ModerationOptions
+	filter   *AhoCorasick
+	blocked  map[string]bool // 严重违禁词集合（小写）
+	limiter  *KeyedRateLimiter
+	dedup    *BloomFilter
+	dedupMu  sync.Mutex
+	pipeline *concurrency.Pipeline
+	done     chan struct{}
+
+	mu        sync.Mutex
+	submitted int
+	status    map[PostStatus]int
+	reasons   map[string]int
+	masked    int
+	latencies []time.Duration
+}
+
+// NewContentModerator 创建并启动内容审核流水线
+func NewContentModerator(options ModerationOptions) *ContentModerator {
+	if options.PostsPerSecond <= 0 {
+		options.PostsPerSecond = DefaultModerationOptions.PostsPerSecond
+	}
+	if options.Burst <= 0 {
+		options.Burst = DefaultModerationOptions.Burst
+	}
+	if options.ExpectedPosts == 0 {
+		options.ExpectedPosts = DefaultModerationOptions.ExpectedPosts
+	}
+	if options.FalsePositive <= 0 {
+		options.FalsePositive = DefaultModerationOptions.FalsePositive
+	}
+	if options.FilterWorkers <= 0 {
+		options.FilterWorkers = DefaultModerationOptions.FilterWorkers
+	}
+	if options.BufferSize <= 0 {
+		options.BufferSize = DefaultModerationOptions.BufferSize
+	}
+
+	blocked := make(map[string]bool, len(options.BlockedWords))
+	for _, word := range options.BlockedWords {
+		blocked[strings.ToLower(word)] = true
+	}
+	m := &ContentModerator{
+		options: options,
+		filter:  NewAhoCorasick(append(append([]string(nil), options.BlockedWords...), options.SensitiveWords...)),
+		blocked: blocked,
+		limiter: NewKeyedRateLimiter(options.PostsPerSecond, options.Burst),
+		dedup:   NewBloomFilterWithParams(options.ExpectedPosts, options.FalsePositive),
+		done:    make(chan struct{}),
+		status:  make(map[PostStatus]int),
+		reasons: make(map[string]int),
+	}
+
+	m.pipeline = concurrency.NewPipeline(options.BufferSize,
+		concurrency.Stage{Name: "限流", Workers: 1, Fn: m.rateLimitStage},
+		concurrency.Stage{Name: "去重", Workers: 2, Fn: m.dedupStage},
+		concurrency.Stage{Name: "违禁词", Workers: options.FilterWorkers, Fn: m.filterStage},
+	)
+	m.pipeline.Start()
+	go m.collect()
+	return m
+}
+
+// rateLimitStage 按作者限流
+func (m *ContentModerator) rateLimitStage(tc *concurrency.TaskContext, item interface{}) (interface{}, error) {
+	post := item.(*ModerationPost)
+	if !m.limiter.Allow(post.Author) {
+		return nil, ErrPostRateLimited
+	}
+	return post, nil
+}
+
+// normalizeContent 去掉空白和标点并转为小写，使仅有格式差异的内容被视为重复
+func normalizeContent(content string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(content) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// dedupStage 用布隆过滤器拒绝重复发布的内容
+func (m *ContentModerator) dedupStage(tc *concurrency.TaskContext, item interface{}) (interface{}, error) {
+	post := item.(*ModerationPost)
+	key := normalizeContent(post.Content)
+
+	m.dedupMu.Lock()
+	defer m.dedupMu.Unlock()
+	if m.dedup.ContainsString(key) {
+		return nil, ErrPostDuplicate
+	}
+	m.dedup.AddString(key)
+	return post, nil
+}
+
+// filterStage 扫描违禁词：命中严重违禁词拒绝，一般敏感词打码
+func (m *ContentModerator) filterStage(tc *concurrency.TaskContext, item interface{}) (interface{}, error) {
+	post := item.(*ModerationPost)
+	if m.options.SlowFilterDelay > 0 {
+		select {
+		case <-time.After(m.options.SlowFilterDelay):
+		case <-tc.Done():
+			return nil, tc.Err()
+		}
+	}
+
+	post.Matches = m.filter.FindAll(post.Content)
+	for _, match := range post.Matches {
+		if m.blocked[match.Word] {
+			return nil, fmt.Errorf("%w: %s", ErrPostBlocked, match.Word)
+		}
+	}
+	post.Masked = post.Content
+	if len(post.Matches) > 0 {
+		post.Masked = m.filter.Replace(post.Content, '*')
+	}
+	return post, nil
+}
+
+// collect 消费流水线结果，驱动状态机并更新指标
+func (m *ContentModerator) collect() {
+	defer close(m.done)
+
+	for result := range m.pipeline.Results() {
+		post := result.Item.(*ModerationPost)
+		if result.Err != nil {
+			post.Reason, post.Stage = result.Err.Error(), result.Stage
+			post.Machine.Fire(EventReject, post.Reason)
+		} else {
+			post.Machine.Fire(EventApprove, "")
+		}
+
+		m.mu.Lock()
+		m.status[post.Machine.State()]++
+		if result.Err != nil {
+			reason := result.Err
+			if unwrapped := errors.Unwrap(reason); unwrapped != nil {
+				reason = unwrapped
+			}
+			m.reasons[reason.Error()]++
+		} else if len(post.Matches) > 0 {
+			m.masked++
+		}
+		m.latencies = append(m.latencies, time.Since(post.Received))
+		m.mu.Unlock()
+
+		if m.options.OnDecision != nil {
+			m.options.OnDecision(post)
+		}
+	}
+}
+
+// Submit 提交内容进入审核，返回的内容处于待审核状态，审核结果通过 OnDecision 回调通知
+func (m *ContentModerator) Submit(id int, author, content string) (*ModerationPost, error) {
+	post := &ModerationPost{
+		ID:       id,
+		Author:   author,
+		Content:  content,
+		Machine:  NewPostStateMachine(),
+		Received: time.Now(),
+	}
+	tc := concurrency.NewTaskContext(context.Background())
+	tc.SetMetadata("post", strconv.Itoa(id))
+	if err := m.pipeline.Submit(tc, post); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	m.submitted++
+	m.mu.Unlock()
+	return post, nil
+}
+
+// Close 停止接收新内容，等待已提交的内容全部审核完毕
+func (m *ContentModerator) Close() {
+	m.pipeline.Close()
+	<-m.done
+}
+
+// Stats 返回审核指标
+func (m *ContentModerator) Stats() map[string]interface{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status := make(map[string]int, len(m.status))
+	for s, count := range m.status {
+		status[string(s)] = count
+	}
+	reasons := make(map[string]int, len(m.reasons))
+	for reason, count := range m.reasons {
+		reasons[reason] = count
+	}
+
+	var avg, p99 time.Duration
+	if len(m.latencies) > 0 {
+		sorted := append([]time.Duration(nil), m.latencies...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		var total time.Duration
+		for _, latency := range sorted {
+			total += latency
+		}
+		avg = total / time.Duration(len(sorted))
+		p99 = sorted[(len(sorted)*99-1)/100]
+	}
+
+	return map[string]interface{}{
+		"submitted":  m.submitted,
+		"status":     status,
+		"reasons":    reasons,
+		"masked":     m.masked,
+		"avgLatency": avg,
+		"p99Latency": p99,
+		"stages":     m.pipeline.Stats(),
+	}
+}
+
+// 场景示例：社区发帖的先审后发
+func ContentModerationDemo() {
+	fmt.Println("内容审核流水线示例:")
+
+	var mu sync.Mutex
+	decisions := make([]*ModerationPost, 0)
+	options := DefaultModerationOptions
+	options.BlockedWords = []string{"网络赌博", "代开发票", "博彩"}
+	options.SensitiveWords = []string{"傻瓜", "vpn"}
+	options.SlowFilterDelay = 5 * time.Millisecond
+	options.OnDecision = func(post *ModerationPost) {
+		mu.Lock()
+		decisions = append(decisions, post)
+		mu.Unlock()
+	}
+	moderator := NewContentModerator(options)
+
+	posts := []struct {
+		author, content string
+	}{
+		{"小王", "今天的晚霞真好看"},
+		{"小李", "周末一起去爬山吗？"},
+		{"广告号", "专业代开发票，价格优惠"},
+		{"小张", "这个傻瓜相机拍得还不错"},
+		{"小李", "周末 一起去爬山吗"}, // 与前一条仅标点不同
+		{"广告号", "网络赌博日赚千元"},
+		{"小赵", "求推荐好用的VPN"},
+		{"小王", "晚饭吃了火锅"},
+	}
+	// 刷屏：同一作者短时间内连续发布
+	for i := 1; i <= 6; i++ {
+		posts = append(posts, struct{ author, content string }{"刷屏号", fmt.Sprintf("快来关注我 第%d条", i)})
+	}
+	// 大量正常内容，体现流水线的并发处理
+	for i := 1; i <= 200; i++ {
+		posts = append(posts, struct{ author, content string }{fmt.Sprintf("用户%d", i), fmt.Sprintf("第%d位用户的日常分享", i)})
+	}
+
+	start := time.Now()
+	for i, p := range posts {
+		if _, err := moderator.Submit(i+1, p.author, p.content); err != nil {
+			fmt.Printf("提交失败: %v\n", err)
+		}
+	}
+	moderator.Close()
+	elapsed := time.Since(start)
+
+	sort.Slice(decisions, func(i, j int) bool { return decisions[i].ID < decisions[j].ID })
+	fmt.Println("\n审核结果（前14条）:")
+	for _, post := range decisions[:14] {
+		switch post.Machine.State() {
+		case PostApproved:
+			fmt.Printf("  #%-2d %s: %s → %s\n", post.ID, post.Author, post.Machine.State(), post.Masked)
+		default:
+			fmt.Printf("  #%-2d %s: %s（%s阶段: %s）\n", post.ID, post.Author, post.Machine.State(), post.Stage, post.Reason)
+		}
+	}
+
+	// 人工复核：已通过的内容被举报下架，被拒绝的内容不能直接通过
+	approved, rejected := decisions[0], decisions[2]
+	approved.Machine.Fire(EventTakeDown, "用户举报")
+	if err := rejected.Machine.Fire(EventApprove, "人工放行"); err != nil {
+		fmt.Printf("\n人工复核 #%d: %v\n", rejected.ID, err)
+	}
+	fmt.Printf("内容 #%d 的状态流转:", approved.ID)
+	for _, t := range approved.Machine.History() {
+		fmt.Printf(" %s -%s-> %s", t.From, t.Event, t.To)
+	}
+	fmt.Println()
+
+	stats := moderator.Stats()
+	fmt.Printf("\n审核指标（%d 篇，耗时 %v，过滤阶段每篇额外 %v，%d 个协程）:\n",
+		stats["submitted"], elapsed.Round(time.Millisecond), options.SlowFilterDelay, options.FilterWorkers)
+	fmt.Printf("  状态分布: %v\n", stats["status"])
+	fmt.Printf("  拒绝原因: %v\n", stats["reasons"])
+	fmt.Printf("  打码后通过: %d\n", stats["masked"])
+	fmt.Printf("  审核耗时: 平均 %v，P99 %v\n",
+		stats["avgLatency"].(time.Duration).Round(time.Microsecond), stats["p99Latency"].(time.Duration).Round(time.Microsecond))
+	stages := stats["stages"].(map[string]interface{})
+	for _, name := range []string{"限流", "去重", "违禁词"} {
+		fmt.Printf("  [%s] %v\n", name, stages[name])
+	}
+}
@@ -0,0 +1,357 @@
+package practical_applications
+
+/*
+CRDT 无冲突复制数据类型
+
+原理：
+多个数据中心同时接受写入（多主、多活）时，同一份数据会在不同地方被并发修改，
+传统做法需要加锁或选出唯一的主节点，跨地域时延迟很高。
+CRDT（Conflict-free Replicated Data Type）把数据设计成一个"只会单调增长"的状态，
+并定义满足交换律、结合律和幂等律的合并操作：无论副本以什么顺序、合并多少次，最终都收敛到同一个状态。
+
+关键特点：
+1. G-Counter：每个节点只增加自己的计数，合并时对每个节点取最大值，总值为各节点之和
+2. PN-Counter：用两个 G-Counter 分别记录增加和减少，值为两者之差，支持减法
+3. OR-Set（Observed-Remove Set）：每次添加生成唯一标签，删除只删除本地已观察到的标签，
+   因此并发的"添加"和"删除"同一个元素时，添加获胜
+4. 合并幂等：重复合并、乱序合并都不会改变结果
+
+实现方式：
+- 计数器使用 节点ID → 计数 的映射
+- OR-Set 使用 元素 → 标签集合 的映射和已删除标签集合（墓碑）
+- 所有类型实现 CRDT 接口，容灾系统的多主模式通过接口统一合并
+
+应用场景：
+- 多活数据中心的点赞数、库存扣减记录、在线用户集合
+- 协同编辑、离线优先的移动应用
+- 分布式缓存的计数统计
+
+优缺点：
+- 优点：无需协调即可接受写入，分区期间各数据中心照常服务，恢复后自动收敛
+- 缺点：只适用于能表达为CRDT的数据；OR-Set 的墓碑会持续增长；不能表达"余额不能为负"等全局约束
+
+以下实现了 G-Counter、PN-Counter 和 OR-Set。
+*/
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ErrCRDTTypeMismatch 合并了不同类型的CRDT
+var ErrCRDTTypeMismatch = errors.New("CRDT类型不匹配")
+
+// CRDT 可合并的复制数据类型
+type CRDT interface {
+	// Merge 将另一个副本的状态合并进来
+	Merge(other CRDT) error
+	// Clone 返回状态的深拷贝，节点ID不变
+	Clone() CRDT
+	// NewReplica 返回同类型的空副本，归属于指定节点
+	NewReplica(nodeID string) CRDT
+	// Value 返回当前值
+	Value() interface{}
+}
+
+// GCounter 只增计数器
+type GCounter struct {
+	nodeID string            // 当前副本所属节点
+	counts map[string]uint64 // 各节点的计数
+}
+
+// NewGCounter 创建只增计数器
+func NewGCounter(nodeID string) *GCounter {
+	return &GCounter{nodeID: nodeID, counts: make(map[string]uint64)}
+}
+
+// Increment 增加当前节点的计数
+func (c *GCounter) Increment(delta uint64) {
+	c.counts[c.nodeID] += delta
+}
+
+// Count 返回计数总和
+func (c *GCounter) Count() uint64 {
+	total := uint64(0)
+	for _, n := range c.counts {
+		total += n
+	}
+	return total
+}
+
+// Merge 对每个节点的计数取最大值
+func (c *GCounter) Merge(other CRDT) error {
+	o, ok := other.(*GCounter)
+	if !ok {
+		return ErrCRDTTypeMismatch
+	}
+	for node, n := range o.counts {
+		if n > c.counts[node] {
+			c.counts[node] = n
+		}
+	}
+	return nil
+}
+
+// Clone 返回深拷贝
+func (c *GCounter) Clone() CRDT {
+	clone := NewGCounter(c.nodeID)
+	for node, n := range c.counts {
+		clone.counts[node] = n
+	}
+	return clone
+}
+
+// NewReplica 返回指定节点的空计数器
+func (c *GCounter) NewReplica(nodeID string) CRDT {
+	return NewGCounter(nodeID)
+}
+
+// Value 返回计数总和
+func (c *GCounter) Value() interface{} {
+	return c.Count()
+}
+
+// PNCounter 可增可减计数器
+type PNCounter struct {
+	increments *GCounter // 增加的部分
+	decrements *GCounter // 减少的部分
+}
+
+// NewPNCounter 创建可增可减计数器
+func NewPNCounter(nodeID string) *PNCounter {
+	return &PNCounter{increments: NewGCounter(nodeID), decrements: NewGCounter(nodeID)}
+}
+
+// Increment 增加计数，delta 为负数时减少
+func (c *PNCounter) Increment(delta int64) {
+	if delta >= 0 {
+		c.increments.Increment(uint64(delta))
+	} else {
+		c.decrements.Increment(uint64(-delta))
+	}
+}
+
+// Count 返回当前计数
+func (c *PNCounter) Count() int64 {
+	return int64(c.increments.Count()) - int64(c.decrements.Count())
+}
+
+// Merge 分别合并增加和减少两部分
+func (c *PNCounter) Merge(other CRDT) error {
+	o, ok := other.(*PNCounter)
+	if !ok {
+		return ErrCRDTTypeMismatch
+	}
+	c.increments.Merge(o.increments)
+	c.decrements.Merge(o.decrements)
+	return nil
+}
+
+// Clone 返回深拷贝
+func (c *PNCounter) Clone() CRDT {
+	return &PNCounter{
+		increments: c.increments.Clone().(*GCounter),
+		decrements: c.decrements.Clone().(*GCounter),
+	}
+}
+
+// NewReplica 返回指定节点的空计数器
+func (c *PNCounter) NewReplica(nodeID string) CRDT {
+	return NewPNCounter(nodeID)
+}
+
+// Value 返回当前计数
+func (c *PNCounter) Value() interface{} {
+	return c.Count()
+}
+
+// ORSet 观察删除集合（添加优先）
+type ORSet struct {
+	nodeID     string                     // 当前副本所属节点
+	clock      uint64                     // 本节点生成标签的序号
+	elements   map[string]map[string]bool // 元素 -> 存活的标签
+	tombstones map[string]bool            // 已删除的标签
+}
+
+// NewORSet 创建观察删除集合
+func NewORSet(nodeID string) *ORSet {
+	return &ORSet{
+		nodeID:     nodeID,
+		elements:   make(map[string]map[string]bool),
+		tombstones: make(map[string]bool),
+	}
+}
+
+// Add 添加元素，每次添加生成一个唯一标签
+func (s *ORSet) Add(element string) {
+	s.clock++
+	tag := fmt.Sprintf("%s:%d", s.nodeID, s.clock)
+	if s.elements[element] == nil {
+		s.elements[element] = make(map[string]bool)
+	}
+	s.elements[element][tag] = true
+}
+
+// Remove 删除元素：只删除当前副本已观察到的标签
+func (s *ORSet) Remove(element string) {
+	for tag := range s.elements[element] {
+		s.tombstones[tag] = true
+	}
+	delete(s.elements, element)
+}
+
+// Contains 判断元素是否存在
+func (s *ORSet) Contains(element string) bool {
+	return len(s.elements[element]) > 0
+}
+
+// Members 返回排序后的所有元素
+func (s *ORSet) Members() []string {
+	members := make([]string, 0, len(s.elements))
+	for element := range s.elements {
+		members = append(members, element)
+	}
+	sort.Strings(members)
+	return members
+}
+
+// Merge 合并标签和墓碑：标签取并集后去掉任一副本已删除的标签
+func (s *ORSet) Merge(other CRDT) error {
+	o, ok := other.(*ORSet)
+	if !ok {
+		return ErrCRDTTypeMismatch
+	}
+
+	for tag := range o.tombstones {
+		s.tombstones[tag] = true
+	}
+	for element, tags := range o.elements {
+		for tag := range tags {
+			if s.elements[element] == nil {
+				s.elements[element] = make(map[string]bool)
+			}
+			s.elements[element][tag] = true
+		}
+	}
+	for element, tags := range s.elements {
+		for tag := range tags {
+			if s.tombstones[tag] {
+				delete(tags, tag)
+			}
+		}
+		if len(tags) == 0 {
+			delete(s.elements, element)
+		}
+	}
+	return nil
+}
+
+// Clone 返回深拷贝
+func (s *ORSet) Clone() CRDT {
+	clone := NewORSet(s.nodeID)
+	clone.clock = s.clock
+	for element, tags := range s.elements {
+		clone.elements[element] = make(map[string]bool, len(tags))
+		for tag := range tags {
+			clone.elements[element][tag] = true
+		}
+	}
+	for tag := range s.tombstones {
+		clone.tombstones[tag] = true
+	}
+	return clone
+}
+
+// NewReplica 返回指定节点的空集合
+func (s *ORSet) NewReplica(nodeID string) CRDT {
+	return NewORSet(nodeID)
+}
+
+// Value 返回排序后的所有元素
+func (s *ORSet) Value() interface{} {
+	return s.Members()
+}
+
+// 场景示例：多活数据中心的点赞计数和在线用户集合
+func CRDTDemo() {
+	fmt.Println("CRDT示例:")
+
+	// 1. 两个副本独立修改后合并
+	fmt.Println("\n两个副本各自修改后双向合并:")
+	shanghai, beijing := NewPNCounter("dc-sh"), NewPNCounter("dc-bj")
+	shanghai.Increment(10)
+	beijing.Increment(5)
+	beijing.Increment(-2)
+	fmt.Printf("  合并前: 上海=%d, 北京=%d\n", shanghai.Count(), beijing.Count())
+	shanghai.Merge(beijing)
+	beijing.Merge(shanghai)
+	shanghai.Merge(beijing) // 重复合并不影响结果
+	fmt.Printf("  合并后: 上海=%d, 北京=%d\n", shanghai.Count(), beijing.Count())
+
+	// 2. OR-Set 并发添加和删除同一个元素，添加获胜
+	fmt.Println("\nOR-Set 并发的添加与删除:")
+	setA, setB := NewORSet("dc-sh"), NewORSet("dc-bj")
+	setA.Add("张三")
+	setB.Merge(setA)
+	setA.Remove("张三") // 上海看到张三下线
+	setB.Add("张三")    // 同时北京看到张三重新上线
+	setA.Merge(setB)
+	setB.Merge(setA)
+	fmt.Printf("  合并后: 上海=%v, 北京=%v（北京的新添加未被上海观察到，因此保留）\n", setA.Members(), setB.Members())
+
+	// 3. 容灾系统的多主模式
+	fmt.Println("\n容灾系统多主模式:")
+	drs := NewDisasterRecoverySystem(ReplicationMultiPrimary, time.Minute)
+	defer drs.Shutdown()
+
+	dcs := []*DataCenter{
+		NewDataCenter("dc-sh", "上海数据中心", "上海", true),
+		NewDataCenter("dc-bj", "北京数据中心", "北京", false),
+		NewDataCenter("dc-gz", "广州数据中心", "广州", false),
+	}
+	for _, dc := range dcs {
+		drs.AddDataCenter(dc)
+	}
+
+	printState := func(title string) {
+		fmt.Printf("  %s:\n", title)
+		for _, dc := range dcs {
+			likes, _ := drs.CounterValue(dc.ID, "likes:post-1")
+			online, _ := drs.SetMembers(dc.ID, "online-users")
+			fmt.Printf("    %s: 点赞=%d, 在线=%v\n", dc.Name, likes, online)
+		}
+	}
+
+	// 各数据中心就近接受写入
+	drs.IncrementCounter("dc-sh", "likes:post-1", 3)
+	drs.IncrementCounter("dc-bj", "likes:post-1", 2)
+	drs.IncrementCounter("dc-gz", "likes:post-1", 1)
+	drs.AddToSet("dc-sh", "online-users", "张三")
+	drs.AddToSet("dc-bj", "online-users", "李四")
+	printState("各自写入后")
+
+	drs.MergeCRDTs()
+	printState("合并后")
+
+	// 广州故障期间其他数据中心继续写入，恢复后自动收敛
+	drs.UpdateDataCenterStatus("dc-gz", StatusFailed)
+	if err := drs.IncrementCounter("dc-gz", "likes:post-1", 1); err != nil {
+		fmt.Printf("  广州故障，写入被拒绝: %v\n", err)
+	}
+	drs.IncrementCounter("dc-sh", "likes:post-1", -1) // 取消点赞
+	drs.RemoveFromSet("dc-bj", "online-users", "张三")
+	drs.AddToSet("dc-sh", "online-users", "王五")
+	drs.MergeCRDTs()
+	printState("广州故障期间合并（广州被跳过）")
+
+	drs.UpdateDataCenterStatus("dc-gz", StatusHealthy)
+	drs.MergeCRDTs()
+	printState("广州恢复后合并")
+
+	// 类型冲突
+	if err := drs.AddToSet("dc-sh", "likes:post-1", "x"); err != nil {
+		fmt.Printf("  对计数器执行集合操作: %v\n", err)
+	}
+}
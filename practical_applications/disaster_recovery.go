@@ -17,8 +17,16 @@ package practical_applications
 
 实现方式：
 - 使用消息队列或日志复制技术进行数据传输
-- 使用心跳机制监控数据中心健康状态
+- 使用心跳机制监控数据中心健康状态：心跳记录由一个 actor 独占，心跳上报和超时检查都是发给它的消息，
+  记录心跳不需要获取系统的全局锁，actor 处理消息 panic 时自动重启并让所有数据中心重新计时
+- 主数据中心的身份由租约（lease.go）授予：租期等于心跳超时，主数据中心的心跳同时为租约续约，
+  写入前用 TimeRemainingSafe 确认租约仍然安全有效；心跳超时触发的切换要等旧主的租约到期再过一个保护间隔才完成，
+  避免失联但仍在运行的旧主与新主同时写入；明确标记为故障视为旧主已经停止服务，释放租约后立即切换
 - 设计适合业务场景的复制策略和一致性模型
+- 状态变化和故障切换通过广播中心（concurrency.Hub）推送给多个观察者（监控面板、审计日志、告警），
+  事件在持有锁时记录、释放锁之后再发布，阻塞策略的观察者不会卡住系统本身
+- 心跳检测、异步复制、CRDT合并协程以及心跳 actor、事件订阅都注册到 lifecycle.Manager，
+  Shutdown 按依赖关系逆序停止：后台协程先退出，再停止它们依赖的 actor 和事件订阅
 
 应用场景：
 - 金融系统的交易数据备份
@@ -40,6 +48,9 @@ import (
 	"log"
 	"sync"
 	"time"
+
+	"github.com/strive/scenario/concurrency"
+	"github.com/strive/scenario/lifecycle"
 )
 
 // 数据中心状态
@@ -54,152 +65,322 @@ const (
 	ReplicationSync     = "同步复制"
 	ReplicationAsync    = "异步复制"
 	ReplicationSemiSync = "半同步复制"
+	// 多主复制：普通键按异步复制处理，CRDT键可以在任意健康的数据中心写入，定期合并收敛
+	ReplicationMultiPrimary = "多主复制"
 )
 
+// ErrDataNotFound 读取的键在数据中心中不存在
+var ErrDataNotFound = errors.New("数据不存在")
+
+// 容灾事件类型
+const (
+	DREventStatusChanged  = "状态变化"
+	DREventFailover       = "故障切换"
+	DREventFailoverFailed = "故障切换失败"
+)
+
+// DREvent 容灾系统事件
+type DREvent struct {
+	Type string    // 事件类型
+	DC   string    // 状态变化的数据中心；故障切换时为新的主数据中心
+	From string    // 变化前的状态；故障切换时为旧的主数据中心
+	To   string    // 变化后的状态；故障切换时为新的主数据中心
+	Time time.Time // 事件发生时间
+}
+
+// crdtMergeInterval 多主模式下CRDT定期合并的间隔
+const crdtMergeInterval = time.Second
+
 // DataCenter 数据中心结构
 type DataCenter struct {
-	ID            string            // 数据中心ID
-	Name          string            // 数据中心名称
-	Location      string            // 地理位置
-	Status        string            // 当前状态
-	IsActive      bool              // 是否为活跃的主数据中心
-	Storage       map[string][]byte // 存储的数据
-	lastHeartbeat time.Time         // 最后一次心跳时间
-	mutex         sync.RWMutex      // 读写锁
+	ID       string            // 数据中心ID
+	Name     string            // 数据中心名称
+	Location string            // 地理位置
+	Status   string            // 当前状态
+	IsActive bool              // 是否为活跃的主数据中心
+	Storage  map[string][]byte // 存储的数据
+	crdts    map[string]CRDT   // 多主模式下的CRDT数据
+	mutex    sync.RWMutex      // 读写锁
 }
 
 // DisasterRecoverySystem 异地容灾系统
 type DisasterRecoverySystem struct {
-	dataCenters      map[string]*DataCenter // 所有数据中心
-	primaryDC        *DataCenter            // 主数据中心
-	replicationMode  string                 // 复制策略
-	heartbeatTimeout time.Duration          // 心跳超时时间
-	pendingWrites    map[string][]byte      // 待复制的写操作
-	mutex            sync.RWMutex           // 读写锁
-	ctx              context.Context        // 上下文
-	cancel           context.CancelFunc     // 取消函数
+	dataCenters      map[string]*DataCenter                     // 所有数据中心
+	primaryDC        *DataCenter                                // 主数据中心
+	replicationMode  string                                     // 复制策略
+	heartbeatTimeout time.Duration                              // 心跳超时时间
+	heartbeats       *concurrency.Actor[heartbeatMsg, []string] // 记录各数据中心最后一次心跳的 actor
+	leases           *LeaseManager                              // 授予主数据中心身份的租约
+	primaryLease     *Lease                                     // 当前主数据中心持有的租约，心跳时续约
+	failoverPending  bool                                       // 主数据中心已故障但旧租约尚未失效，下次检查心跳时重试切换
+	replication      *ReplicationQueue                          // 待复制的写操作（按目标数据中心和优先级排队）
+	priorityFunc     func(key string) ReplicationPriority       // 键的复制优先级
+	seq              uint64                                     // 最近一次写入的全局序号
+	mutex            sync.RWMutex                               // 读写锁
+	ctx              context.Context                            // 上下文
+	cancel           context.CancelFunc                         // 取消函数
+	components       *lifecycle.Manager                         // 后台协程、心跳 actor 和事件订阅的启停
+	events           concurrency.Hub[DREvent]                   // 状态变化和故障切换事件
+	pendingEvents    []DREvent                                  // 持有锁时记录、尚未发布的事件
+	publishMutex     sync.Mutex                                 // 串行化事件发布，保证发布顺序与记录顺序一致
 }
 
 // NewDataCenter 创建新的数据中心
 func NewDataCenter(id, name, location string, isActive bool) *DataCenter {
 	return &DataCenter{
-		ID:            id,
-		Name:          name,
-		Location:      location,
-		Status:        StatusHealthy,
-		IsActive:      isActive,
-		Storage:       make(map[string][]byte),
-		lastHeartbeat: time.Now(),
+		ID:       id,
+		Name:     name,
+		Location: location,
+		Status:   StatusHealthy,
+		IsActive: isActive,
+		Storage:  make(map[string][]byte),
+		crdts:    make(map[string]CRDT),
+	}
+}
+
+// primaryLeaseName 主数据中心租约的名字
+const primaryLeaseName = "primary"
+
+// primaryLeaseOptions 返回主数据中心租约的配置：租期等于心跳超时，时钟偏差上界取租期的十分之一；
+// 心跳超时无效时使用默认配置
+func primaryLeaseOptions(heartbeatTimeout time.Duration) LeaseOptions {
+	if heartbeatTimeout <= 0 {
+		return DefaultLeaseOptions
 	}
+	options := DefaultLeaseOptions
+	options.Duration = heartbeatTimeout
+	options.MaxClockSkew = heartbeatTimeout / 10
+	return options
 }
 
 // NewDisasterRecoverySystem 创建新的异地容灾系统
 func NewDisasterRecoverySystem(replicationMode string, heartbeatTimeout time.Duration) *DisasterRecoverySystem {
 	ctx, cancel := context.WithCancel(context.Background())
 
+	// 保护间隔约为租期的11%，配置总是有效
+	leases, _ := NewLeaseManager(primaryLeaseOptions(heartbeatTimeout))
 	drs := &DisasterRecoverySystem{
 		dataCenters:      make(map[string]*DataCenter),
 		replicationMode:  replicationMode,
 		heartbeatTimeout: heartbeatTimeout,
-		pendingWrites:    make(map[string][]byte),
+		leases:           leases,
+		replication:      NewReplicationQueue(DefaultReplicationQueueOptions),
 		ctx:              ctx,
 		cancel:           cancel,
 	}
-
-	// 启动心跳检测和异步复制（如果是异步模式）
-	go drs.heartbeatMonitor()
-	if replicationMode == ReplicationAsync {
-		go drs.asyncReplicationWorker()
+	drs.heartbeats = concurrency.NewActor("heartbeat", drs.newHeartbeatHandler, concurrency.ActorOptions{
+		MailboxSize: concurrency.DefaultActorOptions.MailboxSize,
+		MaxRestarts: -1,
+		OnFailure: func(name string, err *concurrency.PanicError) {
+			log.Printf("心跳检测 %s 处理消息异常，重启并重新计时: %v", name, err.Value)
+		},
+	})
+
+	// 后台组件交给生命周期管理器：后台协程依赖心跳 actor 和事件订阅，关闭时先停止
+	drs.components = lifecycle.NewManager()
+	drs.components.RegisterStopper("事件订阅", func() {
+		drs.publishEvents()
+		drs.events.Close()
+	})
+	drs.components.RegisterStopper("心跳actor", drs.heartbeats.Stop)
+	drs.registerWorker("心跳检测", drs.heartbeatMonitor, "心跳actor", "事件订阅")
+	// 异步复制：异步写入，以及同步、半同步模式中未能立即复制的部分
+	drs.registerWorker("异步复制", drs.asyncReplicationWorker, "事件订阅")
+	if replicationMode == ReplicationMultiPrimary {
+		drs.registerWorker("CRDT合并", drs.crdtMergeWorker, "事件订阅")
 	}
+	// 所有钩子的启动都只是开启协程，不会失败
+	drs.components.Start(context.Background())
 
 	return drs
 }
 
+// registerWorker 把后台协程注册为生命周期组件：启动时在协程中运行 run，停止时取消它的上下文并等待退出
+func (drs *DisasterRecoverySystem) registerWorker(name string, run func(ctx context.Context), dependsOn ...string) {
+	var cancel context.CancelFunc
+	done := make(chan struct{})
+	drs.components.Register(lifecycle.Hook{
+		Name:      name,
+		DependsOn: dependsOn,
+		Start: func(context.Context) error {
+			var ctx context.Context
+			ctx, cancel = context.WithCancel(drs.ctx)
+			go func() {
+				defer close(done)
+				run(ctx)
+			}()
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			cancel()
+			select {
+			case <-done:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	})
+}
+
 // AddDataCenter 添加数据中心
 func (drs *DisasterRecoverySystem) AddDataCenter(dc *DataCenter) {
 	drs.mutex.Lock()
-	defer drs.mutex.Unlock()
+	defer func() {
+		drs.mutex.Unlock()
+		// 释放锁之后再发消息：心跳 actor 重启时需要读取数据中心列表
+		drs.heartbeats.Tell(heartbeatMsg{dcID: dc.ID, at: time.Now()})
+	}()
 
 	drs.dataCenters[dc.ID] = dc
 
 	// 如果是第一个添加的数据中心，或者明确指定为活跃，则设为主数据中心
 	if drs.primaryDC == nil || dc.IsActive {
-		if drs.primaryDC != nil {
-			drs.primaryDC.IsActive = false
+		// 明确指定新的主数据中心属于计划内切换，旧主先释放租约
+		if drs.primaryLease != nil {
+			drs.primaryLease.Release()
+		}
+		if err := drs.promoteLocked(dc); err != nil {
+			log.Printf("%s 获取主数据中心租约失败: %v", dc.ID, err)
+			dc.IsActive = false
 		}
-		drs.primaryDC = dc
-		dc.IsActive = true
+	}
+}
+
+// promoteLocked 为 dc 获取主数据中心租约并设为主数据中心（调用方需持有锁）；
+// 旧主的租约没有释放时，要等它到期再过一个保护间隔才能获取成功
+func (drs *DisasterRecoverySystem) promoteLocked(dc *DataCenter) error {
+	lease, err := drs.leases.Acquire(primaryLeaseName, dc.ID, nil)
+	if err != nil {
+		return err
+	}
+	if drs.primaryDC != nil {
+		drs.primaryDC.IsActive = false
+	}
+	drs.primaryDC = dc
+	drs.primaryLease = lease
+	dc.IsActive = true
+	return nil
+}
+
+// SetPriorityFunc 设置键的复制优先级，同一个键的优先级必须固定，才能保证该键的写入顺序
+func (drs *DisasterRecoverySystem) SetPriorityFunc(priorityFunc func(key string) ReplicationPriority) {
+	drs.mutex.Lock()
+	defer drs.mutex.Unlock()
+	drs.priorityFunc = priorityFunc
+}
+
+// enqueueReplication 将写入加入除 replicated 以外所有备份数据中心的复制队列（调用方需持有锁）
+func (drs *DisasterRecoverySystem) enqueueReplication(seq uint64, key string, data []byte, replicated map[string]bool) {
+	priority := ReplicationPriorityNormal
+	if drs.priorityFunc != nil {
+		priority = drs.priorityFunc(key)
+	}
+
+	destinations := make([]string, 0, len(drs.dataCenters))
+	for _, dc := range drs.dataCenters {
+		if dc != drs.primaryDC && !replicated[dc.ID] {
+			destinations = append(destinations, dc.ID)
+		}
+	}
+	if err := drs.replication.Enqueue(seq, key, data, priority, destinations...); err != nil {
+		log.Printf("加入复制队列失败: %v", err)
 	}
 }
 
 // Write 写入数据到系统
 func (drs *DisasterRecoverySystem) Write(key string, data []byte) error {
+	_, err := drs.write(key, data)
+	return err
+}
+
+// write 写入数据并返回分配的全局序号，序号为0表示数据没有写入
+func (drs *DisasterRecoverySystem) write(key string, data []byte) (uint64, error) {
 	drs.mutex.Lock()
 	defer drs.mutex.Unlock()
 
 	if drs.primaryDC == nil {
-		return errors.New("没有可用的主数据中心")
+		return 0, errors.New("没有可用的主数据中心")
 	}
 
 	if drs.primaryDC.Status != StatusHealthy && drs.primaryDC.Status != StatusDegraded {
-		return errors.New("主数据中心状态异常，无法写入")
+		return 0, errors.New("主数据中心状态异常，无法写入")
+	}
+
+	// 按最坏的时钟误差计算，租约可能已经失效，新主随时可能接管
+	if drs.primaryLease.TimeRemainingSafe() <= 0 {
+		return 0, fmt.Errorf("主数据中心 %s 的租约已失效，等待续约或故障切换", drs.primaryDC.ID)
 	}
 
 	// 按照不同的复制策略处理写入
 	switch drs.replicationMode {
 	case ReplicationSync:
 		// 同步复制：先写入主数据中心，再同步复制到所有备份数据中心
+		drs.seq++
 		drs.primaryDC.mutex.Lock()
 		drs.primaryDC.Storage[key] = data
 		drs.primaryDC.mutex.Unlock()
 
-		// 同步复制到所有其他数据中心
+		// 同步复制到所有健康的数据中心，不健康的数据中心恢复后通过复制队列追赶
+		replicated := make(map[string]bool, len(drs.dataCenters))
 		for _, dc := range drs.dataCenters {
 			if dc.ID != drs.primaryDC.ID && dc.Status == StatusHealthy {
 				dc.mutex.Lock()
 				dc.Storage[key] = data
 				dc.mutex.Unlock()
+				replicated[dc.ID] = true
 			}
 		}
+		drs.enqueueReplication(drs.seq, key, data, replicated)
 
 	case ReplicationSemiSync:
 		// 半同步复制：写入主数据中心，并至少等待一个备份数据中心确认
+		drs.seq++
 		drs.primaryDC.mutex.Lock()
 		drs.primaryDC.Storage[key] = data
 		drs.primaryDC.mutex.Unlock()
 
 		// 至少复制到一个备份数据中心
-		replicated := false
+		replicated := make(map[string]bool, 1)
 		for _, dc := range drs.dataCenters {
 			if dc.ID != drs.primaryDC.ID && dc.Status == StatusHealthy {
 				dc.mutex.Lock()
 				dc.Storage[key] = data
 				dc.mutex.Unlock()
-				replicated = true
+				replicated[dc.ID] = true
 				break
 			}
 		}
 
-		if !replicated {
-			// 如果没有一个备份数据中心可用，加入待复制队列
-			drs.pendingWrites[key] = data
-			return errors.New("无法完成半同步复制，数据已写入主数据中心但未复制到备份数据中心")
+		// 其余备份数据中心异步复制
+		drs.enqueueReplication(drs.seq, key, data, replicated)
+		if len(replicated) == 0 {
+			return drs.seq, errors.New("无法完成半同步复制，数据已写入主数据中心但未复制到备份数据中心")
 		}
 
-	case ReplicationAsync:
+	case ReplicationAsync, ReplicationMultiPrimary:
 		// 异步复制：先写入主数据中心，再异步复制到备份数据中心
+		drs.seq++
 		drs.primaryDC.mutex.Lock()
 		drs.primaryDC.Storage[key] = data
 		drs.primaryDC.mutex.Unlock()
 
 		// 将数据加入异步复制队列
-		drs.pendingWrites[key] = data
+		drs.enqueueReplication(drs.seq, key, data, nil)
 
 	default:
-		return errors.New("未知的复制策略")
+		return 0, errors.New("未知的复制策略")
 	}
 
-	return nil
+	return drs.seq, nil
+}
+
+// freshnessLocked 返回数据中心已包含的最大连续写入序号（调用方需持有锁）
+func (drs *DisasterRecoverySystem) freshnessLocked(dc *DataCenter) uint64 {
+	if minSeq, pending := drs.replication.MinPendingSeq(dc.ID); pending {
+		return minSeq - 1
+	}
+	return drs.seq
 }
 
 // Read 从系统读取数据
@@ -231,14 +412,51 @@ func (drs *DisasterRecoverySystem) Read(key string) ([]byte, error) {
 
 	data, exists := targetDC.Storage[key]
 	if !exists {
-		return nil, errors.New("数据不存在")
+		return nil, ErrDataNotFound
 	}
 
 	return data, nil
 }
 
+// SubscribeEvents 订阅状态变化和故障切换事件，缓冲区满时按 options.Policy 处理；系统关闭时关闭订阅通道
+func (drs *DisasterRecoverySystem) SubscribeEvents(name string, options concurrency.SubscribeOptions) *concurrency.Subscription[DREvent] {
+	return drs.events.Subscribe(name, options)
+}
+
+// recordEventLocked 记录事件，调用方需持有锁，释放锁后调用 publishEvents 发布
+func (drs *DisasterRecoverySystem) recordEventLocked(event DREvent) {
+	event.Time = time.Now()
+	drs.pendingEvents = append(drs.pendingEvents, event)
+}
+
+// publishEvents 发布已记录的事件，调用方不能持有锁
+func (drs *DisasterRecoverySystem) publishEvents() {
+	drs.publishMutex.Lock()
+	defer drs.publishMutex.Unlock()
+
+	drs.mutex.Lock()
+	events := drs.pendingEvents
+	drs.pendingEvents = nil
+	drs.mutex.Unlock()
+
+	for _, event := range events {
+		drs.events.Publish(event)
+	}
+}
+
+// setStatusLocked 修改数据中心状态并记录状态变化事件，返回修改前的状态
+func (drs *DisasterRecoverySystem) setStatusLocked(dc *DataCenter, status string) string {
+	oldStatus := dc.Status
+	dc.Status = status
+	if oldStatus != status {
+		drs.recordEventLocked(DREvent{Type: DREventStatusChanged, DC: dc.ID, From: oldStatus, To: status})
+	}
+	return oldStatus
+}
+
 // UpdateDataCenterStatus 更新数据中心状态
 func (drs *DisasterRecoverySystem) UpdateDataCenterStatus(dcID, status string) {
+	defer drs.publishEvents()
 	drs.mutex.Lock()
 	defer drs.mutex.Unlock()
 
@@ -247,11 +465,11 @@ func (drs *DisasterRecoverySystem) UpdateDataCenterStatus(dcID, status string) {
 		return
 	}
 
-	oldStatus := dc.Status
-	dc.Status = status
+	oldStatus := drs.setStatusLocked(dc, status)
 
-	// 如果主数据中心发生故障，尝试故障切换
+	// 如果主数据中心发生故障，尝试故障切换；明确标记为故障视为旧主已经停止服务，释放租约后立即切换
 	if dc == drs.primaryDC && status == StatusFailed && oldStatus != StatusFailed {
+		drs.primaryLease.Release()
 		drs.failover()
 	}
 }
@@ -269,23 +487,73 @@ func (drs *DisasterRecoverySystem) failover() {
 		}
 	}
 
-	if newPrimary != nil {
-		newPrimary.IsActive = true
-		drs.primaryDC = newPrimary
-		log.Printf("故障切换：主数据中心从 %s 切换到 %s", drs.primaryDC.ID, newPrimary.ID)
-	} else {
+	if newPrimary == nil {
+		drs.failoverPending = false
 		log.Printf("故障切换失败：没有可用的备份数据中心")
+		drs.recordEventLocked(DREvent{Type: DREventFailoverFailed, DC: drs.primaryDC.ID, From: drs.primaryDC.ID})
+		return
+	}
+
+	oldPrimary := drs.primaryDC
+	if err := drs.promoteLocked(newPrimary); err != nil {
+		// 旧主可能只是与系统失联，仍然认为自己持有租约，等租约到期并过了保护间隔再切换
+		if !drs.failoverPending {
+			log.Printf("故障切换：等待 %s 的租约失效: %v", oldPrimary.ID, err)
+		}
+		drs.failoverPending = true
+		return
+	}
+	drs.failoverPending = false
+
+	// 新主数据中心接管前先补齐复制积压，避免旧数据在接管后覆盖新写入；持有锁期间不会有新的写入
+	if _, err := drs.replication.Flush(newPrimary.ID, drs.replicateLocked()); err != nil {
+		log.Printf("故障切换：%s 补齐复制积压失败: %v", newPrimary.ID, err)
+	}
+	log.Printf("故障切换：主数据中心从 %s 切换到 %s，租约令牌 %d", oldPrimary.ID, newPrimary.ID, drs.primaryLease.Token())
+	drs.recordEventLocked(DREvent{Type: DREventFailover, DC: newPrimary.ID, From: oldPrimary.ID, To: newPrimary.ID})
+}
+
+// heartbeatMsg 心跳 actor 的消息
+type heartbeatMsg struct {
+	dcID string    // 发送心跳的数据中心，为空表示检查超时
+	at   time.Time // 心跳时间或检查时间
+}
+
+// newHeartbeatHandler 创建心跳 actor 的处理函数，最后一次心跳时间只由 actor 协程访问，不需要加锁。
+// actor 重启时心跳记录丢失，所有已知数据中心从当前时间重新计时，宁可晚一个超时周期发现故障也不误判
+func (drs *DisasterRecoverySystem) newHeartbeatHandler() concurrency.ActorHandler[heartbeatMsg, []string] {
+	lastHeartbeat := make(map[string]time.Time)
+	now := time.Now()
+	drs.mutex.RLock()
+	for id := range drs.dataCenters {
+		lastHeartbeat[id] = now
+	}
+	drs.mutex.RUnlock()
+
+	return func(ctx context.Context, msg heartbeatMsg) ([]string, error) {
+		if msg.dcID != "" {
+			lastHeartbeat[msg.dcID] = msg.at
+			return nil, nil
+		}
+		// 检查超时：返回心跳超时的数据中心
+		var expired []string
+		for id, last := range lastHeartbeat {
+			if msg.at.Sub(last) > drs.heartbeatTimeout {
+				expired = append(expired, id)
+			}
+		}
+		return expired, nil
 	}
 }
 
 // 心跳监控
-func (drs *DisasterRecoverySystem) heartbeatMonitor() {
+func (drs *DisasterRecoverySystem) heartbeatMonitor(ctx context.Context) {
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
 
 	for {
 		select {
-		case <-drs.ctx.Done():
+		case <-ctx.Done():
 			return
 		case <-ticker.C:
 			drs.checkHeartbeats()
@@ -295,49 +563,80 @@ func (drs *DisasterRecoverySystem) heartbeatMonitor() {
 
 // 检查所有数据中心的心跳
 func (drs *DisasterRecoverySystem) checkHeartbeats() {
+	// 模拟心跳检测：实际应该通过网络请求检测
+	expired, err := drs.heartbeats.Ask(drs.ctx, heartbeatMsg{at: time.Now()})
+	if err != nil {
+		return
+	}
+
+	defer drs.publishEvents()
 	drs.mutex.Lock()
 	defer drs.mutex.Unlock()
 
-	now := time.Now()
+	for _, id := range expired {
+		dc, exists := drs.dataCenters[id]
+		// 心跳超时，标记为故障
+		if !exists || dc.Status == StatusFailed {
+			continue
+		}
+		drs.setStatusLocked(dc, StatusFailed)
 
-	for _, dc := range drs.dataCenters {
-		// 模拟心跳检测：实际应该通过网络请求检测
-		if now.Sub(dc.lastHeartbeat) > drs.heartbeatTimeout {
-			// 心跳超时，标记为故障
-			if dc.Status != StatusFailed {
-				oldStatus := dc.Status
-				dc.Status = StatusFailed
-
-				// 如果是主数据中心故障，执行故障切换
-				if dc == drs.primaryDC && oldStatus != StatusFailed {
-					drs.failover()
-				}
-			}
+		// 如果是主数据中心故障，执行故障切换
+		if dc == drs.primaryDC {
+			drs.failover()
 		}
 	}
+
+	// 上一次切换在等待旧主的租约失效，主数据中心仍然故障时重试
+	if drs.failoverPending && drs.primaryDC.Status == StatusFailed {
+		drs.failover()
+	}
 }
 
-// 模拟发送心跳
+// 模拟发送心跳，主数据中心的心跳同时为租约续约
 func (drs *DisasterRecoverySystem) SendHeartbeat(dcID string) {
-	drs.mutex.Lock()
-	defer drs.mutex.Unlock()
-
+	drs.mutex.RLock()
 	dc, exists := drs.dataCenters[dcID]
+	var lease *Lease
+	if exists && dc == drs.primaryDC {
+		lease = drs.primaryLease
+	}
+	drs.mutex.RUnlock()
 	if !exists {
 		return
 	}
 
-	dc.lastHeartbeat = time.Now()
+	drs.heartbeats.Tell(heartbeatMsg{dcID: dcID, at: time.Now()})
+	if lease != nil && lease.Renew() != nil {
+		drs.reacquirePrimaryLease(dc)
+	}
+}
+
+// reacquirePrimaryLease 主数据中心的租约已经过期但没有被其他数据中心取得时，重新获取租约
+func (drs *DisasterRecoverySystem) reacquirePrimaryLease(dc *DataCenter) {
+	drs.mutex.Lock()
+	defer drs.mutex.Unlock()
+
+	// 续约期间可能已经切换了主数据中心，或者旧主已被判定为故障正在等待切换
+	if dc != drs.primaryDC || dc.Status == StatusFailed {
+		return
+	}
+	lease, err := drs.leases.Acquire(primaryLeaseName, dc.ID, nil)
+	if err != nil {
+		log.Printf("%s 重新获取主数据中心租约失败: %v", dc.ID, err)
+		return
+	}
+	drs.primaryLease = lease
 }
 
 // 异步复制工作器
-func (drs *DisasterRecoverySystem) asyncReplicationWorker() {
+func (drs *DisasterRecoverySystem) asyncReplicationWorker(ctx context.Context) {
 	ticker := time.NewTicker(500 * time.Millisecond)
 	defer ticker.Stop()
 
 	for {
 		select {
-		case <-drs.ctx.Done():
+		case <-ctx.Done():
 			return
 		case <-ticker.C:
 			drs.processAsyncReplications()
@@ -347,34 +646,228 @@ func (drs *DisasterRecoverySystem) asyncReplicationWorker() {
 
 // 处理异步复制队列
 func (drs *DisasterRecoverySystem) processAsyncReplications() {
-	drs.mutex.Lock()
+	drs.mutex.RLock()
+	defer drs.mutex.RUnlock()
+
+	drs.replication.Process(drs.replicateLocked())
+}
+
+// replicateLocked 返回把一条写入复制到数据中心的函数（调用方需持有锁）
+func (drs *DisasterRecoverySystem) replicateLocked() ReplicateFunc {
+	return func(destination string, entry *ReplicationEntry) error {
+		dc, exists := drs.dataCenters[destination]
+		if !exists {
+			return fmt.Errorf("数据中心 %s 不存在", destination)
+		}
+		if dc.Status == StatusFailed {
+			return errDataCenterUnavailable
+		}
 
-	// 复制待处理的写操作列表，然后释放主锁
-	pendingCopy := make(map[string][]byte)
-	for k, v := range drs.pendingWrites {
-		pendingCopy[k] = v
+		dc.mutex.Lock()
+		dc.Storage[entry.Key] = entry.Data
+		dc.mutex.Unlock()
+		return nil
 	}
+}
 
-	// 清空待处理队列
-	drs.pendingWrites = make(map[string][]byte)
+// ProcessReplication 立即处理一次复制队列，返回成功复制的条数
+func (drs *DisasterRecoverySystem) ProcessReplication() int {
+	drs.mutex.RLock()
+	defer drs.mutex.RUnlock()
 
-	drs.mutex.Unlock()
+	return drs.replication.Process(drs.replicateLocked())
+}
 
-	// 复制到所有健康的备份数据中心
-	for key, data := range pendingCopy {
-		for _, dc := range drs.dataCenters {
-			if dc != drs.primaryDC && dc.Status == StatusHealthy {
-				dc.mutex.Lock()
-				dc.Storage[key] = data
-				dc.mutex.Unlock()
+// ReplicationStats 获取复制积压统计信息
+func (drs *DisasterRecoverySystem) ReplicationStats() map[string]interface{} {
+	return drs.replication.Stats()
+}
+
+// Shutdown 关闭系统，按依赖关系停止后台组件：先等待心跳检测和异步复制协程退出，再停止心跳 actor、关闭所有事件订阅
+func (drs *DisasterRecoverySystem) Shutdown() {
+	drs.cancel() // 先打断正在进行的心跳检查
+	if err := drs.components.Stop(); err != nil {
+		log.Printf("容灾系统关闭失败: %v", err)
+	}
+}
+
+// updateCRDT 在指定数据中心上修改CRDT键，键不存在时用 create 创建
+func (drs *DisasterRecoverySystem) updateCRDT(dcID, key string, create func(nodeID string) CRDT, update func(value CRDT) error) error {
+	drs.mutex.RLock()
+	defer drs.mutex.RUnlock()
+
+	if drs.replicationMode != ReplicationMultiPrimary {
+		return fmt.Errorf("%s模式不支持多点写入，请使用%s模式", drs.replicationMode, ReplicationMultiPrimary)
+	}
+	dc, exists := drs.dataCenters[dcID]
+	if !exists {
+		return fmt.Errorf("数据中心 %s 不存在", dcID)
+	}
+	if dc.Status == StatusFailed {
+		return errDataCenterUnavailable
+	}
+
+	dc.mutex.Lock()
+	defer dc.mutex.Unlock()
+
+	value, exists := dc.crdts[key]
+	if !exists {
+		value = create(dc.ID)
+	}
+	if err := update(value); err != nil {
+		return fmt.Errorf("键 %s: %w", key, err)
+	}
+	dc.crdts[key] = value
+	return nil
+}
+
+// readCRDT 读取指定数据中心上CRDT键的副本
+func (drs *DisasterRecoverySystem) readCRDT(dcID, key string) (CRDT, error) {
+	drs.mutex.RLock()
+	dc, exists := drs.dataCenters[dcID]
+	drs.mutex.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("数据中心 %s 不存在", dcID)
+	}
+
+	dc.mutex.RLock()
+	defer dc.mutex.RUnlock()
+	value, exists := dc.crdts[key]
+	if !exists {
+		return nil, ErrDataNotFound
+	}
+	return value.Clone(), nil
+}
+
+// IncrementCounter 在指定数据中心上增加计数器（PN-Counter），delta 为负数时减少
+func (drs *DisasterRecoverySystem) IncrementCounter(dcID, key string, delta int64) error {
+	return drs.updateCRDT(dcID, key,
+		func(nodeID string) CRDT { return NewPNCounter(nodeID) },
+		func(value CRDT) error {
+			counter, ok := value.(*PNCounter)
+			if !ok {
+				return ErrCRDTTypeMismatch
+			}
+			counter.Increment(delta)
+			return nil
+		})
+}
+
+// AddToSet 在指定数据中心上向集合（OR-Set）添加元素
+func (drs *DisasterRecoverySystem) AddToSet(dcID, key, element string) error {
+	return drs.updateCRDT(dcID, key,
+		func(nodeID string) CRDT { return NewORSet(nodeID) },
+		func(value CRDT) error {
+			set, ok := value.(*ORSet)
+			if !ok {
+				return ErrCRDTTypeMismatch
+			}
+			set.Add(element)
+			return nil
+		})
+}
+
+// RemoveFromSet 在指定数据中心上从集合（OR-Set）删除元素
+func (drs *DisasterRecoverySystem) RemoveFromSet(dcID, key, element string) error {
+	return drs.updateCRDT(dcID, key,
+		func(nodeID string) CRDT { return NewORSet(nodeID) },
+		func(value CRDT) error {
+			set, ok := value.(*ORSet)
+			if !ok {
+				return ErrCRDTTypeMismatch
+			}
+			set.Remove(element)
+			return nil
+		})
+}
+
+// CounterValue 读取指定数据中心上计数器的当前值
+func (drs *DisasterRecoverySystem) CounterValue(dcID, key string) (int64, error) {
+	value, err := drs.readCRDT(dcID, key)
+	if err != nil {
+		return 0, err
+	}
+	counter, ok := value.(*PNCounter)
+	if !ok {
+		return 0, fmt.Errorf("键 %s: %w", key, ErrCRDTTypeMismatch)
+	}
+	return counter.Count(), nil
+}
+
+// SetMembers 读取指定数据中心上集合的所有元素
+func (drs *DisasterRecoverySystem) SetMembers(dcID, key string) ([]string, error) {
+	value, err := drs.readCRDT(dcID, key)
+	if err != nil {
+		return nil, err
+	}
+	set, ok := value.(*ORSet)
+	if !ok {
+		return nil, fmt.Errorf("键 %s: %w", key, ErrCRDTTypeMismatch)
+	}
+	return set.Members(), nil
+}
+
+// MergeCRDTs 在所有可用的数据中心之间合并CRDT状态，合并后各数据中心收敛到相同的值
+func (drs *DisasterRecoverySystem) MergeCRDTs() error {
+	drs.mutex.RLock()
+	defer drs.mutex.RUnlock()
+
+	// 先为每个可用的数据中心拍摄快照，避免同时持有多个数据中心的锁
+	var available []*DataCenter
+	snapshots := make(map[string]map[string]CRDT)
+	for _, dc := range drs.dataCenters {
+		if dc.Status == StatusFailed {
+			continue
+		}
+		available = append(available, dc)
+
+		dc.mutex.RLock()
+		snapshot := make(map[string]CRDT, len(dc.crdts))
+		for key, value := range dc.crdts {
+			snapshot[key] = value.Clone()
+		}
+		dc.mutex.RUnlock()
+		snapshots[dc.ID] = snapshot
+	}
+
+	var errs []error
+	for _, dc := range available {
+		dc.mutex.Lock()
+		for sourceID, snapshot := range snapshots {
+			if sourceID == dc.ID {
+				continue
+			}
+			for key, remote := range snapshot {
+				local, exists := dc.crdts[key]
+				if !exists {
+					local = remote.NewReplica(dc.ID)
+					dc.crdts[key] = local
+				}
+				if err := local.Merge(remote); err != nil {
+					errs = append(errs, fmt.Errorf("%s 合并 %s 的键 %s: %w", dc.ID, sourceID, key, err))
+				}
 			}
 		}
+		dc.mutex.Unlock()
 	}
+	return errors.Join(errs...)
 }
 
-// Shutdown 关闭系统
-func (drs *DisasterRecoverySystem) Shutdown() {
-	drs.cancel()
+// 多主模式下定期合并CRDT
+func (drs *DisasterRecoverySystem) crdtMergeWorker(ctx context.Context) {
+	ticker := time.NewTicker(crdtMergeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := drs.MergeCRDTs(); err != nil {
+				log.Printf("CRDT合并失败: %v", err)
+			}
+		}
+	}
 }
 
 // 场景示例：金融交易系统的异地容灾
@@ -406,6 +899,26 @@ func DisasterRecoveryDemo() {
 	}
 	fmt.Printf("  复制策略: %s\n", drs.replicationMode)
 
+	// 同一份事件流推送给两个观察者：监控面板只关心最新状态，审计日志必须完整记录
+	var observers sync.WaitGroup
+	var dashboard []string
+	var audit []string
+	for _, observer := range []struct {
+		sub  *concurrency.Subscription[DREvent]
+		into *[]string
+	}{
+		{drs.SubscribeEvents("监控面板", concurrency.SubscribeOptions{Buffer: 2, Policy: concurrency.DropOldest}), &dashboard},
+		{drs.SubscribeEvents("审计日志", concurrency.SubscribeOptions{Policy: concurrency.Block, BlockTimeout: time.Second}), &audit},
+	} {
+		observers.Add(1)
+		go func(sub *concurrency.Subscription[DREvent], into *[]string) {
+			defer observers.Done()
+			for event := range sub.C() {
+				*into = append(*into, fmt.Sprintf("%s %s: %s -> %s", event.Type, event.DC, event.From, event.To))
+			}
+		}(observer.sub, observer.into)
+	}
+
 	// 模拟正常业务操作
 	fmt.Println("\n模拟正常业务操作:")
 
@@ -479,6 +992,30 @@ func DisasterRecoveryDemo() {
 		fmt.Printf("  %s: 状态=%s, 是否为主=%v\n", dc.Name, dc.Status, dc.IsActive)
 	}
 
-	// 关闭系统
+	// 关闭系统，事件订阅随之关闭
 	drs.Shutdown()
+	observers.Wait()
+	fmt.Println("\n审计日志收到的事件:")
+	for _, line := range audit {
+		fmt.Printf("  %s\n", line)
+	}
+	fmt.Printf("监控面板收到 %d 条事件（缓冲区满时丢弃最旧的）\n", len(dashboard))
+
+	// 心跳超时检测：心跳记录由心跳 actor 独占，主数据中心停止发送心跳后被判定为故障并自动切换
+	fmt.Println("\n模拟主数据中心心跳中断:")
+	hb := NewDisasterRecoverySystem(ReplicationAsync, 50*time.Millisecond)
+	hbPrimary := NewDataCenter("dc-hz", "杭州数据中心", "杭州", true)
+	hbBackup := NewDataCenter("dc-sz", "深圳数据中心", "深圳", false)
+	hb.AddDataCenter(hbPrimary)
+	hb.AddDataCenter(hbBackup)
+	time.Sleep(80 * time.Millisecond)
+	hb.SendHeartbeat(hbBackup.ID) // 只有备份数据中心还在发送心跳
+	hb.checkHeartbeats()
+	hb.mutex.RLock()
+	fmt.Printf("  %s 状态: %s，%s 状态: %s，新的主数据中心: %s（租约令牌 %d）\n",
+		hbPrimary.Name, hbPrimary.Status, hbBackup.Name, hbBackup.Status, hb.primaryDC.Name, hb.primaryLease.Token())
+	hb.mutex.RUnlock()
+	stats := hb.heartbeats.Stats()
+	fmt.Printf("  心跳 actor 处理消息 %d 条，重启 %d 次\n", stats["processed"], stats["restarts"])
+	hb.Shutdown()
 }
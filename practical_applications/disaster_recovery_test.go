@@ -0,0 +1,91 @@
+package practical_applications
+
+import (
+	"testing"
+	"time"
+)
+
+// 主数据中心停止续约后写入被拒绝，心跳续约后恢复写入
+func TestDisasterRecoveryWriteRequiresPrimaryLease(t *testing.T) {
+	drs := NewDisasterRecoverySystem(ReplicationAsync, 200*time.Millisecond)
+	defer drs.Shutdown()
+	primary := NewDataCenter("dc-a", "A", "A", true)
+	drs.AddDataCenter(primary)
+	drs.AddDataCenter(NewDataCenter("dc-b", "B", "B", false))
+
+	if err := drs.Write("k", []byte("v1")); err != nil {
+		t.Fatalf("持有租约时写入失败: %v", err)
+	}
+
+	// 安全剩余时间为 200ms - 20ms - 已用时长×1.01
+	time.Sleep(200 * time.Millisecond)
+	if err := drs.Write("k", []byte("v2")); err == nil {
+		t.Fatal("租约失效后写入应被拒绝")
+	}
+
+	drs.SendHeartbeat(primary.ID)
+	if err := drs.Write("k", []byte("v3")); err != nil {
+		t.Fatalf("心跳续约后写入失败: %v", err)
+	}
+}
+
+// 心跳超时的旧主没有释放租约，要等租约到期再过一个保护间隔才切换；明确标记为故障时立即切换
+func TestDisasterRecoveryFailoverWaitsForLeaseGuard(t *testing.T) {
+	drs := NewDisasterRecoverySystem(ReplicationAsync, time.Minute)
+	defer drs.Shutdown()
+
+	// 主数据中心租约改用模拟时钟：租期1秒，保护间隔110ms
+	clock := NewSimulatedClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	drs.leases, _ = NewLeaseManager(LeaseOptions{
+		Duration:     time.Second,
+		MaxClockSkew: 100 * time.Millisecond,
+		MaxDriftRate: 0.01,
+		Clock:        clock.Now,
+	})
+	primary := NewDataCenter("dc-a", "A", "A", true)
+	backup := NewDataCenter("dc-b", "B", "B", false)
+	third := NewDataCenter("dc-c", "C", "C", false)
+	drs.AddDataCenter(primary)
+	drs.AddDataCenter(backup)
+	drs.AddDataCenter(third)
+
+	currentPrimary := func() (*DataCenter, bool) {
+		drs.mutex.RLock()
+		defer drs.mutex.RUnlock()
+		return drs.primaryDC, drs.failoverPending
+	}
+
+	// 与 checkHeartbeats 判定心跳超时的处理相同：标记故障并切换，不释放租约
+	drs.mutex.Lock()
+	drs.setStatusLocked(primary, StatusFailed)
+	drs.failover()
+	drs.mutex.Unlock()
+	if dc, pending := currentPrimary(); dc != primary || !pending {
+		t.Fatalf("旧主租约未失效时不应切换: 主数据中心 %s，等待切换 %v", dc.ID, pending)
+	}
+
+	clock.Advance(time.Second)
+	drs.checkHeartbeats()
+	if dc, pending := currentPrimary(); dc != primary || !pending {
+		t.Fatalf("租约到期但保护间隔未结束时不应切换: 主数据中心 %s，等待切换 %v", dc.ID, pending)
+	}
+
+	clock.Advance(110 * time.Millisecond)
+	drs.checkHeartbeats()
+	newPrimary, pending := currentPrimary()
+	if newPrimary == primary || pending {
+		t.Fatalf("保护间隔结束后应完成切换: 主数据中心 %s，等待切换 %v", newPrimary.ID, pending)
+	}
+	if !newPrimary.IsActive || primary.IsActive {
+		t.Errorf("切换后 IsActive 不正确: 新主 %v，旧主 %v", newPrimary.IsActive, primary.IsActive)
+	}
+	if holder := drs.leases.Holder(primaryLeaseName); holder != newPrimary.ID {
+		t.Errorf("租约持有者为 %q，期望 %q", holder, newPrimary.ID)
+	}
+
+	// 明确标记为故障时释放租约，模拟时钟不前进也能立即切换
+	drs.UpdateDataCenterStatus(newPrimary.ID, StatusFailed)
+	if dc, pending := currentPrimary(); dc == newPrimary || dc == primary || pending {
+		t.Fatalf("明确标记为故障后应立即切换: 主数据中心 %s，等待切换 %v", dc.ID, pending)
+	}
+}
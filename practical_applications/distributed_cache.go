@@ -0,0 +1,434 @@
+package practical_applications
+
+/*
+分布式缓存门面与按节点批量的多键读取
+
+原理：
+分布式缓存把键按一致性哈希分布到多个节点。渲染一个页面、组装一个 feed 往往需要几十上百个键，
+如果逐个 Get，总延迟是"键数 × 一次网络往返"，扇出越大越慢。
+多键读取（GetMulti）的思路是：
+- 先用一致性哈希把键按所属节点分组
+- 每个节点只发一次批量请求（相当于 Redis 的 MGET 或 pipeline）
+- 各节点的批量请求通过协程池并行发出
+这样总延迟接近"一次最慢节点的往返"，与键的数量基本无关。
+
+关键特点：
+1. 门面屏蔽节点选择：调用方只面对 Get/Set/Delete/GetMulti/SetMulti
+2. 结果按输入顺序返回，重复的键只请求一次
+3. 部分失败不影响其他节点：结果中标出失败的节点和受影响的键，调用方可以降级回源
+4. 节点的并发请求由协程池执行，扇出再大也不会无限制地创建协程
+
+实现方式：
+- ConsistentHash 负责键到节点的映射，CacheNode 接口抽象一个节点（本地实现或远程客户端）
+- GetMulti 把每个节点的批量请求提交到 concurrency.GoroutinePool，用 WaitGroup 等待全部返回
+- LocalCacheNode 是带模拟网络往返延迟和故障开关的内存节点，用于演示
+
+应用场景：
+- 页面或 feed 组装时批量读取用户、商品信息
+- 批量预热、批量写入缓存
+- 任何"一次请求需要读很多键"的扇出读路径
+
+优缺点：
+- 优点：多键读取的延迟从 O(键数) 降到 O(1) 次往返，节点故障时只损失部分键
+- 缺点：单次批量请求变大，某个节点变慢会拖慢整次 GetMulti（长尾问题）；
+  不在 GetMulti 所用的协程池的任务中调用 GetMulti，否则池满时可能互相等待
+
+以下实现了分布式缓存门面以及按节点分组、并行执行的批量读写。
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/strive/scenario/concurrency"
+)
+
+// CacheNode 分布式缓存中的一个节点，一次调用对应一次网络往返
+type CacheNode interface {
+	GetBatch(keys []string) (map[string]string, error) // 批量读取，不存在的键不出现在结果中
+	SetBatch(entries map[string]string) error          // 批量写入
+	Delete(key string) error                           // 删除
+}
+
+// ErrNoCacheNode 分布式缓存中没有可用节点
+var ErrNoCacheNode = errors.New("没有可用的缓存节点")
+
+// MultiGetResult 多键读取的结果，Values 和 Found 与输入的键一一对应
+type MultiGetResult struct {
+	Keys       []string         // 输入的键
+	Values     []string         // 读取到的值
+	Found      []bool           // 是否命中
+	NodeErrors map[string]error // 失败的节点 -> 错误
+	FailedKeys []string         // 因节点失败而没有结果的键（按输入顺序）
+}
+
+// Hits 返回命中的键数
+func (r *MultiGetResult) Hits() int {
+	hits := 0
+	for _, found := range r.Found {
+		if found {
+			hits++
+		}
+	}
+	return hits
+}
+
+// Err 汇总节点错误，全部成功时返回 nil
+func (r *MultiGetResult) Err() error {
+	if len(r.NodeErrors) == 0 {
+		return nil
+	}
+	nodes := make([]string, 0, len(r.NodeErrors))
+	for node := range r.NodeErrors {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	messages := make([]string, len(nodes))
+	for i, node := range nodes {
+		messages[i] = fmt.Sprintf("%s: %v", node, r.NodeErrors[node])
+	}
+	return fmt.Errorf("%d 个节点失败，%d 个键没有结果（%s）", len(nodes), len(r.FailedKeys), strings.Join(messages, "; "))
+}
+
+// DistributedCache 分布式缓存门面，可被多个协程并发使用
+type DistributedCache struct {
+	mu    sync.RWMutex
+	ring  *ConsistentHash
+	nodes map[string]CacheNode
+	pool  *concurrency.GoroutinePool // 执行各节点的批量请求
+}
+
+// NewDistributedCache 创建分布式缓存，各节点的批量请求在 pool 中执行
+func NewDistributedCache(pool *concurrency.GoroutinePool) *DistributedCache {
+	return &DistributedCache{
+		ring:  NewConsistentHash(DefaultVirtualNodes),
+		nodes: make(map[string]CacheNode),
+		pool:  pool,
+	}
+}
+
+// AddNode 加入节点，同名节点会被替换
+func (dc *DistributedCache) AddNode(name string, node CacheNode) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	dc.nodes[name] = node
+	dc.ring.AddNode(name)
+}
+
+// RemoveNode 移除节点，其上的键会落到环上的下一个节点
+func (dc *DistributedCache) RemoveNode(name string) bool {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	if _, ok := dc.nodes[name]; !ok {
+		return false
+	}
+	delete(dc.nodes, name)
+	dc.ring.RemoveNode(name)
+	return true
+}
+
+// nodeFor 返回键所属的节点
+func (dc *DistributedCache) nodeFor(key string) (string, CacheNode, error) {
+	dc.mu.RLock()
+	defer dc.mu.RUnlock()
+	name, ok := dc.ring.GetNode(key)
+	if !ok {
+		return "", nil, ErrNoCacheNode
+	}
+	return name, dc.nodes[name], nil
+}
+
+// Get 读取单个键
+func (dc *DistributedCache) Get(key string) (string, bool, error) {
+	_, node, err := dc.nodeFor(key)
+	if err != nil {
+		return "", false, err
+	}
+	values, err := node.GetBatch([]string{key})
+	if err != nil {
+		return "", false, err
+	}
+	value, ok := values[key]
+	return value, ok, nil
+}
+
+// Set 写入单个键
+func (dc *DistributedCache) Set(key, value string) error {
+	_, node, err := dc.nodeFor(key)
+	if err != nil {
+		return err
+	}
+	return node.SetBatch(map[string]string{key: value})
+}
+
+// Delete 删除单个键
+func (dc *DistributedCache) Delete(key string) error {
+	_, node, err := dc.nodeFor(key)
+	if err != nil {
+		return err
+	}
+	return node.Delete(key)
+}
+
+// groupByNode 把键按所属节点分组，重复的键只保留一次
+func (dc *DistributedCache) groupByNode(keys []string) (map[string][]string, map[string]CacheNode, error) {
+	dc.mu.RLock()
+	defer dc.mu.RUnlock()
+
+	groups := make(map[string][]string)
+	nodes := make(map[string]CacheNode)
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		name, ok := dc.ring.GetNode(key)
+		if !ok {
+			return nil, nil, ErrNoCacheNode
+		}
+		groups[name] = append(groups[name], key)
+		nodes[name] = dc.nodes[name]
+	}
+	return groups, nodes, nil
+}
+
+// fanOut 在协程池中对每个节点并行执行 fn，返回失败的节点及错误
+func (dc *DistributedCache) fanOut(nodes map[string]CacheNode, fn func(name string, node CacheNode) error) map[string]error {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		failures = make(map[string]error)
+	)
+	record := func(name string, err error) {
+		if err != nil {
+			mu.Lock()
+			failures[name] = err
+			mu.Unlock()
+		}
+	}
+
+	for name, node := range nodes {
+		wg.Add(1)
+		err := dc.pool.Submit(func(context.Context) error {
+			defer wg.Done()
+			err := fn(name, node)
+			record(name, err)
+			return err
+		})
+		if err != nil {
+			wg.Done()
+			record(name, err)
+		}
+	}
+	wg.Wait()
+	return failures
+}
+
+// GetMulti 批量读取：按节点分组，每个节点一次批量请求，各节点并行执行，结果按输入顺序返回
+func (dc *DistributedCache) GetMulti(keys []string) (*MultiGetResult, error) {
+	groups, nodes, err := dc.groupByNode(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	values := make(map[string]string, len(keys))
+	nodeErrors := dc.fanOut(nodes, func(name string, node CacheNode) error {
+		batch, err := node.GetBatch(groups[name])
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		for key, value := range batch {
+			values[key] = value
+		}
+		mu.Unlock()
+		return nil
+	})
+
+	failed := make(map[string]bool)
+	for name := range nodeErrors {
+		for _, key := range groups[name] {
+			failed[key] = true
+		}
+	}
+	result := &MultiGetResult{
+		Keys:       keys,
+		Values:     make([]string, len(keys)),
+		Found:      make([]bool, len(keys)),
+		NodeErrors: nodeErrors,
+	}
+	for i, key := range keys {
+		if failed[key] {
+			result.FailedKeys = append(result.FailedKeys, key)
+			continue
+		}
+		result.Values[i], result.Found[i] = values[key]
+	}
+	return result, nil
+}
+
+// SetMulti 批量写入：按节点分组后并行写入，返回失败的节点及错误（全部成功时为空）
+func (dc *DistributedCache) SetMulti(entries map[string]string) (map[string]error, error) {
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	groups, nodes, err := dc.groupByNode(keys)
+	if err != nil {
+		return nil, err
+	}
+	return dc.fanOut(nodes, func(name string, node CacheNode) error {
+		batch := make(map[string]string, len(groups[name]))
+		for _, key := range groups[name] {
+			batch[key] = entries[key]
+		}
+		return node.SetBatch(batch)
+	}), nil
+}
+
+// LocalCacheNode 内存缓存节点，每次调用前等待 RoundTrip 模拟一次网络往返
+type LocalCacheNode struct {
+	mu        sync.RWMutex
+	data      map[string]string
+	roundTrip time.Duration
+	down      bool
+	calls     int64 // 请求次数（往返次数）
+}
+
+// NewLocalCacheNode 创建内存缓存节点
+func NewLocalCacheNode(roundTrip time.Duration) *LocalCacheNode {
+	return &LocalCacheNode{data: make(map[string]string), roundTrip: roundTrip}
+}
+
+// SetDown 设置节点是否故障，故障时所有请求返回错误
+func (n *LocalCacheNode) SetDown(down bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.down = down
+}
+
+// request 模拟一次往返，节点故障时返回错误
+func (n *LocalCacheNode) request() error {
+	time.Sleep(n.roundTrip)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.calls++
+	if n.down {
+		return errors.New("连接被拒绝")
+	}
+	return nil
+}
+
+// GetBatch 批量读取
+func (n *LocalCacheNode) GetBatch(keys []string) (map[string]string, error) {
+	if err := n.request(); err != nil {
+		return nil, err
+	}
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	values := make(map[string]string, len(keys))
+	for _, key := range keys {
+		if value, ok := n.data[key]; ok {
+			values[key] = value
+		}
+	}
+	return values, nil
+}
+
+// SetBatch 批量写入
+func (n *LocalCacheNode) SetBatch(entries map[string]string) error {
+	if err := n.request(); err != nil {
+		return err
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for key, value := range entries {
+		n.data[key] = value
+	}
+	return nil
+}
+
+// Delete 删除
+func (n *LocalCacheNode) Delete(key string) error {
+	if err := n.request(); err != nil {
+		return err
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.data, key)
+	return nil
+}
+
+// Calls 返回请求次数
+func (n *LocalCacheNode) Calls() int64 {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.calls
+}
+
+// 场景示例：组装商品列表页时批量读取 100 个商品的缓存
+func DistributedCacheDemo() {
+	fmt.Println("分布式缓存批量读取示例:")
+
+	pool := concurrency.NewGoroutinePool(8, 64)
+	defer pool.Shutdown()
+
+	cache := NewDistributedCache(pool)
+	nodes := make(map[string]*LocalCacheNode)
+	for _, name := range []string{"cache-1", "cache-2", "cache-3", "cache-4"} {
+		nodes[name] = NewLocalCacheNode(2 * time.Millisecond)
+		cache.AddNode(name, nodes[name])
+	}
+
+	entries := make(map[string]string)
+	keys := make([]string, 100)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("product:%d", 1000+i)
+		if i%10 != 0 { // 每10个商品有一个不在缓存中
+			entries[keys[i]] = fmt.Sprintf("商品%d详情", 1000+i)
+		}
+	}
+	if failures, err := cache.SetMulti(entries); err != nil || len(failures) > 0 {
+		fmt.Printf("预热失败: %v %v\n", err, failures)
+		return
+	}
+
+	totalCalls := func() int64 {
+		var calls int64
+		for _, node := range nodes {
+			calls += node.Calls()
+		}
+		return calls
+	}
+
+	before := totalCalls()
+	start := time.Now()
+	hits := 0
+	for _, key := range keys {
+		if _, ok, err := cache.Get(key); err == nil && ok {
+			hits++
+		}
+	}
+	fmt.Printf("\n逐个 Get:  耗时 %v，往返 %d 次，命中 %d/%d\n",
+		time.Since(start).Round(time.Millisecond), totalCalls()-before, hits, len(keys))
+
+	before = totalCalls()
+	start = time.Now()
+	result, _ := cache.GetMulti(keys)
+	fmt.Printf("GetMulti:  耗时 %v，往返 %d 次，命中 %d/%d\n",
+		time.Since(start).Round(time.Millisecond), totalCalls()-before, result.Hits(), len(keys))
+	fmt.Printf("结果保持输入顺序: %s=%q, %s 命中=%v\n", keys[1], result.Values[1], keys[10], result.Found[10])
+
+	// 一个节点故障：其余节点的结果照常返回，失败的键交给调用方回源
+	nodes["cache-3"].SetDown(true)
+	result, _ = cache.GetMulti(keys)
+	fmt.Printf("\ncache-3 故障时 GetMulti: 命中 %d，%d 个键没有结果\n", result.Hits(), len(result.FailedKeys))
+	fmt.Printf("错误: %v\n", result.Err())
+}
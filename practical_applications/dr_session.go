@@ -0,0 +1,305 @@
+package practical_applications
+
+/*
+异地容灾客户端会话一致性 - 读己之写与单调读
+
+原理：
+异步复制下备份数据中心的数据总是落后于主数据中心。如果客户端就近读取备份数据中心，
+可能出现"刚写入的数据读不到"（违反读己之写），或者"先读到新值、再读到旧值"（违反单调读）；
+主数据中心故障切换后，从落后的副本读取也会悄悄返回旧数据。
+会话一致性在客户端记录本会话见过的最大写入序号，读取时只把请求路由到至少同样新的副本，
+找不到合适的副本时等待复制追上，超时则明确报错，而不是返回旧数据。
+
+关键特点：
+1. 每次写入分配全局递增的序号，每个数据中心的新鲜度是它已包含的最大连续序号
+2. 读己之写：读取的副本必须包含本会话最近一次写入
+3. 单调读：读取的副本不能比本会话上次读到的副本更旧
+4. 每次读取可以单独指定一致性级别，在延迟和一致性之间取舍
+
+实现方式：
+- 数据中心的新鲜度 = 复制队列中该数据中心最小的待复制序号 − 1，没有积压时等于最新序号
+- 会话记录最近写入序号和最近读取序号
+- 读取时优先选择就近的数据中心，不满足要求时选择其他足够新的数据中心，都不满足时轮询等待
+
+应用场景：
+- 用户修改资料后立即查看（读己之写）
+- 分页浏览、消息列表等不能"回退"的读取（单调读）
+- 多地域部署中的就近读取
+
+优缺点：
+- 优点：大部分读取仍可就近完成，只在必要时绕行或等待，避免了故障切换后静默读到旧数据
+- 缺点：会话状态需要随请求传递；副本长时间落后时读取会超时失败
+
+以下实现了容灾系统之上的会话层。
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ConsistencyLevel 读取一致性级别
+type ConsistencyLevel int
+
+// 读取一致性级别
+const (
+	ConsistencyEventual       ConsistencyLevel = iota // 最终一致：读取任意可用副本
+	ConsistencyMonotonicReads                         // 单调读：不比本会话上次读到的副本旧
+	ConsistencyReadYourWrites                         // 读己之写：包含本会话的写入，同时保证单调读
+	ConsistencyStrong                                 // 强一致：只读主数据中心
+)
+
+// String 返回一致性级别名称
+func (l ConsistencyLevel) String() string {
+	switch l {
+	case ConsistencyEventual:
+		return "最终一致"
+	case ConsistencyMonotonicReads:
+		return "单调读"
+	case ConsistencyReadYourWrites:
+		return "读己之写"
+	case ConsistencyStrong:
+		return "强一致"
+	default:
+		return fmt.Sprintf("未知(%d)", int(l))
+	}
+}
+
+// ErrStaleReplicas 等待超时后仍没有足够新的副本
+var ErrStaleReplicas = errors.New("没有满足一致性要求的数据中心")
+
+// SessionOptions 会话配置
+type SessionOptions struct {
+	PreferredDC  string           // 就近读取的数据中心，为空时优先读主数据中心
+	Consistency  ConsistencyLevel // 默认一致性级别
+	WaitTimeout  time.Duration    // 没有足够新的副本时最多等待的时间，0表示不等待
+	PollInterval time.Duration    // 等待时检查副本新鲜度的间隔
+}
+
+// DefaultSessionOptions 默认会话配置
+var DefaultSessionOptions = SessionOptions{
+	Consistency:  ConsistencyReadYourWrites,
+	WaitTimeout:  time.Second,
+	PollInterval: 20 * time.Millisecond,
+}
+
+// SessionRead 一次会话读取的结果
+type SessionRead struct {
+	Data       []byte        // 读到的数据
+	DataCenter string        // 提供数据的数据中心
+	Freshness  uint64        // 该数据中心的新鲜度（已包含的最大连续写入序号）
+	Waited     time.Duration // 等待副本追上的时间
+}
+
+// Session 客户端会话，记录本会话见过的最大写入序号
+type Session struct {
+	drs       *DisasterRecoverySystem
+	options   SessionOptions
+	lastWrite uint64 // 本会话最近一次写入的序号
+	lastRead  uint64 // 本会话读到过的最大新鲜度
+	mutex     sync.Mutex
+}
+
+// NewSession 创建客户端会话
+func (drs *DisasterRecoverySystem) NewSession(options SessionOptions) *Session {
+	if options.PollInterval <= 0 {
+		options.PollInterval = DefaultSessionOptions.PollInterval
+	}
+	return &Session{drs: drs, options: options}
+}
+
+// Write 写入数据并记录写入序号
+func (s *Session) Write(key string, data []byte) error {
+	seq, err := s.drs.write(key, data)
+
+	// 半同步复制失败时数据已写入主数据中心，同样需要记录
+	if seq > 0 {
+		s.mutex.Lock()
+		if seq > s.lastWrite {
+			s.lastWrite = seq
+		}
+		s.mutex.Unlock()
+	}
+	return err
+}
+
+// Read 按会话默认的一致性级别读取数据
+func (s *Session) Read(key string) ([]byte, error) {
+	result, err := s.ReadWithConsistency(context.Background(), key, s.options.Consistency)
+	if err != nil {
+		return nil, err
+	}
+	return result.Data, nil
+}
+
+// required 返回指定一致性级别要求的最小新鲜度
+func (s *Session) required(level ConsistencyLevel) uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	switch level {
+	case ConsistencyMonotonicReads:
+		return s.lastRead
+	case ConsistencyReadYourWrites:
+		if s.lastWrite > s.lastRead {
+			return s.lastWrite
+		}
+		return s.lastRead
+	default:
+		return 0
+	}
+}
+
+// ReadWithConsistency 按指定一致性级别读取数据，必要时等待副本追上
+func (s *Session) ReadWithConsistency(ctx context.Context, key string, level ConsistencyLevel) (*SessionRead, error) {
+	required := s.required(level)
+	start := time.Now()
+
+	if s.options.WaitTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.options.WaitTimeout)
+		defer cancel()
+	}
+
+	for {
+		dc, freshness := s.drs.chooseReplica(s.options.PreferredDC, level, required)
+		if dc != nil {
+			dc.mutex.RLock()
+			data, exists := dc.Storage[key]
+			dc.mutex.RUnlock()
+
+			s.mutex.Lock()
+			if freshness > s.lastRead {
+				s.lastRead = freshness
+			}
+			s.mutex.Unlock()
+
+			if !exists {
+				return nil, ErrDataNotFound
+			}
+			return &SessionRead{Data: data, DataCenter: dc.ID, Freshness: freshness, Waited: time.Since(start)}, nil
+		}
+
+		if s.options.WaitTimeout <= 0 {
+			return nil, fmt.Errorf("%w: 需要新鲜度 %d（%s）", ErrStaleReplicas, required, level)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: 需要新鲜度 %d（%s），已等待 %v",
+				ErrStaleReplicas, required, level, time.Since(start).Round(time.Millisecond))
+		case <-time.After(s.options.PollInterval):
+		}
+	}
+}
+
+// chooseReplica 选择满足一致性要求的数据中心，优先选择 preferred
+func (drs *DisasterRecoverySystem) chooseReplica(preferred string, level ConsistencyLevel, required uint64) (*DataCenter, uint64) {
+	drs.mutex.RLock()
+	defer drs.mutex.RUnlock()
+
+	available := func(dc *DataCenter) bool {
+		return dc != nil && (dc.Status == StatusHealthy || dc.Status == StatusDegraded)
+	}
+
+	if level == ConsistencyStrong {
+		if available(drs.primaryDC) {
+			return drs.primaryDC, drs.freshnessLocked(drs.primaryDC)
+		}
+		return nil, 0
+	}
+
+	// 候选顺序：就近数据中心、主数据中心、其他数据中心中最新的一个
+	if dc := drs.dataCenters[preferred]; available(dc) {
+		if freshness := drs.freshnessLocked(dc); freshness >= required {
+			return dc, freshness
+		}
+	}
+	if available(drs.primaryDC) {
+		if freshness := drs.freshnessLocked(drs.primaryDC); freshness >= required {
+			return drs.primaryDC, freshness
+		}
+	}
+	var best *DataCenter
+	bestFreshness := uint64(0)
+	for _, dc := range drs.dataCenters {
+		if !available(dc) {
+			continue
+		}
+		if freshness := drs.freshnessLocked(dc); freshness >= required && (best == nil || freshness > bestFreshness) {
+			best, bestFreshness = dc, freshness
+		}
+	}
+	return best, bestFreshness
+}
+
+// Freshness 返回各数据中心的新鲜度
+func (drs *DisasterRecoverySystem) Freshness() map[string]uint64 {
+	drs.mutex.RLock()
+	defer drs.mutex.RUnlock()
+
+	freshness := make(map[string]uint64, len(drs.dataCenters))
+	for id, dc := range drs.dataCenters {
+		freshness[id] = drs.freshnessLocked(dc)
+	}
+	return freshness
+}
+
+// 场景示例：用户就近读取备份数据中心时的会话一致性
+func SessionConsistencyDemo() {
+	fmt.Println("容灾系统会话一致性示例:")
+
+	drs := NewDisasterRecoverySystem(ReplicationAsync, time.Minute)
+	defer drs.Shutdown()
+
+	shanghai := NewDataCenter("dc-sh", "上海数据中心", "上海", true)
+	beijing := NewDataCenter("dc-bj", "北京数据中心", "北京", false)
+	guangzhou := NewDataCenter("dc-gz", "广州数据中心", "广州", false)
+	for _, dc := range []*DataCenter{shanghai, beijing, guangzhou} {
+		drs.AddDataCenter(dc)
+	}
+
+	// 广州用户就近读取广州数据中心
+	options := DefaultSessionOptions
+	options.PreferredDC = guangzhou.ID
+	session := drs.NewSession(options)
+
+	show := func(label string, level ConsistencyLevel) {
+		result, err := session.ReadWithConsistency(context.Background(), "profile:1001", level)
+		if err != nil {
+			fmt.Printf("  %s %s读取失败: %v\n", label, level, err)
+			return
+		}
+		fmt.Printf("  %s %s读取 %s: %q（新鲜度 %d）\n",
+			label, level, result.DataCenter, result.Data, result.Freshness)
+	}
+
+	session.Write("profile:1001", []byte("昵称=小明"))
+	drs.ProcessReplication()
+	fmt.Printf("初始资料已复制，新鲜度: %v\n", drs.Freshness())
+
+	// 广州短暂故障，修改只复制到了北京，广州恢复后仍有积压
+	drs.UpdateDataCenterStatus(guangzhou.ID, StatusFailed)
+	session.Write("profile:1001", []byte("昵称=明明"))
+	drs.ProcessReplication()
+	drs.UpdateDataCenterStatus(guangzhou.ID, StatusHealthy)
+	fmt.Printf("\n修改资料，广州恢复但尚未追上，新鲜度: %v\n", drs.Freshness())
+	show("修改后读取:", ConsistencyEventual)
+	show("修改后读取:", ConsistencyReadYourWrites)
+	show("再次读取:  ", ConsistencyMonotonicReads)
+
+	// 另一个会话没有写入过，单调读允许读取广州
+	other := drs.NewSession(options)
+	result, _ := other.ReadWithConsistency(context.Background(), "profile:1001", ConsistencyMonotonicReads)
+	fmt.Printf("  新会话     单调读读取 %s: %q（新鲜度 %d）\n", result.DataCenter, result.Data, result.Freshness)
+
+	show("强一致:    ", ConsistencyStrong)
+
+	// 北京、上海相继故障，故障切换到广州前先补齐积压
+	drs.UpdateDataCenterStatus(beijing.ID, StatusFailed)
+	drs.UpdateDataCenterStatus(shanghai.ID, StatusFailed)
+	fmt.Printf("\n北京、上海相继故障，切换到广州，新鲜度: %v\n", drs.Freshness())
+	show("切换后读取:", ConsistencyReadYourWrites)
+}
@@ -0,0 +1,308 @@
+package practical_applications
+
+/*
+请求对冲（Hedged Requests）- 降低长尾延迟
+
+原理：
+分布式系统中，一次请求的延迟往往由少数慢副本决定：GC停顿、磁盘抖动、网络重传都会让个别请求慢上几十倍。
+请求对冲的做法是先向一个副本发出主请求，如果它在一个较短的延迟（通常取P95左右）内没有返回，
+就再向另一个副本发出备份请求，谁先成功就用谁的结果，并取消另一个。
+因为慢请求只占少数，备份请求只会在尾部触发，用很少的额外负载换来P99的大幅下降。
+
+关键特点：
+1. 延迟触发：备份请求只在主请求"已经慢了"时才发出，而不是每次都发两份
+2. 先到先得：返回第一个成功的结果，失败的一方不会掩盖另一方的成功
+3. 取消落败者：胜出后通过 context 取消另一个请求，及时释放下游资源
+4. 预算控制：对冲预算按请求数累积令牌，每次对冲消耗一个，保证额外负载不超过设定比例，
+   下游整体变慢时不会因为大量对冲而雪上加霜
+
+实现方式：
+- 主请求和备份请求在各自的 goroutine 中执行，共享一个可取消的 context，结果写入带缓冲的通道
+- 定时器到期或主请求提前失败时发起备份请求（需要预算允许）
+- 对冲预算：每个请求存入 ratio 个令牌，令牌数不超过 burst，发起对冲需要取出一个完整令牌
+
+应用场景：
+- 读多副本存储（分布式KV、搜索集群的多个分片副本）
+- 调用多个等价的下游实例或多个可用区
+- 延迟敏感的扇出查询，任何一个子请求慢都会拖慢整体
+
+优缺点：
+- 优点：实现简单，对P99/P999改善明显，与重试、熔断互补
+- 缺点：只适用于幂等请求；会带来额外负载，需要预算限制；延迟阈值需要根据延迟分布调整
+
+以下实现了带对冲预算的泛型请求对冲函数。
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HedgeFunc 可被对冲的请求，需要在 ctx 取消时尽快返回
+type HedgeFunc[T any] func(ctx context.Context) (T, error)
+
+// hedgeResult 单个请求的结果
+type hedgeResult[T any] struct {
+	value  T
+	err    error
+	backup bool // 是否来自备份请求
+}
+
+// HedgeBudget 对冲预算，限制对冲带来的额外负载
+type HedgeBudget struct {
+	ratio      float64 // 每个请求存入的令牌数，即长期允许的对冲比例
+	burst      float64 // 令牌上限，允许短时间内集中对冲的次数
+	tokens     float64 // 当前令牌数
+	mutex      sync.Mutex
+	requests   int64 // 请求总数
+	hedged     int64 // 发起的对冲次数
+	denied     int64 // 因预算不足放弃的对冲次数
+	backupWins int64 // 备份请求胜出的次数
+}
+
+// NewHedgeBudget 创建对冲预算，ratio 为允许的对冲比例（如0.1表示最多增加约10%的请求），burst 为令牌上限
+func NewHedgeBudget(ratio float64, burst int) *HedgeBudget {
+	if ratio < 0 {
+		ratio = 0
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &HedgeBudget{
+		ratio:  ratio,
+		burst:  float64(burst),
+		tokens: float64(burst), // 初始预算是满的，冷启动时也能对冲
+	}
+}
+
+// deposit 记录一个请求并存入令牌
+func (b *HedgeBudget) deposit() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.requests++
+	b.tokens = math.Min(b.burst, b.tokens+b.ratio)
+}
+
+// withdraw 尝试取出一个令牌用于对冲
+func (b *HedgeBudget) withdraw() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.tokens < 1 {
+		b.denied++
+		return false
+	}
+	b.tokens--
+	b.hedged++
+	return true
+}
+
+// recordBackupWin 记录一次备份请求胜出
+func (b *HedgeBudget) recordBackupWin() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.backupWins++
+}
+
+// Stats 获取对冲预算的统计信息
+func (b *HedgeBudget) Stats() map[string]interface{} {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	hedgeRate := 0.0
+	if b.requests > 0 {
+		hedgeRate = float64(b.hedged) / float64(b.requests)
+	}
+	return map[string]interface{}{
+		"ratio":      b.ratio,
+		"burst":      b.burst,
+		"tokens":     b.tokens,
+		"requests":   b.requests,
+		"hedged":     b.hedged,
+		"denied":     b.denied,
+		"backupWins": b.backupWins,
+		"hedgeRate":  hedgeRate,
+	}
+}
+
+// Hedge 发起主请求，delay 内未返回时再发起备份请求，返回先成功的结果并取消另一个；
+// backup 为 nil 时重复调用 primary
+func Hedge[T any](ctx context.Context, primary, backup HedgeFunc[T], delay time.Duration) (T, error) {
+	return HedgeWithBudget(ctx, nil, primary, backup, delay)
+}
+
+// HedgeWithBudget 与 Hedge 相同，但发起备份请求前需要从预算中取出令牌，预算为 nil 表示不限制。
+// 主请求在 delay 之前失败时立即发起备份请求；两个请求都失败时返回包含两个错误的错误
+func HedgeWithBudget[T any](ctx context.Context, budget *HedgeBudget, primary, backup HedgeFunc[T], delay time.Duration) (T, error) {
+	var zero T
+	if primary == nil {
+		return zero, errors.New("主请求不能为空")
+	}
+	if backup == nil {
+		backup = primary
+	}
+	if budget != nil {
+		budget.deposit()
+	}
+
+	// 返回时取消仍在执行的请求
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// 缓冲区足够容纳两个结果，落败的请求返回后不会阻塞
+	results := make(chan hedgeResult[T], 2)
+	run := func(fn HedgeFunc[T], isBackup bool) {
+		go func() {
+			value, err := fn(ctx)
+			results <- hedgeResult[T]{value: value, err: err, backup: isBackup}
+		}()
+	}
+
+	run(primary, false)
+	pending := 1
+	hedged := false
+	hedge := func() {
+		hedged = true
+		if budget == nil || budget.withdraw() {
+			run(backup, true)
+			pending++
+		}
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var failures []error
+	for {
+		select {
+		case <-timer.C:
+			if !hedged {
+				hedge()
+			}
+		case result := <-results:
+			pending--
+			if result.err == nil {
+				if result.backup && budget != nil {
+					budget.recordBackupWin()
+				}
+				return result.value, nil
+			}
+			failures = append(failures, result.err)
+			if !hedged {
+				hedge()
+			}
+			if pending == 0 {
+				if len(failures) == 1 {
+					return zero, failures[0]
+				}
+				return zero, fmt.Errorf("主请求和备份请求均失败: %w; %w", failures[0], failures[1])
+			}
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		}
+	}
+}
+
+// 场景示例：从两个副本读取数据，个别副本偶尔出现长尾延迟
+func HedgeDemo() {
+	fmt.Println("请求对冲示例:")
+
+	rng := rand.New(rand.NewSource(7))
+	var rngMutex sync.Mutex
+	var calls, cancelled int64
+
+	// replica 模拟一次副本读取：95%的请求2~4ms，5%的请求遇到60ms的长尾
+	replica := func(ctx context.Context) (string, error) {
+		atomic.AddInt64(&calls, 1)
+		rngMutex.Lock()
+		latency := 2*time.Millisecond + time.Duration(rng.Int63n(int64(2*time.Millisecond)))
+		if rng.Float64() < 0.05 {
+			latency = 60 * time.Millisecond
+		}
+		rngMutex.Unlock()
+
+		select {
+		case <-time.After(latency):
+			return "value", nil
+		case <-ctx.Done():
+			atomic.AddInt64(&cancelled, 1)
+			return "", ctx.Err()
+		}
+	}
+
+	const requests, workers = 400, 8
+	// measure 并发执行请求，返回按升序排列的延迟
+	measure := func(call func(ctx context.Context) error) []time.Duration {
+		latencies := make([]time.Duration, requests)
+		var next int64 = -1
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := atomic.AddInt64(&next, 1); i < requests; i = atomic.AddInt64(&next, 1) {
+					start := time.Now()
+					if err := call(context.Background()); err != nil {
+						fmt.Printf("请求失败: %v\n", err)
+					}
+					latencies[i] = time.Since(start)
+				}
+			}()
+		}
+		wg.Wait()
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		return latencies
+	}
+
+	summarize := func(name string, latencies []time.Duration) {
+		quantile := func(q float64) time.Duration {
+			return latencies[int(q*float64(len(latencies)-1))].Round(100 * time.Microsecond)
+		}
+		// 落败的请求可能还在退出，计数器需要原子读取
+		total, aborted := atomic.SwapInt64(&calls, 0), atomic.SwapInt64(&cancelled, 0)
+		fmt.Printf("%-16s P50 %-8v P95 %-8v P99 %-8v 后端调用 %d 次（额外负载 %.1f%%），取消 %d 次\n",
+			name, quantile(0.5), quantile(0.95), quantile(0.99),
+			total, float64(total-requests)/requests*100, aborted)
+	}
+
+	// 不对冲
+	summarize("不对冲", measure(func(ctx context.Context) error {
+		_, err := replica(ctx)
+		return err
+	}))
+
+	// 对冲：6ms 未返回就向另一个副本发出备份请求
+	delay := 6 * time.Millisecond
+	summarize("对冲(不限预算)", measure(func(ctx context.Context) error {
+		_, err := Hedge(ctx, replica, replica, delay)
+		return err
+	}))
+
+	// 对冲预算只允许约2%的额外请求，部分长尾请求无法对冲
+	budget := NewHedgeBudget(0.02, 2)
+	summarize("对冲(预算2%)", measure(func(ctx context.Context) error {
+		_, err := HedgeWithBudget(ctx, budget, replica, replica, delay)
+		return err
+	}))
+	stats := budget.Stats()
+	fmt.Printf("\n对冲预算: 请求 %d 次，对冲 %d 次，预算不足放弃 %d 次，备份请求胜出 %d 次\n",
+		stats["requests"], stats["hedged"], stats["denied"], stats["backupWins"])
+
+	// 主请求快速失败时立即发起备份请求，不必等到延迟阈值
+	failing := func(ctx context.Context) (string, error) { return "", errors.New("副本A连接被拒绝") }
+	start := time.Now()
+	value, err := Hedge(context.Background(), failing, replica, time.Second)
+	fmt.Printf("\n主副本快速失败: 结果 %q，错误 %v，耗时 %v（未等待1s的对冲延迟）\n",
+		value, err, time.Since(start).Round(time.Millisecond))
+
+	// 两个副本都失败时返回两个错误
+	_, err = Hedge(context.Background(), failing, func(ctx context.Context) (string, error) {
+		return "", errors.New("副本B超时")
+	}, time.Millisecond)
+	fmt.Printf("两个副本都失败: %v\n", err)
+}
@@ -0,0 +1,362 @@
+package practical_applications
+
+/*
+容忍时钟偏差的租约
+
+原理：
+租约（Lease）是带有效期的锁：持有者在有效期内拥有独占权，到期不续约则自动失效，
+因此持有者崩溃后不需要人工介入就能转移所有权，常用于选主、分布式锁和会话保持。
+租约的正确性依赖时间，而不同机器的时钟既有偏移（offset）也有漂移（走快或走慢）：
+如果持有者的时钟走得慢，它会认为租约还没到期，而授予方已经把租约交给了别人，出现"双主"。
+解决办法是双方各留一个保护间隔：持有者提前放弃，授予方推迟转移，
+只要实际时钟误差不超过声明的上界，两段"自认为持有"的时间就不会重叠。
+
+关键特点：
+1. 显式声明时钟误差上界：最大时钟偏差（含网络延迟的不确定性）和最大漂移率
+2. 保护间隔 = 最大时钟偏差 + 租期 × 最大漂移率
+3. 持有者只用自己的单调时间计算已用时长，并在请求发出前记录起点，偏保守
+4. TimeRemainingSafe 返回扣除保护间隔后的剩余时间，小于等于0时不能再执行受保护的操作
+5. 每次授予生成递增的防护令牌（fencing token），存储层可以拒绝旧令牌的写入
+
+实现方式：
+- LeaseManager 作为授予方（类似协调服务），记录每个租约的持有者、令牌和到期时间
+- 授予方在到期后还要再等待一个保护间隔，才会把租约授予新的持有者
+- Lease 作为持有者一侧的句柄，提供续约、释放和安全剩余时间
+- 时钟通过函数注入，可以用模拟时钟精确复现时钟漂移
+
+应用场景：
+- 选主：只有持有租约的节点执行写入或调度
+- 分布式锁：锁自动过期，避免持有者崩溃导致死锁
+- 会话保持：客户端定期续约，超时则清理会话
+
+优缺点：
+- 优点：不依赖时钟同步精度，只要求误差有上界；防护令牌进一步防止停顿后恢复的旧持有者写入
+- 缺点：保护间隔降低了可用时间，故障转移时间也相应变长；上界设置过小时仍然可能出现重叠
+
+以下实现了授予方、持有者句柄和用于演示的模拟时钟；disaster_recovery.go 用它选择主数据中心。
+*/
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 租约相关错误
+var (
+	ErrLeaseHeld    = errors.New("租约被其他持有者占用")
+	ErrLeaseLost    = errors.New("租约已失效")
+	ErrStaleToken   = errors.New("防护令牌已过期")
+	ErrLeaseUnknown = errors.New("租约不存在")
+)
+
+// LeaseOptions 租约配置
+type LeaseOptions struct {
+	Duration     time.Duration    // 租期
+	MaxClockSkew time.Duration    // 最大时钟偏差（含消息延迟的不确定性）
+	MaxDriftRate float64          // 最大时钟漂移率，例如 0.01 表示每秒最多偏差 10ms
+	Clock        func() time.Time // 授予方的时钟，默认 time.Now
+}
+
+// DefaultLeaseOptions 默认租约配置
+var DefaultLeaseOptions = LeaseOptions{
+	Duration:     10 * time.Second,
+	MaxClockSkew: 200 * time.Millisecond,
+	MaxDriftRate: 0.01,
+	Clock:        time.Now,
+}
+
+// Guard 返回保护间隔
+func (o LeaseOptions) Guard() time.Duration {
+	return o.MaxClockSkew + time.Duration(float64(o.Duration)*o.MaxDriftRate)
+}
+
+// leaseRecord 授予方记录的租约状态
+type leaseRecord struct {
+	holder    string    // 当前持有者
+	token     uint64    // 防护令牌
+	expiresAt time.Time // 到期时间（授予方时钟）
+	released  bool      // 是否已主动释放
+}
+
+// LeaseManager 租约授予方
+type LeaseManager struct {
+	options LeaseOptions
+	leases  map[string]*leaseRecord
+	token   uint64 // 全局递增的防护令牌
+	mutex   sync.Mutex
+}
+
+// NewLeaseManager 创建租约授予方
+func NewLeaseManager(options LeaseOptions) (*LeaseManager, error) {
+	if options.Duration <= 0 {
+		return nil, errors.New("租期必须为正数")
+	}
+	if options.MaxClockSkew < 0 || options.MaxDriftRate < 0 || options.MaxDriftRate >= 1 {
+		return nil, fmt.Errorf("时钟误差上界无效: 偏差 %v，漂移率 %v", options.MaxClockSkew, options.MaxDriftRate)
+	}
+	if options.Guard() >= options.Duration {
+		return nil, fmt.Errorf("保护间隔 %v 不小于租期 %v，租约没有可用时间", options.Guard(), options.Duration)
+	}
+	if options.Clock == nil {
+		options.Clock = time.Now
+	}
+	return &LeaseManager{options: options, leases: make(map[string]*leaseRecord)}, nil
+}
+
+// Acquire 为 holder 获取名为 name 的租约，localClock 为持有者本地的时钟
+func (m *LeaseManager) Acquire(name, holder string, localClock func() time.Time) (*Lease, error) {
+	if localClock == nil {
+		localClock = time.Now
+	}
+	// 在请求发出前记录本地起点，网络延迟只会让持有者更早放弃
+	localStart := localClock()
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := m.options.Clock()
+	record, exists := m.leases[name]
+	if exists && !record.released && record.holder != holder {
+		// 到期后再等待一个保护间隔，确保旧持有者已经自认为失效
+		if availableAt := record.expiresAt.Add(m.options.Guard()); now.Before(availableAt) {
+			return nil, fmt.Errorf("%w: %s 持有 %s，%v 后可获取",
+				ErrLeaseHeld, record.holder, name, availableAt.Sub(now).Round(time.Millisecond))
+		}
+	}
+
+	m.token++
+	m.leases[name] = &leaseRecord{holder: holder, token: m.token, expiresAt: now.Add(m.options.Duration)}
+	return &Lease{
+		name:       name,
+		holder:     holder,
+		token:      m.token,
+		localStart: localStart,
+		localClock: localClock,
+		manager:    m,
+	}, nil
+}
+
+// CheckToken 校验防护令牌是否仍是该租约最新的令牌，存储层在写入前调用
+func (m *LeaseManager) CheckToken(name string, token uint64) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	record, exists := m.leases[name]
+	if !exists {
+		return ErrLeaseUnknown
+	}
+	if token < record.token {
+		return fmt.Errorf("%w: 令牌 %d，最新令牌 %d", ErrStaleToken, token, record.token)
+	}
+	return nil
+}
+
+// Holder 返回租约当前的持有者（按授予方时钟判断），没有持有者时返回空字符串
+func (m *LeaseManager) Holder(name string) string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	record, exists := m.leases[name]
+	if !exists || record.released || !m.options.Clock().Before(record.expiresAt) {
+		return ""
+	}
+	return record.holder
+}
+
+// Lease 持有者一侧的租约句柄
+type Lease struct {
+	name       string
+	holder     string
+	token      uint64
+	localStart time.Time        // 本次授予或续约请求发出时的本地时间
+	localClock func() time.Time // 持有者本地时钟
+	released   bool
+	manager    *LeaseManager
+	mutex      sync.Mutex
+}
+
+// Token 返回防护令牌
+func (l *Lease) Token() uint64 {
+	return l.token
+}
+
+// Holder 返回持有者
+func (l *Lease) Holder() string {
+	return l.holder
+}
+
+// TimeRemaining 返回不考虑时钟误差的剩余时间，仅用于对比，不应作为安全判断依据
+func (l *Lease) TimeRemaining() time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.released {
+		return 0
+	}
+	return l.manager.options.Duration - l.localClock().Sub(l.localStart)
+}
+
+// TimeRemainingSafe 返回扣除保护间隔后的剩余时间，小于等于0表示不能再认为自己持有租约
+func (l *Lease) TimeRemainingSafe() time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.released {
+		return 0
+	}
+	options := l.manager.options
+	// 本地时钟可能走慢，已用时长按最大漂移率放大
+	elapsed := time.Duration(float64(l.localClock().Sub(l.localStart)) * (1 + options.MaxDriftRate))
+	return options.Duration - options.MaxClockSkew - elapsed
+}
+
+// Valid 是否仍安全地持有租约
+func (l *Lease) Valid() bool {
+	return l.TimeRemainingSafe() > 0
+}
+
+// Renew 续约，成功后重新计算有效期；租约已经转移或过期时返回 ErrLeaseLost
+func (l *Lease) Renew() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.released {
+		return ErrLeaseLost
+	}
+	localStart := l.localClock()
+
+	m := l.manager
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := m.options.Clock()
+	record, exists := m.leases[l.name]
+	if !exists || record.token != l.token || record.released || !now.Before(record.expiresAt) {
+		return ErrLeaseLost
+	}
+	record.expiresAt = now.Add(m.options.Duration)
+	l.localStart = localStart
+	return nil
+}
+
+// Release 主动释放租约，其他持有者可以立即获取
+func (l *Lease) Release() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.released {
+		return
+	}
+	l.released = true
+
+	m := l.manager
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if record, exists := m.leases[l.name]; exists && record.token == l.token {
+		record.released = true
+	}
+}
+
+// SimulatedClock 模拟时钟，用于复现时钟漂移
+type SimulatedClock struct {
+	start time.Time
+	now   time.Time
+	mutex sync.Mutex
+}
+
+// NewSimulatedClock 创建模拟时钟
+func NewSimulatedClock(start time.Time) *SimulatedClock {
+	return &SimulatedClock{start: start, now: start}
+}
+
+// Now 返回当前模拟时间
+func (c *SimulatedClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// Advance 推进模拟时间
+func (c *SimulatedClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Skewed 返回一个相对真实时间有固定偏移和漂移的本地时钟，drift 为负数表示走慢
+func (c *SimulatedClock) Skewed(offset time.Duration, drift float64) func() time.Time {
+	return func() time.Time {
+		now := c.Now()
+		elapsed := now.Sub(c.start)
+		return now.Add(offset + time.Duration(float64(elapsed)*drift))
+	}
+}
+
+// 场景示例：选主时旧主时钟走慢，对比有无保护间隔
+func LeaseDemo() {
+	fmt.Println("容忍时钟偏差的租约示例:")
+
+	run := func(title string, options LeaseOptions) {
+		fmt.Printf("\n%s（租期 %v，保护间隔 %v）:\n", title, options.Duration, options.Guard())
+
+		clock := NewSimulatedClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+		options.Clock = clock.Now
+		manager, err := NewLeaseManager(options)
+		if err != nil {
+			fmt.Printf("  创建失败: %v\n", err)
+			return
+		}
+
+		// 节点A的时钟每秒慢50ms，并且在获得租约后发生长时间停顿，没有续约
+		clockA := clock.Skewed(-300*time.Millisecond, -0.05)
+		clockB := clock.Skewed(200*time.Millisecond, 0.02)
+		leaseA, _ := manager.Acquire("leader", "node-a", clockA)
+		fmt.Printf("  t=0s node-a 成为主节点，令牌 %d\n", leaseA.Token())
+
+		// 简单的存储：只接受最新令牌的写入
+		write := func(lease *Lease) string {
+			if err := manager.CheckToken("leader", lease.Token()); err != nil {
+				return "写入被拒绝: " + err.Error()
+			}
+			return "写入成功"
+		}
+
+		var leaseB *Lease
+		overlap := time.Duration(0)
+		step := 100 * time.Millisecond
+		for elapsed := step; elapsed <= 15*time.Second; elapsed += step {
+			clock.Advance(step)
+			if leaseB == nil {
+				if lease, err := manager.Acquire("leader", "node-b", clockB); err == nil {
+					leaseB = lease
+					fmt.Printf("  t=%v node-b 成为主节点，令牌 %d；此时 node-a 安全剩余 %v，不考虑误差时剩余 %v\n",
+						elapsed, lease.Token(), leaseA.TimeRemainingSafe().Round(time.Millisecond),
+						leaseA.TimeRemaining().Round(time.Millisecond))
+				}
+			}
+
+			// 保护间隔为0时 TimeRemainingSafe 等同于 TimeRemaining
+			if leaseB != nil && leaseB.Valid() && leaseA.Valid() {
+				overlap += step
+			}
+		}
+
+		if overlap > 0 {
+			fmt.Printf("  出现双主！两个节点同时认为自己是主节点的时间约 %v\n", overlap)
+		} else {
+			fmt.Println("  没有出现双主")
+		}
+		fmt.Printf("  node-a 恢复后尝试写入: %s\n", write(leaseA))
+		fmt.Printf("  node-a 续约: %v\n", leaseA.Renew())
+	}
+
+	naive := DefaultLeaseOptions
+	naive.MaxClockSkew, naive.MaxDriftRate = 0, 0
+	run("不考虑时钟误差", naive)
+
+	safe := DefaultLeaseOptions
+	safe.MaxClockSkew, safe.MaxDriftRate = 200*time.Millisecond, 0.1
+	run("声明误差上界：偏差200ms、漂移率10%", safe)
+}
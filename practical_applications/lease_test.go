@@ -0,0 +1,179 @@
+package practical_applications
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// newTestLeaseManager 用模拟时钟创建授予方：租期10秒，偏差200ms，漂移率10%，保护间隔1.2秒
+func newTestLeaseManager(t *testing.T) (*LeaseManager, *SimulatedClock) {
+	t.Helper()
+	clock := NewSimulatedClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	manager, err := NewLeaseManager(LeaseOptions{
+		Duration:     10 * time.Second,
+		MaxClockSkew: 200 * time.Millisecond,
+		MaxDriftRate: 0.1,
+		Clock:        clock.Now,
+	})
+	if err != nil {
+		t.Fatalf("创建授予方失败: %v", err)
+	}
+	return manager, clock
+}
+
+func TestLeaseOptionsGuard(t *testing.T) {
+	options := LeaseOptions{Duration: 10 * time.Second, MaxClockSkew: 200 * time.Millisecond, MaxDriftRate: 0.1}
+	if guard := options.Guard(); guard != 1200*time.Millisecond {
+		t.Errorf("保护间隔为 %v，期望 1.2s", guard)
+	}
+
+	// 保护间隔不小于租期时租约没有可用时间
+	options.MaxClockSkew = 9 * time.Second
+	if _, err := NewLeaseManager(options); err == nil {
+		t.Error("保护间隔不小于租期时应拒绝创建")
+	}
+	options.MaxClockSkew, options.MaxDriftRate = 0, 1
+	if _, err := NewLeaseManager(options); err == nil {
+		t.Error("漂移率不小于1时应拒绝创建")
+	}
+}
+
+func TestLeaseGuardDelaysTakeover(t *testing.T) {
+	manager, clock := newTestLeaseManager(t)
+	leaseA, err := manager.Acquire("leader", "node-a", clock.Now)
+	if err != nil {
+		t.Fatalf("node-a 获取租约失败: %v", err)
+	}
+
+	// 到期之后、保护间隔结束之前，其他持有者都不能获取
+	for _, elapsed := range []time.Duration{5 * time.Second, 10 * time.Second, 11190 * time.Millisecond} {
+		clock.Advance(elapsed - clock.Now().Sub(clock.start))
+		if _, err := manager.Acquire("leader", "node-b", clock.Now); !errors.Is(err, ErrLeaseHeld) {
+			t.Fatalf("t=%v node-b 获取租约应返回 ErrLeaseHeld，实际 %v", elapsed, err)
+		}
+	}
+	if holder := manager.Holder("leader"); holder != "" {
+		t.Errorf("租约到期后授予方仍认为 %q 持有", holder)
+	}
+
+	clock.Advance(10 * time.Millisecond)
+	leaseB, err := manager.Acquire("leader", "node-b", clock.Now)
+	if err != nil {
+		t.Fatalf("保护间隔结束后 node-b 获取租约失败: %v", err)
+	}
+	if leaseB.Token() <= leaseA.Token() {
+		t.Errorf("新令牌 %d 应大于旧令牌 %d", leaseB.Token(), leaseA.Token())
+	}
+	if err := manager.CheckToken("leader", leaseA.Token()); !errors.Is(err, ErrStaleToken) {
+		t.Errorf("旧令牌应被拒绝，实际 %v", err)
+	}
+	if err := leaseA.Renew(); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("租约转移后旧持有者续约应返回 ErrLeaseLost，实际 %v", err)
+	}
+}
+
+func TestLeaseTimeRemainingSafe(t *testing.T) {
+	manager, clock := newTestLeaseManager(t)
+	lease, _ := manager.Acquire("leader", "node-a", clock.Now)
+
+	// 刚获取时只扣除时钟偏差
+	if remaining := lease.TimeRemainingSafe(); remaining != 9800*time.Millisecond {
+		t.Errorf("安全剩余时间为 %v，期望 9.8s", remaining)
+	}
+
+	// 已用时长按最大漂移率放大：10s - 200ms - 5s×1.1
+	clock.Advance(5 * time.Second)
+	if remaining := lease.TimeRemaining(); remaining != 5*time.Second {
+		t.Errorf("剩余时间为 %v，期望 5s", remaining)
+	}
+	if remaining := lease.TimeRemainingSafe(); remaining != 4300*time.Millisecond {
+		t.Errorf("安全剩余时间为 %v，期望 4.3s", remaining)
+	}
+
+	// 续约后从续约请求发出的时刻重新计算
+	if err := lease.Renew(); err != nil {
+		t.Fatalf("续约失败: %v", err)
+	}
+	if remaining := lease.TimeRemainingSafe(); remaining != 9800*time.Millisecond {
+		t.Errorf("续约后安全剩余时间为 %v，期望 9.8s", remaining)
+	}
+
+	// 10s - 200ms = 9.8s = 已用时长×1.1，约8.909秒后不再安全
+	clock.Advance(8900 * time.Millisecond)
+	if !lease.Valid() {
+		t.Error("8.9秒时租约应仍然安全有效")
+	}
+	clock.Advance(10 * time.Millisecond)
+	if lease.Valid() {
+		t.Errorf("8.91秒时租约不应再被认为有效，安全剩余 %v", lease.TimeRemainingSafe())
+	}
+
+	lease.Release()
+	if remaining := lease.TimeRemainingSafe(); remaining != 0 {
+		t.Errorf("释放后安全剩余时间为 %v，期望0", remaining)
+	}
+}
+
+// 持有者的时钟在声明的误差上界内走慢时，新持有者获得租约的时刻旧持有者一定已经自认为失效
+func TestLeaseNoOverlapWithinSkewBounds(t *testing.T) {
+	cases := []struct {
+		name   string
+		offset time.Duration
+		drift  float64
+	}{
+		{"时钟准确", 0, 0},
+		{"走慢10%", 0, -0.1},
+		{"偏移-200ms且走慢10%", -200 * time.Millisecond, -0.1},
+		{"偏移+200ms且走快10%", 200 * time.Millisecond, 0.1},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			manager, clock := newTestLeaseManager(t)
+			leaseA, _ := manager.Acquire("leader", "node-a", clock.Skewed(c.offset, c.drift))
+
+			step := 10 * time.Millisecond
+			for elapsed := step; elapsed <= 15*time.Second; elapsed += step {
+				clock.Advance(step)
+				if _, err := manager.Acquire("leader", "node-b", clock.Now); err == nil {
+					if remaining := leaseA.TimeRemainingSafe(); remaining > 0 {
+						t.Fatalf("t=%v node-b 获得租约时 node-a 仍安全剩余 %v", elapsed, remaining)
+					}
+					return
+				}
+			}
+			t.Fatal("node-b 始终没有获得租约")
+		})
+	}
+}
+
+// 不声明时钟误差时，走慢的旧持有者在新持有者接管后仍认为自己持有租约
+func TestLeaseOverlapWithoutGuard(t *testing.T) {
+	clock := NewSimulatedClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	manager, err := NewLeaseManager(LeaseOptions{Duration: 10 * time.Second, Clock: clock.Now})
+	if err != nil {
+		t.Fatalf("创建授予方失败: %v", err)
+	}
+	leaseA, _ := manager.Acquire("leader", "node-a", clock.Skewed(0, -0.05))
+
+	clock.Advance(10 * time.Second)
+	if _, err := manager.Acquire("leader", "node-b", clock.Now); err != nil {
+		t.Fatalf("没有保护间隔时到期即可获取，实际 %v", err)
+	}
+	if remaining := leaseA.TimeRemainingSafe(); remaining <= 0 {
+		t.Errorf("走慢的 node-a 此时应仍认为持有租约，安全剩余 %v", remaining)
+	}
+}
+
+func TestLeaseReleaseAllowsImmediateTakeover(t *testing.T) {
+	manager, clock := newTestLeaseManager(t)
+	leaseA, _ := manager.Acquire("leader", "node-a", clock.Now)
+	leaseA.Release()
+
+	if _, err := manager.Acquire("leader", "node-b", clock.Now); err != nil {
+		t.Fatalf("主动释放后应可立即获取，实际 %v", err)
+	}
+	if err := leaseA.Renew(); !errors.Is(err, ErrLeaseLost) {
+		t.Errorf("释放后续约应返回 ErrLeaseLost，实际 %v", err)
+	}
+}
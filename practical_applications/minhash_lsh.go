@@ -0,0 +1,495 @@
+package practical_applications
+
+/*
+MinHash + LSH 近似重复检测
+
+原理：
+爬虫抓回的网页、用户发布的内容中有大量"几乎相同"的副本：转载时改了标题、加了一句广告、换了几个字。
+精确哈希只能发现完全相同的内容，逐对比较所有文档的 Jaccard 相似度又是 O(n²)。
+- Shingling：把文本切成长度为 k 的连续字符片段集合，两篇文档的相似度用集合的 Jaccard 系数衡量
+- MinHash：对集合应用 n 个随机哈希函数，每个函数取最小值组成签名；两个签名对应位置相等的比例
+  是 Jaccard 系数的无偏估计，签名长度固定，与文档长度无关
+- LSH 分段（banding）：把签名分成 b 段、每段 r 行，任意一段完全相同就成为候选对。
+  相似度为 s 的两篇文档成为候选的概率为 1 − (1 − s^r)^b，是一条以 (1/b)^(1/r) 为阈值的 S 形曲线，
+  相似的文档几乎一定落入同一个桶，不相似的文档几乎不会，只需要对候选对计算相似度
+
+关键特点：
+1. 签名大小固定（默认128个64位整数），与文档长度无关
+2. 根据目标阈值自动选择段数和每段行数
+3. 支持增量添加和删除，新文档到来时可以立即查询是否与已有文档重复
+4. 与布隆过滤器一样用可控的误差换取空间和时间
+
+实现方式：
+- 文本去掉空白和标点后按字符切成 k-shingle
+- 每个哈希函数用不同的种子对 shingle 的 FNV 哈希做 splitmix64 混合
+- 每段用一个哈希表把段的哈希值映射到文档ID列表
+
+应用场景：
+- 爬虫抓取结果去重
+- 推荐结果去除近似重复的内容
+- 抄袭检测、相似问题合并
+
+优缺点：
+- 优点：近似线性的时间复杂度，内存占用固定，阈值可调
+- 缺点：结果是概率性的，阈值附近存在漏报和误报；只考虑字面相似，不理解语义
+
+以下实现了 MinHash 签名和 LSH 分段索引，并与逐对精确比较进行对比。
+*/
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/strive/scenario/report"
+)
+
+// NearDuplicateOptions 近似重复检测选项
+type NearDuplicateOptions struct {
+	NumHashes   int     // MinHash 签名长度
+	Threshold   float64 // LSH 设计阈值，据此选择段数和每段行数
+	ShingleSize int     // shingle 的字符数
+	Seed        int64   // 哈希函数种子
+}
+
+// DefaultNearDuplicateOptions 默认的近似重复检测选项
+var DefaultNearDuplicateOptions = NearDuplicateOptions{
+	NumHashes:   128,
+	Threshold:   0.7,
+	ShingleSize: 3,
+	Seed:        1,
+}
+
+// DuplicatePair 一对近似重复的文档
+type DuplicatePair struct {
+	A, B       string  // 文档ID，A < B
+	Similarity float64 // 签名估计的 Jaccard 相似度
+}
+
+// DuplicateMatch 查询到的近似重复文档
+type DuplicateMatch struct {
+	ID         string
+	Similarity float64
+}
+
+// NearDuplicateDetector 基于 MinHash + LSH 的近似重复检测器
+type NearDuplicateDetector struct {
+	mu         sync.RWMutex
+	options    NearDuplicateOptions
+	bands      int                   // 段数
+	rows       int                   // 每段行数
+	seeds      []uint64              // 每个哈希函数的种子
+	signatures map[string][]uint64   // 文档ID -> 签名
+	buckets    []map[uint64][]string // 每段：段哈希 -> 文档ID
+}
+
+// NewNearDuplicateDetector 创建近似重复检测器
+func NewNearDuplicateDetector(options NearDuplicateOptions) *NearDuplicateDetector {
+	if options.NumHashes <= 0 {
+		options.NumHashes = DefaultNearDuplicateOptions.NumHashes
+	}
+	if options.Threshold <= 0 || options.Threshold >= 1 {
+		options.Threshold = DefaultNearDuplicateOptions.Threshold
+	}
+	if options.ShingleSize <= 0 {
+		options.ShingleSize = DefaultNearDuplicateOptions.ShingleSize
+	}
+
+	bands, rows := LSHBands(options.NumHashes, options.Threshold)
+	rng := rand.New(rand.NewSource(options.Seed))
+	seeds := make([]uint64, options.NumHashes)
+	for i := range seeds {
+		seeds[i] = rng.Uint64()
+	}
+	buckets := make([]map[uint64][]string, bands)
+	for i := range buckets {
+		buckets[i] = make(map[uint64][]string)
+	}
+
+	return &NearDuplicateDetector{
+		options:    options,
+		bands:      bands,
+		rows:       rows,
+		seeds:      seeds,
+		signatures: make(map[string][]uint64),
+		buckets:    buckets,
+	}
+}
+
+// LSHBands 在签名长度 numHashes 内选择段数和每段行数，使 S 形曲线的阈值 (1/b)^(1/r) 最接近 threshold
+func LSHBands(numHashes int, threshold float64) (bands, rows int) {
+	bestDiff := math.Inf(1)
+	for r := 1; r <= numHashes; r++ {
+		b := numHashes / r
+		diff := math.Abs(math.Pow(1/float64(b), 1/float64(r)) - threshold)
+		if diff < bestDiff {
+			bestDiff, bands, rows = diff, b, r
+		}
+	}
+	return bands, rows
+}
+
+// Shingles 把文本切成长度为 k 的字符片段集合，忽略空白、标点和大小写
+func Shingles(text string, k int) map[string]bool {
+	runes := make([]rune, 0, len(text))
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			runes = append(runes, r)
+		}
+	}
+
+	shingles := make(map[string]bool)
+	if len(runes) < k {
+		if len(runes) > 0 {
+			shingles[string(runes)] = true
+		}
+		return shingles
+	}
+	for i := 0; i+k <= len(runes); i++ {
+		shingles[string(runes[i:i+k])] = true
+	}
+	return shingles
+}
+
+// JaccardSimilarity 计算两个集合的 Jaccard 系数
+func JaccardSimilarity(a, b map[string]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	common := 0
+	for item := range a {
+		if b[item] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common)
+}
+
+// splitmix64 64位整数混合函数
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// Signature 计算文本的 MinHash 签名
+func (d *NearDuplicateDetector) Signature(text string) []uint64 {
+	signature := make([]uint64, d.options.NumHashes)
+	for i := range signature {
+		signature[i] = math.MaxUint64
+	}
+	h := fnv.New64a()
+	for shingle := range Shingles(text, d.options.ShingleSize) {
+		h.Reset()
+		h.Write([]byte(shingle))
+		base := h.Sum64()
+		for i, seed := range d.seeds {
+			if v := splitmix64(base ^ seed); v < signature[i] {
+				signature[i] = v
+			}
+		}
+	}
+	return signature
+}
+
+// SignatureSimilarity 用两个签名中相等位置的比例估计 Jaccard 系数
+func SignatureSimilarity(a, b []uint64) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	equal := 0
+	for i := range a {
+		if a[i] == b[i] {
+			equal++
+		}
+	}
+	return float64(equal) / float64(len(a))
+}
+
+// bandKey 计算签名第 band 段的哈希值
+func (d *NearDuplicateDetector) bandKey(signature []uint64, band int) uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	for _, v := range signature[band*d.rows : (band+1)*d.rows] {
+		binary.LittleEndian.PutUint64(buf[:], v)
+		h.Write(buf[:])
+	}
+	return h.Sum64()
+}
+
+// Add 添加文档，ID 已存在时替换原有内容
+func (d *NearDuplicateDetector) Add(id, text string) {
+	signature := d.Signature(text)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.removeLocked(id)
+	d.signatures[id] = signature
+	for band := 0; band < d.bands; band++ {
+		key := d.bandKey(signature, band)
+		d.buckets[band][key] = append(d.buckets[band][key], id)
+	}
+}
+
+// Remove 删除文档
+func (d *NearDuplicateDetector) Remove(id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.removeLocked(id)
+}
+
+// removeLocked 删除文档，调用方需持有写锁
+func (d *NearDuplicateDetector) removeLocked(id string) bool {
+	signature, ok := d.signatures[id]
+	if !ok {
+		return false
+	}
+	for band := 0; band < d.bands; band++ {
+		key := d.bandKey(signature, band)
+		ids := d.buckets[band][key]
+		for i, other := range ids {
+			if other == id {
+				ids = append(ids[:i], ids[i+1:]...)
+				break
+			}
+		}
+		if len(ids) == 0 {
+			delete(d.buckets[band], key)
+		} else {
+			d.buckets[band][key] = ids
+		}
+	}
+	delete(d.signatures, id)
+	return true
+}
+
+// Similarity 返回两篇已添加文档的估计相似度
+func (d *NearDuplicateDetector) Similarity(a, b string) (float64, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	sigA, okA := d.signatures[a]
+	sigB, okB := d.signatures[b]
+	if !okA || !okB {
+		return 0, false
+	}
+	return SignatureSimilarity(sigA, sigB), true
+}
+
+// Query 查找与文本近似重复的已有文档，按相似度从高到低排列
+func (d *NearDuplicateDetector) Query(text string, threshold float64) []DuplicateMatch {
+	signature := d.Signature(text)
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	seen := make(map[string]bool)
+	matches := make([]DuplicateMatch, 0)
+	for band := 0; band < d.bands; band++ {
+		for _, id := range d.buckets[band][d.bandKey(signature, band)] {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			if similarity := SignatureSimilarity(signature, d.signatures[id]); similarity >= threshold {
+				matches = append(matches, DuplicateMatch{ID: id, Similarity: similarity})
+			}
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Similarity != matches[j].Similarity {
+			return matches[i].Similarity > matches[j].Similarity
+		}
+		return matches[i].ID < matches[j].ID
+	})
+	return matches
+}
+
+// FindNearDuplicates 找出所有估计相似度不低于 threshold 的文档对，按相似度从高到低排列。
+// 只检查 LSH 候选对，threshold 明显低于设计阈值时会漏掉部分文档对
+func (d *NearDuplicateDetector) FindNearDuplicates(threshold float64) []DuplicatePair {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	checked := make(map[[2]string]bool)
+	pairs := make([]DuplicatePair, 0)
+	for _, bucket := range d.buckets {
+		for _, ids := range bucket {
+			for i := 0; i < len(ids); i++ {
+				for j := i + 1; j < len(ids); j++ {
+					a, b := ids[i], ids[j]
+					if b < a {
+						a, b = b, a
+					}
+					if checked[[2]string{a, b}] {
+						continue
+					}
+					checked[[2]string{a, b}] = true
+					if similarity := SignatureSimilarity(d.signatures[a], d.signatures[b]); similarity >= threshold {
+						pairs = append(pairs, DuplicatePair{A: a, B: b, Similarity: similarity})
+					}
+				}
+			}
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Similarity != pairs[j].Similarity {
+			return pairs[i].Similarity > pairs[j].Similarity
+		}
+		if pairs[i].A != pairs[j].A {
+			return pairs[i].A < pairs[j].A
+		}
+		return pairs[i].B < pairs[j].B
+	})
+	return pairs
+}
+
+// Len 返回文档数量
+func (d *NearDuplicateDetector) Len() int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return len(d.signatures)
+}
+
+// Stats 返回检测器统计信息
+func (d *NearDuplicateDetector) Stats() map[string]interface{} {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	buckets := 0
+	for _, bucket := range d.buckets {
+		buckets += len(bucket)
+	}
+	return map[string]interface{}{
+		"documents":     len(d.signatures),
+		"numHashes":     d.options.NumHashes,
+		"bands":         d.bands,
+		"rows":          d.rows,
+		"lshThreshold":  math.Pow(1/float64(d.bands), 1/float64(d.rows)),
+		"bucketEntries": buckets,
+	}
+}
+
+// generateCrawledPages 生成模拟的爬虫抓取结果，其中 dupRate 比例的页面是对已有页面的少量改写
+func generateCrawledPages(count int, dupRate float64, rng *rand.Rand) []string {
+	words := []string{"分布式", "缓存", "数据库", "索引", "一致性", "协程", "调度", "网络", "延迟", "吞吐",
+		"存储", "副本", "日志", "事务", "锁", "队列", "哈希", "压缩", "算法", "服务"}
+	pages := make([]string, 0, count)
+	for len(pages) < count {
+		if len(pages) > 0 && rng.Float64() < dupRate {
+			// 转载改写：替换少量词语并在末尾加一句
+			source := []rune(pages[rng.Intn(len(pages))])
+			for i := 0; i < 3; i++ {
+				pos := rng.Intn(len(source))
+				source[pos] = []rune(words[rng.Intn(len(words))])[0]
+			}
+			pages = append(pages, string(source)+"转载请注明出处")
+			continue
+		}
+		var b strings.Builder
+		for i := 0; i < 60; i++ {
+			b.WriteString(words[rng.Intn(len(words))])
+		}
+		pages = append(pages, b.String())
+	}
+	return pages
+}
+
+// 场景示例：爬虫抓取结果去重，比较 LSH 与逐对精确比较
+func MinHashLSHDemo() {
+	fmt.Println("MinHash + LSH 近似重复检测示例:")
+
+	rng := rand.New(rand.NewSource(42))
+	pages := generateCrawledPages(600, 0.1, rng)
+	const threshold = 0.7
+
+	// 逐对计算精确 Jaccard 系数作为基准
+	shingles := make([]map[string]bool, len(pages))
+	for i, page := range pages {
+		shingles[i] = Shingles(page, DefaultNearDuplicateOptions.ShingleSize)
+	}
+	truth := make(map[[2]string]bool)
+
+	comparison := report.NewComparison(
+		"近似重复检测对比",
+		fmt.Sprintf("%d 个网页，约10%%为改写转载，相似度阈值 %.1f", len(pages), threshold),
+		report.Metric{Name: "重复对", Precision: 0},
+		report.Metric{Name: "召回率(%)", Precision: 1},
+		report.Metric{Name: "候选对", LowerIsBetter: true, Precision: 0},
+	)
+	comparison.Measure("逐对精确比较", func() (map[string]float64, error) {
+		comparisons := 0
+		for i := 0; i < len(pages); i++ {
+			for j := i + 1; j < len(pages); j++ {
+				comparisons++
+				if JaccardSimilarity(shingles[i], shingles[j]) >= threshold {
+					truth[[2]string{pageID(i), pageID(j)}] = true
+				}
+			}
+		}
+		return map[string]float64{"重复对": float64(len(truth)), "召回率(%)": 100, "候选对": float64(comparisons)}, nil
+	})
+
+	detector := NewNearDuplicateDetector(DefaultNearDuplicateOptions)
+	comparison.Measure("MinHash+LSH", func() (map[string]float64, error) {
+		for i, page := range pages {
+			detector.Add(pageID(i), page)
+		}
+		pairs := detector.FindNearDuplicates(threshold)
+		found := 0
+		for _, pair := range pairs {
+			if truth[[2]string{pair.A, pair.B}] {
+				found++
+			}
+		}
+		recall := 100.0
+		if len(truth) > 0 {
+			recall = float64(found) / float64(len(truth)) * 100
+		}
+		candidates := make(map[[2]string]bool)
+		for _, bucket := range detector.buckets {
+			for _, ids := range bucket {
+				for i := 0; i < len(ids); i++ {
+					for j := i + 1; j < len(ids); j++ {
+						candidates[[2]string{ids[i], ids[j]}] = true
+					}
+				}
+			}
+		}
+		return map[string]float64{"重复对": float64(len(pairs)), "召回率(%)": recall, "候选对": float64(len(candidates))}, nil
+	})
+	comparison.Write(os.Stdout, report.FormatMarkdown)
+
+	stats := detector.Stats()
+	fmt.Printf("签名长度 %d，分为 %d 段 × %d 行，LSH 阈值约 %.2f\n",
+		stats["numHashes"], stats["bands"], stats["rows"], stats["lshThreshold"])
+
+	// 抓取过程中实时去重：新页面先查询，重复的不入库
+	fmt.Println("\n实时去重:")
+	crawler := NewNearDuplicateDetector(DefaultNearDuplicateOptions)
+	skipped := 0
+	for i, page := range generateCrawledPages(300, 0.2, rand.New(rand.NewSource(7))) {
+		if matches := crawler.Query(page, threshold); len(matches) > 0 {
+			if skipped < 3 {
+				fmt.Printf("  页面 %s 与 %s 相似度 %.2f，跳过\n", pageID(i), matches[0].ID, matches[0].Similarity)
+			}
+			skipped++
+			continue
+		}
+		crawler.Add(pageID(i), page)
+	}
+	fmt.Printf("  共抓取 300 个页面，入库 %d 个，跳过 %d 个近似重复页面\n", crawler.Len(), skipped)
+}
+
+// pageID 返回演示页面的标识
+func pageID(i int) string {
+	return fmt.Sprintf("page-%04d", i)
+}
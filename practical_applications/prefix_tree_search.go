@@ -41,6 +41,8 @@ import (
 	"sync"
 	"time"
 	"unicode"
+
+	"github.com/strive/scenario/sizeof"
 )
 
 // TrieNode 前缀树节点
@@ -276,6 +278,13 @@ func (t *Trie) Size() int {
 	return t.size
 }
 
+// MemoryUsage 返回深度估算的内存占用（字节）
+func (t *Trie) MemoryUsage() int64 {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return sizeof.Of(t)
+}
+
 // GetHotWords 获取热门单词
 func (t *Trie) GetHotWords(limit int) []Suggestion {
 	t.mutex.RLock()
@@ -0,0 +1,466 @@
+package practical_applications
+
+/*
+多租户配额管理 - 层级配额与预留
+
+原理：
+多租户平台需要同时限制"用得多快"（速率）和"总共用多少"（绝对用量），并且配额是分层的：
+租户下有多个项目，项目下有多个用户，每一层都可以设置上限，下层的用量同时计入所有上层。
+创建虚拟机、申请GPU这类操作通常分两步：先预留资源，操作成功后确认（提交），失败则释放，
+这样并发申请时不会出现"都检查通过、合起来却超额"的问题。
+
+关键特点：
+1. 层级配额：路径形如 租户/项目/用户，用量沿路径向上累加，任一层超额都会拒绝
+2. 预留-提交-释放：预留时占用额度，提交时转为实际用量（可以少于预留量），释放时归还
+3. 预留超时：长时间未提交的预留自动过期归还，避免客户端崩溃造成额度泄漏
+4. 速率与用量一起检查：路径上配置了速率限制的层级同时经过按键限流器
+
+实现方式：
+- 每个路径节点记录各资源的上限、已用量和预留量，并指向父节点
+- 预留时先检查路径上所有节点的额度，再检查速率限制，全部通过后才修改状态
+- 过期的预留在预留、提交、释放和查询时惰性清理
+
+应用场景：
+- 云平台的CPU、内存、GPU、存储配额
+- SaaS 服务按租户、项目、用户限制API调用和资源数量
+- 内部平台的资源池分配
+
+优缺点：
+- 优点：并发安全，层级关系清晰，额度不会因客户端异常而泄漏
+- 缺点：上层配额允许超卖（子节点配额之和可以大于父节点），需要运营方自行规划；
+  速率限制在额度检查之后扣减，被额度拒绝或被任一层速率限制拒绝的请求都不消耗令牌
+
+以下实现了一个支持层级配额和预留的配额管理器。
+*/
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 配额相关错误
+var (
+	ErrQuotaExceeded       = errors.New("超出配额")
+	ErrQuotaRateLimited    = errors.New("请求过于频繁")
+	ErrReservationNotFound = errors.New("预留不存在或已过期")
+)
+
+// QuotaPathSeparator 配额路径分隔符
+const QuotaPathSeparator = "/"
+
+// QuotaOptions 配额管理器配置
+type QuotaOptions struct {
+	ReservationTTL time.Duration // 预留的有效期，超时未提交自动释放
+	DefaultRate    int64         // 按键限流器的默认速率（每秒），只对配置了速率限制的路径生效
+	DefaultBurst   int64         // 按键限流器的默认突发容量
+}
+
+// DefaultQuotaOptions 默认配额管理器配置
+var DefaultQuotaOptions = QuotaOptions{
+	ReservationTTL: 30 * time.Second,
+	DefaultRate:    100,
+	DefaultBurst:   100,
+}
+
+// QuotaUsage 某个路径上一种资源的使用情况
+type QuotaUsage struct {
+	Limit     int64 // 上限，-1表示不限制
+	Used      int64 // 已用量
+	Reserved  int64 // 预留量
+	Available int64 // 可用量，不限制时为-1
+}
+
+// quotaNode 配额树节点
+type quotaNode struct {
+	path        string
+	parent      *quotaNode
+	limits      map[string]int64 // 资源 -> 上限
+	used        map[string]int64 // 资源 -> 已用量（包含子节点）
+	reserved    map[string]int64 // 资源 -> 预留量（包含子节点）
+	rateLimited bool             // 是否配置了速率限制
+}
+
+// chain 返回从当前节点到根节点的路径
+func (n *quotaNode) chain() []*quotaNode {
+	var nodes []*quotaNode
+	for node := n; node != nil; node = node.parent {
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// Reservation 一次资源预留
+type Reservation struct {
+	ID        uint64    // 预留ID
+	Path      string    // 申请路径
+	Resource  string    // 资源名称
+	Amount    int64     // 预留数量
+	ExpiresAt time.Time // 过期时间
+	manager   *QuotaManager
+}
+
+// QuotaManager 层级配额管理器
+type QuotaManager struct {
+	options      QuotaOptions
+	nodes        map[string]*quotaNode
+	reservations map[uint64]*Reservation
+	limiter      *KeyedRateLimiter
+	nextID       uint64
+	stats        map[string]int64
+	mutex        sync.Mutex
+}
+
+// NewQuotaManager 创建配额管理器
+func NewQuotaManager(options QuotaOptions) *QuotaManager {
+	if options.ReservationTTL <= 0 {
+		options.ReservationTTL = DefaultQuotaOptions.ReservationTTL
+	}
+	if options.DefaultRate <= 0 {
+		options.DefaultRate = DefaultQuotaOptions.DefaultRate
+	}
+	return &QuotaManager{
+		options:      options,
+		nodes:        make(map[string]*quotaNode),
+		reservations: make(map[uint64]*Reservation),
+		limiter:      NewKeyedRateLimiter(options.DefaultRate, options.DefaultBurst),
+		stats:        make(map[string]int64),
+	}
+}
+
+// nodeLocked 返回路径对应的节点，不存在时连同父节点一起创建（调用方需持有锁）
+func (qm *QuotaManager) nodeLocked(path string) (*quotaNode, error) {
+	path = strings.Trim(path, QuotaPathSeparator)
+	if path == "" {
+		return nil, errors.New("配额路径不能为空")
+	}
+	if node, exists := qm.nodes[path]; exists {
+		return node, nil
+	}
+
+	var parent *quotaNode
+	parts := strings.Split(path, QuotaPathSeparator)
+	for i := range parts {
+		current := strings.Join(parts[:i+1], QuotaPathSeparator)
+		node, exists := qm.nodes[current]
+		if !exists {
+			node = &quotaNode{
+				path:     current,
+				parent:   parent,
+				limits:   make(map[string]int64),
+				used:     make(map[string]int64),
+				reserved: make(map[string]int64),
+			}
+			qm.nodes[current] = node
+		}
+		parent = node
+	}
+	return parent, nil
+}
+
+// SetQuota 设置路径上某种资源的上限，limit 为负数表示取消限制
+func (qm *QuotaManager) SetQuota(path, resource string, limit int64) error {
+	qm.mutex.Lock()
+	defer qm.mutex.Unlock()
+
+	node, err := qm.nodeLocked(path)
+	if err != nil {
+		return err
+	}
+	if limit < 0 {
+		delete(node.limits, resource)
+	} else {
+		node.limits[resource] = limit
+	}
+	return nil
+}
+
+// SetRateLimit 设置路径的请求速率限制，该路径及其子路径的每次预留都会消耗一个令牌
+func (qm *QuotaManager) SetRateLimit(path string, rate, burst int64) error {
+	qm.mutex.Lock()
+	defer qm.mutex.Unlock()
+
+	node, err := qm.nodeLocked(path)
+	if err != nil {
+		return err
+	}
+	node.rateLimited = true
+	qm.limiter.SetLimit(node.path, rate, burst)
+	return nil
+}
+
+// expireLocked 释放已过期的预留（调用方需持有锁）
+func (qm *QuotaManager) expireLocked(now time.Time) {
+	for id, r := range qm.reservations {
+		if now.After(r.ExpiresAt) {
+			qm.releaseLocked(r)
+			delete(qm.reservations, id)
+			qm.stats["expired"]++
+		}
+	}
+}
+
+// releaseLocked 归还预留的额度（调用方需持有锁）
+func (qm *QuotaManager) releaseLocked(r *Reservation) {
+	for _, node := range qm.nodes[r.Path].chain() {
+		node.reserved[r.Resource] -= r.Amount
+	}
+}
+
+// Reserve 在路径上预留资源，路径上任一层级超出配额或速率限制都会失败
+func (qm *QuotaManager) Reserve(path, resource string, amount int64) (*Reservation, error) {
+	if amount <= 0 {
+		return nil, fmt.Errorf("预留数量必须为正数: %d", amount)
+	}
+
+	qm.mutex.Lock()
+	defer qm.mutex.Unlock()
+
+	now := time.Now()
+	qm.expireLocked(now)
+
+	node, err := qm.nodeLocked(path)
+	if err != nil {
+		return nil, err
+	}
+	chain := node.chain()
+
+	// 1. 检查路径上每一层的绝对用量
+	for _, n := range chain {
+		limit, limited := n.limits[resource]
+		if limited && n.used[resource]+n.reserved[resource]+amount > limit {
+			qm.stats["rejectedByQuota"]++
+			return nil, fmt.Errorf("%w: %s 的 %s 上限 %d，已用 %d，预留 %d，申请 %d",
+				ErrQuotaExceeded, n.path, resource, limit, n.used[resource], n.reserved[resource], amount)
+		}
+	}
+
+	// 2. 检查路径上配置了速率限制的层级，任一层拒绝时归还已经扣掉的令牌
+	taken := make([]*TokenBucket, 0, len(chain))
+	for _, n := range chain {
+		if !n.rateLimited {
+			continue
+		}
+		bucket := qm.limiter.Limiter(n.path)
+		if !bucket.Allow() {
+			for _, b := range taken {
+				b.CancelN(1)
+			}
+			qm.stats["rejectedByRate"]++
+			return nil, fmt.Errorf("%w: %s", ErrQuotaRateLimited, n.path)
+		}
+		taken = append(taken, bucket)
+	}
+
+	// 3. 占用额度
+	for _, n := range chain {
+		n.reserved[resource] += amount
+	}
+	qm.nextID++
+	reservation := &Reservation{
+		ID:        qm.nextID,
+		Path:      node.path,
+		Resource:  resource,
+		Amount:    amount,
+		ExpiresAt: now.Add(qm.options.ReservationTTL),
+		manager:   qm,
+	}
+	qm.reservations[reservation.ID] = reservation
+	qm.stats["reserved"]++
+	return reservation, nil
+}
+
+// Commit 确认预留，actual 为实际使用量（不超过预留量），多余部分归还
+func (r *Reservation) Commit(actual int64) error {
+	qm := r.manager
+	qm.mutex.Lock()
+	defer qm.mutex.Unlock()
+
+	qm.expireLocked(time.Now())
+	if _, exists := qm.reservations[r.ID]; !exists {
+		return fmt.Errorf("%w: #%d", ErrReservationNotFound, r.ID)
+	}
+	if actual < 0 || actual > r.Amount {
+		return fmt.Errorf("实际使用量 %d 必须在 0 到预留量 %d 之间", actual, r.Amount)
+	}
+
+	for _, node := range qm.nodes[r.Path].chain() {
+		node.reserved[r.Resource] -= r.Amount
+		node.used[r.Resource] += actual
+	}
+	delete(qm.reservations, r.ID)
+	qm.stats["committed"]++
+	return nil
+}
+
+// Release 取消预留，归还全部额度
+func (r *Reservation) Release() error {
+	qm := r.manager
+	qm.mutex.Lock()
+	defer qm.mutex.Unlock()
+
+	qm.expireLocked(time.Now())
+	if _, exists := qm.reservations[r.ID]; !exists {
+		return fmt.Errorf("%w: #%d", ErrReservationNotFound, r.ID)
+	}
+	qm.releaseLocked(r)
+	delete(qm.reservations, r.ID)
+	qm.stats["released"]++
+	return nil
+}
+
+// Free 归还已使用的资源，例如删除虚拟机后归还CPU
+func (qm *QuotaManager) Free(path, resource string, amount int64) error {
+	qm.mutex.Lock()
+	defer qm.mutex.Unlock()
+
+	node, exists := qm.nodes[strings.Trim(path, QuotaPathSeparator)]
+	if !exists {
+		return fmt.Errorf("配额路径 %s 不存在", path)
+	}
+	if amount <= 0 || amount > node.used[resource] {
+		return fmt.Errorf("归还数量 %d 无效，%s 的 %s 已用 %d", amount, node.path, resource, node.used[resource])
+	}
+	for _, n := range node.chain() {
+		n.used[resource] -= amount
+	}
+	return nil
+}
+
+// Usage 返回路径上各资源的使用情况
+func (qm *QuotaManager) Usage(path string) map[string]QuotaUsage {
+	qm.mutex.Lock()
+	defer qm.mutex.Unlock()
+
+	qm.expireLocked(time.Now())
+	node, exists := qm.nodes[strings.Trim(path, QuotaPathSeparator)]
+	if !exists {
+		return nil
+	}
+
+	resources := make(map[string]bool)
+	for _, m := range []map[string]int64{node.limits, node.used, node.reserved} {
+		for resource := range m {
+			resources[resource] = true
+		}
+	}
+
+	usage := make(map[string]QuotaUsage, len(resources))
+	for resource := range resources {
+		u := QuotaUsage{Limit: -1, Used: node.used[resource], Reserved: node.reserved[resource], Available: -1}
+		if limit, limited := node.limits[resource]; limited {
+			u.Limit = limit
+			u.Available = limit - u.Used - u.Reserved
+		}
+		usage[resource] = u
+	}
+	return usage
+}
+
+// Stats 获取配额管理器统计信息
+func (qm *QuotaManager) Stats() map[string]interface{} {
+	qm.mutex.Lock()
+	defer qm.mutex.Unlock()
+
+	rateLimited := 0
+	for _, node := range qm.nodes {
+		if node.rateLimited {
+			rateLimited++
+		}
+	}
+	stats := map[string]interface{}{
+		"paths":              len(qm.nodes),
+		"activeReservations": len(qm.reservations),
+		"rateLimitedPaths":   rateLimited,
+		"reservationTTL":     qm.options.ReservationTTL.String(),
+	}
+	for key, value := range qm.stats {
+		stats[key] = value
+	}
+	return stats
+}
+
+// 场景示例：多租户平台运营方为租户、项目、用户分配GPU配额
+func QuotaManagerDemo() {
+	fmt.Println("多租户配额管理示例:")
+
+	options := DefaultQuotaOptions
+	options.ReservationTTL = 50 * time.Millisecond
+	qm := NewQuotaManager(options)
+
+	// 租户 acme 共 8 块GPU，其中 ml 项目最多 6 块，alice 最多 4 块
+	qm.SetQuota("acme", "gpu", 8)
+	qm.SetQuota("acme/ml", "gpu", 6)
+	qm.SetQuota("acme/ml/alice", "gpu", 4)
+	// 每个用户每秒最多申请 3 次，整个租户每秒最多 20 次
+	qm.SetRateLimit("acme/ml/alice", 3, 3)
+	qm.SetRateLimit("acme", 20, 20)
+
+	printUsage := func() {
+		for _, path := range []string{"acme", "acme/ml", "acme/ml/alice", "acme/ml/bob"} {
+			u := qm.Usage(path)["gpu"]
+			limit := "不限"
+			if u.Limit >= 0 {
+				limit = fmt.Sprintf("%d", u.Limit)
+			}
+			fmt.Printf("    %-14s 上限 %-4s 已用 %d  预留 %d\n", path, limit, u.Used, u.Reserved)
+		}
+	}
+
+	try := func(path string, amount int64) *Reservation {
+		r, err := qm.Reserve(path, "gpu", amount)
+		if err != nil {
+			fmt.Printf("  %s 申请 %d 块GPU: 失败 - %v\n", path, amount, err)
+			return nil
+		}
+		fmt.Printf("  %s 申请 %d 块GPU: 预留成功 #%d\n", path, amount, r.ID)
+		return r
+	}
+
+	fmt.Println("\n1. 预留后提交：")
+	if r := try("acme/ml/alice", 3); r != nil {
+		r.Commit(2) // 实际只启动了2块，多余的归还
+	}
+	if r := try("acme/ml/bob", 3); r != nil {
+		r.Commit(3)
+	}
+	printUsage()
+
+	fmt.Println("\n2. 超出各层级配额：")
+	try("acme/ml/alice", 3) // alice 自身上限 4，已用 2
+	try("acme/ml/bob", 2)   // ml 项目上限 6，已用 5
+	try("acme/web", 2)      // 租户上限 8，剩余 3，web 项目没有单独限制
+	printUsage()
+
+	fmt.Println("\n3. 预留超时自动归还：")
+	pending := try("acme/web", 1)
+	time.Sleep(options.ReservationTTL * 2)
+	if pending != nil {
+		if err := pending.Commit(1); err != nil {
+			fmt.Printf("  超时后提交: %v\n", err)
+		}
+	}
+	printUsage()
+
+	fmt.Println("\n4. 速率限制与用量限制同时生效：")
+	qm.Free("acme/ml/alice", "gpu", 2)
+	for i := 0; i < 5; i++ {
+		if r := try("acme/ml/alice", 1); r != nil {
+			r.Release()
+		}
+	}
+
+	fmt.Println("\n配额管理器统计:")
+	stats := qm.Stats()
+	keys := make([]string, 0, len(stats))
+	for key := range stats {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Printf("  %s: %v\n", key, stats[key])
+	}
+}
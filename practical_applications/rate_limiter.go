@@ -17,6 +17,8 @@ package practical_applications
 实现方式：
 - 令牌桶：使用计时器定期添加令牌，使用原子操作进行令牌计数
 - 漏桶：使用队列和定时器实现固定处理速率
+- 请求数、通过数、被限制数使用分段原子计数器（concurrency.StripedCounter），每个请求都要累加，避免计数器成为热点
+- 限流只保护下游不被突发流量压垮，下游故障时仍会把请求发过去；示例中在令牌桶之后串联熔断器（concurrency.CircuitBreaker）形成分层保护
 
 应用场景：
 - API访问频率控制
@@ -33,12 +35,17 @@ package practical_applications
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
-	"sync/atomic"
 	"time"
+
+	"github.com/strive/scenario/concurrency"
 )
 
+// ErrLimiterStopped 限流器已停止，等待中的请求不会再被放行
+var ErrLimiterStopped = errors.New("限流器已停止")
+
 // RateLimiter 限流器接口
 type RateLimiter interface {
 	// Allow 判断当前请求是否允许通过
@@ -55,14 +62,14 @@ type RateLimiter interface {
 
 // TokenBucket 令牌桶限流器
 type TokenBucket struct {
-	rate           int64      // 令牌生成速率（每秒）
-	capacity       int64      // 桶容量
-	tokens         int64      // 当前令牌数
-	lastRefillTime int64      // 上次令牌补充时间（Unix纳秒）
-	mutex          sync.Mutex // 互斥锁
-	accessCount    int64      // 请求总数
-	limitedCount   int64      // 被限制的请求数
-	passedCount    int64      // 通过的请求数
+	rate           int64                      // 令牌生成速率（每秒）
+	capacity       int64                      // 桶容量
+	tokens         int64                      // 当前令牌数
+	lastRefillTime int64                      // 上次令牌补充时间（Unix纳秒）
+	mutex          sync.Mutex                 // 互斥锁
+	accessCount    concurrency.StripedCounter // 请求总数
+	limitedCount   concurrency.StripedCounter // 被限制的请求数
+	passedCount    concurrency.StripedCounter // 通过的请求数
 }
 
 // NewTokenBucket 创建新的令牌桶限流器
@@ -110,7 +117,7 @@ func (tb *TokenBucket) AllowN(n int64) bool {
 		return true
 	}
 
-	atomic.AddInt64(&tb.accessCount, 1)
+	tb.accessCount.Inc()
 	tb.refillTokens()
 
 	tb.mutex.Lock()
@@ -118,11 +125,11 @@ func (tb *TokenBucket) AllowN(n int64) bool {
 
 	if tb.tokens >= n {
 		tb.tokens -= n
-		atomic.AddInt64(&tb.passedCount, 1)
+		tb.passedCount.Inc()
 		return true
 	}
 
-	atomic.AddInt64(&tb.limitedCount, 1)
+	tb.limitedCount.Inc()
 	return false
 }
 
@@ -137,26 +144,27 @@ func (tb *TokenBucket) WaitN(ctx context.Context, n int64) error {
 		return nil
 	}
 
-	atomic.AddInt64(&tb.accessCount, 1)
+	tb.accessCount.Inc()
 
 	for {
 		select {
 		case <-ctx.Done():
-			atomic.AddInt64(&tb.limitedCount, 1)
+			tb.limitedCount.Inc()
 			return ctx.Err()
 		default:
 			tb.refillTokens()
 			tb.mutex.Lock()
 			if tb.tokens >= n {
 				tb.tokens -= n
-				atomic.AddInt64(&tb.passedCount, 1)
+				tb.passedCount.Inc()
 				tb.mutex.Unlock()
 				return nil
 			}
+			missing, rate := n-tb.tokens, tb.rate
 			tb.mutex.Unlock()
 
 			// 计算等待时间
-			waitTime := time.Duration(float64(n-tb.tokens) / float64(tb.rate) * float64(time.Second))
+			waitTime := time.Duration(float64(missing) / float64(rate) * float64(time.Second))
 			if waitTime < time.Millisecond {
 				waitTime = time.Millisecond
 			}
@@ -166,7 +174,7 @@ func (tb *TokenBucket) WaitN(ctx context.Context, n int64) error {
 			select {
 			case <-ctx.Done():
 				timer.Stop()
-				atomic.AddInt64(&tb.limitedCount, 1)
+				tb.limitedCount.Inc()
 				return ctx.Err()
 			case <-timer.C:
 				// 继续尝试获取令牌
@@ -185,23 +193,63 @@ func (tb *TokenBucket) GetStats() map[string]interface{} {
 		"rate":         tb.rate,
 		"capacity":     tb.capacity,
 		"current":      tb.tokens,
-		"accessCount":  atomic.LoadInt64(&tb.accessCount),
-		"passedCount":  atomic.LoadInt64(&tb.passedCount),
-		"limitedCount": atomic.LoadInt64(&tb.limitedCount),
+		"accessCount":  tb.accessCount.Sum(),
+		"passedCount":  tb.passedCount.Sum(),
+		"limitedCount": tb.limitedCount.Sum(),
+	}
+}
+
+// SetRate 动态调整令牌生成速率，常用于根据服务质量收紧或放宽限流
+func (tb *TokenBucket) SetRate(rate int64) {
+	if rate <= 0 {
+		rate = 1
+	}
+	// 先按旧速率补充令牌，避免新速率作用到调整之前的时间段
+	tb.refillTokens()
+
+	tb.mutex.Lock()
+	defer tb.mutex.Unlock()
+	tb.rate = rate
+}
+
+// Rate 返回当前令牌生成速率
+func (tb *TokenBucket) Rate() int64 {
+	tb.mutex.Lock()
+	defer tb.mutex.Unlock()
+	return tb.rate
+}
+
+// Capacity 返回桶容量，即一次最多能获取的令牌数
+func (tb *TokenBucket) Capacity() int64 {
+	return tb.capacity
+}
+
+// CancelN 归还之前通过 AllowN 取得的 n 个令牌（不超过桶容量），该次请求改记为被限制；
+// 用于同一请求要经过多个限流器的场景：后面的限流器拒绝时，把前面已经扣掉的令牌还回去
+func (tb *TokenBucket) CancelN(n int64) {
+	if n <= 0 {
+		return
 	}
+	tb.mutex.Lock()
+	defer tb.mutex.Unlock()
+	tb.tokens = min(tb.capacity, tb.tokens+n)
+	tb.passedCount.Add(-1)
+	tb.limitedCount.Inc()
 }
 
 // LeakyBucket 漏桶限流器
 type LeakyBucket struct {
-	rate         int64          // 漏出速率（每秒）
-	capacity     int64          // 桶容量
-	water        int64          // 当前水量
-	lastLeakTime int64          // 上次漏水时间（Unix纳秒）
-	mutex        sync.Mutex     // 互斥锁
-	waiters      *PriorityQueue // 等待队列
-	accessCount  int64          // 请求总数
-	limitedCount int64          // 被限制的请求数
-	passedCount  int64          // 通过的请求数
+	rate         int64                      // 漏出速率（每秒）
+	capacity     int64                      // 桶容量
+	water        int64                      // 当前水量
+	lastLeakTime int64                      // 上次漏水时间（Unix纳秒）
+	mutex        sync.Mutex                 // 互斥锁
+	waiters      *PriorityQueue             // 等待队列
+	accessCount  concurrency.StripedCounter // 请求总数
+	limitedCount concurrency.StripedCounter // 被限制的请求数
+	passedCount  concurrency.StripedCounter // 通过的请求数
+	stopCh       chan struct{}              // 停止漏水协程的通道
+	stopOnce     sync.Once                  // 保证只停止一次
 }
 
 // Waiter 等待请求
@@ -264,6 +312,7 @@ func NewLeakyBucket(rate, capacity int64) *LeakyBucket {
 		water:        0, // 初始状态桶是空的
 		lastLeakTime: time.Now().UnixNano(),
 		waiters:      NewPriorityQueue(),
+		stopCh:       make(chan struct{}),
 	}
 
 	// 启动漏水协程
@@ -277,12 +326,24 @@ func (lb *LeakyBucket) leakingProcess() {
 	ticker := time.NewTicker(time.Millisecond * 100)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		lb.leak()
-		lb.checkWaiters()
+	for {
+		select {
+		case <-ticker.C:
+			lb.leak()
+			lb.checkWaiters()
+		case <-lb.stopCh:
+			return
+		}
 	}
 }
 
+// Stop 停止漏水协程，可重复调用；正在 Wait 的请求返回 ErrLimiterStopped
+func (lb *LeakyBucket) Stop() {
+	lb.stopOnce.Do(func() {
+		close(lb.stopCh)
+	})
+}
+
 // leak 漏水
 func (lb *LeakyBucket) leak() {
 	now := time.Now().UnixNano()
@@ -319,6 +380,11 @@ func (lb *LeakyBucket) checkWaiters() {
 	}
 }
 
+// Capacity 返回桶容量，即一次最多能放入的请求数
+func (lb *LeakyBucket) Capacity() int64 {
+	return lb.capacity
+}
+
 // Allow 判断当前请求是否允许通过
 func (lb *LeakyBucket) Allow() bool {
 	return lb.AllowN(1)
@@ -330,7 +396,7 @@ func (lb *LeakyBucket) AllowN(n int64) bool {
 		return true
 	}
 
-	atomic.AddInt64(&lb.accessCount, 1)
+	lb.accessCount.Inc()
 	lb.leak()
 
 	lb.mutex.Lock()
@@ -338,11 +404,11 @@ func (lb *LeakyBucket) AllowN(n int64) bool {
 
 	if lb.water+n <= lb.capacity {
 		lb.water += n
-		atomic.AddInt64(&lb.passedCount, 1)
+		lb.passedCount.Inc()
 		return true
 	}
 
-	atomic.AddInt64(&lb.limitedCount, 1)
+	lb.limitedCount.Inc()
 	return false
 }
 
@@ -351,19 +417,19 @@ func (lb *LeakyBucket) Wait(ctx context.Context) error {
 	return lb.WaitN(ctx, 1)
 }
 
-// WaitN 等待直到有N个空间或上下文取消
+// WaitN 等待直到有N个空间或上下文取消；漏桶停止后不再漏水，等待中的请求返回 ErrLimiterStopped
 func (lb *LeakyBucket) WaitN(ctx context.Context, n int64) error {
 	if n <= 0 {
 		return nil
 	}
 
-	atomic.AddInt64(&lb.accessCount, 1)
+	lb.accessCount.Inc()
 	lb.leak()
 
 	lb.mutex.Lock()
 	if lb.water+n <= lb.capacity {
 		lb.water += n
-		atomic.AddInt64(&lb.passedCount, 1)
+		lb.passedCount.Inc()
 		lb.mutex.Unlock()
 		return nil
 	}
@@ -379,14 +445,17 @@ func (lb *LeakyBucket) WaitN(ctx context.Context, n int64) error {
 	// 添加到等待队列
 	lb.waiters.Push(waiter)
 
-	// 等待信号或上下文取消
+	// 等待信号、上下文取消或漏桶停止
 	select {
 	case <-readyCh:
-		atomic.AddInt64(&lb.passedCount, 1)
+		lb.passedCount.Inc()
 		return nil
 	case <-ctx.Done():
-		atomic.AddInt64(&lb.limitedCount, 1)
+		lb.limitedCount.Inc()
 		return ctx.Err()
+	case <-lb.stopCh:
+		lb.limitedCount.Inc()
+		return ErrLimiterStopped
 	}
 }
 
@@ -401,12 +470,78 @@ func (lb *LeakyBucket) GetStats() map[string]interface{} {
 		"capacity":     lb.capacity,
 		"current":      lb.water,
 		"waiting":      lb.waiters.Len(),
-		"accessCount":  atomic.LoadInt64(&lb.accessCount),
-		"passedCount":  atomic.LoadInt64(&lb.passedCount),
-		"limitedCount": atomic.LoadInt64(&lb.limitedCount),
+		"accessCount":  lb.accessCount.Sum(),
+		"passedCount":  lb.passedCount.Sum(),
+		"limitedCount": lb.limitedCount.Sum(),
 	}
 }
 
+// KeyedRateLimiter 按键限流器：每个键（用户、租户、接口）一个独立的令牌桶
+type KeyedRateLimiter struct {
+	rate      int64                   // 默认速率（每秒）
+	capacity  int64                   // 默认容量
+	overrides map[string][2]int64     // 单独配置的键：速率和容量
+	limiters  map[string]*TokenBucket // 已创建的令牌桶
+	mutex     sync.Mutex
+}
+
+// NewKeyedRateLimiter 创建按键限流器，未单独配置的键使用默认速率和容量
+func NewKeyedRateLimiter(rate, capacity int64) *KeyedRateLimiter {
+	return &KeyedRateLimiter{
+		rate:      rate,
+		capacity:  capacity,
+		overrides: make(map[string][2]int64),
+		limiters:  make(map[string]*TokenBucket),
+	}
+}
+
+// SetLimit 单独设置某个键的速率和容量，已创建的令牌桶会被重建
+func (k *KeyedRateLimiter) SetLimit(key string, rate, capacity int64) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	k.overrides[key] = [2]int64{rate, capacity}
+	delete(k.limiters, key)
+}
+
+// Limiter 返回键对应的令牌桶，不存在时创建
+func (k *KeyedRateLimiter) Limiter(key string) *TokenBucket {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	limiter, exists := k.limiters[key]
+	if !exists {
+		rate, capacity := k.rate, k.capacity
+		if override, ok := k.overrides[key]; ok {
+			rate, capacity = override[0], override[1]
+		}
+		limiter = NewTokenBucket(rate, capacity)
+		k.limiters[key] = limiter
+	}
+	return limiter
+}
+
+// Allow 判断键的一次请求是否允许通过
+func (k *KeyedRateLimiter) Allow(key string) bool {
+	return k.Limiter(key).Allow()
+}
+
+// AllowN 判断键的N个请求是否允许通过
+func (k *KeyedRateLimiter) AllowN(key string, n int64) bool {
+	return k.Limiter(key).AllowN(n)
+}
+
+// Wait 等待直到键有可用令牌或上下文取消
+func (k *KeyedRateLimiter) Wait(ctx context.Context, key string) error {
+	return k.Limiter(key).Wait(ctx)
+}
+
+// Len 返回已创建的令牌桶数量
+func (k *KeyedRateLimiter) Len() int {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	return len(k.limiters)
+}
+
 // 辅助函数
 func min(a, b int64) int64 {
 	if a < b {
@@ -488,4 +623,53 @@ func RateLimiterDemo() {
 	fmt.Println("- 令牌桶允许突发流量，初始状态可以处理更多请求")
 	fmt.Println("- 漏桶对请求进行排队，平滑处理速率更稳定")
 	fmt.Println("- 两者都能有效控制长期的请求速率")
+
+	// 6. 分层保护：限流保护下游不被突发流量压垮，熔断在下游故障时快速失败，不再把请求发过去
+	fmt.Println("\n分层保护 (令牌桶限流 + 熔断器) 调用模拟的库存接口:")
+	errInventoryDown := errors.New("库存接口超时")
+	var inventoryDown bool
+	downstreamCalls := 0
+	inventoryAPI := func(ctx context.Context) error {
+		downstreamCalls++
+		if inventoryDown {
+			return errInventoryDown
+		}
+		return nil
+	}
+
+	limiter := NewTokenBucket(100, 10)
+	breaker := concurrency.NewCircuitBreaker("库存接口", concurrency.CircuitBreakerOptions{
+		WindowSize:       10,
+		MinimumCalls:     5,
+		OpenTimeout:      100 * time.Millisecond,
+		HalfOpenMaxCalls: 2,
+		OnStateChange: func(name string, from, to concurrency.BreakerState) {
+			fmt.Printf("  %s 熔断器: %s -> %s\n", name, from, to)
+		},
+	})
+
+	// 每个阶段每 5ms 发起一次请求，先经过限流再经过熔断器
+	phase := func(title string, down bool, requests int) {
+		inventoryDown = down
+		before := downstreamCalls
+		var limited, rejected, failed, ok int
+		for i := 0; i < requests; i++ {
+			if !limiter.Allow() {
+				limited++
+			} else if err := breaker.Execute(context.Background(), inventoryAPI); errors.Is(err, concurrency.ErrBreakerOpen) {
+				rejected++
+			} else if err != nil {
+				failed++
+			} else {
+				ok++
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		fmt.Printf("%s: %d 个请求，限流 %d，熔断拒绝 %d，失败 %d，成功 %d，实际调用下游 %d 次\n",
+			title, requests, limited, rejected, failed, ok, downstreamCalls-before)
+	}
+	phase("库存接口正常", false, 40)
+	phase("库存接口故障", true, 60)
+	phase("库存接口恢复", false, 40)
+	fmt.Println("- 限流把请求速率控制在下游的容量以内，熔断器在下游故障期间挡住了大部分请求，恢复后经过半开试探自动放行")
 }
@@ -0,0 +1,46 @@
+package practical_applications
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// 漏桶停止后，阻塞在 Wait 中的请求返回 ErrLimiterStopped，而不是永远等待
+func TestLeakyBucketStopWakesWaiters(t *testing.T) {
+	// 每秒漏出1个，桶装满后下一个请求至少要等1秒
+	bucket := NewLeakyBucket(1, 1)
+	if !bucket.Allow() {
+		t.Fatal("空桶应允许第一个请求")
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- bucket.Wait(context.Background())
+	}()
+
+	// 等请求进入等待队列再停止
+	deadline := time.Now().Add(time.Second)
+	for bucket.waiters.Len() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("请求没有进入等待队列")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	bucket.Stop()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrLimiterStopped) {
+			t.Errorf("Wait 返回 %v，期望 ErrLimiterStopped", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("漏桶停止后 Wait 没有返回")
+	}
+
+	// 停止之后再等待也立即返回
+	if err := bucket.Wait(context.Background()); !errors.Is(err, ErrLimiterStopped) {
+		t.Errorf("停止后 Wait 返回 %v，期望 ErrLimiterStopped", err)
+	}
+}
@@ -0,0 +1,502 @@
+package practical_applications
+
+/*
+异地容灾复制积压队列 - 按目标数据中心的优先级多队列调度
+
+原理：
+异步复制时，主数据中心的写入先进入待复制队列，再由后台协程发送到各备份数据中心。
+如果用一个无序集合保存待复制的写入，同一个键的多次写入顺序会丢失，
+重要数据和普通数据也无法区分，某个数据中心故障时还会拖累其他数据中心。
+优先级多队列为每个目标数据中心维护独立的有序队列，每个队列再按优先级分为多条通道，
+关键数据优先复制；某个数据中心写入失败时只对该数据中心退避重试，不影响其他数据中心。
+
+关键特点：
+1. 按目标隔离：每个数据中心一个队列，故障数据中心的积压不阻塞健康的数据中心
+2. 有序：每条优先级通道先进先出，同一个键总是进入同一条通道，因此写入顺序不变
+3. 优先级：关键数据（如交易）先于普通数据（如日志）复制
+4. 退避重试：写入失败时该目标暂停一段时间再重试，退避时间由 concurrency.RetryPolicy 按配置的策略计算，
+   默认逐次翻倍并有上限，也可以选择固定间隔或去相关抖动
+5. 积压指标：按目标、按优先级统计积压数量和最老条目的等待时间
+
+实现方式：
+- 每个目标的每个优先级使用一个切片作为FIFO队列
+- 处理时从最高优先级的非空通道取出队头尝试复制，失败则保留在队头并设置下次重试时间
+- 超过最大重试次数的条目移入该目标的死信集合，不再阻塞队列；死信仍计入该目标的复制水位，
+  直到同一个键的更新写入复制成功（旧值已被覆盖）或 Flush 把它补齐，因此读己之写检查不会把未复制的写入当作已复制
+
+应用场景：
+- 异地容灾的异步复制和半同步复制中未确认的部分
+- 消息系统的跨地域同步
+- 任何需要"按目标隔离 + 优先级 + 重试"的后台投递任务
+
+优缺点：
+- 优点：保证写入顺序，关键数据复制延迟低，故障隔离
+- 缺点：严格优先级下低优先级数据可能长时间等待；同一个键的优先级必须固定才能保证顺序
+
+以下实现了复制积压队列，并由异地容灾系统的异步复制协程使用。
+*/
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/strive/scenario/concurrency"
+)
+
+// ReplicationPriority 复制优先级，数值越小优先级越高
+type ReplicationPriority int
+
+// 复制优先级
+const (
+	ReplicationPriorityCritical ReplicationPriority = iota // 关键数据，如交易记录
+	ReplicationPriorityNormal                              // 普通数据
+	ReplicationPriorityLow                                 // 低优先级数据，如日志、统计
+	replicationPriorityLevels                              // 优先级数量
+)
+
+// String 返回优先级名称
+func (p ReplicationPriority) String() string {
+	switch p {
+	case ReplicationPriorityCritical:
+		return "关键"
+	case ReplicationPriorityNormal:
+		return "普通"
+	case ReplicationPriorityLow:
+		return "低"
+	default:
+		return fmt.Sprintf("未知(%d)", int(p))
+	}
+}
+
+// ReplicationEntry 一条待复制的写入
+type ReplicationEntry struct {
+	Seq        uint64              // 全局写入序号，由调用方分配并单调递增
+	Key        string              // 键
+	Data       []byte              // 数据
+	Priority   ReplicationPriority // 优先级
+	EnqueuedAt time.Time           // 入队时间
+	Attempts   int                 // 已尝试次数
+	LastError  error               // 最近一次失败原因
+}
+
+// ReplicationQueueOptions 复制队列配置
+type ReplicationQueueOptions struct {
+	BaseBackoff time.Duration               // 首次失败后的退避时间
+	MaxBackoff  time.Duration               // 最大退避时间
+	Strategy    concurrency.BackoffStrategy // 退避策略，零值为指数退避
+	MaxAttempts int                         // 单条写入的最大尝试次数，0表示不限制
+	BatchSize   int                         // 每次处理时每个目标最多复制的条数
+}
+
+// DefaultReplicationQueueOptions 默认复制队列配置
+var DefaultReplicationQueueOptions = ReplicationQueueOptions{
+	BaseBackoff: 200 * time.Millisecond,
+	MaxBackoff:  10 * time.Second,
+	Strategy:    concurrency.BackoffExponential,
+	MaxAttempts: 0,
+	BatchSize:   256,
+}
+
+// ReplicateFunc 将一条写入复制到目标，返回错误表示复制失败
+type ReplicateFunc func(destination string, entry *ReplicationEntry) error
+
+// replicationDestination 单个目标的队列
+type replicationDestination struct {
+	lanes      [replicationPriorityLevels][]*ReplicationEntry // 各优先级通道
+	retryAt    time.Time                                      // 退避结束时间
+	failures   int                                            // 连续失败次数
+	backoff    time.Duration                                  // 最近一次的退避时间
+	replicated int64                                          // 已复制条数
+	retries    int64                                          // 失败重试次数
+	dropped    int64                                          // 超过重试次数移入死信的条数
+	dead       []*ReplicationEntry                            // 死信：超过重试次数、尚未复制的写入
+	maxBacklog int                                            // 历史最大积压
+}
+
+// backlog 返回当前积压条数
+func (d *replicationDestination) backlog() int {
+	total := 0
+	for _, lane := range d.lanes {
+		total += len(lane)
+	}
+	return total
+}
+
+// head 返回优先级最高的非空通道
+func (d *replicationDestination) head() (ReplicationPriority, *ReplicationEntry) {
+	for priority, lane := range d.lanes {
+		if len(lane) > 0 {
+			return ReplicationPriority(priority), lane[0]
+		}
+	}
+	return 0, nil
+}
+
+// pop 移除指定通道的队头
+func (d *replicationDestination) pop(priority ReplicationPriority) {
+	lane := d.lanes[priority]
+	lane[0] = nil
+	d.lanes[priority] = lane[1:]
+}
+
+// supersede 同一个键的新写入复制成功后，移除该键更早的死信（目标上的旧值已被覆盖）
+func (d *replicationDestination) supersede(entry *ReplicationEntry) {
+	kept := d.dead[:0]
+	for _, dead := range d.dead {
+		if dead.Key != entry.Key || dead.Seq > entry.Seq {
+			kept = append(kept, dead)
+		}
+	}
+	clear(d.dead[len(kept):])
+	d.dead = kept
+}
+
+// requeueDead 把死信按序号放回各自优先级通道的队头；死信总是比同一通道中剩余的条目更早
+func (d *replicationDestination) requeueDead() {
+	sort.Slice(d.dead, func(i, j int) bool { return d.dead[i].Seq < d.dead[j].Seq })
+	var heads [replicationPriorityLevels][]*ReplicationEntry
+	for _, entry := range d.dead {
+		heads[entry.Priority] = append(heads[entry.Priority], entry)
+	}
+	for priority, head := range heads {
+		if len(head) > 0 {
+			d.lanes[priority] = append(head, d.lanes[priority]...)
+		}
+	}
+	d.dead = nil
+}
+
+// ReplicationQueue 按目标数据中心隔离的优先级复制队列
+type ReplicationQueue struct {
+	options      ReplicationQueueOptions
+	retry        concurrency.RetryPolicy // 由配置生成的退避策略
+	destinations map[string]*replicationDestination
+	mutex        sync.Mutex
+}
+
+// NewReplicationQueue 创建复制队列
+func NewReplicationQueue(options ReplicationQueueOptions) *ReplicationQueue {
+	if options.BaseBackoff <= 0 {
+		options.BaseBackoff = DefaultReplicationQueueOptions.BaseBackoff
+	}
+	if options.MaxBackoff < options.BaseBackoff {
+		options.MaxBackoff = options.BaseBackoff
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = DefaultReplicationQueueOptions.BatchSize
+	}
+	return &ReplicationQueue{
+		options: options,
+		retry: concurrency.RetryPolicy{
+			Strategy:       options.Strategy,
+			InitialBackoff: options.BaseBackoff,
+			MaxBackoff:     options.MaxBackoff,
+			Multiplier:     2,
+		},
+		destinations: make(map[string]*replicationDestination),
+	}
+}
+
+// destination 返回目标的队列，不存在时创建（调用方需持有锁）
+func (q *ReplicationQueue) destination(id string) *replicationDestination {
+	d, ok := q.destinations[id]
+	if !ok {
+		d = &replicationDestination{}
+		q.destinations[id] = d
+	}
+	return d
+}
+
+// Enqueue 将序号为 seq 的一次写入加入各目标的队列
+func (q *ReplicationQueue) Enqueue(seq uint64, key string, data []byte, priority ReplicationPriority, destinations ...string) error {
+	if priority < 0 || priority >= replicationPriorityLevels {
+		return fmt.Errorf("无效的复制优先级: %d", priority)
+	}
+	if len(destinations) == 0 {
+		return nil
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	now := time.Now()
+	for _, id := range destinations {
+		d := q.destination(id)
+		// 每个目标一份独立的条目，重试次数互不影响
+		d.lanes[priority] = append(d.lanes[priority], &ReplicationEntry{
+			Seq:        seq,
+			Key:        key,
+			Data:       data,
+			Priority:   priority,
+			EnqueuedAt: now,
+		})
+		if backlog := d.backlog(); backlog > d.maxBacklog {
+			d.maxBacklog = backlog
+		}
+	}
+	return nil
+}
+
+// backoff 计算目标连续失败后的退避时间并记录下来（调用方需持有锁）
+func (q *ReplicationQueue) backoff(d *replicationDestination) time.Duration {
+	d.backoff = q.retry.NextBackoff(d.failures, d.backoff)
+	return d.backoff
+}
+
+// Process 处理各目标的队列，返回本次成功复制的条数
+func (q *ReplicationQueue) Process(replicate ReplicateFunc) int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	now := time.Now()
+	ids := make([]string, 0, len(q.destinations))
+	for id := range q.destinations {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	total := 0
+	for _, id := range ids {
+		d := q.destinations[id]
+		if now.Before(d.retryAt) {
+			continue // 该目标处于退避期
+		}
+
+		for processed := 0; processed < q.options.BatchSize; processed++ {
+			priority, entry := d.head()
+			if entry == nil {
+				break
+			}
+
+			entry.Attempts++
+			if err := replicate(id, entry); err != nil {
+				entry.LastError = err
+				d.failures++
+				d.retries++
+				d.retryAt = now.Add(q.backoff(d))
+				if q.options.MaxAttempts > 0 && entry.Attempts >= q.options.MaxAttempts {
+					d.pop(priority)
+					d.dead = append(d.dead, entry)
+					d.dropped++
+				}
+				break
+			}
+
+			d.pop(priority)
+			d.supersede(entry)
+			d.failures = 0
+			d.backoff = 0
+			d.replicated++
+			total++
+		}
+	}
+	return total
+}
+
+// Flush 忽略退避和最大重试次数，立即把某个目标的积压（包括死信）全部复制完，遇到失败时停止，返回成功复制的条数
+func (q *ReplicationQueue) Flush(destination string, replicate ReplicateFunc) (int, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	d, ok := q.destinations[destination]
+	if !ok {
+		return 0, nil
+	}
+	d.requeueDead()
+
+	total := 0
+	for {
+		priority, entry := d.head()
+		if entry == nil {
+			d.failures = 0
+			d.backoff = 0
+			d.retryAt = time.Time{}
+			return total, nil
+		}
+
+		entry.Attempts++
+		if err := replicate(destination, entry); err != nil {
+			entry.LastError = err
+			d.retries++
+			return total, err
+		}
+		d.pop(priority)
+		d.supersede(entry)
+		d.replicated++
+		total++
+	}
+}
+
+// MinPendingSeq 返回目标积压和死信中最小的写入序号，都为空时返回 false
+func (q *ReplicationQueue) MinPendingSeq(destination string) (uint64, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	d, ok := q.destinations[destination]
+	if !ok {
+		return 0, false
+	}
+
+	// 每条通道内序号递增，因此只需比较各通道的队头
+	minSeq, found := uint64(0), false
+	for _, lane := range d.lanes {
+		if len(lane) > 0 && (!found || lane[0].Seq < minSeq) {
+			minSeq, found = lane[0].Seq, true
+		}
+	}
+	for _, entry := range d.dead {
+		if !found || entry.Seq < minSeq {
+			minSeq, found = entry.Seq, true
+		}
+	}
+	return minSeq, found
+}
+
+// DeadLetters 返回目标超过最大重试次数、尚未复制的写入，按序号排列
+func (q *ReplicationQueue) DeadLetters(destination string) []ReplicationEntry {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	d, ok := q.destinations[destination]
+	if !ok {
+		return nil
+	}
+	entries := make([]ReplicationEntry, len(d.dead))
+	for i, entry := range d.dead {
+		entries[i] = *entry
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Seq < entries[j].Seq })
+	return entries
+}
+
+// Backlog 返回所有目标的积压总数
+func (q *ReplicationQueue) Backlog() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	total := 0
+	for _, d := range q.destinations {
+		total += d.backlog()
+	}
+	return total
+}
+
+// Stats 获取复制队列统计信息，按目标分组
+func (q *ReplicationQueue) Stats() map[string]interface{} {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	now := time.Now()
+	stats := make(map[string]interface{}, len(q.destinations))
+	for id, d := range q.destinations {
+		byPriority := make(map[string]int, replicationPriorityLevels)
+		oldest := time.Duration(0)
+		for priority, lane := range d.lanes {
+			byPriority[ReplicationPriority(priority).String()] = len(lane)
+			if len(lane) > 0 {
+				if age := now.Sub(lane[0].EnqueuedAt); age > oldest {
+					oldest = age
+				}
+			}
+		}
+
+		destStats := map[string]interface{}{
+			"backlog":           d.backlog(),
+			"backlogByPriority": byPriority,
+			"maxBacklog":        d.maxBacklog,
+			"oldestAge":         oldest.Round(time.Millisecond).String(),
+			"replicated":        d.replicated,
+			"retries":           d.retries,
+			"dropped":           d.dropped,
+			"deadLetters":       len(d.dead),
+			"failures":          d.failures,
+		}
+		if now.Before(d.retryAt) {
+			destStats["retryIn"] = d.retryAt.Sub(now).Round(time.Millisecond).String()
+		}
+		stats[id] = destStats
+	}
+	return stats
+}
+
+// errDataCenterUnavailable 目标数据中心不可写
+var errDataCenterUnavailable = errors.New("数据中心不可用")
+
+// 场景示例：备份数据中心故障期间的复制积压与恢复
+func ReplicationQueueDemo() {
+	fmt.Println("异步复制积压队列示例:")
+
+	drs := NewDisasterRecoverySystem(ReplicationAsync, time.Minute)
+	defer drs.Shutdown()
+
+	// 交易记录优先复制，审计日志最后复制
+	drs.SetPriorityFunc(func(key string) ReplicationPriority {
+		switch {
+		case strings.HasPrefix(key, "tx-"):
+			return ReplicationPriorityCritical
+		case strings.HasPrefix(key, "log-"):
+			return ReplicationPriorityLow
+		default:
+			return ReplicationPriorityNormal
+		}
+	})
+
+	primary := NewDataCenter("dc-sh", "上海数据中心", "上海", true)
+	beijing := NewDataCenter("dc-bj", "北京数据中心", "北京", false)
+	guangzhou := NewDataCenter("dc-gz", "广州数据中心", "广州", false)
+	for _, dc := range []*DataCenter{primary, beijing, guangzhou} {
+		drs.AddDataCenter(dc)
+	}
+
+	printBacklog := func(title string) {
+		fmt.Printf("\n%s:\n", title)
+		stats := drs.ReplicationStats()
+		for _, id := range []string{"dc-bj", "dc-gz"} {
+			s, ok := stats[id].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			fmt.Printf("  %s: 积压 %v %v, 已复制 %v, 重试 %v, 最老等待 %v",
+				id, s["backlog"], s["backlogByPriority"], s["replicated"], s["retries"], s["oldestAge"])
+			if retryIn, ok := s["retryIn"]; ok {
+				fmt.Printf(", %v 后重试", retryIn)
+			}
+			fmt.Println()
+		}
+	}
+
+	// 广州数据中心故障
+	drs.UpdateDataCenterStatus(guangzhou.ID, StatusFailed)
+	fmt.Println("广州数据中心故障，期间写入日志、普通数据和交易记录（交易最后写入）")
+
+	for i := 1; i <= 5; i++ {
+		drs.Write(fmt.Sprintf("log-%03d", i), []byte("审计日志"))
+		drs.Write(fmt.Sprintf("user-%03d", i), []byte("用户资料"))
+	}
+	drs.Write("tx-001", []byte("转账 100 元"))
+	drs.Write("tx-001", []byte("转账 100 元（已确认）"))
+	drs.Write("tx-002", []byte("转账 200 元"))
+
+	drs.ProcessReplication()
+	printBacklog("第一次复制后")
+	drs.ProcessReplication()
+	printBacklog("退避期内再次处理（广州被跳过）")
+
+	// 北京按优先级复制：交易记录最先到达，且同一个键保持写入顺序
+	beijing.mutex.RLock()
+	fmt.Printf("\n北京 tx-001 = %s（同一键的两次写入按顺序复制）\n", beijing.Storage["tx-001"])
+	beijing.mutex.RUnlock()
+
+	// 广州恢复后积压被清空
+	drs.UpdateDataCenterStatus(guangzhou.ID, StatusHealthy)
+	time.Sleep(DefaultReplicationQueueOptions.BaseBackoff * 2)
+	drs.ProcessReplication()
+	printBacklog("广州恢复后")
+	guangzhou.mutex.RLock()
+	fmt.Printf("\n广州数据中心存储 %d 条数据\n", len(guangzhou.Storage))
+	guangzhou.mutex.RUnlock()
+}
@@ -0,0 +1,96 @@
+package practical_applications
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// processUntilIdle 反复处理队列直到积压清空或超时
+func processUntilIdle(t *testing.T, q *ReplicationQueue, replicate ReplicateFunc) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for q.Backlog() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("积压未清空: %d", q.Backlog())
+		}
+		q.Process(replicate)
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// 目标始终失败时，超过重试次数的写入进入死信，复制水位不能越过它们
+func TestReplicationQueueDeadLetterPinsWatermark(t *testing.T) {
+	q := NewReplicationQueue(ReplicationQueueOptions{
+		BaseBackoff: time.Millisecond,
+		MaxBackoff:  time.Millisecond,
+		MaxAttempts: 2,
+	})
+	replicate := func(destination string, entry *ReplicationEntry) error {
+		if destination == "dc-bad" {
+			return errDataCenterUnavailable
+		}
+		return nil
+	}
+
+	for seq, key := range []string{"a", "b", "c"} {
+		q.Enqueue(uint64(seq+1), key, []byte(key), ReplicationPriorityNormal, "dc-ok", "dc-bad")
+	}
+	processUntilIdle(t, q, replicate)
+
+	if seq, pending := q.MinPendingSeq("dc-bad"); !pending || seq != 1 {
+		t.Fatalf("dc-bad 水位: seq=%d pending=%v，期望停在 1", seq, pending)
+	}
+	if _, pending := q.MinPendingSeq("dc-ok"); pending {
+		t.Fatal("dc-ok 已全部复制，不应有待复制写入")
+	}
+	if dead := q.DeadLetters("dc-bad"); len(dead) != 3 || dead[0].Seq != 1 {
+		t.Fatalf("死信: %+v", dead)
+	}
+
+	// 之后的写入也不能让水位前进
+	q.Enqueue(4, "d", []byte("d"), ReplicationPriorityNormal, "dc-bad")
+	processUntilIdle(t, q, replicate)
+	if seq, _ := q.MinPendingSeq("dc-bad"); seq != 1 {
+		t.Fatalf("dc-bad 水位前进到 %d", seq)
+	}
+}
+
+// 同一个键的更新复制成功后旧死信失效；Flush 会补齐剩余死信
+func TestReplicationQueueDeadLetterRecovery(t *testing.T) {
+	q := NewReplicationQueue(ReplicationQueueOptions{
+		BaseBackoff: time.Millisecond,
+		MaxBackoff:  time.Millisecond,
+		MaxAttempts: 1,
+	})
+	down := true
+	stored := make(map[string]string)
+	replicate := func(destination string, entry *ReplicationEntry) error {
+		if down {
+			return errors.New("不可用")
+		}
+		stored[entry.Key] = string(entry.Data)
+		return nil
+	}
+
+	q.Enqueue(1, "a", []byte("a1"), ReplicationPriorityNormal, "dc")
+	q.Enqueue(2, "b", []byte("b1"), ReplicationPriorityNormal, "dc")
+	processUntilIdle(t, q, replicate)
+
+	down = false
+	q.Enqueue(3, "a", []byte("a2"), ReplicationPriorityNormal, "dc")
+	processUntilIdle(t, q, replicate)
+	if seq, _ := q.MinPendingSeq("dc"); seq != 2 {
+		t.Fatalf("a 被覆盖后水位应停在 b 的序号 2，实际 %d", seq)
+	}
+
+	if n, err := q.Flush("dc", replicate); err != nil || n != 1 {
+		t.Fatalf("Flush: n=%d err=%v", n, err)
+	}
+	if _, pending := q.MinPendingSeq("dc"); pending {
+		t.Fatal("Flush 后不应有待复制写入")
+	}
+	if stored["a"] != "a2" || stored["b"] != "b1" {
+		t.Fatalf("目标数据: %v", stored)
+	}
+}
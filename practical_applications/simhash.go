@@ -0,0 +1,420 @@
+package practical_applications
+
+/*
+SimHash 指纹与海明距离索引
+
+原理：
+电商平台上同一件商品经常被反复上架：卖家在标题前后加上"包邮""正品""热卖"，调换几个词的顺序，
+或者改一个无关紧要的字。搜索系统如果把这些副本都建进索引，前缀树里会堆满只出现在营销前缀里的词条，
+热词统计也会被同一件商品反复计数。
+SimHash（Charikar 2002）把一篇文本压缩成一个64位指纹，并且让相似文本的指纹也相似：
+- 把文本切成特征（词或字的二元组），每个特征计算64位哈希
+- 维护64个计数器，特征哈希的第 i 位为1时第 i 个计数器加上特征权重，为0时减去
+- 计数器为正的位置1，得到指纹
+少量特征变化只能拉动少数计数器越过0，所以相似文本的指纹只有几位不同，用海明距离（异或后1的个数）衡量。
+
+关键特点：
+1. 指纹固定为8字节，与文本长度无关，适合海量文档存储
+2. 海明距离不超过 k 即视为近似重复：网页等长文本通常取3，标题等短文本特征少，需要放宽到10左右
+3. 索引利用抽屉原理：把64位分成 k+1 段，距离不超过 k 的两个指纹至少有一段完全相同，
+   只需在 k+1 张表中精确查找各段，再对候选计算海明距离，无需与所有指纹逐一比较
+4. 哈希函数可替换（默认 FNV-1a，也可以使用 hashing 包中的其他算法）
+
+实现方式：
+- 文本按非字母数字字符切词；含汉字的词再切成字二元组，词频作为权重
+- SimHashIndex 为每一段维护一张"段值 -> 文档ID列表"的哈希表
+
+应用场景：
+- 商品、帖子建索引前的近似去重
+- 网页抓取去重（Google 爬虫使用的方案）
+- 检测洗稿、刷屏
+
+优缺点：
+- 优点：指纹极小，查询只需 k+1 次哈希表查找，适合实时去重
+- 缺点：对很短的文本不够稳定；距离阈值较大时分段变短，候选数量急剧增加；
+  只度量字面相似度，比 MinHash 更难精确控制相似度阈值
+
+以下实现了 SimHash 指纹计算和按段划分的海明距离索引，并在商品建索引前用它去除重复上架的商品。
+*/
+
+import (
+	"fmt"
+	"math/bits"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"unicode"
+
+	"github.com/strive/scenario/hashing"
+	"github.com/strive/scenario/report"
+)
+
+// SimHashBits 指纹的位数
+const SimHashBits = 64
+
+// SimHashOptions SimHash 索引选项
+type SimHashOptions struct {
+	MaxDistance int            // 视为近似重复的最大海明距离
+	Hasher      hashing.Hasher // 特征哈希函数，为 nil 时使用 FNV-1a
+}
+
+// DefaultSimHashOptions 默认的 SimHash 索引选项
+var DefaultSimHashOptions = SimHashOptions{
+	MaxDistance: 3,
+}
+
+// SimHashMatch 查询到的近似重复文档
+type SimHashMatch struct {
+	ID       string
+	Distance int // 海明距离
+}
+
+// SimHashIndex 按段划分的 SimHash 指纹索引，可被多个协程并发使用
+type SimHashIndex struct {
+	mu           sync.RWMutex
+	options      SimHashOptions
+	blocks       []simHashBlock        // 每一段的位范围
+	fingerprints map[string]uint64     // 文档ID -> 指纹
+	tables       []map[uint64][]string // 每段：段值 -> 文档ID
+	candidates   uint64                // 累计比较过的候选数
+}
+
+// simHashBlock 指纹中的一段，[shift, shift+width) 位
+type simHashBlock struct {
+	shift uint
+	width uint
+}
+
+// NewSimHashIndex 创建 SimHash 索引
+func NewSimHashIndex(options SimHashOptions) *SimHashIndex {
+	if options.MaxDistance < 0 || options.MaxDistance >= SimHashBits {
+		options.MaxDistance = DefaultSimHashOptions.MaxDistance
+	}
+	if options.Hasher == nil {
+		options.Hasher = hashing.NewFNV1a(0)
+	}
+
+	// 64位尽量均分为 MaxDistance+1 段
+	count := options.MaxDistance + 1
+	blocks := make([]simHashBlock, count)
+	shift := uint(0)
+	for i := range blocks {
+		width := uint(SimHashBits / count)
+		if i < SimHashBits%count {
+			width++
+		}
+		blocks[i] = simHashBlock{shift: shift, width: width}
+		shift += width
+	}
+	tables := make([]map[uint64][]string, count)
+	for i := range tables {
+		tables[i] = make(map[uint64][]string)
+	}
+
+	return &SimHashIndex{
+		options:      options,
+		blocks:       blocks,
+		fingerprints: make(map[string]uint64),
+		tables:       tables,
+	}
+}
+
+// SimHashFeatures 把文本切成特征及其权重（词频）：英文和数字按词，含汉字的词切成字二元组
+func SimHashFeatures(text string) map[string]int {
+	features := make(map[string]int)
+	for _, token := range tokenize(text) {
+		runes := []rune(token)
+		han := false
+		for _, r := range runes {
+			if unicode.Is(unicode.Han, r) {
+				han = true
+				break
+			}
+		}
+		if !han || len(runes) < 2 {
+			features[token]++
+			continue
+		}
+		for i := 0; i+2 <= len(runes); i++ {
+			features[string(runes[i:i+2])]++
+		}
+	}
+	return features
+}
+
+// SimHash 用给定的哈希函数计算文本的64位指纹
+func SimHash(h hashing.Hasher, text string) uint64 {
+	var counters [SimHashBits]int
+	for feature, weight := range SimHashFeatures(text) {
+		sum := hashing.SumString(h, feature)
+		for i := 0; i < SimHashBits; i++ {
+			if sum&(1<<uint(i)) != 0 {
+				counters[i] += weight
+			} else {
+				counters[i] -= weight
+			}
+		}
+	}
+
+	var fingerprint uint64
+	for i, c := range counters {
+		if c > 0 {
+			fingerprint |= 1 << uint(i)
+		}
+	}
+	return fingerprint
+}
+
+// HammingDistance 计算两个指纹的海明距离
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// blockValue 取出指纹第 i 段的值
+func (idx *SimHashIndex) blockValue(fingerprint uint64, i int) uint64 {
+	b := idx.blocks[i]
+	return (fingerprint >> b.shift) & (1<<b.width - 1)
+}
+
+// Fingerprint 使用索引的哈希函数计算文本指纹
+func (idx *SimHashIndex) Fingerprint(text string) uint64 {
+	return SimHash(idx.options.Hasher, text)
+}
+
+// Add 计算文本指纹并加入索引，返回指纹；ID 已存在时替换
+func (idx *SimHashIndex) Add(id, text string) uint64 {
+	fingerprint := idx.Fingerprint(text)
+	idx.AddFingerprint(id, fingerprint)
+	return fingerprint
+}
+
+// AddFingerprint 把已计算好的指纹加入索引，ID 已存在时替换
+func (idx *SimHashIndex) AddFingerprint(id string, fingerprint uint64) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.removeLocked(id)
+	idx.fingerprints[id] = fingerprint
+	for i := range idx.blocks {
+		key := idx.blockValue(fingerprint, i)
+		idx.tables[i][key] = append(idx.tables[i][key], id)
+	}
+}
+
+// Remove 从索引中删除文档
+func (idx *SimHashIndex) Remove(id string) bool {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return idx.removeLocked(id)
+}
+
+// removeLocked 删除文档，调用方需持有写锁
+func (idx *SimHashIndex) removeLocked(id string) bool {
+	fingerprint, ok := idx.fingerprints[id]
+	if !ok {
+		return false
+	}
+	for i := range idx.blocks {
+		key := idx.blockValue(fingerprint, i)
+		ids := idx.tables[i][key]
+		for j, other := range ids {
+			if other == id {
+				ids = append(ids[:j], ids[j+1:]...)
+				break
+			}
+		}
+		if len(ids) == 0 {
+			delete(idx.tables[i], key)
+		} else {
+			idx.tables[i][key] = ids
+		}
+	}
+	delete(idx.fingerprints, id)
+	return true
+}
+
+// Query 查找与文本近似重复的文档，按海明距离从小到大排序
+func (idx *SimHashIndex) Query(text string) []SimHashMatch {
+	return idx.QueryFingerprint(idx.Fingerprint(text))
+}
+
+// QueryFingerprint 查找与指纹的海明距离不超过 MaxDistance 的文档
+func (idx *SimHashIndex) QueryFingerprint(fingerprint uint64) []SimHashMatch {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var matches []SimHashMatch
+	for i := range idx.blocks {
+		for _, id := range idx.tables[i][idx.blockValue(fingerprint, i)] {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			if d := HammingDistance(fingerprint, idx.fingerprints[id]); d <= idx.options.MaxDistance {
+				matches = append(matches, SimHashMatch{ID: id, Distance: d})
+			}
+		}
+	}
+	atomic.AddUint64(&idx.candidates, uint64(len(seen)))
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Distance != matches[j].Distance {
+			return matches[i].Distance < matches[j].Distance
+		}
+		return matches[i].ID < matches[j].ID
+	})
+	return matches
+}
+
+// Len 返回索引中的文档数量
+func (idx *SimHashIndex) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.fingerprints)
+}
+
+// Stats 返回索引统计信息
+func (idx *SimHashIndex) Stats() map[string]interface{} {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	buckets := 0
+	for _, table := range idx.tables {
+		buckets += len(table)
+	}
+	return map[string]interface{}{
+		"documents":   len(idx.fingerprints),
+		"maxDistance": idx.options.MaxDistance,
+		"blocks":      len(idx.blocks),
+		"buckets":     buckets,
+		"candidates":  atomic.LoadUint64(&idx.candidates),
+		"hasher":      idx.options.Hasher.Name(),
+	}
+}
+
+// productListing 演示用的商品上架记录
+type productListing struct {
+	Title     string
+	Weight    int
+	Duplicate bool // 是否为已有商品的重复上架
+}
+
+// generateProductListings 生成商品上架记录：每件商品先正常上架一次，之后有一部分被换个标题重复上架
+func generateProductListings() []productListing {
+	products := []struct {
+		Title  string
+		Weight int
+	}{
+		{"苹果手机 iPhone 13 Pro 256GB 远峰蓝色 全网通5G手机", 90},
+		{"华为手机 Mate 40 Pro 5G 256GB 亮黑色 麒麟9000芯片", 85},
+		{"小米手机 11 Ultra 256GB 陶瓷白 骁龙888 2K曲面屏", 75},
+		{"苹果笔记本电脑 MacBook Pro 14英寸 M1 Pro芯片 16GB内存 512GB固态硬盘", 85},
+		{"联想笔记本电脑 ThinkPad X1 Carbon 14英寸 轻薄商务本 i7处理器", 70},
+		{"戴尔笔记本电脑 XPS 13 超窄边框 触控屏 11代酷睿", 70},
+		{"索尼降噪耳机 WF-1000XM4 真无线蓝牙耳机 主动降噪", 60},
+		{"苹果无线耳机 AirPods Pro 主动降噪 无线充电盒", 65},
+		{"罗技机械键盘 G915 无线RGB 矮轴 游戏键盘", 50},
+		{"三星显示器 奥德赛 G9 49英寸 曲面电竞显示器 240Hz", 40},
+		{"华为智能手表 Watch GT 3 血氧监测 两周续航", 55},
+		{"小米智能手环 6 全面彩屏 心率血氧监测 运动手环", 50},
+	}
+	// 重复上架的常见手法：加营销前后缀、改一个规格词
+	prefixes := []string{"【包邮】", "【正品保证】", "热卖 ", "官方旗舰店 "}
+	suffixes := []string{" 顺丰发货", " 假一赔十", " 限时特价", ""}
+
+	listings := make([]productListing, 0, len(products)*4)
+	for _, p := range products {
+		listings = append(listings, productListing{Title: p.Title, Weight: p.Weight})
+	}
+	for round := 0; round < 3; round++ {
+		for i, p := range products {
+			if (i+round)%3 == 0 {
+				continue
+			}
+			title := prefixes[(i+round)%len(prefixes)] + p.Title + suffixes[(i*round)%len(suffixes)]
+			listings = append(listings, productListing{Title: title, Weight: p.Weight, Duplicate: true})
+		}
+	}
+	return listings
+}
+
+// 场景示例：商品建索引前用 SimHash 去除重复上架的商品，减少前缀树中的冗余词条
+func SimHashDemo() {
+	fmt.Println("SimHash 近似去重示例 - 商品建索引前去重:")
+
+	a := "苹果手机 iPhone 13 Pro 256GB 远峰蓝色 全网通5G手机"
+	b := "【包邮】苹果手机 iPhone 13 Pro 256GB 远峰蓝色 全网通5G手机 顺丰发货"
+	c := "华为手机 Mate 40 Pro 5G 256GB 亮黑色 麒麟9000芯片"
+	h := hashing.NewFNV1a(0)
+	fa, fb, fc := SimHash(h, a), SimHash(h, b), SimHash(h, c)
+	fmt.Printf("\n原标题:     %016x\n重复上架:   %016x  海明距离 %d\n另一件商品: %016x  海明距离 %d\n",
+		fa, fb, HammingDistance(fa, fb), fc, HammingDistance(fa, fc))
+
+	listings := generateProductListings()
+	duplicates := 0
+	for _, l := range listings {
+		if l.Duplicate {
+			duplicates++
+		}
+	}
+
+	var kept, caught, falseHits, bruteForce int
+	comparison := report.NewComparison(
+		"商品索引去重对比",
+		fmt.Sprintf("%d 条上架记录，其中 %d 条为重复上架", len(listings), duplicates),
+		report.Metric{Name: "入库商品", LowerIsBetter: true, Precision: 0},
+		report.Metric{Name: "前缀树词条", LowerIsBetter: true, Precision: 0},
+		report.Metric{Name: "词频累计", LowerIsBetter: true, Precision: 0},
+		report.Metric{Name: "前缀树内存(KB)", LowerIsBetter: true, Precision: 1},
+	)
+	comparison.Measure("直接建索引", func() (map[string]float64, error) {
+		engine := NewPrefixSearchEngine()
+		for _, l := range listings {
+			engine.AddDocument(l.Title, l.Weight)
+		}
+		return indexMetrics(engine, len(listings)), nil
+	})
+
+	// 商品标题只有二三十个特征，营销词在其中占比不小，重复上架的距离多在 3~12 之间，
+	// 而不同商品通常在 15 以上，所以短文本要放宽默认的阈值3（网页等长文本用3即可）
+	index := NewSimHashIndex(SimHashOptions{MaxDistance: 12})
+	comparison.Measure("SimHash去重后建索引", func() (map[string]float64, error) {
+		engine := NewPrefixSearchEngine()
+		for i, l := range listings {
+			bruteForce += index.Len()
+			if matches := index.Query(l.Title); len(matches) > 0 {
+				if l.Duplicate {
+					caught++
+				} else {
+					falseHits++
+				}
+				continue
+			}
+			index.Add(fmt.Sprintf("sku-%03d", i), l.Title)
+			engine.AddDocument(l.Title, l.Weight)
+			kept++
+		}
+		return indexMetrics(engine, kept), nil
+	})
+	fmt.Println()
+	comparison.Write(os.Stdout, report.FormatMarkdown)
+
+	fmt.Printf("识别出 %d/%d 条重复上架，误判 %d 条不同商品\n", caught, duplicates, falseHits)
+	stats := index.Stats()
+	fmt.Printf("索引: %d 个指纹，分 %d 段，累计比较候选 %d 个（逐一比较需要 %d 次）\n",
+		stats["documents"], stats["blocks"], stats["candidates"], bruteForce)
+}
+
+// indexMetrics 统计前缀树的规模；词频累计是所有词条出现次数之和，重复上架会让同一件商品的词被反复计数
+func indexMetrics(engine *PrefixSearchEngine, documents int) map[string]float64 {
+	occurrences := 0
+	for _, word := range engine.trie.GetHotWords(0) {
+		occurrences += word.Count
+	}
+	return map[string]float64{
+		"入库商品":      float64(documents),
+		"前缀树词条":     float64(engine.trie.Size()),
+		"词频累计":      float64(occurrences),
+		"前缀树内存(KB)": float64(engine.trie.MemoryUsage()) / 1024,
+	}
+}
@@ -0,0 +1,311 @@
+package practical_applications
+
+/*
+跳表键值存储的快照迭代器
+
+原理：
+Scan 和 Keys 在整个遍历期间持有存储的读锁，键很多或者调用方边遍历边做耗时处理时，写入会被长时间阻塞；
+如果改成分批加锁、批与批之间释放锁，遍历过程中又会看到并发写入造成的中间状态，
+例如同一次遍历里一部分键是转账前的值、另一部分是转账后的值。
+快照迭代器在创建时确定一个快照时刻，之后分批加读锁读取，但保证输出的正好是快照时刻范围内的全部键值对：
+写入者在修改一个键之前，先检查每个未关闭的迭代器是否还没有遍历到这个键，
+如果是，就把这个键在快照时刻的状态（值、过期时间，或者"当时不存在"）保存到该迭代器中（保存前像）。
+迭代器遍历时，保存过前像的键按前像输出，其余的键在快照之后没有被修改过，直接输出跳表中的当前值。
+
+关键特点：
+1. Iterate(start, end) 返回 [start, end) 范围内的键值对，nil 表示不限制；默认存储按键的字典序输出，
+   使用哈希分数的存储按哈希顺序输出
+2. 快照语义：只输出快照时刻存在且未过期的键，值为快照时刻的值；快照之后插入的键不输出，删除或过期的键照常输出
+3. 每批最多读取 kvIteratorBatch 个键，批与批之间不持有锁，调用方处理键值对时写入不受影响
+4. 每个键只保存一次前像，且只为迭代器还没有遍历到的键保存；已遍历部分的前像及时丢弃
+5. 遍历结束或调用 Close 后注销迭代器，写入者不再为它保存前像
+
+实现方式：
+- 迭代器记录上一次访问的位置（分数, 键），下一批从该位置之后继续；按字典序排列时从 start 直接定位，到 end 停止
+- 前像保存在哈希表中，快照时刻存在的键另按位置排序，与跳表的当前内容归并输出，
+  这样快照之后被删除、已经不在跳表中的键也能在正确的位置输出
+- 迭代器的位置和前像由存储的锁保护：写入者持有写锁保存前像，迭代器持有读锁读取
+
+应用场景：
+- 导出、备份、对账等需要一致视图的全量遍历
+- 边遍历边做网络请求等耗时处理的扫描
+- 为快照持久化提供一致的数据来源
+
+优缺点：
+- 优点：遍历期间不阻塞写入，结果与快照时刻完全一致，没有快照时写入没有额外开销
+- 缺点：迭代器未关闭期间，每次写入都要检查所有迭代器；迭代器遍历得越慢，积累的前像越多
+
+以下实现了快照迭代器，示例见 skiplist_kv_store.go 的排行榜示例。
+*/
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"time"
+)
+
+// kvIteratorBatch 迭代器每次加锁读取的最大键数
+const kvIteratorBatch = 64
+
+// KVPair 键值对
+type KVPair struct {
+	Key      []byte
+	Value    []byte
+	ExpireAt time.Time // 快照时刻的过期时间，零值表示不过期
+}
+
+// preimage 键在快照时刻的状态
+type preimage struct {
+	key     []byte
+	score   float64
+	value   []byte
+	expiry  time.Time // 快照时刻的过期时间，零值表示不过期
+	existed bool      // 快照时刻键是否存在
+}
+
+// KVIterator 快照迭代器，只能在一个协程中使用
+type KVIterator struct {
+	store      *SkiplistKVStore
+	start, end []byte
+	at         time.Time // 快照时刻，按该时刻判断键是否已过期
+
+	// 以下字段由 store.mutex 保护
+	visited   bool                 // 是否已访问过至少一个位置
+	lastScore float64              // 上一次访问的位置
+	lastKey   []byte               // 上一次访问的位置
+	preserved map[string]*preimage // 尚未遍历到的键的前像
+	pending   []*preimage          // preserved 中快照时刻存在的键，按位置排序
+	done      bool                 // 跳表和前像都已遍历完
+
+	batch     []KVPair
+	index     int
+	current   KVPair
+	closeOnce sync.Once
+}
+
+// Iterate 创建 [start, end) 范围内的快照迭代器，nil 表示不限制；遍历完毕或不再需要时应调用 Close
+func (s *SkiplistKVStore) Iterate(start, end []byte) *KVIterator {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.iterateLocked(start, end)
+}
+
+// iterateLocked 以当前时刻为快照创建迭代器，调用方需持有 mutex 写锁
+func (s *SkiplistKVStore) iterateLocked(start, end []byte) *KVIterator {
+	it := &KVIterator{
+		store:     s,
+		start:     start,
+		end:       end,
+		at:        time.Now(),
+		preserved: make(map[string]*preimage),
+	}
+	s.iterators[it] = struct{}{}
+	return it
+}
+
+// Next 移动到下一个键值对，没有更多键值对时返回 false 并关闭迭代器
+func (it *KVIterator) Next() bool {
+	if it.index >= len(it.batch) {
+		it.batch = it.batch[:0]
+		it.index = 0
+
+		it.store.mutex.RLock()
+		it.store.ttlMutex.RLock()
+		it.fillLocked()
+		it.store.ttlMutex.RUnlock()
+		it.store.mutex.RUnlock()
+
+		if len(it.batch) == 0 {
+			it.Close()
+			return false
+		}
+	}
+	it.current = it.batch[it.index]
+	it.index++
+	return true
+}
+
+// Key 返回当前的键
+func (it *KVIterator) Key() []byte {
+	return it.current.Key
+}
+
+// Value 返回当前的值
+func (it *KVIterator) Value() []byte {
+	return it.current.Value
+}
+
+// ExpireAt 返回当前键在快照时刻的过期时间，零值表示不过期
+func (it *KVIterator) ExpireAt() time.Time {
+	return it.current.ExpireAt
+}
+
+// Close 注销迭代器，之后写入者不再为它保存前像，可重复调用
+func (it *KVIterator) Close() {
+	it.closeOnce.Do(func() {
+		it.store.mutex.Lock()
+		it.closeLocked()
+		it.store.mutex.Unlock()
+	})
+}
+
+// closeLocked 注销迭代器并丢弃前像，调用方需持有 mutex 写锁
+func (it *KVIterator) closeLocked() {
+	delete(it.store.iterators, it)
+	it.preserved = nil
+	it.pending = nil
+	it.done = true
+}
+
+// inRange 判断键是否在迭代范围内
+func (it *KVIterator) inRange(key []byte) bool {
+	return (it.start == nil || bytes.Compare(key, it.start) >= 0) &&
+		(it.end == nil || bytes.Compare(key, it.end) < 0)
+}
+
+// visitedLocked 判断位置 (score, key) 是否已经遍历过
+func (it *KVIterator) visitedLocked(score float64, key []byte) bool {
+	return it.visited && comparePosition(score, key, it.lastScore, it.lastKey) <= 0
+}
+
+// comparePosition 按跳表的排序规则比较两个位置
+func comparePosition(aScore float64, aKey []byte, bScore float64, bKey []byte) int {
+	switch {
+	case aScore < bScore:
+		return -1
+	case aScore > bScore:
+		return 1
+	default:
+		return bytes.Compare(aKey, bKey)
+	}
+}
+
+// preserveLocked 键即将被修改，为尚未遍历到它的迭代器保存前像；调用方需持有 mutex 写锁
+func (s *SkiplistKVStore) preserveLocked(key []byte) {
+	for it := range s.iterators {
+		it.preserveLocked(key)
+	}
+}
+
+// preserveLocked 保存键在快照时刻的状态，已保存过或已遍历过的键不再保存
+func (it *KVIterator) preserveLocked(key []byte) {
+	if !it.inRange(key) {
+		return
+	}
+	if _, exists := it.preserved[string(key)]; exists {
+		return
+	}
+	s := it.store
+	score := s.score(key)
+	if it.visitedLocked(score, key) {
+		return
+	}
+
+	entry := &preimage{key: append([]byte(nil), key...), score: score}
+	if elem := s.data.Search(key, score); elem != nil {
+		entry.existed = true
+		entry.value = elem.Value
+		s.ttlMutex.RLock()
+		entry.expiry = s.ttlData[string(key)]
+		s.ttlMutex.RUnlock()
+	}
+	it.preserved[string(key)] = entry
+
+	if entry.existed {
+		i := sort.Search(len(it.pending), func(i int) bool {
+			return comparePosition(it.pending[i].score, it.pending[i].key, score, key) > 0
+		})
+		it.pending = append(it.pending, nil)
+		copy(it.pending[i+1:], it.pending[i:])
+		it.pending[i] = entry
+	}
+}
+
+// fillLocked 从上一次访问的位置之后读取下一批键值对，把跳表的当前内容与前像归并；
+// 调用方需持有 mutex 和 ttlMutex 的读锁
+func (it *KVIterator) fillLocked() {
+	if it.done {
+		return
+	}
+	s := it.store
+	ordered := s.hasher == nil
+
+	var elem *Element
+	switch {
+	case it.visited:
+		elem = s.data.seek(it.lastScore, it.lastKey, false)
+	case ordered && it.start != nil:
+		elem = s.data.seek(s.score(it.start), it.start, true)
+	default:
+		elem = s.data.First()
+	}
+
+	for len(it.batch) < kvIteratorBatch {
+		// 按字典序排列时，跳表中超出 end 的部分不需要再看
+		if elem != nil && ordered && it.end != nil && bytes.Compare(elem.Key, it.end) >= 0 {
+			elem = nil
+		}
+		var entry *preimage
+		if len(it.pending) > 0 {
+			entry = it.pending[0]
+		}
+		if elem == nil && entry == nil {
+			it.done = true
+			return
+		}
+
+		var order int
+		switch {
+		case entry == nil:
+			order = -1
+		case elem == nil:
+			order = 1
+		default:
+			order = comparePosition(elem.Score, elem.Key, entry.score, entry.key)
+		}
+
+		if order < 0 {
+			// 跳表中的元素位置在前：有前像说明是快照之后插入的键，跳过；否则快照之后没有修改过，输出当前值
+			it.visited, it.lastScore, it.lastKey = true, elem.Score, elem.Key
+			if _, exists := it.preserved[string(elem.Key)]; exists {
+				delete(it.preserved, string(elem.Key))
+			} else if it.inRange(elem.Key) {
+				if expiry, exists := s.ttlData[string(elem.Key)]; !exists || it.at.Before(expiry) {
+					it.batch = append(it.batch, KVPair{Key: elem.Key, Value: elem.Value, ExpireAt: expiry})
+				}
+			}
+			elem = elem.Next[0]
+			continue
+		}
+
+		// 前像位置在前（键在快照之后被删除）或与跳表元素是同一个键（键在快照之后被修改）：输出前像
+		it.visited, it.lastScore, it.lastKey = true, entry.score, entry.key
+		if entry.expiry.IsZero() || it.at.Before(entry.expiry) {
+			it.batch = append(it.batch, KVPair{Key: entry.key, Value: entry.value, ExpireAt: entry.expiry})
+		}
+		delete(it.preserved, string(entry.key))
+		it.pending = it.pending[1:]
+		if order == 0 {
+			elem = elem.Next[0]
+		}
+	}
+}
+
+// seek 返回位置在 (score, key) 之后的第一个元素，inclusive 为 true 时包含该位置本身
+func (sl *SkipList) seek(score float64, key []byte, inclusive bool) *Element {
+	sl.mutex.RLock()
+	defer sl.mutex.RUnlock()
+
+	x := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.Next[i] != nil {
+			order := comparePosition(x.Next[i].Score, x.Next[i].Key, score, key)
+			if order > 0 || (order == 0 && inclusive) {
+				break
+			}
+			x = x.Next[i]
+		}
+	}
+	return x.Next[0]
+}
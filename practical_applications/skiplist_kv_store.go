@@ -19,6 +19,14 @@ Redis的有序集合(Sorted Set)就使用跳表作为底层实现之一。
 - 使用多层链表实现，每层链表是前一层的子集
 - 使用随机函数决定元素在哪一层出现
 - 提供插入、删除、查找和范围查询操作
+- 每个节点在每一层记录到下一个节点跨过的元素数（跨度），沿查找路径累加跨度即可在 O(log n) 内求名次或按名次定位；
+  第0层带前向指针，支持从尾到头的逆序遍历
+- 带TTL的键在进程内共享的时间轮上各注册一个过期定时器，到期时删除，不需要清理协程定期扫描全部键；
+  Expire 为已有的键重新设置过期时间，Persist 取消过期时间，二者都会重新安排或取消定时器
+- 普通键值对默认以键的前8个字节作为保序的分数，跳表顺序即键的字典序，也可以改用哈希值作为分数；
+  有序集合（skiplist_zset.go）以业务分数作为分数，排行榜直接沿跳表顺序输出
+- 快照迭代器（skiplist_kv_iterator.go）分批加锁遍历，写入者为尚未遍历到的键保存前像，输出与快照时刻一致
+- 事务（skiplist_kv_txn.go）复用快照迭代器的前像读取开始时刻的版本，提交时检查写写冲突并在写锁内一次性应用全部写入
 
 应用场景：
 - 键值存储数据库
@@ -36,13 +44,20 @@ Redis的有序集合(Sorted Set)就使用跳表作为底层实现之一。
 
 import (
 	"bytes"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"math"
 	"math/rand"
-	"sort"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/strive/scenario/cache_strategies"
+	"github.com/strive/scenario/concurrency"
+	"github.com/strive/scenario/hashing"
+	"github.com/strive/scenario/keyspace"
+	"github.com/strive/scenario/sizeof"
 )
 
 const (
@@ -61,6 +76,7 @@ type Element struct {
 	Value []byte     // 值
 	Score float64    // 分数（用于排序）
 	Next  []*Element // 指向每一层的下一个元素
+	Span  []int      // 每一层到下一个元素之间跨过的第0层元素数，用于按名次查找
 	Prev  *Element   // 指向前一个元素（仅在第0层）
 }
 
@@ -76,11 +92,20 @@ type SkipList struct {
 
 // SkiplistKVStore 基于跳表的键值存储
 type SkiplistKVStore struct {
-	data     *SkipList            // 跳表数据结构
-	mutex    sync.RWMutex         // 读写锁
-	ttlData  map[string]time.Time // TTL数据
-	ttlMutex sync.RWMutex         // TTL读写锁
-	stopCh   chan struct{}        // 停止清理协程的通道
+	data     *SkipList                          // 跳表数据结构
+	mutex    sync.RWMutex                       // 读写锁
+	ttlData  map[string]time.Time               // TTL数据
+	timers   map[string]*concurrency.WheelTimer // 每个带TTL的键的过期定时器，由 ttlMutex 保护
+	ttlMutex sync.RWMutex                       // TTL读写锁
+	stopOnce sync.Once                          // 保证只关闭一次
+	events   *keyspace.Notifier                 // 键空间事件
+	hasher   hashing.Hasher                     // 计算键的分数，nil 表示使用保序的分数
+
+	zsets     map[string]*ZSet // 按名字保存的有序集合，见 skiplist_zset.go
+	zsetMutex sync.Mutex       // 保护 zsets
+
+	iterators map[*KVIterator]struct{} // 未关闭的快照迭代器，由 mutex 保护，见 skiplist_kv_iterator.go
+	wal       *kvWAL                   // 预写日志，nil 表示不持久化，见 skiplist_kv_wal.go
 }
 
 // NewElement 创建新的跳表元素
@@ -90,6 +115,7 @@ func NewElement(key, value []byte, score float64, level int) *Element {
 		Value: value,
 		Score: score,
 		Next:  make([]*Element, level),
+		Span:  make([]int, level),
 		Prev:  nil,
 	}
 }
@@ -121,13 +147,18 @@ func (sl *SkipList) Insert(key, value []byte, score float64) *Element {
 	sl.mutex.Lock()
 	defer sl.mutex.Unlock()
 
-	// 查找插入位置
+	// 查找插入位置，rank[i] 记录第i层停下的节点之前的元素数
 	update := make([]*Element, MaxLevel)
+	rank := make([]int, MaxLevel)
 	x := sl.head
 
 	for i := sl.level - 1; i >= 0; i-- {
+		if i < sl.level-1 {
+			rank[i] = rank[i+1]
+		}
 		for x.Next[i] != nil && (x.Next[i].Score < score ||
 			(x.Next[i].Score == score && bytes.Compare(x.Next[i].Key, key) < 0)) {
+			rank[i] += x.Span[i]
 			x = x.Next[i]
 		}
 		update[i] = x
@@ -143,7 +174,9 @@ func (sl *SkipList) Insert(key, value []byte, score float64) *Element {
 	level := sl.randomLevel()
 	if level > sl.level {
 		for i := sl.level; i < level; i++ {
+			rank[i] = 0
 			update[i] = sl.head
+			sl.head.Span[i] = sl.length
 		}
 		sl.level = level
 	}
@@ -151,10 +184,17 @@ func (sl *SkipList) Insert(key, value []byte, score float64) *Element {
 	// 创建新节点
 	newElement := NewElement(key, value, score, level)
 
-	// 更新所有相关节点的指针
+	// 更新所有相关节点的指针，新节点把前驱节点原来的跨度分成两段
 	for i := 0; i < level; i++ {
 		newElement.Next[i] = update[i].Next[i]
 		update[i].Next[i] = newElement
+		newElement.Span[i] = update[i].Span[i] - (rank[0] - rank[i])
+		update[i].Span[i] = rank[0] - rank[i] + 1
+	}
+
+	// 新节点没有到达的层，跨过新节点的指针跨度加一
+	for i := level; i < sl.level; i++ {
+		update[i].Span[i]++
 	}
 
 	// 更新前向指针（仅在第0层）
@@ -196,12 +236,14 @@ func (sl *SkipList) Delete(key []byte, score float64) bool {
 		return false // 节点不存在
 	}
 
-	// 更新指针，删除节点
+	// 更新指针和跨度，删除节点
 	for i := 0; i < sl.level; i++ {
-		if update[i].Next[i] != x {
-			break
+		if update[i].Next[i] == x {
+			update[i].Span[i] += x.Span[i] - 1
+			update[i].Next[i] = x.Next[i]
+		} else {
+			update[i].Span[i]--
 		}
-		update[i].Next[i] = x.Next[i]
 	}
 
 	// 更新前向指针
@@ -276,6 +318,126 @@ func (sl *SkipList) Range(minScore, maxScore float64, limit int) []*Element {
 	return result
 }
 
+// RevRange 逆序范围查询，按分数从高到低返回 [minScore, maxScore] 内的元素
+func (sl *SkipList) RevRange(maxScore, minScore float64, limit int) []*Element {
+	sl.mutex.RLock()
+	defer sl.mutex.RUnlock()
+
+	result := make([]*Element, 0)
+
+	// 找到最后一个小于等于maxScore的节点
+	x := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.Next[i] != nil && x.Next[i].Score <= maxScore {
+			x = x.Next[i]
+		}
+	}
+	if x == sl.head {
+		return result
+	}
+
+	// 沿前向指针遍历范围内的所有节点
+	for x != nil && x.Score >= minScore {
+		result = append(result, x)
+
+		if limit > 0 && len(result) >= limit {
+			break
+		}
+
+		x = x.Prev
+	}
+
+	return result
+}
+
+// Rank 返回元素按 (分数, 键) 升序的名次（从0开始），沿各层累加跨度，复杂度 O(log n)
+func (sl *SkipList) Rank(key []byte, score float64) (int, bool) {
+	sl.mutex.RLock()
+	defer sl.mutex.RUnlock()
+
+	traversed := 0
+	x := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.Next[i] != nil && (x.Next[i].Score < score ||
+			(x.Next[i].Score == score && bytes.Compare(x.Next[i].Key, key) <= 0)) {
+			traversed += x.Span[i]
+			x = x.Next[i]
+		}
+		// 停在目标元素上说明已找到
+		if x != sl.head && x.Score == score && bytes.Equal(x.Key, key) {
+			return traversed - 1, true
+		}
+	}
+	return 0, false
+}
+
+// GetByRank 返回升序名次为 rank（从0开始）的元素，越界时返回 nil，复杂度 O(log n)
+func (sl *SkipList) GetByRank(rank int) *Element {
+	sl.mutex.RLock()
+	defer sl.mutex.RUnlock()
+
+	if rank < 0 || rank >= sl.length {
+		return nil
+	}
+	traversed := 0
+	x := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.Next[i] != nil && traversed+x.Span[i] <= rank+1 {
+			traversed += x.Span[i]
+			x = x.Next[i]
+		}
+		if traversed == rank+1 {
+			return x
+		}
+	}
+	return nil
+}
+
+// SkipListIterator 跳表迭代器，正向从 First 到 Last，逆向从 Last 到 First。
+// 每一步单独加读锁，不会长时间阻塞写入；迭代期间其他协程插入或删除的元素可能被看到，也可能被跳过
+type SkipListIterator struct {
+	list    *SkipList
+	current *Element
+	started bool
+	reverse bool
+}
+
+// Iterator 返回从第一个元素开始的正向迭代器
+func (sl *SkipList) Iterator() *SkipListIterator {
+	return &SkipListIterator{list: sl}
+}
+
+// ReverseIterator 返回从最后一个元素开始的逆向迭代器
+func (sl *SkipList) ReverseIterator() *SkipListIterator {
+	return &SkipListIterator{list: sl, reverse: true}
+}
+
+// Next 移动到下一个元素，没有更多元素时返回 false；第一次调用移动到起始元素
+func (it *SkipListIterator) Next() bool {
+	it.list.mutex.RLock()
+	defer it.list.mutex.RUnlock()
+
+	switch {
+	case !it.started && it.reverse:
+		it.current = it.list.tail
+	case !it.started:
+		it.current = it.list.head.Next[0]
+	case it.current == nil:
+		return false
+	case it.reverse:
+		it.current = it.current.Prev
+	default:
+		it.current = it.current.Next[0]
+	}
+	it.started = true
+	return it.current != nil
+}
+
+// Element 返回迭代器当前所在的元素
+func (it *SkipListIterator) Element() *Element {
+	return it.current
+}
+
 // Length 返回跳表元素数量
 func (sl *SkipList) Length() int {
 	sl.mutex.RLock()
@@ -297,52 +459,67 @@ func (sl *SkipList) Last() *Element {
 	return sl.tail
 }
 
-// NewSkiplistKVStore 创建新的基于跳表的键值存储
+// NewSkiplistKVStore 创建新的基于跳表的键值存储，键按字典序排列
 func NewSkiplistKVStore() *SkiplistKVStore {
+	return NewSkiplistKVStoreWithHasher(nil)
+}
+
+// NewSkiplistKVStoreWithHasher 创建使用指定哈希函数计算键分数的存储，键按哈希值排列；
+// hasher 为 nil 时使用保序的分数，键按字典序排列
+func NewSkiplistKVStoreWithHasher(hasher hashing.Hasher) *SkiplistKVStore {
 	store := &SkiplistKVStore{
 		data:    NewSkipList(),
 		ttlData: make(map[string]time.Time),
-		stopCh:  make(chan struct{}),
-	}
+		timers:  make(map[string]*concurrency.WheelTimer),
+		events:  keyspace.NewNotifier(keyspace.DefaultBufferSize),
+		hasher:  hasher,
+		zsets:   make(map[string]*ZSet),
 
-	// 启动TTL清理协程
-	go store.ttlCleaner()
+		iterators: make(map[*KVIterator]struct{}),
+	}
 
 	return store
 }
 
-// ttlCleaner 定期清理过期数据
-func (s *SkiplistKVStore) ttlCleaner() {
-	ticker := time.NewTicker(1 * time.Second)
-	defer ticker.Stop()
+// setTTLLocked 记录键的过期时间并在共享时间轮上重新安排过期定时器，调用方需持有 ttlMutex
+func (s *SkiplistKVStore) setTTLLocked(key string, ttl time.Duration) {
+	s.clearTTLLocked(key)
+	s.ttlData[key] = time.Now().Add(ttl)
+	s.timers[key] = concurrency.SharedTimingWheel().AfterFunc(ttl, func() {
+		s.expire([]byte(key))
+	})
+}
 
-	for {
-		select {
-		case <-ticker.C:
-			s.cleanExpiredKeys()
-		case <-s.stopCh:
-			return
-		}
+// clearTTLLocked 删除键的过期时间并取消过期定时器，调用方需持有 ttlMutex
+func (s *SkiplistKVStore) clearTTLLocked(key string) {
+	if timer, ok := s.timers[key]; ok {
+		timer.Stop()
+		delete(s.timers, key)
 	}
+	delete(s.ttlData, key)
 }
 
-// cleanExpiredKeys 清理过期的键
-func (s *SkiplistKVStore) cleanExpiredKeys() {
-	now := time.Now()
-	expiredKeys := make([]string, 0)
+// expire 删除已过期的键并发布过期事件；如果键在此期间被重新写入则保留
+func (s *SkiplistKVStore) expire(key []byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 
-	// 找出所有过期的键
-	s.ttlMutex.RLock()
-	for key, expiry := range s.ttlData {
-		if expiry.Before(now) {
-			expiredKeys = append(expiredKeys, key)
-		}
+	s.ttlMutex.Lock()
+	expiry, exists := s.ttlData[string(key)]
+	if !exists || time.Now().Before(expiry) {
+		s.ttlMutex.Unlock()
+		return
 	}
-	s.ttlMutex.RUnlock()
+	s.ttlMutex.Unlock()
 
-	// 删除过期的键
-	for _, key := range expiredKeys {
-		s.Delete([]byte(key))
+	s.preserveLocked(key)
+	s.log(walRecord{op: walExpire, key: key, expireAt: expiry})
+	s.ttlMutex.Lock()
+	s.clearTTLLocked(string(key))
+	s.ttlMutex.Unlock()
+
+	if s.data.Delete(key, s.score(key)) {
+		s.events.Publish(keyspace.EventExpired, string(key))
 	}
 }
 
@@ -351,14 +528,8 @@ func (s *SkiplistKVStore) Set(key, value []byte) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	// 使用键的哈希值作为分数，确保唯一性
-	score := float64(hashBytes(key))
-	s.data.Insert(key, value, score)
-
-	// 删除可能存在的TTL
-	s.ttlMutex.Lock()
-	delete(s.ttlData, string(key))
-	s.ttlMutex.Unlock()
+	s.log(walRecord{op: walSet, key: key, value: value})
+	s.setLocked(key, value, 0)
 }
 
 // SetWithTTL 设置带过期时间的键值对
@@ -366,13 +537,31 @@ func (s *SkiplistKVStore) SetWithTTL(key, value []byte, ttl time.Duration) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	score := float64(hashBytes(key))
+	// ttl 小于等于0表示不过期，日志中的过期时间留空，否则重放时会被当作已经过期
+	rec := walRecord{op: walSet, key: key, value: value}
+	if ttl > 0 {
+		rec.expireAt = time.Now().Add(ttl)
+	}
+	s.log(rec)
+	s.setLocked(key, value, ttl)
+}
+
+// setLocked 写入键值对，ttl 小于等于0表示不过期；不写日志，调用方需持有 mutex 写锁
+func (s *SkiplistKVStore) setLocked(key, value []byte, ttl time.Duration) {
+	s.preserveLocked(key)
+	score := s.score(key)
 	s.data.Insert(key, value, score)
 
-	// 设置TTL
+	// 设置TTL，或删除可能存在的TTL
 	s.ttlMutex.Lock()
-	s.ttlData[string(key)] = time.Now().Add(ttl)
+	if ttl > 0 {
+		s.setTTLLocked(string(key), ttl)
+	} else {
+		s.clearTTLLocked(string(key))
+	}
 	s.ttlMutex.Unlock()
+
+	s.events.Publish(keyspace.EventSet, string(key))
 }
 
 // Get 获取键对应的值
@@ -385,12 +574,12 @@ func (s *SkiplistKVStore) Get(key []byte) ([]byte, error) {
 	if expiry, exists := s.ttlData[string(key)]; exists && time.Now().After(expiry) {
 		s.ttlMutex.RUnlock()
 		// 懒惰删除
-		go s.Delete(key)
+		go s.expire(key)
 		return nil, ErrKeyNotFound
 	}
 	s.ttlMutex.RUnlock()
 
-	score := float64(hashBytes(key))
+	score := s.score(key)
 	elem := s.data.Search(key, score)
 
 	if elem == nil {
@@ -405,17 +594,80 @@ func (s *SkiplistKVStore) Delete(key []byte) bool {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	score := float64(hashBytes(key))
+	result := s.deleteLocked(key)
+	if result {
+		s.log(walRecord{op: walDelete, key: key})
+	}
+	return result
+}
+
+// deleteLocked 删除键，键存在时返回 true；不写日志，调用方需持有 mutex 写锁
+func (s *SkiplistKVStore) deleteLocked(key []byte) bool {
+	s.preserveLocked(key)
+	score := s.score(key)
 	result := s.data.Delete(key, score)
 
 	// 删除TTL
 	s.ttlMutex.Lock()
-	delete(s.ttlData, string(key))
+	s.clearTTLLocked(string(key))
 	s.ttlMutex.Unlock()
 
+	if result {
+		s.events.Publish(keyspace.EventDelete, string(key))
+	}
 	return result
 }
 
+// Expire 为已存在的键设置过期时间，覆盖原来的过期时间；ttl 小于等于0时立即删除键。键不存在时返回 false
+func (s *SkiplistKVStore) Expire(key []byte, ttl time.Duration) bool {
+	if ttl <= 0 {
+		return s.Delete(key)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data.Search(key, s.score(key)) == nil {
+		return false
+	}
+	s.ttlMutex.RLock()
+	expiry, exists := s.ttlData[string(key)]
+	s.ttlMutex.RUnlock()
+	if exists && !time.Now().Before(expiry) {
+		return false // 已过期，等待删除
+	}
+
+	s.preserveLocked(key)
+	s.log(walRecord{op: walExpire, key: key, expireAt: time.Now().Add(ttl)})
+	s.ttlMutex.Lock()
+	s.setTTLLocked(string(key), ttl)
+	s.ttlMutex.Unlock()
+	return true
+}
+
+// Persist 取消键的过期时间，键不存在、已过期或本来就没有过期时间时返回 false
+func (s *SkiplistKVStore) Persist(key []byte) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.data.Search(key, s.score(key)) == nil {
+		return false
+	}
+	s.ttlMutex.RLock()
+	expiry, exists := s.ttlData[string(key)]
+	s.ttlMutex.RUnlock()
+	if !exists || !time.Now().Before(expiry) {
+		return false
+	}
+
+	s.preserveLocked(key)
+	s.log(walRecord{op: walExpire, key: key}) // 零值过期时间表示取消过期
+	s.ttlMutex.Lock()
+	s.clearTTLLocked(string(key))
+	s.ttlMutex.Unlock()
+	return true
+}
+
 // GetTTL 获取键的剩余过期时间
 func (s *SkiplistKVStore) GetTTL(key []byte) (time.Duration, bool) {
 	s.ttlMutex.RLock()
@@ -488,9 +740,39 @@ func (s *SkiplistKVStore) SizeActive() int {
 	return count
 }
 
-// Close 关闭存储
+// MemoryUsage 返回深度估算的内存占用（字节）
+func (s *SkiplistKVStore) MemoryUsage() int64 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	s.ttlMutex.RLock()
+	defer s.ttlMutex.RUnlock()
+	s.zsetMutex.Lock()
+	defer s.zsetMutex.Unlock()
+	return sizeof.Of(s)
+}
+
+// Subscribe 订阅匹配 pattern 的键的 set/del/expired 事件，存储关闭时通道随之关闭
+func (s *SkiplistKVStore) Subscribe(pattern string) <-chan keyspace.KeyEvent {
+	return s.events.Subscribe(pattern)
+}
+
+// Unsubscribe 取消订阅并关闭通道
+func (s *SkiplistKVStore) Unsubscribe(ch <-chan keyspace.KeyEvent) bool {
+	return s.events.Unsubscribe(ch)
+}
+
+// Close 关闭存储并取消已安排的过期定时器，启用预写日志时刷盘并关闭日志，可重复调用
 func (s *SkiplistKVStore) Close() {
-	close(s.stopCh) // 停止TTL清理协程
+	s.stopOnce.Do(func() {
+		s.ttlMutex.Lock()
+		for key, timer := range s.timers {
+			timer.Stop()
+			delete(s.timers, key)
+		}
+		s.ttlMutex.Unlock()
+		s.events.Close()
+		s.closeWAL()
+	})
 }
 
 // Scan 范围扫描
@@ -521,16 +803,43 @@ func (s *SkiplistKVStore) Scan(prefix []byte, limit int) map[string][]byte {
 	return result
 }
 
-// 计算字节数组的哈希值
-func hashBytes(data []byte) uint64 {
-	var hash uint64 = 14695981039346656037 // FNV-1a 哈希初始值
+// skiplistBackingStore 把 SkiplistKVStore 适配为二级缓存的慢速存储
+type skiplistBackingStore struct {
+	store *SkiplistKVStore
+}
+
+// AsBackingStore 返回以本存储为底层的 BackingStore，可作为 cache_strategies.TieredCache 的第二级
+func (s *SkiplistKVStore) AsBackingStore() cache_strategies.BackingStore {
+	return skiplistBackingStore{store: s}
+}
 
-	for _, b := range data {
-		hash ^= uint64(b)
-		hash *= 1099511628211 // FNV-1a 质数
+func (b skiplistBackingStore) Load(key string) ([]byte, error) {
+	value, err := b.store.Get([]byte(key))
+	if errors.Is(err, ErrKeyNotFound) {
+		return nil, fmt.Errorf("%w: %s", cache_strategies.ErrStoreKeyNotFound, key)
 	}
+	return value, err
+}
+
+func (b skiplistBackingStore) Store(key string, value []byte) error {
+	b.store.Set([]byte(key), value)
+	return nil
+}
 
-	return hash
+func (b skiplistBackingStore) Delete(key string) error {
+	b.store.Delete([]byte(key))
+	return nil
+}
+
+// score 计算键在跳表中的分数。保序分数取键的前8个字节按大端序解释，
+// 前缀小的键分数不会更大，分数相同时跳表再按完整的键比较，因此整体顺序就是键的字典序
+func (s *SkiplistKVStore) score(key []byte) float64 {
+	if s.hasher != nil {
+		return float64(s.hasher.Sum64(key))
+	}
+	var prefix [8]byte
+	copy(prefix[:], key)
+	return float64(binary.BigEndian.Uint64(prefix[:]))
 }
 
 // 展示跳表的可视化结构（用于调试）
@@ -559,9 +868,10 @@ func (sl *SkipList) visualize() string {
 func SkiplistKVStoreDemo() {
 	fmt.Println("基于跳表的键值存储示例 - 游戏排行榜系统:")
 
-	// 创建键值存储
+	// 创建键值存储，玩家资料保存为普通键值对，积分保存在有序集合中
 	store := NewSkiplistKVStore()
 	defer store.Close()
+	leaderboard := store.ZSet(leaderboardName)
 
 	// 模拟游戏玩家数据
 	players := []struct {
@@ -585,6 +895,7 @@ func SkiplistKVStoreDemo() {
 		key := []byte(p.ID)
 		value := []byte(fmt.Sprintf("%s|%d", p.Name, p.Score))
 		store.Set(key, value)
+		leaderboard.ZAdd(key, float64(p.Score))
 		fmt.Printf("添加玩家: %s, 分数: %d\n", p.Name, p.Score)
 	}
 
@@ -615,6 +926,7 @@ func SkiplistKVStoreDemo() {
 		// 更新数据，并加入7天TTL（模拟一周内有效的分数）
 		value := []byte(fmt.Sprintf("%s|%d", name, newScore))
 		store.SetWithTTL(key, value, 7*24*time.Hour)
+		leaderboard.ZAdd(key, float64(newScore))
 		fmt.Printf("更新玩家: %s, 新分数: %d（有效期7天）\n", name, newScore)
 	}
 
@@ -631,11 +943,26 @@ func SkiplistKVStoreDemo() {
 	name := parts[0]
 
 	fmt.Printf("设置玩家 %s 的数据过期（1秒后）\n", name)
+	expirations := store.Subscribe("player:*")
 	store.SetWithTTL([]byte(expiringPlayer), oldData, 1*time.Second)
 
-	// 等待数据过期
-	fmt.Println("等待1秒钟...")
-	time.Sleep(1500 * time.Millisecond)
+	// 通过键空间事件等待数据过期，而不是轮询
+	fmt.Println("等待过期事件...")
+	for waiting := true; waiting; {
+		select {
+		case event := <-expirations:
+			if event.Type == keyspace.EventExpired {
+				// 玩家资料过期后把玩家移出排行榜
+				leaderboard.ZRem([]byte(event.Key))
+				fmt.Printf("收到事件: %s %s，已移出排行榜\n", event.Type, event.Key)
+				waiting = false
+			}
+		case <-time.After(3 * time.Second):
+			fmt.Println("等待超时")
+			waiting = false
+		}
+	}
+	store.Unsubscribe(expirations)
 
 	// 6. 玩家过期后的排行榜
 	fmt.Println("\n6. 玩家数据过期后的排行榜:")
@@ -661,59 +988,72 @@ func SkiplistKVStoreDemo() {
 	fmt.Printf("跳表元素数量: %d\n", skipList.Length())
 
 	// 10. 示范基于跳表的范围查询能力
-	fmt.Println("\n10. 范围查询示例 (比如查询分数在8500-9500之间的玩家):")
-	fmt.Println("注意：实际应用中需要将玩家分数作为跳表的分数字段，这里只是演示")
-	fmt.Println("在真实应用中，我们会使用专门的排序键或独立的跳表索引")
-}
-
-// 构建并显示排行榜
-func buildLeaderboard(store *SkiplistKVStore) {
-	// 获取所有玩家数据
-	keys := store.Keys()
-
-	// 解析并排序
-	type PlayerScore struct {
-		ID    string
-		Name  string
-		Score int
+	fmt.Println("\n10. 有序集合查询:")
+	fmt.Println("分数在8500-9500之间的玩家（沿跳表顺序直接取出，按分数升序）:")
+	for _, m := range leaderboard.ZRangeByScore(8500, 9500, 0) {
+		fmt.Printf("  %s: %.0f\n", m.Member, m.Score)
+	}
+	zhao := []byte("player:1004")
+	before, _ := leaderboard.ZRevRank(zhao)
+	score := leaderboard.ZIncrBy(zhao, 800)
+	after, _ := leaderboard.ZRevRank(zhao)
+	fmt.Printf("赵六完成任务加800分: 新分数 %.0f，名次 第%d名 → 第%d名\n", score, before+1, after+1)
+	if score, ok := leaderboard.ZScore([]byte("player:1001")); ok {
+		rank, _ := leaderboard.ZRank([]byte("player:1001"))
+		fmt.Printf("张三: 分数 %.0f，升序名次 %d（从0开始），共 %d 名玩家\n", score, rank, leaderboard.ZCard())
+	}
+	fmt.Println("排行榜第二页（每页3名，第4-6名）:")
+	for i, m := range leaderboard.ZRevRange(3, 5) {
+		fmt.Printf("  第%d名: %s - %.0f分\n", i+4, m.Member, m.Score)
 	}
 
-	players := make([]PlayerScore, 0, len(keys))
-
-	for _, key := range keys {
-		if !bytes.HasPrefix(key, []byte("player:")) {
-			continue
-		}
-
-		data, err := store.Get(key)
-		if err != nil {
-			continue
-		}
-
-		parts := strings.Split(string(data), "|")
-		if len(parts) != 2 {
-			continue
+	// 11. 作为二级缓存的底层存储：热门玩家的资料留在内存LRU中，其余从跳表读取
+	fmt.Println("\n11. 作为二级缓存的底层存储 (内存LRU容量=2，写穿):")
+	tiered := cache_strategies.NewTieredCache(store.AsBackingStore(), cache_strategies.TieredCacheOptions{Capacity: 2})
+	defer tiered.Close()
+	for _, id := range []string{"player:1007", "player:1004", "player:1007", "player:1007", "player:1001"} {
+		tiered.Get(id)
+	}
+	tiered.Put("player:1009", []byte("钱十一|8800"))
+	profile, _ := store.Get([]byte("player:1009"))
+	stats := tiered.Stats()
+	fmt.Printf("读取5次: 内存命中 %v 次，读跳表 %v 次；写穿后跳表中 player:1009 = %s\n",
+		stats["hits"], stats["storeReads"], profile)
+
+	// 12. 快照迭代器：导出玩家资料的过程中发生写入，导出结果仍是开始时刻的状态
+	fmt.Println("\n12. 快照迭代器（按键的字典序导出玩家资料，导出途中有写入）:")
+	// "player;" 是字典序上紧接在所有 "player:" 前缀之后的键
+	it := store.Iterate([]byte("player:"), []byte("player;"))
+	defer it.Close()
+	exported := 0
+	for it.Next() {
+		fmt.Printf("  导出 %s: %s\n", it.Key(), it.Value())
+		exported++
+		if exported == 2 {
+			store.Set([]byte("player:1007"), []byte("吴九|9999"))
+			store.Delete([]byte("player:1008"))
+			store.Set([]byte("player:1010"), []byte("王十二|8600"))
+			fmt.Println("  （导出途中：修改 player:1007、删除 player:1008、新增 player:1010）")
 		}
-
-		name := parts[0]
-		var score int
-		fmt.Sscanf(parts[1], "%d", &score)
-
-		players = append(players, PlayerScore{
-			ID:    string(key),
-			Name:  name,
-			Score: score,
-		})
 	}
+	fmt.Printf("共导出 %d 个玩家，与导出开始时一致；当前存储中的玩家数: %d\n",
+		exported, len(store.Scan([]byte("player:"), 0)))
+}
 
-	// 按分数排序（从高到低）
-	sort.Slice(players, func(i, j int) bool {
-		return players[i].Score > players[j].Score
-	})
+// leaderboardName 排行榜有序集合的名字
+const leaderboardName = "leaderboard"
 
-	// 显示排行榜
+// 构建并显示排行榜：积分顺序直接来自有序集合的逆序遍历，玩家名从键值对中读取
+func buildLeaderboard(store *SkiplistKVStore) {
 	fmt.Println("排行榜（按分数从高到低）:")
-	for i, p := range players {
-		fmt.Printf("  第%d名: %s - %d分\n", i+1, p.Name, p.Score)
+	rank := 0
+	for _, m := range store.ZSet(leaderboardName).ZRevRangeByScore(math.Inf(1), math.Inf(-1), 0) {
+		data, err := store.Get(m.Member)
+		if err != nil {
+			continue
+		}
+		name, _, _ := strings.Cut(string(data), "|")
+		rank++
+		fmt.Printf("  第%d名: %s - %.0f分\n", rank, name, m.Score)
 	}
 }
@@ -0,0 +1,347 @@
+package practical_applications
+
+/*
+跳表键值存储的多版本并发控制（MVCC）事务
+
+原理：
+单个 Set、Delete 是原子的，但转账这类操作要读两个账户、再写两个账户：
+如果两次写入之间被其他协程读到，会看到钱凭空少了一笔；如果两个转账同时读到同一个余额再各自写回，
+其中一笔扣款会被覆盖（丢失更新）。事务把一组读写打包成一个整体：
+读取看到的是事务开始时刻的一致快照（快照隔离），写入先缓存在事务内，提交时一次性、原子地生效。
+快照的实现沿用快照迭代器的前像机制：事务开始时注册一个覆盖全部键的快照，
+之后任何写入者修改某个键之前都会先把该键在快照时刻的版本保存下来，
+于是每个键都有"当前版本"和"快照时刻版本"两个版本，事务读取时优先读快照时刻的版本。
+提交时检查写集合中的键在事务开始之后是否被其他人修改过（即是否保存过前像），
+有则说明发生了写写冲突，按"先提交者胜"的规则放弃后提交的事务，由调用方重试。
+
+关键特点：
+1. Begin 开始事务，Get 读取快照并能读到事务自己未提交的写入，Set、SetWithTTL、Delete 把修改缓存在事务内
+2. Commit 在存储写锁内检查冲突并应用全部修改，其他读者要么看到全部修改，要么一个都看不到；
+   有冲突时返回 ErrTxnConflict，事务的修改全部丢弃
+3. Rollback 丢弃修改并释放快照，提交或回滚之后事务不能再使用，再次调用返回 ErrTxnDone
+4. 启用预写日志时，一个事务的全部修改写成一条 Batch 记录，崩溃恢复后不会出现只生效一半的事务
+5. 只读事务（写集合为空）提交时不做冲突检查，总能成功
+
+实现方式：
+- 事务持有一个以开始时刻为快照、范围不限的 KVIterator，但从不遍历它，只利用写入者为它保存的前像
+- 写集合按键保存最后一次写入（值与 TTL，或删除标记），提交时按键的顺序应用，日志和事件的顺序是确定的
+- 冲突检测只看写集合：快照隔离允许写偏斜（两个事务各自读对方要写的键、写入不相交的键），
+  需要可串行化时，可以把读到的关键键也原样写回，让它进入写集合参与冲突检测
+- 事务开始之后被删除或过期的键同样会保存前像，因此也算作冲突
+
+应用场景：
+- 账户之间转账、库存扣减等需要多键原子更新的业务
+- 需要一致视图的多键读取（例如同时读取配置的多个字段）
+- 乐观并发控制：冲突很少时无锁等待，冲突时重试
+
+优缺点：
+- 优点：读不阻塞写、写不阻塞读，事务执行期间不持有锁；没有活跃事务时普通写入没有额外开销
+- 缺点：事务未结束期间，每次写入都要为它保存前像，长事务会积累大量前像；冲突时需要调用方重试
+
+以下实现了事务，以及多个协程并发转账、总余额保持不变的示例。
+*/
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	ErrTxnConflict = errors.New("事务冲突：写入的键在事务开始之后已被修改")
+	ErrTxnDone     = errors.New("事务已经提交或回滚")
+)
+
+// txnWrite 事务内对一个键的最后一次写入
+type txnWrite struct {
+	key     []byte
+	value   []byte
+	ttl     time.Duration // 小于等于0表示不过期
+	deleted bool
+}
+
+// Txn 快照隔离的事务，只能在一个协程中使用
+type Txn struct {
+	store    *SkiplistKVStore
+	snapshot *KVIterator          // 只用来收集前像，从不遍历
+	writes   map[string]*txnWrite // 写集合
+	done     bool
+}
+
+// Begin 以当前时刻为快照开始一个事务，使用完毕后必须调用 Commit 或 Rollback
+func (s *SkiplistKVStore) Begin() *Txn {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return &Txn{
+		store:    s,
+		snapshot: s.iterateLocked(nil, nil),
+		writes:   make(map[string]*txnWrite),
+	}
+}
+
+// Get 读取键：事务内写过的键返回事务内的值，否则返回快照时刻的值
+func (t *Txn) Get(key []byte) ([]byte, error) {
+	if t.done {
+		return nil, ErrTxnDone
+	}
+	if w, exists := t.writes[string(key)]; exists {
+		if w.deleted {
+			return nil, ErrKeyNotFound
+		}
+		return w.value, nil
+	}
+
+	s := t.store
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	// 快照之后被修改过的键读前像，否则当前版本就是快照时刻的版本
+	if entry, exists := t.snapshot.preserved[string(key)]; exists {
+		if !entry.existed || (!entry.expiry.IsZero() && !t.snapshot.at.Before(entry.expiry)) {
+			return nil, ErrKeyNotFound
+		}
+		return entry.value, nil
+	}
+	elem := s.data.Search(key, s.score(key))
+	if elem == nil {
+		return nil, ErrKeyNotFound
+	}
+	s.ttlMutex.RLock()
+	expiry, exists := s.ttlData[string(key)]
+	s.ttlMutex.RUnlock()
+	if exists && !t.snapshot.at.Before(expiry) {
+		return nil, ErrKeyNotFound
+	}
+	return elem.Value, nil
+}
+
+// Set 在事务内写入键值对
+func (t *Txn) Set(key, value []byte) error {
+	return t.write(&txnWrite{key: key, value: value})
+}
+
+// SetWithTTL 在事务内写入带过期时间的键值对，过期时间从提交时开始计算
+func (t *Txn) SetWithTTL(key, value []byte, ttl time.Duration) error {
+	return t.write(&txnWrite{key: key, value: value, ttl: ttl})
+}
+
+// Delete 在事务内删除键
+func (t *Txn) Delete(key []byte) error {
+	return t.write(&txnWrite{key: key, deleted: true})
+}
+
+// write 记录写入，同一个键只保留最后一次；复制键和值，调用方之后修改切片不影响事务
+func (t *Txn) write(w *txnWrite) error {
+	if t.done {
+		return ErrTxnDone
+	}
+	w.key = append([]byte(nil), w.key...)
+	if w.value != nil {
+		w.value = append([]byte(nil), w.value...)
+	}
+	t.writes[string(w.key)] = w
+	return nil
+}
+
+// Commit 检查冲突并原子地应用全部写入；有冲突时返回 ErrTxnConflict，事务的写入全部丢弃
+func (t *Txn) Commit() error {
+	if t.done {
+		return ErrTxnDone
+	}
+	t.done = true
+	s := t.store
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// 先提交者胜：写集合中的键在快照之后被修改过（保存过前像）就放弃
+	conflict := false
+	for key := range t.writes {
+		if _, modified := t.snapshot.preserved[key]; modified {
+			conflict = true
+			break
+		}
+	}
+	// 注销快照，之后应用自己的写入时不再为它保存前像
+	t.snapshot.closeLocked()
+	if conflict {
+		return ErrTxnConflict
+	}
+	if len(t.writes) == 0 {
+		return nil
+	}
+
+	writes := make([]*txnWrite, 0, len(t.writes))
+	for _, w := range t.writes {
+		writes = append(writes, w)
+	}
+	sort.Slice(writes, func(i, j int) bool {
+		return bytes.Compare(writes[i].key, writes[j].key) < 0
+	})
+
+	if s.wal != nil {
+		now := time.Now()
+		var batch []byte
+		for _, w := range writes {
+			rec := walRecord{op: walSet, key: w.key, value: w.value}
+			if w.deleted {
+				rec = walRecord{op: walDelete, key: w.key}
+			} else if w.ttl > 0 {
+				rec.expireAt = now.Add(w.ttl)
+			}
+			batch = append(batch, encodeWALRecord(rec)...)
+		}
+		s.log(walRecord{op: walBatch, value: batch})
+	}
+
+	for _, w := range writes {
+		if w.deleted {
+			s.deleteLocked(w.key)
+		} else {
+			s.setLocked(w.key, w.value, w.ttl)
+		}
+	}
+	return nil
+}
+
+// Rollback 丢弃事务的全部写入并释放快照
+func (t *Txn) Rollback() error {
+	if t.done {
+		return ErrTxnDone
+	}
+	t.done = true
+	t.snapshot.Close()
+	return nil
+}
+
+// Update 在事务中执行 fn 并提交，遇到冲突时重新开始，最多尝试 attempts 次；
+// fn 返回错误时回滚并原样返回该错误
+func (s *SkiplistKVStore) Update(attempts int, fn func(txn *Txn) error) error {
+	var err error
+	for i := 0; i < attempts; i++ {
+		txn := s.Begin()
+		if err = fn(txn); err != nil {
+			txn.Rollback()
+			return err
+		}
+		if err = txn.Commit(); err != ErrTxnConflict {
+			return err
+		}
+	}
+	return err
+}
+
+// 场景示例：多个协程在账户之间并发转账，事务保证余额不会凭空增减
+func SkiplistTxnDemo() {
+	fmt.Println("跳表键值存储的 MVCC 事务示例:")
+
+	store := NewSkiplistKVStore()
+	defer store.Close()
+
+	const accounts = 5
+	const initial = 1000
+	accountKey := func(i int) []byte { return []byte(fmt.Sprintf("account:%02d", i)) }
+	for i := 0; i < accounts; i++ {
+		store.Set(accountKey(i), []byte(strconv.Itoa(initial)))
+	}
+	balance := func(txn *Txn, key []byte) (int, error) {
+		value, err := txn.Get(key)
+		if err != nil {
+			return 0, err
+		}
+		return strconv.Atoi(string(value))
+	}
+	errInsufficient := errors.New("余额不足")
+	transfer := func(txn *Txn, from, to []byte, amount int) error {
+		fromBalance, err := balance(txn, from)
+		if err != nil {
+			return err
+		}
+		toBalance, err := balance(txn, to)
+		if err != nil {
+			return err
+		}
+		if fromBalance < amount {
+			return errInsufficient
+		}
+		txn.Set(from, []byte(strconv.Itoa(fromBalance-amount)))
+		txn.Set(to, []byte(strconv.Itoa(toBalance+amount)))
+		return nil
+	}
+
+	// 1. 快照隔离：事务开始后其他人的写入不可见，事务自己的写入可见
+	fmt.Println("\n1. 快照隔离:")
+	txn := store.Begin()
+	store.Set(accountKey(0), []byte("5000"))
+	before, _ := balance(txn, accountKey(0))
+	fmt.Printf("事务开始后 account:00 被改为5000，事务内读到 %d\n", before)
+	txn.Set(accountKey(1), []byte("0"))
+	own, _ := balance(txn, accountKey(1))
+	current, _ := store.Get(accountKey(1))
+	fmt.Printf("事务内把 account:01 改为0：事务内读到 %d，提交前其他人读到 %s\n", own, current)
+	txn.Rollback()
+	store.Set(accountKey(0), []byte(strconv.Itoa(initial)))
+
+	// 2. 写写冲突：两个事务同时给同一个账户转账，后提交的失败
+	fmt.Println("\n2. 写写冲突:")
+	first, second := store.Begin(), store.Begin()
+	transfer(first, accountKey(0), accountKey(1), 100)
+	transfer(second, accountKey(0), accountKey(2), 200)
+	fmt.Printf("事务1提交: %v\n", first.Commit())
+	fmt.Printf("事务2提交: %v\n", second.Commit())
+
+	// 3. 并发转账：冲突的事务自动重试，结束后总余额不变
+	fmt.Println("\n3. 并发转账:")
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	committed, rejected, conflicts := 0, 0, 0
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				from, to := (w+i)%accounts, (w*3+i*7+1)%accounts
+				if from == to {
+					to = (to + 1) % accounts
+				}
+				tries := 0
+				err := store.Update(100, func(txn *Txn) error {
+					tries++
+					if err := transfer(txn, accountKey(from), accountKey(to), 10+(w*i)%90); err != nil {
+						return err
+					}
+					runtime.Gosched() // 模拟提交前的业务处理，让其他转账有机会先提交
+					return nil
+				})
+				mu.Lock()
+				conflicts += tries - 1
+				switch err {
+				case nil:
+					committed++
+				case errInsufficient:
+					rejected++
+				default:
+					fmt.Printf("转账失败: %v\n", err)
+				}
+				mu.Unlock()
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	total := 0
+	check := store.Begin()
+	for i := 0; i < accounts; i++ {
+		b, _ := balance(check, accountKey(i))
+		fmt.Printf("  account:%02d = %d\n", i, b)
+		total += b
+	}
+	check.Commit()
+	fmt.Printf("成功 %d 笔，余额不足 %d 笔，冲突重试 %d 次；总余额 %d（初始 %d）\n",
+		committed, rejected, conflicts, total, accounts*initial)
+}
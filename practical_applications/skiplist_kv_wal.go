@@ -0,0 +1,795 @@
+package practical_applications
+
+/*
+跳表键值存储的预写日志（WAL）与崩溃恢复
+
+原理：
+SkiplistKVStore 的数据只在内存中，进程退出后全部丢失。预写日志把每一次修改（写入、删除、过期时间变化）
+在修改内存之前追加到磁盘上的日志文件，进程重启时按顺序重放日志，即可恢复到退出前的状态。
+日志只追加不修改，顺序写磁盘很快；但日志会无限增长，重放也越来越慢，
+因此定期把内存中的全部数据写成快照，快照之前的日志就可以删除，恢复时先加载快照，再重放快照之后的日志。
+
+关键特点：
+1. 四种日志记录：Set（键、值、绝对过期时间）、Delete（键）、Expire（键的过期时间变为指定的绝对时间，零值表示取消过期），
+   以及把事务的全部修改打包在一起的 Batch；每条记录带有单调递增的日志序号（LSN）
+2. 三种刷盘策略：每次写入都 fsync（最安全，最慢）；每隔固定时间 fsync（操作系统崩溃时最多丢失一个间隔的数据）；
+   从不主动 fsync（由操作系统决定）。每条记录都立即交给操作系统，因此进程崩溃不会丢数据，
+   刷盘策略只影响操作系统崩溃或断电时丢失多少数据
+3. 每条记录带 CRC32 校验和长度，崩溃时写了一半的记录在重放时被识别并丢弃，不会把损坏的数据读进内存；
+   事务的修改在同一条 Batch 记录中，恢复后要么全部生效，要么全部不生效
+4. 快照基于快照迭代器生成，不阻塞写入；生成快照时同时切换到新的日志段，快照恰好对应切换前的最后一个 LSN
+5. 过期时间以绝对时间保存，停机期间到期的键在全部日志重放完毕后丢弃；
+   重放过程中先保留这些键，因为之后的 Expire 记录可能延长或取消它们的过期时间
+
+实现方式：
+- 日志按段保存为 wal-<首条记录的LSN>.log，快照保存为 snapshot-<LSN>.dat；快照先写临时文件、fsync 后再重命名，
+  成功后删除它已经覆盖的旧日志段和旧快照
+- 写入者在持有存储写锁时追加日志，日志顺序与内存中的修改顺序一致
+- 恢复时加载 LSN 最大的快照，再按 LSN 顺序重放之后的日志段，每个日志段读到第一条损坏的记录为止
+- 追加日志失败后记录错误，之后的写入只修改内存，错误通过 WALError 返回（Close 之后也可以查询）
+- 有序集合（ZSet）不写入日志
+- 配置 WriteLimiter 后日志段和快照的写入都经过 RateLimitedWriter 限速，避免持久化占满磁盘带宽
+
+应用场景：
+- 需要在进程重启后保留数据的嵌入式存储
+- Redis AOF、数据库 redo log 的简化版
+- 配合快照实现冷备份
+
+优缺点：
+- 优点：写入只多一次顺序追加，崩溃后可以恢复到最后一条完整的记录；刷盘策略可以在安全和性能之间取舍
+- 缺点：每次 fsync 的策略下写入吞吐受磁盘延迟限制，且 fsync 在存储写锁内进行；恢复时间与快照之后的日志量成正比
+
+以下实现了预写日志、快照和恢复，以及模拟进程崩溃后恢复数据的示例。
+*/
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WALSyncPolicy 日志刷盘策略
+type WALSyncPolicy int
+
+const (
+	WALSyncAlways   WALSyncPolicy = iota // 每次写入后 fsync
+	WALSyncInterval                      // 每隔 SyncInterval 在后台 fsync
+	WALSyncNever                         // 不主动 fsync，由操作系统决定何时写入磁盘
+)
+
+func (p WALSyncPolicy) String() string {
+	switch p {
+	case WALSyncAlways:
+		return "always"
+	case WALSyncInterval:
+		return "interval"
+	case WALSyncNever:
+		return "never"
+	default:
+		return fmt.Sprintf("WALSyncPolicy(%d)", int(p))
+	}
+}
+
+// WALOptions 预写日志配置
+type WALOptions struct {
+	Dir              string        // 日志和快照所在目录，不存在时创建
+	Sync             WALSyncPolicy // 刷盘策略
+	SyncInterval     time.Duration // WALSyncInterval 策略的刷盘间隔
+	SnapshotInterval time.Duration // 定期生成快照的间隔，0 表示只在调用 Snapshot 时生成
+	WriteLimiter     RateLimiter   // 日志和快照的写入限速（字节/秒），nil 表示不限速
+}
+
+// writerFor 按配置包装写入端
+func (o WALOptions) writerFor(w io.Writer) io.Writer {
+	if o.WriteLimiter == nil {
+		return w
+	}
+	return NewRateLimitedWriter(w, o.WriteLimiter)
+}
+
+// DefaultWALOptions 默认预写日志配置：每秒刷盘一次，每分钟生成一次快照
+var DefaultWALOptions = WALOptions{
+	Sync:             WALSyncInterval,
+	SyncInterval:     time.Second,
+	SnapshotInterval: time.Minute,
+}
+
+// ErrWALCorrupted 快照或日志文件损坏
+var ErrWALCorrupted = errors.New("持久化文件损坏")
+
+// walOp 日志记录类型
+type walOp byte
+
+const (
+	walSet    walOp = iota + 1 // 写入键值，带绝对过期时间
+	walDelete                  // 删除键
+	walExpire                  // 修改键的过期时间
+	walBatch                   // 事务提交，value 为依次编码的多条 Set、Delete 记录
+)
+
+// walRecord 一条日志记录
+type walRecord struct {
+	lsn      uint64
+	op       walOp
+	key      []byte
+	value    []byte
+	expireAt time.Time // 零值表示不过期
+}
+
+// walMaxRecordSize 单条记录的长度上限，超过时视为损坏
+const walMaxRecordSize = 64 << 20
+
+// snapshotMagic 快照文件头
+const snapshotMagic = "SKVSNAP1"
+
+// encodeWALRecord 编码记录：CRC32(4字节) | 记录体长度(4字节) | 记录体
+func encodeWALRecord(rec walRecord) []byte {
+	body := make([]byte, 0, 32+len(rec.key)+len(rec.value))
+	body = binary.BigEndian.AppendUint64(body, rec.lsn)
+	body = append(body, byte(rec.op))
+	body = binary.AppendUvarint(body, uint64(len(rec.key)))
+	body = append(body, rec.key...)
+	body = binary.AppendUvarint(body, uint64(len(rec.value)))
+	body = append(body, rec.value...)
+	var expireAt int64
+	if !rec.expireAt.IsZero() {
+		expireAt = rec.expireAt.UnixNano()
+	}
+	body = binary.BigEndian.AppendUint64(body, uint64(expireAt))
+
+	buf := make([]byte, 8, 8+len(body))
+	binary.BigEndian.PutUint32(buf[0:4], crc32.ChecksumIEEE(body))
+	binary.BigEndian.PutUint32(buf[4:8], uint32(len(body)))
+	return append(buf, body...)
+}
+
+// readWALRecord 读取一条记录，正常结束返回 io.EOF，记录不完整或校验失败返回 ErrWALCorrupted
+func readWALRecord(r *bufio.Reader) (walRecord, error) {
+	var header [8]byte
+	if n, err := io.ReadFull(r, header[:]); err != nil {
+		if n == 0 && err == io.EOF {
+			return walRecord{}, io.EOF
+		}
+		return walRecord{}, ErrWALCorrupted
+	}
+	size := binary.BigEndian.Uint32(header[4:8])
+	if size > walMaxRecordSize {
+		return walRecord{}, ErrWALCorrupted
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return walRecord{}, ErrWALCorrupted
+	}
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(header[0:4]) {
+		return walRecord{}, ErrWALCorrupted
+	}
+	return decodeWALBody(body)
+}
+
+// decodeWALBody 解码记录体
+func decodeWALBody(body []byte) (walRecord, error) {
+	var rec walRecord
+	if len(body) < 9 {
+		return rec, ErrWALCorrupted
+	}
+	rec.lsn = binary.BigEndian.Uint64(body)
+	rec.op = walOp(body[8])
+	body = body[9:]
+
+	readBytes := func() ([]byte, bool) {
+		n, width := binary.Uvarint(body)
+		if width <= 0 || uint64(len(body)-width) < n {
+			return nil, false
+		}
+		data := body[width : width+int(n)]
+		body = body[width+int(n):]
+		return data, true
+	}
+	var ok bool
+	if rec.key, ok = readBytes(); !ok {
+		return rec, ErrWALCorrupted
+	}
+	if rec.value, ok = readBytes(); !ok {
+		return rec, ErrWALCorrupted
+	}
+	if len(body) != 8 {
+		return rec, ErrWALCorrupted
+	}
+	if expireAt := int64(binary.BigEndian.Uint64(body)); expireAt != 0 {
+		rec.expireAt = time.Unix(0, expireAt)
+	}
+	if rec.op < walSet || rec.op > walBatch {
+		return rec, ErrWALCorrupted
+	}
+	return rec, nil
+}
+
+// kvWAL 存储的预写日志，append 和切换日志段在存储的写锁内调用
+type kvWAL struct {
+	options WALOptions
+	file    *os.File  // 当前日志段
+	writer  io.Writer // 当前日志段的写入端，配置限速时经过 RateLimitedWriter
+	lsn     uint64    // 最后一条记录的 LSN
+	err     error     // 第一次写入或刷盘失败的错误
+
+	mutex     sync.Mutex // 保护 file、writer 和 err，后台刷盘与写入者之间使用
+	snapMutex sync.Mutex // 串行化快照
+	stop      chan struct{}
+	wg        sync.WaitGroup
+
+	// 统计
+	appended       int64
+	syncs          int64
+	snapshots      int64
+	lastSnapshot   uint64
+	recoveredKeys  int
+	replayed       int
+	corruptRecords int
+}
+
+// segmentName 日志段文件名
+func segmentName(firstLSN uint64) string {
+	return fmt.Sprintf("wal-%020d.log", firstLSN)
+}
+
+// snapshotName 快照文件名
+func snapshotName(lsn uint64) string {
+	return fmt.Sprintf("snapshot-%020d.dat", lsn)
+}
+
+// listPersistFiles 按 LSN 升序列出目录中指定前缀和后缀的文件
+func listPersistFiles(dir, prefix, suffix string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var lsns []uint64
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+			continue
+		}
+		var lsn uint64
+		if _, err := fmt.Sscanf(strings.TrimSuffix(strings.TrimPrefix(name, prefix), suffix), "%d", &lsn); err == nil {
+			lsns = append(lsns, lsn)
+		}
+	}
+	sort.Slice(lsns, func(i, j int) bool { return lsns[i] < lsns[j] })
+	return lsns, nil
+}
+
+// OpenSkiplistKVStore 打开带预写日志的存储：从 options.Dir 中的快照和日志恢复数据，之后的修改都写入日志
+func OpenSkiplistKVStore(options WALOptions) (*SkiplistKVStore, error) {
+	if options.Dir == "" {
+		return nil, errors.New("预写日志目录不能为空")
+	}
+	if options.Sync == WALSyncInterval && options.SyncInterval <= 0 {
+		options.SyncInterval = DefaultWALOptions.SyncInterval
+	}
+	if err := os.MkdirAll(options.Dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	store := NewSkiplistKVStore()
+	wal := &kvWAL{options: options, stop: make(chan struct{})}
+	if err := store.recover(wal); err != nil {
+		store.Close()
+		return nil, err
+	}
+
+	// 恢复完成后切换到新的日志段，不在可能带有损坏尾部的旧日志段后面继续追加
+	if err := wal.openSegment(wal.lsn + 1); err != nil {
+		store.Close()
+		return nil, err
+	}
+	store.wal = wal
+
+	if options.Sync == WALSyncInterval {
+		wal.wg.Add(1)
+		go wal.syncLoop()
+	}
+	if options.SnapshotInterval > 0 {
+		wal.wg.Add(1)
+		go store.snapshotLoop()
+	}
+	return store, nil
+}
+
+// recover 加载最新的快照并重放其后的日志，恢复期间存储还没有挂上日志，不会重复写日志
+func (s *SkiplistKVStore) recover(wal *kvWAL) error {
+	dir := wal.options.Dir
+	snapshots, err := listPersistFiles(dir, "snapshot-", ".dat")
+	if err != nil {
+		return err
+	}
+	if len(snapshots) > 0 {
+		lsn := snapshots[len(snapshots)-1]
+		keys, err := s.loadSnapshot(filepath.Join(dir, snapshotName(lsn)))
+		if err != nil {
+			return err
+		}
+		wal.lsn = lsn
+		wal.lastSnapshot = lsn
+		wal.recoveredKeys = keys
+	}
+
+	segments, err := listPersistFiles(dir, "wal-", ".log")
+	if err != nil {
+		return err
+	}
+	for _, first := range segments {
+		if err := s.replaySegment(wal, filepath.Join(dir, segmentName(first))); err != nil {
+			return err
+		}
+	}
+	s.dropExpired()
+	return nil
+}
+
+// loadSnapshot 加载快照文件，返回恢复的键数
+func (s *SkiplistKVStore) loadSnapshot(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(reader, magic); err != nil || string(magic) != snapshotMagic {
+		return 0, fmt.Errorf("%w: %s 不是快照文件", ErrWALCorrupted, path)
+	}
+	keys := 0
+	for {
+		rec, err := readWALRecord(reader)
+		if err == io.EOF {
+			return keys, nil
+		}
+		if err != nil {
+			// 快照先写临时文件再重命名，出现损坏说明文件被外部破坏，不能只恢复一部分
+			return keys, fmt.Errorf("%w: %s", err, path)
+		}
+		if s.applyWALRecord(rec) {
+			keys++
+		}
+	}
+}
+
+// replaySegment 重放一个日志段中 LSN 大于已恢复位置的记录，读到损坏的记录时停止
+func (s *SkiplistKVStore) replaySegment(wal *kvWAL, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		rec, err := readWALRecord(reader)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			// 崩溃时写了一半的记录：之后的内容都不可信，丢弃
+			wal.corruptRecords++
+			return nil
+		}
+		if rec.lsn <= wal.lsn {
+			continue
+		}
+		s.applyWALRecord(rec)
+		wal.lsn = rec.lsn
+		wal.replayed++
+	}
+}
+
+// applyWALRecord 把一条记录应用到内存，已过期的写入被丢弃；写入了键时返回 true
+func (s *SkiplistKVStore) applyWALRecord(rec walRecord) bool {
+	switch rec.op {
+	case walSet:
+		if rec.expireAt.IsZero() {
+			s.Set(rec.key, rec.value)
+			return true
+		}
+		if ttl := time.Until(rec.expireAt); ttl > 0 {
+			s.SetWithTTL(rec.key, rec.value, ttl)
+			return true
+		}
+		s.mutex.Lock()
+		s.data.Insert(rec.key, rec.value, s.score(rec.key))
+		s.ttlMutex.Lock()
+		s.restoreExpiredLocked(string(rec.key), rec.expireAt)
+		s.ttlMutex.Unlock()
+		s.mutex.Unlock()
+	case walDelete:
+		s.Delete(rec.key)
+	case walExpire:
+		s.mutex.Lock()
+		s.ttlMutex.Lock()
+		if s.data.Search(rec.key, s.score(rec.key)) != nil {
+			switch {
+			case rec.expireAt.IsZero():
+				s.clearTTLLocked(string(rec.key))
+			case time.Now().Before(rec.expireAt):
+				s.setTTLLocked(string(rec.key), time.Until(rec.expireAt))
+			default:
+				s.restoreExpiredLocked(string(rec.key), rec.expireAt)
+			}
+		}
+		s.ttlMutex.Unlock()
+		s.mutex.Unlock()
+	case walBatch:
+		reader := bufio.NewReader(bytes.NewReader(rec.value))
+		for {
+			sub, err := readWALRecord(reader)
+			if err != nil {
+				// 整条 Batch 记录已经通过校验，内部不会出现损坏，读到 io.EOF 即结束
+				break
+			}
+			s.applyWALRecord(sub)
+		}
+	}
+	return false
+}
+
+// restoreExpiredLocked 记录恢复期间已经过期的键的过期时间，但不安排定时器：
+// 之后的日志可能延长或取消它的过期时间，由 dropExpired 在重放完毕后统一删除；调用方需持有 ttlMutex
+func (s *SkiplistKVStore) restoreExpiredLocked(key string, expireAt time.Time) {
+	s.clearTTLLocked(key)
+	s.ttlData[key] = expireAt
+}
+
+// dropExpired 删除重放完毕后仍然过期的键，恢复期间存储还没有挂上日志，不会写日志
+func (s *SkiplistKVStore) dropExpired() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.ttlMutex.Lock()
+	defer s.ttlMutex.Unlock()
+
+	now := time.Now()
+	for key, expiry := range s.ttlData {
+		if _, scheduled := s.timers[key]; scheduled || now.Before(expiry) {
+			continue
+		}
+		s.data.Delete([]byte(key), s.score([]byte(key)))
+		delete(s.ttlData, key)
+	}
+}
+
+// openSegment 关闭当前日志段并打开以 firstLSN 开头的新日志段
+func (w *kvWAL) openSegment(firstLSN uint64) error {
+	// 同名文件只可能是上次运行留下的、没有任何完整记录的日志段，直接截断
+	file, err := os.OpenFile(filepath.Join(w.options.Dir, segmentName(firstLSN)), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.file != nil {
+		if err := w.file.Sync(); err != nil && w.err == nil {
+			w.err = err
+		}
+		w.file.Close()
+	}
+	w.file = file
+	w.writer = w.options.writerFor(file)
+	return nil
+}
+
+// append 追加一条记录并按策略刷盘，调用方需持有存储的写锁
+func (w *kvWAL) append(rec walRecord) {
+	w.lsn++
+	rec.lsn = w.lsn
+	data := encodeWALRecord(rec)
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.err != nil {
+		return
+	}
+	if _, err := w.writer.Write(data); err != nil {
+		w.err = fmt.Errorf("写入预写日志失败: %w", err)
+		return
+	}
+	w.appended++
+	if w.options.Sync == WALSyncAlways {
+		w.syncLocked()
+	}
+}
+
+// syncLocked 把当前日志段刷到磁盘，调用方需持有 w.mutex
+func (w *kvWAL) syncLocked() {
+	if err := w.file.Sync(); err != nil && w.err == nil {
+		w.err = fmt.Errorf("预写日志刷盘失败: %w", err)
+	}
+	w.syncs++
+}
+
+// syncLoop WALSyncInterval 策略的后台刷盘
+func (w *kvWAL) syncLoop() {
+	defer w.wg.Done()
+	ticker := time.NewTicker(w.options.SyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.mutex.Lock()
+			if w.err == nil {
+				w.syncLocked()
+			}
+			w.mutex.Unlock()
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// snapshotLoop 定期生成快照
+func (s *SkiplistKVStore) snapshotLoop() {
+	defer s.wal.wg.Done()
+	ticker := time.NewTicker(s.wal.options.SnapshotInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.Snapshot()
+		case <-s.wal.stop:
+			return
+		}
+	}
+}
+
+// log 把修改写入预写日志，没有启用日志时什么也不做；调用方需持有存储的写锁
+func (s *SkiplistKVStore) log(rec walRecord) {
+	if s.wal != nil {
+		s.wal.append(rec)
+	}
+}
+
+// Snapshot 生成快照并删除快照已经覆盖的日志段和旧快照，没有启用预写日志时返回错误
+func (s *SkiplistKVStore) Snapshot() error {
+	wal := s.wal
+	if wal == nil {
+		return errors.New("存储没有启用预写日志")
+	}
+	wal.snapMutex.Lock()
+	defer wal.snapMutex.Unlock()
+
+	// 在同一个写锁内切换日志段并创建快照迭代器，快照恰好包含 LSN 不超过 lsn 的全部修改
+	s.mutex.Lock()
+	lsn := wal.lsn
+	if err := wal.openSegment(lsn + 1); err != nil {
+		s.mutex.Unlock()
+		return err
+	}
+	it := s.iterateLocked(nil, nil)
+	s.mutex.Unlock()
+	defer it.Close()
+
+	dir := wal.options.Dir
+	file, err := os.CreateTemp(dir, snapshotName(lsn)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmp := file.Name()
+	writer := bufio.NewWriter(wal.options.writerFor(file))
+	writer.WriteString(snapshotMagic)
+	for it.Next() {
+		writer.Write(encodeWALRecord(walRecord{lsn: lsn, op: walSet, key: it.Key(), value: it.Value(), expireAt: it.ExpireAt()}))
+	}
+	if err = writer.Flush(); err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, filepath.Join(dir, snapshotName(lsn)))
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("生成快照失败: %w", err)
+	}
+
+	// 快照已经落盘，删除它覆盖的旧日志段和旧快照
+	if segments, err := listPersistFiles(dir, "wal-", ".log"); err == nil {
+		for _, first := range segments {
+			if first <= lsn {
+				os.Remove(filepath.Join(dir, segmentName(first)))
+			}
+		}
+	}
+	if snapshots, err := listPersistFiles(dir, "snapshot-", ".dat"); err == nil {
+		for _, old := range snapshots {
+			if old < lsn {
+				os.Remove(filepath.Join(dir, snapshotName(old)))
+			}
+		}
+	}
+
+	s.mutex.Lock()
+	wal.snapshots++
+	wal.lastSnapshot = lsn
+	s.mutex.Unlock()
+	return nil
+}
+
+// WALError 返回预写日志第一次写入或刷盘失败的错误，没有启用日志或没有失败时返回 nil
+func (s *SkiplistKVStore) WALError() error {
+	if s.wal == nil {
+		return nil
+	}
+	s.wal.mutex.Lock()
+	defer s.wal.mutex.Unlock()
+	return s.wal.err
+}
+
+// WALStats 返回预写日志的统计信息，没有启用日志时返回 nil
+func (s *SkiplistKVStore) WALStats() map[string]interface{} {
+	if s.wal == nil {
+		return nil
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	wal := s.wal
+	wal.mutex.Lock()
+	defer wal.mutex.Unlock()
+	return map[string]interface{}{
+		"sync":           wal.options.Sync.String(),
+		"lsn":            wal.lsn,
+		"appended":       wal.appended,
+		"syncs":          wal.syncs,
+		"snapshots":      wal.snapshots,
+		"lastSnapshot":   wal.lastSnapshot,
+		"recoveredKeys":  wal.recoveredKeys,
+		"replayed":       wal.replayed,
+		"corruptRecords": wal.corruptRecords,
+	}
+}
+
+// closeWAL 停止后台刷盘和快照，刷盘后关闭日志段
+func (s *SkiplistKVStore) closeWAL() {
+	wal := s.wal
+	if wal == nil {
+		return
+	}
+	close(wal.stop)
+	wal.wg.Wait()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	wal.mutex.Lock()
+	defer wal.mutex.Unlock()
+	if wal.err == nil {
+		wal.syncLocked()
+	}
+	wal.file.Close()
+}
+
+// copyDir 把目录中的普通文件复制到另一个目录，用于在示例中保留"崩溃瞬间"的磁盘内容
+func copyDir(from, to string) error {
+	if err := os.MkdirAll(to, 0o755); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(from)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(from, entry.Name()))
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(to, entry.Name()), data, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 场景示例：玩家资料存储在写入途中崩溃，重启后从快照和预写日志恢复
+func SkiplistWALDemo() {
+	fmt.Println("跳表键值存储的预写日志与崩溃恢复示例:")
+
+	dir, err := os.MkdirTemp("", "skiplist-wal")
+	if err != nil {
+		fmt.Printf("创建临时目录失败: %v\n", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	dataDir := filepath.Join(dir, "data")
+
+	options := WALOptions{Dir: dataDir, Sync: WALSyncAlways}
+	store, err := OpenSkiplistKVStore(options)
+	if err != nil {
+		fmt.Printf("打开存储失败: %v\n", err)
+		return
+	}
+
+	// 第一批写入之后生成快照，第二批写入只在日志中
+	for i := 1; i <= 5; i++ {
+		store.Set([]byte(fmt.Sprintf("player:%04d", i)), []byte(fmt.Sprintf("玩家%d|%d", i, 8000+i*100)))
+	}
+	if err := store.Snapshot(); err != nil {
+		fmt.Printf("生成快照失败: %v\n", err)
+	}
+	store.Set([]byte("player:0002"), []byte("玩家2|9999"))
+	store.Delete([]byte("player:0003"))
+	store.SetWithTTL([]byte("session:0001"), []byte("在线"), time.Hour)
+	store.SetWithTTL([]byte("session:0002"), []byte("即将过期"), 30*time.Millisecond)
+	fmt.Printf("写入后: %d 个键，日志统计 %v\n", store.Size(), store.WALStats())
+
+	// 崩溃：保留此刻的磁盘内容，并模拟最后一条记录只写了一半
+	crashDir := filepath.Join(dir, "crash")
+	if err := copyDir(dataDir, crashDir); err != nil {
+		fmt.Printf("复制数据目录失败: %v\n", err)
+		return
+	}
+	store.Close()
+	if segments, err := listPersistFiles(crashDir, "wal-", ".log"); err == nil && len(segments) > 0 {
+		last := filepath.Join(crashDir, segmentName(segments[len(segments)-1]))
+		torn := encodeWALRecord(walRecord{lsn: 1 << 40, op: walSet, key: []byte("player:0006"), value: []byte("玩家6|8600")})
+		if file, err := os.OpenFile(last, os.O_APPEND|os.O_WRONLY, 0); err == nil {
+			file.Write(torn[:len(torn)/2])
+			file.Close()
+		}
+	}
+	files, _ := os.ReadDir(crashDir)
+	names := make([]string, len(files))
+	for i, file := range files {
+		names[i] = file.Name()
+	}
+	fmt.Printf("崩溃时的磁盘文件: %v（最后一条记录只写了一半）\n", names)
+
+	time.Sleep(50 * time.Millisecond) // 停机期间 session:0002 到期
+
+	// 重启：加载快照，重放快照之后的日志，丢弃写了一半的记录和停机期间过期的键
+	recovered, err := OpenSkiplistKVStore(WALOptions{Dir: crashDir, Sync: WALSyncAlways})
+	if err != nil {
+		fmt.Printf("恢复失败: %v\n", err)
+		return
+	}
+	defer recovered.Close()
+	stats := recovered.WALStats()
+	fmt.Printf("重启恢复: 快照中 %v 个键，重放 %v 条日志，丢弃 %v 条损坏记录\n",
+		stats["recoveredKeys"], stats["replayed"], stats["corruptRecords"])
+	it := recovered.Iterate(nil, nil)
+	for it.Next() {
+		ttl := ""
+		if !it.ExpireAt().IsZero() {
+			ttl = fmt.Sprintf("（剩余 %v）", time.Until(it.ExpireAt()).Round(time.Minute))
+		}
+		fmt.Printf("  %s = %s%s\n", it.Key(), it.Value(), ttl)
+	}
+
+	// 刷盘策略对写入吞吐的影响
+	fmt.Println("\n刷盘策略对比（各写入2000个键）:")
+	for _, policy := range []WALSyncPolicy{WALSyncAlways, WALSyncInterval, WALSyncNever} {
+		bench, err := OpenSkiplistKVStore(WALOptions{Dir: filepath.Join(dir, "bench-"+policy.String()), Sync: policy, SyncInterval: 100 * time.Millisecond})
+		if err != nil {
+			fmt.Printf("打开存储失败: %v\n", err)
+			return
+		}
+		start := time.Now()
+		for i := 0; i < 2000; i++ {
+			bench.Set([]byte(fmt.Sprintf("key:%05d", i)), []byte("value"))
+		}
+		elapsed := time.Since(start)
+		fmt.Printf("  %-8s 耗时 %v，fsync %v 次\n", policy, elapsed.Round(time.Microsecond), bench.WALStats()["syncs"])
+		bench.Close()
+	}
+}
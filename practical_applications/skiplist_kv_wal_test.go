@@ -0,0 +1,92 @@
+package practical_applications
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// ttl 小于等于0的写入不过期，重新打开后仍然存在；带 ttl 的写入恢复后保留剩余的过期时间
+func TestWALReplaySetWithoutTTL(t *testing.T) {
+	options := WALOptions{Dir: t.TempDir(), Sync: WALSyncAlways}
+	store, err := OpenSkiplistKVStore(options)
+	if err != nil {
+		t.Fatalf("打开存储失败: %v", err)
+	}
+	store.SetWithTTL([]byte("zero"), []byte("v0"), 0)
+	store.SetWithTTL([]byte("negative"), []byte("v1"), -time.Second)
+	store.SetWithTTL([]byte("ttl"), []byte("v2"), time.Hour)
+	store.Close()
+
+	store, err = OpenSkiplistKVStore(options)
+	if err != nil {
+		t.Fatalf("重新打开存储失败: %v", err)
+	}
+	defer store.Close()
+
+	for key, want := range map[string]string{"zero": "v0", "negative": "v1", "ttl": "v2"} {
+		value, err := store.Get([]byte(key))
+		if err != nil {
+			t.Errorf("恢复后读取 %s 失败: %v", key, err)
+			continue
+		}
+		if string(value) != want {
+			t.Errorf("恢复后 %s = %q，期望 %q", key, value, want)
+		}
+	}
+	if ttl, ok := store.GetTTL([]byte("zero")); ok {
+		t.Errorf("ttl 为0的键恢复后不应有过期时间，实际剩余 %v", ttl)
+	}
+	if ttl, ok := store.GetTTL([]byte("ttl")); !ok || ttl <= 59*time.Minute {
+		t.Errorf("带 ttl 的键恢复后剩余 %v（存在 %v），期望接近1小时", ttl, ok)
+	}
+}
+
+// countingLimiter 只统计申请的令牌数，从不拒绝
+type countingLimiter struct {
+	tokens atomic.Int64
+}
+
+func (l *countingLimiter) Allow() bool                    { return l.AllowN(1) }
+func (l *countingLimiter) AllowN(n int64) bool            { l.tokens.Add(n); return true }
+func (l *countingLimiter) Wait(ctx context.Context) error { return l.WaitN(ctx, 1) }
+func (l *countingLimiter) WaitN(_ context.Context, n int64) error {
+	l.tokens.Add(n)
+	return nil
+}
+func (l *countingLimiter) GetStats() map[string]interface{} { return nil }
+
+// 配置 WriteLimiter 后日志段和快照的写入都按字节申请令牌，限速不影响恢复结果
+func TestWALWriteLimiter(t *testing.T) {
+	limiter := &countingLimiter{}
+	options := WALOptions{Dir: t.TempDir(), Sync: WALSyncNever, WriteLimiter: limiter}
+	store, err := OpenSkiplistKVStore(options)
+	if err != nil {
+		t.Fatalf("打开存储失败: %v", err)
+	}
+	store.Set([]byte("k1"), []byte("v1"))
+	logged := limiter.tokens.Load()
+	if logged == 0 {
+		t.Fatal("追加日志没有经过限速器")
+	}
+	if err := store.Snapshot(); err != nil {
+		t.Fatalf("生成快照失败: %v", err)
+	}
+	if limiter.tokens.Load() <= logged {
+		t.Fatal("写快照没有经过限速器")
+	}
+	store.Set([]byte("k2"), []byte("v2"))
+	store.Close()
+
+	store, err = OpenSkiplistKVStore(WALOptions{Dir: options.Dir, Sync: WALSyncNever})
+	if err != nil {
+		t.Fatalf("重新打开存储失败: %v", err)
+	}
+	defer store.Close()
+	for key, want := range map[string]string{"k1": "v1", "k2": "v2"} {
+		if value, err := store.Get([]byte(key)); err != nil || string(value) != want {
+			t.Errorf("恢复后 %s = %q（错误 %v），期望 %q", key, value, err, want)
+		}
+	}
+}
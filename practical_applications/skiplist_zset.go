@@ -0,0 +1,240 @@
+package practical_applications
+
+/*
+基于跳表的有序集合（Sorted Set）
+
+原理：
+SkiplistKVStore 用键的哈希值作为跳表分数，只利用了跳表的查找能力，键之间的顺序没有业务含义。
+有序集合直接把业务分数（玩家积分、时间戳、优先级）作为跳表分数，成员作为跳表的键，
+跳表按 (分数, 成员) 排序，范围查询和排名都直接沿着跳表的顺序完成，不需要取出全部数据再排序。
+跳表只能按 (分数, 成员) 定位元素，因此另外用哈希表保存成员到当前分数的映射，
+按成员查分数是 O(1)，更新分数时先按旧分数删除再按新分数插入。这与 Redis 有序集合的结构相同。
+
+关键特点：
+1. ZAdd 添加成员或更新分数，ZIncrBy 在原分数上累加，成员不存在时从0开始
+2. ZScore 按成员查询分数，ZRangeByScore 按分数闭区间升序返回成员
+3. ZRank 返回成员的升序名次（从0开始），ZRevRank 返回降序名次；ZRevRangeByScore 按分数从高到低返回成员，
+   ZRange、ZRevRange 按升序、降序名次区间返回成员，适合排行榜分页
+4. 分数相同的成员按成员的字节序排列，顺序稳定
+5. 有序集合按名字保存在 SkiplistKVStore 中，与普通键值对互不影响
+
+实现方式：
+- ZSet 由一个跳表和一个成员到分数的哈希表组成，二者由同一把读写锁保护，保证始终一致
+- SkiplistKVStore.ZSet(name) 按名字取得有序集合，不存在时创建；只读的场景用 LookupZSet，不会创建空集合
+- 排名和按名次取成员使用跳表的跨度计数，复杂度 O(log n)；降序输出沿第0层的前向指针遍历
+
+应用场景：
+- 游戏排行榜
+- 延迟队列（分数为到期时间）
+- 按时间窗口查询的事件索引
+
+优缺点：
+- 优点：范围查询和排序输出不需要额外排序；按成员查分数 O(1)
+- 缺点：成员同时存在于跳表和哈希表中，内存占用约为普通键值对的两倍
+
+以下实现了有序集合，排行榜示例见 skiplist_kv_store.go。
+*/
+
+import (
+	"sync"
+)
+
+// ZMember 有序集合中的一个成员及其分数
+type ZMember struct {
+	Member []byte
+	Score  float64
+}
+
+// ZSet 基于跳表的有序集合，并发安全
+type ZSet struct {
+	list   *SkipList          // 按 (分数, 成员) 排序
+	scores map[string]float64 // 成员到当前分数
+	mutex  sync.RWMutex
+}
+
+// NewZSet 创建空的有序集合
+func NewZSet() *ZSet {
+	return &ZSet{list: NewSkipList(), scores: make(map[string]float64)}
+}
+
+// ZAdd 添加成员或更新已有成员的分数，成员是新添加的返回 true
+func (z *ZSet) ZAdd(member []byte, score float64) bool {
+	z.mutex.Lock()
+	defer z.mutex.Unlock()
+	return z.setLocked(member, score)
+}
+
+// setLocked 把成员的分数设为 score，调用方需持有写锁
+func (z *ZSet) setLocked(member []byte, score float64) bool {
+	old, exists := z.scores[string(member)]
+	if exists {
+		if old == score {
+			return false
+		}
+		z.list.Delete(member, old)
+	}
+	// 复制成员，调用方之后修改切片不影响跳表中的顺序
+	key := append([]byte(nil), member...)
+	z.list.Insert(key, nil, score)
+	z.scores[string(key)] = score
+	return !exists
+}
+
+// ZIncrBy 把成员的分数加上 delta 并返回新分数，成员不存在时视为0分
+func (z *ZSet) ZIncrBy(member []byte, delta float64) float64 {
+	z.mutex.Lock()
+	defer z.mutex.Unlock()
+	score := z.scores[string(member)] + delta
+	z.setLocked(member, score)
+	return score
+}
+
+// ZScore 返回成员的分数
+func (z *ZSet) ZScore(member []byte) (float64, bool) {
+	z.mutex.RLock()
+	defer z.mutex.RUnlock()
+	score, exists := z.scores[string(member)]
+	return score, exists
+}
+
+// ZRem 删除成员，成员存在时返回 true
+func (z *ZSet) ZRem(member []byte) bool {
+	z.mutex.Lock()
+	defer z.mutex.Unlock()
+	score, exists := z.scores[string(member)]
+	if !exists {
+		return false
+	}
+	z.list.Delete(member, score)
+	delete(z.scores, string(member))
+	return true
+}
+
+// ZCard 返回成员数量
+func (z *ZSet) ZCard() int {
+	z.mutex.RLock()
+	defer z.mutex.RUnlock()
+	return len(z.scores)
+}
+
+// ZRangeByScore 按分数升序返回分数在 [min, max] 内的成员，limit<=0 表示不限制数量；
+// 取全部成员时传入 math.Inf(-1) 和 math.Inf(1)
+func (z *ZSet) ZRangeByScore(min, max float64, limit int) []ZMember {
+	z.mutex.RLock()
+	defer z.mutex.RUnlock()
+	return toZMembers(z.list.Range(min, max, limit))
+}
+
+// ZRank 返回成员按分数升序的名次（从0开始）
+func (z *ZSet) ZRank(member []byte) (int, bool) {
+	z.mutex.RLock()
+	defer z.mutex.RUnlock()
+	return z.rankLocked(member)
+}
+
+// ZRevRank 返回成员按分数降序的名次（从0开始），排行榜第一名为0
+func (z *ZSet) ZRevRank(member []byte) (int, bool) {
+	z.mutex.RLock()
+	defer z.mutex.RUnlock()
+	rank, exists := z.rankLocked(member)
+	if !exists {
+		return 0, false
+	}
+	return len(z.scores) - 1 - rank, true
+}
+
+// rankLocked 返回成员的升序名次，调用方需持有锁
+func (z *ZSet) rankLocked(member []byte) (int, bool) {
+	score, exists := z.scores[string(member)]
+	if !exists {
+		return 0, false
+	}
+	return z.list.Rank(member, score)
+}
+
+// ZRevRangeByScore 按分数降序返回分数在 [min, max] 内的成员，limit<=0 表示不限制数量
+func (z *ZSet) ZRevRangeByScore(max, min float64, limit int) []ZMember {
+	z.mutex.RLock()
+	defer z.mutex.RUnlock()
+	return toZMembers(z.list.RevRange(max, min, limit))
+}
+
+// ZRange 返回升序名次在 [start, stop] 内的成员（从0开始，包含两端），stop 超出范围时截断到最后一名
+func (z *ZSet) ZRange(start, stop int) []ZMember {
+	z.mutex.RLock()
+	defer z.mutex.RUnlock()
+	if start < 0 {
+		start = 0
+	}
+	if stop >= len(z.scores) {
+		stop = len(z.scores) - 1
+	}
+	if start > stop {
+		return []ZMember{}
+	}
+	members := make([]ZMember, 0, stop-start+1)
+	for e := z.list.GetByRank(start); e != nil && len(members) <= stop-start; e = e.Next[0] {
+		members = append(members, ZMember{Member: e.Key, Score: e.Score})
+	}
+	return members
+}
+
+// ZRevRange 返回降序名次在 [start, stop] 内的成员（从0开始，包含两端），stop 超出范围时截断到最后一名
+func (z *ZSet) ZRevRange(start, stop int) []ZMember {
+	z.mutex.RLock()
+	defer z.mutex.RUnlock()
+	n := len(z.scores)
+	if start < 0 {
+		start = 0
+	}
+	if stop >= n {
+		stop = n - 1
+	}
+	if start > stop {
+		return []ZMember{}
+	}
+	// 降序名次 start 对应升序名次 n-1-start，从该元素沿前向指针走 stop-start 步
+	members := make([]ZMember, 0, stop-start+1)
+	for e := z.list.GetByRank(n - 1 - start); e != nil && len(members) <= stop-start; e = e.Prev {
+		members = append(members, ZMember{Member: e.Key, Score: e.Score})
+	}
+	return members
+}
+
+// toZMembers 把跳表元素转换为成员列表
+func toZMembers(elements []*Element) []ZMember {
+	members := make([]ZMember, len(elements))
+	for i, e := range elements {
+		members[i] = ZMember{Member: e.Key, Score: e.Score}
+	}
+	return members
+}
+
+// ZSet 返回名为 name 的有序集合，不存在时创建
+func (s *SkiplistKVStore) ZSet(name string) *ZSet {
+	s.zsetMutex.Lock()
+	defer s.zsetMutex.Unlock()
+	zset, exists := s.zsets[name]
+	if !exists {
+		zset = NewZSet()
+		s.zsets[name] = zset
+	}
+	return zset
+}
+
+// LookupZSet 返回名为 name 的有序集合，不存在时不创建
+func (s *SkiplistKVStore) LookupZSet(name string) (*ZSet, bool) {
+	s.zsetMutex.Lock()
+	defer s.zsetMutex.Unlock()
+	zset, exists := s.zsets[name]
+	return zset, exists
+}
+
+// DeleteZSet 删除名为 name 的有序集合，存在时返回 true
+func (s *SkiplistKVStore) DeleteZSet(name string) bool {
+	s.zsetMutex.Lock()
+	defer s.zsetMutex.Unlock()
+	_, exists := s.zsets[name]
+	delete(s.zsets, name)
+	return exists
+}
@@ -0,0 +1,412 @@
+package practical_applications
+
+/*
+基于滑动窗口分位数的SLA监控
+
+原理：
+服务等级协议（SLA）通常用"P99延迟不超过200ms、错误率不超过1%"这类指标描述。
+平均延迟会掩盖长尾，因此需要持续计算分位数；而流量是无穷的数据流，不能保存全部样本。
+SLA监控把时间窗口切成若干个小桶，每个桶用一个T-Digest记录延迟分布、用计数器记录请求数和错误数，
+查询时合并窗口内所有桶的摘要，得到最近一段时间的P50/P95/P99和错误率，再与阈值比较。
+
+关键特点：
+1. 滑动窗口：过期的桶被新数据覆盖，指标只反映最近一段时间
+2. 流式分位数：每个桶的内存固定，与请求量无关
+3. 状态变化回调：指标从达标变为违约时触发 OnBreach，恢复时触发 OnRecover，只在状态变化时触发一次
+4. 最小样本数：请求太少时不做判断，避免偶发慢请求造成误报
+
+实现方式：
+- 环形数组保存时间桶，桶的起始时间不匹配当前时间片时先清空再写入
+- 记录请求时按检查间隔惰性触发检查，也可以由调用方主动调用 Check
+- 回调在锁外执行，回调中可以安全地调用监控器的方法或调整限流器
+
+应用场景：
+- 延迟或错误率超标时收紧限流、打开熔断、触发降级
+- 服务健康度看板和告警
+- 灰度发布时对比新旧版本的延迟分布
+
+优缺点：
+- 优点：内存固定、反应快、能直接联动限流和熔断等保护措施
+- 缺点：分位数为近似值；窗口切分粒度决定了指标的平滑程度
+
+以下实现了一个基于T-Digest的滑动窗口SLA监控器。
+*/
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SLAOptions SLA监控配置
+type SLAOptions struct {
+	Window        time.Duration    // 统计窗口长度
+	Buckets       int              // 窗口切分的桶数
+	Compression   float64          // 每个桶T-Digest的压缩参数
+	P50Threshold  time.Duration    // P50延迟阈值，0表示不检查
+	P95Threshold  time.Duration    // P95延迟阈值，0表示不检查
+	P99Threshold  time.Duration    // P99延迟阈值，0表示不检查
+	MaxErrorRate  float64          // 最大错误率（0~1），0表示不检查
+	MinRequests   int64            // 窗口内请求数少于该值时不做判断
+	CheckInterval time.Duration    // Record 触发检查的最小间隔，0表示只在调用 Check 时检查
+	Clock         func() time.Time // 时钟，默认 time.Now，可替换为模拟时钟
+}
+
+// DefaultSLAOptions 默认SLA监控配置
+var DefaultSLAOptions = SLAOptions{
+	Window:        10 * time.Second,
+	Buckets:       10,
+	Compression:   DefaultTDigestCompression,
+	P99Threshold:  500 * time.Millisecond,
+	MaxErrorRate:  0.05,
+	MinRequests:   20,
+	CheckInterval: time.Second,
+	Clock:         time.Now,
+}
+
+// SLASnapshot 某一时刻的窗口指标
+type SLASnapshot struct {
+	Time       time.Time     // 快照时间
+	Requests   int64         // 窗口内请求数
+	Errors     int64         // 窗口内错误数
+	ErrorRate  float64       // 错误率
+	P50        time.Duration // P50延迟
+	P95        time.Duration // P95延迟
+	P99        time.Duration // P99延迟
+	Violations []string      // 违反的指标，为空表示达标
+}
+
+// Breached 是否违反SLA
+func (s SLASnapshot) Breached() bool {
+	return len(s.Violations) > 0
+}
+
+// String 返回快照的简要描述
+func (s SLASnapshot) String() string {
+	return fmt.Sprintf("请求 %d, 错误率 %.2f%%, P50 %v, P95 %v, P99 %v",
+		s.Requests, s.ErrorRate*100,
+		s.P50.Round(time.Millisecond), s.P95.Round(time.Millisecond), s.P99.Round(time.Millisecond))
+}
+
+// SLACallback 状态变化回调
+type SLACallback func(snapshot SLASnapshot)
+
+// slaBucket 时间桶
+type slaBucket struct {
+	start    int64    // 桶对应的时间片序号
+	digest   *TDigest // 延迟分布（毫秒）
+	requests int64    // 请求数
+	errors   int64    // 错误数
+}
+
+// SLAMonitor 滑动窗口SLA监控器
+type SLAMonitor struct {
+	options    SLAOptions
+	bucketSize time.Duration
+	buckets    []slaBucket
+	breached   bool      // 当前是否处于违约状态
+	lastCheck  time.Time // 上次检查时间
+	breaches   int64     // 违约次数
+	recoveries int64     // 恢复次数
+	total      int64     // 累计请求数
+	onBreach   []SLACallback
+	onRecover  []SLACallback
+	mutex      sync.Mutex
+}
+
+// NewSLAMonitor 创建SLA监控器
+func NewSLAMonitor(options SLAOptions) (*SLAMonitor, error) {
+	if options.Window <= 0 {
+		return nil, errors.New("统计窗口长度必须为正数")
+	}
+	if options.Buckets <= 0 {
+		options.Buckets = DefaultSLAOptions.Buckets
+	}
+	if options.Window/time.Duration(options.Buckets) <= 0 {
+		return nil, fmt.Errorf("统计窗口 %v 无法切分为 %d 个桶", options.Window, options.Buckets)
+	}
+	if options.MaxErrorRate < 0 || options.MaxErrorRate > 1 {
+		return nil, fmt.Errorf("错误率阈值必须在0到1之间: %v", options.MaxErrorRate)
+	}
+	if options.Clock == nil {
+		options.Clock = time.Now
+	}
+
+	m := &SLAMonitor{
+		options:    options,
+		bucketSize: options.Window / time.Duration(options.Buckets),
+		buckets:    make([]slaBucket, options.Buckets),
+	}
+	for i := range m.buckets {
+		m.buckets[i] = slaBucket{start: -1, digest: NewTDigest(options.Compression)}
+	}
+	return m, nil
+}
+
+// OnBreach 注册违约回调，指标从达标变为违约时调用
+func (m *SLAMonitor) OnBreach(callback SLACallback) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.onBreach = append(m.onBreach, callback)
+}
+
+// OnRecover 注册恢复回调，指标从违约恢复为达标时调用
+func (m *SLAMonitor) OnRecover(callback SLACallback) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.onRecover = append(m.onRecover, callback)
+}
+
+// Record 记录一次请求的延迟和结果，err 不为 nil 表示请求失败
+func (m *SLAMonitor) Record(latency time.Duration, err error) {
+	now := m.options.Clock()
+
+	m.mutex.Lock()
+	bucket := m.bucketLocked(now)
+	bucket.digest.Add(float64(latency) / float64(time.Millisecond))
+	bucket.requests++
+	if err != nil {
+		bucket.errors++
+	}
+	m.total++
+
+	due := m.options.CheckInterval > 0 && now.Sub(m.lastCheck) >= m.options.CheckInterval
+	m.mutex.Unlock()
+
+	if due {
+		m.Check()
+	}
+}
+
+// bucketLocked 返回当前时间对应的桶，桶已过期时先清空（调用方需持有锁）
+func (m *SLAMonitor) bucketLocked(now time.Time) *slaBucket {
+	slot := now.UnixNano() / int64(m.bucketSize)
+	bucket := &m.buckets[slot%int64(len(m.buckets))]
+	if bucket.start != slot {
+		bucket.start = slot
+		bucket.digest.Reset()
+		bucket.requests = 0
+		bucket.errors = 0
+	}
+	return bucket
+}
+
+// snapshotLocked 合并窗口内的桶并计算指标（调用方需持有锁）
+func (m *SLAMonitor) snapshotLocked(now time.Time) SLASnapshot {
+	current := now.UnixNano() / int64(m.bucketSize)
+	oldest := current - int64(len(m.buckets)) + 1
+
+	merged := NewTDigest(m.options.Compression)
+	snapshot := SLASnapshot{Time: now}
+	for i := range m.buckets {
+		bucket := &m.buckets[i]
+		if bucket.start < oldest || bucket.start > current {
+			continue
+		}
+		merged.Merge(bucket.digest)
+		snapshot.Requests += bucket.requests
+		snapshot.Errors += bucket.errors
+	}
+
+	if snapshot.Requests == 0 {
+		return snapshot
+	}
+	snapshot.ErrorRate = float64(snapshot.Errors) / float64(snapshot.Requests)
+	snapshot.P50 = time.Duration(merged.Quantile(0.50) * float64(time.Millisecond))
+	snapshot.P95 = time.Duration(merged.Quantile(0.95) * float64(time.Millisecond))
+	snapshot.P99 = time.Duration(merged.Quantile(0.99) * float64(time.Millisecond))
+
+	if snapshot.Requests < m.options.MinRequests {
+		return snapshot
+	}
+	checks := []struct {
+		name      string
+		value     time.Duration
+		threshold time.Duration
+	}{
+		{"P50", snapshot.P50, m.options.P50Threshold},
+		{"P95", snapshot.P95, m.options.P95Threshold},
+		{"P99", snapshot.P99, m.options.P99Threshold},
+	}
+	for _, check := range checks {
+		if check.threshold > 0 && check.value > check.threshold {
+			snapshot.Violations = append(snapshot.Violations,
+				fmt.Sprintf("%s %v > %v", check.name, check.value.Round(time.Millisecond), check.threshold))
+		}
+	}
+	if m.options.MaxErrorRate > 0 && snapshot.ErrorRate > m.options.MaxErrorRate {
+		snapshot.Violations = append(snapshot.Violations,
+			fmt.Sprintf("错误率 %.2f%% > %.2f%%", snapshot.ErrorRate*100, m.options.MaxErrorRate*100))
+	}
+	return snapshot
+}
+
+// Snapshot 返回当前窗口的指标，不触发回调
+func (m *SLAMonitor) Snapshot() SLASnapshot {
+	now := m.options.Clock()
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.snapshotLocked(now)
+}
+
+// Check 计算当前窗口的指标，状态发生变化时触发对应回调
+func (m *SLAMonitor) Check() SLASnapshot {
+	now := m.options.Clock()
+
+	m.mutex.Lock()
+	m.lastCheck = now
+	snapshot := m.snapshotLocked(now)
+
+	// 样本不足时保持原状态，避免低流量时反复切换
+	var callbacks []SLACallback
+	if snapshot.Requests >= m.options.MinRequests {
+		breached := snapshot.Breached()
+		if breached && !m.breached {
+			m.breaches++
+			callbacks = append(callbacks, m.onBreach...)
+		} else if !breached && m.breached {
+			m.recoveries++
+			callbacks = append(callbacks, m.onRecover...)
+		}
+		m.breached = breached
+	}
+	m.mutex.Unlock()
+
+	for _, callback := range callbacks {
+		callback(snapshot)
+	}
+	return snapshot
+}
+
+// Breached 当前是否处于违约状态
+func (m *SLAMonitor) Breached() bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.breached
+}
+
+// Stats 获取监控统计信息
+func (m *SLAMonitor) Stats() map[string]interface{} {
+	now := m.options.Clock()
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	snapshot := m.snapshotLocked(now)
+	return map[string]interface{}{
+		"window":        m.options.Window.String(),
+		"buckets":       len(m.buckets),
+		"totalRequests": m.total,
+		"requests":      snapshot.Requests,
+		"errorRate":     snapshot.ErrorRate,
+		"p50":           snapshot.P50.String(),
+		"p95":           snapshot.P95.String(),
+		"p99":           snapshot.P99.String(),
+		"breached":      m.breached,
+		"breaches":      m.breaches,
+		"recoveries":    m.recoveries,
+	}
+}
+
+// 场景示例：下游变慢时自动收紧限流并降级，恢复后放开
+func SLAMonitorDemo() {
+	fmt.Println("SLA监控示例:")
+
+	// 使用模拟时钟，在几毫秒内演示几十秒的流量变化
+	clock := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	options := DefaultSLAOptions
+	options.Window = 5 * time.Second
+	options.Buckets = 5
+	options.P95Threshold = 150 * time.Millisecond
+	options.P99Threshold = 300 * time.Millisecond
+	options.MaxErrorRate = 0.02
+	options.Clock = func() time.Time { return clock }
+
+	monitor, err := NewSLAMonitor(options)
+	if err != nil {
+		fmt.Printf("创建监控器失败: %v\n", err)
+		return
+	}
+
+	const normalRate, degradedRate = 200, 50
+	limiter := NewTokenBucket(normalRate, normalRate)
+	degraded := false // 降级开关：打开后跳过非核心的推荐调用（也可以在这里打开熔断器）
+	skipped := 0      // 降级期间跳过的非核心调用数
+
+	monitor.OnBreach(func(s SLASnapshot) {
+		limiter.SetRate(degradedRate)
+		degraded = true
+		fmt.Printf("  [%s] SLA违约 %v -> 限流收紧到 %d/s，打开降级\n",
+			s.Time.Format("15:04:05"), s.Violations, limiter.Rate())
+	})
+	monitor.OnRecover(func(s SLASnapshot) {
+		limiter.SetRate(normalRate)
+		degraded = false
+		fmt.Printf("  [%s] SLA恢复 (%s) -> 限流恢复到 %d/s，关闭降级\n",
+			s.Time.Format("15:04:05"), s, limiter.Rate())
+	})
+
+	rng := rand.New(rand.NewSource(7))
+	// simulate 模拟一秒内的请求：latency 为基础延迟，errorRate 为下游错误率
+	simulate := func(requests int, latency time.Duration, slowRate, errorRate float64) {
+		for i := 0; i < requests; i++ {
+			clock = clock.Add(time.Second / time.Duration(requests))
+			cost := latency + time.Duration(rng.ExpFloat64()*float64(latency)/4)
+			if rng.Float64() < slowRate {
+				cost *= 10
+			}
+			var reqErr error
+			if rng.Float64() < errorRate {
+				reqErr = errors.New("下游超时")
+			}
+			if degraded {
+				skipped++
+			}
+			monitor.Record(cost, reqErr)
+		}
+	}
+
+	phases := []struct {
+		name      string
+		seconds   int
+		latency   time.Duration
+		slowRate  float64
+		errorRate float64
+	}{
+		{"正常流量", 6, 40 * time.Millisecond, 0.002, 0.001},
+		{"下游变慢并出现错误", 4, 80 * time.Millisecond, 0.05, 0.08},
+		{"下游恢复", 8, 40 * time.Millisecond, 0.002, 0.001},
+	}
+	for _, phase := range phases {
+		fmt.Printf("\n阶段: %s\n", phase.name)
+		for s := 0; s < phase.seconds; s++ {
+			simulate(100, phase.latency, phase.slowRate, phase.errorRate)
+			fmt.Printf("  %s  %s\n", clock.Format("15:04:05"), monitor.Snapshot())
+		}
+	}
+
+	fmt.Println("\n监控统计:")
+	stats := monitor.Stats()
+	for _, key := range []string{"totalRequests", "breaches", "recoveries", "breached"} {
+		fmt.Printf("%s: %v\n", key, stats[key])
+	}
+	fmt.Printf("降级期间跳过的非核心调用: %d\n", skipped)
+	fmt.Printf("限流器当前速率: %d/s\n", limiter.Rate())
+
+	// T-Digest 精度：与精确排序结果对比
+	digest := NewTDigest(DefaultTDigestCompression)
+	samples := make([]float64, 100000)
+	for i := range samples {
+		samples[i] = rng.ExpFloat64() * 50
+		digest.Add(samples[i])
+	}
+	sorted := append([]float64(nil), samples...)
+	sort.Float64s(sorted)
+	fmt.Printf("\nT-Digest精度（10万个指数分布样本，%d个质心）:\n", digest.Centroids())
+	for _, q := range []float64{0.5, 0.95, 0.99, 0.999} {
+		exact := sorted[int(q*float64(len(sorted)-1))]
+		fmt.Printf("  P%-5v 估计 %.2f, 精确 %.2f\n", q*100, digest.Quantile(q), exact)
+	}
+}
@@ -0,0 +1,213 @@
+package practical_applications
+
+/*
+T-Digest 流式分位数估计
+
+原理：
+计算P99延迟最直接的方法是保存所有样本再排序，但在高吞吐的服务中样本量巨大，无法全部保存。
+T-Digest 把样本聚合成若干个"质心"（均值 + 权重），并且限制每个质心的权重：
+越靠近分位数两端（q接近0或1）的质心权重越小，越靠近中位数的质心权重越大。
+这样在内存固定的情况下，尾部分位数（P99、P999）依然有很高的精度。
+
+关键特点：
+1. 内存占用与样本数无关，只与压缩参数有关（约 compression 个质心）
+2. 尾部分位数精度高，适合延迟监控
+3. 支持合并：多个时间窗口或多台机器的摘要可以合并后再查询
+4. 支持加权样本
+
+实现方式：
+- 新样本先写入缓冲区，缓冲区满时与已有质心一起排序并合并（merging digest）
+- 合并时要求质心在刻度函数 k(q) = δ/(2π)·asin(2q−1) 上的跨度不超过1，q 为质心所在的分位数位置
+- 查询时在相邻质心中心之间线性插值，两端使用记录的最小值和最大值
+
+应用场景：
+- 接口延迟的P50/P95/P99监控
+- 分布式系统中各节点分位数的汇总
+- 数据流的中位数、分位数统计
+
+优缺点：
+- 优点：内存小、可合并、尾部精度高
+- 缺点：结果是近似值；非并发安全，需要调用方加锁
+
+以下实现了一个合并式的T-Digest。
+*/
+
+import (
+	"math"
+	"sort"
+)
+
+// DefaultTDigestCompression 默认压缩参数
+const DefaultTDigestCompression = 100
+
+// Centroid T-Digest 质心
+type Centroid struct {
+	Mean   float64 // 均值
+	Weight float64 // 权重（样本数）
+}
+
+// TDigest 流式分位数摘要（非并发安全）
+type TDigest struct {
+	compression float64    // 压缩参数，越大越精确、质心越多
+	centroids   []Centroid // 已合并的质心（按均值排序）
+	buffer      []Centroid // 未合并的样本
+	totalWeight float64    // 总权重（含缓冲区）
+	min         float64    // 最小值
+	max         float64    // 最大值
+}
+
+// NewTDigest 创建T-Digest
+func NewTDigest(compression float64) *TDigest {
+	if compression <= 0 {
+		compression = DefaultTDigestCompression
+	}
+	return &TDigest{
+		compression: compression,
+		centroids:   make([]Centroid, 0, int(compression)),
+		buffer:      make([]Centroid, 0, int(compression)*5),
+		min:         math.Inf(1),
+		max:         math.Inf(-1),
+	}
+}
+
+// Add 添加一个样本
+func (t *TDigest) Add(x float64) {
+	t.AddWeighted(x, 1)
+}
+
+// AddWeighted 添加一个带权重的样本
+func (t *TDigest) AddWeighted(x, weight float64) {
+	if math.IsNaN(x) || weight <= 0 {
+		return
+	}
+
+	t.buffer = append(t.buffer, Centroid{Mean: x, Weight: weight})
+	t.totalWeight += weight
+	t.min = math.Min(t.min, x)
+	t.max = math.Max(t.max, x)
+
+	if len(t.buffer) >= cap(t.buffer) {
+		t.compress()
+	}
+}
+
+// compress 将缓冲区与已有质心合并
+func (t *TDigest) compress() {
+	if len(t.buffer) == 0 {
+		return
+	}
+
+	all := make([]Centroid, 0, len(t.centroids)+len(t.buffer))
+	all = append(all, t.centroids...)
+	all = append(all, t.buffer...)
+	sort.Slice(all, func(i, j int) bool { return all[i].Mean < all[j].Mean })
+	t.buffer = t.buffer[:0]
+
+	merged := make([]Centroid, 0, len(t.centroids)+1)
+	current := all[0]
+	weightSoFar := 0.0
+
+	for _, next := range all[1:] {
+		proposed := current.Weight + next.Weight
+		qLeft := weightSoFar / t.totalWeight
+		qRight := (weightSoFar + proposed) / t.totalWeight
+
+		// 合并后的质心在刻度函数上的跨度不超过1
+		if t.scale(qRight)-t.scale(qLeft) <= 1 {
+			current.Mean += (next.Mean - current.Mean) * next.Weight / proposed
+			current.Weight = proposed
+		} else {
+			merged = append(merged, current)
+			weightSoFar += current.Weight
+			current = next
+		}
+	}
+	t.centroids = append(merged, current)
+}
+
+// scale 刻度函数 k(q) = δ/(2π)·asin(2q−1)，两端斜率大，因此两端的质心更小
+func (t *TDigest) scale(q float64) float64 {
+	return t.compression / (2 * math.Pi) * math.Asin(2*math.Min(1, q)-1)
+}
+
+// Quantile 返回分位数 q（0~1）的估计值，没有样本时返回 NaN
+func (t *TDigest) Quantile(q float64) float64 {
+	t.compress()
+
+	n := len(t.centroids)
+	if n == 0 {
+		return math.NaN()
+	}
+	if q <= 0 {
+		return t.min
+	}
+	if q >= 1 {
+		return t.max
+	}
+	if n == 1 {
+		return t.centroids[0].Mean
+	}
+
+	target := q * t.totalWeight
+
+	// 第一个质心中心之前：在最小值与第一个质心之间插值
+	first := t.centroids[0]
+	if target < first.Weight/2 {
+		return t.min + (first.Mean-t.min)*target/(first.Weight/2)
+	}
+
+	// 相邻质心中心之间线性插值
+	cumulative := 0.0
+	for i := 0; i < n-1; i++ {
+		left, right := t.centroids[i], t.centroids[i+1]
+		leftCenter := cumulative + left.Weight/2
+		rightCenter := cumulative + left.Weight + right.Weight/2
+		if target <= rightCenter {
+			ratio := (target - leftCenter) / (rightCenter - leftCenter)
+			return left.Mean + (right.Mean-left.Mean)*ratio
+		}
+		cumulative += left.Weight
+	}
+
+	// 最后一个质心中心之后：在最后一个质心与最大值之间插值
+	last := t.centroids[n-1]
+	lastCenter := t.totalWeight - last.Weight/2
+	ratio := (target - lastCenter) / (last.Weight / 2)
+	return last.Mean + (t.max-last.Mean)*math.Min(1, ratio)
+}
+
+// Merge 将另一个摘要合并进来
+func (t *TDigest) Merge(other *TDigest) {
+	if other == nil || other.totalWeight == 0 {
+		return
+	}
+	other.compress()
+
+	for _, c := range other.centroids {
+		t.buffer = append(t.buffer, c)
+		t.totalWeight += c.Weight
+	}
+	t.min = math.Min(t.min, other.min)
+	t.max = math.Max(t.max, other.max)
+	t.compress()
+}
+
+// Count 返回样本总权重
+func (t *TDigest) Count() float64 {
+	return t.totalWeight
+}
+
+// Centroids 返回当前质心数量
+func (t *TDigest) Centroids() int {
+	t.compress()
+	return len(t.centroids)
+}
+
+// Reset 清空摘要
+func (t *TDigest) Reset() {
+	t.centroids = t.centroids[:0]
+	t.buffer = t.buffer[:0]
+	t.totalWeight = 0
+	t.min = math.Inf(1)
+	t.max = math.Inf(-1)
+}
@@ -0,0 +1,274 @@
+package report
+
+/*
+算法对比报告
+
+原理：
+同一个问题往往有多种可互换的实现：LRU/LFU/FIFO 缓存淘汰、Dijkstra/A* 最短路径、堆/快速选择求TopK。
+单独演示某一种实现只能说明"它能工作"，而工程上真正关心的是"在同一份输入下，哪一种更好"。
+对比报告让多个实现在完全相同的输入上运行，统一采集延迟、内存分配和质量指标（命中率、结果正确性等），
+最后输出一张 Markdown 或 JSON 表格，并标出每一项指标的最优实现。
+
+关键特点：
+1. 延迟和内存分配由报告框架统一测量，各实现只需返回自己的质量指标
+2. 每个指标声明"越小越好"还是"越大越好"，自动标出最优值
+3. 同时支持 Markdown（便于阅读）和 JSON（便于程序处理）两种输出格式
+
+实现方式：
+- 使用 time.Since 测量总耗时，runtime.MemStats.TotalAlloc 的差值测量内存分配量
+- 测量前执行一次GC，减少上一个实现的垃圾对本次测量的干扰
+- 测量期间关闭默认追踪器，被测代码中的埋点不影响结果
+- 结果按添加顺序保存，指标按声明顺序输出
+
+应用场景：
+- 缓存淘汰策略在同一访问轨迹上的命中率对比
+- 路径算法在同一批查询上的速度与结果一致性对比
+- TopK 等算法在不同数据规模下的性能对比
+
+优缺点：
+- 优点：输入一致、指标统一，对比结论直观
+- 缺点：单次运行的延迟受调度和GC影响，不能替代严格的基准测试
+
+以下实现了对比报告的采集和输出。
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/strive/scenario/tracing"
+)
+
+// 框架统一采集的指标名称
+const (
+	MetricLatency = "耗时(ms)"   // 总耗时
+	MetricAlloc   = "内存分配(KB)" // 运行期间的内存分配量
+)
+
+// 输出格式
+const (
+	FormatMarkdown = "markdown"
+	FormatJSON     = "json"
+)
+
+// Metric 指标定义
+type Metric struct {
+	Name          string `json:"name"`          // 指标名称
+	LowerIsBetter bool   `json:"lowerIsBetter"` // 是否越小越好
+	Precision     int    `json:"precision"`     // 输出的小数位数
+}
+
+// Result 单个实现的测量结果
+type Result struct {
+	Name   string             `json:"name"`            // 实现名称
+	Values map[string]float64 `json:"values"`          // 指标值
+	Note   string             `json:"note,omitempty"`  // 备注
+	Error  string             `json:"error,omitempty"` // 运行失败的原因
+}
+
+// Comparison 一次对比报告
+type Comparison struct {
+	Title   string    `json:"title"`   // 报告标题
+	Input   string    `json:"input"`   // 输入描述
+	Metrics []Metric  `json:"metrics"` // 指标定义（按输出顺序）
+	Results []*Result `json:"results"` // 各实现的结果
+}
+
+// NewComparison 创建对比报告，自动包含耗时和内存分配两项指标
+func NewComparison(title, input string, metrics ...Metric) *Comparison {
+	all := []Metric{
+		{Name: MetricLatency, LowerIsBetter: true, Precision: 3},
+		{Name: MetricAlloc, LowerIsBetter: true, Precision: 1},
+	}
+	all = append(all, metrics...)
+
+	return &Comparison{
+		Title:   title,
+		Input:   input,
+		Metrics: all,
+		Results: make([]*Result, 0),
+	}
+}
+
+// Add 直接添加一个实现的结果
+func (c *Comparison) Add(name string, values map[string]float64) *Result {
+	result := &Result{Name: name, Values: values}
+	c.Results = append(c.Results, result)
+	return result
+}
+
+// Measure 运行一个实现，测量耗时和内存分配，并合并其返回的质量指标
+func (c *Comparison) Measure(name string, fn func() (map[string]float64, error)) *Result {
+	// 测量期间关闭默认追踪器，埋点的开销不计入各实现的耗时和分配
+	defer tracing.SetEnabled(tracing.SetEnabled(false))
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	start := time.Now()
+	quality, err := fn()
+	elapsed := time.Since(start)
+
+	runtime.ReadMemStats(&after)
+
+	values := map[string]float64{
+		MetricLatency: float64(elapsed.Microseconds()) / 1000,
+		MetricAlloc:   float64(after.TotalAlloc-before.TotalAlloc) / 1024,
+	}
+	for k, v := range quality {
+		values[k] = v
+	}
+
+	result := c.Add(name, values)
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Best 返回某项指标的最优实现名称
+func (c *Comparison) Best(metric string) string {
+	best, _, _ := c.best(metric)
+	return best
+}
+
+// best 返回某项指标的最优实现名称、最优值，以及所有实现的该项指标是否相同
+func (c *Comparison) best(metric string) (string, float64, bool) {
+	lowerIsBetter := true
+	for _, m := range c.Metrics {
+		if m.Name == metric {
+			lowerIsBetter = m.LowerIsBetter
+		}
+	}
+
+	best, bestValue, allEqual := "", math.NaN(), true
+	for _, r := range c.Results {
+		v, ok := r.Values[metric]
+		if !ok || r.Error != "" {
+			continue
+		}
+		if !math.IsNaN(bestValue) && v != bestValue {
+			allEqual = false
+		}
+		if math.IsNaN(bestValue) || (lowerIsBetter && v < bestValue) || (!lowerIsBetter && v > bestValue) {
+			best, bestValue = r.Name, v
+		}
+	}
+	return best, bestValue, allEqual
+}
+
+// Markdown 以Markdown表格形式输出报告，每项指标的最优值加粗
+func (c *Comparison) Markdown() string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "### %s\n\n", c.Title)
+	if c.Input != "" {
+		fmt.Fprintf(&sb, "输入：%s\n\n", c.Input)
+	}
+
+	// 只输出至少有一个实现给出了值的指标（例如直接 Add 的结果没有耗时）
+	metrics := make([]Metric, 0, len(c.Metrics))
+	for _, m := range c.Metrics {
+		for _, r := range c.Results {
+			if _, ok := r.Values[m.Name]; ok {
+				metrics = append(metrics, m)
+				break
+			}
+		}
+	}
+
+	// 表头
+	sb.WriteString("| 实现 |")
+	for _, m := range metrics {
+		arrow := "↑"
+		if m.LowerIsBetter {
+			arrow = "↓"
+		}
+		fmt.Fprintf(&sb, " %s%s |", m.Name, arrow)
+	}
+	sb.WriteString(" 备注 |\n|---|")
+	for range metrics {
+		sb.WriteString("---:|")
+	}
+	sb.WriteString("---|\n")
+
+	// 每项指标的最优值（所有实现相同时不标记）
+	bestValues := make(map[string]float64, len(metrics))
+	for _, m := range metrics {
+		if _, v, allEqual := c.best(m.Name); !allEqual {
+			bestValues[m.Name] = v
+		}
+	}
+
+	// 数据行
+	for _, r := range c.Results {
+		fmt.Fprintf(&sb, "| %s |", r.Name)
+		for _, m := range metrics {
+			v, ok := r.Values[m.Name]
+			if !ok {
+				sb.WriteString(" - |")
+				continue
+			}
+			cell := fmt.Sprintf("%.*f", m.Precision, v)
+			if bestValue, ok := bestValues[m.Name]; ok && v == bestValue && r.Error == "" {
+				cell = "**" + cell + "**"
+			}
+			fmt.Fprintf(&sb, " %s |", cell)
+		}
+		note := r.Note
+		if r.Error != "" {
+			note = "失败: " + r.Error
+		}
+		fmt.Fprintf(&sb, " %s |\n", note)
+	}
+
+	return sb.String()
+}
+
+// JSON 以JSON形式输出报告
+func (c *Comparison) JSON() ([]byte, error) {
+	return json.MarshalIndent(c, "", "  ")
+}
+
+// Write 按指定格式输出报告
+func (c *Comparison) Write(w io.Writer, format string) error {
+	switch format {
+	case FormatJSON:
+		data, err := c.JSON()
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	case FormatMarkdown, "":
+		_, err := fmt.Fprintln(w, c.Markdown())
+		return err
+	default:
+		return fmt.Errorf("不支持的报告格式: %s", format)
+	}
+}
+
+// WriteAll 按指定格式输出多份报告，JSON格式下输出为一个数组
+func WriteAll(w io.Writer, format string, comparisons ...*Comparison) error {
+	if format == FormatJSON {
+		data, err := json.MarshalIndent(comparisons, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	}
+
+	for _, c := range comparisons {
+		if err := c.Write(w, format); err != nil {
+			return err
+		}
+	}
+	return nil
+}
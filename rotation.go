@@ -0,0 +1,313 @@
+package main
+
+/*
+多协程轮转打印（Rotation）
+
+原理：
+"两个协程交替打印奇偶数""三个协程依次打印""一个协程打印3的倍数、另一个打印其他数"都是同一个问题：
+一个数字序列按顺序推进，每个数字归属于某一个协程，只有轮到的协程可以处理当前数字，处理完后把执行权交给下一个数字的归属者。
+不同之处只在于归属规则（谓词）和交接执行权的同步方式。
+Rotation 把归属规则和处理逻辑交给调用方注册，把交接方式做成可选的策略，
+从而用同一套代码覆盖各种交替打印的变体。
+
+关键特点：
+1. 注册 N 个工作者，每个工作者有谓词（数字是否归它处理）和处理函数，每个工作者运行在自己的协程中
+2. 一个数字由第一个谓词匹配的工作者处理；启动前检查区间内每个数字都有归属，没有时返回错误
+3. 三种交接策略：通道传递令牌、互斥锁加条件变量、原子变量自旋，通过选项选择
+4. 区间可以自定义；上下文取消时所有工作者尽快退出，Run 返回上下文的错误
+
+实现方式：
+- 通道：每个工作者一个无缓冲通道，处理完当前数字后把下一个数字发给它的归属者；下一个数字仍归自己时直接继续处理
+- 互斥锁：共享的当前数字由互斥锁保护，工作者在条件变量上等待轮到自己，推进后广播；上下文取消时通过 context.AfterFunc 广播唤醒
+- 原子变量：当前数字是原子变量，工作者读取后发现轮到自己就处理并加1，否则让出CPU
+
+应用场景：
+- 面试和教学中的各种交替打印题
+- 需要多个协程严格按顺序轮流执行的场景：轮流写同一个输出、按顺序交替访问设备
+- 对比不同同步原语在严格交替场景下的开销
+
+优缺点：
+- 优点：归属规则、处理逻辑和同步方式解耦，新增变体只需要注册新的谓词
+- 缺点：严格交替本质上是串行的，多个协程只是轮流执行，并不能提高吞吐量；
+  原子策略在等待时持续占用CPU，只适合每一步都很快的场景
+
+以下实现了通用的轮转执行器，以及自定义区间和取消的示例。
+*/
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RotationStrategy 工作者之间交接执行权的方式
+type RotationStrategy int
+
+const (
+	RotationChannel RotationStrategy = iota // 通道传递令牌
+	RotationMutex                           // 互斥锁加条件变量
+	RotationAtomic                          // 原子变量自旋
+)
+
+// String 返回策略名称
+func (s RotationStrategy) String() string {
+	switch s {
+	case RotationChannel:
+		return "通道"
+	case RotationMutex:
+		return "互斥锁"
+	case RotationAtomic:
+		return "原子操作"
+	default:
+		return "未知策略"
+	}
+}
+
+// RotationWorker 参与轮转的工作者
+type RotationWorker struct {
+	Name  string           // 名称
+	Match func(n int) bool // 数字 n 是否由该工作者处理
+	Step  func(n int)      // 处理数字 n
+}
+
+// RotationOptions 轮转配置
+type RotationOptions struct {
+	Strategy RotationStrategy // 交接策略
+	Start    int              // 区间起点（包含）
+	End      int              // 区间终点（包含）
+}
+
+// DefaultRotationOptions 默认轮转配置：通道策略，数字1到100
+var DefaultRotationOptions = RotationOptions{
+	Strategy: RotationChannel,
+	Start:    1,
+	End:      100,
+}
+
+// ErrRotationNoWorker 区间内有数字没有匹配的工作者
+var ErrRotationNoWorker = errors.New("数字没有匹配的工作者")
+
+// Rotation 多协程轮转执行器，区间内的数字按顺序由各自的工作者处理
+type Rotation struct {
+	workers []RotationWorker
+	options RotationOptions
+}
+
+// NewRotation 创建轮转执行器
+func NewRotation(options RotationOptions) *Rotation {
+	return &Rotation{options: options}
+}
+
+// Register 注册工作者，数字由第一个谓词匹配的工作者处理
+func (r *Rotation) Register(name string, match func(n int) bool, step func(n int)) {
+	r.workers = append(r.workers, RotationWorker{Name: name, Match: match, Step: step})
+}
+
+// owner 返回数字 n 的归属工作者下标，没有时返回-1
+func (r *Rotation) owner(n int) int {
+	for i, w := range r.workers {
+		if w.Match(n) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Run 启动所有工作者按顺序处理区间内的数字，全部处理完返回 nil；
+// 上下文取消时返回上下文的错误，有数字没有归属时在启动前返回 ErrRotationNoWorker
+func (r *Rotation) Run(ctx context.Context) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	for n := r.options.Start; n <= r.options.End; n++ {
+		if r.owner(n) < 0 {
+			return fmt.Errorf("%w: %d", ErrRotationNoWorker, n)
+		}
+	}
+	if r.options.Start > r.options.End {
+		return nil
+	}
+
+	var completed bool
+	switch r.options.Strategy {
+	case RotationMutex:
+		completed = r.runMutex(ctx)
+	case RotationAtomic:
+		completed = r.runAtomic(ctx)
+	default:
+		completed = r.runChannel(ctx)
+	}
+	if !completed {
+		return ctx.Err()
+	}
+	return nil
+}
+
+// runChannel 通道策略：处理完当前数字后把下一个数字发给它的归属者，返回是否处理完整个区间
+func (r *Rotation) runChannel(ctx context.Context) bool {
+	inboxes := make([]chan int, len(r.workers))
+	for i := range inboxes {
+		inboxes[i] = make(chan int)
+	}
+	finished := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i, w := range r.workers {
+		wg.Add(1)
+		go func(i int, w RotationWorker) {
+			defer wg.Done()
+			for {
+				var n int
+				select {
+				case n = <-inboxes[i]:
+				case <-finished:
+					return
+				case <-ctx.Done():
+					return
+				}
+				// 下一个数字仍归自己时直接继续，不需要给自己发消息
+				for {
+					w.Step(n)
+					n++
+					if n > r.options.End {
+						close(finished)
+						return
+					}
+					next := r.owner(n)
+					if next == i {
+						if ctx.Err() != nil {
+							return
+						}
+						continue
+					}
+					select {
+					case inboxes[next] <- n:
+					case <-ctx.Done():
+						return
+					}
+					break
+				}
+			}
+		}(i, w)
+	}
+
+	// 把第一个数字交给它的归属者
+	select {
+	case inboxes[r.owner(r.options.Start)] <- r.options.Start:
+	case <-ctx.Done():
+	}
+	wg.Wait()
+
+	select {
+	case <-finished:
+		return true
+	default:
+		return false
+	}
+}
+
+// runMutex 互斥锁策略：工作者在条件变量上等待当前数字归自己，返回是否处理完整个区间
+func (r *Rotation) runMutex(ctx context.Context) bool {
+	var mu sync.Mutex
+	cond := sync.NewCond(&mu)
+	current := r.options.Start
+	stop := context.AfterFunc(ctx, func() {
+		mu.Lock()
+		cond.Broadcast()
+		mu.Unlock()
+	})
+	defer stop()
+
+	var wg sync.WaitGroup
+	for i, w := range r.workers {
+		wg.Add(1)
+		go func(i int, w RotationWorker) {
+			defer wg.Done()
+			mu.Lock()
+			defer mu.Unlock()
+			for {
+				for current <= r.options.End && ctx.Err() == nil && r.owner(current) != i {
+					cond.Wait() // 等待直到轮到自己
+				}
+				if current > r.options.End || ctx.Err() != nil {
+					return
+				}
+				w.Step(current)
+				current++
+				cond.Broadcast() // 通知所有等待的工作者
+			}
+		}(i, w)
+	}
+	wg.Wait()
+	return current > r.options.End
+}
+
+// runAtomic 原子策略：工作者读取当前数字，轮到自己时处理并加1，否则让出CPU，返回是否处理完整个区间
+func (r *Rotation) runAtomic(ctx context.Context) bool {
+	var current int64 = int64(r.options.Start)
+
+	var wg sync.WaitGroup
+	for i, w := range r.workers {
+		wg.Add(1)
+		go func(i int, w RotationWorker) {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				n := int(atomic.LoadInt64(&current))
+				if n > r.options.End {
+					return
+				}
+				if r.owner(n) != i {
+					runtime.Gosched()
+					continue
+				}
+				// 只有归属者会推进 n，不需要 CAS
+				w.Step(n)
+				atomic.StoreInt64(&current, int64(n+1))
+			}
+		}(i, w)
+	}
+	wg.Wait()
+	return int(current) > r.options.End
+}
+
+// RotationDemo 演示自定义区间、三种策略和取消
+func RotationDemo() {
+	fmt.Println("轮转执行器示例:")
+
+	// 自定义区间：4个协程按 FizzBuzz 规则轮流处理 1~15
+	for _, strategy := range []RotationStrategy{RotationChannel, RotationMutex, RotationAtomic} {
+		var out []string
+		rotation := NewRotation(RotationOptions{Strategy: strategy, Start: 1, End: 15})
+		rotation.Register("FizzBuzz", func(n int) bool { return n%15 == 0 }, func(n int) { out = append(out, "FizzBuzz") })
+		rotation.Register("Fizz", func(n int) bool { return n%3 == 0 }, func(n int) { out = append(out, "Fizz") })
+		rotation.Register("Buzz", func(n int) bool { return n%5 == 0 }, func(n int) { out = append(out, "Buzz") })
+		rotation.Register("数字", func(n int) bool { return true }, func(n int) { out = append(out, fmt.Sprint(n)) })
+		err := rotation.Run(context.Background())
+		fmt.Printf("%s策略: %v (错误: %v)\n", strategy, out, err)
+	}
+
+	// 没有归属的数字：启动前报错
+	rotation := NewRotation(RotationOptions{Start: 1, End: 10})
+	rotation.Register("偶数", func(n int) bool { return n%2 == 0 }, func(n int) {})
+	fmt.Printf("只注册偶数工作者: %v\n", rotation.Run(context.Background()))
+
+	// 取消：每一步都很慢，超时后所有工作者退出
+	for _, strategy := range []RotationStrategy{RotationChannel, RotationMutex, RotationAtomic} {
+		var processed int64
+		rotation := NewRotation(RotationOptions{Strategy: strategy, Start: 1, End: 1000})
+		for i := 0; i < 3; i++ {
+			rotation.Register(fmt.Sprintf("线程%d", i+1), func(n int) bool { return n%3 == i }, func(n int) {
+				atomic.AddInt64(&processed, 1)
+				time.Sleep(time.Millisecond)
+			})
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		err := rotation.Run(ctx)
+		cancel()
+		fmt.Printf("%s策略 20ms 后取消: 处理了 %d 个数字，错误: %v\n", strategy, atomic.LoadInt64(&processed), err)
+	}
+}
@@ -36,6 +36,7 @@ import (
 	"bufio"
 	"container/heap"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"math/rand"
 	"os"
@@ -44,6 +45,9 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/strive/scenario/practical_applications"
+	"github.com/strive/scenario/tracing"
 )
 
 // 用于多路归并的优先队列项
@@ -74,33 +78,82 @@ func (h *minHeap) Pop() interface{} {
 	return item
 }
 
+// ExternalSortOptions 外部排序配置
+type ExternalSortOptions struct {
+	MaxLinesPerChunk int                                // 每个块的最大行数（内存限制）
+	TempDir          string                             // 临时目录
+	ReadLimiter      practical_applications.RateLimiter // 读取限速（字节/秒），nil表示不限速
+	WriteLimiter     practical_applications.RateLimiter // 写入限速（字节/秒），nil表示不限速
+}
+
+// readerFor 按配置包装读取端
+func (o ExternalSortOptions) readerFor(r io.Reader) io.Reader {
+	if o.ReadLimiter == nil {
+		return r
+	}
+	return practical_applications.NewRateLimitedReader(r, o.ReadLimiter)
+}
+
+// writerFor 按配置包装写入端
+func (o ExternalSortOptions) writerFor(w io.Writer) io.Writer {
+	if o.WriteLimiter == nil {
+		return w
+	}
+	return practical_applications.NewRateLimitedWriter(w, o.WriteLimiter)
+}
+
 // ExternalSort 外部排序函数
 // 输入: 大文件路径，内存限制（每个块的最大行数），临时目录
 // 输出: 排序后的文件路径
 func ExternalSort(inputFile string, maxLinesPerChunk int, tempDir string) (string, error) {
+	return ExternalSortWithOptions(inputFile, ExternalSortOptions{
+		MaxLinesPerChunk: maxLinesPerChunk,
+		TempDir:          tempDir,
+	})
+}
+
+// ExternalSortWithOptions 按配置执行外部排序，可以限制磁盘读写速率
+func ExternalSortWithOptions(inputFile string, options ExternalSortOptions) (string, error) {
+	maxLinesPerChunk, tempDir := options.MaxLinesPerChunk, options.TempDir
+	if maxLinesPerChunk <= 0 {
+		return "", fmt.Errorf("每个块的最大行数必须为正数: %d", maxLinesPerChunk)
+	}
+
+	span := tracing.StartSpan("ExternalSort")
+	span.SetAttribute("maxLinesPerChunk", maxLinesPerChunk)
+	span.SetAttribute("rateLimited", options.ReadLimiter != nil || options.WriteLimiter != nil)
+	defer span.Finish()
+
 	// 1. 分割-排序阶段: 将大文件分割成多个小块并分别排序
-	chunkFiles, err := splitAndSort(inputFile, maxLinesPerChunk, tempDir)
+	splitSpan := span.StartChild("分割排序")
+	chunkFiles, err := splitAndSort(inputFile, maxLinesPerChunk, tempDir, options)
+	splitSpan.SetAttribute("chunks", len(chunkFiles))
+	splitSpan.Finish()
 	if err != nil {
 		return "", fmt.Errorf("分割排序阶段失败: %v", err)
 	}
 
 	// 2. 归并阶段: 将排序好的小块合并成最终结果
+	mergeSpan := span.StartChild("多路归并")
 	outputFile := filepath.Join(tempDir, "sorted_output.txt")
-	err = mergeChunks(chunkFiles, outputFile)
+	err = mergeChunks(chunkFiles, outputFile, options)
+	mergeSpan.Finish()
 	if err != nil {
 		return "", fmt.Errorf("归并阶段失败: %v", err)
 	}
 
 	// 3. 删除临时文件
+	cleanupSpan := span.StartChild("清理临时文件")
 	for _, file := range chunkFiles {
 		os.Remove(file)
 	}
+	cleanupSpan.Finish()
 
 	return outputFile, nil
 }
 
 // 分割大文件并对每个小块排序
-func splitAndSort(inputFile string, maxLinesPerChunk int, tempDir string) ([]string, error) {
+func splitAndSort(inputFile string, maxLinesPerChunk int, tempDir string, options ExternalSortOptions) ([]string, error) {
 	// 打开输入文件
 	file, err := os.Open(inputFile)
 	if err != nil {
@@ -113,7 +166,7 @@ func splitAndSort(inputFile string, maxLinesPerChunk int, tempDir string) ([]str
 	chunkID := 0
 
 	// 扫描文件中的每一行
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(options.readerFor(file))
 	for scanner.Scan() {
 		// 将字符串转换为整数
 		num, err := strconv.Atoi(strings.TrimSpace(scanner.Text()))
@@ -125,7 +178,7 @@ func splitAndSort(inputFile string, maxLinesPerChunk int, tempDir string) ([]str
 
 		// 当达到块大小时，对当前块排序并写入磁盘
 		if len(lines) >= maxLinesPerChunk {
-			chunkFile, err := sortAndWriteChunk(lines, chunkID, tempDir)
+			chunkFile, err := sortAndWriteChunk(lines, chunkID, tempDir, options)
 			if err != nil {
 				return chunkFiles, err
 			}
@@ -137,7 +190,7 @@ func splitAndSort(inputFile string, maxLinesPerChunk int, tempDir string) ([]str
 
 	// 处理最后一个不完整的块
 	if len(lines) > 0 {
-		chunkFile, err := sortAndWriteChunk(lines, chunkID, tempDir)
+		chunkFile, err := sortAndWriteChunk(lines, chunkID, tempDir, options)
 		if err != nil {
 			return chunkFiles, err
 		}
@@ -152,7 +205,7 @@ func splitAndSort(inputFile string, maxLinesPerChunk int, tempDir string) ([]str
 }
 
 // 对一个块进行排序并写入磁盘
-func sortAndWriteChunk(lines []int, chunkID int, tempDir string) (string, error) {
+func sortAndWriteChunk(lines []int, chunkID int, tempDir string, options ExternalSortOptions) (string, error) {
 	// 对块内数据排序
 	sort.Ints(lines)
 
@@ -165,15 +218,19 @@ func sortAndWriteChunk(lines []int, chunkID int, tempDir string) (string, error)
 	defer outFile.Close()
 
 	// 将排序后的数据写入文件
+	writer := bufio.NewWriter(options.writerFor(outFile))
 	for _, num := range lines {
-		fmt.Fprintf(outFile, "%d\n", num)
+		fmt.Fprintf(writer, "%d\n", num)
+	}
+	if err := writer.Flush(); err != nil {
+		return "", err
 	}
 
 	return chunkFile, nil
 }
 
 // 合并多个排序好的块
-func mergeChunks(chunkFiles []string, outputFile string) error {
+func mergeChunks(chunkFiles []string, outputFile string, options ExternalSortOptions) error {
 	if len(chunkFiles) == 0 {
 		return nil
 	}
@@ -186,7 +243,7 @@ func mergeChunks(chunkFiles []string, outputFile string) error {
 			return err
 		}
 		defer f.Close()
-		scanners[i] = bufio.NewScanner(f)
+		scanners[i] = bufio.NewScanner(options.readerFor(f))
 	}
 
 	// 创建输出文件
@@ -197,7 +254,7 @@ func mergeChunks(chunkFiles []string, outputFile string) error {
 	defer outFile.Close()
 
 	// 创建一个缓冲写入器以提高性能
-	writer := bufio.NewWriter(outFile)
+	writer := bufio.NewWriter(options.writerFor(outFile))
 	defer writer.Flush()
 
 	// 创建优先队列用于多路归并
@@ -366,6 +423,8 @@ func outputPreview(filename string, lines int) error {
 // 场景示例：对大型日志文件中的时间戳进行排序
 func ExternalSortDemo() {
 	fmt.Println("外部排序示例 - 对大型日志文件中的时间戳进行排序:")
+	tracing.DefaultExporter().Reset()
+	defer tracing.SetEnabled(tracing.SetEnabled(true)) // 只在演示期间开启追踪
 
 	// 创建临时目录
 	tempDir, err := ioutil.TempDir("", "external_sort")
@@ -428,4 +487,33 @@ func ExternalSortDemo() {
 	// 输出排序后文件的部分内容
 	fmt.Println("\n排序后文件的前10行:")
 	outputPreview(outputFile, 10)
+
+	// 查看各阶段耗时分布
+	fmt.Println("\n排序各阶段耗时（链路追踪）:")
+	tracing.DefaultExporter().Report(os.Stdout)
+
+	// 模拟慢磁盘：读写各限速 2MB/s
+	const diskRate = 2 * 1024 * 1024
+	fmt.Printf("\n模拟慢磁盘（读写各限速 %d MB/s）重新排序...\n", diskRate/(1024*1024))
+	slowDir := filepath.Join(tempDir, "slow_disk")
+	if err := os.Mkdir(slowDir, 0755); err != nil {
+		fmt.Printf("创建目录失败: %v\n", err)
+		return
+	}
+	startTime = time.Now()
+	_, err = ExternalSortWithOptions(inputFile, ExternalSortOptions{
+		MaxLinesPerChunk: maxLinesPerChunk,
+		TempDir:          slowDir,
+		ReadLimiter:      practical_applications.NewTokenBucket(diskRate, 256*1024),
+		WriteLimiter:     practical_applications.NewTokenBucket(diskRate, 256*1024),
+	})
+	if err != nil {
+		fmt.Printf("排序失败: %v\n", err)
+		return
+	}
+	slowDuration := time.Since(startTime)
+	fmt.Printf("排序完成，耗时: %v（不限速时 %v）\n",
+		slowDuration.Round(time.Millisecond), duration.Round(time.Millisecond))
+	fmt.Printf("读写各 %.2f MB 数据，两次读两次写，理论耗时约 %.2fs\n",
+		float64(inputInfo.Size())/(1024*1024), float64(inputInfo.Size())*2/diskRate)
 }
@@ -35,8 +35,11 @@ import (
 	"container/heap"
 	"fmt"
 	"math/rand"
+	"os"
 	"sort"
 	"time"
+
+	"github.com/strive/scenario/report"
 )
 
 // 使用最小堆实现的TopK（找最大的K个元素）
@@ -366,3 +369,77 @@ func TopKDemo() {
 		}
 	}
 }
+
+// CompareTopK 在同一份随机数据上对比各种TopK实现
+func CompareTopK(n, k, maxVal int, seed int64) *report.Comparison {
+	rng := rand.New(rand.NewSource(seed))
+	nums := make([]int, n)
+	for i := range nums {
+		nums[i] = rng.Intn(maxVal + 1)
+	}
+
+	// 基准答案：全量排序
+	expected := make([]int, n)
+	copy(expected, nums)
+	sort.Sort(sort.Reverse(sort.IntSlice(expected)))
+	expected = expected[:k]
+
+	// 准确率：结果中与基准答案一致的位置占比
+	accuracy := func(result []int) float64 {
+		sorted := make([]int, len(result))
+		copy(sorted, result)
+		sort.Sort(sort.Reverse(sort.IntSlice(sorted)))
+		matched := 0
+		for i := 0; i < len(sorted) && i < k; i++ {
+			if sorted[i] == expected[i] {
+				matched++
+			}
+		}
+		return float64(matched) / float64(k) * 100
+	}
+
+	comparison := report.NewComparison(
+		"TopK算法对比",
+		fmt.Sprintf("%d 个 [0, %d] 范围内的随机整数，K=%d", n, maxVal, k),
+		report.Metric{Name: "准确率(%)", Precision: 1},
+	)
+
+	implementations := []struct {
+		name string
+		fn   func() []int
+	}{
+		{"自定义最小堆", func() []int {
+			h := NewMinHeapTopK(k)
+			for _, num := range nums {
+				h.Add(num)
+			}
+			return h.Result()
+		}},
+		{"标准库堆", func() []int { return FindTopKWithHeap(nums, k) }},
+		{"快速选择", func() []int { return FindTopKWithQuickSelect(nums, k) }},
+		{"桶排序", func() []int { return FindTopKWithBucketSort(nums, k, maxVal) }},
+		{"全量排序", func() []int {
+			sorted := make([]int, len(nums))
+			copy(sorted, nums)
+			sort.Sort(sort.Reverse(sort.IntSlice(sorted)))
+			return sorted[:k]
+		}},
+	}
+
+	for _, impl := range implementations {
+		comparison.Measure(impl.name, func() (map[string]float64, error) {
+			result := impl.fn()
+			return map[string]float64{"准确率(%)": accuracy(result)}, nil
+		})
+	}
+
+	return comparison
+}
+
+// 场景示例：不同数据规模下的TopK算法对比
+func TopKComparisonDemo() {
+	fmt.Println("TopK算法对比报告:")
+
+	CompareTopK(1_000_000, 100, 100_000, 42).Write(os.Stdout, report.FormatMarkdown)
+	CompareTopK(1_000_000, 100_000, 100_000, 42).Write(os.Stdout, report.FormatMarkdown)
+}
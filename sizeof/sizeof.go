@@ -0,0 +1,328 @@
+package sizeof
+
+/*
+数据结构内存占用估算
+
+原理：
+unsafe.Sizeof 只返回值本身（"浅"）的大小：一个切片永远是24字节、一个map永远是8字节，
+而真正占内存的是它们引用的底层数组、哈希桶和字符串数据。
+深度内存估算通过反射遍历整个对象图：对每个指针、切片、字符串、map、通道，
+计算其引用的堆内存，并记录已访问过的地址，避免共享对象被重复计算、循环引用导致死循环。
+
+关键特点：
+1. 深度遍历结构体、数组、指针、切片、map、接口、通道，包括未导出字段
+2. 按地址去重：共享的节点、双向链表的前后指针、重复引用的字符串只计算一次
+3. map 按 Go 运行时的哈希桶布局估算（每个桶8个槽位，装载因子6.5）
+4. 按类别（切片、map、字符串、指针）汇总，便于分析内存主要花在哪里
+
+实现方式：
+- 使用 reflect 遍历对象图，只读取指针地址和长度，不调用 Interface()，因此可以访问未导出字段
+- 值本身的大小取 Type.Size()，间接引用的大小递归累加
+- 时区（*time.Location）、函数等全局共享对象不计入
+
+应用场景：
+- 验证"布隆过滤器比哈希集合节省内存"等结论
+- 比较不同数据结构在相同数据下的内存占用
+- 容量规划时估算单个元素的平均开销
+
+优缺点：
+- 优点：无需修改被测数据结构，结果可重复
+- 缺点：是估算值，不包含内存分配器的对齐和碎片开销；遍历大对象图有一定耗时
+
+以下实现了深度内存估算和分类报告。
+*/
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unsafe"
+
+	"github.com/strive/scenario/concurrency"
+)
+
+// 按类别汇总的名称
+const (
+	KindInline  = "值本身"
+	KindPointer = "指针指向的对象"
+	KindSlice   = "切片底层数组"
+	KindString  = "字符串数据"
+	KindMap     = "map哈希桶"
+	KindChan    = "通道缓冲区"
+)
+
+// map 运行时布局相关常量（Go 1.23 及之前的哈希桶实现）
+const (
+	mapHeaderSize  = 48  // hmap 结构体大小
+	mapBucketSlots = 8   // 每个桶的槽位数
+	mapLoadFactor  = 6.5 // 平均每个桶的元素数上限
+	mapMaxInlineKV = 128 // 超过该大小的键值以指针形式存储
+	chanHeaderSize = 96  // hchan 结构体大小
+	pointerSize    = int64(unsafe.Sizeof(uintptr(0)))
+)
+
+// skipTypes 全局共享、不计入的类型
+var skipTypes = map[reflect.Type]bool{
+	reflect.TypeOf((*time.Location)(nil)):           true,
+	reflect.TypeOf((*concurrency.TimingWheel)(nil)): true, // 定时器引用的共享时间轮
+}
+
+// Result 内存估算结果
+type Result struct {
+	Total   int64            // 总字节数
+	ByKind  map[string]int64 // 按类别汇总
+	Objects int              // 遍历到的堆对象数（指针、切片、字符串、map、通道）
+}
+
+// String 返回结果的简要描述
+func (r *Result) String() string {
+	kinds := make([]string, 0, len(r.ByKind))
+	for kind := range r.ByKind {
+		kinds = append(kinds, kind)
+	}
+	sort.Slice(kinds, func(i, j int) bool { return r.ByKind[kinds[i]] > r.ByKind[kinds[j]] })
+
+	parts := make([]string, 0, len(kinds))
+	for _, kind := range kinds {
+		if r.ByKind[kind] > 0 {
+			parts = append(parts, fmt.Sprintf("%s %s", kind, FormatBytes(r.ByKind[kind])))
+		}
+	}
+	return fmt.Sprintf("%s (%d个对象; %s)", FormatBytes(r.Total), r.Objects, strings.Join(parts, ", "))
+}
+
+// walker 对象图遍历器
+type walker struct {
+	seen    map[uintptr]bool
+	byKind  map[string]int64
+	objects int
+}
+
+// Of 估算 v 的深度内存占用（字节）；v 为指针时包含其指向的对象
+func Of(v interface{}) int64 {
+	return Measure(v).Total
+}
+
+// Measure 估算 v 的深度内存占用，并按类别汇总
+func Measure(v interface{}) *Result {
+	w := &walker{
+		seen:   make(map[uintptr]bool),
+		byKind: make(map[string]int64),
+	}
+
+	value := reflect.ValueOf(v)
+	if !value.IsValid() {
+		return &Result{ByKind: w.byKind}
+	}
+
+	inline := int64(value.Type().Size())
+	w.byKind[KindInline] += inline
+	total := inline + w.indirect(value)
+
+	return &Result{Total: total, ByKind: w.byKind, Objects: w.objects}
+}
+
+// visit 记录地址，返回是否首次访问
+func (w *walker) visit(addr uintptr) bool {
+	if addr == 0 || w.seen[addr] {
+		return false
+	}
+	w.seen[addr] = true
+	w.objects++
+	return true
+}
+
+// indirect 返回 v 间接引用的内存大小（不包含 v 本身）
+func (w *walker) indirect(v reflect.Value) int64 {
+	if skipTypes[v.Type()] {
+		return 0
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() || !w.visit(v.Pointer()) {
+			return 0
+		}
+		elem := v.Elem()
+		size := int64(elem.Type().Size())
+		w.byKind[KindPointer] += size
+		return size + w.indirect(elem)
+
+	case reflect.Slice:
+		if v.IsNil() || v.Cap() == 0 || !w.visit(v.Pointer()) {
+			return 0
+		}
+		size := int64(v.Cap()) * int64(v.Type().Elem().Size())
+		w.byKind[KindSlice] += size
+		if hasPointers(v.Type().Elem()) {
+			for i := 0; i < v.Len(); i++ {
+				size += w.indirect(v.Index(i))
+			}
+		}
+		return size
+
+	case reflect.String:
+		s := v.String()
+		if len(s) == 0 || !w.visit(uintptr(unsafe.Pointer(unsafe.StringData(s)))) {
+			return 0
+		}
+		size := int64(len(s))
+		w.byKind[KindString] += size
+		return size
+
+	case reflect.Map:
+		if v.IsNil() || !w.visit(v.Pointer()) {
+			return 0
+		}
+		size := mapBucketsSize(v.Type(), v.Len())
+		w.byKind[KindMap] += size
+		keyPointers, valuePointers := hasPointers(v.Type().Key()), hasPointers(v.Type().Elem())
+		if keyPointers || valuePointers {
+			iter := v.MapRange()
+			for iter.Next() {
+				if keyPointers {
+					size += w.indirect(iter.Key())
+				}
+				if valuePointers {
+					size += w.indirect(iter.Value())
+				}
+			}
+		}
+		return size
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+		elem := v.Elem()
+		switch elem.Kind() {
+		case reflect.Ptr, reflect.Map, reflect.Chan, reflect.Func, reflect.UnsafePointer:
+			// 指针形状的值直接存放在接口中
+			return w.indirect(elem)
+		default:
+			// 其他值被装箱到堆上
+			size := int64(elem.Type().Size())
+			w.byKind[KindPointer] += size
+			return size + w.indirect(elem)
+		}
+
+	case reflect.Struct:
+		size := int64(0)
+		for i := 0; i < v.NumField(); i++ {
+			size += w.indirect(v.Field(i))
+		}
+		return size
+
+	case reflect.Array:
+		size := int64(0)
+		if hasPointers(v.Type().Elem()) {
+			for i := 0; i < v.Len(); i++ {
+				size += w.indirect(v.Index(i))
+			}
+		}
+		return size
+
+	case reflect.Chan:
+		if v.IsNil() || !w.visit(v.Pointer()) {
+			return 0
+		}
+		size := int64(chanHeaderSize) + int64(v.Cap())*int64(v.Type().Elem().Size())
+		w.byKind[KindChan] += size
+		return size
+
+	default:
+		// 基本类型、函数、unsafe.Pointer 不计入间接内存
+		return 0
+	}
+}
+
+// mapBucketsSize 按运行时哈希桶布局估算map占用
+func mapBucketsSize(t reflect.Type, length int) int64 {
+	keySize, valueSize := int64(t.Key().Size()), int64(t.Elem().Size())
+	if keySize > mapMaxInlineKV {
+		keySize = pointerSize
+	}
+	if valueSize > mapMaxInlineKV {
+		valueSize = pointerSize
+	}
+
+	buckets := int64(1)
+	for float64(length) > mapLoadFactor*float64(buckets) {
+		buckets *= 2
+	}
+	bucketSize := mapBucketSlots + mapBucketSlots*(keySize+valueSize) + pointerSize
+
+	return mapHeaderSize + buckets*bucketSize
+}
+
+// hasPointers 判断类型是否可能引用其他内存
+func hasPointers(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.String, reflect.Map, reflect.Interface, reflect.Chan:
+		return true
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if hasPointers(t.Field(i).Type) {
+				return true
+			}
+		}
+		return false
+	case reflect.Array:
+		return t.Len() > 0 && hasPointers(t.Elem())
+	default:
+		return false
+	}
+}
+
+// FormatBytes 将字节数格式化为易读的形式
+func FormatBytes(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.2f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.2f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.2f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
+
+// 场景示例：浅大小与深度大小的差异
+func SizeofDemo() {
+	fmt.Println("深度内存估算示例:")
+
+	type User struct {
+		ID    int64
+		Name  string
+		Tags  []string
+		Attrs map[string]string
+	}
+
+	user := &User{
+		ID:    1,
+		Name:  "张三",
+		Tags:  []string{"vip", "beta-tester", "beijing"},
+		Attrs: map[string]string{"city": "北京", "level": "gold"},
+	}
+	fmt.Printf("unsafe.Sizeof(*user) = %d 字节（只包含结构体本身）\n", unsafe.Sizeof(*user))
+	fmt.Printf("深度估算: %s\n", Measure(user))
+
+	// 共享对象只计算一次
+	shared := make([]byte, 1024)
+	pair := [2][]byte{shared, shared}
+	fmt.Printf("两个切片共享同一个1KB数组: %s\n", Measure(pair))
+
+	// 不同的整数集合表示方式
+	const n = 10000
+	set := make(map[int]struct{}, n)
+	list := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		set[i] = struct{}{}
+		list = append(list, i)
+	}
+	fmt.Printf("%d个整数: map[int]struct{} %s, []int %s\n",
+		n, FormatBytes(Of(set)), FormatBytes(Of(list)))
+}
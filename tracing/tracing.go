@@ -0,0 +1,491 @@
+package tracing
+
+/*
+轻量级进程内链路追踪
+
+原理：
+链路追踪把一次请求拆成若干个"Span"（片段），每个Span记录名称、开始/结束时间和属性，
+Span之间通过父子关系连接成一棵树（Trace）。分析这棵树就能知道时间花在了哪里，
+而不需要借助外部的追踪系统（如Jaeger、Zipkin）。
+
+关键特点：
+1. StartSpan/Finish 成对使用，子Span自动继承TraceID并记录父SpanID
+2. 支持为Span附加任意属性（如节点数、块数量、结果大小）
+3. Span结束后交给导出器（Exporter），默认导出到内存
+4. 提供树形文本报告和火焰图折叠格式（folded stacks）两种输出
+5. 默认追踪器默认关闭（SetEnabled 开启），关闭时 StartSpan 返回共享的空Span，不分配内存、不生成ID，
+   埋点留在热点路径上也几乎没有开销
+
+实现方式：
+- Tracer 负责创建Span并在Finish时调用导出器
+- InMemoryExporter 以环形缓冲保存最近的Span，避免长期运行时内存无限增长
+- 报告时按TraceID分组，根据父子关系重建调用树
+- 支持通过 context.Context 传递当前Span；上下文中没有Span且默认追踪器关闭时直接返回空Span
+- ID 由非加密的伪随机数生成，只要求在进程内不重复
+
+应用场景：
+- 分析多阶段算法（外部排序、推荐、路径规划）的耗时分布
+- 在没有外部基础设施的情况下进行性能排查
+- 教学演示：直观展示调用链和各阶段占比
+
+优缺点：
+- 优点：零依赖，使用简单，报告直观
+- 缺点：只在单进程内有效，不支持跨进程传播；内存导出器只保留最近的Span
+
+以下实现了一个最小可用的追踪门面、内存导出器和文本报告。
+*/
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultMaxSpans 内存导出器默认保留的最大Span数量
+const DefaultMaxSpans = 4096
+
+// Span 一次操作的追踪片段
+type Span struct {
+	TraceID    string                 // 所属链路ID
+	SpanID     string                 // 自身ID
+	ParentID   string                 // 父SpanID（根Span为空）
+	Name       string                 // 操作名称
+	StartTime  time.Time              // 开始时间
+	EndTime    time.Time              // 结束时间
+	Attributes map[string]interface{} // 属性
+
+	tracer   *Tracer    // 创建该Span的追踪器，空Span为nil
+	mutex    sync.Mutex // 保护属性和结束状态
+	finished bool       // 是否已结束
+}
+
+// noopSpan 追踪关闭时返回的空Span，所有操作都不做任何事
+var noopSpan = &Span{}
+
+// Recording 是否在记录：空Span返回false，调用方可以据此跳过计算属性的开销
+func (s *Span) Recording() bool {
+	return s.tracer != nil
+}
+
+// SetAttribute 设置属性
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if !s.Recording() {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.Attributes[key] = value
+}
+
+// StartChild 创建子Span
+func (s *Span) StartChild(name string) *Span {
+	if !s.Recording() {
+		return noopSpan
+	}
+	return s.tracer.newSpan(name, s.TraceID, s.SpanID)
+}
+
+// Finish 结束Span并导出，重复调用无效
+func (s *Span) Finish() {
+	if !s.Recording() {
+		return
+	}
+	s.mutex.Lock()
+	if s.finished {
+		s.mutex.Unlock()
+		return
+	}
+	s.finished = true
+	s.EndTime = time.Now()
+	s.mutex.Unlock()
+
+	s.tracer.exporter.Export(s)
+}
+
+// Duration 返回Span耗时（未结束时返回到目前为止的耗时）
+func (s *Span) Duration() time.Duration {
+	if !s.Recording() {
+		return 0
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !s.finished {
+		return time.Since(s.StartTime)
+	}
+	return s.EndTime.Sub(s.StartTime)
+}
+
+// attributesString 以稳定顺序格式化属性
+func (s *Span) attributesString() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(s.Attributes) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(s.Attributes))
+	for k := range s.Attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", k, s.Attributes[k]))
+	}
+	return strings.Join(pairs, " ")
+}
+
+// Exporter Span导出器接口
+type Exporter interface {
+	// Export 导出一个已结束的Span
+	Export(span *Span)
+}
+
+// Tracer 追踪器
+type Tracer struct {
+	exporter Exporter
+}
+
+// NewTracer 创建使用指定导出器的追踪器，exporter 为nil时追踪器只返回空Span
+func NewTracer(exporter Exporter) *Tracer {
+	return &Tracer{exporter: exporter}
+}
+
+// StartSpan 开始一个新的根Span（新的TraceID）
+func (t *Tracer) StartSpan(name string) *Span {
+	if t == nil || t.exporter == nil {
+		return noopSpan
+	}
+	return t.newSpan(name, NewID(16), "")
+}
+
+// newSpan 创建Span
+func (t *Tracer) newSpan(name, traceID, parentID string) *Span {
+	return &Span{
+		TraceID:    traceID,
+		SpanID:     NewID(8),
+		ParentID:   parentID,
+		Name:       name,
+		StartTime:  time.Now(),
+		Attributes: make(map[string]interface{}),
+		tracer:     t,
+	}
+}
+
+// NewID 生成 n 字节的十六进制随机ID，TraceID 用16字节、SpanID 用8字节；
+// 使用非加密的伪随机数，只需在进程内不重复，concurrency 的任务上下文也用它生成追踪ID
+func NewID(n int) string {
+	buf := make([]byte, n)
+	var word uint64
+	for i := range buf {
+		if i%8 == 0 {
+			word = rand.Uint64()
+		}
+		buf[i] = byte(word)
+		word >>= 8
+	}
+	return hex.EncodeToString(buf)
+}
+
+// 默认追踪器和导出器
+var (
+	defaultExporter = NewInMemoryExporter(DefaultMaxSpans)
+	defaultTracer   = NewTracer(defaultExporter)
+	defaultMutex    sync.RWMutex
+	defaultEnabled  atomic.Bool // 默认追踪器是否开启，默认关闭
+)
+
+// SetEnabled 开启或关闭默认追踪器，返回之前的状态，便于 defer tracing.SetEnabled(tracing.SetEnabled(true)) 恢复
+func SetEnabled(enabled bool) bool {
+	return defaultEnabled.Swap(enabled)
+}
+
+// Enabled 默认追踪器是否开启
+func Enabled() bool {
+	return defaultEnabled.Load()
+}
+
+// SetDefaultTracer 替换全局默认追踪器
+func SetDefaultTracer(t *Tracer) {
+	defaultMutex.Lock()
+	defer defaultMutex.Unlock()
+	defaultTracer = t
+}
+
+// DefaultTracer 返回全局默认追踪器
+func DefaultTracer() *Tracer {
+	defaultMutex.RLock()
+	defer defaultMutex.RUnlock()
+	return defaultTracer
+}
+
+// DefaultExporter 返回默认的内存导出器
+func DefaultExporter() *InMemoryExporter {
+	return defaultExporter
+}
+
+// StartSpan 使用默认追踪器开始一个根Span，默认追踪器关闭时返回空Span
+func StartSpan(name string) *Span {
+	if !Enabled() {
+		return noopSpan
+	}
+	return DefaultTracer().StartSpan(name)
+}
+
+// spanContextKey 在 context 中存放当前Span的键
+type spanContextKey struct{}
+
+// ContextWithSpan 将Span放入上下文
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, span)
+}
+
+// SpanFromContext 从上下文中取出当前Span
+func SpanFromContext(ctx context.Context) (*Span, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	span, ok := ctx.Value(spanContextKey{}).(*Span)
+	return span, ok
+}
+
+// StartSpanFromContext 如果上下文中有Span则创建其子Span，否则创建根Span；
+// 没有可记录的Span时原样返回上下文，不做任何分配
+func StartSpanFromContext(ctx context.Context, name string) (*Span, context.Context) {
+	var span *Span
+	if parent, ok := SpanFromContext(ctx); ok {
+		span = parent.StartChild(name)
+	} else {
+		span = StartSpan(name)
+	}
+	if !span.Recording() {
+		return span, ctx
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return span, ContextWithSpan(ctx, span)
+}
+
+// InMemoryExporter 内存导出器，以环形缓冲保存最近的Span
+type InMemoryExporter struct {
+	spans    []*Span
+	maxSpans int
+	next     int  // 下一个写入位置
+	full     bool // 缓冲区是否已写满
+	mutex    sync.Mutex
+}
+
+// NewInMemoryExporter 创建内存导出器
+func NewInMemoryExporter(maxSpans int) *InMemoryExporter {
+	if maxSpans <= 0 {
+		maxSpans = DefaultMaxSpans
+	}
+	return &InMemoryExporter{
+		spans:    make([]*Span, maxSpans),
+		maxSpans: maxSpans,
+	}
+}
+
+// Export 保存Span
+func (e *InMemoryExporter) Export(span *Span) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.spans[e.next] = span
+	e.next = (e.next + 1) % e.maxSpans
+	if e.next == 0 {
+		e.full = true
+	}
+}
+
+// Spans 按结束顺序返回所有保存的Span
+func (e *InMemoryExporter) Spans() []*Span {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if !e.full {
+		result := make([]*Span, e.next)
+		copy(result, e.spans[:e.next])
+		return result
+	}
+
+	result := make([]*Span, 0, e.maxSpans)
+	result = append(result, e.spans[e.next:]...)
+	result = append(result, e.spans[:e.next]...)
+	return result
+}
+
+// Reset 清空已保存的Span
+func (e *InMemoryExporter) Reset() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.spans = make([]*Span, e.maxSpans)
+	e.next = 0
+	e.full = false
+}
+
+// spanNode 报告中使用的调用树节点
+type spanNode struct {
+	span     *Span
+	children []*spanNode
+}
+
+// buildTrees 按TraceID分组并重建调用树，返回按开始时间排序的根节点
+func buildTrees(spans []*Span) []*spanNode {
+	nodes := make(map[string]*spanNode, len(spans))
+	for _, span := range spans {
+		nodes[span.SpanID] = &spanNode{span: span}
+	}
+
+	roots := make([]*spanNode, 0)
+	for _, node := range nodes {
+		if parent, ok := nodes[node.span.ParentID]; ok && node.span.ParentID != "" {
+			parent.children = append(parent.children, node)
+		} else {
+			// 父Span未导出（未结束或已被环形缓冲淘汰）时作为根节点展示
+			roots = append(roots, node)
+		}
+	}
+
+	var sortTree func(list []*spanNode)
+	sortTree = func(list []*spanNode) {
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].span.StartTime.Before(list[j].span.StartTime)
+		})
+		for _, n := range list {
+			sortTree(n.children)
+		}
+	}
+	sortTree(roots)
+
+	return roots
+}
+
+// Report 输出树形文本报告，每个Span显示耗时、相对根Span的占比条形图和属性
+func (e *InMemoryExporter) Report(w io.Writer) {
+	roots := buildTrees(e.Spans())
+	if len(roots) == 0 {
+		fmt.Fprintln(w, "(没有追踪数据)")
+		return
+	}
+
+	const barWidth = 30
+	for _, root := range roots {
+		total := root.span.Duration()
+		fmt.Fprintf(w, "trace %s  总耗时 %v\n", root.span.TraceID[:8], total.Round(time.Microsecond))
+
+		var walk func(node *spanNode, depth int)
+		walk = func(node *spanNode, depth int) {
+			d := node.span.Duration()
+			ratio := 0.0
+			if total > 0 {
+				ratio = float64(d) / float64(total)
+			}
+			bars := int(ratio * barWidth)
+			if bars > barWidth {
+				bars = barWidth
+			}
+
+			label := strings.Repeat("  ", depth) + node.span.Name
+			fmt.Fprintf(w, "  %-40s %12v %-*s %5.1f%%",
+				label, d.Round(time.Microsecond), barWidth, strings.Repeat("█", bars), ratio*100)
+			if attrs := node.span.attributesString(); attrs != "" {
+				fmt.Fprintf(w, "  [%s]", attrs)
+			}
+			fmt.Fprintln(w)
+
+			for _, child := range node.children {
+				walk(child, depth+1)
+			}
+		}
+		walk(root, 0)
+		fmt.Fprintln(w)
+	}
+}
+
+// FoldedStacks 输出火焰图折叠格式（"a;b;c 自身耗时微秒"），可直接交给 flamegraph.pl 生成SVG
+func (e *InMemoryExporter) FoldedStacks() []string {
+	roots := buildTrees(e.Spans())
+	stacks := make(map[string]int64)
+
+	var walk func(node *spanNode, prefix string)
+	walk = func(node *spanNode, prefix string) {
+		path := node.span.Name
+		if prefix != "" {
+			path = prefix + ";" + node.span.Name
+		}
+
+		// 自身耗时 = 总耗时 - 子Span耗时之和
+		self := node.span.Duration()
+		for _, child := range node.children {
+			self -= child.span.Duration()
+			walk(child, path)
+		}
+		if self < 0 {
+			self = 0
+		}
+		stacks[path] += self.Microseconds()
+	}
+	for _, root := range roots {
+		walk(root, "")
+	}
+
+	lines := make([]string, 0, len(stacks))
+	for path, micros := range stacks {
+		lines = append(lines, fmt.Sprintf("%s %d", path, micros))
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// 场景示例：追踪一次API请求的处理过程
+func TracingDemo() {
+	fmt.Println("链路追踪示例 - 一次商品详情请求:")
+
+	exporter := NewInMemoryExporter(128)
+	tracer := NewTracer(exporter)
+
+	root := tracer.StartSpan("GET /product/1001")
+	root.SetAttribute("user", "u-42")
+
+	auth := root.StartChild("鉴权")
+	time.Sleep(3 * time.Millisecond)
+	auth.Finish()
+
+	ctx := ContextWithSpan(context.Background(), root)
+	loadSpan, ctx := StartSpanFromContext(ctx, "加载商品")
+	cacheSpan, _ := StartSpanFromContext(ctx, "查询缓存")
+	cacheSpan.SetAttribute("hit", false)
+	time.Sleep(2 * time.Millisecond)
+	cacheSpan.Finish()
+
+	dbSpan, _ := StartSpanFromContext(ctx, "查询数据库")
+	dbSpan.SetAttribute("rows", 1)
+	time.Sleep(12 * time.Millisecond)
+	dbSpan.Finish()
+	loadSpan.Finish()
+
+	render := root.StartChild("渲染响应")
+	time.Sleep(4 * time.Millisecond)
+	render.Finish()
+	root.Finish()
+
+	fmt.Println("\n=== 调用树报告 ===")
+	var sb strings.Builder
+	exporter.Report(&sb)
+	fmt.Print(sb.String())
+
+	fmt.Println("=== 火焰图折叠格式 ===")
+	for _, line := range exporter.FoldedStacks() {
+		fmt.Println(line)
+	}
+}
@@ -0,0 +1,500 @@
+package workload
+
+/*
+负载生成器
+
+原理：
+缓存、限流器、存储系统的表现强烈依赖于负载特征：访问哪些键（键分布）以及请求何时到达（到达过程）。
+用 rand.Intn 生成的均匀随机键无法体现真实流量中的热点、扫描和时间局部性，
+用固定间隔发送的请求也无法体现真实流量中的突发。
+负载生成器把这两个维度抽象出来，让各个演示和压测使用同一套可配置、可复现的负载。
+
+关键特点：
+1. 键分布：均匀、Zipf、热点（二八分布）、顺序扫描、时间局部性，以及按权重混合
+2. 到达过程：恒定速率、泊松过程、突发（开关调制的泊松过程）
+3. 所有生成器都接受随机种子，相同种子产生相同序列，结果可复现
+4. 键以整数编号生成，可按需格式化为字符串或字节切片
+
+实现方式：
+- Zipf 使用标准库 rand.Zipf（要求偏斜系数 s > 1）
+- 热点分布：以给定概率访问前 hotFraction 比例的键
+- 时间局部性：以给定概率从最近访问的滑动窗口中重复选取，否则从底层分布取新键
+- 泊松过程的到达间隔服从指数分布；突发过程在高低两种速率之间周期切换
+
+应用场景：
+- 缓存淘汰策略的命中率对比
+- 限流器在突发流量下的行为验证
+- 键值存储的压力测试
+
+优缺点：
+- 优点：负载特征明确、可复现，多个模块共享同一套生成器
+- 缺点：生成器本身不是并发安全的，多个协程需各自创建生成器
+
+以下实现了常用的键分布和到达过程。
+*/
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/strive/scenario/practical_applications"
+)
+
+// KeyGenerator 键生成器，返回 [0, Keys()) 范围内的键编号（非并发安全）
+type KeyGenerator interface {
+	Next() uint64
+	Keys() uint64
+}
+
+// DefaultKeyPrefix 默认的键前缀
+const DefaultKeyPrefix = "key-"
+
+// FormatKey 将键编号格式化为字符串
+func FormatKey(prefix string, index uint64) string {
+	return fmt.Sprintf("%s%d", prefix, index)
+}
+
+// Strings 生成 n 个字符串键
+func Strings(gen KeyGenerator, n int, prefix string) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = FormatKey(prefix, gen.Next())
+	}
+	return keys
+}
+
+// UniformGenerator 均匀分布
+type UniformGenerator struct {
+	keys uint64
+	rng  *rand.Rand
+}
+
+// NewUniform 创建均匀分布的键生成器
+func NewUniform(keys uint64, seed int64) *UniformGenerator {
+	if keys == 0 {
+		keys = 1
+	}
+	return &UniformGenerator{keys: keys, rng: rand.New(rand.NewSource(seed))}
+}
+
+// Next 返回下一个键编号
+func (g *UniformGenerator) Next() uint64 { return uint64(g.rng.Int63n(int64(g.keys))) }
+
+// Keys 返回键空间大小
+func (g *UniformGenerator) Keys() uint64 { return g.keys }
+
+// ZipfGenerator Zipf分布：编号越小的键越热
+type ZipfGenerator struct {
+	keys uint64
+	zipf *rand.Zipf
+}
+
+// NewZipf 创建Zipf分布的键生成器，skew 必须大于1（越大越集中）
+func NewZipf(keys uint64, skew float64, seed int64) *ZipfGenerator {
+	if keys == 0 {
+		keys = 1
+	}
+	if skew <= 1 {
+		skew = 1.01
+	}
+	rng := rand.New(rand.NewSource(seed))
+	return &ZipfGenerator{keys: keys, zipf: rand.NewZipf(rng, skew, 1, keys-1)}
+}
+
+// Next 返回下一个键编号
+func (g *ZipfGenerator) Next() uint64 { return g.zipf.Uint64() }
+
+// Keys 返回键空间大小
+func (g *ZipfGenerator) Keys() uint64 { return g.keys }
+
+// HotspotGenerator 热点分布：hotProbability 的请求落在前 hotFraction 的键上
+type HotspotGenerator struct {
+	keys           uint64
+	hotKeys        uint64
+	hotProbability float64
+	rng            *rand.Rand
+}
+
+// NewHotspot 创建热点分布的键生成器，例如 (0.2, 0.8) 表示80%的请求访问20%的键
+func NewHotspot(keys uint64, hotFraction, hotProbability float64, seed int64) *HotspotGenerator {
+	if keys == 0 {
+		keys = 1
+	}
+	hotKeys := uint64(float64(keys) * hotFraction)
+	if hotKeys == 0 {
+		hotKeys = 1
+	}
+	if hotKeys > keys {
+		hotKeys = keys
+	}
+	return &HotspotGenerator{
+		keys:           keys,
+		hotKeys:        hotKeys,
+		hotProbability: hotProbability,
+		rng:            rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Next 返回下一个键编号
+func (g *HotspotGenerator) Next() uint64 {
+	if g.rng.Float64() < g.hotProbability || g.hotKeys == g.keys {
+		return uint64(g.rng.Int63n(int64(g.hotKeys)))
+	}
+	return g.hotKeys + uint64(g.rng.Int63n(int64(g.keys-g.hotKeys)))
+}
+
+// Keys 返回键空间大小
+func (g *HotspotGenerator) Keys() uint64 { return g.keys }
+
+// SequentialGenerator 顺序扫描：依次访问每个键，到末尾后从头开始
+type SequentialGenerator struct {
+	keys    uint64
+	current uint64
+}
+
+// NewSequential 创建顺序扫描的键生成器，从 start 开始
+func NewSequential(keys, start uint64) *SequentialGenerator {
+	if keys == 0 {
+		keys = 1
+	}
+	return &SequentialGenerator{keys: keys, current: start % keys}
+}
+
+// Next 返回下一个键编号
+func (g *SequentialGenerator) Next() uint64 {
+	key := g.current
+	g.current = (g.current + 1) % g.keys
+	return key
+}
+
+// Keys 返回键空间大小
+func (g *SequentialGenerator) Keys() uint64 { return g.keys }
+
+// TemporalLocalityGenerator 时间局部性：最近访问过的键有较高概率被再次访问
+type TemporalLocalityGenerator struct {
+	base        KeyGenerator // 产生新键的底层分布
+	window      []uint64     // 最近访问的键（环形缓冲区）
+	position    int          // 下一个写入位置
+	filled      int          // 已写入数量
+	reuseChance float64      // 从窗口中重复访问的概率
+	rng         *rand.Rand
+}
+
+// NewTemporalLocality 创建时间局部性的键生成器
+func NewTemporalLocality(base KeyGenerator, windowSize int, reuseChance float64, seed int64) *TemporalLocalityGenerator {
+	if windowSize <= 0 {
+		windowSize = 1
+	}
+	return &TemporalLocalityGenerator{
+		base:        base,
+		window:      make([]uint64, windowSize),
+		reuseChance: reuseChance,
+		rng:         rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Next 返回下一个键编号
+func (g *TemporalLocalityGenerator) Next() uint64 {
+	var key uint64
+	if g.filled > 0 && g.rng.Float64() < g.reuseChance {
+		key = g.window[g.rng.Intn(g.filled)]
+	} else {
+		key = g.base.Next()
+	}
+
+	g.window[g.position] = key
+	g.position = (g.position + 1) % len(g.window)
+	if g.filled < len(g.window) {
+		g.filled++
+	}
+	return key
+}
+
+// Keys 返回键空间大小
+func (g *TemporalLocalityGenerator) Keys() uint64 { return g.base.Keys() }
+
+// MixedGenerator 按权重混合多个键分布
+type MixedGenerator struct {
+	generators []KeyGenerator
+	cumulative []float64
+	offsets    []uint64
+	keys       uint64
+	rng        *rand.Rand
+}
+
+// NewMixed 创建混合分布的键生成器，各分布的键空间首尾相接，互不重叠
+func NewMixed(generators []KeyGenerator, weights []float64, seed int64) *MixedGenerator {
+	g := &MixedGenerator{
+		generators: generators,
+		cumulative: make([]float64, len(generators)),
+		offsets:    make([]uint64, len(generators)),
+		rng:        rand.New(rand.NewSource(seed)),
+	}
+
+	total := 0.0
+	for i := range generators {
+		weight := 1.0
+		if i < len(weights) {
+			weight = weights[i]
+		}
+		total += weight
+		g.cumulative[i] = total
+		g.offsets[i] = g.keys
+		g.keys += generators[i].Keys()
+	}
+	for i := range g.cumulative {
+		g.cumulative[i] /= total
+	}
+
+	return g
+}
+
+// Next 返回下一个键编号
+func (g *MixedGenerator) Next() uint64 {
+	r := g.rng.Float64()
+	for i, c := range g.cumulative {
+		if r < c || i == len(g.cumulative)-1 {
+			return g.offsets[i] + g.generators[i].Next()
+		}
+	}
+	return 0
+}
+
+// Keys 返回键空间大小
+func (g *MixedGenerator) Keys() uint64 { return g.keys }
+
+// ArrivalProcess 到达过程，返回距下一个请求的时间间隔（非并发安全）
+type ArrivalProcess interface {
+	NextInterval() time.Duration
+}
+
+// ConstantArrival 恒定速率
+type ConstantArrival struct {
+	interval time.Duration
+}
+
+// NewConstantArrival 创建恒定速率的到达过程（每秒 rate 个请求）
+func NewConstantArrival(rate float64) *ConstantArrival {
+	return &ConstantArrival{interval: rateToInterval(rate)}
+}
+
+// NextInterval 返回下一个到达间隔
+func (a *ConstantArrival) NextInterval() time.Duration { return a.interval }
+
+// PoissonArrival 泊松过程：到达间隔服从指数分布
+type PoissonArrival struct {
+	rate float64
+	rng  *rand.Rand
+}
+
+// NewPoissonArrival 创建平均每秒 rate 个请求的泊松到达过程
+func NewPoissonArrival(rate float64, seed int64) *PoissonArrival {
+	return &PoissonArrival{rate: rate, rng: rand.New(rand.NewSource(seed))}
+}
+
+// NextInterval 返回下一个到达间隔
+func (a *PoissonArrival) NextInterval() time.Duration {
+	if a.rate <= 0 {
+		return time.Second
+	}
+	return time.Duration(a.rng.ExpFloat64() / a.rate * float64(time.Second))
+}
+
+// BurstyArrival 突发到达：在平稳期和突发期之间周期切换，两个阶段内均为泊松过程
+type BurstyArrival struct {
+	baseRate      float64       // 平稳期速率（每秒）
+	burstRate     float64       // 突发期速率（每秒）
+	burstDuration time.Duration // 每个周期中突发期的时长
+	period        time.Duration // 周期
+	elapsed       time.Duration // 模拟时间
+	rng           *rand.Rand
+}
+
+// NewBurstyArrival 创建突发到达过程：每个 period 的开头有 burstDuration 的突发期
+func NewBurstyArrival(baseRate, burstRate float64, burstDuration, period time.Duration, seed int64) *BurstyArrival {
+	if period <= 0 {
+		period = time.Second
+	}
+	if burstDuration > period {
+		burstDuration = period
+	}
+	return &BurstyArrival{
+		baseRate:      baseRate,
+		burstRate:     burstRate,
+		burstDuration: burstDuration,
+		period:        period,
+		rng:           rand.New(rand.NewSource(seed)),
+	}
+}
+
+// InBurst 当前是否处于突发期
+func (a *BurstyArrival) InBurst() bool {
+	return a.elapsed%a.period < a.burstDuration
+}
+
+// NextInterval 返回下一个到达间隔
+func (a *BurstyArrival) NextInterval() time.Duration {
+	rate := a.baseRate
+	if a.InBurst() {
+		rate = a.burstRate
+	}
+	interval := time.Second
+	if rate > 0 {
+		interval = time.Duration(a.rng.ExpFloat64() / rate * float64(time.Second))
+	}
+	a.elapsed += interval
+	return interval
+}
+
+// rateToInterval 将每秒请求数换算为请求间隔
+func rateToInterval(rate float64) time.Duration {
+	if rate <= 0 {
+		return time.Second
+	}
+	return time.Duration(float64(time.Second) / rate)
+}
+
+// Request 负载中的一个请求
+type Request struct {
+	Key    string        // 请求的键
+	Offset time.Duration // 相对负载开始的时间
+}
+
+// Schedule 预先生成 n 个请求的时间表（不等待，适合离线回放）
+func Schedule(keys KeyGenerator, arrivals ArrivalProcess, n int, prefix string) []Request {
+	requests := make([]Request, n)
+	offset := time.Duration(0)
+	for i := range requests {
+		offset += arrivals.NextInterval()
+		requests[i] = Request{Key: FormatKey(prefix, keys.Next()), Offset: offset}
+	}
+	return requests
+}
+
+// Stream 按到达过程实时产生请求，ctx 结束或产生 n 个请求（n <= 0 表示不限）后关闭通道
+func Stream(ctx context.Context, keys KeyGenerator, arrivals ArrivalProcess, n int, prefix string) <-chan Request {
+	ch := make(chan Request)
+
+	go func() {
+		defer close(ch)
+		start := time.Now()
+		offset := time.Duration(0)
+
+		for i := 0; n <= 0 || i < n; i++ {
+			offset += arrivals.NextInterval()
+			if wait := offset - time.Since(start); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+					return
+				}
+			}
+
+			select {
+			case ch <- Request{Key: FormatKey(prefix, keys.Next()), Offset: offset}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch
+}
+
+// 场景示例：用统一的负载生成器驱动限流器和键值存储
+func WorkloadDemo() {
+	fmt.Println("负载生成器示例:")
+
+	// 1. 各种键分布的特征
+	fmt.Println("\n[键分布] 10000个键、100000次访问:")
+	const keys, draws = 10000, 100000
+	distributions := []struct {
+		name string
+		gen  KeyGenerator
+	}{
+		{"均匀分布", NewUniform(keys, 1)},
+		{"Zipf(1.2)", NewZipf(keys, 1.2, 1)},
+		{"热点(20%键/80%请求)", NewHotspot(keys, 0.2, 0.8, 1)},
+		{"顺序扫描", NewSequential(keys, 0)},
+		{"时间局部性(窗口100/重复70%)", NewTemporalLocality(NewUniform(keys, 1), 100, 0.7, 1)},
+		{"混合(90%Zipf+10%扫描)", NewMixed([]KeyGenerator{NewZipf(keys, 1.2, 1), NewSequential(keys, 0)}, []float64{0.9, 0.1}, 1)},
+	}
+	for _, d := range distributions {
+		counts := make(map[uint64]int)
+		for i := 0; i < draws; i++ {
+			counts[d.gen.Next()]++
+		}
+		// 访问最多的100个键占总访问的比例
+		top := make([]int, 0, len(counts))
+		for _, c := range counts {
+			top = append(top, c)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(top)))
+		topSum := 0
+		for i := 0; i < 100 && i < len(top); i++ {
+			topSum += top[i]
+		}
+		fmt.Printf("  去重键数: %5d, Top100键占比: %5.1f%%  %s\n",
+			len(counts), float64(topSum)/draws*100, d.name)
+	}
+
+	// 2. 突发流量下的限流器
+	fmt.Println("\n[到达过程] 突发流量驱动令牌桶限流器（平稳期20/s，突发期200/s）:")
+	limiter := practical_applications.NewTokenBucket(30, 10)
+	arrivals := NewBurstyArrival(20, 200, 200*time.Millisecond, 500*time.Millisecond, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+	defer cancel()
+
+	type window struct{ passed, limited int }
+	windows := make(map[int]*window)
+	for req := range Stream(ctx, NewZipf(100, 1.2, 1), arrivals, 0, "user-") {
+		slot := int(req.Offset / (250 * time.Millisecond))
+		if windows[slot] == nil {
+			windows[slot] = &window{}
+		}
+		if limiter.Allow() {
+			windows[slot].passed++
+		} else {
+			windows[slot].limited++
+		}
+	}
+	for slot := 0; slot < len(windows); slot++ {
+		if w := windows[slot]; w != nil {
+			fmt.Printf("  %4dms-%4dms: 通过 %3d, 限流 %3d\n", slot*250, (slot+1)*250, w.passed, w.limited)
+		}
+	}
+
+	// 3. 键值存储压力测试（热点读多写少）
+	fmt.Println("\n[压力测试] 跳表键值存储，热点分布，读写比 9:1:")
+	store := practical_applications.NewSkiplistKVStore()
+	defer store.Close()
+
+	gen := NewHotspot(50000, 0.1, 0.9, 1)
+	rng := rand.New(rand.NewSource(1))
+	requests := Schedule(gen, NewPoissonArrival(100000, 1), 200000, "item-")
+
+	start := time.Now()
+	reads, hits := 0, 0
+	for _, req := range requests {
+		if rng.Float64() < 0.1 {
+			store.Set([]byte(req.Key), []byte("value"))
+		} else {
+			reads++
+			if _, err := store.Get([]byte(req.Key)); err == nil {
+				hits++
+			}
+		}
+	}
+	elapsed := time.Since(start)
+	fmt.Printf("  %d 次操作耗时 %v，吞吐 %.0f ops/s，读命中率 %.1f%%\n",
+		len(requests), elapsed.Round(time.Millisecond),
+		float64(len(requests))/elapsed.Seconds(), float64(hits)/float64(reads)*100)
+	fmt.Printf("  按泊松过程(100000/s)到达时，负载的模拟时长为 %v\n",
+		requests[len(requests)-1].Offset.Round(time.Millisecond))
+}